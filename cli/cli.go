@@ -27,8 +27,9 @@ func init() {
 }
 
 var genCompletionCmd = &cobra.Command{
-	Use:   "gencompletion",
-	Short: "generate shell auto-completions",
+	Use:        "gencompletion",
+	Short:      "generate shell auto-completions",
+	Deprecated: "use `zrepl completion bash|zsh|fish`, which also completes job names",
 }
 
 type completionCmdInfo struct {
@@ -81,6 +82,10 @@ type Subcommand struct {
 	Run              func(ctx context.Context, subcommand *Subcommand, args []string) error
 	SetupFlags       func(f *pflag.FlagSet)
 	SetupSubcommands func() []*Subcommand
+	// shell completion of the positional arguments, see `zrepl completion`
+	CompleteArgs CompletionFunc
+	// shell completion of flag values, by flag name
+	CompleteFlags map[string]CompletionFunc
 
 	config    *config.Config
 	configErr error
@@ -97,6 +102,16 @@ func (s *Subcommand) Config() *config.Config {
 	return s.config
 }
 
+// ConfigPath returns the value of the --config flag, empty if the config is searched in the default locations.
+func (s *Subcommand) ConfigPath() string {
+	return rootArgs.configPath
+}
+
+// ReloadConfig parses the config file again, e.g. when the daemon is asked to reload it.
+func (s *Subcommand) ReloadConfig() (*config.Config, error) {
+	return config.ParseConfig(rootArgs.configPath)
+}
+
 func (s *Subcommand) run(cmd *cobra.Command, args []string) {
 	s.tryParseConfig()
 	ctx := context.Background()
@@ -105,9 +120,29 @@ func (s *Subcommand) run(cmd *cobra.Command, args []string) {
 	err := s.Run(ctx, s, args)
 	endTask()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%s\n", err)
-		os.Exit(1)
+		code := 1
+		if e, ok := err.(*ExitCodeError); ok {
+			code = e.Code
+		}
+		if e, ok := err.(*ExitCodeError); !ok || e.Err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+		}
+		os.Exit(code)
+	}
+}
+
+// ExitCodeError can be returned by Subcommand.Run to exit with a code other than 1.
+// If Err is nil, nothing is printed, e.g. because the subcommand has already written its output.
+type ExitCodeError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitCodeError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit code %d", e.Code)
 	}
+	return e.Err.Error()
 }
 
 func (s *Subcommand) tryParseConfig() {
@@ -145,6 +180,7 @@ func addSubcommandToCobraCmd(c *cobra.Command, s *Subcommand) {
 	if s.SetupFlags != nil {
 		s.SetupFlags(cmd.Flags())
 	}
+	subcommandsByCobraCmd[&cmd] = s
 	c.AddCommand(&cmd)
 }
 
@@ -0,0 +1,272 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/config"
+)
+
+// CompletionFunc returns the candidates for the shell completion of a positional argument or flag value.
+// conf is nil if the config cannot be parsed.
+// args are the positional arguments before the completed one.
+type CompletionFunc func(conf *config.Config, args []string) []string
+
+// ConfigJobNames returns the names of the jobs in conf, a CompletionFunc for commands that do not need the daemon.
+func ConfigJobNames(conf *config.Config, _ []string) []string {
+	if conf == nil {
+		return nil
+	}
+	names := make([]string, 0, len(conf.Jobs))
+	for _, j := range conf.Jobs {
+		names = append(names, j.Name())
+	}
+	return names
+}
+
+// CompleteNthArg is a CompletionFunc that completes only the positional argument at index n, using f.
+func CompleteNthArg(n int, f CompletionFunc) CompletionFunc {
+	return func(conf *config.Config, args []string) []string {
+		if len(args) != n {
+			return nil
+		}
+		return f(conf, args)
+	}
+}
+
+var subcommandsByCobraCmd = make(map[*cobra.Command]*Subcommand)
+
+var completionScripts = map[string]string{
+	"bash": `# bash completion for zrepl, generated by ` + "`zrepl completion bash`" + `
+_zrepl() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local IFS=$'\n'
+    COMPREPLY=( $(compgen -W "$("${COMP_WORDS[0]}" __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null)" -- "$cur") )
+}
+complete -o default -F _zrepl zrepl
+`,
+	"zsh": `#compdef zrepl
+# zsh completion for zrepl, generated by ` + "`zrepl completion zsh`" + `
+_zrepl() {
+    local -a candidates
+    candidates=("${(@f)$(${words[1]} __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    if (( ${#candidates} == 0 )) || [[ -z "${candidates[1]}" ]]; then
+        _files
+        return
+    fi
+    compadd -a candidates
+}
+if [ "$funcstack[1]" = "_zrepl" ]; then
+    _zrepl "$@"
+else
+    compdef _zrepl zrepl
+fi
+`,
+	"fish": `# fish completion for zrepl, generated by ` + "`zrepl completion fish`" + `
+function __zrepl_complete
+    set -l args (commandline -opc)
+    set -e args[1]
+    set -l cur (commandline -ct)
+    set -l candidates (command zrepl __complete $args $cur 2>/dev/null)
+    if test (count $candidates) -eq 0
+        __fish_complete_path $cur
+        return
+    end
+    printf '%s\n' $candidates
+end
+complete -c zrepl -f -a '(__zrepl_complete)'
+`,
+}
+
+var completionCmd = &cobra.Command{
+	Use:   "completion bash|zsh|fish",
+	Short: "print a shell completion script, which also completes the names of the daemon's jobs",
+	Example: `  bash: source <(zrepl completion bash), e.g. in your .bashrc
+  zsh:  zrepl completion zsh > "${fpath[1]}/_zrepl"
+  fish: zrepl completion fish > ~/.config/fish/completions/zrepl.fish`,
+	ValidArgs: []string{"bash", "zsh", "fish"},
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Fprintf(os.Stderr, "specify exactly one positional argument: bash, zsh or fish\n")
+			os.Exit(1)
+		}
+		script, ok := completionScripts[args[0]]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unsupported shell %q, use bash, zsh or fish\n", args[0])
+			os.Exit(1)
+		}
+		fmt.Print(script)
+	},
+}
+
+// called by the completion scripts with the words after `zrepl`, including the word that is completed
+var completeCmd = &cobra.Command{
+	Use:                "__complete WORDS...",
+	Hidden:             true,
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		writeCompletions(os.Stdout, rootCmd, args, func(configPath string) *config.Config {
+			conf, err := config.ParseConfig(configPath)
+			if err != nil {
+				return nil
+			}
+			return conf
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+	rootCmd.AddCommand(completeCmd)
+}
+
+func writeCompletions(w io.Writer, root *cobra.Command, words []string, parseConfig func(configPath string) *config.Config) {
+	for _, c := range complete(root, words, parseConfig) {
+		fmt.Fprintln(w, c)
+	}
+}
+
+// complete returns the candidates for the last of words, which are the arguments after the root command.
+func complete(root *cobra.Command, words []string, parseConfig func(configPath string) *config.Config) []string {
+	cur := ""
+	if len(words) > 0 {
+		cur = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	cmd := root
+	var args []string
+	var configPath string
+	var pendingFlag *pflag.Flag // the flag whose value is cur
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		if strings.HasPrefix(w, "-") && len(w) > 1 && w != "--" {
+			f, hasValue, value := lookupFlagWord(cmd, w)
+			if f == nil {
+				continue
+			}
+			if f.NoOptDefVal == "" && !hasValue {
+				if i+1 == len(words) {
+					pendingFlag = f
+					break
+				}
+				i++
+				value = words[i]
+			}
+			if f == cmd.Root().PersistentFlags().Lookup("config") {
+				configPath = value
+			}
+			continue
+		}
+		if len(args) == 0 {
+			if sub := findSubcommand(cmd, w); sub != nil {
+				cmd = sub
+				continue
+			}
+		}
+		args = append(args, w)
+	}
+
+	s := subcommandsByCobraCmd[cmd]
+	var candidates []string
+	prefix := ""
+	switch {
+	case pendingFlag != nil:
+		if s != nil && s.CompleteFlags[pendingFlag.Name] != nil {
+			candidates = s.CompleteFlags[pendingFlag.Name](parseConfig(configPath), args)
+		}
+	case strings.HasPrefix(cur, "--") && strings.Contains(cur, "="):
+		name := strings.SplitN(cur[2:], "=", 2)[0]
+		if s != nil && s.CompleteFlags[name] != nil {
+			candidates = s.CompleteFlags[name](parseConfig(configPath), args)
+			prefix = "--" + name + "="
+		}
+	case strings.HasPrefix(cur, "-"):
+		candidates = flagNames(cmd)
+	case len(args) == 0 && cmd.HasAvailableSubCommands():
+		for _, sub := range cmd.Commands() {
+			if sub.IsAvailableCommand() {
+				candidates = append(candidates, sub.Name())
+			}
+		}
+	case s != nil && s.CompleteArgs != nil:
+		candidates = s.CompleteArgs(parseConfig(configPath), args)
+	case len(args) == 0:
+		candidates = cmd.ValidArgs
+	}
+
+	var out []string
+	for _, c := range candidates {
+		c = prefix + c
+		if strings.HasPrefix(c, cur) {
+			out = append(out, c)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// lookupFlagWord returns the flag of word `--name`, `--name=value` or `-n` that is valid for cmd.
+func lookupFlagWord(cmd *cobra.Command, word string) (f *pflag.Flag, hasValue bool, value string) {
+	var name string
+	if strings.HasPrefix(word, "--") {
+		name = word[2:]
+		if i := strings.Index(name, "="); i >= 0 {
+			name, value, hasValue = name[:i], name[i+1:], true
+		}
+		for _, fs := range flagSets(cmd) {
+			if f = fs.Lookup(name); f != nil {
+				break
+			}
+		}
+		return f, hasValue, value
+	}
+	short := word[1:2]
+	if len(word) > 2 {
+		value, hasValue = word[2:], true
+	}
+	for _, fs := range flagSets(cmd) {
+		if f = fs.ShorthandLookup(short); f != nil {
+			break
+		}
+	}
+	return f, hasValue, value
+}
+
+func findSubcommand(cmd *cobra.Command, name string) *cobra.Command {
+	for _, sub := range cmd.Commands() {
+		if sub.Name() == name || sub.HasAlias(name) {
+			return sub
+		}
+	}
+	return nil
+}
+
+// the flags of cmd, including the persistent flags of cmd and its parents
+func flagSets(cmd *cobra.Command) []*pflag.FlagSet {
+	return []*pflag.FlagSet{cmd.Flags(), cmd.PersistentFlags(), cmd.InheritedFlags()}
+}
+
+func flagNames(cmd *cobra.Command) (names []string) {
+	seen := make(map[*pflag.Flag]bool)
+	add := func(f *pflag.Flag) {
+		if f.Hidden || seen[f] {
+			return
+		}
+		seen[f] = true
+		names = append(names, "--"+f.Name)
+		if f.Shorthand != "" {
+			names = append(names, "-"+f.Shorthand)
+		}
+	}
+	for _, fs := range flagSets(cmd) {
+		fs.VisitAll(add)
+	}
+	return names
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zrepl/zrepl/config"
+)
+
+func TestComplete(t *testing.T) {
+	root := &cobra.Command{Use: "zrepl"}
+	root.PersistentFlags().String("config", "", "")
+	jobs := func(conf *config.Config, args []string) []string {
+		if conf == nil {
+			return nil
+		}
+		return []string{"prod", "backup"}
+	}
+	addSubcommandToCobraCmd(root, &Subcommand{
+		Use: "signal [wakeup|reset] JOB",
+		CompleteArgs: func(conf *config.Config, args []string) []string {
+			if len(args) == 0 {
+				return []string{"wakeup", "reset"}
+			}
+			return jobs(conf, args)
+		},
+	})
+	addSubcommandToCobraCmd(root, &Subcommand{
+		Use: "status [JOB]",
+		SetupFlags: func(f *pflag.FlagSet) {
+			f.String("job", "", "")
+			f.BoolP("follow", "f", false, "")
+		},
+		CompleteArgs:  CompleteNthArg(0, jobs),
+		CompleteFlags: map[string]CompletionFunc{"job": jobs},
+	})
+
+	var parsedConfigPath string
+	parseConfig := func(configPath string) *config.Config {
+		parsedConfigPath = configPath
+		return &config.Config{}
+	}
+	c := func(words ...string) []string { return complete(root, words, parseConfig) }
+
+	assert.Equal(t, []string{"signal", "status"}, c(""))
+	assert.Equal(t, []string{"status"}, c("st"))
+	assert.Equal(t, []string{"reset", "wakeup"}, c("signal", ""))
+	assert.Equal(t, []string{"backup", "prod"}, c("signal", "wakeup", ""))
+	assert.Equal(t, []string{"prod"}, c("status", "-f", "p"))
+	assert.Nil(t, c("status", "prod", ""))
+	assert.Equal(t, []string{"backup", "prod"}, c("status", "--job", ""))
+	assert.Equal(t, []string{"--job=prod"}, c("status", "--job=p"))
+	assert.Equal(t, []string{"--config", "--follow", "--job", "-f"}, c("status", "-"))
+
+	assert.Equal(t, []string{"backup", "prod"}, c("--config", "/etc/zrepl/other.yml", "status", ""))
+	assert.Equal(t, "/etc/zrepl/other.yml", parsedConfigPath)
+	assert.Equal(t, []string{"backup", "prod"}, c("status", "--config=/tmp/z.yml", ""))
+	assert.Equal(t, "/tmp/z.yml", parsedConfigPath)
+}
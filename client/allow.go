@@ -0,0 +1,194 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"sort"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/zfs"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+var allowArgs struct {
+	job   string
+	user  string
+	apply bool
+	yes   bool
+}
+
+var AllowCmd = &cli.Subcommand{
+	Use:           "allow --user USER [--job JOB] [--apply [--yes]]",
+	Short:         "print the zfs allow delegations that the configured jobs require to run as USER, and apply them with --apply",
+	CompleteFlags: map[string]cli.CompletionFunc{"job": cli.ConfigJobNames},
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.StringVar(&allowArgs.user, "user", "", "the user that the daemon runs as (required)")
+		f.StringVar(&allowArgs.job, "job", "", "only delegate the permissions of JOB")
+		f.BoolVar(&allowArgs.apply, "apply", false, "run the zfs allow commands and verify the delegations afterwards")
+		f.BoolVarP(&allowArgs.yes, "yes", "y", false, "do not ask for confirmation (required if stdin is not a terminal)")
+	},
+	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+		if len(args) != 0 {
+			return errors.New("allow does not take arguments")
+		}
+		return runAllow(ctx, subcommand.Config(), os.Stdout)
+	},
+}
+
+// allowGrant is a `zfs allow -u` invocation.
+type allowGrant struct {
+	dataset string
+	perms   []string
+}
+
+func (g allowGrant) args(username string) []string {
+	return []string{"allow", "-u", username, strings.Join(g.perms, ","), g.dataset}
+}
+
+// allowMerge merges the datasets of several jobs or roles on the same dataset into one, sorted by path.
+func allowMerge(datasets []doctorDataset) []doctorDataset {
+	byPath := make(map[string]*doctorDataset)
+	var paths []string
+	for _, ds := range datasets {
+		m, ok := byPath[ds.path.ToString()]
+		if !ok {
+			m = &doctorDataset{job: ds.job, role: ds.role, path: ds.path}
+			byPath[ds.path.ToString()] = m
+			paths = append(paths, ds.path.ToString())
+		} else {
+			m.job = allowJoin(m.job, ds.job, ", ")
+			m.role.name = allowJoin(m.role.name, ds.role.name, "+")
+		}
+		m.perms = append(m.perms, ds.perms...)
+	}
+	sort.Strings(paths)
+	merged := make([]doctorDataset, len(paths))
+	for i, p := range paths {
+		ds := byPath[p]
+		ds.perms = allowUnique(ds.perms)
+		merged[i] = *ds
+	}
+	return merged
+}
+
+// allowJoin appends item to the sep-separated list unless it contains item.
+func allowJoin(list, item, sep string) string {
+	for _, i := range strings.Split(list, sep) {
+		if i == item {
+			return list
+		}
+	}
+	return list + sep + item
+}
+
+func allowUnique(perms []string) []string {
+	seen := make(map[string]bool, len(perms))
+	var unique []string
+	for _, p := range perms {
+		if !seen[p] {
+			seen[p] = true
+			unique = append(unique, p)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+func runAllow(ctx context.Context, conf *config.Config, out io.Writer) error {
+	if allowArgs.user == "" {
+		return errors.New("--user is required")
+	}
+	if conf.Global.ZFSHelper != nil {
+		fmt.Fprintln(out, "the daemon runs zfs through global.zfs_helper, which runs as root, no delegation required")
+		return nil
+	}
+	u, err := user.Lookup(allowArgs.user)
+	if err != nil {
+		return errors.Wrap(err, "cannot look up --user")
+	}
+	groups := doctorUserGroups(u)
+
+	jobs := conf.Jobs
+	if allowArgs.job != "" {
+		j, err := conf.Job(allowArgs.job)
+		if err != nil {
+			return err
+		}
+		jobs = []config.JobEnum{*j}
+	}
+
+	datasets, findings := doctorJobDatasets(ctx, jobs)
+	if doctorPrint(os.Stderr, findings) > 0 {
+		return errors.New("cannot determine the datasets of all jobs")
+	}
+
+	var existing []doctorDataset
+	var grants []allowGrant
+	for _, ds := range allowMerge(datasets) {
+		if _, err := zfs.ZFSGet(ctx, ds.path, []string{"name"}); err != nil {
+			if _, ok := err.(*zfs.DatasetDoesNotExist); ok {
+				fmt.Fprintf(os.Stderr, "skipping %s of job %s: the dataset does not exist, create it with `zfs create -p %s` and re-run\n", ds.path.ToString(), ds.job, ds.path.ToString())
+				continue
+			}
+			return errors.Wrapf(err, "cannot get %s", ds.path.ToString())
+		}
+		existing = append(existing, ds)
+		_, missing, err := doctorMissingPermissions(ctx, ds, u.Username, groups)
+		if err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			grants = append(grants, allowGrant{ds.path.ToString(), missing})
+		}
+	}
+
+	if len(grants) == 0 {
+		fmt.Fprintf(os.Stderr, "user %s has all permissions that the jobs require\n", u.Username)
+		return nil
+	}
+	for _, g := range grants {
+		fmt.Fprintf(out, "%s %s\n", zfs.ZFS_BINARY, strings.Join(g.args(u.Username), " "))
+	}
+	if !allowArgs.apply {
+		fmt.Fprintln(os.Stderr, "run with --apply to delegate these permissions")
+		return nil
+	}
+
+	if !allowArgs.yes {
+		if !isatty.IsTerminal(os.Stdin.Fd()) {
+			return errors.New("stdin is not a terminal, use --yes to apply without confirmation")
+		}
+		ok, err := zabsConfirm(os.Stdin, os.Stderr, fmt.Sprintf("run these %d zfs allow command(s)?", len(grants)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("aborted")
+		}
+	}
+	for _, g := range grants {
+		output, err := zfscmd.CommandContext(ctx, zfs.ZFS_BINARY, g.args(u.Username)...).CombinedOutput()
+		if err != nil {
+			return errors.Errorf("zfs allow on %s failed: %s", g.dataset, strings.TrimSpace(string(output)))
+		}
+	}
+
+	// verify
+	findings = nil
+	for _, ds := range existing {
+		findings = append(findings, doctorDelegation(ctx, ds, u.Username, groups))
+	}
+	if failed := doctorPrint(out, findings); failed > 0 {
+		return errors.Errorf("%d dataset(s) still lack permissions after zfs allow", failed)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestAllowMerge(t *testing.T) {
+	path := func(p string) *zfs.DatasetPath {
+		dp, err := zfs.NewDatasetPath(p)
+		require.NoError(t, err)
+		return dp
+	}
+	merged := allowMerge([]doctorDataset{
+		{"push", doctorRoleSender, path("pool/data"), doctorRoleSender.perms},
+		{"sink", doctorRoleReceiver, path("pool/backups"), []string{"receive", "create"}},
+		{"snap", doctorRoleSnap, path("pool/data"), doctorRoleSnap.perms},
+		{"push", doctorRoleSender, path("pool/data"), doctorRoleSender.perms},
+	})
+	require.Len(t, merged, 2)
+	assert.Equal(t, "pool/backups", merged[0].path.ToString())
+	assert.Equal(t, []string{"create", "receive"}, merged[0].perms)
+	assert.Equal(t, "pool/data", merged[1].path.ToString())
+	assert.Equal(t, "push, snap", merged[1].job)
+	assert.Equal(t, "sender+snapshotter", merged[1].role.name)
+	assert.Equal(t, doctorRoleSender.perms, merged[1].perms, "the snapshotter's permissions are a subset")
+
+	g := allowGrant{"pool/data", []string{"hold", "send"}}
+	assert.Equal(t, []string{"allow", "-u", "zrepl", "hold,send", "pool/data"}, g.args("zrepl"))
+}
@@ -0,0 +1,491 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/tlsconf"
+	"github.com/zrepl/zrepl/transport"
+)
+
+var bootstrapArgs bootstrapParams
+
+type bootstrapParams struct {
+	Sink        string
+	SinkAddress string
+	Push        string
+	RootFS      string
+	Filesystems string
+	ConfigDir   string // the directory of the files on the hosts
+	out         string // the directory of the generated files
+	days        int
+	test        bool
+}
+
+var BootstrapCmd = &cli.Subcommand{
+	Use:             "bootstrap [--sink NAME --sink-address HOST:PORT --push NAME]",
+	Short:           "generate the certificates and jobs for replication from a push host to a sink host using the tls transport",
+	NoRequireConfig: true,
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.StringVar(&bootstrapArgs.Sink, "sink", "", "name of the sink host, the CN of its certificate")
+		f.StringVar(&bootstrapArgs.SinkAddress, "sink-address", "", "address on which the push host reaches the sink host, e.g. backups.example.com:8888")
+		f.StringVar(&bootstrapArgs.Push, "push", "", "name of the push host, the CN of its certificate and its client identity")
+		f.StringVar(&bootstrapArgs.RootFS, "root-fs", "", "root_fs of the sink job")
+		f.StringVar(&bootstrapArgs.Filesystems, "filesystems", "", "filesystems of the push job, in the syntax of the filter, e.g. 'pool<'")
+		f.StringVar(&bootstrapArgs.ConfigDir, "config-dir", "/etc/zrepl", "directory on the hosts to which the generated files are copied")
+		f.StringVarP(&bootstrapArgs.out, "out", "o", ".", "directory for the generated files, an existing CA in it is reused")
+		f.IntVar(&bootstrapArgs.days, "days", 1825, "validity of generated certificates in days")
+		f.BoolVar(&bootstrapArgs.test, "test", false, "connect to --sink-address with the push host's certificate")
+	},
+	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+		if len(args) != 0 {
+			return errors.New("bootstrap does not take arguments, see --help")
+		}
+		return runBootstrap(ctx, bootstrapArgs, os.Stdin, os.Stdout)
+	},
+}
+
+func runBootstrap(ctx context.Context, p bootstrapParams, stdin *os.File, out io.Writer) error {
+	if err := bootstrapAsk(&p, stdin, out); err != nil {
+		return err
+	}
+	if err := p.validate(); err != nil {
+		return err
+	}
+
+	sinkConf, pushConf, err := bootstrapConfigs(p)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(p.out, 0700); err != nil {
+		return err
+	}
+	exp := time.Duration(p.days) * 24 * time.Hour
+	ca, caKey, err := bootstrapCA(p.out, exp, out)
+	if err != nil {
+		return err
+	}
+	sinkHost, _, _ := net.SplitHostPort(p.SinkAddress)
+	if err := bootstrapCert(p.out, p.Sink, []string{p.Sink, sinkHost}, x509.ExtKeyUsageServerAuth, ca, caKey, exp, out); err != nil {
+		return err
+	}
+	if err := bootstrapCert(p.out, p.Push, []string{p.Push}, x509.ExtKeyUsageClientAuth, ca, caKey, exp, out); err != nil {
+		return err
+	}
+
+	// named after the pair because the CA and the sink host's certificate are reused to pair more push hosts
+	pair := p.Push + "-to-" + p.Sink
+	sinkFile, pushFile := pair+".sink.yml", pair+".push.yml"
+	for file, content := range map[string][]byte{sinkFile: sinkConf, pushFile: pushConf} {
+		if err := bootstrapWriteFile(p.out, file, content, 0644, out); err != nil {
+			return err
+		}
+	}
+
+	if err := bootstrapSelfCheck(p); err != nil {
+		return errors.Wrap(err, "generated certificates do not work")
+	}
+	fmt.Fprintf(out, "\n%s the certificates of %s and %s authenticate each other\n", succ.Sprint("OK:"), p.Push, p.Sink)
+
+	fmt.Fprintf(out, "\nNext steps\n")
+	fmt.Fprintf(out, "1. copy ca.crt %[1]s.crt %[1]s.key to %[2]s on %[1]s and add the job of %[3]s to its config\n", p.Sink, p.ConfigDir, sinkFile)
+	fmt.Fprintf(out, "   (if %s already has a sink job for this CA, add %q to its client_cns instead)\n", p.Sink, p.Push)
+	fmt.Fprintf(out, "2. copy ca.crt %[1]s.crt %[1]s.key to %[2]s on %[1]s and add the job of %[3]s to its config\n", p.Push, p.ConfigDir, pushFile)
+	fmt.Fprintf(out, "3. keep ca.key offline, it is only needed to pair more hosts\n")
+	fmt.Fprintf(out, "4. restart both daemons and run `zrepl test replication push_to_%s` on %s\n", p.Sink, p.Push)
+
+	if p.test {
+		fmt.Fprintf(out, "\n")
+		if err := bootstrapTestConnect(ctx, p); err != nil {
+			return errors.Wrapf(err, "cannot connect to %s", p.SinkAddress)
+		}
+		fmt.Fprintf(out, "%s TLS handshake with %s succeeded\n", succ.Sprint("OK:"), p.SinkAddress)
+	}
+	return nil
+}
+
+// bootstrapAsk prompts for the required parameters that are not set by flags if stdin is a terminal.
+func bootstrapAsk(p *bootstrapParams, stdin *os.File, out io.Writer) error {
+	questions := []struct {
+		flag, question, def string
+		v                   *string
+	}{
+		{"sink", "name of the sink host (receives the backups)", "", &p.Sink},
+		{"sink-address", "address of the sink host as reached from the push host", "", &p.SinkAddress},
+		{"push", "name of the push host (sends the backups)", "", &p.Push},
+		{"root-fs", "dataset on the sink host below which the backups are received", "storage/zrepl/sink", &p.RootFS},
+		{"filesystems", "filesystems on the push host to back up", "", &p.Filesystems},
+	}
+	interactive := isatty.IsTerminal(stdin.Fd())
+	in := bufio.NewReader(stdin)
+	for _, q := range questions {
+		if *q.v != "" {
+			continue
+		}
+		if !interactive {
+			return errors.Errorf("stdin is not a terminal, --%s is required", q.flag)
+		}
+		if q.flag == "sink-address" && p.Sink != "" {
+			q.def = net.JoinHostPort(p.Sink, "8888")
+		}
+		if q.flag == "filesystems" {
+			fmt.Fprintf(out, "(filter syntax, e.g. 'pool<' for pool and all its children, 'pool/tmp<!' to exclude)\n")
+		}
+		answer, err := bootstrapPrompt(in, out, q.question, q.def)
+		if err != nil {
+			return err
+		}
+		*q.v = answer
+	}
+	return nil
+}
+
+func bootstrapPrompt(in *bufio.Reader, out io.Writer, question, def string) (string, error) {
+	for {
+		if def != "" {
+			fmt.Fprintf(out, "%s [%s]: ", question, def)
+		} else {
+			fmt.Fprintf(out, "%s: ", question)
+		}
+		line, err := in.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			line = def
+		}
+		if line != "" {
+			return line, nil
+		}
+	}
+}
+
+func (p bootstrapParams) validate() error {
+	for _, name := range []string{p.Sink, p.Push} {
+		if err := transport.ValidateClientIdentity(name); err != nil {
+			return errors.Wrapf(err, "invalid host name %q", name)
+		}
+	}
+	if p.Sink == p.Push {
+		return errors.New("sink and push host must have different names")
+	}
+	if _, _, err := net.SplitHostPort(p.SinkAddress); err != nil {
+		return errors.Wrap(err, "invalid sink address")
+	}
+	if p.days <= 0 {
+		return errors.New("--days must be positive")
+	}
+	return nil
+}
+
+const bootstrapSinkTemplate = `# generated by zrepl bootstrap for {{.Sink}}, see https://zrepl.github.io/configuration/transports.html
+jobs:
+- name: sink
+  type: sink
+  root_fs: {{printf "%q" .RootFS}}
+  serve:
+    type: tls
+    listen: {{printf "%q" .Listen}}
+    ca: {{.ConfigDir}}/ca.crt
+    cert: {{.ConfigDir}}/{{.Sink}}.crt
+    key: {{.ConfigDir}}/{{.Sink}}.key
+    client_cns:
+    - {{printf "%q" .Push}}
+`
+
+const bootstrapPushTemplate = `# generated by zrepl bootstrap for {{.Push}}, see https://zrepl.github.io/configuration/jobs.html
+jobs:
+- name: push_to_{{.Sink}}
+  type: push
+  connect:
+    type: tls
+    address: {{printf "%q" .SinkAddress}}
+    ca: {{.ConfigDir}}/ca.crt
+    cert: {{.ConfigDir}}/{{.Push}}.crt
+    key: {{.ConfigDir}}/{{.Push}}.key
+    server_cn: {{printf "%q" .Sink}}
+  filesystems:
+    {{printf "%q" .Filesystems}}: true
+  snapshotting:
+    type: periodic
+    prefix: zrepl_
+    interval: 10m
+  pruning:
+    keep_sender:
+    - type: not_replicated
+    - type: last_n
+      count: 10
+    keep_receiver:
+    - type: grid
+      grid: 1x1h(keep=all) | 24x1h | 30x1d | 6x30d
+      regex: "^zrepl_"
+`
+
+// bootstrapConfigs returns the configs of the sink and the push host, both parsed to make sure they are valid.
+func bootstrapConfigs(p bootstrapParams) (sink, push []byte, err error) {
+	_, port, _ := net.SplitHostPort(p.SinkAddress)
+	data := struct {
+		bootstrapParams
+		Listen string
+	}{p, ":" + port}
+	for _, c := range []struct {
+		tmpl string
+		out  *[]byte
+	}{{bootstrapSinkTemplate, &sink}, {bootstrapPushTemplate, &push}} {
+		var buf bytes.Buffer
+		if err := template.Must(template.New("").Parse(c.tmpl)).Execute(&buf, data); err != nil {
+			return nil, nil, err
+		}
+		if _, err := config.ParseConfigBytes(buf.Bytes()); err != nil {
+			return nil, nil, errors.Wrapf(err, "generated config is invalid:\n%s", buf.String())
+		}
+		*c.out = buf.Bytes()
+	}
+	return sink, push, nil
+}
+
+// bootstrapWriteFile writes a new file, it never overwrites existing files.
+func bootstrapWriteFile(dir, name string, content []byte, perm os.FileMode, out io.Writer) error {
+	path := filepath.Join(dir, name)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "wrote %s\n", path)
+	return nil
+}
+
+func bootstrapExists(dir, name string) (bool, error) {
+	_, err := os.Stat(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// bootstrapCA loads ca.crt and ca.key from dir or generates them.
+func bootstrapCA(dir string, exp time.Duration, out io.Writer) (*x509.Certificate, crypto.Signer, error) {
+	exists, err := bootstrapExists(dir, "ca.crt")
+	if err != nil {
+		return nil, nil, err
+	}
+	if exists {
+		pair, err := tls.LoadX509KeyPair(filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key"))
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "cannot load existing CA")
+		}
+		ca, err := x509.ParseCertificate(pair.Certificate[0])
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "cannot parse existing CA")
+		}
+		key, ok := pair.PrivateKey.(crypto.Signer)
+		if !ok || !ca.IsCA {
+			return nil, nil, errors.New("existing ca.crt is not a CA certificate")
+		}
+		fmt.Fprintf(out, "reusing CA %q of %s\n", ca.Subject.CommonName, filepath.Join(dir, "ca.crt"))
+		return ca, key, nil
+	}
+
+	tmpl := bootstrapTemplate("zrepl CA", exp)
+	tmpl.IsCA = true
+	tmpl.BasicConstraintsValid = true
+	tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return nil, nil, err
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := bootstrapWritePair(dir, "ca", der, key, out); err != nil {
+		return nil, nil, err
+	}
+	return ca, key, nil
+}
+
+// bootstrapCert generates NAME.crt and NAME.key in dir, signed by ca, unless NAME.crt exists.
+func bootstrapCert(dir, name string, sans []string, usage x509.ExtKeyUsage, ca *x509.Certificate, caKey crypto.Signer, exp time.Duration, out io.Writer) error {
+	exists, err := bootstrapExists(dir, name+".crt")
+	if err != nil || exists {
+		if exists {
+			fmt.Fprintf(out, "reusing %s\n", filepath.Join(dir, name+".crt"))
+		}
+		return err
+	}
+	tmpl := bootstrapTemplate(name, exp)
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+	tmpl.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+	// Go requires Subject Alternative Names, zrepl uses the CN
+	seen := make(map[string]bool, len(sans))
+	for _, san := range sans {
+		if san == "" || seen[san] {
+			continue
+		}
+		seen[san] = true
+		if ip := net.ParseIP(san); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, san)
+		}
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, key.Public(), caKey)
+	if err != nil {
+		return err
+	}
+	return bootstrapWritePair(dir, name, der, key, out)
+}
+
+func bootstrapTemplate(cn string, exp time.Duration) *x509.Certificate {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		panic(err)
+	}
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    now.Add(-1 * time.Hour), // tolerate clock skew between the hosts
+		NotAfter:     now.Add(exp),
+	}
+}
+
+func bootstrapWritePair(dir, name string, der []byte, key *ecdsa.PrivateKey, out io.Writer) error {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := bootstrapWriteFile(dir, name+".key", keyPEM, 0600, out); err != nil {
+		return err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return bootstrapWriteFile(dir, name+".crt", certPEM, 0644, out)
+}
+
+// bootstrapTLSConfigs returns the TLS configs of the transport for the push host (client) and sink host (server),
+// loaded from the generated files.
+func bootstrapTLSConfigs(p bootstrapParams) (client *tls.Config, ca *x509.CertPool, server tls.Certificate, err error) {
+	ca, err = tlsconf.ParseCAFile(filepath.Join(p.out, "ca.crt"))
+	if err != nil {
+		return nil, nil, server, err
+	}
+	push, err := tls.LoadX509KeyPair(filepath.Join(p.out, p.Push+".crt"), filepath.Join(p.out, p.Push+".key"))
+	if err != nil {
+		return nil, nil, server, err
+	}
+	server, err = tls.LoadX509KeyPair(filepath.Join(p.out, p.Sink+".crt"), filepath.Join(p.out, p.Sink+".key"))
+	if err != nil {
+		return nil, nil, server, err
+	}
+	client, err = tlsconf.ClientAuthClient(p.Sink, ca, push)
+	return client, ca, server, err
+}
+
+// bootstrapSelfCheck performs the TLS handshake of the transport between the generated certificates over loopback.
+func bootstrapSelfCheck(p bootstrapParams) error {
+	clientConf, ca, serverCert, err := bootstrapTLSConfigs(p)
+	if err != nil {
+		return err
+	}
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	listener := tlsconf.NewClientAuthListener(l, ca, serverCert, 10*time.Second)
+
+	clientErr := make(chan error, 1)
+	go func() {
+		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", l.Addr().String(), clientConf)
+		if err == nil {
+			// with TLS 1.3, the server verifies the client certificate after the client finished the handshake
+			_, err = conn.Read(make([]byte, 1))
+			if err == io.EOF {
+				err = nil
+			}
+			conn.Close()
+		}
+		clientErr <- err
+	}()
+
+	_, tlsConn, cn, err := listener.Accept()
+	if err != nil {
+		return errors.Wrap(err, "sink host")
+	}
+	tlsConn.Close()
+	if err := <-clientErr; err != nil {
+		return errors.Wrap(err, "push host")
+	}
+	if cn != p.Push {
+		return errors.Errorf("client CN is %q, expected %q", cn, p.Push)
+	}
+	return nil
+}
+
+// bootstrapTestConnect performs the TLS handshake with the sink host at p.SinkAddress.
+// It succeeds only if the sink host already serves with a certificate of the same CA.
+func bootstrapTestConnect(ctx context.Context, p bootstrapParams) error {
+	clientConf, _, _, err := bootstrapTLSConfigs(p)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", p.SinkAddress)
+	if err != nil {
+		return errors.Wrap(err, "is the sink host's daemon running and the port reachable?")
+	}
+	defer conn.Close()
+	if dl, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(dl); err != nil {
+			return err
+		}
+	}
+	if err := tls.Client(conn, clientConf).Handshake(); err != nil {
+		return errors.Wrap(err, "TLS handshake failed, does the sink host use the generated certificate?")
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+)
+
+func TestBootstrap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-bootstrap")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	stdin, err := os.Open(os.DevNull)
+	require.NoError(t, err)
+	defer stdin.Close()
+
+	p := bootstrapParams{
+		Sink:        "backups",
+		SinkAddress: "192.0.2.1:8888",
+		Push:        "prod",
+		RootFS:      "storage/zrepl/sink",
+		Filesystems: "zroot<",
+		ConfigDir:   "/etc/zrepl",
+		out:         dir,
+		days:        10,
+	}
+	var out bytes.Buffer
+	require.NoError(t, runBootstrap(context.Background(), p, stdin, &out), "%s", out.String())
+
+	for _, f := range []string{"ca.crt", "ca.key", "backups.crt", "backups.key", "prod.crt", "prod.key", "prod-to-backups.sink.yml", "prod-to-backups.push.yml"} {
+		assert.FileExists(t, filepath.Join(dir, f))
+	}
+	fi, err := os.Stat(filepath.Join(dir, "prod.key"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	certPEM, err := ioutil.ReadFile(filepath.Join(dir, "backups.crt"))
+	require.NoError(t, err)
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	assert.Equal(t, "backups", cert.Subject.CommonName)
+	assert.Equal(t, []string{"backups"}, cert.DNSNames)
+	require.Len(t, cert.IPAddresses, 1)
+	assert.Equal(t, "192.0.2.1", cert.IPAddresses[0].String())
+
+	c, err := config.ParseConfig(filepath.Join(dir, "prod-to-backups.push.yml"))
+	require.NoError(t, err)
+	push := c.Jobs[0].Ret.(*config.PushJob)
+	assert.Equal(t, "push_to_backups", push.Name)
+	assert.Equal(t, "/etc/zrepl/prod.crt", push.Connect.Ret.(*config.TLSConnect).Cert)
+	c, err = config.ParseConfig(filepath.Join(dir, "prod-to-backups.sink.yml"))
+	require.NoError(t, err)
+	serve := c.Jobs[0].Ret.(*config.SinkJob).Serve.Ret.(*config.TLSServe)
+	assert.Equal(t, ":8888", serve.Listen)
+	assert.Equal(t, []string{"prod"}, serve.ClientCNs)
+
+	// pairing another push host reuses the CA and the sink host's certificate
+	caPEM, err := ioutil.ReadFile(filepath.Join(dir, "ca.crt"))
+	require.NoError(t, err)
+	p.Push = "dev"
+	out.Reset()
+	require.NoError(t, runBootstrap(context.Background(), p, stdin, &out), "%s", out.String())
+	assert.Contains(t, out.String(), "reusing CA")
+	assert.Contains(t, out.String(), "reusing "+filepath.Join(dir, "backups.crt"))
+	caPEMAfter, err := ioutil.ReadFile(filepath.Join(dir, "ca.crt"))
+	require.NoError(t, err)
+	assert.Equal(t, caPEM, caPEMAfter)
+
+	// files are never overwritten
+	require.Error(t, runBootstrap(context.Background(), p, stdin, &out))
+
+	p.Push, p.Filesystems = "other", ""
+	assert.EqualError(t, runBootstrap(context.Background(), p, stdin, &out), "stdin is not a terminal, --filesystems is required")
+}
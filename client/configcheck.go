@@ -22,17 +22,47 @@ var configcheckArgs struct {
 	format        string
 	what          string
 	skipCertCheck bool
+	schema        bool
+	errorFormat   string
+	render        bool
 }
 
 var ConfigcheckCmd = &cli.Subcommand{
 	Use:   "configcheck",
 	Short: "check if config can be parsed without errors",
+	// parsing errors are reported by Run in the requested --error-format
+	NoRequireConfig: true,
 	SetupFlags: func(f *pflag.FlagSet) {
 		f.StringVar(&configcheckArgs.format, "format", "", "dump parsed config object [pretty|yaml|json]")
 		f.StringVar(&configcheckArgs.what, "what", "all", "what to print [all|config|jobs|logging]")
 		f.BoolVar(&configcheckArgs.skipCertCheck, "skip-cert-check", false, "skip checking cert files")
+		f.BoolVar(&configcheckArgs.schema, "schema", false, "print the JSON schema of the config file and exit")
+		f.StringVar(&configcheckArgs.errorFormat, "error-format", "text", "format of config validation errors [text|json]")
+		f.BoolVar(&configcheckArgs.render, "render", false, "print the effective config in config file syntax, with defaults, includes and job templates applied")
 	},
 	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+		if configcheckArgs.schema {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(config.JSONSchema())
+		}
+
+		if err := subcommand.ConfigParsingError(); err != nil {
+			switch configcheckArgs.errorFormat {
+			case "text":
+				return fmt.Errorf("could not parse config: %s", err)
+			case "json":
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if encErr := enc.Encode(config.AsValidationErrors(err)); encErr != nil {
+					return encErr
+				}
+				return fmt.Errorf("config validation failed")
+			default:
+				return fmt.Errorf("unsupported --error-format %q", configcheckArgs.errorFormat)
+			}
+		}
+
 		formatMap := map[string]func(interface{}){
 			"": func(i interface{}) {},
 			"pretty": func(i interface{}) {
@@ -57,6 +87,19 @@ var ConfigcheckCmd = &cli.Subcommand{
 			return fmt.Errorf("unsupported --format %q", configcheckArgs.format)
 		}
 
+		if configcheckArgs.render {
+			if configcheckArgs.format != "" {
+				return fmt.Errorf("--render and --format are mutually exclusive")
+			}
+			rendered, err := config.RenderYAML(subcommand.Config())
+			if err != nil {
+				return errors.Wrap(err, "cannot render config")
+			}
+			if _, err := os.Stdout.Write(rendered); err != nil {
+				return err
+			}
+		}
+
 		var hadErr bool
 
 		parseFlags := config.ParseFlagsNone
@@ -0,0 +1,750 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon"
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/rpc/versionhandshake"
+	"github.com/zrepl/zrepl/version"
+	"github.com/zrepl/zrepl/zfs"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+var doctorArgs struct {
+	job string
+}
+
+var DoctorCmd = &cli.Subcommand{
+	Use:           "doctor [--job JOB]",
+	Short:         "check the environment of the daemon and print actionable findings",
+	CompleteFlags: map[string]cli.CompletionFunc{"job": cli.ConfigJobNames},
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.StringVar(&doctorArgs.job, "job", "", "only check the datasets, permissions and peers of JOB")
+	},
+	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+		if len(args) != 0 {
+			return errors.New("doctor does not take arguments")
+		}
+		return runDoctor(ctx, subcommand.Config(), os.Stdout)
+	},
+}
+
+type doctorSeverity int
+
+const (
+	doctorOK doctorSeverity = iota
+	doctorInfo
+	doctorWarn
+	doctorFail
+)
+
+func (s doctorSeverity) String() string {
+	switch s {
+	case doctorOK:
+		return "OK"
+	case doctorInfo:
+		return "INFO"
+	case doctorWarn:
+		return "WARN"
+	default:
+		return "FAIL"
+	}
+}
+
+func (s doctorSeverity) color() *color.Color {
+	switch s {
+	case doctorOK:
+		return succ
+	case doctorWarn:
+		return color.New(color.FgYellow)
+	case doctorFail:
+		return fail
+	default:
+		return color.New()
+	}
+}
+
+type doctorFinding struct {
+	Severity doctorSeverity
+	// what was checked, e.g. "zfs" or "job prod"
+	Check   string
+	Message string
+	// how to fix it, empty if there is nothing to do
+	Hint string
+}
+
+func doctorf(s doctorSeverity, check, format string, args ...interface{}) doctorFinding {
+	return doctorFinding{Severity: s, Check: check, Message: fmt.Sprintf(format, args...)}
+}
+
+func (f doctorFinding) hint(format string, args ...interface{}) doctorFinding {
+	f.Hint = fmt.Sprintf(format, args...)
+	return f
+}
+
+// doctorDaemon is what the running daemon reports, nil if it is not reachable.
+type doctorDaemon struct {
+	status daemon.Status
+	peers  []versionhandshake.PeerReport
+}
+
+func runDoctor(ctx context.Context, conf *config.Config, out io.Writer) error {
+	jobs := conf.Jobs
+	if doctorArgs.job != "" {
+		j, err := conf.Job(doctorArgs.job)
+		if err != nil {
+			return err
+		}
+		jobs = []config.JobEnum{*j}
+	}
+
+	var findings []doctorFinding
+	d, controlFindings := doctorControl(conf, jobs)
+	findings = append(findings, controlFindings...)
+	findings = append(findings, doctorZFS(ctx)...)
+	findings = append(findings, doctorDatasets(ctx, conf, jobs)...)
+	findings = append(findings, doctorListeners(ctx, conf, jobs, d)...)
+	if d != nil {
+		findings = append(findings, doctorClock(d.peers, jobs, doctorClockTolerance)...)
+	}
+
+	failed := doctorPrint(out, findings)
+	if failed > 0 {
+		return errors.Errorf("%d check(s) failed", failed)
+	}
+	return nil
+}
+
+// doctorPrint prints the findings and returns the number of failed checks.
+func doctorPrint(out io.Writer, findings []doctorFinding) (failed int) {
+	checkWidth := 0
+	for _, f := range findings {
+		if len(f.Check) > checkWidth {
+			checkWidth = len(f.Check)
+		}
+	}
+	for _, f := range findings {
+		sev := fmt.Sprintf("%-4s", f.Severity)
+		fmt.Fprintf(out, "%s  %-*s  %s\n", f.Severity.color().Sprint(sev), checkWidth, f.Check, f.Message)
+		if f.Hint != "" {
+			fmt.Fprintf(out, "      %-*s  -> %s\n", checkWidth, "", f.Hint)
+		}
+		if f.Severity == doctorFail {
+			failed++
+		}
+	}
+	return failed
+}
+
+func doctorControl(conf *config.Config, jobs []config.JobEnum) (*doctorDaemon, []doctorFinding) {
+	const check = "control socket"
+	where := conf.Global.Control.SockPath
+	if conf.Global.Control.Connect != nil {
+		where = conf.Global.Control.Connect.Address
+	}
+	httpc, err := controlHttpClient(conf.Global.Control)
+	if err != nil {
+		return nil, []doctorFinding{doctorf(doctorFail, check, "%s", err).hint("fix global.control.connect")}
+	}
+	var v version.ZreplVersionInformation
+	if err := jsonRequestResponse(httpc, daemon.ControlJobEndpointVersion, "", &v); err != nil {
+		return nil, []doctorFinding{doctorf(doctorFail, check, "the daemon does not respond on %s: %s", where, err).
+			hint("start the daemon, e.g. `systemctl start zrepl`, or check global.control of the config")}
+	}
+	var d doctorDaemon
+	if err := jsonRequestResponse(httpc, daemon.ControlJobEndpointStatus, struct{}{}, &d.status); err != nil {
+		return nil, []doctorFinding{doctorf(doctorFail, check, "daemon %s responds on %s, but its status is unavailable: %s", v.Version, where, err)}
+	}
+	if err := jsonRequestResponse(httpc, daemon.ControlJobEndpointPeers, "", &d.peers); err != nil {
+		return nil, []doctorFinding{doctorf(doctorFail, check, "daemon %s responds on %s, but its peers are unavailable: %s", v.Version, where, err)}
+	}
+
+	findings := []doctorFinding{doctorf(doctorOK, check, "daemon %s responds on %s", v.Version, where)}
+	if ours := version.NewZreplVersionInformation().Version; ours != v.Version {
+		findings = append(findings, doctorf(doctorWarn, check, "the zrepl binary is %s, the daemon runs %s", ours, v.Version).
+			hint("restart the daemon to run the installed version"))
+	}
+	var notRunning []string
+	for _, j := range jobs {
+		if _, ok := d.status.Jobs[j.Name()]; !ok {
+			notRunning = append(notRunning, j.Name())
+		}
+	}
+	if len(notRunning) > 0 {
+		findings = append(findings, doctorf(doctorWarn, check, "the daemon does not run job(s) %s of the config", strings.Join(notRunning, ", ")).
+			hint("reload the daemon's config with `kill -HUP` or restart it"))
+	}
+	return &d, findings
+}
+
+var doctorZFSVersionRE = regexp.MustCompile(`^zfs-(kmod-)?v?(\d+)\.(\d+)\.(\d+)`)
+
+// doctorParseZFSVersion parses the output of `zfs version`, e.g.
+//
+//	zfs-2.1.5-1ubuntu6~22.04.1
+//	zfs-kmod-2.1.5-1ubuntu6~22.04.1
+func doctorParseZFSVersion(output string) (userland, kmod string, major int) {
+	for _, line := range strings.Split(output, "\n") {
+		m := doctorZFSVersionRE.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		v := m[2] + "." + m[3] + "." + m[4]
+		if m[1] != "" {
+			kmod = v
+		} else {
+			userland = v
+			fmt.Sscan(m[2], &major)
+		}
+	}
+	return userland, kmod, major
+}
+
+func doctorZFSVersionFindings(output string, err error) []doctorFinding {
+	const check = "zfs"
+	userland, kmod, major := doctorParseZFSVersion(output)
+	if err != nil || userland == "" {
+		msg := strings.TrimSpace(output)
+		if err != nil {
+			msg = strings.TrimSpace(msg + " " + err.Error())
+		}
+		return []doctorFinding{doctorf(doctorWarn, check, "cannot determine the ZFS version with `zfs version`: %s", msg).
+			hint("`zfs version` exists since OpenZFS 0.8, upgrade ZFS if it is older")}
+	}
+	var findings []doctorFinding
+	if major < 2 {
+		findings = append(findings, doctorf(doctorWarn, check, "OpenZFS %s is older than 2.0, which has known send/recv data corruption bugs", userland).
+			hint("upgrade ZFS, see the large_blocks warning in the send options documentation"))
+	} else {
+		findings = append(findings, doctorf(doctorOK, check, "OpenZFS %s", userland))
+	}
+	if kmod != "" && kmod != userland {
+		findings = append(findings, doctorf(doctorWarn, check, "the userland tools are %s, the kernel module is %s", userland, kmod).
+			hint("reboot or reload the kernel module after upgrading ZFS"))
+	}
+	return findings
+}
+
+func doctorZFS(ctx context.Context) []doctorFinding {
+	output, err := zfscmd.CommandContext(ctx, zfs.ZFS_BINARY, "version").CombinedOutput()
+	findings := doctorZFSVersionFindings(string(output), err)
+	if _, err := zfscmd.CommandContext(ctx, "zpool", "version").CombinedOutput(); err != nil {
+		findings = append(findings, doctorf(doctorWarn, "zfs", "`zpool version` failed: %s", err))
+	}
+	if supported, err := zfs.ResumeRecvSupported(ctx, nil); err != nil || !supported {
+		findings = append(findings, doctorf(doctorWarn, "zfs", "zfs recv does not support resumable receives (%v)", err).
+			hint("interrupted replication steps restart from the beginning, upgrade ZFS"))
+	}
+	if supported, err := zfs.EncryptionCLISupported(ctx); err != nil || !supported {
+		findings = append(findings, doctorf(doctorInfo, "zfs", "zfs does not support native encryption (%v)", err))
+	}
+	return findings
+}
+
+// doctorRole is what a job does with a dataset.
+type doctorRole struct {
+	name  string
+	perms []string
+	// pool features that the role requires, and for which a missing feature is only a warning
+	features, optionalFeatures []string
+}
+
+var (
+	// `zfs destroy` requires the mount permission, too
+	doctorRoleSender   = doctorRole{"sender", []string{"bookmark", "destroy", "hold", "mount", "release", "send", "snapshot"}, []string{"bookmarks"}, nil}
+	doctorRoleReceiver = doctorRole{"receiver", []string{"create", "destroy", "hold", "mount", "receive", "release", "userprop"}, nil, []string{"extensible_dataset"}}
+	doctorRoleSnap     = doctorRole{"snapshotter", []string{"destroy", "mount", "snapshot"}, nil, nil}
+)
+
+type doctorDataset struct {
+	job  string
+	role doctorRole
+	path *zfs.DatasetPath
+	// the permissions of role and those for the properties that the job sets
+	perms []string
+}
+
+// doctorRecvPermissions returns the permissions for the properties that a receiver with recv sets,
+// in addition to those of doctorRoleReceiver.
+func doctorRecvPermissions(recv *config.RecvOptions, quotas *config.SinkClientQuotas) []string {
+	set := make(map[string]bool)
+	if recv != nil {
+		if recv.Properties != nil {
+			for _, p := range recv.Properties.Inherit {
+				set[string(p)] = true
+			}
+			for p := range recv.Properties.Override {
+				set[string(p)] = true
+			}
+		}
+		if recv.Placeholder != nil {
+			for p := range recv.Placeholder.Properties {
+				set[string(p)] = true
+			}
+			if recv.Placeholder.Encryption == "off" {
+				set["encryption"] = true
+			}
+		}
+		if recv.EncryptOnReceive {
+			set["encryption"] = true // zfs recv -o encryption=on
+		}
+		if recv.BrowseMounts != nil {
+			set["mountpoint"] = true
+			set["readonly"] = true
+		}
+		if recv.ForceReceive != "never" {
+			set["rollback"] = true // placeholder replacement with zfs rollback -r
+		}
+	}
+	if quotas != nil && (quotas.Default != nil || len(quotas.Clients) > 0) {
+		for _, p := range []string{"quota", "reservation"} {
+			set[p] = true
+		}
+	}
+	perms := make([]string, 0, len(doctorRoleReceiver.perms)+len(set))
+	for _, p := range doctorRoleReceiver.perms {
+		if !set[p] {
+			perms = append(perms, p)
+		}
+	}
+	for p := range set {
+		perms = append(perms, p)
+	}
+	sort.Strings(perms)
+	return perms
+}
+
+// doctorJobDatasets returns the topmost datasets of each job, i.e., those whose permissions are inherited by the others.
+func doctorJobDatasets(ctx context.Context, jobs []config.JobEnum) ([]doctorDataset, []doctorFinding) {
+	var datasets []doctorDataset
+	var findings []doctorFinding
+	addFilter := func(job string, role doctorRole, in config.FilesystemsFilter) {
+		f, err := filters.DatasetMapFilterFromConfig(in)
+		if err != nil {
+			findings = append(findings, doctorf(doctorFail, "job "+job, "invalid filesystems filter: %s", err))
+			return
+		}
+		matched, err := zfs.ZFSListMapping(ctx, f)
+		if err != nil {
+			findings = append(findings, doctorf(doctorFail, "job "+job, "cannot list filesystems: %s", err))
+			return
+		}
+		if len(matched) == 0 {
+			findings = append(findings, doctorf(doctorWarn, "job "+job, "the filesystems filter matches no filesystem").
+				hint("check the filter with `zrepl test filesystems --job %s --all`", job))
+		}
+		for _, p := range doctorTopmost(matched) {
+			datasets = append(datasets, doctorDataset{job, role, p, role.perms})
+		}
+	}
+	addRootFS := func(job, rootFS string, perms []string) {
+		p, err := zfs.NewDatasetPath(rootFS)
+		if err != nil {
+			findings = append(findings, doctorf(doctorFail, "job "+job, "invalid root_fs: %s", err))
+			return
+		}
+		datasets = append(datasets, doctorDataset{job, doctorRoleReceiver, p, perms})
+	}
+	for _, j := range jobs {
+		switch v := j.Ret.(type) {
+		case *config.PushJob:
+			addFilter(v.Name, doctorRoleSender, v.Filesystems)
+		case *config.SourceJob:
+			addFilter(v.Name, doctorRoleSender, v.Filesystems)
+		case *config.SnapJob:
+			addFilter(v.Name, doctorRoleSnap, v.Filesystems)
+		case *config.SinkJob:
+			addRootFS(v.Name, v.RootFS, doctorRecvPermissions(v.Recv, v.ClientQuotas))
+		case *config.PullJob:
+			perms := doctorRecvPermissions(v.Recv, nil)
+			addRootFS(v.Name, v.RootFS, perms)
+			for _, s := range v.Sources {
+				addRootFS(v.Name, s.RootFS, perms)
+			}
+		}
+	}
+	return datasets, findings
+}
+
+func doctorParent(ds string) (string, bool) {
+	i := strings.LastIndex(ds, "/")
+	if i < 0 {
+		return "", false
+	}
+	return ds[:i], true
+}
+
+// doctorTopmost returns the datasets of matched whose parent is not in matched.
+func doctorTopmost(matched []*zfs.DatasetPath) []*zfs.DatasetPath {
+	in := make(map[string]bool, len(matched))
+	for _, p := range matched {
+		in[p.ToString()] = true
+	}
+	var topmost []*zfs.DatasetPath
+	for _, p := range matched {
+		if parent, ok := doctorParent(p.ToString()); ok && in[parent] {
+			continue
+		}
+		topmost = append(topmost, p)
+	}
+	sort.Slice(topmost, func(i, j int) bool { return topmost[i].ToString() < topmost[j].ToString() })
+	return topmost
+}
+
+func doctorDatasets(ctx context.Context, conf *config.Config, jobs []config.JobEnum) []doctorFinding {
+	datasets, findings := doctorJobDatasets(ctx, jobs)
+
+	// pool features
+	type poolRole struct{ pool, role string }
+	checked := make(map[poolRole]bool)
+	for _, ds := range datasets {
+		pool, err := ds.path.Pool()
+		if err != nil {
+			continue // root_fs is validated by the config
+		}
+		if checked[poolRole{pool, ds.role.name}] {
+			continue
+		}
+		checked[poolRole{pool, ds.role.name}] = true
+		findings = append(findings, doctorPoolFeatures(ctx, pool, ds.role)...)
+	}
+
+	// delegations
+	if os.Geteuid() == 0 {
+		return append(findings, doctorf(doctorOK, "permissions", "running as root, no delegation required"))
+	}
+	if conf.Global.ZFSHelper != nil {
+		return append(findings, doctorf(doctorInfo, "permissions", "the daemon runs zfs through global.zfs_helper").
+			hint("run `zrepl doctor` as the user of the zfs-helper to check its permissions"))
+	}
+	u, err := user.Current()
+	if err != nil {
+		return append(findings, doctorf(doctorWarn, "permissions", "cannot determine the current user: %s", err))
+	}
+	groups := doctorUserGroups(u)
+	for _, ds := range datasets {
+		findings = append(findings, doctorDelegation(ctx, ds, u.Username, groups))
+	}
+	return findings
+}
+
+// doctorUserGroups returns the names of the groups that u is a member of, for matching `zfs allow -g` delegations.
+func doctorUserGroups(u *user.User) map[string]bool {
+	groups := map[string]bool{}
+	if gids, err := u.GroupIds(); err == nil {
+		for _, gid := range gids {
+			if g, err := user.LookupGroupId(gid); err == nil {
+				groups[g.Name] = true
+			}
+		}
+	}
+	return groups
+}
+
+func doctorPoolFeatures(ctx context.Context, pool string, role doctorRole) []doctorFinding {
+	check := "pool " + pool
+	features := append(append([]string{}, role.features...), role.optionalFeatures...)
+	if len(features) == 0 {
+		return nil
+	}
+	props := make([]string, len(features))
+	for i, f := range features {
+		props[i] = "feature@" + f
+	}
+	output, err := zfscmd.CommandContext(ctx, "zpool", "get", "-H", "-p", "-o", "property,value", strings.Join(props, ","), pool).CombinedOutput()
+	if err != nil {
+		return []doctorFinding{doctorf(doctorFail, check, "cannot get feature flags: %s", strings.TrimSpace(string(output)))}
+	}
+	state := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) == 2 {
+			state[strings.TrimPrefix(fields[0], "feature@")] = fields[1]
+		}
+	}
+	var findings []doctorFinding
+	for i, f := range features {
+		s := state[f]
+		if s == "enabled" || s == "active" {
+			continue
+		}
+		sev := doctorFail
+		if i >= len(role.features) {
+			sev = doctorWarn
+		}
+		findings = append(findings, doctorf(sev, check, "feature@%s is %q, the %s role of a job needs it", f, s, role.name).
+			hint("`zpool upgrade %s` enables all supported features", pool))
+	}
+	if len(findings) == 0 {
+		findings = append(findings, doctorf(doctorOK, check, "has the features required by the %s role", role.name))
+	}
+	return findings
+}
+
+func doctorDelegation(ctx context.Context, ds doctorDataset, username string, groups map[string]bool) doctorFinding {
+	check := "job " + ds.job
+	path, missing, err := doctorMissingPermissions(ctx, ds, username, groups)
+	if err != nil {
+		return doctorf(doctorFail, check, "%s", err)
+	}
+	if len(missing) > 0 {
+		return doctorf(doctorFail, check, "user %s lacks permissions %s on %s, required by the %s role", username, strings.Join(missing, ","), path, ds.role.name).
+			hint("zfs allow -u %s %s %s", username, strings.Join(ds.perms, ","), path)
+	}
+	return doctorf(doctorOK, check, "user %s has the permissions of the %s role on %s", username, ds.role.name, path)
+}
+
+// doctorMissingPermissions returns the permissions of ds.perms that username lacks on path,
+// which is ds.path or, if root_fs does not exist yet, its closest existing ancestor.
+func doctorMissingPermissions(ctx context.Context, ds doctorDataset, username string, groups map[string]bool) (path string, missing []string, err error) {
+	// root_fs may not exist yet, zfs allow shows the permissions of the ancestors
+	path = ds.path.ToString()
+	var output []byte
+	for {
+		output, err = zfscmd.CommandContext(ctx, zfs.ZFS_BINARY, "allow", path).CombinedOutput()
+		parent, ok := doctorParent(path)
+		if err == nil || !ok {
+			break
+		}
+		path = parent
+	}
+	if err != nil {
+		return "", nil, errors.Errorf("cannot get delegated permissions of %s: %s", ds.path.ToString(), strings.TrimSpace(string(output)))
+	}
+	have := zfsAllowPermissions(string(output), path, username, groups)
+	for _, p := range ds.perms {
+		if !have[p] {
+			missing = append(missing, p)
+		}
+	}
+	return path, missing, nil
+}
+
+// zfsAllowPermissions returns the permissions of username (member of groups) on dataset
+// in the output of `zfs allow dataset`, which lists the permissions on dataset and its ancestors:
+//
+//	---- Permissions on pool/a ------------------------------------------
+//	Permission sets:
+//		@backup hold,send
+//	Local+Descendent permissions:
+//		user zrepl @backup,bookmark
+//		everyone snapshot
+func zfsAllowPermissions(output, dataset, username string, groups map[string]bool) map[string]bool {
+	const blockPrefix = "---- Permissions on "
+	sets := make(map[string][]string)
+	var perms []string
+	var onDataset, local, descendent, isSets bool
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, blockPrefix) {
+			ds := strings.TrimRight(strings.TrimPrefix(line, blockPrefix), " -")
+			onDataset = ds == dataset
+			continue
+		}
+		if !strings.HasPrefix(line, "\t") && strings.HasSuffix(line, ":") {
+			section := strings.TrimSuffix(line, ":")
+			isSets = section == "Permission sets"
+			local = section == "Local permissions" || section == "Local+Descendent permissions"
+			descendent = section == "Descendent permissions" || section == "Local+Descendent permissions"
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if isSets {
+			sets[fields[0]] = append(sets[fields[0]], strings.Split(fields[len(fields)-1], ",")...)
+			continue
+		}
+		if !(onDataset && local || !onDataset && descendent) {
+			continue
+		}
+		var applies bool
+		switch fields[0] {
+		case "everyone":
+			applies = true
+		case "user":
+			applies = len(fields) == 3 && fields[1] == username
+		case "group":
+			applies = len(fields) == 3 && groups[fields[1]]
+		}
+		if applies {
+			perms = append(perms, strings.Split(fields[len(fields)-1], ",")...)
+		}
+	}
+
+	have := make(map[string]bool)
+	var add func(p string, depth int)
+	add = func(p string, depth int) {
+		if strings.HasPrefix(p, "@") {
+			if depth > 10 {
+				return // sets that include each other
+			}
+			for _, sp := range sets[p] {
+				add(sp, depth+1)
+			}
+			return
+		}
+		have[p] = true
+	}
+	for _, p := range perms {
+		add(p, 0)
+	}
+	return have
+}
+
+const doctorDialTimeout = 5 * time.Second
+
+func doctorDial(ctx context.Context, address string) error {
+	ctx, cancel := context.WithTimeout(ctx, doctorDialTimeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// doctorListenAddress returns the address to connect to a listener on listen, e.g. ":8888".
+func doctorListenAddress(listen string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return listen
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+func doctorListeners(ctx context.Context, conf *config.Config, jobs []config.JobEnum, d *doctorDaemon) []doctorFinding {
+	var findings []doctorFinding
+	connect := func(check string, c config.ConnectEnum) {
+		var address string
+		switch v := c.Ret.(type) {
+		case *config.TCPConnect:
+			address = v.Address
+		case *config.TLSConnect:
+			address = v.Address
+		case *config.SSHStdinserverConnect:
+			findings = append(findings, doctorf(doctorInfo, check, "ssh+stdinserver connection to %s is not checked", v.Host).
+				hint("run `zrepl test replication %s`", strings.TrimPrefix(check, "job ")))
+			return
+		default:
+			return
+		}
+		if err := doctorDial(ctx, address); err != nil {
+			findings = append(findings, doctorf(doctorFail, check, "cannot connect to %s: %s", address, err).
+				hint("check that the peer's daemon runs and listens on that address, DNS and firewalls"))
+			return
+		}
+		findings = append(findings, doctorf(doctorOK, check, "%s is reachable", address))
+	}
+	listen := func(check, listen string) {
+		if d == nil {
+			return // the daemon is not running, there is nothing to connect to
+		}
+		address := doctorListenAddress(listen)
+		if err := doctorDial(ctx, address); err != nil {
+			findings = append(findings, doctorf(doctorFail, check, "the daemon does not accept connections on %s: %s", listen, err).
+				hint("check the daemon's log for listen errors, e.g. `zrepl logs --follow --job %s`", strings.TrimPrefix(check, "job ")))
+			return
+		}
+		findings = append(findings, doctorf(doctorOK, check, "the daemon accepts connections on %s", listen))
+	}
+	serve := func(check string, s config.ServeEnum) {
+		switch v := s.Ret.(type) {
+		case *config.TCPServe:
+			listen(check, v.Listen)
+		case *config.TLSServe:
+			listen(check, v.Listen)
+		}
+	}
+
+	for _, j := range jobs {
+		check := "job " + j.Name()
+		switch v := j.Ret.(type) {
+		case *config.PushJob:
+			connect(check, v.Connect)
+			for _, dst := range v.Destinations {
+				connect(check, dst.Connect)
+			}
+		case *config.PullJob:
+			connect(check, v.Connect)
+			for _, src := range v.Sources {
+				connect(check, src.Connect)
+			}
+		case *config.SinkJob:
+			serve(check, v.Serve)
+		case *config.SourceJob:
+			serve(check, v.Serve)
+		}
+	}
+	if doctorArgs.job == "" {
+		for _, m := range conf.Global.Monitoring {
+			if p, ok := m.Ret.(*config.PrometheusMonitoring); ok {
+				listen("monitoring", p.Listen)
+			}
+		}
+	}
+	return findings
+}
+
+// doctorClockTolerance is the clock offset above which doctor warns.
+// Pruning by snapshot age, e.g. with the grid rule, compares creation times of one side with the clock of the other.
+const doctorClockTolerance = 30 * time.Second
+
+func doctorClock(peers []versionhandshake.PeerReport, jobs []config.JobEnum, tolerance time.Duration) []doctorFinding {
+	const check = "clock"
+	names := make(map[string]bool, len(jobs))
+	for _, j := range jobs {
+		names[j.Name()] = true
+	}
+	var findings []doctorFinding
+	seen := 0
+	for _, p := range peers {
+		if !names[p.Job] {
+			continue
+		}
+		seen++
+		peer := p.Job
+		if p.Peer != "" {
+			peer += " peer " + p.Peer
+		}
+		if p.ClockOffset == nil {
+			findings = append(findings, doctorf(doctorInfo, check, "job %s: the peer does not announce its clock", peer).
+				hint("upgrade zrepl on the peer"))
+			continue
+		}
+		offset := *p.ClockOffset
+		if offset < 0 {
+			offset = -offset
+		}
+		if offset > tolerance {
+			findings = append(findings, doctorf(doctorWarn, check, "job %s: the peer's clock differs by %s (at %s)", peer, p.ClockOffset.Round(time.Second), p.LastSeen.Format(time.RFC3339)).
+				hint("synchronize the clocks of both hosts, e.g. with NTP"))
+			continue
+		}
+		findings = append(findings, doctorf(doctorOK, check, "job %s: the peer's clock differs by less than %s", peer, tolerance))
+	}
+	if seen == 0 {
+		findings = append(findings, doctorf(doctorInfo, check, "no peers seen since the daemon started"))
+	}
+	return findings
+}
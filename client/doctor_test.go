@@ -0,0 +1,147 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/rpc/versionhandshake"
+	"github.com/zrepl/zrepl/zfs"
+	zfsprop "github.com/zrepl/zrepl/zfs/property"
+)
+
+func TestZFSAllowPermissions(t *testing.T) {
+	output := strings.Join([]string{
+		"---- Permissions on pool/backups/sink -------------------------------",
+		"Local permissions:",
+		"\tuser zrepl receive",
+		"---- Permissions on pool/backups ------------------------------------",
+		"Permission sets:",
+		"\t@recv create,mount,@holds",
+		"\t@holds hold,release",
+		"Descendent permissions:",
+		"\tgroup backup destroy",
+		"Local+Descendent permissions:",
+		"\tuser zrepl @recv",
+		"\tuser other userprop",
+		"Local permissions:",
+		"\teveryone send",
+		"---- Permissions on pool --------------------------------------------",
+		"Local+Descendent permissions:",
+		"\teveryone snapshot",
+		"",
+	}, "\n")
+
+	have := zfsAllowPermissions(output, "pool/backups/sink", "zrepl", map[string]bool{"backup": true})
+	assert.Equal(t, map[string]bool{
+		"receive":  true, // local on the dataset itself
+		"create":   true, // permission sets, nested
+		"mount":    true,
+		"hold":     true,
+		"release":  true,
+		"destroy":  true, // group
+		"snapshot": true, // everyone, inherited from the pool
+	}, have)
+
+	have = zfsAllowPermissions(output, "pool/backups/sink", "nobody", nil)
+	assert.Equal(t, map[string]bool{"snapshot": true}, have)
+}
+
+func TestDoctorZFSVersion(t *testing.T) {
+	userland, kmod, major := doctorParseZFSVersion("zfs-2.1.5-1ubuntu6~22.04.1\nzfs-kmod-2.1.4-1ubuntu6~22.04.1\n")
+	assert.Equal(t, "2.1.5", userland)
+	assert.Equal(t, "2.1.4", kmod)
+	assert.Equal(t, 2, major)
+
+	severities := func(findings []doctorFinding) (s []doctorSeverity) {
+		for _, f := range findings {
+			s = append(s, f.Severity)
+		}
+		return s
+	}
+	assert.Equal(t, []doctorSeverity{doctorOK}, severities(doctorZFSVersionFindings("zfs-2.1.4-FreeBSD_g52bad4f23\nzfs-kmod-v2.1.4-FreeBSD_g52bad4f23\n", nil)))
+	assert.Equal(t, []doctorSeverity{doctorOK, doctorWarn}, severities(doctorZFSVersionFindings("zfs-2.1.5-1\nzfs-kmod-2.1.4-1\n", nil)))
+	assert.Equal(t, []doctorSeverity{doctorWarn}, severities(doctorZFSVersionFindings("zfs-0.8.6-1\nzfs-kmod-0.8.6-1\n", nil)))
+	f := doctorZFSVersionFindings("unrecognized command 'version'\n", errors.New("exit status 2"))
+	require.Len(t, f, 1)
+	assert.Equal(t, doctorWarn, f[0].Severity)
+	assert.Contains(t, f[0].Message, "unrecognized command 'version' exit status 2")
+}
+
+func TestDoctorTopmost(t *testing.T) {
+	var paths []*zfs.DatasetPath
+	for _, p := range []string{"pool/b", "pool/a/x", "pool/a", "pool/a/x/y", "other/c/d"} {
+		dp, err := zfs.NewDatasetPath(p)
+		require.NoError(t, err)
+		paths = append(paths, dp)
+	}
+	var topmost []string
+	for _, p := range doctorTopmost(paths) {
+		topmost = append(topmost, p.ToString())
+	}
+	assert.Equal(t, []string{"other/c/d", "pool/a", "pool/b"}, topmost)
+}
+
+func TestDoctorRecvPermissions(t *testing.T) {
+	assert.Equal(t, doctorRoleReceiver.perms, doctorRecvPermissions(nil, nil))
+	recv := &config.RecvOptions{
+		Properties: &config.PropertyRecvOptions{
+			Inherit:  []zfsprop.Property{"mountpoint"},
+			Override: map[zfsprop.Property]string{"canmount": "off"},
+		},
+		Placeholder:  &config.PlaceholderRecvOptions{Encryption: "off"},
+		BrowseMounts: &config.BrowseMountsRecvOptions{Prefix: "/backups"},
+	}
+	assert.Equal(t, []string{"canmount", "create", "destroy", "encryption", "hold", "mount", "mountpoint", "readonly", "receive", "release", "rollback", "userprop"},
+		doctorRecvPermissions(recv, nil))
+	recv.ForceReceive = "never"
+	assert.NotContains(t, doctorRecvPermissions(recv, nil), "rollback")
+	assert.Contains(t, doctorRecvPermissions(&config.RecvOptions{EncryptOnReceive: true}, nil), "encryption")
+	perms := doctorRecvPermissions(nil, &config.SinkClientQuotas{Default: &config.ClientQuota{}})
+	assert.Subset(t, perms, []string{"quota", "reservation"})
+}
+
+func TestDoctorListenAddress(t *testing.T) {
+	assert.Equal(t, "localhost:8888", doctorListenAddress(":8888"))
+	assert.Equal(t, "localhost:8888", doctorListenAddress("0.0.0.0:8888"))
+	assert.Equal(t, "localhost:8888", doctorListenAddress("[::]:8888"))
+	assert.Equal(t, "192.0.2.1:8888", doctorListenAddress("192.0.2.1:8888"))
+}
+
+func TestDoctorClock(t *testing.T) {
+	offset := func(d time.Duration) *time.Duration { return &d }
+	peers := []versionhandshake.PeerReport{
+		{PeerKey: versionhandshake.PeerKey{Job: "sink", Peer: "prod"}, ClockOffset: offset(2 * time.Second)},
+		{PeerKey: versionhandshake.PeerKey{Job: "sink", Peer: "laptop"}, ClockOffset: offset(-5 * time.Minute)},
+		{PeerKey: versionhandshake.PeerKey{Job: "sink", Peer: "old"}},
+		{PeerKey: versionhandshake.PeerKey{Job: "other"}, ClockOffset: offset(time.Hour)},
+	}
+	jobs := []config.JobEnum{{Ret: &config.SinkJob{PassiveJob: config.PassiveJob{Name: "sink"}}}}
+
+	findings := doctorClock(peers, jobs, 30*time.Second)
+	require.Len(t, findings, 3)
+	assert.Equal(t, doctorOK, findings[0].Severity)
+	assert.Equal(t, doctorWarn, findings[1].Severity)
+	assert.Contains(t, findings[1].Message, "job sink peer laptop: the peer's clock differs by -5m0s")
+	assert.Equal(t, doctorInfo, findings[2].Severity)
+
+	findings = doctorClock(nil, jobs, 30*time.Second)
+	require.Len(t, findings, 1)
+	assert.Equal(t, "no peers seen since the daemon started", findings[0].Message)
+}
+
+func TestDoctorPrint(t *testing.T) {
+	var buf bytes.Buffer
+	failed := doctorPrint(&buf, []doctorFinding{
+		doctorf(doctorOK, "zfs", "OpenZFS 2.1.5"),
+		doctorf(doctorFail, "job prod", "cannot connect").hint("start the daemon"),
+	})
+	assert.Equal(t, 1, failed)
+	assert.Equal(t, "OK    zfs       OpenZFS 2.1.5\nFAIL  job prod  cannot connect\n                -> start the daemon\n", buf.String())
+}
@@ -0,0 +1,503 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon"
+	"github.com/zrepl/zrepl/transport"
+	"github.com/zrepl/zrepl/transport/ssh"
+)
+
+var identitiesArgs struct {
+	authorizedKeys string
+	zreplBinary    string
+	legacyOpenSSH  bool
+}
+
+var IdentitiesCmd = &cli.Subcommand{
+	Use:   "identities",
+	Short: "manage the client identities of stdinserver jobs and their authorized_keys entries",
+	SetupSubcommands: func() []*cli.Subcommand {
+		return []*cli.Subcommand{identitiesCmdList, identitiesCmdAdd, identitiesCmdRemove}
+	},
+}
+
+func identitiesSetupAuthorizedKeysFlag(f *pflag.FlagSet) {
+	f.StringVar(&identitiesArgs.authorizedKeys, "authorized-keys", "", "the authorized_keys file of the user that the connecting daemons log in as (default ~/.ssh/authorized_keys)")
+}
+
+var identitiesCmdList = &cli.Subcommand{
+	Use:        "list [--authorized-keys PATH]",
+	Short:      "list the client identities of the config and of the authorized_keys file, and their connections to the daemon",
+	SetupFlags: identitiesSetupAuthorizedKeysFlag,
+	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+		if len(args) != 0 {
+			return errors.New("list does not take arguments")
+		}
+		conf := subcommand.Config()
+		keysPath, err := identitiesAuthorizedKeysPath()
+		if err != nil {
+			return err
+		}
+		entries, err := readAuthorizedKeys(keysPath)
+		if err != nil && !os.IsNotExist(errors.Cause(err)) {
+			return err
+		}
+
+		var sockets []ssh.StdinserverSocketReport
+		httpc, err := localControlHttpClient(conf.Global.Control, daemon.ControlJobEndpointStdinserver)
+		if err == nil {
+			err = jsonRequestResponse(httpc, daemon.ControlJobEndpointStdinserver, "", &sockets)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: the connections are unknown, the daemon does not respond: %s\n", err)
+			sockets = nil
+		}
+
+		rows := identitiesRows(conf, entries, sockets, err == nil, statSocket)
+		return identitiesPrint(os.Stdout, rows)
+	},
+}
+
+var identitiesCmdAdd = &cli.Subcommand{
+	Use:   "add [--authorized-keys PATH] [--zrepl-binary PATH] [--legacy-openssh] CLIENT_IDENTITY PUBKEY_FILE",
+	Short: "allow the SSH key in PUBKEY_FILE (- for stdin) to connect as CLIENT_IDENTITY through `zrepl stdinserver`",
+	SetupFlags: func(f *pflag.FlagSet) {
+		identitiesSetupAuthorizedKeysFlag(f)
+		f.StringVar(&identitiesArgs.zreplBinary, "zrepl-binary", "zrepl", "the zrepl binary in the forced command, e.g. /usr/local/bin/zrepl if it is not in sshd's PATH")
+		f.BoolVar(&identitiesArgs.legacyOpenSSH, "legacy-openssh", false, "use the restrictions for OpenSSH < 7.2, which does not support the restrict option")
+	},
+	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+		if len(args) != 2 {
+			return errors.New("expected 2 arguments: CLIENT_IDENTITY PUBKEY_FILE")
+		}
+		identity := args[0]
+		if err := transport.ValidateClientIdentity(identity); err != nil {
+			return errors.Wrapf(err, "invalid client identity %q", identity)
+		}
+		if strings.Contains(identity, " ") {
+			// the forced command is split at spaces
+			return errors.Errorf("client identity %q must not contain spaces to be used with stdinserver", identity)
+		}
+		var pubkey []byte
+		var err error
+		if args[1] == "-" {
+			pubkey, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			pubkey, err = ioutil.ReadFile(args[1])
+		}
+		if err != nil {
+			return errors.Wrap(err, "read public key")
+		}
+		keysPath, err := identitiesAuthorizedKeysPath()
+		if err != nil {
+			return err
+		}
+
+		entry, err := authorizedKeysEntryLine(identity, string(pubkey),
+			identitiesStdinserverCommand(identitiesArgs.zreplBinary, subcommand.ConfigPath()), identitiesArgs.legacyOpenSSH)
+		if err != nil {
+			return err
+		}
+		err = updateAuthorizedKeys(keysPath, func(entries []authorizedKeysEntry) ([]authorizedKeysEntry, error) {
+			added, err := parseAuthorizedKeysLine(entry)
+			if err != nil {
+				panic(err) // we just generated it
+			}
+			for _, e := range entries {
+				if e.key != "" && e.key == added.key {
+					return nil, errors.Errorf("the key is already in %s on line %d, remove it first: sshd only uses the first entry of a key", keysPath, e.lineNo)
+				}
+			}
+			return append(entries, added), nil
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("added client identity %q to %s:\n%s\n", identity, keysPath, entry)
+
+		if jobs := identitiesConfigured(subcommand.Config())[identity]; len(jobs) == 0 {
+			fmt.Fprintf(os.Stderr, "WARNING: client identity %q is not in the client_identities of a stdinserver job, add it to the config and reload the daemon\n", identity)
+		}
+		return nil
+	},
+}
+
+var identitiesCmdRemove = &cli.Subcommand{
+	Use:        "remove [--authorized-keys PATH] CLIENT_IDENTITY",
+	Short:      "remove the authorized_keys entries of CLIENT_IDENTITY",
+	SetupFlags: identitiesSetupAuthorizedKeysFlag,
+	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+		if len(args) != 1 {
+			return errors.New("expected 1 argument: CLIENT_IDENTITY")
+		}
+		identity := args[0]
+		keysPath, err := identitiesAuthorizedKeysPath()
+		if err != nil {
+			return err
+		}
+		var removed int
+		err = updateAuthorizedKeys(keysPath, func(entries []authorizedKeysEntry) ([]authorizedKeysEntry, error) {
+			kept := entries[:0]
+			for _, e := range entries {
+				if e.identity == identity {
+					removed++
+					continue
+				}
+				kept = append(kept, e)
+			}
+			if removed == 0 {
+				return nil, errors.Errorf("%s has no entry for client identity %q", keysPath, identity)
+			}
+			return kept, nil
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("removed %d entry(s) of client identity %q from %s\n", removed, identity, keysPath)
+
+		if jobs := identitiesConfigured(subcommand.Config())[identity]; len(jobs) > 0 {
+			fmt.Fprintf(os.Stderr, "WARNING: client identity %q is still in the client_identities of job(s) %s, remove it from the config and reload the daemon to close its socket\n",
+				identity, strings.Join(jobs, ", "))
+		}
+		return nil
+	},
+}
+
+func identitiesAuthorizedKeysPath() (string, error) {
+	if identitiesArgs.authorizedKeys != "" {
+		return identitiesArgs.authorizedKeys, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.Wrap(err, "cannot determine the authorized_keys file, use --authorized-keys")
+	}
+	return filepath.Join(home, ".ssh", "authorized_keys"), nil
+}
+
+// identitiesStdinserverCommand returns the forced command for authorized_keys without the client identity.
+// configPath is passed on so that the command finds the daemon's sockets if the config is not in a default location.
+func identitiesStdinserverCommand(zreplBinary, configPath string) string {
+	cmd := zreplBinary
+	if configPath != "" {
+		if abs, err := filepath.Abs(configPath); err == nil {
+			configPath = abs
+		}
+		cmd += " --config " + configPath
+	}
+	return cmd + " stdinserver"
+}
+
+// identitiesConfigured returns the names of the stdinserver jobs in conf, by client identity.
+func identitiesConfigured(conf *config.Config) map[string][]string {
+	ret := make(map[string][]string)
+	for _, j := range conf.Jobs {
+		var serve config.ServeEnum
+		switch v := j.Ret.(type) {
+		case *config.SinkJob:
+			serve = v.Serve
+		case *config.SourceJob:
+			serve = v.Serve
+		default:
+			continue
+		}
+		if s, ok := serve.Ret.(*config.StdinserverServer); ok {
+			for _, ci := range s.ClientIdentities {
+				ret[ci] = append(ret[ci], j.Name())
+			}
+		}
+	}
+	return ret
+}
+
+// authorizedKeysEntry is a line of an authorized_keys file.
+// identity is set for the entries whose forced command is `zrepl stdinserver CLIENT_IDENTITY`.
+type authorizedKeysEntry struct {
+	lineNo   int // 1-based, 0 for added entries
+	line     string
+	identity string
+	// the key type and base64 part of the key, empty for comments and empty lines
+	keyType, key string
+}
+
+func isAuthorizedKeysKeyType(field string) bool {
+	return strings.HasPrefix(field, "ssh-") || strings.HasPrefix(field, "ecdsa-") || strings.HasPrefix(field, "sk-")
+}
+
+// parseAuthorizedKeysLine parses a line in the format of sshd(8), section AUTHORIZED_KEYS FILE FORMAT:
+// optional comma-separated options, which may contain quoted strings, followed by key type, base64 key and comment.
+func parseAuthorizedKeysLine(line string) (authorizedKeysEntry, error) {
+	e := authorizedKeysEntry{line: line}
+	rest := strings.TrimSpace(line)
+	if rest == "" || strings.HasPrefix(rest, "#") {
+		return e, nil
+	}
+
+	var options []string
+	if fields := strings.Fields(rest); !isAuthorizedKeysKeyType(fields[0]) {
+		var opt strings.Builder
+		quoted, escaped, end := false, false, len(rest)
+	scan:
+		for i, c := range rest {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\' && quoted:
+				escaped = true
+			case c == '"':
+				quoted = !quoted
+			case c == ',' && !quoted:
+				options = append(options, opt.String())
+				opt.Reset()
+				continue
+			case (c == ' ' || c == '\t') && !quoted:
+				end = i
+				break scan
+			}
+			opt.WriteRune(c)
+		}
+		if quoted {
+			return e, errors.New("unterminated quoted string in options")
+		}
+		options = append(options, opt.String())
+		rest = rest[end:]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) < 2 || !isAuthorizedKeysKeyType(fields[0]) {
+		return e, errors.New("expected key type and key after the options")
+	}
+	e.keyType, e.key = fields[0], fields[1]
+
+	for _, opt := range options {
+		if !strings.HasPrefix(strings.ToLower(opt), `command="`) || !strings.HasSuffix(opt, `"`) {
+			continue
+		}
+		cmd := strings.Replace(opt[len(`command="`):len(opt)-1], `\"`, `"`, -1)
+		cmdFields := strings.Fields(cmd)
+		for i, f := range cmdFields {
+			if f == "stdinserver" && i+1 < len(cmdFields) {
+				e.identity = cmdFields[i+1]
+				break
+			}
+		}
+	}
+	return e, nil
+}
+
+func readAuthorizedKeys(keysPath string) ([]authorizedKeysEntry, error) {
+	f, err := os.Open(keysPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseAuthorizedKeys(f, keysPath)
+}
+
+func parseAuthorizedKeys(r io.Reader, keysPath string) ([]authorizedKeysEntry, error) {
+	var entries []authorizedKeysEntry
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20) // certificates and options can make lines long
+	for lineNo := 1; s.Scan(); lineNo++ {
+		e, err := parseAuthorizedKeysLine(s.Text())
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s line %d", keysPath, lineNo)
+		}
+		e.lineNo = lineNo
+		entries = append(entries, e)
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrapf(err, "read %s", keysPath)
+	}
+	return entries, nil
+}
+
+// authorizedKeysEntryLine returns the authorized_keys line that forces the key in pubkey to run `command identity`.
+// pubkey is the content of a .pub file, i.e. key type, key and optional comment.
+func authorizedKeysEntryLine(identity, pubkey, command string, legacyOpenSSH bool) (string, error) {
+	var keyLine string
+	for _, l := range strings.Split(pubkey, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		if keyLine != "" {
+			return "", errors.New("the public key file must contain exactly one key")
+		}
+		keyLine = l
+	}
+	fields := strings.Fields(keyLine)
+	if len(fields) < 2 || !isAuthorizedKeysKeyType(fields[0]) {
+		return "", errors.New("the public key file must contain a single public key without options, e.g. a .pub file of ssh-keygen")
+	}
+	restrictions := "restrict"
+	if legacyOpenSSH {
+		restrictions = "no-port-forwarding,no-X11-forwarding,no-pty,no-agent-forwarding,no-user-rc"
+	}
+	return fmt.Sprintf(`command="%s %s",%s %s`, command, identity, restrictions, strings.Join(fields, " ")), nil
+}
+
+// updateAuthorizedKeys replaces the entries of the authorized_keys file at keysPath with the result of update.
+// The file is replaced atomically and keeps its mode and owner.
+// If it does not exist, it is created with mode 0600.
+func updateAuthorizedKeys(keysPath string, update func([]authorizedKeysEntry) ([]authorizedKeysEntry, error)) error {
+	mode := os.FileMode(0600)
+	uid, gid := -1, -1
+	entries, err := readAuthorizedKeys(keysPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		fi, err := os.Stat(keysPath)
+		if err != nil {
+			return err
+		}
+		mode = fi.Mode().Perm()
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+			uid, gid = int(st.Uid), int(st.Gid)
+		}
+	} else if err := os.MkdirAll(filepath.Dir(keysPath), 0700); err != nil {
+		return err
+	}
+
+	entries, err = update(entries)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, e := range entries {
+		b.WriteString(e.line)
+		b.WriteString("\n")
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(keysPath), "."+filepath.Base(keysPath)+".zrepl-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after the rename
+	_, err = tmp.WriteString(b.String())
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), mode)
+	}
+	if err == nil && uid != -1 && (uid != os.Getuid() || gid != os.Getgid()) {
+		err = os.Chown(tmp.Name(), uid, gid)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "write %s", tmp.Name())
+	}
+	return os.Rename(tmp.Name(), keysPath)
+}
+
+type identitiesRow struct {
+	identity string
+	jobs     []string // empty if the identity is not configured
+	keys     int
+	// socket state, see identitiesRows
+	socket    string
+	connected string
+	lastSeen  time.Time
+}
+
+func statSocket(sockpath string) bool {
+	fi, err := os.Stat(sockpath)
+	return err == nil && fi.Mode()&os.ModeSocket != 0
+}
+
+// identitiesRows combines the client identities of the config and of the authorized_keys entries.
+// If the daemon responded, sockets are its stdinserver sockets, otherwise the socket files are checked with sockExists.
+func identitiesRows(conf *config.Config, entries []authorizedKeysEntry, sockets []ssh.StdinserverSocketReport, daemonResponded bool, sockExists func(string) bool) []identitiesRow {
+	configured := identitiesConfigured(conf)
+	keys := make(map[string]int)
+	for _, e := range entries {
+		if e.identity != "" {
+			keys[e.identity]++
+		}
+	}
+	bySock := make(map[string]ssh.StdinserverSocketReport, len(sockets))
+	for _, s := range sockets {
+		bySock[s.SockPath] = s
+	}
+
+	identities := make(map[string]bool)
+	for ci := range configured {
+		identities[ci] = true
+	}
+	for ci := range keys {
+		identities[ci] = true
+	}
+	rows := make([]identitiesRow, 0, len(identities))
+	for ci := range identities {
+		r := identitiesRow{identity: ci, jobs: configured[ci], keys: keys[ci], socket: "-", connected: "-"}
+		if len(r.jobs) > 0 {
+			sockpath := path.Join(conf.Global.Serve.StdinServer.SockDir, ci)
+			switch s, ok := bySock[sockpath]; {
+			case !daemonResponded:
+				r.socket, r.connected = "missing", "unknown"
+				if sockExists(sockpath) {
+					r.socket = "exists"
+				}
+			case !ok || !s.Listening:
+				r.socket, r.connected = "not listening", "0"
+			default:
+				r.socket, r.connected, r.lastSeen = "listening", fmt.Sprintf("%d", s.Open), s.LastAccepted
+			}
+		}
+		rows = append(rows, r)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].identity < rows[j].identity })
+	return rows
+}
+
+func identitiesPrint(out io.Writer, rows []identitiesRow) error {
+	if len(rows) == 0 {
+		fmt.Fprintln(out, "no client identities in the config or the authorized_keys file")
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IDENTITY\tJOBS\tKEYS\tSOCKET\tCONNECTIONS\tLAST CONNECTED")
+	var problems []string
+	for _, r := range rows {
+		jobs := strings.Join(r.jobs, ",")
+		if jobs == "" {
+			jobs = "-"
+			problems = append(problems, fmt.Sprintf("client identity %q has authorized_keys entries, but is not in the client_identities of a stdinserver job", r.identity))
+		} else if r.keys == 0 {
+			problems = append(problems, fmt.Sprintf("client identity %q has no authorized_keys entry, use `zrepl identities add`", r.identity))
+		}
+		last := "-"
+		if !r.lastSeen.IsZero() {
+			last = r.lastSeen.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n", r.identity, jobs, r.keys, r.socket, r.connected, last)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for _, p := range problems {
+		fmt.Fprintf(out, "WARNING: %s\n", p)
+	}
+	return nil
+}
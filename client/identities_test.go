@@ -0,0 +1,167 @@
+package client
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/transport/ssh"
+)
+
+func TestParseAuthorizedKeysLine(t *testing.T) {
+	tcs := []struct {
+		line              string
+		identity, keyType string
+		err               bool
+	}{
+		{line: ""},
+		{line: "  # a comment"},
+		{line: "ssh-ed25519 AAAAC3Nz admin@laptop", keyType: "ssh-ed25519"},
+		{line: `command="zrepl stdinserver prod1",restrict ssh-ed25519 AAAAC3Nz root@prod1`, identity: "prod1", keyType: "ssh-ed25519"},
+		{line: `command="/usr/local/bin/zrepl --config /etc/zrepl/zrepl.yml stdinserver prod2",no-pty,no-user-rc ecdsa-sha2-nistp256 AAAAE2Vj`, identity: "prod2", keyType: "ecdsa-sha2-nistp256"},
+		{line: `from="10.0.0.1, 10.0.0.2",command="zrepl stdinserver prod3" ssh-rsa AAAAB3Nz`, identity: "prod3", keyType: "ssh-rsa"},
+		{line: `command="echo \"stdinserver\"" ssh-rsa AAAAB3Nz`, keyType: "ssh-rsa"},
+		{line: `command="rsync --server",restrict ssh-rsa AAAAB3Nz`, keyType: "ssh-rsa"},
+		{line: `command="zrepl stdinserver prod1 ssh-rsa AAAAB3Nz`, err: true},
+		{line: `restrict`, err: true},
+	}
+	for _, tc := range tcs {
+		e, err := parseAuthorizedKeysLine(tc.line)
+		if tc.err {
+			assert.Error(t, err, tc.line)
+			continue
+		}
+		require.NoError(t, err, tc.line)
+		assert.Equal(t, tc.identity, e.identity, tc.line)
+		assert.Equal(t, tc.keyType, e.keyType, tc.line)
+		assert.Equal(t, tc.line, e.line)
+	}
+}
+
+func TestAuthorizedKeysEntryLine(t *testing.T) {
+	cmd := identitiesStdinserverCommand("zrepl", "")
+	l, err := authorizedKeysEntryLine("prod1", "# generated\nssh-ed25519   AAAAC3Nz root@prod1\n", cmd, false)
+	require.NoError(t, err)
+	assert.Equal(t, `command="zrepl stdinserver prod1",restrict ssh-ed25519 AAAAC3Nz root@prod1`, l)
+	e, err := parseAuthorizedKeysLine(l)
+	require.NoError(t, err)
+	assert.Equal(t, "prod1", e.identity)
+
+	l, err = authorizedKeysEntryLine("prod1", "ssh-ed25519 AAAAC3Nz", identitiesStdinserverCommand("/usr/local/bin/zrepl", "/etc/zrepl/zrepl.yml"), true)
+	require.NoError(t, err)
+	assert.Equal(t, `command="/usr/local/bin/zrepl --config /etc/zrepl/zrepl.yml stdinserver prod1",no-port-forwarding,no-X11-forwarding,no-pty,no-agent-forwarding,no-user-rc ssh-ed25519 AAAAC3Nz`, l)
+
+	_, err = authorizedKeysEntryLine("prod1", "ssh-ed25519 AAAAC3Nz a\nssh-rsa AAAAB3Nz b\n", cmd, false)
+	assert.Error(t, err, "multiple keys")
+	_, err = authorizedKeysEntryLine("prod1", `restrict ssh-ed25519 AAAAC3Nz`, cmd, false)
+	assert.Error(t, err, "options")
+	_, err = authorizedKeysEntryLine("prod1", "", cmd, false)
+	assert.Error(t, err, "empty")
+}
+
+func TestUpdateAuthorizedKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-identities-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	keysPath := filepath.Join(dir, ".ssh", "authorized_keys")
+
+	add := func(line string) func([]authorizedKeysEntry) ([]authorizedKeysEntry, error) {
+		return func(entries []authorizedKeysEntry) ([]authorizedKeysEntry, error) {
+			e, err := parseAuthorizedKeysLine(line)
+			return append(entries, e), err
+		}
+	}
+	require.NoError(t, updateAuthorizedKeys(keysPath, add("ssh-ed25519 AAAAC3Nz admin@laptop")))
+	fi, err := os.Stat(keysPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	require.NoError(t, os.Chmod(keysPath, 0640))
+	require.NoError(t, updateAuthorizedKeys(keysPath, add(`command="zrepl stdinserver prod1",restrict ssh-rsa AAAAB3Nz`)))
+	content, err := ioutil.ReadFile(keysPath)
+	require.NoError(t, err)
+	assert.Equal(t, "ssh-ed25519 AAAAC3Nz admin@laptop\ncommand=\"zrepl stdinserver prod1\",restrict ssh-rsa AAAAB3Nz\n", string(content))
+	fi, err = os.Stat(keysPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), fi.Mode().Perm(), "keeps the mode")
+
+	entries, err := readAuthorizedKeys(keysPath)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, 2, entries[1].lineNo)
+	assert.Equal(t, "prod1", entries[1].identity)
+
+	err = updateAuthorizedKeys(keysPath, func([]authorizedKeysEntry) ([]authorizedKeysEntry, error) {
+		return nil, assert.AnError
+	})
+	assert.Equal(t, assert.AnError, err)
+	content2, err := ioutil.ReadFile(keysPath)
+	require.NoError(t, err)
+	assert.Equal(t, content, content2, "unchanged on error")
+	tmps, err := filepath.Glob(filepath.Join(dir, ".ssh", ".authorized_keys.zrepl-*"))
+	require.NoError(t, err)
+	assert.Empty(t, tmps)
+}
+
+func TestIdentitiesRows(t *testing.T) {
+	conf, err := config.ParseConfigBytes([]byte(`
+jobs:
+- name: sink
+  type: sink
+  root_fs: pool/sink
+  serve:
+    type: stdinserver
+    client_identities: [prod1, prod2]
+- name: source
+  type: source
+  filesystems: {"pool<": true}
+  snapshotting:
+    type: manual
+  serve:
+    type: stdinserver
+    client_identities: [prod2]
+`))
+	require.NoError(t, err)
+	entries := []authorizedKeysEntry{
+		{identity: "prod1"},
+		{identity: "prod1"},
+		{identity: "old"},
+		{},
+	}
+	sockdir := conf.Global.Serve.StdinServer.SockDir
+	lastAccepted := time.Date(2022, 7, 23, 14, 0, 0, 0, time.UTC)
+	sockets := []ssh.StdinserverSocketReport{
+		{ClientIdentity: "prod1", SockPath: filepath.Join(sockdir, "prod1"), Listening: true, Open: 2, LastAccepted: lastAccepted},
+	}
+
+	rows := identitiesRows(conf, entries, sockets, true, nil)
+	assert.Equal(t, []identitiesRow{
+		{identity: "old", keys: 1, socket: "-", connected: "-"},
+		{identity: "prod1", jobs: []string{"sink"}, keys: 2, socket: "listening", connected: "2", lastSeen: lastAccepted},
+		{identity: "prod2", jobs: []string{"sink", "source"}, socket: "not listening", connected: "0"},
+	}, rows)
+
+	var buf bytes.Buffer
+	require.NoError(t, identitiesPrint(&buf, rows))
+	assert.Equal(t, `IDENTITY  JOBS         KEYS  SOCKET         CONNECTIONS  LAST CONNECTED
+old       -            1     -              -            -
+prod1     sink         2     listening      2            2022-07-23T14:00:00Z
+prod2     sink,source  0     not listening  0            -
+WARNING: client identity "old" has authorized_keys entries, but is not in the client_identities of a stdinserver job
+WARNING: client identity "prod2" has no authorized_keys entry, use `+"`zrepl identities add`"+`
+`, buf.String())
+
+	// the daemon does not respond
+	rows = identitiesRows(conf, nil, nil, false, func(sockpath string) bool { return sockpath == filepath.Join(sockdir, "prod2") })
+	assert.Equal(t, []identitiesRow{
+		{identity: "prod1", jobs: []string{"sink"}, socket: "missing", connected: "unknown"},
+		{identity: "prod2", jobs: []string{"sink", "source"}, socket: "exists", connected: "unknown"},
+	}, rows)
+}
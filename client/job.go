@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	statusclient "github.com/zrepl/zrepl/client/status/client"
+	"github.com/zrepl/zrepl/daemon"
+)
+
+var JobCmd = &cli.Subcommand{
+	Use:   "job",
+	Short: "disable or enable the scheduling of a job at runtime",
+	SetupSubcommands: func() []*cli.Subcommand {
+		return []*cli.Subcommand{jobCmdDisable, jobCmdEnable, jobCmdMaintenance}
+	},
+}
+
+var jobDisableArgs struct {
+	abort     bool
+	temporary bool
+}
+
+var jobCmdDisable = &cli.Subcommand{
+	Use:          "disable [--abort] [--temporary] JOB",
+	CompleteArgs: cli.CompleteNthArg(0, statusclient.CompleteJobNames),
+	Short:        "skip the invocations of a job until it is enabled again",
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.BoolVar(&jobDisableArgs.abort, "abort", false, "also cancel the current invocation (active jobs only)")
+		f.BoolVar(&jobDisableArgs.temporary, "temporary", false, "enable the job again when the daemon restarts")
+	},
+	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+		return runJobStateCmd(subcommand, args, daemon.JobStateRequest{
+			Disabled:  true,
+			Abort:     jobDisableArgs.abort,
+			Temporary: jobDisableArgs.temporary,
+		})
+	},
+}
+
+var jobCmdEnable = &cli.Subcommand{
+	Use:          "enable JOB",
+	CompleteArgs: cli.CompleteNthArg(0, statusclient.CompleteJobNames),
+	Short:        "enable a job that was disabled",
+	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+		return runJobStateCmd(subcommand, args, daemon.JobStateRequest{Disabled: false})
+	},
+}
+
+func runJobStateCmd(subcommand *cli.Subcommand, args []string, req daemon.JobStateRequest) error {
+	if len(args) != 1 {
+		return errors.Errorf("Expected 1 argument: JOB")
+	}
+	req.Name = args[0]
+
+	httpc, err := controlHttpClient(subcommand.Config().Global.Control)
+	if err != nil {
+		return err
+	}
+	return jsonRequestResponse(httpc, daemon.ControlJobEndpointJobState, req, struct{}{})
+}
+
+var jobCmdMaintenance = &cli.Subcommand{
+	Use:   "maintenance",
+	Short: "skip new invocations of a job while running invocations complete",
+	SetupSubcommands: func() []*cli.Subcommand {
+		return []*cli.Subcommand{jobCmdMaintenanceStart, jobCmdMaintenanceEnd}
+	},
+}
+
+var jobMaintenanceStartArgs struct {
+	duration  time.Duration
+	temporary bool
+}
+
+var jobCmdMaintenanceStart = &cli.Subcommand{
+	Use:          "start [--for DURATION] [--temporary] JOB",
+	CompleteArgs: cli.CompleteNthArg(0, statusclient.CompleteJobNames),
+	Short:        "put a job into maintenance until it is ended or DURATION has passed",
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.DurationVar(&jobMaintenanceStartArgs.duration, "for", 0, "end the maintenance automatically after this duration, e.g. 4h")
+		f.BoolVar(&jobMaintenanceStartArgs.temporary, "temporary", false, "end the maintenance when the daemon restarts")
+	},
+	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+		if jobMaintenanceStartArgs.duration < 0 {
+			return errors.New("--for must be positive")
+		}
+		req := daemon.JobMaintenanceRequest{Start: true, Temporary: jobMaintenanceStartArgs.temporary}
+		if jobMaintenanceStartArgs.duration > 0 {
+			req.Until = time.Now().Add(jobMaintenanceStartArgs.duration)
+		}
+		return runJobMaintenanceCmd(subcommand, args, req)
+	},
+}
+
+var jobCmdMaintenanceEnd = &cli.Subcommand{
+	Use:          "end JOB",
+	CompleteArgs: cli.CompleteNthArg(0, statusclient.CompleteJobNames),
+	Short:        "end the maintenance started with `zrepl job maintenance start`",
+	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+		return runJobMaintenanceCmd(subcommand, args, daemon.JobMaintenanceRequest{Start: false})
+	},
+}
+
+func runJobMaintenanceCmd(subcommand *cli.Subcommand, args []string, req daemon.JobMaintenanceRequest) error {
+	if len(args) != 1 {
+		return errors.Errorf("Expected 1 argument: JOB")
+	}
+	req.Name = args[0]
+
+	httpc, err := controlHttpClient(subcommand.Config().Global.Control)
+	if err != nil {
+		return err
+	}
+	return jsonRequestResponse(httpc, daemon.ControlJobEndpointMaintenance, req, struct{}{})
+}
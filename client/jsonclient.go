@@ -2,23 +2,32 @@ package client
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
 	"io"
-	"net"
 	"net/http"
 
 	"github.com/pkg/errors"
+
+	statusclient "github.com/zrepl/zrepl/client/status/client"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon"
 )
 
-func controlHttpClient(sockpath string) (client http.Client, err error) {
-	return http.Client{
-		Transport: &http.Transport{
-			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
-				return net.Dial("unix", sockpath)
-			},
-		},
-	}, nil
+func controlHttpClient(conf *config.GlobalControl) (client http.Client, err error) {
+	c, err := statusclient.HTTPClientFromConfig(conf)
+	if err != nil {
+		return http.Client{}, err
+	}
+	return *c, nil
+}
+
+// localControlHttpClient is controlHttpClient for commands that use endpoint, which the control
+// listener of a remote daemon does not serve (see daemon.ControlListenerEndpoints).
+func localControlHttpClient(conf *config.GlobalControl, endpoint string) (http.Client, error) {
+	if conf.Connect != nil && !daemon.ControlListenerEndpoints[endpoint] {
+		return http.Client{}, errors.Errorf("command is not available with global.control.connect: the control listener of a remote daemon does not serve %s", endpoint)
+	}
+	return controlHttpClient(conf)
 }
 
 func jsonRequestResponse(c http.Client, endpoint string, req interface{}, res interface{}) error {
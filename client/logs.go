@@ -0,0 +1,103 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	statusclient "github.com/zrepl/zrepl/client/status/client"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon"
+	"github.com/zrepl/zrepl/daemon/logging"
+	"github.com/zrepl/zrepl/logger"
+)
+
+var logsArgs struct {
+	follow bool
+	job    string
+	level  logger.Level
+	format string
+}
+
+var LogsCmd = &cli.Subcommand{
+	Use:   "logs --follow [--job JOB] [--level LEVEL]",
+	Short: "stream the log entries of the running daemon",
+	SetupFlags: func(f *pflag.FlagSet) {
+		logsArgs.level = logger.Info
+		f.BoolVarP(&logsArgs.follow, "follow", "f", false, "stream new log entries until interrupted")
+		f.StringVar(&logsArgs.job, "job", "", "only stream the log entries of JOB")
+		f.Var(&logsArgs.level, "level", "minimum log level")
+		f.StringVar(&logsArgs.format, "format", "human", "output format (human|logfmt|json)")
+	},
+	CompleteFlags: map[string]cli.CompletionFunc{
+		"job":    statusclient.CompleteJobNames,
+		"level":  func(*config.Config, []string) []string { return []string{"debug", "info", "warn", "error"} },
+		"format": func(*config.Config, []string) []string { return []string{"human", "logfmt", "json"} },
+	},
+	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+		if len(args) != 0 {
+			return errors.New("logs does not take arguments")
+		}
+		if !logsArgs.follow {
+			// log history is the business of the configured outlets
+			return errors.New("the daemon does not keep past log entries, use --follow to stream new ones")
+		}
+		return runLogsCmd(subcommand.Config())
+	},
+}
+
+func runLogsCmd(conf *config.Config) error {
+	outlet, _, err := logging.ParseOutlet(config.LoggingOutletEnum{Ret: &config.StdoutLoggingOutlet{
+		LoggingOutletCommon: config.LoggingOutletCommon{Type: "stdout", Level: "debug", Format: logsArgs.format},
+		Time:                true,
+		Color:               true,
+	}})
+	if err != nil {
+		return errors.Wrap(err, "invalid --format")
+	}
+
+	httpc, err := localControlHttpClient(conf.Global.Control, daemon.ControlJobEndpointLogs)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	err = json.NewEncoder(&buf).Encode(daemon.LogStreamRequest{Job: logsArgs.job, Level: logsArgs.level})
+	if err != nil {
+		return err
+	}
+	resp, err := httpc.Post("http://unix"+daemon.ControlJobEndpointLogs, "application/json", &buf)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var msg bytes.Buffer
+		_, _ = io.CopyN(&msg, resp.Body, 4096) // ignore error, just display what we got
+		return errors.Errorf("%s", bytes.TrimSpace(msg.Bytes()))
+	}
+
+	return logsCopy(bufio.NewReader(resp.Body), outlet)
+}
+
+// logsCopy writes the entries of a log stream to outlet until the stream ends.
+func logsCopy(r io.Reader, outlet logger.Outlet) error {
+	dec := json.NewDecoder(r)
+	for {
+		var e daemon.LogStreamEntry
+		if err := dec.Decode(&e); err == io.EOF {
+			return errors.New("daemon closed the log stream")
+		} else if err != nil {
+			return errors.Wrap(err, "cannot decode log stream")
+		}
+		if err := outlet.WriteEntry(e.Entry()); err != nil {
+			return err
+		}
+	}
+}
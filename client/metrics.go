@@ -0,0 +1,161 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon"
+)
+
+var metricsArgs struct {
+	prefixes []string
+	raw      bool
+}
+
+var MetricsCmd = &cli.Subcommand{
+	Use:   "metrics [--prefix PREFIX]...",
+	Short: "print the current Prometheus metrics of the running daemon",
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.StringSliceVar(&metricsArgs.prefixes, "prefix", nil, "only print metrics whose name starts with PREFIX (repeatable)")
+		f.BoolVar(&metricsArgs.raw, "raw", false, "print the Prometheus text format instead of a table")
+	},
+	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+		if len(args) != 0 {
+			return errors.New("metrics does not take arguments")
+		}
+		return runMetricsCmd(subcommand.Config(), os.Stdout)
+	},
+}
+
+func runMetricsCmd(conf *config.Config, out io.Writer) error {
+	httpc, err := localControlHttpClient(conf.Global.Control, daemon.ControlJobEndpointMetrics)
+	if err != nil {
+		return err
+	}
+	resp, err := httpc.Get("http://unix" + daemon.ControlJobEndpointMetrics)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var msg strings.Builder
+		_, _ = io.CopyN(&msg, resp.Body, 4096) // ignore error, just display what we got
+		return errors.Errorf("daemon returned %s: %s", resp.Status, msg.String())
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "cannot parse metrics of daemon")
+	}
+	filtered := metricsFilter(families, metricsArgs.prefixes)
+	if len(filtered) == 0 && len(metricsArgs.prefixes) > 0 {
+		return errors.Errorf("no metrics with prefix %s", strings.Join(metricsArgs.prefixes, ", "))
+	}
+
+	if metricsArgs.raw {
+		for _, f := range filtered {
+			if _, err := expfmt.MetricFamilyToText(out, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return metricsPrint(out, filtered)
+}
+
+// metricsFilter returns the families whose name starts with one of prefixes, sorted by name.
+// All families are returned if prefixes is empty.
+func metricsFilter(families map[string]*dto.MetricFamily, prefixes []string) []*dto.MetricFamily {
+	var l []*dto.MetricFamily
+	for name, f := range families {
+		match := len(prefixes) == 0
+		for _, p := range prefixes {
+			if strings.HasPrefix(name, p) {
+				match = true
+				break
+			}
+		}
+		if match {
+			l = append(l, f)
+		}
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].GetName() < l[j].GetName() })
+	return l
+}
+
+// metricsPrint prints one block per family, with one line per label set.
+// Histograms and summaries are summarized by their count, sum and average.
+func metricsPrint(out io.Writer, families []*dto.MetricFamily) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for i, f := range families {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s (%s)\t%s\n", f.GetName(), strings.ToLower(f.GetType().String()), f.GetHelp())
+		metrics := make([]*dto.Metric, len(f.GetMetric()))
+		copy(metrics, f.GetMetric())
+		sort.SliceStable(metrics, func(i, j int) bool {
+			return metricsLabels(metrics[i]) < metricsLabels(metrics[j])
+		})
+		for _, m := range metrics {
+			labels := metricsLabels(m)
+			if labels == "" {
+				labels = "-"
+			}
+			fmt.Fprintf(w, "  %s\t%s\n", labels, metricsValue(f.GetType(), m))
+		}
+	}
+	return w.Flush()
+}
+
+func metricsLabels(m *dto.Metric) string {
+	labels := make([]string, len(m.GetLabel()))
+	for i, l := range m.GetLabel() {
+		labels[i] = fmt.Sprintf("%s=%q", l.GetName(), l.GetValue())
+	}
+	sort.Strings(labels)
+	return strings.Join(labels, " ")
+}
+
+func metricsValue(t dto.MetricType, m *dto.Metric) string {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	summarize := func(count uint64, sum float64) string {
+		if count == 0 {
+			return "count=0"
+		}
+		return fmt.Sprintf("count=%d sum=%s avg=%s", count, f(sum), f(sum/float64(count)))
+	}
+	switch t {
+	case dto.MetricType_COUNTER:
+		return f(m.GetCounter().GetValue())
+	case dto.MetricType_GAUGE:
+		return f(m.GetGauge().GetValue())
+	case dto.MetricType_HISTOGRAM:
+		h := m.GetHistogram()
+		return summarize(h.GetSampleCount(), h.GetSampleSum())
+	case dto.MetricType_SUMMARY:
+		s := m.GetSummary()
+		v := summarize(s.GetSampleCount(), s.GetSampleSum())
+		for _, q := range s.GetQuantile() {
+			v += fmt.Sprintf(" q%s=%s", f(q.GetQuantile()), f(q.GetValue()))
+		}
+		return v
+	default:
+		return f(m.GetUntyped().GetValue())
+	}
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+)
+
+const metricsTestInput = `# HELP zrepl_control_request_begin number of request we started to handle
+# TYPE zrepl_control_request_begin counter
+zrepl_control_request_begin{endpoint="/status"} 12
+zrepl_control_request_begin{endpoint="/metrics"} 1
+# HELP zrepl_zfs_list_duration seconds it took for zfs list to return
+# TYPE zrepl_zfs_list_duration histogram
+zrepl_zfs_list_duration_bucket{le="1"} 3
+zrepl_zfs_list_duration_bucket{le="+Inf"} 4
+zrepl_zfs_list_duration_sum 6
+zrepl_zfs_list_duration_count 4
+# HELP go_goroutines Number of goroutines that currently exist.
+# TYPE go_goroutines gauge
+go_goroutines 42
+`
+
+func TestMetricsPrint(t *testing.T) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(metricsTestInput))
+	require.NoError(t, err)
+
+	var all []string
+	for _, f := range metricsFilter(families, nil) {
+		all = append(all, f.GetName())
+	}
+	assert.Equal(t, []string{"go_goroutines", "zrepl_control_request_begin", "zrepl_zfs_list_duration"}, all)
+
+	var buf bytes.Buffer
+	require.NoError(t, metricsPrint(&buf, metricsFilter(families, []string{"zrepl_control", "zrepl_zfs"})))
+	assert.Equal(t, `zrepl_control_request_begin (counter)  number of request we started to handle
+  endpoint="/metrics"                  1
+  endpoint="/status"                   12
+
+zrepl_zfs_list_duration (histogram)  seconds it took for zfs list to return
+  -                                  count=4 sum=6 avg=1.5
+`, buf.String())
+}
+
+func TestMetricsCmdRemote(t *testing.T) {
+	conf := &config.Config{Global: &config.Global{Control: &config.GlobalControl{
+		Connect: &config.GlobalControlConnect{Address: "backup1.example.com:9811"},
+	}}}
+	err := runMetricsCmd(conf, ioutil.Discard)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not serve /metrics")
+}
@@ -42,6 +42,15 @@ var migrations = []*cli.Subcommand{
 			f.BoolVar(&migrateReplicationCursorArgs.dryRun, "dry-run", false, "dry run")
 		},
 	},
+	&cli.Subcommand{
+		Use:   "root-fs JOB OLD NEW",
+		Short: "move the filesystems received by sink or pull job JOB from root_fs OLD to NEW",
+		Run:   doMigrateRootFS,
+		SetupFlags: func(f *pflag.FlagSet) {
+			f.BoolVar(&migrateRootFSArgs.dryRun, "dry-run", false, "dry run")
+		},
+		CompleteArgs: cli.CompleteNthArg(0, cli.ConfigJobNames),
+	},
 }
 
 var migratePlaceholder0_1Args struct {
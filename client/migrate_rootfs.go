@@ -0,0 +1,310 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+var migrateRootFSArgs struct {
+	dryRun bool
+}
+
+// The state of a filesystem below root_fs that subsequent incremental receives depend on.
+type migrateRootFSState struct {
+	IsPlaceholder bool
+	// sorted by createtxg
+	Snapshots []zfs.FilesystemVersion
+	// the snapshot with the job's last-received hold, nil if there is none
+	LastReceived *zfs.FilesystemVersion
+}
+
+func (s *migrateRootFSState) newest() *zfs.FilesystemVersion {
+	if len(s.Snapshots) == 0 {
+		return nil
+	}
+	return &s.Snapshots[len(s.Snapshots)-1]
+}
+
+func (s *migrateRootFSState) snapshotByGUID(guid uint64) *zfs.FilesystemVersion {
+	for i := range s.Snapshots {
+		if s.Snapshots[i].Guid == guid {
+			return &s.Snapshots[i]
+		}
+	}
+	return nil
+}
+
+func doMigrateRootFS(ctx context.Context, sc *cli.Subcommand, args []string) error {
+	if len(args) != 3 {
+		return errors.Errorf("expected 3 arguments: JOB OLD NEW")
+	}
+	jobName := args[0]
+	oldRoot, err := zfs.NewDatasetPath(args[1])
+	if err != nil || oldRoot.Empty() {
+		return errors.Errorf("OLD %q is not a valid dataset path", args[1])
+	}
+	newRoot, err := zfs.NewDatasetPath(args[2])
+	if err != nil || newRoot.Empty() {
+		return errors.Errorf("NEW %q is not a valid dataset path", args[2])
+	}
+	if oldRoot.HasPrefix(newRoot) || newRoot.HasPrefix(oldRoot) {
+		return errors.Errorf("OLD %q and NEW %q must not contain each other", oldRoot.ToString(), newRoot.ToString())
+	}
+
+	cfg := sc.Config()
+	rootFS, err := migrateRootFSConfiguredRoot(cfg, jobName)
+	if err != nil {
+		return err
+	}
+	if rootFS != oldRoot.ToString() && rootFS != newRoot.ToString() {
+		return errors.Errorf("root_fs of job %q is %q, expected OLD or NEW", jobName, rootFS)
+	}
+	jobID, err := endpoint.MakeJobID(jobName)
+	if err != nil {
+		return errors.Wrap(err, "job name")
+	}
+	if err := migrateRootFSCheckDaemon(cfg, jobName); err != nil {
+		return err
+	}
+
+	before, err := migrateRootFSListStates(ctx, oldRoot, jobID)
+	if err != nil {
+		return err
+	}
+	if len(before) == 0 {
+		return errors.Errorf("OLD %q does not exist", oldRoot.ToString())
+	}
+
+	newRootState, err := zfs.ZFSGetFilesystemPlaceholderState(ctx, newRoot)
+	if err != nil {
+		return errors.Wrapf(err, "cannot check whether %q exists", newRoot.ToString())
+	}
+	copied := newRootState.FSExists
+	if copied {
+		fmt.Printf("%q exists, assuming it is a copy of %q, e.g. made with `zfs send -R`\n", newRoot.ToString(), oldRoot.ToString())
+	} else {
+		oldPool, _ := oldRoot.Pool()
+		newPool, _ := newRoot.Pool()
+		if oldPool != newPool {
+			return errors.Errorf("NEW %q does not exist and cannot be renamed to from another pool: "+
+				"copy the filesystems first, e.g. with `zfs snapshot -r %s@migrate && zfs send -R %s@migrate | zfs recv %s && zfs destroy -r %s@migrate`, then run this command again",
+				newRoot.ToString(), oldRoot.ToString(), oldRoot.ToString(), newRoot.ToString(), newRoot.ToString())
+		}
+		fmt.Printf("rename %q to %q\n", oldRoot.ToString(), newRoot.ToString())
+	}
+	if migrateRootFSArgs.dryRun {
+		for _, rel := range migrateRootFSSortedKeys(before) {
+			fmt.Printf("\t%q => %q\n", migrateRootFSPath(oldRoot, rel).ToString(), migrateRootFSPath(newRoot, rel).ToString())
+		}
+		succ.Printf("DRY RUN\n")
+		return nil
+	}
+	if !copied {
+		if err := zfs.ZFSRename(ctx, oldRoot, newRoot); err != nil {
+			return errors.Wrap(err, "rename")
+		}
+	}
+
+	after, err := migrateRootFSListStates(ctx, newRoot, jobID)
+	if err != nil {
+		return err
+	}
+	if err := migrateRootFSRewrite(ctx, newRoot, jobID, before, after); err != nil {
+		return err
+	}
+
+	after, err = migrateRootFSListStates(ctx, newRoot, jobID)
+	if err != nil {
+		return err
+	}
+	problems := migrateRootFSValidate(before, after)
+	for _, rel := range migrateRootFSSortedKeys(before) {
+		fs := migrateRootFSPath(newRoot, rel).ToString()
+		if len(problems[rel]) == 0 {
+			succ.Printf("%q OK\n", fs)
+			continue
+		}
+		for _, p := range problems[rel] {
+			fail.Printf("%q: %s\n", fs, p)
+		}
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("%d filesystem(s) below %q are not ready for incremental replication", len(problems), newRoot.ToString())
+	}
+
+	if rootFS == oldRoot.ToString() {
+		bold.Printf("set root_fs of job %q to %q and reload the daemon before enabling the job\n", jobName, newRoot.ToString())
+	}
+	if copied {
+		fmt.Printf("the filesystems below %q are left in place, destroy them once replication to %q works\n", oldRoot.ToString(), newRoot.ToString())
+	}
+	return nil
+}
+
+func migrateRootFSConfiguredRoot(cfg *config.Config, jobName string) (string, error) {
+	for _, j := range cfg.Jobs {
+		if j.Name() != jobName {
+			continue
+		}
+		switch job := j.Ret.(type) {
+		case *config.SinkJob:
+			return job.RootFS, nil
+		case *config.PullJob:
+			return job.RootFS, nil
+		default:
+			return "", errors.Errorf("job %q is not a sink or pull job", jobName)
+		}
+	}
+	return "", errors.Errorf("job %q does not exist in the config", jobName)
+}
+
+// The job must not receive while its filesystems are moved.
+// If the daemon is not running, there is nothing to check.
+func migrateRootFSCheckDaemon(cfg *config.Config, jobName string) error {
+	httpc, err := controlHttpClient(cfg.Global.Control)
+	if err != nil {
+		return nil
+	}
+	var s daemon.Status
+	if err := jsonRequestResponse(httpc, daemon.ControlJobEndpointStatus, struct{}{}, &s); err != nil {
+		return nil
+	}
+	if js, ok := s.Jobs[jobName]; ok && !js.Disabled {
+		return errors.Errorf("job %q is running in the daemon: stop the daemon or `zrepl job disable --abort %s` first", jobName, jobName)
+	}
+	return nil
+}
+
+// returns the states of root and the filesystems below it, keyed by their path relative to root
+func migrateRootFSListStates(ctx context.Context, root *zfs.DatasetPath, jobID endpoint.JobID) (map[string]*migrateRootFSState, error) {
+	holdTag, err := endpoint.LastReceivedHoldTag(jobID)
+	if err != nil {
+		return nil, errors.Wrap(err, "last-received hold tag")
+	}
+	fss, err := zfs.ZFSListMapping(ctx, zfs.NoFilter())
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list filesystems")
+	}
+	states := make(map[string]*migrateRootFSState)
+	for _, fs := range fss {
+		if !fs.HasPrefix(root) {
+			continue
+		}
+		ph, err := zfs.ZFSGetFilesystemPlaceholderState(ctx, fs)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot get placeholder state of %q", fs.ToString())
+		}
+		snaps, err := zfs.ZFSListFilesystemVersions(ctx, fs, zfs.ListFilesystemVersionsOptions{Types: zfs.Snapshots})
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot list snapshots of %q", fs.ToString())
+		}
+		sort.Slice(snaps, func(i, j int) bool { return snaps[i].CreateTXG < snaps[j].CreateTXG })
+		st := &migrateRootFSState{IsPlaceholder: ph.IsPlaceholder, Snapshots: snaps}
+		for i := range snaps {
+			if !snaps[i].UserRefs.Valid || snaps[i].UserRefs.Value == 0 {
+				continue
+			}
+			tags, err := zfs.ZFSHolds(ctx, fs.ToString(), snaps[i].Name)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot list holds of %q", snaps[i].ToAbsPath(fs))
+			}
+			for _, tag := range tags {
+				if tag == holdTag {
+					st.LastReceived = &snaps[i]
+				}
+			}
+		}
+		rel := fs.Copy()
+		rel.TrimPrefix(root)
+		states[rel.ToString()] = st
+	}
+	return states, nil
+}
+
+// Restores the placeholder property and the last-received hold below newRoot that `zfs rename` or `zfs send -R` did not preserve:
+// legacy placeholder values depend on the filesystem's path, and `zfs send -R` does not send holds.
+func migrateRootFSRewrite(ctx context.Context, newRoot *zfs.DatasetPath, jobID endpoint.JobID, before, after map[string]*migrateRootFSState) error {
+	for _, rel := range migrateRootFSSortedKeys(before) {
+		b := before[rel]
+		a, ok := after[rel]
+		if !ok {
+			continue // reported by migrateRootFSValidate
+		}
+		fs := migrateRootFSPath(newRoot, rel)
+		if b.IsPlaceholder && !a.IsPlaceholder {
+			fmt.Printf("%q: set %s\n", fs.ToString(), zfs.PlaceholderPropertyName)
+			if err := zfs.ZFSSetPlaceholder(ctx, fs, true); err != nil {
+				return errors.Wrapf(err, "cannot set placeholder property of %q", fs.ToString())
+			}
+		}
+		if b.LastReceived == nil || (a.LastReceived != nil && a.LastReceived.Guid == b.LastReceived.Guid) {
+			continue
+		}
+		v := a.snapshotByGUID(b.LastReceived.Guid)
+		if v == nil {
+			continue // reported by migrateRootFSValidate
+		}
+		fmt.Printf("%q: create last-received hold on %q\n", fs.ToString(), v.RelName())
+		if _, err := endpoint.CreateLastReceivedHold(ctx, fs.ToString(), *v, jobID); err != nil {
+			return errors.Wrapf(err, "cannot create last-received hold on %q", v.ToAbsPath(fs))
+		}
+	}
+	return nil
+}
+
+// migrateRootFSValidate returns, by relative path, why the filesystems in after cannot continue to receive incrementally where the filesystems in before left off.
+// Incremental receives need the snapshot of the latest receive to be the newest snapshot.
+func migrateRootFSValidate(before, after map[string]*migrateRootFSState) map[string][]string {
+	problems := make(map[string][]string)
+	for rel, b := range before {
+		a, ok := after[rel]
+		if !ok {
+			problems[rel] = append(problems[rel], "does not exist")
+			continue
+		}
+		if b.IsPlaceholder != a.IsPlaceholder {
+			problems[rel] = append(problems[rel], fmt.Sprintf("placeholder state changed from %v to %v", b.IsPlaceholder, a.IsPlaceholder))
+		}
+		an := a.newest()
+		if b.LastReceived != nil {
+			if a.LastReceived == nil || a.LastReceived.Guid != b.LastReceived.Guid {
+				problems[rel] = append(problems[rel], fmt.Sprintf("the last-received hold is not on %q (guid %d)", b.LastReceived.RelName(), b.LastReceived.Guid))
+			} else if an.Guid != b.LastReceived.Guid {
+				problems[rel] = append(problems[rel], fmt.Sprintf("snapshot %q is newer than the last received snapshot %q, destroy it or the next receive fails", an.RelName(), b.LastReceived.RelName()))
+			}
+		} else if an != nil && b.snapshotByGUID(an.Guid) == nil {
+			problems[rel] = append(problems[rel], fmt.Sprintf("newest snapshot %q does not exist below OLD", an.RelName()))
+		}
+	}
+	return problems
+}
+
+func migrateRootFSPath(root *zfs.DatasetPath, rel string) *zfs.DatasetPath {
+	p := root.Copy()
+	if rel != "" {
+		relPath, err := zfs.NewDatasetPath(rel)
+		if err != nil {
+			panic(err) // rel is the suffix of a valid path
+		}
+		p.Extend(relPath)
+	}
+	return p
+}
+
+func migrateRootFSSortedKeys(states map[string]*migrateRootFSState) []string {
+	keys := make([]string, 0, len(states))
+	for k := range states {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestMigrateRootFSValidate(t *testing.T) {
+	snap := func(name string, guid uint64) zfs.FilesystemVersion {
+		return zfs.FilesystemVersion{Type: zfs.Snapshot, Name: name, Guid: guid, CreateTXG: guid}
+	}
+	s1, s2, migrate := snap("s1", 1), snap("s2", 2), snap("migrate", 3)
+	state := func(placeholder bool, lastReceived *zfs.FilesystemVersion, snaps ...zfs.FilesystemVersion) *migrateRootFSState {
+		return &migrateRootFSState{IsPlaceholder: placeholder, Snapshots: snaps, LastReceived: lastReceived}
+	}
+
+	before := map[string]*migrateRootFSState{
+		"":                  state(false, nil),
+		"client":            state(true, nil),
+		"client/pool/a":     state(false, &s2, s1, s2),
+		"client/pool/b":     state(false, &s2, s1, s2, migrate),
+		"client/pool/c":     state(false, &s2, s1, s2),
+		"client/pool/d":     state(false, nil, s1),
+		"client/pool/e":     state(false, &s1, s1),
+		"client/pool/empty": state(false, nil),
+	}
+	after := map[string]*migrateRootFSState{
+		"":              state(false, nil),
+		"client":        state(false, nil),
+		"client/pool/a": state(false, &s2, s1, s2),
+		// copied with `zfs send -R` of a snapshot created for the migration
+		"client/pool/b": state(false, &s2, s1, s2, migrate),
+		// the hold was not recreated
+		"client/pool/c": state(false, nil, s1, s2),
+		"client/pool/d": state(false, nil, s1, snap("foreign", 4)),
+		"client/pool/e": state(false, &s1, s1),
+	}
+
+	problems := migrateRootFSValidate(before, after)
+	for _, ok := range []string{"", "client/pool/a", "client/pool/e"} {
+		assert.Empty(t, problems[ok], ok)
+	}
+	assert.Equal(t, map[string][]string{
+		"client":            {"placeholder state changed from true to false"},
+		"client/pool/b":     {`snapshot "@migrate" is newer than the last received snapshot "@s2", destroy it or the next receive fails`},
+		"client/pool/c":     {`the last-received hold is not on "@s2" (guid 2)`},
+		"client/pool/d":     {`newest snapshot "@foreign" does not exist below OLD`},
+		"client/pool/empty": {"does not exist"},
+	}, problems)
+}
+
+func TestMigrateRootFSPath(t *testing.T) {
+	root, err := zfs.NewDatasetPath("newpool/sink")
+	assert.NoError(t, err)
+	assert.Equal(t, "newpool/sink", migrateRootFSPath(root, "").ToString())
+	assert.Equal(t, "newpool/sink/client/pool/a", migrateRootFSPath(root, "client/pool/a").ToString())
+	assert.Equal(t, "newpool/sink", root.ToString(), "root must not be modified")
+}
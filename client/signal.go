@@ -4,15 +4,34 @@ import (
 	"context"
 
 	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
 
 	"github.com/zrepl/zrepl/cli"
+	statusclient "github.com/zrepl/zrepl/client/status/client"
 	"github.com/zrepl/zrepl/config"
 	"github.com/zrepl/zrepl/daemon"
 )
 
+var signalArgs struct {
+	filesystems []string
+}
+
 var SignalCmd = &cli.Subcommand{
-	Use:   "signal [wakeup|reset] JOB",
-	Short: "wake up a job from wait state or abort its current invocation",
+	Use:   "signal [wakeup [--filesystem PATTERN]...|reset|abort] JOB",
+	Short: "wake up a job from wait state, reset it, or abort its current invocation",
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.StringArrayVar(&signalArgs.filesystems, "filesystem", nil, "wakeup: only replicate the filesystems matching PATTERN, e.g. `pool/data` or `pool/data<` (may be specified multiple times, default: all filesystems of the job)")
+	},
+	CompleteArgs: func(conf *config.Config, args []string) []string {
+		switch len(args) {
+		case 0:
+			return []string{"wakeup", "reset", "abort"}
+		case 1:
+			return statusclient.CompleteJobNames(conf, args)
+		default:
+			return nil
+		}
+	},
 	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
 		return runSignalCmd(subcommand.Config(), args)
 	},
@@ -20,21 +39,26 @@ var SignalCmd = &cli.Subcommand{
 
 func runSignalCmd(config *config.Config, args []string) error {
 	if len(args) != 2 {
-		return errors.Errorf("Expected 2 arguments: [wakeup|reset] JOB")
+		return errors.Errorf("Expected 2 arguments: [wakeup|reset|abort] JOB")
+	}
+	if len(signalArgs.filesystems) > 0 && args[0] != "wakeup" {
+		return errors.Errorf("--filesystem is only valid for wakeup")
 	}
 
-	httpc, err := controlHttpClient(config.Global.Control.SockPath)
+	httpc, err := controlHttpClient(config.Global.Control)
 	if err != nil {
 		return err
 	}
 
 	err = jsonRequestResponse(httpc, daemon.ControlJobEndpointSignal,
 		struct {
-			Name string
-			Op   string
+			Name        string
+			Op          string
+			Filesystems []string
 		}{
-			Name: args[1],
-			Op:   args[0],
+			Name:        args[1],
+			Op:          args[0],
+			Filesystems: signalArgs.filesystems,
 		},
 		struct{}{},
 	)
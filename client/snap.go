@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	statusclient "github.com/zrepl/zrepl/client/status/client"
+	"github.com/zrepl/zrepl/daemon"
+	"github.com/zrepl/zrepl/daemon/snapper"
+)
+
+var snapArgs struct {
+	filesystems []string
+	prefix      string
+	suffix      string
+}
+
+var SnapCmd = &cli.Subcommand{
+	Use:          "snap [--filesystem FS]... [--prefix PREFIX] [--suffix SUFFIX] JOB",
+	CompleteArgs: cli.CompleteNthArg(0, statusclient.CompleteJobNames),
+	Short:        "take a snapshot of a job's filesystems now, running the job's snapshot hooks",
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.StringArrayVar(&snapArgs.filesystems, "filesystem", nil, "only snapshot this filesystem (may be specified multiple times, default: all filesystems of the job)")
+		f.StringVar(&snapArgs.prefix, "prefix", "", "snapshot name prefix (default: the job's prefix, required for manual snapshotting)")
+		f.StringVar(&snapArgs.suffix, "suffix", "", "use this suffix instead of a timestamp, e.g. `pre-upgrade`")
+	},
+	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+		if len(args) != 1 {
+			return errors.Errorf("Expected 1 argument: JOB")
+		}
+
+		httpc, err := controlHttpClient(subcommand.Config().Global.Control)
+		if err != nil {
+			return err
+		}
+
+		var res []*snapper.ReportFilesystem
+		err = jsonRequestResponse(httpc, daemon.ControlJobEndpointSnap,
+			daemon.SnapRequest{
+				Name: args[0],
+				OnDemandRequest: snapper.OnDemandRequest{
+					Filesystems: snapArgs.filesystems,
+					Prefix:      snapArgs.prefix,
+					Suffix:      snapArgs.suffix,
+				},
+			},
+			&res,
+		)
+		if err != nil {
+			return err
+		}
+
+		failed := 0
+		for _, fs := range res {
+			switch fs.State {
+			case snapper.SnapDone:
+				fmt.Printf("%s@%s\n", fs.Path, fs.SnapName)
+			default:
+				failed++
+				fmt.Printf("%s@%s: %s\n", fs.Path, fs.SnapName, fs.State)
+				if fs.HooksHadError {
+					fmt.Println(fs.Hooks)
+				}
+			}
+		}
+		if failed > 0 {
+			return errors.Errorf("snapshotting failed for %d of %d filesystems, see daemon log for details", failed, len(res))
+		}
+		return nil
+	},
+}
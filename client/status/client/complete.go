@@ -0,0 +1,36 @@
+package client
+
+import (
+	"time"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon"
+)
+
+// how long shell completion waits for the daemon
+const completeJobNamesTimeout = 2 * time.Second
+
+// CompleteJobNames is a cli.CompletionFunc that returns the names of the daemon's jobs.
+// If the daemon cannot be reached, it returns the names of the jobs in conf.
+func CompleteJobNames(conf *config.Config, args []string) []string {
+	if conf == nil {
+		return nil
+	}
+	httpc, err := HTTPClientFromConfig(conf.Global.Control)
+	if err != nil {
+		return cli.ConfigJobNames(conf, args)
+	}
+	httpc.Timeout = completeJobNamesTimeout
+	s, err := (&Client{httpc}).Status()
+	if err != nil {
+		return cli.ConfigJobNames(conf, args)
+	}
+	names := make([]string, 0, len(s.Jobs))
+	for name := range s.Jobs {
+		if !daemon.IsInternalJobName(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/secret"
+	"github.com/zrepl/zrepl/tlsconf"
+)
+
+// NewFromConfig connects to the daemon's control socket,
+// or to the remote daemon of conf.Connect if it is configured.
+func NewFromConfig(conf *config.GlobalControl) (*Client, error) {
+	httpc, err := HTTPClientFromConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{httpc}, nil
+}
+
+// HTTPClientFromConfig returns a client for the control endpoints, see NewFromConfig.
+func HTTPClientFromConfig(conf *config.GlobalControl) (*http.Client, error) {
+	if conf.Connect == nil {
+		return makeControlHttpClient(func(_ context.Context) (net.Conn, error) { return net.Dial("unix", conf.SockPath) })
+	}
+	c := conf.Connect
+
+	var tlsConf *tls.Config
+	if c.Ca != "" {
+		ca, err := tlsconf.ParseCAFile(c.Ca)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot parse ca file")
+		}
+		serverName := c.ServerCN
+		if serverName == "" {
+			serverName, _, _ = net.SplitHostPort(c.Address)
+		}
+		tlsConf = &tls.Config{RootCAs: ca, ServerName: serverName, MinVersion: tls.VersionTLS12}
+		if (c.Cert == "") != c.Key.IsZero() {
+			return nil, errors.New("cert and key must be specified together")
+		}
+		if c.Cert != "" {
+			cert, err := secret.LoadX509KeyPair(c.Cert, c.Key)
+			if err != nil {
+				return nil, errors.Wrap(err, "cannot load client certificate")
+			}
+			tlsConf.Certificates = []tls.Certificate{cert}
+		}
+	} else if c.Cert != "" || !c.Key.IsZero() || c.ServerCN != "" {
+		return nil, errors.New("cert, key and server_cn require ca")
+	}
+
+	dialer := net.Dialer{Timeout: c.DialTimeout}
+	httpc, err := makeControlHttpClient(func(ctx context.Context) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, "tcp", c.Address)
+		if err != nil || tlsConf == nil {
+			return conn, err
+		}
+		return tls.Client(conn, tlsConf), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.TokenFile.IsZero() {
+		token, err := secret.Load(c.TokenFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot load token")
+		}
+		httpc.Transport = bearerTokenTransport{
+			token: strings.TrimSpace(string(token)),
+			next:  httpc.Transport,
+		}
+	}
+	return httpc, nil
+}
+
+type bearerTokenTransport struct {
+	token string
+	next  http.RoundTripper
+}
+
+func (t bearerTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrip must not modify the request
+	r := *req
+	r.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	r.Header.Set("Authorization", "Bearer "+t.token)
+	return t.next.RoundTrip(&r)
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon"
+)
+
+func TestHTTPClientFromConfigToken(t *testing.T) {
+	var auth string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer s.Close()
+
+	dir, err := ioutil.TempDir("", "zrepl-control-connect")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("s3cret\n"), 0600))
+
+	httpc, err := HTTPClientFromConfig(&config.GlobalControl{Connect: &config.GlobalControlConnect{
+		Address:   strings.TrimPrefix(s.URL, "http://"),
+		TokenFile: config.Secret{File: tokenFile},
+	}})
+	require.NoError(t, err)
+	var res struct{}
+	require.NoError(t, jsonRequestResponse(httpc, daemon.ControlJobEndpointStatus, struct{}{}, &res))
+	assert.Equal(t, "Bearer s3cret", auth)
+
+	_, err = HTTPClientFromConfig(&config.GlobalControl{Connect: &config.GlobalControlConnect{
+		Address: "localhost:9811",
+		Cert:    "cert.pem",
+	}})
+	assert.Error(t, err, "cert requires ca")
+}
@@ -0,0 +1,182 @@
+package status
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/client/status/client"
+	"github.com/zrepl/zrepl/daemon"
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/pruner"
+)
+
+// exit codes of `zrepl monitor`, as defined by the Nagios plugin API
+const (
+	MonitorExitOK       = 0
+	MonitorExitWarning  = 1
+	MonitorExitCritical = 2
+	MonitorExitUnknown  = 3
+)
+
+var monitorStateNames = map[int]string{
+	MonitorExitOK:       "OK",
+	MonitorExitWarning:  "WARNING",
+	MonitorExitCritical: "CRITICAL",
+	MonitorExitUnknown:  "UNKNOWN",
+}
+
+var monitorFlags struct {
+	job              string
+	warnLag, critLag time.Duration
+}
+
+var MonitorCmd = &cli.Subcommand{
+	Use:           "monitor --job JOB [--warn-lag DURATION] [--crit-lag DURATION]",
+	Short:         "check the replication health of a push or pull job, for Nagios, Icinga and compatible monitoring systems",
+	CompleteFlags: map[string]cli.CompletionFunc{"job": client.CompleteJobNames},
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.StringVar(&monitorFlags.job, "job", "", "the push or pull job to check (required)")
+		f.DurationVar(&monitorFlags.warnLag, "warn-lag", 0, "WARNING if the last successful replication is older (e.g. 2h, 0 disables the threshold)")
+		f.DurationVar(&monitorFlags.critLag, "crit-lag", 0, "CRITICAL if the last successful replication is older (e.g. 6h, 0 disables the threshold)")
+	},
+	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+		var s daemon.Status
+		err := func() error {
+			if len(args) != 0 {
+				return errors.New("monitor does not take arguments, use --job JOB")
+			}
+			if monitorFlags.job == "" {
+				return errors.New("--job is required")
+			}
+			if monitorFlags.warnLag < 0 || monitorFlags.critLag < 0 {
+				return errors.New("--warn-lag and --crit-lag must not be negative")
+			}
+			if monitorFlags.warnLag > 0 && monitorFlags.critLag > 0 && monitorFlags.warnLag > monitorFlags.critLag {
+				return errors.New("--warn-lag must not exceed --crit-lag")
+			}
+			c, err := client.NewFromConfig(subcommand.Config().Global.Control)
+			if err != nil {
+				return errors.Wrap(err, "connect to daemon")
+			}
+			s, err = c.Status()
+			return err
+		}()
+		var code int
+		if err != nil {
+			code = printMonitorUnknown(os.Stdout, err)
+		} else {
+			code = printMonitor(os.Stdout, s, monitorFlags.job, time.Now(), monitorFlags.warnLag, monitorFlags.critLag)
+		}
+		if code == MonitorExitOK {
+			return nil
+		}
+		// the output is on stdout, where monitoring systems expect it
+		return &cli.ExitCodeError{Code: code}
+	},
+}
+
+func printMonitorUnknown(w io.Writer, err error) int {
+	fmt.Fprintf(w, "ZREPL UNKNOWN - %s\n", strings.Replace(err.Error(), "\n", " ", -1))
+	return MonitorExitUnknown
+}
+
+// printMonitor writes the plugin output for job jobName in s and returns the exit code:
+// a status line followed by the performance data
+// (lag of the last successful replication, snapshot counts after the latest pruning, bytes not yet replicated).
+// Errors of the job are WARNING, the lag thresholds can raise it to CRITICAL.
+// Without a successful replication since the daemon started, the lag is unknown, and so is the state unless there are errors.
+// warnLag and critLag of 0 disable the respective threshold.
+func printMonitor(w io.Writer, s daemon.Status, jobName string, now time.Time, warnLag, critLag time.Duration) int {
+	st, ok := s.Jobs[jobName]
+	if !ok || daemon.IsInternalJobName(jobName) || st == nil {
+		return printMonitorUnknown(w, errors.Errorf("job %q not found", jobName))
+	}
+	active, ok := st.JobSpecific.(*job.ActiveSideStatus)
+	if !ok || active == nil {
+		return printMonitorUnknown(w, errors.Errorf("job %q is a %s job, only push and pull jobs can be monitored", jobName, st.Type))
+	}
+	j := jsonJob(jobName, st, now)
+
+	code := MonitorExitOK
+	raise := func(c int) {
+		if c > code {
+			code = c
+		}
+	}
+	var msgs []string
+	_, reasons := evaluateHealth(j, now, 0)
+	if len(reasons) > 0 {
+		raise(MonitorExitWarning)
+		msgs = append(msgs, reasons...)
+	}
+
+	lag := "U" // unknown
+	switch {
+	case j.Disabled:
+		msgs = append([]string{"job is disabled"}, msgs...)
+	case j.Maintenance:
+		msgs = append([]string{"job is in maintenance"}, msgs...)
+	case j.LastSuccessfulReplication == nil:
+		if len(reasons) == 0 {
+			raise(MonitorExitUnknown) // the lag is unknown, but nothing failed either
+		}
+		msgs = append([]string{"no successful replication since the daemon started"}, msgs...)
+	}
+	if last := j.LastSuccessfulReplication; last != nil {
+		d := now.Sub(*last)
+		lag = fmt.Sprintf("%ds", int64(d.Seconds()))
+		if !j.Disabled && !j.Maintenance {
+			switch {
+			case critLag > 0 && d > critLag:
+				raise(MonitorExitCritical)
+			case warnLag > 0 && d > warnLag:
+				raise(MonitorExitWarning)
+			}
+		}
+		msgs = append([]string{fmt.Sprintf("last successful replication %s ago", d.Truncate(time.Second))}, msgs...)
+	}
+
+	threshold := func(d time.Duration) string {
+		if d == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%d", int64(d.Seconds()))
+	}
+	perfdata := []string{fmt.Sprintf("lag=%s;%s;%s;0", lag, threshold(warnLag), threshold(critLag))}
+	if n, ok := monitorSnapshotCount(active.PruningSender); ok {
+		perfdata = append(perfdata, fmt.Sprintf("sender_snapshots=%d;;;0", n))
+	}
+	if n, ok := monitorSnapshotCount(active.PruningReceiver); ok {
+		perfdata = append(perfdata, fmt.Sprintf("receiver_snapshots=%d;;;0", n))
+	}
+	if r := j.Replication; r != nil {
+		var behind uint64
+		if r.BytesExpected > r.BytesReplicated {
+			behind = r.BytesExpected - r.BytesReplicated
+		}
+		perfdata = append(perfdata, fmt.Sprintf("bytes_behind=%dB;;;0", behind))
+	}
+
+	fmt.Fprintf(w, "ZREPL %s - %s: %s | %s\n", monitorStateNames[code], jobName,
+		strings.Replace(strings.Join(msgs, "; "), "|", "/", -1), strings.Join(perfdata, " "))
+	return code
+}
+
+// monitorSnapshotCount returns the number of snapshots that the latest pruning kept, ok is false if it has not completed.
+func monitorSnapshotCount(r *pruner.Report) (n int, ok bool) {
+	if r == nil || len(r.Completed) == 0 || len(r.Pending) > 0 {
+		return 0, false
+	}
+	for _, fs := range r.Completed {
+		n += len(fs.SnapshotList) - fs.Destroyed
+	}
+	return n, true
+}
@@ -0,0 +1,156 @@
+package status
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zrepl/zrepl/daemon"
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/replication/report"
+)
+
+func TestPrintMonitor(t *testing.T) {
+	now := time.Date(2022, 7, 23, 14, 0, 0, 0, time.UTC)
+
+	pruning := &pruner.Report{
+		State: "Done",
+		Completed: []pruner.FSReport{
+			{Filesystem: "zroot/a", SnapshotList: make([]pruner.SnapshotReport, 5), DestroyList: make([]pruner.SnapshotReport, 2), Destroyed: 2},
+			{Filesystem: "zroot/b", SnapshotList: make([]pruner.SnapshotReport, 3)},
+		},
+	}
+	done := &report.Report{
+		StartAt: now.Add(-3 * time.Hour),
+		Attempts: []*report.AttemptReport{{
+			State: report.AttemptDone,
+			Filesystems: []*report.FilesystemReport{{
+				Info:  &report.FilesystemInfo{Name: "zroot/a"},
+				State: report.FilesystemDone,
+			}},
+		}},
+	}
+	failed := &report.Report{
+		StartAt: now.Add(-10 * time.Minute),
+		Attempts: []*report.AttemptReport{{
+			State: report.AttemptFanOutError,
+			Filesystems: []*report.FilesystemReport{{
+				Info:      &report.FilesystemInfo{Name: "zroot/a"},
+				State:     report.FilesystemSteppingErrored,
+				StepError: report.NewTimedError("broken pipe", now),
+			}},
+		}},
+	}
+
+	s := daemon.Status{
+		Jobs: map[string]*job.Status{
+			"_control": {Type: "control"},
+			"prod": {
+				Type: job.TypePush,
+				JobSpecific: &job.ActiveSideStatus{
+					Replication:               done,
+					PruningSender:             pruning,
+					LastSuccessfulReplication: now.Add(-3 * time.Hour),
+				},
+			},
+			"failing": {
+				Type: job.TypePush,
+				JobSpecific: &job.ActiveSideStatus{
+					Replication:               failed,
+					LastSuccessfulReplication: now.Add(-30 * time.Minute),
+				},
+			},
+			"failing_since_start": {
+				Type:        job.TypePull,
+				JobSpecific: &job.ActiveSideStatus{Replication: failed},
+			},
+			"fresh":    {Type: job.TypePull, JobSpecific: &job.ActiveSideStatus{}},
+			"disabled": {Type: job.TypePull, Disabled: true, JobSpecific: &job.ActiveSideStatus{LastSuccessfulReplication: now.Add(-48 * time.Hour)}},
+			"sink":     {Type: job.TypeSink, JobSpecific: &job.PassiveStatus{}},
+		},
+	}
+
+	tcs := []struct {
+		job              string
+		warnLag, critLag time.Duration
+		code             int
+		output           string
+	}{
+		{
+			job: "prod", warnLag: 4 * time.Hour, critLag: 8 * time.Hour,
+			code:   MonitorExitOK,
+			output: "ZREPL OK - prod: last successful replication 3h0m0s ago | lag=10800s;14400;28800;0 sender_snapshots=6;;;0 bytes_behind=0B;;;0\n",
+		},
+		{
+			job: "prod", warnLag: 2 * time.Hour, critLag: 8 * time.Hour,
+			code:   MonitorExitWarning,
+			output: "ZREPL WARNING - prod: last successful replication 3h0m0s ago | lag=10800s;7200;28800;0 sender_snapshots=6;;;0 bytes_behind=0B;;;0\n",
+		},
+		{
+			job: "prod", warnLag: time.Hour, critLag: 2 * time.Hour,
+			code:   MonitorExitCritical,
+			output: "ZREPL CRITICAL - prod: last successful replication 3h0m0s ago | lag=10800s;3600;7200;0 sender_snapshots=6;;;0 bytes_behind=0B;;;0\n",
+		},
+		{
+			job:    "prod",
+			code:   MonitorExitOK,
+			output: "ZREPL OK - prod: last successful replication 3h0m0s ago | lag=10800s;;;0 sender_snapshots=6;;;0 bytes_behind=0B;;;0\n",
+		},
+		{
+			job: "failing", warnLag: 2 * time.Hour,
+			code:   MonitorExitWarning,
+			output: "ZREPL WARNING - failing: last successful replication 30m0s ago; replication of \"zroot/a\": broken pipe | lag=1800s;7200;;0 bytes_behind=0B;;;0\n",
+		},
+		{
+			job:    "failing_since_start",
+			code:   MonitorExitWarning,
+			output: "ZREPL WARNING - failing_since_start: no successful replication since the daemon started; replication of \"zroot/a\": broken pipe | lag=U;;;0 bytes_behind=0B;;;0\n",
+		},
+		{
+			job:    "fresh",
+			code:   MonitorExitUnknown,
+			output: "ZREPL UNKNOWN - fresh: no successful replication since the daemon started | lag=U;;;0\n",
+		},
+		{
+			job: "disabled", critLag: time.Hour,
+			code:   MonitorExitOK,
+			output: "ZREPL OK - disabled: last successful replication 48h0m0s ago; job is disabled | lag=172800s;;3600;0\n",
+		},
+		{
+			job:    "sink",
+			code:   MonitorExitUnknown,
+			output: "ZREPL UNKNOWN - job \"sink\" is a sink job, only push and pull jobs can be monitored\n",
+		},
+		{
+			job:    "nonexistent",
+			code:   MonitorExitUnknown,
+			output: "ZREPL UNKNOWN - job \"nonexistent\" not found\n",
+		},
+		{
+			job:    "_control",
+			code:   MonitorExitUnknown,
+			output: "ZREPL UNKNOWN - job \"_control\" not found\n",
+		},
+	}
+	for _, tc := range tcs {
+		var buf bytes.Buffer
+		code := printMonitor(&buf, s, tc.job, now, tc.warnLag, tc.critLag)
+		assert.Equal(t, tc.code, code, "%s warn=%s crit=%s", tc.job, tc.warnLag, tc.critLag)
+		assert.Equal(t, tc.output, buf.String(), "%s warn=%s crit=%s", tc.job, tc.warnLag, tc.critLag)
+	}
+}
+
+func TestMonitorSnapshotCount(t *testing.T) {
+	_, ok := monitorSnapshotCount(nil)
+	assert.False(t, ok)
+	_, ok = monitorSnapshotCount(&pruner.Report{})
+	assert.False(t, ok)
+	_, ok = monitorSnapshotCount(&pruner.Report{
+		Pending:   []pruner.FSReport{{Filesystem: "zroot/b", SnapshotList: make([]pruner.SnapshotReport, 1)}},
+		Completed: []pruner.FSReport{{Filesystem: "zroot/a", SnapshotList: make([]pruner.SnapshotReport, 1)}},
+	})
+	assert.False(t, ok, "pruning in progress")
+}
@@ -24,9 +24,12 @@ type Client interface {
 }
 
 type statusFlags struct {
-	Mode  choices.Choices
-	Job   string
-	Delay time.Duration
+	Mode   choices.Choices
+	Job    string
+	Delay  time.Duration
+	Follow bool
+	// only used by `zrepl status JOB`, 0 disables the check
+	MaxReplicationLag time.Duration
 }
 
 var statusv2Flags statusFlags
@@ -38,23 +41,31 @@ const (
 	StatusV2ModeDump
 	StatusV2ModeRaw
 	StatusV2ModeLegacy
+	StatusV2ModeJSON
+	StatusV2ModeReport
 )
 
 var Subcommand = &cli.Subcommand{
-	Use:   "status",
-	Short: "retrieve & display daemon status information",
+	Use:           "status [JOB]",
+	CompleteArgs:  cli.CompleteNthArg(0, client.CompleteJobNames),
+	CompleteFlags: map[string]cli.CompletionFunc{"job": client.CompleteJobNames},
+	Short:         "retrieve & display daemon status information, or the status and health of JOB",
 	SetupFlags: func(f *pflag.FlagSet) {
 		statusv2Flags.Mode.Init(
 			"interactive", StatusV2ModeInteractive,
 			"dump", StatusV2ModeDump,
 			"raw", StatusV2ModeRaw,
 			"legacy", StatusV2ModeLegacy,
+			"json", StatusV2ModeJSON,
+			"report", StatusV2ModeReport,
 		)
 		statusv2Flags.Mode.SetTypeString("mode")
 		statusv2Flags.Mode.SetDefaultValue(StatusV2ModeInteractive)
 		f.Var(&statusv2Flags.Mode, "mode", statusv2Flags.Mode.Usage())
-		f.StringVar(&statusv2Flags.Job, "job", "", "only show specified job (works in \"dump\" and \"interactive\" mode)")
+		f.StringVar(&statusv2Flags.Job, "job", "", "only show specified job (works in \"dump\", \"json\", \"report\" and \"interactive\" mode)")
 		f.DurationVarP(&statusv2Flags.Delay, "delay", "d", 1*time.Second, "use -d 3s for 3 seconds delay (minimum delay is 1s)")
+		f.BoolVarP(&statusv2Flags.Follow, "follow", "f", false, "print a line whenever the status of a job changes, every --delay (works in \"dump\" and \"json\" mode)")
+		f.DurationVar(&statusv2Flags.MaxReplicationLag, "max-replication-lag", 0, "with JOB, exit as stalled if the last successful replication is older (e.g. 6h, 0 disables the check), also applies to \"report\" mode")
 	},
 	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
 		return runStatusV2Command(ctx, subcommand.Config(), args)
@@ -63,23 +74,54 @@ var Subcommand = &cli.Subcommand{
 
 func runStatusV2Command(ctx context.Context, config *config.Config, args []string) error {
 
-	c, err := client.New("unix", config.Global.Control.SockPath)
+	c, err := client.NewFromConfig(config.Global.Control)
 	if err != nil {
-		return errors.Wrapf(err, "connect to daemon socket at %q", config.Global.Control.SockPath)
+		return errors.Wrap(err, "connect to daemon")
 	}
 
 	mode := statusv2Flags.Mode.Value().(statusv2Mode)
 
-	if !isatty.IsTerminal(os.Stdout.Fd()) && mode != StatusV2ModeDump && mode != StatusV2ModeRaw {
+	if len(args) > 1 {
+		return errors.New("expected at most 1 argument: JOB")
+	}
+	if len(args) == 1 {
+		if statusv2Flags.Follow {
+			return errors.New("--follow cannot be used with JOB")
+		}
+		if statusv2Flags.MaxReplicationLag < 0 {
+			return errors.New("--max-replication-lag must not be negative")
+		}
+		switch mode {
+		case StatusV2ModeInteractive, StatusV2ModeDump:
+			return jobStatus(c, args[0], false, statusv2Flags.MaxReplicationLag)
+		case StatusV2ModeJSON:
+			return jobStatus(c, args[0], true, statusv2Flags.MaxReplicationLag)
+		default:
+			return errors.New("JOB only works with --mode dump or --mode json")
+		}
+	}
+
+	if !isatty.IsTerminal(os.Stdout.Fd()) && mode != StatusV2ModeDump && mode != StatusV2ModeRaw && mode != StatusV2ModeJSON && mode != StatusV2ModeReport {
 		dumpmode, err := statusv2Flags.Mode.InputForChoice(StatusV2ModeDump)
 		if err != nil {
 			panic(err)
 		}
-		rawmode, err := statusv2Flags.Mode.InputForChoice(StatusV2ModeRaw)
+		jsonmode, err := statusv2Flags.Mode.InputForChoice(StatusV2ModeJSON)
 		if err != nil {
 			panic(err)
 		}
-		return errors.Errorf("error: stdout is not a tty, please use --mode %s or --mode %s", dumpmode, rawmode)
+		return errors.Errorf("error: stdout is not a tty, please use --mode %s or --mode %s", dumpmode, jsonmode)
+	}
+
+	if statusv2Flags.Follow {
+		switch mode {
+		case StatusV2ModeDump:
+			return follow(c, statusv2Flags, false, os.Stdout)
+		case StatusV2ModeJSON:
+			return follow(c, statusv2Flags, true, os.Stdout)
+		default:
+			return errors.New("--follow only works with --mode dump or --mode json")
+		}
 	}
 
 	switch mode {
@@ -89,6 +131,10 @@ func runStatusV2Command(ctx context.Context, config *config.Config, args []strin
 		return dump(c, statusv2Flags.Job)
 	case StatusV2ModeRaw:
 		return raw(c)
+	case StatusV2ModeJSON:
+		return jsonStatus(c, statusv2Flags.Job)
+	case StatusV2ModeReport:
+		return plainTextReport(c, statusv2Flags)
 	case StatusV2ModeLegacy:
 		return legacy(c, statusv2Flags)
 	default:
@@ -10,6 +10,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/zrepl/zrepl/client/status/viewmodel"
+	"github.com/zrepl/zrepl/daemon"
 )
 
 func dump(c Client, job string) error {
@@ -17,7 +18,10 @@ func dump(c Client, job string) error {
 	if err != nil {
 		return err
 	}
+	return printDump(s, job)
+}
 
+func printDump(s daemon.Status, job string) error {
 	if job != "" {
 		if _, ok := s.Jobs[job]; !ok {
 			return errors.Errorf("job %q not found", job)
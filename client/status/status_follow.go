@@ -0,0 +1,227 @@
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/client/status/viewmodel"
+)
+
+// JSONFollowEvent is a line of the output of `zrepl status --mode json --follow`.
+// It has the same stability guarantees as JSONStatus.
+type JSONFollowEvent struct {
+	Version int       `json:"version"`
+	Time    time.Time `json:"time"`
+	// nil if Error is set
+	Job *JSONJob `json:"job,omitempty"`
+	// the job no longer exists
+	Removed bool `json:"removed,omitempty"`
+	// the status could not be retrieved from the daemon
+	Error string `json:"error,omitempty"`
+}
+
+// follow polls the daemon every flags.Delay and prints a line for each job whose status changed,
+// a compact summary or, if asJSON, a JSONFollowEvent.
+// It only returns if writing to out fails.
+func follow(c Client, flags statusFlags, asJSON bool, out io.Writer) error {
+	if flags.Delay < time.Second {
+		return errors.New("minimum delay is 1s")
+	}
+	f := &follower{out: out, asJSON: asJSON, job: flags.Job, last: make(map[string]string)}
+	for {
+		if err := f.poll(c, time.Now()); err != nil {
+			return err
+		}
+		time.Sleep(flags.Delay)
+	}
+}
+
+type follower struct {
+	out    io.Writer
+	asJSON bool
+	job    string // all jobs if empty
+	// the latest line printed per job name, "" is used for status retrieval errors
+	last map[string]string
+}
+
+func (f *follower) poll(c Client, now time.Time) error {
+	s, err := c.Status()
+	if err != nil {
+		return f.print("", now, nil, false, err.Error())
+	}
+	delete(f.last, "")
+	st := NewJSONStatus(s, now)
+	seen := make(map[string]bool, len(st.Jobs))
+	for _, j := range st.Jobs {
+		if f.job != "" && j.Name != f.job {
+			continue
+		}
+		seen[j.Name] = true
+		if err := f.print(j.Name, now, j, false, ""); err != nil {
+			return err
+		}
+	}
+	for name := range f.last {
+		if name != "" && !seen[name] {
+			if err := f.print(name, now, nil, true, ""); err != nil {
+				return err
+			}
+			delete(f.last, name)
+		}
+	}
+	return nil
+}
+
+// print prints the event if it differs from the latest one for the job
+func (f *follower) print(name string, now time.Time, j *JSONJob, removed bool, errMsg string) error {
+	var key, line string
+	if f.asJSON {
+		b, err := json.Marshal(&JSONFollowEvent{Version: JSONStatusVersion, Job: j, Removed: removed, Error: errMsg})
+		if err != nil {
+			return err
+		}
+		key = string(b)
+		ev := &JSONFollowEvent{Version: JSONStatusVersion, Time: now, Job: j, Removed: removed, Error: errMsg}
+		if b, err = json.Marshal(ev); err != nil {
+			return err
+		}
+		line = string(b)
+	} else {
+		switch {
+		case errMsg != "":
+			key = fmt.Sprintf("ERROR cannot get status: %s", errMsg)
+		case removed:
+			key = fmt.Sprintf("%s: job no longer exists", name)
+		default:
+			key = followSummary(j)
+		}
+		line = fmt.Sprintf("%s %s", now.Format(time.RFC3339), key)
+	}
+	if f.last[name] == key {
+		return nil
+	}
+	f.last[name] = key
+	_, err := fmt.Fprintln(f.out, line)
+	return err
+}
+
+// followSummary returns a single line that summarizes the status of j.
+func followSummary(j *JSONJob) string {
+	var parts []string
+	switch {
+	case j.Disabled:
+		parts = append(parts, "disabled")
+	case j.Maintenance && j.MaintenanceUntil != nil:
+		parts = append(parts, fmt.Sprintf("maintenance until %s", j.MaintenanceUntil.Format(time.RFC3339)))
+	case j.Maintenance:
+		parts = append(parts, "maintenance")
+	}
+	if j.CircuitBreakerSuspendedUntil != nil {
+		parts = append(parts, fmt.Sprintf("suspended by circuit breaker until %s", j.CircuitBreakerSuspendedUntil.Format(time.RFC3339)))
+	}
+	if j.WaitBlackoutUntil != nil {
+		parts = append(parts, fmt.Sprintf("waiting for blackout window to end at %s", j.WaitBlackoutUntil.Format(time.RFC3339)))
+	}
+	if j.AbortedAt != nil {
+		parts = append(parts, fmt.Sprintf("aborted at %s", j.AbortedAt.Format(time.RFC3339)))
+	}
+	if j.Replication != nil || j.Type == "push" || j.Type == "pull" {
+		parts = append(parts, "replication "+followReplicationSummary(j.Replication))
+	}
+	for _, d := range j.Destinations {
+		parts = append(parts, fmt.Sprintf("destination %q replication %s", d.Name, followReplicationSummary(d.Replication)))
+	}
+	for _, s := range j.Sources {
+		parts = append(parts, fmt.Sprintf("source %q replication %s", s.Name, followReplicationSummary(s.Replication)))
+	}
+	for _, p := range []struct {
+		side string
+		r    *JSONPruning
+	}{{"pruning", j.Pruning}, {"pruning sender", j.PruningSender}, {"pruning receiver", j.PruningReceiver}} {
+		if p.r != nil {
+			parts = append(parts, p.side+" "+followPruningSummary(p.r))
+		}
+	}
+	if s := j.Snapshotting; s != nil && s.Type != "manual" {
+		parts = append(parts, "snapshotting "+followSnapshottingSummary(s))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "idle")
+	}
+	return fmt.Sprintf("%s (%s): %s", j.Name, j.Type, strings.Join(parts, "; "))
+}
+
+func followReplicationSummary(r *JSONReplication) string {
+	if r == nil {
+		return "not started"
+	}
+	if r.Attempts == 0 {
+		return "starting"
+	}
+	done, failed := 0, 0
+	for _, fs := range r.Filesystems {
+		switch {
+		case fs.Error != "":
+			failed++
+		case fs.State == "done":
+			done++
+		}
+	}
+	s := fmt.Sprintf("%s (attempt %d) %d/%d filesystems done", r.State, r.Attempts, done, len(r.Filesystems))
+	if failed > 0 {
+		s += fmt.Sprintf(", %d failed", failed)
+	}
+	if r.BytesExpected > 0 || r.BytesReplicated > 0 {
+		s += fmt.Sprintf(", %s/%s", viewmodel.ByteCountBinaryUint(r.BytesReplicated), viewmodel.ByteCountBinaryUint(r.BytesExpected))
+	}
+	if r.Error != "" {
+		s += fmt.Sprintf(", error: %s", r.Error)
+	}
+	return s
+}
+
+func followPruningSummary(r *JSONPruning) string {
+	completed, failed := 0, 0
+	for _, fs := range r.Filesystems {
+		if fs.State == "completed" {
+			completed++
+		}
+		if fs.Error != "" {
+			failed++
+		}
+	}
+	s := fmt.Sprintf("%s %d/%d filesystems", r.State, completed, len(r.Filesystems))
+	if failed > 0 {
+		s += fmt.Sprintf(", %d failed", failed)
+	}
+	if r.Error != "" {
+		s += fmt.Sprintf(", error: %s", r.Error)
+	}
+	return s
+}
+
+func followSnapshottingSummary(r *JSONSnapshotting) string {
+	if len(r.Schedules) > 0 {
+		states := make([]string, 0, len(r.Schedules))
+		for _, sr := range r.Schedules {
+			states = append(states, followSnapshottingSummary(sr))
+		}
+		return strings.Join(states, ", ")
+	}
+	s := r.State
+	if s == "" {
+		s = r.Type
+	}
+	if r.SleepUntil != nil {
+		s += fmt.Sprintf(" until %s", r.SleepUntil.Format(time.RFC3339))
+	}
+	if len(r.Errors) > 0 {
+		s += fmt.Sprintf(", error: %s", strings.Join(r.Errors, ", "))
+	}
+	return s
+}
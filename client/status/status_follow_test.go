@@ -0,0 +1,67 @@
+package status
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon"
+	"github.com/zrepl/zrepl/daemon/job"
+)
+
+type followTestClient struct {
+	Client
+	status daemon.Status
+	err    error
+}
+
+func (c *followTestClient) Status() (daemon.Status, error) { return c.status, c.err }
+
+func TestFollower(t *testing.T) {
+	now := time.Date(2022, 7, 23, 14, 0, 0, 0, time.UTC)
+	c := &followTestClient{status: daemon.Status{Jobs: map[string]*job.Status{
+		"a": {Type: job.TypePull, JobSpecific: &job.ActiveSideStatus{}},
+		"b": {Type: job.TypeSnap, JobSpecific: &job.SnapJobStatus{}},
+	}}}
+	var out bytes.Buffer
+	f := &follower{out: &out, last: make(map[string]string)}
+	lines := func() []string {
+		defer out.Reset()
+		return strings.Split(strings.TrimSpace(out.String()), "\n")
+	}
+
+	require.NoError(t, f.poll(c, now))
+	assert.Equal(t, []string{
+		"2022-07-23T14:00:00Z a (pull): replication not started",
+		"2022-07-23T14:00:00Z b (snap): idle",
+	}, lines())
+
+	// unchanged
+	require.NoError(t, f.poll(c, now.Add(time.Second)))
+	assert.Empty(t, out.String())
+
+	c.status.Jobs["b"].Disabled = true
+	require.NoError(t, f.poll(c, now.Add(2*time.Second)))
+	assert.Equal(t, []string{"2022-07-23T14:00:02Z b (snap): disabled"}, lines())
+
+	c.err = errors.New("connection refused")
+	require.NoError(t, f.poll(c, now.Add(3*time.Second)))
+	require.NoError(t, f.poll(c, now.Add(4*time.Second)))
+	assert.Equal(t, []string{"2022-07-23T14:00:03Z ERROR cannot get status: connection refused"}, lines())
+
+	c.err = nil
+	delete(c.status.Jobs, "a")
+	require.NoError(t, f.poll(c, now.Add(5*time.Second)))
+	assert.Equal(t, []string{"2022-07-23T14:00:05Z a: job no longer exists"}, lines())
+
+	f = &follower{out: &out, asJSON: true, job: "b", last: make(map[string]string)}
+	require.NoError(t, f.poll(c, now))
+	assert.Equal(t, []string{`{"version":1,"time":"2022-07-23T14:00:00Z","job":{"name":"b","type":"snap","disabled":true,"maintenance":false}}`}, lines())
+	require.NoError(t, f.poll(c, now.Add(time.Second)))
+	assert.Empty(t, out.String())
+}
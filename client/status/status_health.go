@@ -0,0 +1,175 @@
+package status
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/daemon"
+)
+
+// exit codes of `zrepl status JOB`
+const (
+	StatusJobExitHealthy = 0
+	StatusJobExitFailed  = 1 // the job does not exist or the status could not be retrieved
+	StatusJobExitError   = 2 // the job reports errors
+	StatusJobExitStalled = 3 // replication lag exceeds --max-replication-lag
+)
+
+type jobHealthState int
+
+const (
+	jobHealthy jobHealthState = iota
+	jobStalled
+	jobError
+)
+
+// evaluateHealth determines whether j is healthy.
+// Errors include pools of pool_monitor jobs that are not ONLINE.
+// Errors take precedence over stalled replication.
+// Disabled jobs and jobs in maintenance are healthy because they are paused on purpose.
+// maxLag is the maximum age of the latest successful replication of push and pull jobs, 0 disables the check.
+// If no replication succeeded since the daemon started, the lag is unknown and not checked.
+func evaluateHealth(j *JSONJob, now time.Time, maxLag time.Duration) (state jobHealthState, reasons []string) {
+	if j.Disabled || j.Maintenance {
+		return jobHealthy, nil
+	}
+
+	addErr := func(format string, args ...interface{}) {
+		state = jobError
+		reasons = append(reasons, fmt.Sprintf(format, args...))
+	}
+	checkReplication := func(prefix string, r *JSONReplication) {
+		if r == nil {
+			return
+		}
+		if r.Error != "" {
+			addErr("%sreplication: %s", prefix, r.Error)
+		}
+		for _, fs := range r.Filesystems {
+			if fs.Error != "" {
+				addErr("%sreplication of %q: %s", prefix, fs.Name, fs.Error)
+			}
+		}
+	}
+	checkPruning := func(prefix string, p *JSONPruning) {
+		if p == nil {
+			return
+		}
+		if p.Error != "" {
+			addErr("%s: %s", prefix, p.Error)
+		}
+		for _, fs := range p.Filesystems {
+			if fs.Error != "" {
+				addErr("%s of %q: %s", prefix, fs.Name, fs.Error)
+			}
+		}
+	}
+	var checkSnapshotting func(s *JSONSnapshotting)
+	checkSnapshotting = func(s *JSONSnapshotting) {
+		if s == nil {
+			return
+		}
+		for _, e := range s.Errors {
+			addErr("snapshotting: %s", e)
+		}
+		for _, sched := range s.Schedules {
+			checkSnapshotting(sched)
+		}
+	}
+
+	checkReplication("", j.Replication)
+	checkPruning("pruning sender", j.PruningSender)
+	checkPruning("pruning receiver", j.PruningReceiver)
+	for _, d := range j.Destinations {
+		prefix := fmt.Sprintf("destination %q ", d.Name)
+		checkReplication(prefix, d.Replication)
+		checkPruning(prefix+"pruning receiver", d.PruningReceiver)
+	}
+	for _, s := range j.Sources {
+		prefix := fmt.Sprintf("source %q ", s.Name)
+		checkReplication(prefix, s.Replication)
+		checkPruning(prefix+"pruning sender", s.PruningSender)
+		checkPruning(prefix+"pruning receiver", s.PruningReceiver)
+	}
+	checkPruning("pruning", j.Pruning)
+	checkSnapshotting(j.Snapshotting)
+	if p := j.Pools; p != nil {
+		if p.Error != "" {
+			addErr("pools: %s", p.Error)
+		}
+		for _, pool := range p.Pools {
+			switch {
+			case pool.Error != "":
+				addErr("pool %q: %s", pool.Name, pool.Error)
+			case pool.Health != "ONLINE":
+				addErr("pool %q is %s", pool.Name, pool.Health)
+			}
+			if pool.Scan != nil && pool.Scan.Errors > 0 {
+				addErr("%s of pool %q found %d error(s)", pool.Scan.Function, pool.Name, pool.Scan.Errors)
+			}
+		}
+	}
+	if j.CircuitBreakerSuspendedUntil != nil {
+		addErr("suspended by circuit breaker until %s", j.CircuitBreakerSuspendedUntil.Format(time.RFC3339))
+	}
+	if j.AbortedAt != nil {
+		addErr("latest invocation was aborted at %s", j.AbortedAt.Format(time.RFC3339))
+	}
+
+	if maxLag > 0 && j.LastSuccessfulReplication != nil {
+		if lag := now.Sub(*j.LastSuccessfulReplication); lag > maxLag {
+			if state < jobStalled {
+				state = jobStalled
+			}
+			reasons = append(reasons, fmt.Sprintf("last successful replication was %s ago (at %s), exceeds %s",
+				lag.Truncate(time.Second), j.LastSuccessfulReplication.Format(time.RFC3339), maxLag))
+		}
+	}
+	return state, reasons
+}
+
+// jobStatus prints the status of job jobName and returns a *cli.ExitCodeError unless the job is healthy.
+func jobStatus(c Client, jobName string, asJSON bool, maxLag time.Duration) error {
+	failed := func(err error) error { return &cli.ExitCodeError{Code: StatusJobExitFailed, Err: err} }
+	if daemon.IsInternalJobName(jobName) {
+		return failed(errors.Errorf("job %q not found", jobName))
+	}
+	s, err := c.Status()
+	if err != nil {
+		return failed(err)
+	}
+	now := time.Now()
+	if asJSON {
+		err = printJSONStatus(s, jobName, now)
+	} else {
+		err = printDump(s, jobName)
+	}
+	if err != nil {
+		return failed(err)
+	}
+
+	var j *JSONJob
+	for _, cand := range NewJSONStatus(s, now).Jobs {
+		if cand.Name == jobName {
+			j = cand
+		}
+	}
+	if j == nil {
+		return failed(errors.Errorf("job %q has no status", jobName))
+	}
+	state, reasons := evaluateHealth(j, now, maxLag)
+	switch state {
+	case jobError:
+		return &cli.ExitCodeError{Code: StatusJobExitError,
+			Err: errors.Errorf("job %q is unhealthy:\n%s", jobName, strings.Join(reasons, "\n"))}
+	case jobStalled:
+		return &cli.ExitCodeError{Code: StatusJobExitStalled,
+			Err: errors.Errorf("job %q is stalled:\n%s", jobName, strings.Join(reasons, "\n"))}
+	default:
+		return nil
+	}
+}
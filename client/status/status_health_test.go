@@ -0,0 +1,161 @@
+package status
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/daemon"
+	"github.com/zrepl/zrepl/daemon/job"
+)
+
+func TestEvaluateHealth(t *testing.T) {
+	now := time.Date(2022, 7, 23, 14, 0, 0, 0, time.UTC)
+	lastSuccess := now.Add(-2 * time.Hour)
+
+	type tc struct {
+		name    string
+		job     JSONJob
+		maxLag  time.Duration
+		state   jobHealthState
+		reasons int
+	}
+	tcs := []tc{
+		{name: "idle", job: JSONJob{Type: "pull"}, state: jobHealthy},
+		{
+			name:  "lag_check_disabled",
+			job:   JSONJob{Type: "push", LastSuccessfulReplication: &lastSuccess},
+			state: jobHealthy,
+		},
+		{
+			name:   "lag_below_threshold",
+			job:    JSONJob{Type: "push", LastSuccessfulReplication: &lastSuccess},
+			maxLag: 3 * time.Hour,
+			state:  jobHealthy,
+		},
+		{
+			name:    "stalled",
+			job:     JSONJob{Type: "push", LastSuccessfulReplication: &lastSuccess},
+			maxLag:  time.Hour,
+			state:   jobStalled,
+			reasons: 1,
+		},
+		{
+			name:   "lag_unknown",
+			job:    JSONJob{Type: "push"},
+			maxLag: time.Hour,
+			state:  jobHealthy,
+		},
+		{
+			name: "replication_fs_error",
+			job: JSONJob{Type: "pull", Replication: &JSONReplication{Filesystems: []*JSONReplicationFilesystem{
+				{Name: "pool/a", Error: "connection reset"},
+				{Name: "pool/b"},
+			}}},
+			state:   jobError,
+			reasons: 1,
+		},
+		{
+			name: "error_takes_precedence",
+			job: JSONJob{
+				Type:                      "push",
+				LastSuccessfulReplication: &lastSuccess,
+				PruningSender:             &JSONPruning{State: "planning-error", Error: "cannot list snapshots"},
+			},
+			maxLag:  time.Hour,
+			state:   jobError,
+			reasons: 2,
+		},
+		{
+			name: "destination_error",
+			job: JSONJob{Type: "push", Destinations: []*JSONPeer{
+				{Name: "offsite", Replication: &JSONReplication{Error: "dial tcp: connection refused"}},
+			}},
+			state:   jobError,
+			reasons: 1,
+		},
+		{
+			name: "snapshotting_schedule_error",
+			job: JSONJob{Type: "snap", Snapshotting: &JSONSnapshotting{Type: "multi", Schedules: []*JSONSnapshotting{
+				{Type: "periodic"},
+				{Type: "cron", Errors: []string{"hook failed"}},
+			}}},
+			state:   jobError,
+			reasons: 1,
+		},
+		{
+			name: "circuit_breaker",
+			job: JSONJob{Type: "push", CircuitBreakerSuspendedUntil: func() *time.Time {
+				t := now.Add(time.Hour)
+				return &t
+			}()},
+			state:   jobError,
+			reasons: 1,
+		},
+		{
+			name:    "aborted",
+			job:     JSONJob{Type: "push", AbortedAt: &lastSuccess},
+			state:   jobError,
+			reasons: 1,
+		},
+		{
+			name: "pools_healthy",
+			job: JSONJob{Type: "pool_monitor", Pools: &JSONPools{Pools: []*JSONPool{
+				{Name: "tank", Health: "ONLINE", Scan: &JSONScan{Function: "scrub", State: "in_progress"}},
+			}}},
+			state: jobHealthy,
+		},
+		{
+			name: "pools_unhealthy",
+			job: JSONJob{Type: "pool_monitor", Pools: &JSONPools{Pools: []*JSONPool{
+				{Name: "tank", Health: "DEGRADED", Scan: &JSONScan{Function: "scrub", State: "finished", Errors: 3}},
+				{Name: "backup", Error: "cannot open 'backup': no such pool"},
+			}}},
+			state:   jobError,
+			reasons: 3,
+		},
+		{
+			name: "disabled",
+			job: JSONJob{Type: "pull", Disabled: true, LastSuccessfulReplication: &lastSuccess,
+				Replication: &JSONReplication{Error: "dial tcp: connection refused"}},
+			maxLag: time.Hour,
+			state:  jobHealthy,
+		},
+	}
+
+	for _, c := range tcs {
+		t.Run(c.name, func(t *testing.T) {
+			state, reasons := evaluateHealth(&c.job, now, c.maxLag)
+			assert.Equal(t, c.state, state)
+			assert.Len(t, reasons, c.reasons, "%v", reasons)
+		})
+	}
+}
+
+func TestJobStatusExitCodes(t *testing.T) {
+	c := &followTestClient{status: daemon.Status{Jobs: map[string]*job.Status{
+		"ok":     {Type: job.TypeSnap, JobSpecific: &job.SnapJobStatus{}},
+		"failed": {Type: job.TypePull, JobSpecific: &job.ActiveSideStatus{LastSuccessfulReplication: time.Now().Add(-time.Hour)}},
+	}}}
+
+	exitCode := func(job string, asJSON bool, maxLag time.Duration) int {
+		err := jobStatus(c, job, asJSON, maxLag)
+		if err == nil {
+			return StatusJobExitHealthy
+		}
+		require.IsType(t, &cli.ExitCodeError{}, err)
+		return err.(*cli.ExitCodeError).Code
+	}
+
+	assert.Equal(t, StatusJobExitHealthy, exitCode("ok", true, 0))
+	assert.Equal(t, StatusJobExitHealthy, exitCode("failed", true, 0))
+	assert.Equal(t, StatusJobExitStalled, exitCode("failed", true, time.Minute))
+	assert.Equal(t, StatusJobExitFailed, exitCode("doesnotexist", true, 0))
+
+	c.err = errors.New("connection refused")
+	assert.Equal(t, StatusJobExitFailed, exitCode("ok", true, 0))
+}
@@ -129,7 +129,9 @@ func interactive(c Client, flag statusFlags) error {
 		FSFilter:                func(_ string) bool { return true },
 		DetailViewWidth:         100,
 		DetailViewWrap:          false,
-		ShortKeybindingOverview: "[::b]Q[::-] quit  [::b]<TAB>[::-] switch panes [::b]W[::-] wrap lines  [::b]Shift+M[::-] toggle navbar  [::b]Shift+S[::-] signal job [::b]</>[::-] filter filesystems",
+		ShortKeybindingOverview: "[::b]Q[::-] quit  [::b]<TAB>[::-] switch panes [::b]W[::-] wrap lines  [::b]Shift+M[::-] toggle navbar  [::b]Shift+S[::-] signal job [::b]</>[::-] filter filesystems [::b]N/P[::-] next/previous filesystem [::b]<ENTER>/E[::-] expand filesystem/all",
+		FilesystemNavigation:    true,
+		ExpandedFilesystems:     make(map[viewmodel.FilesystemRow]bool),
 	}
 	paramsMtx := &sync.Mutex{}
 	var redraw func()
@@ -331,7 +333,71 @@ func interactive(c Client, flag statusFlags) error {
 		return event
 	})
 
+	// moves the filesystem cursor of the selected job and scrolls it into view
+	moveFilesystemCursor := func(delta int) {
+		selJ := m.SelectedJob()
+		if selJ == nil {
+			return
+		}
+		viewmodelupdate(func(p *viewmodel.Params) {
+			if next, ok := viewmodel.NextFilesystemRow(selJ.FilesystemRows(), p.SelectedFilesystem, delta); ok {
+				p.SelectedFilesystem = next
+			}
+		})
+		redraw()
+		if line := selJ.SelectedFilesystemLine(); line >= 0 {
+			_, _, _, height := jobTextDetail.GetInnerRect()
+			row, _ := jobTextDetail.GetScrollOffset()
+			if line < row || line >= row+height {
+				jobTextDetail.ScrollTo(line, 0)
+			}
+		}
+	}
+
 	jobTextDetail.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyRune && (event.Rune() == 'n' || event.Rune() == 'p') {
+			delta := 1
+			if event.Rune() == 'p' {
+				delta = -1
+			}
+			moveFilesystemCursor(delta)
+			return nil
+		}
+		if event.Key() == tcell.KeyEnter {
+			// toggle the details of the filesystem at the cursor
+			viewmodelupdate(func(p *viewmodel.Params) {
+				if p.ExpandedFilesystems[p.SelectedFilesystem] {
+					delete(p.ExpandedFilesystems, p.SelectedFilesystem)
+				} else if p.SelectedFilesystem != (viewmodel.FilesystemRow{}) {
+					p.ExpandedFilesystems[p.SelectedFilesystem] = true
+				}
+			})
+			redraw()
+			return nil
+		}
+		if event.Key() == tcell.KeyRune && event.Rune() == 'e' {
+			// expand all filesystems of the selected job, or collapse them if they are all expanded
+			selJ := m.SelectedJob()
+			if selJ == nil {
+				return nil
+			}
+			viewmodelupdate(func(p *viewmodel.Params) {
+				rows := selJ.FilesystemRows()
+				allExpanded := true
+				for _, r := range rows {
+					allExpanded = allExpanded && p.ExpandedFilesystems[r]
+				}
+				for _, r := range rows {
+					if allExpanded {
+						delete(p.ExpandedFilesystems, r)
+					} else {
+						p.ExpandedFilesystems[r] = true
+					}
+				}
+			})
+			redraw()
+			return nil
+		}
 		if event.Key() == tcell.KeyRune && event.Rune() == 'w' {
 			// toggle wrapping
 			viewmodelupdate(func(p *viewmodel.Params) {
@@ -0,0 +1,591 @@
+package status
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/daemon"
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/daemon/snapper"
+	"github.com/zrepl/zrepl/replication/report"
+)
+
+// JSONStatusVersion is the version of the output format of `zrepl status --mode json`.
+// Fields may be added without changing the version, other changes increment it.
+const JSONStatusVersion = 1
+
+// JSONStatus is the output of `zrepl status --mode json`.
+//
+// Unlike `--mode raw`, which dumps the daemon's internal data structures,
+// the format is stable across releases (see JSONStatusVersion).
+// Optional timestamps are omitted if they do not apply.
+type JSONStatus struct {
+	Version int       `json:"version"`
+	Time    time.Time `json:"time"`
+	// sorted by name
+	Jobs []*JSONJob `json:"jobs"`
+}
+
+type JSONJob struct {
+	Name             string     `json:"name"`
+	Type             string     `json:"type"`
+	Disabled         bool       `json:"disabled"`
+	Maintenance      bool       `json:"maintenance"`
+	MaintenanceUntil *time.Time `json:"maintenance_until,omitempty"`
+
+	// push and pull jobs
+	Replication     *JSONReplication `json:"replication,omitempty"`
+	PruningSender   *JSONPruning     `json:"pruning_sender,omitempty"`
+	PruningReceiver *JSONPruning     `json:"pruning_receiver,omitempty"`
+	// additional destinations of push jobs, sorted by name
+	Destinations []*JSONPeer `json:"destinations,omitempty"`
+	// additional sources of pull jobs, sorted by name
+	Sources                      []*JSONPeer `json:"sources,omitempty"`
+	WaitBlackoutUntil            *time.Time  `json:"wait_blackout_until,omitempty"`
+	CircuitBreakerSuspendedUntil *time.Time  `json:"circuit_breaker_suspended_until,omitempty"`
+	// set if the latest invocation was aborted by `zrepl signal abort`
+	AbortedAt *time.Time `json:"aborted_at,omitempty"`
+	// end of the latest replication without failed filesystems (not including destinations and sources),
+	// unset if there was none since the daemon started
+	LastSuccessfulReplication *time.Time `json:"last_successful_replication,omitempty"`
+	// the invocations that finished within the last 24 hours, oldest first
+	RecentInvocations []*JSONInvocation `json:"recent_invocations,omitempty"`
+
+	// snap jobs
+	Pruning *JSONPruning `json:"pruning,omitempty"`
+
+	// push, snap and source jobs
+	Snapshotting *JSONSnapshotting `json:"snapshotting,omitempty"`
+
+	// pool_monitor jobs, unset before the first collection
+	Pools *JSONPools `json:"pools,omitempty"`
+
+	// sink jobs, unset before the first collection
+	ClientUsage *JSONClientUsage `json:"client_usage,omitempty"`
+}
+
+type JSONInvocation struct {
+	StartAt  time.Time `json:"start_at"`
+	FinishAt time.Time `json:"finish_at"`
+	// to all peers, not including the bytes that resumed steps had transferred before
+	BytesReplicated   uint64 `json:"bytes_replicated"`
+	FailedFilesystems int    `json:"failed_filesystems"`
+	// unset if the invocation succeeded
+	Error string `json:"error,omitempty"`
+}
+
+type JSONPeer struct {
+	Name            string           `json:"name"`
+	Replication     *JSONReplication `json:"replication,omitempty"`
+	PruningSender   *JSONPruning     `json:"pruning_sender,omitempty"`
+	PruningReceiver *JSONPruning     `json:"pruning_receiver,omitempty"`
+}
+
+// JSONReplication describes the latest replication run of a job.
+type JSONReplication struct {
+	StartAt  time.Time  `json:"start_at"`
+	FinishAt *time.Time `json:"finish_at,omitempty"`
+	Attempts int        `json:"attempts"`
+	// state of the latest attempt, see report.AttemptState
+	State string `json:"state"`
+	// the planning error of the latest attempt, or the error while waiting for reconnection
+	Error              string     `json:"error,omitempty"`
+	WaitReconnectUntil *time.Time `json:"wait_reconnect_until,omitempty"`
+	WaitRetryUntil     *time.Time `json:"wait_retry_until,omitempty"`
+	// sums over the filesystems of the latest attempt
+	BytesExpected   uint64                       `json:"bytes_expected"`
+	BytesReplicated uint64                       `json:"bytes_replicated"`
+	Filesystems     []*JSONReplicationFilesystem `json:"filesystems"`
+	// the most recent errors of the filesystems across invocations since the daemon started, sorted by name,
+	// includes filesystems that are not part of the latest attempt
+	FilesystemErrors []*JSONFilesystemErrors `json:"filesystem_errors,omitempty"`
+}
+
+type JSONFilesystemErrors struct {
+	Name string `json:"name"`
+	// oldest first
+	Errors []*JSONFilesystemError `json:"errors"`
+}
+
+type JSONFilesystemError struct {
+	Time  time.Time `json:"time"`
+	Error string    `json:"error"`
+	// the step in which the error occurred, unset for errors outside of steps, e.g. during planning
+	StepFrom string `json:"step_from,omitempty"`
+	StepTo   string `json:"step_to,omitempty"`
+}
+
+type JSONReplicationFilesystem struct {
+	Name string `json:"name"`
+	// see report.FilesystemState
+	State string `json:"state"`
+	// see report.FsBlockedOn
+	BlockedOn       string                 `json:"blocked_on"`
+	Error           string                 `json:"error,omitempty"`
+	ErrorTime       *time.Time             `json:"error_time,omitempty"`
+	Retries         int                    `json:"retries"`
+	BytesExpected   uint64                 `json:"bytes_expected"`
+	BytesReplicated uint64                 `json:"bytes_replicated"`
+	StepsDone       int                    `json:"steps_done"`
+	Steps           []*JSONReplicationStep `json:"steps"`
+}
+
+type JSONReplicationStep struct {
+	// empty for full sends
+	From    string `json:"from,omitempty"`
+	To      string `json:"to"`
+	Resumed bool   `json:"resumed"`
+	// including the bytes transferred before the step was resumed
+	BytesExpected   uint64 `json:"bytes_expected"`
+	BytesReplicated uint64 `json:"bytes_replicated"`
+}
+
+type JSONPruning struct {
+	// "planning", "planning-error", "executing", "execution-error" or "done"
+	State       string                   `json:"state"`
+	Error       string                   `json:"error,omitempty"`
+	Filesystems []*JSONPruningFilesystem `json:"filesystems"`
+}
+
+type JSONPruningFilesystem struct {
+	Name string `json:"name"`
+	// "pending", "executing" or "completed"
+	State      string `json:"state"`
+	SkipReason string `json:"skip_reason,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// snapshots and bookmarks
+	DestroyTotal  int `json:"destroy_total"`
+	Destroyed     int `json:"destroyed"`
+	DestroyFailed int `json:"destroy_failed"`
+}
+
+type JSONSnapshotting struct {
+	// see snapper.Type
+	Type string `json:"type"`
+	// for type periodic "sync-up", "sync-up-error-wait", "planning", "snapshotting", "waiting", "error-wait" or "stopped",
+	// for type cron "running" or "waiting"
+	State       string                        `json:"state,omitempty"`
+	Errors      []string                      `json:"errors,omitempty"`
+	SleepUntil  *time.Time                    `json:"sleep_until,omitempty"`
+	Filesystems []*JSONSnapshottingFilesystem `json:"filesystems,omitempty"`
+	// one per schedule for snapshotting type `multi`, in config order
+	Schedules []*JSONSnapshotting `json:"schedules,omitempty"`
+}
+
+type JSONPools struct {
+	LastCollection time.Time `json:"last_collection"`
+	// set if the imported pools could not be listed
+	Error string      `json:"error,omitempty"`
+	Pools []*JSONPool `json:"pools"`
+}
+
+type JSONClientUsage struct {
+	LastCollection time.Time `json:"last_collection"`
+	// set if the last collection failed, clients are those of the latest successful collection then
+	Error   string            `json:"error,omitempty"`
+	Clients []*JSONClientUsed `json:"clients"`
+}
+
+type JSONClientUsed struct {
+	ClientIdentity string `json:"client_identity"`
+	// of the client root, including descendants and snapshots
+	UsedBytes uint64 `json:"used_bytes"`
+	// sums over the filesystems and volumes below the client root
+	UsedBySnapshotsBytes   uint64 `json:"used_by_snapshots_bytes"`
+	LogicalReferencedBytes uint64 `json:"logical_referenced_bytes"`
+}
+
+type JSONPool struct {
+	Name string `json:"name"`
+	// set if the pool's state could not be collected, the other fields are unset then
+	Error string `json:"error,omitempty"`
+	// e.g. "ONLINE", "DEGRADED" or "FAULTED"
+	Health         string `json:"health,omitempty"`
+	SizeBytes      uint64 `json:"size_bytes"`
+	AllocatedBytes uint64 `json:"allocated_bytes"`
+	FreeBytes      uint64 `json:"free_bytes"`
+	// percentages
+	Capacity int `json:"capacity"`
+	// unset if the pool does not report it
+	Fragmentation *int      `json:"fragmentation,omitempty"`
+	Scan          *JSONScan `json:"scan,omitempty"`
+}
+
+type JSONScan struct {
+	// "scrub" or "resilver"
+	Function string `json:"function"`
+	// "in_progress", "paused", "finished" or "canceled"
+	State string `json:"state"`
+	// between 0 and 1, for scans in progress or paused
+	Progress float64 `json:"progress,omitempty"`
+	// found by a finished scan
+	Errors uint64 `json:"errors"`
+	// start of a scan in progress or paused, end of a finished or canceled scan
+	Time *time.Time `json:"time,omitempty"`
+}
+
+type JSONSnapshottingFilesystem struct {
+	Name string `json:"name"`
+	// "pending", "started", "done", "error", "skipped" or "aborted"
+	State    string     `json:"state"`
+	Snapshot string     `json:"snapshot,omitempty"`
+	StartAt  *time.Time `json:"start_at,omitempty"`
+	DoneAt   *time.Time `json:"done_at,omitempty"`
+	// the output of the hooks if any of them failed
+	HookError string `json:"hook_error,omitempty"`
+}
+
+func jsonStatus(c Client, jobName string) error {
+	s, err := c.Status()
+	if err != nil {
+		return err
+	}
+	return printJSONStatus(s, jobName, time.Now())
+}
+
+func printJSONStatus(s daemon.Status, jobName string, now time.Time) error {
+	if jobName != "" {
+		if _, ok := s.Jobs[jobName]; !ok {
+			return errors.Errorf("job %q not found", jobName)
+		}
+	}
+	out := NewJSONStatus(s, now)
+	if jobName != "" {
+		jobs := out.Jobs[:0]
+		for _, j := range out.Jobs {
+			if j.Name == jobName {
+				jobs = append(jobs, j)
+			}
+		}
+		out.Jobs = jobs
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// NewJSONStatus converts the daemon's status into the stable JSON format, omitting internal jobs.
+func NewJSONStatus(s daemon.Status, now time.Time) *JSONStatus {
+	out := &JSONStatus{
+		Version: JSONStatusVersion,
+		Time:    now,
+		Jobs:    []*JSONJob{},
+	}
+	for name, st := range s.Jobs {
+		if daemon.IsInternalJobName(name) || st == nil {
+			continue
+		}
+		out.Jobs = append(out.Jobs, jsonJob(name, st, now))
+	}
+	sort.Slice(out.Jobs, func(i, j int) bool { return out.Jobs[i].Name < out.Jobs[j].Name })
+	return out
+}
+
+func jsonJob(name string, st *job.Status, now time.Time) *JSONJob {
+	j := &JSONJob{
+		Name:        name,
+		Type:        string(st.Type),
+		Disabled:    st.Disabled,
+		Maintenance: st.Maintenance.Active,
+	}
+	if st.Maintenance.Active {
+		j.MaintenanceUntil = jsonTime(st.Maintenance.Until)
+	}
+	switch s := st.JobSpecific.(type) {
+	case *job.ActiveSideStatus:
+		j.Replication = jsonReplication(s.Replication, s.FilesystemErrors)
+		j.PruningSender = jsonPruning(s.PruningSender)
+		j.PruningReceiver = jsonPruning(s.PruningReceiver)
+		if st.Type == job.TypePush {
+			j.Snapshotting = jsonSnapshotting(s.Snapshotting, now)
+		}
+		for name, d := range s.Destinations {
+			j.Destinations = append(j.Destinations, &JSONPeer{
+				Name:            name,
+				Replication:     jsonReplication(d.Replication, d.FilesystemErrors),
+				PruningReceiver: jsonPruning(d.PruningReceiver),
+			})
+		}
+		sortJSONPeers(j.Destinations)
+		for name, src := range s.Sources {
+			j.Sources = append(j.Sources, &JSONPeer{
+				Name:            name,
+				Replication:     jsonReplication(src.Replication, src.FilesystemErrors),
+				PruningSender:   jsonPruning(src.PruningSender),
+				PruningReceiver: jsonPruning(src.PruningReceiver),
+			})
+		}
+		sortJSONPeers(j.Sources)
+		j.WaitBlackoutUntil = jsonTime(s.WaitBlackoutUntil)
+		if s.CircuitBreaker.Suspended(now) {
+			j.CircuitBreakerSuspendedUntil = jsonTime(s.CircuitBreaker.SuspendedUntil)
+		}
+		j.LastSuccessfulReplication = jsonTime(s.LastSuccessfulReplication)
+		j.AbortedAt = jsonTime(s.AbortedAt)
+		for _, inv := range s.RecentInvocations {
+			j.RecentInvocations = append(j.RecentInvocations, &JSONInvocation{
+				StartAt:           inv.StartAt,
+				FinishAt:          inv.FinishAt,
+				BytesReplicated:   inv.BytesReplicated,
+				FailedFilesystems: inv.FailedFilesystems,
+				Error:             inv.Error,
+			})
+		}
+	case *job.SnapJobStatus:
+		j.Pruning = jsonPruning(s.Pruning)
+		j.Snapshotting = jsonSnapshotting(s.Snapshotting, now)
+	case *job.PassiveStatus:
+		j.Snapshotting = jsonSnapshotting(s.Snapper, now)
+		j.ClientUsage = jsonClientUsage(s.ClientUsage)
+	case *job.PoolMonitorStatus:
+		j.Pools = jsonPools(s)
+	}
+	return j
+}
+
+func jsonPools(s *job.PoolMonitorStatus) *JSONPools {
+	if s.LastCollection.IsZero() {
+		return nil
+	}
+	out := &JSONPools{LastCollection: s.LastCollection, Error: s.Error, Pools: []*JSONPool{}}
+	for _, p := range s.Pools {
+		pool := &JSONPool{Name: p.Name, Error: p.Error}
+		if st := p.Status; st != nil {
+			pool.Health = st.Health
+			pool.SizeBytes = st.Size
+			pool.AllocatedBytes = st.Allocated
+			pool.FreeBytes = st.Free
+			pool.Capacity = st.Capacity
+			if st.Fragmentation >= 0 {
+				f := st.Fragmentation
+				pool.Fragmentation = &f
+			}
+			if sc := st.Scan; sc != nil {
+				pool.Scan = &JSONScan{
+					Function: sc.Function,
+					State:    sc.State,
+					Progress: sc.Progress,
+					Errors:   sc.Errors,
+					Time:     jsonTime(sc.Time),
+				}
+			}
+		}
+		out.Pools = append(out.Pools, pool)
+	}
+	return out
+}
+
+func jsonClientUsage(s *job.ClientUsageStatus) *JSONClientUsage {
+	if s == nil || s.LastCollection.IsZero() {
+		return nil
+	}
+	out := &JSONClientUsage{LastCollection: s.LastCollection, Error: s.Error, Clients: []*JSONClientUsed{}}
+	for _, c := range s.Clients {
+		out.Clients = append(out.Clients, &JSONClientUsed{
+			ClientIdentity:         c.ClientIdentity,
+			UsedBytes:              c.Used,
+			UsedBySnapshotsBytes:   c.UsedBySnapshots,
+			LogicalReferencedBytes: c.LogicalReferenced,
+		})
+	}
+	return out
+}
+
+func sortJSONPeers(peers []*JSONPeer) {
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Name < peers[j].Name })
+}
+
+// nil for the zero time
+func jsonTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func jsonReplication(r *report.Report, fsErrors map[string][]*report.FilesystemError) *JSONReplication {
+	if r == nil {
+		return nil
+	}
+	out := &JSONReplication{
+		StartAt:            r.StartAt,
+		FinishAt:           jsonTime(r.FinishAt),
+		Attempts:           len(r.Attempts),
+		WaitReconnectUntil: jsonTime(r.WaitReconnectUntil),
+		WaitRetryUntil:     jsonTime(r.WaitRetryUntil),
+		Filesystems:        []*JSONReplicationFilesystem{},
+	}
+	if r.WaitReconnectError != nil {
+		out.Error = r.WaitReconnectError.Err
+	}
+	out.FilesystemErrors = jsonFilesystemErrors(fsErrors)
+	if len(r.Attempts) == 0 {
+		return out
+	}
+	a := r.Attempts[len(r.Attempts)-1]
+	out.State = string(a.State)
+	if a.PlanError != nil {
+		out.Error = a.PlanError.Err
+	}
+	for _, fs := range a.Filesystems {
+		jfs := &JSONReplicationFilesystem{
+			Name:      fs.Info.Name,
+			State:     string(fs.State),
+			BlockedOn: string(fs.BlockedOn),
+			Retries:   fs.Retries,
+			Steps:     []*JSONReplicationStep{},
+		}
+		if err := fs.Error(); err != nil {
+			jfs.Error = err.Err
+			jfs.ErrorTime = jsonTime(err.Time)
+		}
+		jfs.BytesExpected, jfs.BytesReplicated, _ = fs.BytesSum()
+		switch fs.State {
+		case report.FilesystemDone:
+			jfs.StepsDone = len(fs.Steps)
+		case report.FilesystemStepping, report.FilesystemSteppingErrored:
+			jfs.StepsDone = fs.CurrentStep
+		}
+		for _, step := range fs.Steps {
+			jfs.Steps = append(jfs.Steps, &JSONReplicationStep{
+				From:            step.Info.From,
+				To:              step.Info.To,
+				Resumed:         step.Info.Resumed,
+				BytesExpected:   step.Info.BytesResumed + step.Info.BytesExpected,
+				BytesReplicated: step.Info.BytesResumed + step.Info.BytesReplicated,
+			})
+		}
+		out.BytesExpected += jfs.BytesExpected
+		out.BytesReplicated += jfs.BytesReplicated
+		out.Filesystems = append(out.Filesystems, jfs)
+	}
+	return out
+}
+
+func jsonFilesystemErrors(in map[string][]*report.FilesystemError) []*JSONFilesystemErrors {
+	var out []*JSONFilesystemErrors
+	for fs, errs := range in {
+		jfs := &JSONFilesystemErrors{Name: fs, Errors: make([]*JSONFilesystemError, 0, len(errs))}
+		for _, e := range errs {
+			je := &JSONFilesystemError{Time: e.Time, Error: e.Err}
+			if e.Step != nil {
+				je.StepFrom, je.StepTo = e.Step.From, e.Step.To
+			}
+			jfs.Errors = append(jfs.Errors, je)
+		}
+		out = append(out, jfs)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// keyed by the names of the pruner.State constants
+var jsonPrunerStates = map[string]string{
+	"Plan":    "planning",
+	"PlanErr": "planning-error",
+	"Exec":    "executing",
+	"ExecErr": "execution-error",
+	"Done":    "done",
+}
+
+func jsonPruning(r *pruner.Report) *JSONPruning {
+	if r == nil {
+		return nil
+	}
+	out := &JSONPruning{
+		State:       jsonPrunerStates[r.State],
+		Error:       r.Error,
+		Filesystems: []*JSONPruningFilesystem{},
+	}
+	add := func(fs pruner.FSReport, state string) {
+		out.Filesystems = append(out.Filesystems, &JSONPruningFilesystem{
+			Name:          fs.Filesystem,
+			State:         state,
+			SkipReason:    string(fs.SkipReason),
+			Error:         fs.LastError,
+			DestroyTotal:  len(fs.DestroyList) + len(fs.BookmarkDestroyList),
+			Destroyed:     fs.Destroyed,
+			DestroyFailed: fs.DestroyFailed,
+		})
+	}
+	for _, fs := range r.Pending {
+		if fs.Executing {
+			add(fs, "executing")
+		} else {
+			add(fs, "pending")
+		}
+	}
+	for _, fs := range r.Completed {
+		add(fs, "completed")
+	}
+	sort.SliceStable(out.Filesystems, func(i, j int) bool { return out.Filesystems[i].Name < out.Filesystems[j].Name })
+	return out
+}
+
+// independent of the names of the constants in package snapper
+var jsonPeriodicStates = map[snapper.State]string{
+	snapper.SyncUp:        "sync-up",
+	snapper.SyncUpErrWait: "sync-up-error-wait",
+	snapper.Planning:      "planning",
+	snapper.Snapshotting:  "snapshotting",
+	snapper.Waiting:       "waiting",
+	snapper.ErrorWait:     "error-wait",
+	snapper.Stopped:       "stopped",
+}
+
+var jsonSnapStates = map[snapper.SnapState]string{
+	snapper.SnapPending: "pending",
+	snapper.SnapStarted: "started",
+	snapper.SnapDone:    "done",
+	snapper.SnapError:   "error",
+	snapper.SnapSkipped: "skipped",
+	snapper.SnapAborted: "aborted",
+}
+
+func jsonSnapshotting(r *snapper.Report, now time.Time) *JSONSnapshotting {
+	if r == nil {
+		return nil
+	}
+	out := &JSONSnapshotting{Type: string(r.Type)}
+	var progress []*snapper.ReportFilesystem
+	switch {
+	case r.Periodic != nil:
+		out.State = jsonPeriodicStates[r.Periodic.State]
+		if r.Periodic.Error != "" {
+			out.Errors = []string{r.Periodic.Error}
+		}
+		out.SleepUntil = jsonTime(r.Periodic.SleepUntil)
+		progress = r.Periodic.Progress
+	case r.Cron != nil:
+		out.State = string(r.Cron.State)
+		out.Errors = r.Cron.Errors
+		if r.Cron.WakeupTime.After(now) {
+			out.SleepUntil = jsonTime(r.Cron.WakeupTime)
+		}
+		progress = r.Cron.Progress
+	case r.Multi != nil:
+		for _, sr := range r.Multi {
+			out.Schedules = append(out.Schedules, jsonSnapshotting(sr, now))
+		}
+	}
+	for _, fs := range progress {
+		jfs := &JSONSnapshottingFilesystem{
+			Name:     fs.Path,
+			State:    jsonSnapStates[fs.State],
+			Snapshot: fs.SnapName,
+			StartAt:  jsonTime(fs.StartAt),
+			DoneAt:   jsonTime(fs.DoneAt),
+		}
+		if fs.HooksHadError {
+			jfs.HookError = fs.Hooks
+		}
+		out.Filesystems = append(out.Filesystems, jfs)
+	}
+	sort.Slice(out.Filesystems, func(i, j int) bool { return out.Filesystems[i].Name < out.Filesystems[j].Name })
+	return out
+}
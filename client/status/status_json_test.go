@@ -0,0 +1,173 @@
+package status
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon"
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/job/maintenance"
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/daemon/snapper"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/replication/report"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestNewJSONStatus(t *testing.T) {
+	now := time.Date(2022, 7, 23, 14, 0, 0, 0, time.UTC)
+	start := now.Add(-10 * time.Minute)
+
+	s := daemon.Status{
+		Jobs: map[string]*job.Status{
+			"_control": {Type: "control"},
+			"snap": {
+				Type:        job.TypeSnap,
+				Maintenance: maintenance.State{Active: true, Until: now.Add(time.Hour)},
+				JobSpecific: &job.SnapJobStatus{
+					Pruning: &pruner.Report{State: "Exec", Pending: []pruner.FSReport{
+						{Filesystem: "zroot/b", Executing: true, DestroyList: make([]pruner.SnapshotReport, 2), Destroyed: 1},
+					}},
+					Snapshotting: &snapper.Report{Type: snapper.TypePeriodic, Periodic: &snapper.PeriodicReport{
+						State:      snapper.Waiting,
+						SleepUntil: now.Add(5 * time.Minute),
+						Progress: []*snapper.ReportFilesystem{
+							{Path: "zroot/b", State: snapper.SnapDone, SnapName: "zrepl_1", StartAt: start, DoneAt: start},
+						},
+					}},
+				},
+			},
+			"push": {
+				Type: job.TypePush,
+				JobSpecific: &job.ActiveSideStatus{
+					Replication: &report.Report{
+						StartAt: start,
+						Attempts: []*report.AttemptReport{{
+							State: report.AttemptFanOutError,
+							Filesystems: []*report.FilesystemReport{
+								{
+									Info:        &report.FilesystemInfo{Name: "zroot/a"},
+									State:       report.FilesystemSteppingErrored,
+									BlockedOn:   report.FsBlockedOnNothing,
+									StepError:   report.NewTimedError("broken pipe", now),
+									CurrentStep: 1,
+									Steps: []*report.StepReport{
+										{Info: &report.StepInfo{To: "zrepl_1", BytesExpected: 100, BytesReplicated: 100}},
+										{Info: &report.StepInfo{From: "zrepl_1", To: "zrepl_2", Resumed: true, BytesResumed: 10, BytesExpected: 40, BytesReplicated: 20}},
+									},
+								},
+							},
+						}},
+					},
+					FilesystemErrors: map[string][]*report.FilesystemError{
+						"zroot/c": {{Err: "dataset is busy", Time: start}},
+						"zroot/a": {
+							{Err: "connection reset by peer", Time: start, Step: &report.StepInfo{From: "zrepl_1", To: "zrepl_2"}},
+							{Err: "broken pipe", Time: now, Step: &report.StepInfo{From: "zrepl_1", To: "zrepl_2"}},
+						},
+					},
+					Destinations: map[string]*job.ActiveSideDestinationStatus{
+						"usb":     {},
+						"offsite": {},
+					},
+				},
+			},
+		},
+	}
+
+	out := NewJSONStatus(s, now)
+	assert.Equal(t, JSONStatusVersion, out.Version)
+	require.Len(t, out.Jobs, 2, "internal jobs must be omitted")
+
+	push := out.Jobs[0]
+	assert.Equal(t, "push", push.Name)
+	rep := push.Replication
+	require.NotNil(t, rep)
+	assert.Nil(t, rep.FinishAt)
+	assert.Equal(t, 1, rep.Attempts)
+	assert.Equal(t, "filesystem-error", rep.State)
+	assert.Equal(t, uint64(150), rep.BytesExpected)
+	assert.Equal(t, uint64(130), rep.BytesReplicated)
+	require.Len(t, rep.Filesystems, 1)
+	fs := rep.Filesystems[0]
+	assert.Equal(t, "broken pipe", fs.Error)
+	assert.Equal(t, now, *fs.ErrorTime)
+	assert.Equal(t, 1, fs.StepsDone)
+	assert.Equal(t, &JSONReplicationStep{From: "zrepl_1", To: "zrepl_2", Resumed: true, BytesExpected: 50, BytesReplicated: 30}, fs.Steps[1])
+	require.Len(t, rep.FilesystemErrors, 2)
+	assert.Equal(t, "zroot/a", rep.FilesystemErrors[0].Name)
+	assert.Equal(t, []*JSONFilesystemError{
+		{Time: start, Error: "connection reset by peer", StepFrom: "zrepl_1", StepTo: "zrepl_2"},
+		{Time: now, Error: "broken pipe", StepFrom: "zrepl_1", StepTo: "zrepl_2"},
+	}, rep.FilesystemErrors[0].Errors)
+	assert.Equal(t, []*JSONFilesystemError{{Time: start, Error: "dataset is busy"}}, rep.FilesystemErrors[1].Errors)
+	require.Len(t, push.Destinations, 2)
+	assert.Equal(t, "offsite", push.Destinations[0].Name)
+	assert.Nil(t, push.Destinations[0].Replication)
+
+	snap := out.Jobs[1]
+	assert.True(t, snap.Maintenance)
+	assert.Equal(t, now.Add(time.Hour), *snap.MaintenanceUntil)
+	assert.Equal(t, &JSONPruning{State: "executing", Filesystems: []*JSONPruningFilesystem{
+		{Name: "zroot/b", State: "executing", DestroyTotal: 2, Destroyed: 1},
+	}}, snap.Pruning)
+	assert.Equal(t, "waiting", snap.Snapshotting.State)
+	assert.Equal(t, "done", snap.Snapshotting.Filesystems[0].State)
+
+	// the format is part of the interface
+	b, err := json.Marshal(out.Jobs[1].Pruning)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"state":"executing","filesystems":[{"name":"zroot/b","state":"executing","destroy_total":2,"destroyed":1,"destroy_failed":0}]}`, string(b))
+}
+
+func TestNewJSONStatusPools(t *testing.T) {
+	now := time.Date(2022, 7, 23, 14, 0, 0, 0, time.UTC)
+	s := daemon.Status{Jobs: map[string]*job.Status{
+		"pools": {Type: job.TypePoolMonitor, JobSpecific: &job.PoolMonitorStatus{
+			LastCollection: now,
+			Pools: []*job.PoolMonitorPool{
+				{Name: "tank", Status: &zfs.ZPoolStatus{
+					Name: "tank", Health: "ONLINE", Size: 1000, Allocated: 600, Free: 400, Capacity: 60, Fragmentation: -1,
+					Scan: &zfs.ZPoolScan{Function: "scrub", State: "in_progress", Progress: 0.25, Time: now.Add(-time.Hour)},
+				}},
+				{Name: "backup", Error: "cannot open 'backup': no such pool"},
+			},
+		}},
+		"idle": {Type: job.TypePoolMonitor, JobSpecific: &job.PoolMonitorStatus{}},
+	}}
+
+	out := NewJSONStatus(s, now)
+	require.Len(t, out.Jobs, 2)
+	assert.Nil(t, out.Jobs[0].Pools, "no collection yet")
+	b, err := json.Marshal(out.Jobs[1].Pools)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"last_collection":"2022-07-23T14:00:00Z","pools":[
+		{"name":"tank","health":"ONLINE","size_bytes":1000,"allocated_bytes":600,"free_bytes":400,"capacity":60,
+		 "scan":{"function":"scrub","state":"in_progress","progress":0.25,"errors":0,"time":"2022-07-23T13:00:00Z"}},
+		{"name":"backup","error":"cannot open 'backup': no such pool","size_bytes":0,"allocated_bytes":0,"free_bytes":0,"capacity":0}
+	]}`, string(b))
+}
+
+func TestNewJSONStatusClientUsage(t *testing.T) {
+	now := time.Date(2022, 7, 23, 14, 0, 0, 0, time.UTC)
+	s := daemon.Status{Jobs: map[string]*job.Status{
+		"sink": {Type: job.TypeSink, JobSpecific: &job.PassiveStatus{ClientUsage: &job.ClientUsageStatus{
+			LastCollection: now,
+			Clients:        []endpoint.ClientUsage{{ClientIdentity: "prod", Used: 600, UsedBySnapshots: 250, LogicalReferenced: 900}},
+		}}},
+		"new": {Type: job.TypeSink, JobSpecific: &job.PassiveStatus{ClientUsage: &job.ClientUsageStatus{}}},
+	}}
+
+	out := NewJSONStatus(s, now)
+	require.Len(t, out.Jobs, 2)
+	assert.Nil(t, out.Jobs[0].ClientUsage, "no collection yet")
+	b, err := json.Marshal(out.Jobs[1].ClientUsage)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"last_collection":"2022-07-23T14:00:00Z","clients":[
+		{"client_identity":"prod","used_bytes":600,"used_by_snapshots_bytes":250,"logical_referenced_bytes":900}
+	]}`, string(b))
+}
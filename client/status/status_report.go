@@ -0,0 +1,120 @@
+package status
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/client/status/viewmodel"
+	"github.com/zrepl/zrepl/daemon"
+	"github.com/zrepl/zrepl/daemon/job"
+)
+
+// reportWindow is the time span of the activity summary of `zrepl status --mode report`.
+// The daemon keeps the invocations of the last 24 hours, see job.ActiveSideStatus.RecentInvocations.
+const reportWindow = 24 * time.Hour
+
+func plainTextReport(c Client, flags statusFlags) error {
+	s, err := c.Status()
+	if err != nil {
+		return err
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown host"
+	}
+	return printReport(os.Stdout, s, flags.Job, hostname, time.Now(), flags.MaxReplicationLag)
+}
+
+// printReport writes a plain-text summary of the jobs in s, meant for report mails, e.g. from cron.
+// maxLag is passed to evaluateHealth.
+func printReport(w io.Writer, s daemon.Status, jobName, hostname string, now time.Time, maxLag time.Duration) error {
+	if jobName != "" {
+		if _, ok := s.Jobs[jobName]; !ok {
+			return errors.Errorf("job %q not found", jobName)
+		}
+	}
+	var jobs []*JSONJob
+	for _, j := range NewJSONStatus(s, now).Jobs {
+		if jobName == "" || j.Name == jobName {
+			jobs = append(jobs, j)
+		}
+	}
+
+	var b strings.Builder
+	attention := 0
+	for _, j := range jobs {
+		b.WriteString("\n")
+		if !reportJob(&b, j, now, maxLag) {
+			attention++
+		}
+	}
+
+	fmt.Fprintf(w, "zrepl status report of %s at %s\n", hostname, now.Format("2006-01-02 15:04:05 MST"))
+	fmt.Fprintf(w, "%d job(s), %d need(s) attention\n", len(jobs), attention)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// reportJob writes the section of j, returns false if the job needs attention.
+func reportJob(b *strings.Builder, j *JSONJob, now time.Time, maxLag time.Duration) (healthy bool) {
+	state, reasons := evaluateHealth(j, now, maxLag)
+	var summary string
+	switch {
+	case j.Disabled:
+		summary = "DISABLED"
+	case j.Maintenance:
+		summary = "MAINTENANCE"
+	case state == jobError:
+		summary = "ERROR"
+	case state == jobStalled:
+		summary = "STALLED"
+	default:
+		summary = "OK"
+	}
+	fmt.Fprintf(b, "%s (%s): %s\n", j.Name, j.Type, summary)
+
+	if j.Type == string(job.TypePush) || j.Type == string(job.TypePull) {
+		if last := j.LastSuccessfulReplication; last != nil {
+			fmt.Fprintf(b, "  Last successful replication: %s (lag %s)\n",
+				last.Format("2006-01-02 15:04:05"), humanizeLag(now.Sub(*last)))
+		} else {
+			b.WriteString("  Last successful replication: none since the daemon started\n")
+		}
+
+		var invocations, failed int
+		var bytes uint64
+		for _, inv := range j.RecentInvocations {
+			if now.Sub(inv.FinishAt) > reportWindow {
+				continue
+			}
+			invocations++
+			if inv.Error != "" {
+				failed++
+			}
+			bytes += inv.BytesReplicated
+		}
+		fmt.Fprintf(b, "  Last 24h: %d invocation(s), %d failed, %s replicated\n",
+			invocations, failed, viewmodel.ByteCountBinaryUint(bytes))
+	}
+
+	if len(reasons) > 0 {
+		b.WriteString("  Problems:\n")
+		for _, r := range reasons {
+			// keep multi-line errors readable in mails
+			fmt.Fprintf(b, "    - %s\n", strings.Replace(r, "\n", "\n      ", -1))
+		}
+	}
+	return state == jobHealthy
+}
+
+func humanizeLag(d time.Duration) string {
+	if d < time.Minute {
+		return d.Truncate(time.Second).String()
+	}
+	return d.Truncate(time.Minute).String()
+}
@@ -0,0 +1,71 @@
+package status
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon"
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/replication/report"
+)
+
+func TestPrintReport(t *testing.T) {
+	now := time.Date(2022, 7, 23, 14, 0, 0, 0, time.UTC)
+	s := daemon.Status{
+		Jobs: map[string]*job.Status{
+			"_control": {Type: "control"},
+			"prod": {
+				Type: job.TypePush,
+				JobSpecific: &job.ActiveSideStatus{
+					Replication: &report.Report{
+						StartAt: now.Add(-10 * time.Minute),
+						Attempts: []*report.AttemptReport{{
+							State: report.AttemptFanOutError,
+							Filesystems: []*report.FilesystemReport{{
+								Info:      &report.FilesystemInfo{Name: "zroot/a"},
+								State:     report.FilesystemSteppingErrored,
+								StepError: report.NewTimedError("broken pipe", now),
+							}},
+						}},
+					},
+					LastSuccessfulReplication: now.Add(-90 * time.Minute),
+					RecentInvocations: []*job.InvocationSummary{
+						{FinishAt: now.Add(-25 * time.Hour), BytesReplicated: 1 << 40},
+						{FinishAt: now.Add(-90 * time.Minute), BytesReplicated: 1 << 30},
+						{FinishAt: now.Add(-5 * time.Minute), BytesReplicated: 1 << 29, FailedFilesystems: 1, Error: "replication: 1 filesystem(s) failed"},
+					},
+				},
+			},
+			"backups": {Type: job.TypePull, Disabled: true, JobSpecific: &job.ActiveSideStatus{}},
+			"sink":    {Type: job.TypeSink, JobSpecific: &job.PassiveStatus{}},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, printReport(&buf, s, "", "backup1", now, 0))
+	assert.Equal(t, `zrepl status report of backup1 at 2022-07-23 14:00:00 UTC
+3 job(s), 1 need(s) attention
+
+backups (pull): DISABLED
+  Last successful replication: none since the daemon started
+  Last 24h: 0 invocation(s), 0 failed, 0 B replicated
+
+prod (push): ERROR
+  Last successful replication: 2022-07-23 12:30:00 (lag 1h30m0s)
+  Last 24h: 2 invocation(s), 1 failed, 1.5 GiB replicated
+  Problems:
+    - replication of "zroot/a": broken pipe
+
+sink (sink): OK
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, printReport(&buf, s, "sink", "backup1", now, 0))
+	assert.Contains(t, buf.String(), "1 job(s), 0 need(s) attention\n\nsink (sink): OK\n")
+
+	assert.Error(t, printReport(&buf, s, "nonexistent", "backup1", now, 0))
+}
@@ -0,0 +1,210 @@
+package viewmodel
+
+import (
+	"time"
+
+	"github.com/zrepl/zrepl/client/status/viewmodel/stringbuilder"
+	"github.com/zrepl/zrepl/replication/report"
+)
+
+// FilesystemRow identifies the row of a replicated filesystem in Job.FullDescription.
+type FilesystemRow struct {
+	Job string
+	// "" for the replication with the peer in `connect`,
+	// "destination NAME" or "source NAME" for the additional destinations of push jobs and sources of pull jobs
+	Section    string
+	Filesystem string
+}
+
+// NextFilesystemRow returns the row delta rows below cur (above if delta is negative), stopping at the first and last row.
+// If cur is not in rows, it starts from the top for delta > 0 and from the bottom otherwise.
+// ok is false if rows is empty.
+func NextFilesystemRow(rows []FilesystemRow, cur FilesystemRow, delta int) (next FilesystemRow, ok bool) {
+	if len(rows) == 0 {
+		return FilesystemRow{}, false
+	}
+	i := -1
+	for j, r := range rows {
+		if r == cur {
+			i = j
+			break
+		}
+	}
+	switch {
+	case i == -1 && delta > 0:
+		i = 0
+	case i == -1:
+		i = len(rows) - 1
+	default:
+		i += delta
+	}
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(rows) {
+		i = len(rows) - 1
+	}
+	return rows[i], true
+}
+
+// fsRows tracks the filesystem rows while a job is drawn.
+type fsRows struct {
+	job      string
+	nav      bool
+	selected FilesystemRow
+	expanded map[FilesystemRow]bool
+	history  map[FilesystemRow]*bytesProgressHistory
+
+	// populated while drawing
+	rows         []FilesystemRow
+	selectedLine int
+}
+
+// add records that row is drawn at the current line of t and returns its cursor column.
+func (r *fsRows) add(t *stringbuilder.B, row FilesystemRow) (cursor string) {
+	r.rows = append(r.rows, row)
+	if !r.nav {
+		return ""
+	}
+	if row == r.selected {
+		r.selectedLine = t.Line()
+		return "> "
+	}
+	return "  "
+}
+
+func (r *fsRows) row(section, fs string) FilesystemRow {
+	return FilesystemRow{Job: r.job, Section: section, Filesystem: fs}
+}
+
+func (r *fsRows) fsHistory(row FilesystemRow) *bytesProgressHistory {
+	h, ok := r.history[row]
+	if !ok {
+		h = &bytesProgressHistory{}
+		r.history[row] = h
+	}
+	return h
+}
+
+// renderFilesystemDetails draws the throughput, retries, last error, the steps of rep and the error history errs,
+// one per line. It does not end with a newline.
+func renderFilesystemDetails(t *stringbuilder.B, rep *report.FilesystemReport, errs []*report.FilesystemError, history *bytesProgressHistory) {
+	first := true
+	newline := func() {
+		if !first {
+			t.Newline()
+		}
+		first = false
+	}
+
+	expected, replicated, _ := rep.BytesSum()
+	rate, changeCount := history.Update(replicated)
+	if rep.State == report.FilesystemStepping {
+		newline()
+		t.Printf("Throughput: %s/s", ByteCountBinary(rate))
+		if rate > 0 && expected > replicated {
+			eta := time.Duration(float64(expected-replicated)/float64(rate)) * time.Second
+			t.Printf(" (%s remaining)", humanizeDuration(eta))
+		}
+	}
+
+	if rep.Retries > 0 {
+		newline()
+		t.Printf("Retries: %d", rep.Retries)
+		if rep.RetryError != nil {
+			t.Printf(", latest after error at %s: ", rep.RetryError.Time.Round(time.Second))
+			t.PrintfDrawIndentedAndWrappedIfMultiline("%s", rep.RetryError.Err)
+		}
+	}
+
+	if err := rep.Error(); err != nil {
+		newline()
+		t.Printf("Error at %s: ", err.Time.Round(time.Second))
+		t.PrintfDrawIndentedAndWrappedIfMultiline("%s", err.Err)
+	}
+
+	defer renderFilesystemErrorHistory(t, errs)
+
+	newline()
+	if len(rep.Steps) == 0 {
+		t.Printf("Steps: none planned")
+		return
+	}
+	t.Printf("Steps:")
+	t.AddIndent(1)
+	for i, step := range rep.Steps {
+		t.Newline()
+
+		var state string
+		stepChangeCount := 0
+		switch {
+		case i < rep.CurrentStep:
+			state = "done"
+		case i > rep.CurrentStep:
+			state = "pending"
+		case rep.State == report.FilesystemSteppingErrored:
+			state = "failed"
+		case rep.State == report.FilesystemStepping:
+			state = "running"
+			stepChangeCount = changeCount
+		default:
+			state = "pending"
+		}
+
+		stepExpected := step.Info.BytesResumed + step.Info.BytesExpected
+		stepReplicated := step.Info.BytesResumed + step.Info.BytesReplicated
+		if state == "done" && step.Info.BytesExpected == 0 {
+			// no size estimate, but there is nothing left to transfer
+			stepExpected = stepReplicated
+		}
+
+		t.Printf("%d/%d %s ", i+1, len(rep.Steps), stringbuilder.RightPad(state, len("running"), " "))
+		barTotal := stepExpected
+		if barTotal == 0 && state != "done" {
+			barTotal = 1 // DrawBar draws a full bar for 0 of 0 bytes
+		}
+		t.DrawBar(20, stepReplicated, barTotal, stepChangeCount)
+		t.Printf(" %s / %s ", ByteCountBinaryUint(stepReplicated), ByteCountBinaryUint(stepExpected))
+		if step.IsIncremental() {
+			t.Printf("%s => %s", step.Info.From, step.Info.To)
+		} else {
+			t.Printf("full send %s", step.Info.To)
+		}
+		if step.Info.Resumed {
+			if step.Info.BytesResumed > 0 {
+				t.Printf(" (resumed after %s)", ByteCountBinaryUint(step.Info.BytesResumed))
+			} else {
+				t.Printf(" (resumed)")
+			}
+		}
+		if step.Info.BytesExpected == 0 && state != "done" {
+			t.Printf(" (no size estimate)")
+		}
+	}
+	t.AddIndent(-1)
+}
+
+// renderFilesystemErrorHistory draws errs, the most recent first, starting on a new line.
+func renderFilesystemErrorHistory(t *stringbuilder.B, errs []*report.FilesystemError) {
+	if len(errs) == 0 {
+		return
+	}
+	t.Newline()
+	t.Printf("Recent errors (since the daemon started):")
+	t.AddIndent(1)
+	for i := len(errs) - 1; i >= 0; i-- {
+		e := errs[i]
+		t.Newline()
+		t.Printf("%s ", e.Time.Format("2006-01-02 15:04:05"))
+		switch {
+		case e.Step == nil:
+			t.Printf("planning: ")
+		case e.Step.From != "":
+			t.Printf("%s => %s: ", e.Step.From, e.Step.To)
+		default:
+			t.Printf("full send %s: ", e.Step.To)
+		}
+		t.PrintfDrawIndentedAndWrappedIfMultiline("%s", e.Err)
+	}
+	t.AddIndent(-1)
+}
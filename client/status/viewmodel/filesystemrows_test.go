@@ -0,0 +1,115 @@
+package viewmodel
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/replication/report"
+)
+
+func TestNextFilesystemRow(t *testing.T) {
+	a := FilesystemRow{Job: "j", Filesystem: "pool/a"}
+	b := FilesystemRow{Job: "j", Filesystem: "pool/b"}
+	c := FilesystemRow{Job: "j", Section: "destination offsite", Filesystem: "pool/a"}
+	rows := []FilesystemRow{a, b, c}
+
+	next := func(cur FilesystemRow, delta int) FilesystemRow {
+		r, ok := NextFilesystemRow(rows, cur, delta)
+		require.True(t, ok)
+		return r
+	}
+	assert.Equal(t, a, next(FilesystemRow{}, 1))
+	assert.Equal(t, c, next(FilesystemRow{}, -1))
+	assert.Equal(t, b, next(a, 1))
+	assert.Equal(t, c, next(b, 1))
+	assert.Equal(t, c, next(c, 1))
+	assert.Equal(t, a, next(a, -1))
+
+	_, ok := NextFilesystemRow(nil, a, 1)
+	assert.False(t, ok)
+}
+
+func TestExpandedFilesystem(t *testing.T) {
+	now := time.Now()
+	st := map[string]*job.Status{
+		"j": {Type: job.TypePush, JobSpecific: &job.ActiveSideStatus{Replication: &report.Report{
+			StartAt: now,
+			Attempts: []*report.AttemptReport{{
+				State:   report.AttemptFanOutFSs,
+				StartAt: now,
+				Filesystems: []*report.FilesystemReport{
+					{
+						Info:        &report.FilesystemInfo{Name: "pool/a"},
+						State:       report.FilesystemStepping,
+						BlockedOn:   report.FsBlockedOnNothing,
+						CurrentStep: 1,
+						Retries:     2,
+						RetryError:  &report.TimedError{Err: "connection reset by peer", Time: now},
+						Steps: []*report.StepReport{
+							{Info: &report.StepInfo{To: "@s1", BytesExpected: 100, BytesReplicated: 100}},
+							{Info: &report.StepInfo{From: "@s1", To: "@s2", Resumed: true, BytesResumed: 50, BytesExpected: 50, BytesReplicated: 10}},
+							{Info: &report.StepInfo{From: "@s2", To: "@s3"}},
+						},
+					},
+					{
+						Info:  &report.FilesystemInfo{Name: "pool/b"},
+						State: report.FilesystemDone,
+					},
+				},
+			}},
+		}}},
+	}
+	st["j"].JobSpecific.(*job.ActiveSideStatus).FilesystemErrors = map[string][]*report.FilesystemError{
+		"pool/a": {
+			{Err: "cannot open 'pool/a': dataset does not exist", Time: now.Add(-2 * time.Hour)},
+			{Err: "broken pipe", Time: now.Add(-time.Hour), Step: &report.StepInfo{From: "@s1", To: "@s2"}},
+		},
+	}
+
+	m := New()
+	p := Params{
+		Report:               st,
+		FSFilter:             func(string) bool { return true },
+		DetailViewWidth:      100,
+		FilesystemNavigation: true,
+		ExpandedFilesystems:  make(map[FilesystemRow]bool),
+	}
+	m.Update(p)
+	require.Len(t, m.Jobs(), 1)
+	j := m.Jobs()[0]
+	p.SelectedJob = j
+
+	a := FilesystemRow{Job: "j", Filesystem: "pool/a"}
+	assert.Equal(t, []FilesystemRow{a, {Job: "j", Filesystem: "pool/b"}}, j.FilesystemRows())
+	assert.Equal(t, -1, j.SelectedFilesystemLine())
+	assert.NotContains(t, j.FullDescription(), "Steps:")
+
+	p.SelectedFilesystem = a
+	p.ExpandedFilesystems[a] = true
+	m.Update(p)
+	desc := j.FullDescription()
+	lines := strings.Split(desc, "\n")
+	require.True(t, j.SelectedFilesystemLine() >= 0)
+	assert.Contains(t, lines[j.SelectedFilesystemLine()], "> * pool/a")
+	assert.Contains(t, desc, "Retries: 2, latest after error at")
+	assert.Contains(t, desc, "connection reset by peer")
+	assert.Contains(t, desc, "1/3 done    [")
+	assert.Contains(t, desc, "2/3 running [")
+	assert.Contains(t, desc, "60 B / 100 B @s1 => @s2 (resumed after 50 B)")
+	assert.Contains(t, desc, "3/3 pending [")
+	assert.Contains(t, desc, "(no size estimate)")
+	require.Contains(t, desc, "Recent errors (since the daemon started):")
+	history := desc[strings.Index(desc, "Recent errors (since the daemon started):"):]
+	assert.Regexp(t, `(?s)@s1 => @s2: broken pipe.*planning: cannot open 'pool/a'`, history, "most recent first")
+
+	// filtered rows can't be selected
+	p.FSFilter = func(fs string) bool { return fs != "pool/a" }
+	m.Update(p)
+	assert.Equal(t, []FilesystemRow{{Job: "j", Filesystem: "pool/b"}}, j.FilesystemRows())
+	assert.Equal(t, -1, j.SelectedFilesystemLine())
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/zrepl/zrepl/daemon/pruner"
 	"github.com/zrepl/zrepl/daemon/snapper"
 	"github.com/zrepl/zrepl/replication/report"
+	"github.com/zrepl/zrepl/rpc/versionhandshake"
 )
 
 type M struct {
@@ -30,6 +31,15 @@ type Job struct {
 	// long-lived
 	name         string
 	byteProgress *bytesProgressHistory
+	// for the additional destinations of push jobs and sources of pull jobs, keyed by name
+	peersByteProgress map[string]*bytesProgressHistory
+	// per replicated filesystem, keyed by FilesystemRow
+	fsByteProgress map[FilesystemRow]*bytesProgressHistory
+
+	// the filesystem rows of the latest fulldescription, in the order in which they are drawn
+	fsRows []FilesystemRow
+	// line of the selected filesystem row in fulldescription, -1 if it was not drawn
+	selectedFSLine int
 
 	lastStatus      *job.Status
 	fulldescription string
@@ -53,6 +63,12 @@ type Params struct {
 	DetailViewWidth         int        `validate:"gte=1"`
 	DetailViewWrap          bool
 	ShortKeybindingOverview string
+
+	// draw a cursor at SelectedFilesystem, the rows don't have a cursor column if false
+	FilesystemNavigation bool
+	SelectedFilesystem   FilesystemRow
+	// the replication steps, throughput, retries and errors of these filesystems are drawn below their row
+	ExpandedFilesystems map[FilesystemRow]bool
 }
 
 var validate = validator.New()
@@ -72,8 +88,10 @@ func (m *M) Update(p Params) {
 			j, ok := m.jobs[jobname]
 			if !ok {
 				j = &Job{
-					name:         jobname,
-					byteProgress: &bytesProgressHistory{},
+					name:              jobname,
+					byteProgress:      &bytesProgressHistory{},
+					peersByteProgress: make(map[string]*bytesProgressHistory),
+					fsByteProgress:    make(map[FilesystemRow]*bytesProgressHistory),
 				}
 				m.jobs[jobname] = j
 				m.jobsList = append(m.jobsList, j)
@@ -128,8 +146,18 @@ func (j *Job) updateFullDescription(p Params) {
 		IndentMultiplier: 3,
 		Width:            width,
 	})
-	drawJob(b, j.name, j.lastStatus, j.byteProgress, p.FSFilter)
+	rows := &fsRows{
+		job:          j.name,
+		nav:          p.FilesystemNavigation,
+		selected:     p.SelectedFilesystem,
+		expanded:     p.ExpandedFilesystems,
+		history:      j.fsByteProgress,
+		selectedLine: -1,
+	}
+	drawJob(b, j.name, j.lastStatus, j.byteProgress, j.peersByteProgress, p.FSFilter, rows)
 	j.fulldescription = b.String()
+	j.fsRows = rows.rows
+	j.selectedFSLine = rows.selectedLine
 }
 
 func (j *Job) JobTreeTitle() string {
@@ -140,15 +168,41 @@ func (j *Job) FullDescription() string {
 	return j.fulldescription
 }
 
+// FilesystemRows returns the replicated filesystems of FullDescription that pass Params.FSFilter, from top to bottom.
+func (j *Job) FilesystemRows() []FilesystemRow {
+	return j.fsRows
+}
+
+// SelectedFilesystemLine returns the line of Params.SelectedFilesystem in FullDescription, -1 if it is not drawn.
+func (j *Job) SelectedFilesystemLine() int {
+	return j.selectedFSLine
+}
+
 func (j *Job) Name() string {
 	return j.name
 }
 
-func drawJob(t *stringbuilder.B, name string, v *job.Status, history *bytesProgressHistory, fsfilter FilterFunc) {
+func drawJob(t *stringbuilder.B, name string, v *job.Status, history *bytesProgressHistory, peersHistory map[string]*bytesProgressHistory, fsfilter FilterFunc, rows *fsRows) {
 
 	t.Printf("Job: %s\n", name)
 	t.Printf("Type: %s\n\n", v.Type)
 
+	if v.Disabled {
+		t.Printf("Disabled: invocations are skipped until `zrepl job enable %s`\n\n", name)
+	}
+	if m := v.Maintenance; m.Active {
+		switch {
+		case m.Until.IsZero():
+			t.Printf("Maintenance: new invocations are skipped until `zrepl job maintenance end %s`\n\n", name)
+		case m.Scheduled:
+			t.Printf("Maintenance: new invocations are skipped until the maintenance window ends in %s (at %s)\n\n",
+				time.Until(m.Until).Round(time.Second), m.Until.Round(time.Second))
+		default:
+			t.Printf("Maintenance: new invocations are skipped for %s (until %s)\n\n",
+				time.Until(m.Until).Round(time.Second), m.Until.Round(time.Second))
+		}
+	}
+
 	if v.Type == job.TypePush || v.Type == job.TypePull {
 		activeStatus, ok := v.JobSpecific.(*job.ActiveSideStatus)
 		if !ok || activeStatus == nil {
@@ -157,9 +211,55 @@ func drawJob(t *stringbuilder.B, name string, v *job.Status, history *bytesProgr
 			return
 		}
 
+		if len(activeStatus.After) > 0 || len(activeStatus.Dependents) > 0 {
+			if len(activeStatus.After) > 0 {
+				t.Printf("Runs after: %s", strings.Join(activeStatus.After, ", "))
+				if len(activeStatus.WaitingFor) > 0 {
+					t.Printf(" (waiting for %s)", strings.Join(activeStatus.WaitingFor, ", "))
+				}
+				t.Newline()
+			}
+			if len(activeStatus.Dependents) > 0 {
+				t.Printf("Triggers: %s", strings.Join(activeStatus.Dependents, ", "))
+				t.Newline()
+			}
+			t.Newline()
+		}
+
+		renderPeerVersionSkew(t, activeStatus.Peers)
+
+		if cb := activeStatus.CircuitBreaker; cb.Suspended(time.Now()) {
+			until := cb.SuspendedUntil.Format("15:04")
+			if time.Until(cb.SuspendedUntil) > 24*time.Hour {
+				until = cb.SuspendedUntil.Format("2006-01-02 15:04")
+			}
+			t.Printf("Circuit Breaker: suspended until %s after %d consecutive failures (`zrepl signal reset %s` to resume now)",
+				until, cb.ConsecutiveFailures, name)
+			t.Newline()
+			t.Newline()
+		} else if cb != nil {
+			t.Printf("Circuit Breaker: %d of %d consecutive failures before the job is suspended", cb.ConsecutiveFailures, cb.Threshold)
+			t.Newline()
+			t.Newline()
+		}
+
+		if !activeStatus.WaitBlackoutUntil.IsZero() {
+			t.Printf("Blackout: waiting for window to end in %s (until %s)",
+				time.Until(activeStatus.WaitBlackoutUntil).Round(time.Second), activeStatus.WaitBlackoutUntil.Round(time.Second))
+			t.Newline()
+			t.Newline()
+		}
+
+		if !activeStatus.AbortedAt.IsZero() {
+			t.Printf("Aborted: at %s, interrupted steps resume in the next invocation (`zrepl signal wakeup %s` to start it now)",
+				activeStatus.AbortedAt.Format("2006-01-02 15:04:05"), name)
+			t.Newline()
+			t.Newline()
+		}
+
 		t.Printf("Replication:")
 		t.AddIndentAndNewline(1)
-		renderReplicationReport(t, activeStatus.Replication, history, fsfilter)
+		renderReplicationReport(t, activeStatus.Replication, activeStatus.FilesystemErrors, history, fsfilter, rows, "")
 		t.AddIndentAndNewline(-1)
 
 		t.Printf("Pruning Sender:")
@@ -172,6 +272,69 @@ func drawJob(t *stringbuilder.B, name string, v *job.Status, history *bytesProgr
 		renderPrunerReport(t, activeStatus.PruningReceiver, fsfilter)
 		t.AddIndentAndNewline(-1)
 
+		peerHistory := func(name string) *bytesProgressHistory {
+			h, ok := peersHistory[name]
+			if !ok {
+				h = &bytesProgressHistory{}
+				peersHistory[name] = h
+			}
+			return h
+		}
+
+		dstNames := make([]string, 0, len(activeStatus.Destinations))
+		for name := range activeStatus.Destinations {
+			dstNames = append(dstNames, name)
+		}
+		sort.Strings(dstNames)
+		for _, name := range dstNames {
+			dst := activeStatus.Destinations[name]
+			dstHistory := peerHistory(name)
+
+			t.Printf("Destination %q:", name)
+			t.AddIndentAndNewline(1)
+
+			t.Printf("Replication:")
+			t.AddIndentAndNewline(1)
+			renderReplicationReport(t, dst.Replication, dst.FilesystemErrors, dstHistory, fsfilter, rows, "destination "+name)
+			t.AddIndentAndNewline(-1)
+
+			t.Printf("Pruning Receiver:")
+			t.AddIndentAndNewline(1)
+			renderPrunerReport(t, dst.PruningReceiver, fsfilter)
+			t.AddIndentAndNewline(-1)
+
+			t.AddIndentAndNewline(-1)
+		}
+
+		srcNames := make([]string, 0, len(activeStatus.Sources))
+		for name := range activeStatus.Sources {
+			srcNames = append(srcNames, name)
+		}
+		sort.Strings(srcNames)
+		for _, name := range srcNames {
+			src := activeStatus.Sources[name]
+
+			t.Printf("Source %q:", name)
+			t.AddIndentAndNewline(1)
+
+			t.Printf("Replication:")
+			t.AddIndentAndNewline(1)
+			renderReplicationReport(t, src.Replication, src.FilesystemErrors, peerHistory(name), fsfilter, rows, "source "+name)
+			t.AddIndentAndNewline(-1)
+
+			t.Printf("Pruning Sender:")
+			t.AddIndentAndNewline(1)
+			renderPrunerReport(t, src.PruningSender, fsfilter)
+			t.AddIndentAndNewline(-1)
+
+			t.Printf("Pruning Receiver:")
+			t.AddIndentAndNewline(1)
+			renderPrunerReport(t, src.PruningReceiver, fsfilter)
+			t.AddIndentAndNewline(-1)
+
+			t.AddIndentAndNewline(-1)
+		}
+
 		if v.Type == job.TypePush {
 			t.Printf("Snapshotting:")
 			t.AddIndentAndNewline(1)
@@ -197,11 +360,26 @@ func drawJob(t *stringbuilder.B, name string, v *job.Status, history *bytesProgr
 	} else if v.Type == job.TypeSource {
 
 		st := v.JobSpecific.(*job.PassiveStatus)
+		renderPeerVersionSkew(t, st.Peers)
 		t.Printf("Snapshotting:\n")
 		t.AddIndent(1)
 		renderSnapperReport(t, st.Snapper, fsfilter)
 		t.AddIndentAndNewline(-1)
 
+	} else if v.Type == job.TypeSink {
+
+		st := v.JobSpecific.(*job.PassiveStatus)
+		renderPeerVersionSkew(t, st.Peers)
+		renderClientUsage(t, st.ClientUsage)
+
+	} else if v.Type == job.TypePoolMonitor {
+		poolStatus, ok := v.JobSpecific.(*job.PoolMonitorStatus)
+		if !ok || poolStatus == nil {
+			t.Printf("PoolMonitorStatus is null")
+			t.Newline()
+			return
+		}
+		renderPoolMonitorStatus(t, poolStatus)
 	} else {
 		t.Printf("No status representation for job type '%s', dumping as YAML", v.Type)
 		t.Newline()
@@ -216,7 +394,98 @@ func drawJob(t *stringbuilder.B, name string, v *job.Status, history *bytesProgr
 	}
 }
 
-func printFilesystemStatus(t *stringbuilder.B, rep *report.FilesystemReport, maxFS int) {
+func renderPoolMonitorStatus(t *stringbuilder.B, s *job.PoolMonitorStatus) {
+	if s.LastCollection.IsZero() {
+		t.Printf("Pools: not collected yet")
+		t.Newline()
+		return
+	}
+	t.Printf("Pools (collected at %s):", s.LastCollection.Format("2006-01-02 15:04:05"))
+	t.AddIndentAndNewline(1)
+	if s.Error != "" {
+		t.Printf("ERROR: %s", s.Error)
+		t.Newline()
+	}
+	for _, p := range s.Pools {
+		if p.Status == nil {
+			t.Printf("%s: ERROR: %s", p.Name, p.Error)
+			t.Newline()
+			continue
+		}
+		st := p.Status
+		t.Printf("%s: %s, %d%% used (%s of %s, %s free)", p.Name, st.Health, st.Capacity,
+			ByteCountBinaryUint(st.Allocated), ByteCountBinaryUint(st.Size), ByteCountBinaryUint(st.Free))
+		if st.Fragmentation >= 0 {
+			t.Printf(", %d%% fragmented", st.Fragmentation)
+		}
+		if sc := st.Scan; sc != nil {
+			at := "unknown time"
+			if !sc.Time.IsZero() {
+				at = sc.Time.Format("2006-01-02 15:04")
+			}
+			switch sc.State {
+			case "in_progress":
+				t.Printf(", %s in progress since %s, %.1f%% done", sc.Function, at, sc.Progress*100)
+			case "paused":
+				t.Printf(", %s paused since %s, %.1f%% done", sc.Function, at, sc.Progress*100)
+			case "finished":
+				t.Printf(", last %s finished at %s with %d error(s)", sc.Function, at, sc.Errors)
+			default:
+				t.Printf(", last %s %s at %s", sc.Function, sc.State, at)
+			}
+		}
+		t.Newline()
+	}
+	t.AddIndentAndNewline(-1)
+}
+
+func renderClientUsage(t *stringbuilder.B, s *job.ClientUsageStatus) {
+	if s == nil || s.LastCollection.IsZero() {
+		t.Printf("Client usage: not collected yet")
+		t.Newline()
+		return
+	}
+	t.Printf("Client usage (collected at %s):", s.LastCollection.Format("2006-01-02 15:04:05"))
+	t.AddIndentAndNewline(1)
+	if s.Error != "" {
+		t.Printf("ERROR: %s", s.Error)
+		t.Newline()
+	}
+	if len(s.Clients) == 0 {
+		t.Printf("no clients")
+		t.Newline()
+	}
+	for _, c := range s.Clients {
+		t.Printf("%s: %s used (%s by snapshots), %s logical referenced", c.ClientIdentity,
+			ByteCountBinaryUint(c.Used), ByteCountBinaryUint(c.UsedBySnapshots), ByteCountBinaryUint(c.LogicalReferenced))
+		t.Newline()
+	}
+	t.AddIndentAndNewline(-1)
+}
+
+// renderPeerVersionSkew renders the peers whose versions differ by more than `global.version_skew`.
+func renderPeerVersionSkew(t *stringbuilder.B, peers []versionhandshake.PeerReport) {
+	printed := false
+	for _, p := range peers {
+		if p.Skew == "" {
+			continue
+		}
+		peer := p.Peer
+		if peer == "" {
+			peer = "peer"
+		}
+		t.Printf("WARNING: %s: %s", peer, p.Skew)
+		t.Newline()
+		printed = true
+	}
+	if printed {
+		t.Newline()
+	}
+}
+
+func printFilesystemStatus(t *stringbuilder.B, rep *report.FilesystemReport, errs []*report.FilesystemError, maxFS int, rows *fsRows, section string) {
+	row := rows.row(section, rep.Info.Name)
+	cursor := rows.add(t, row)
 
 	expected, replicated, containsInvalidSizeEstimates := rep.BytesSum()
 	sizeEstimationImpreciseNotice := ""
@@ -252,7 +521,8 @@ func printFilesystemStatus(t *stringbuilder.B, rep *report.FilesystemReport, max
 		activeIndicator = "*"
 	}
 	t.AddIndent(1)
-	t.Printf("%s %s %s ",
+	t.Printf("%s%s %s %s ",
+		cursor,
 		activeIndicator,
 		stringbuilder.RightPad(rep.Info.Name, maxFS, " "),
 		status)
@@ -260,6 +530,10 @@ func printFilesystemStatus(t *stringbuilder.B, rep *report.FilesystemReport, max
 	next := ""
 	if err := rep.Error(); err != nil {
 		next = err.Err
+	} else if rep.BlockedOn == report.FsBlockedOnRetryWait {
+		next = fmt.Sprintf("waiting to retry (#%d) after error: %s", rep.Retries, rep.RetryError.Err)
+	} else if rep.BlockedOn == report.FsBlockedOnReconnect {
+		next = fmt.Sprintf("waiting for reconnect to retry (#%d) after error: %s", rep.Retries, rep.RetryError.Err)
 	} else if rep.State != report.FilesystemDone {
 		if nextStep := rep.NextStep(); nextStep != nil {
 			if nextStep.IsIncremental() {
@@ -270,7 +544,14 @@ func printFilesystemStatus(t *stringbuilder.B, rep *report.FilesystemReport, max
 			attribs := []string{}
 
 			if nextStep.Info.Resumed {
-				attribs = append(attribs, "resumed")
+				if nextStep.Info.BytesResumed > 0 {
+					attribs = append(attribs, fmt.Sprintf("resumed after %s", ByteCountBinaryUint(nextStep.Info.BytesResumed)))
+				} else {
+					attribs = append(attribs, "resumed")
+				}
+			}
+			if rep.Retries > 0 {
+				attribs = append(attribs, fmt.Sprintf("retry #%d", rep.Retries))
 			}
 
 			if len(attribs) > 0 {
@@ -283,11 +564,19 @@ func printFilesystemStatus(t *stringbuilder.B, rep *report.FilesystemReport, max
 	}
 	t.Printf("%s", next)
 
+	if rows.expanded[row] {
+		t.AddIndentAndNewline(1)
+		renderFilesystemDetails(t, rep, errs, rows.fsHistory(row))
+		t.AddIndent(-1)
+	}
+
 	t.AddIndent(-1)
 	t.Newline()
 }
 
-func renderReplicationReport(t *stringbuilder.B, rep *report.Report, history *bytesProgressHistory, fsfilter FilterFunc) {
+// section identifies the peer of rep in FilesystemRow.Section,
+// fsErrors is the error history of the filesystems, see job.ActiveSideStatus.FilesystemErrors
+func renderReplicationReport(t *stringbuilder.B, rep *report.Report, fsErrors map[string][]*report.FilesystemError, history *bytesProgressHistory, fsfilter FilterFunc, rows *fsRows, section string) {
 	if rep == nil {
 		t.Printf("...\n")
 		return
@@ -314,17 +603,31 @@ func renderReplicationReport(t *stringbuilder.B, rep *report.Report, history *by
 		t.Newline()
 	}
 
+	if !rep.WaitRetryUntil.IsZero() {
+		t.PrintfDrawIndentedAndWrappedIfMultiline("Retry: waiting %s before attempt #%d (until %s)",
+			time.Until(rep.WaitRetryUntil).Round(time.Second), len(rep.Attempts)+1, rep.WaitRetryUntil.Round(time.Second))
+		t.Newline()
+	}
+
 	// TODO visualize more than the latest attempt by folding all attempts into one
 	if len(rep.Attempts) == 0 {
 		t.Printf("no attempts made yet")
 		return
 	} else {
-		t.Printf("Attempt #%d", len(rep.Attempts))
+		if rep.MaxAttempts > 0 {
+			t.Printf("Attempt #%d/%d", len(rep.Attempts), rep.MaxAttempts)
+		} else {
+			t.Printf("Attempt #%d", len(rep.Attempts))
+		}
 		if len(rep.Attempts) > 1 {
 			t.Printf(". Previous attempts failed with the following statuses:")
 			t.AddIndentAndNewline(1)
 			for i, a := range rep.Attempts[:len(rep.Attempts)-1] {
-				t.PrintfDrawIndentedAndWrappedIfMultiline("#%d: %s (failed at %s) (ran %s)\n", i+1, a.State, a.FinishAt, a.FinishAt.Sub(a.StartAt))
+				errClass := ""
+				if a.ErrorClass != "" {
+					errClass = fmt.Sprintf(" (%s error)", a.ErrorClass)
+				}
+				t.PrintfDrawIndentedAndWrappedIfMultiline("#%d: %s%s (failed at %s) (ran %s)\n", i+1, a.State, errClass, a.FinishAt, a.FinishAt.Sub(a.StartAt))
 			}
 			t.AddIndentAndNewline(-1)
 		} else {
@@ -347,7 +650,14 @@ func renderReplicationReport(t *stringbuilder.B, rep *report.Report, history *by
 	}
 	latest.Filesystems = filtered
 
-	t.Printf("Status: %s", latest.State)
+	if latest.ErrorClass == report.AttemptErrorReceiverLowOnSpace {
+		t.Printf("Status: paused (receiving side is low on free space, replication resumes on the next invocation once space is freed up)")
+	} else {
+		t.Printf("Status: %s", latest.State)
+		if latest.ErrorClass != "" {
+			t.Printf(" (%s error)", latest.ErrorClass)
+		}
+	}
 	t.Newline()
 	if !latest.FinishAt.IsZero() {
 		t.Printf("Last Run: %s (lasted %s)\n", latest.FinishAt.Round(time.Second), latest.FinishAt.Sub(latest.StartAt).Round(time.Second))
@@ -400,7 +710,7 @@ func renderReplicationReport(t *stringbuilder.B, rep *report.Report, history *by
 			}
 		}
 		for _, fs := range latest.Filesystems {
-			printFilesystemStatus(t, fs, maxFSLen)
+			printFilesystemStatus(t, fs, fsErrors[fs.Info.Name], maxFSLen, rows, section)
 		}
 
 	}
@@ -485,10 +795,8 @@ func renderPrunerReport(t *stringbuilder.B, r *pruner.Report, fsfilter FilterFun
 	var totalDestroyCount, completedDestroyCount int
 	var maxFSname int
 	for _, fs := range all {
-		totalDestroyCount += len(fs.DestroyList)
-		if fs.completed {
-			completedDestroyCount += len(fs.DestroyList)
-		}
+		totalDestroyCount += len(fs.DestroyList) + len(fs.BookmarkDestroyList)
+		completedDestroyCount += fs.Destroyed + fs.DestroyFailed
 		if maxFSname < len(fs.Filesystem) {
 			maxFSname = len(fs.Filesystem)
 		}
@@ -503,7 +811,7 @@ func renderPrunerReport(t *stringbuilder.B, r *pruner.Report, fsfilter FilterFun
 		t.Write(">")
 		t.Write(stringbuilder.Times("-", 80-progress))
 		t.Write("]")
-		t.Printf(" %d/%d snapshots", completedDestroyCount, totalDestroyCount)
+		t.Printf(" %d/%d snapshots and bookmarks", completedDestroyCount, totalDestroyCount)
 		t.Newline()
 	}
 
@@ -530,14 +838,27 @@ func renderPrunerReport(t *stringbuilder.B, r *pruner.Report, fsfilter FilterFun
 			continue
 		}
 
-		pruneRuleActionStr := fmt.Sprintf("(destroy %d of %d snapshots)",
+		pruneRuleActionStr := fmt.Sprintf("destroy %d of %d snapshots",
 			len(fs.DestroyList), len(fs.SnapshotList))
+		for _, keptBy := range []string{pruner.KeptByCloneOrigin, pruner.KeptByMinAge, pruner.KeptByGracePeriod} {
+			if n := numKeptBy(fs.FSReport, keptBy); n > 0 {
+				pruneRuleActionStr += fmt.Sprintf(", %d %s", n, keptBy)
+			}
+		}
+		pruneRuleActionStr = "(" + pruneRuleActionStr + ")"
 
 		if fs.completed {
 			t.Printf("Completed  %s\n", pruneRuleActionStr)
 			continue
 		}
 
+		if fs.Executing {
+			total := len(fs.DestroyList) + len(fs.BookmarkDestroyList)
+			t.Printf("Executing  (destroyed %d, failed %d, pending %d of %d)\n",
+				fs.Destroyed, fs.DestroyFailed, total-fs.Destroyed-fs.DestroyFailed, total)
+			continue
+		}
+
 		t.Write("Pending    ") // whitespace is padding 10
 		if len(fs.DestroyList) == 1 {
 			t.Write(fs.DestroyList[0].Name)
@@ -549,6 +870,17 @@ func renderPrunerReport(t *stringbuilder.B, r *pruner.Report, fsfilter FilterFun
 
 }
 
+func numKeptBy(fs *pruner.FSReport, keptBy string) (n int) {
+	for _, s := range fs.SnapshotList {
+		for _, k := range s.KeptBy {
+			if k == keptBy {
+				n++
+			}
+		}
+	}
+	return n
+}
+
 func renderSnapperReport(t *stringbuilder.B, r *snapper.Report, fsfilter FilterFunc) {
 	if r == nil {
 		t.Printf("<no snapshotting report available>\n")
@@ -559,6 +891,13 @@ func renderSnapperReport(t *stringbuilder.B, r *snapper.Report, fsfilter FilterF
 		renderSnapperReportPeriodic(t, r.Periodic, fsfilter)
 	} else if r.Cron != nil {
 		renderSnapperReportCron(t, r.Cron, fsfilter)
+	} else if r.Multi != nil {
+		for i, sr := range r.Multi {
+			t.Printf("Schedule #%d:\n", i+1)
+			t.AddIndent(1)
+			renderSnapperReport(t, sr, fsfilter)
+			t.AddIndent(-1)
+		}
 	} else {
 		t.Printf("<no details available>")
 	}
@@ -630,6 +969,16 @@ func renderSnapperPlanReportFilesystem(t *stringbuilder.B, fss []*snapper.Report
 		case snapper.SnapError:
 			r.duration = dur(fs.DoneAt.Sub(fs.StartAt))
 			r.remainder = fmt.Sprintf("snap name: %q", fs.SnapName)
+		case snapper.SnapSkipped:
+			r.duration = ""
+			r.remainder = "unchanged since latest snapshot"
+			if fs.SnapName != "" {
+				r.duration = dur(fs.DoneAt.Sub(fs.StartAt))
+				r.remainder = fmt.Sprintf("snap name: %q skipped by hook", fs.SnapName)
+			}
+		case snapper.SnapAborted:
+			r.duration = ""
+			r.remainder = "aborted by a hook of another filesystem"
 		}
 		rows = append(rows, r)
 		if len(r.path) > widths.path {
@@ -36,6 +36,9 @@ func New(config Config) *B {
 
 func (b *B) String() string { return b.sb.String() }
 
+// Line returns the number of the line that is currently written, starting at 0.
+func (b *B) Line() int { return b.y }
+
 func (w *B) Newline() {
 	w.Write("\n")
 }
@@ -78,6 +81,7 @@ func (w *B) Write(s string) {
 			w.x = 0
 			fmt.Fprint(w.sb, Times(" ", w.indent-w.x))
 			w.x = w.indent
+			w.y++
 		}
 		fmt.Fprintf(w.sb, "%c", c)
 		w.x++
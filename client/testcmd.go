@@ -5,20 +5,27 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
 
 	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/client/status/viewmodel"
 	"github.com/zrepl/zrepl/config"
 	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/logging"
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/logger"
 	"github.com/zrepl/zrepl/zfs"
 )
 
 var TestCmd = &cli.Subcommand{
 	Use: "test",
 	SetupSubcommands: func() []*cli.Subcommand {
-		return []*cli.Subcommand{testFilter, testPlaceholder, testDecodeResumeToken}
+		return []*cli.Subcommand{testFilter, testPlaceholder, testDecodeResumeToken, testReplication, testPrune, testConnect}
 	},
 }
 
@@ -29,8 +36,9 @@ var testFilterArgs struct {
 }
 
 var testFilter = &cli.Subcommand{
-	Use:   "filesystems --job JOB [--all | --input INPUT]",
-	Short: "test filesystems filter specified in push or source job",
+	Use:           "filesystems --job JOB [--all | --input INPUT]",
+	CompleteFlags: map[string]cli.CompletionFunc{"job": cli.ConfigJobNames},
+	Short:         "test filesystems filter specified in push or source job",
 	SetupFlags: func(f *pflag.FlagSet) {
 		f.StringVar(&testFilterArgs.job, "job", "", "the name of the push or source job")
 		f.StringVar(&testFilterArgs.input, "input", "", "a filesystem name to test against the job's filters")
@@ -218,3 +226,198 @@ func runTestDecodeResumeTokenCmd(ctx context.Context, subcommand *cli.Subcommand
 	}
 	return nil
 }
+
+var testReplicationArgs struct {
+	verbose bool
+}
+
+var testReplication = &cli.Subcommand{
+	Use:          "replication JOB",
+	CompleteArgs: cli.CompleteNthArg(0, cli.ConfigJobNames),
+	Short:        "connect to the peer of push or pull job JOB and print the replication plan without replicating",
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.BoolVarP(&testReplicationArgs.verbose, "verbose", "v", false, "log debug messages to stderr")
+	},
+	Run: runTestReplicationCmd,
+}
+
+func runTestReplicationCmd(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("must specify exactly one job name as positional argument")
+	}
+	activeSide, err := activeSideForCLI(subcommand.Config(), args[0])
+	if err != nil {
+		return err
+	}
+	ctx = withCLILoggers(ctx, testReplicationArgs.verbose)
+
+	hadError := false
+	for _, plan := range activeSide.DryRunReplicationPlan(ctx) {
+		switch {
+		case plan.Destination != "":
+			fmt.Printf("DESTINATION %q\n", plan.Destination)
+		case plan.Source != "":
+			fmt.Printf("SOURCE %q\n", plan.Source)
+		default:
+			fmt.Printf("RECEIVER (connect)\n")
+		}
+		if plan.Error != nil {
+			hadError = true
+			fmt.Printf("\tERROR\t%s\n\n", plan.Error)
+			continue
+		}
+		var numSteps int
+		var bytesExpected uint64
+		for _, fs := range plan.Filesystems {
+			switch {
+			case fs.Error != nil:
+				hadError = true
+				fmt.Printf("\tERROR\t%s\t%s\n", fs.Filesystem, fs.Error)
+			case len(fs.Steps) == 0:
+				fmt.Printf("\tUPTODATE\t%s\n", fs.Filesystem)
+			default:
+				fmt.Printf("\tREPLICATE\t%s\n", fs.Filesystem)
+			}
+			for _, step := range fs.Steps {
+				from := step.From
+				if from == "" {
+					from = "full"
+				}
+				size := "unknown size"
+				if step.BytesExpected > 0 {
+					size = viewmodel.ByteCountBinaryUint(step.BytesExpected)
+				}
+				resumed := ""
+				if step.Resumed {
+					resumed = " (resumed)"
+				}
+				fmt.Printf("\t\t%s => %s\t%s%s\n", from, step.To, size, resumed)
+				numSteps++
+				bytesExpected += step.BytesExpected
+			}
+		}
+		fmt.Printf("\t%d filesystems, %d steps, %s estimated\n\n",
+			len(plan.Filesystems), numSteps, viewmodel.ByteCountBinaryUint(bytesExpected))
+	}
+
+	if hadError {
+		return fmt.Errorf("replication planning errors occurred")
+	}
+	return nil
+}
+
+var testPruneArgs struct {
+	verbose bool
+}
+
+var testPrune = &cli.Subcommand{
+	Use:          "prune JOB",
+	CompleteArgs: cli.CompleteNthArg(0, cli.ConfigJobNames),
+	Short:        "evaluate the keep rules of push or pull job JOB against the snapshots on sender and receiver without destroying any snapshots",
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.BoolVarP(&testPruneArgs.verbose, "verbose", "v", false, "log debug messages to stderr")
+	},
+	Run: runTestPruneCmd,
+}
+
+func runTestPruneCmd(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("must specify exactly one job name as positional argument")
+	}
+	activeSide, err := activeSideForCLI(subcommand.Config(), args[0])
+	if err != nil {
+		return err
+	}
+	ctx = withCLILoggers(ctx, testPruneArgs.verbose)
+
+	hadError := false
+	for _, plan := range activeSide.DryRunPrune(ctx) {
+		switch {
+		case plan.Destination != "":
+			fmt.Printf("%s OF DESTINATION %q\n", strings.ToUpper(plan.Side), plan.Destination)
+		case plan.Source != "":
+			fmt.Printf("%s OF SOURCE %q\n", strings.ToUpper(plan.Side), plan.Source)
+		default:
+			fmt.Printf("%s\n", strings.ToUpper(plan.Side))
+		}
+		rep := plan.Report
+		if rep.Error != "" {
+			hadError = true
+			fmt.Printf("\tERROR\t%s\n\n", rep.Error)
+			continue
+		}
+		var numDestroy, numDestroyBookmarks int
+		for _, fs := range rep.Completed {
+			switch {
+			case !fs.SkipReason.NotSkipped():
+				fmt.Printf("\tSKIP\t%s\t%s\n", fs.Filesystem, fs.SkipReason)
+				continue
+			case fs.LastError != "":
+				hadError = true
+				fmt.Printf("\tERROR\t%s\t%s\n", fs.Filesystem, fs.LastError)
+				continue
+			default:
+				fmt.Printf("\tPRUNE\t%s\n", fs.Filesystem)
+			}
+			numDestroy += printTestPruneVersions("@", fs.SnapshotList, fs.DestroyList)
+			numDestroyBookmarks += printTestPruneVersions("#", fs.BookmarkList, fs.BookmarkDestroyList)
+		}
+		fmt.Printf("\t%d filesystems, %d snapshots and %d bookmarks would be destroyed\n\n", len(rep.Completed), numDestroy, numDestroyBookmarks)
+	}
+
+	if hadError {
+		return fmt.Errorf("pruning planning errors occurred")
+	}
+	return nil
+}
+
+// Returns the number of versions that would be destroyed.
+func printTestPruneVersions(delim string, versions, destroyList []pruner.SnapshotReport) (numDestroy int) {
+	destroy := make(map[string]bool, len(destroyList))
+	for _, s := range destroyList {
+		destroy[s.Name] = true
+	}
+	for _, s := range versions {
+		date := s.Date.Format(time.RFC3339)
+		if destroy[s.Name] {
+			fmt.Printf("\t\tDESTROY\t%s%s\t%s\n", delim, s.Name, date)
+			numDestroy++
+		} else {
+			fmt.Printf("\t\tKEEP\t%s%s\t%s\t%s\n", delim, s.Name, date, strings.Join(s.KeptBy, ", "))
+		}
+	}
+	return numDestroy
+}
+
+// Builds push or pull job jobName for use outside of the daemon.
+func activeSideForCLI(conf *config.Config, jobName string) (*job.ActiveSide, error) {
+	confJob, err := conf.Job(jobName)
+	if err != nil {
+		return nil, err
+	}
+	switch confJob.Ret.(type) {
+	case *config.PushJob:
+	case *config.PullJob:
+	default:
+		return nil, fmt.Errorf("job %q is of type %T, must be a push or pull job", jobName, confJob.Ret)
+	}
+
+	jobs, err := job.JobsFromConfig(conf, config.ParseFlagsNone)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot build jobs from config")
+	}
+	for _, j := range jobs {
+		if j.Name() == jobName {
+			return j.(*job.ActiveSide), nil
+		}
+	}
+	panic("implementation error: job in config but not in built jobs")
+}
+
+func withCLILoggers(ctx context.Context, verbose bool) context.Context {
+	var log logger.Logger = logger.NewNullLogger()
+	if verbose {
+		log = logger.NewStderrDebugLogger()
+	}
+	return logging.WithLoggers(ctx, logging.SubsystemLoggersWithUniversalLogger(log))
+}
@@ -0,0 +1,323 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+	"github.com/zrepl/zrepl/rpc"
+	"github.com/zrepl/zrepl/rpc/versionhandshake"
+	"github.com/zrepl/zrepl/transport/fromconfig"
+)
+
+var testConnectArgs struct {
+	timeout time.Duration
+	pings   int
+	verbose bool
+}
+
+var testConnect = &cli.Subcommand{
+	Use:          "connect JOB",
+	CompleteArgs: cli.CompleteNthArg(0, cli.ConfigJobNames),
+	Short:        "dial the peers of push or pull job JOB and print transport, TLS, handshake, latency and the filesystems that the peers expose",
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.DurationVar(&testConnectArgs.timeout, "timeout", 30*time.Second, "give up on a peer after this duration")
+		f.IntVar(&testConnectArgs.pings, "pings", 5, "number of ping rpcs to measure the round-trip latency")
+		f.BoolVarP(&testConnectArgs.verbose, "verbose", "v", false, "log debug messages to stderr")
+	},
+	Run: runTestConnectCmd,
+}
+
+func runTestConnectCmd(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("must specify exactly one job name as positional argument")
+	}
+	if testConnectArgs.pings < 1 {
+		return fmt.Errorf("--pings must be at least 1")
+	}
+	conf := subcommand.Config()
+	peers, err := testConnectPeers(conf, args[0])
+	if err != nil {
+		return err
+	}
+	ctx = withCLILoggers(ctx, testConnectArgs.verbose)
+
+	hadError := false
+	for _, p := range peers {
+		r := testConnectPeer(ctx, conf.Global, p)
+		printTestConnect(os.Stdout, r)
+		hadError = hadError || r.err != nil
+	}
+	if hadError {
+		return fmt.Errorf("connecting to peers failed")
+	}
+	return nil
+}
+
+// testConnectPeerConfig is a peer of an active job.
+type testConnectPeerConfig struct {
+	// the heading in the output, like `zrepl test replication`
+	title   string
+	key     versionhandshake.PeerKey
+	connect config.ConnectEnum
+}
+
+func testConnectPeers(conf *config.Config, jobName string) ([]testConnectPeerConfig, error) {
+	confJob, err := conf.Job(jobName)
+	if err != nil {
+		return nil, err
+	}
+	var peers []testConnectPeerConfig
+	switch v := confJob.Ret.(type) {
+	case *config.PushJob:
+		peers = append(peers, testConnectPeerConfig{"RECEIVER (connect)", versionhandshake.PeerKey{Job: jobName}, v.Connect})
+		for _, d := range v.Destinations {
+			peers = append(peers, testConnectPeerConfig{fmt.Sprintf("DESTINATION %q", d.Name), versionhandshake.PeerKey{Job: jobName, Peer: d.Name}, d.Connect})
+		}
+	case *config.PullJob:
+		peers = append(peers, testConnectPeerConfig{"SENDER (connect)", versionhandshake.PeerKey{Job: jobName}, v.Connect})
+		for _, s := range v.Sources {
+			peers = append(peers, testConnectPeerConfig{fmt.Sprintf("SOURCE %q", s.Name), versionhandshake.PeerKey{Job: jobName, Peer: s.Name}, s.Connect})
+		}
+	default:
+		return nil, fmt.Errorf("job %q is of type %T, must be a push or pull job", jobName, confJob.Ret)
+	}
+	return peers, nil
+}
+
+// testConnectReport is the result of connecting to a peer.
+// If err is set, stage names the step that failed, the fields of later steps are unset.
+type testConnectReport struct {
+	title              string
+	transport, address string
+
+	// the version handshake includes the TLS handshake
+	dialed, handshake time.Duration
+	// nil if the transport does not use TLS
+	tls *tls.ConnectionState
+
+	peer *versionhandshake.PeerVersion
+	// nil if the peer does not announce its time
+	clockOffset *time.Duration
+
+	rtts        []time.Duration
+	filesystems []*pdu.Filesystem
+
+	stage string
+	err   error
+}
+
+func testConnectTransport(c config.ConnectEnum) (transport, address string) {
+	switch v := c.Ret.(type) {
+	case *config.TCPConnect:
+		return "tcp", v.Address
+	case *config.TLSConnect:
+		return "tls", v.Address
+	case *config.SSHStdinserverConnect:
+		return "ssh+stdinserver", fmt.Sprintf("%s@%s", v.User, net.JoinHostPort(v.Host, strconv.Itoa(int(v.Port))))
+	case *config.LocalConnect:
+		return "local", v.ListenerName
+	default:
+		return fmt.Sprintf("%T", v), ""
+	}
+}
+
+func testConnectPeer(ctx context.Context, g *config.Global, p testConnectPeerConfig) *testConnectReport {
+	r := &testConnectReport{title: p.title}
+	r.transport, r.address = testConnectTransport(p.connect)
+	fail := func(stage string, err error) *testConnectReport {
+		r.stage, r.err = stage, err
+		return r
+	}
+
+	if _, ok := p.connect.Ret.(*config.LocalConnect); ok {
+		return fail("dial", errors.New("the local transport connects to a listener within the daemon, it cannot be tested outside of it"))
+	}
+	connecter, err := fromconfig.ConnecterFromConfig(g, p.connect, config.ParseFlagsNone)
+	if err != nil {
+		return fail("config", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, testConnectArgs.timeout)
+	defer cancel()
+
+	// a separate connection for the details of transport and handshake, which the rpc client does not expose.
+	// The peer logs an error when it is closed after the handshake.
+	start := time.Now()
+	conn, err := connecter.Connect(ctx)
+	if err != nil {
+		return fail("dial", err)
+	}
+	r.dialed = time.Since(start)
+	deadline, _ := ctx.Deadline()
+	start = time.Now()
+	peer, err := versionhandshake.DoHandshakeCurrentVersion(conn, deadline)
+	handshakeDone := time.Now()
+	r.handshake = handshakeDone.Sub(start)
+	// the TLS handshake happens with the first write, i.e., in the version handshake
+	if c, ok := conn.(interface{ ConnectionState() tls.ConnectionState }); ok {
+		if st := c.ConnectionState(); st.HandshakeComplete {
+			r.tls = &st
+		}
+	}
+	conn.Close()
+	if err != nil {
+		return fail("handshake", err)
+	}
+	r.peer = peer
+	if !peer.Time.IsZero() {
+		offset := peer.Time.Sub(handshakeDone)
+		r.clockOffset = &offset
+	}
+
+	client := rpc.NewClient(connecter, rpc.GetLoggersOrPanic(ctx), p.key)
+	defer client.Close()
+	if err := client.WaitForConnectivity(ctx); err != nil {
+		return fail("rpc", err)
+	}
+	for i := 0; i < testConnectArgs.pings; i++ {
+		req := &pdu.PingReq{Message: uuid.New().String()}
+		start := time.Now()
+		res, err := client.Ping(ctx, req)
+		if err == nil && res.GetEcho() != req.GetMessage() {
+			err = errors.New("ping message not echoed correctly")
+		}
+		if err != nil {
+			return fail("ping", err)
+		}
+		r.rtts = append(r.rtts, time.Since(start))
+	}
+
+	fss, err := client.ListFilesystems(ctx, &pdu.ListFilesystemReq{})
+	if err != nil {
+		return fail("list filesystems", err)
+	}
+	r.filesystems = fss.GetFilesystems()
+	return r
+}
+
+var testConnectTLSVersions = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+var testConnectTLSCipherSuites = map[uint16]string{
+	tls.TLS_AES_128_GCM_SHA256:                        "TLS_AES_128_GCM_SHA256",
+	tls.TLS_AES_256_GCM_SHA384:                        "TLS_AES_256_GCM_SHA384",
+	tls.TLS_CHACHA20_POLY1305_SHA256:                  "TLS_CHACHA20_POLY1305_SHA256",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256:       "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384:       "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256: "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256:         "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384:         "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256:   "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+}
+
+func testConnectTLSDetails(st *tls.ConnectionState) string {
+	version, ok := testConnectTLSVersions[st.Version]
+	if !ok {
+		version = fmt.Sprintf("TLS version 0x%04x", st.Version)
+	}
+	suite, ok := testConnectTLSCipherSuites[st.CipherSuite]
+	if !ok {
+		suite = fmt.Sprintf("cipher suite 0x%04x", st.CipherSuite)
+	}
+	details := version + ", " + suite
+	if len(st.PeerCertificates) > 0 {
+		cert := st.PeerCertificates[0]
+		details += fmt.Sprintf(", server certificate %q issued by %q, expires %s",
+			cert.Subject.CommonName, cert.Issuer.CommonName, cert.NotAfter.UTC().Format(time.RFC3339))
+	}
+	return details
+}
+
+func testConnectRound(d time.Duration) time.Duration { return d.Round(10 * time.Microsecond) }
+
+func testConnectLatency(rtts []time.Duration) string {
+	min, max, sum := rtts[0], rtts[0], time.Duration(0)
+	for _, d := range rtts {
+		if d < min {
+			min = d
+		}
+		if d > max {
+			max = d
+		}
+		sum += d
+	}
+	r := testConnectRound
+	return fmt.Sprintf("min %s, avg %s, max %s (%d pings)", r(min), r(sum/time.Duration(len(rtts))), r(max), len(rtts))
+}
+
+func printTestConnect(w io.Writer, r *testConnectReport) {
+	fmt.Fprintf(w, "%s\n", r.title)
+	if r.address != "" {
+		fmt.Fprintf(w, "\tTRANSPORT\t%s %s", r.transport, r.address)
+	} else {
+		fmt.Fprintf(w, "\tTRANSPORT\t%s", r.transport)
+	}
+	if r.dialed > 0 {
+		fmt.Fprintf(w, ", connected in %s", testConnectRound(r.dialed))
+	}
+	if r.handshake > 0 {
+		fmt.Fprintf(w, ", handshake in %s", testConnectRound(r.handshake))
+	}
+	fmt.Fprintln(w)
+	if r.tls != nil {
+		fmt.Fprintf(w, "\tTLS\t%s\n", testConnectTLSDetails(r.tls))
+	}
+	if p := r.peer; p != nil {
+		v, zfsVersion := p.Version, p.ZFSVersion
+		if v == "" {
+			v = "unknown"
+		}
+		if zfsVersion == "" {
+			zfsVersion = "unknown"
+		}
+		offset := "unknown"
+		if r.clockOffset != nil {
+			offset = r.clockOffset.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(w, "\tHANDSHAKE\tprotocol %d, zrepl %s, ZFS %s, clock offset %s\n", p.ProtocolVersion, v, zfsVersion, offset)
+		if len(p.Features) > 0 {
+			fmt.Fprintf(w, "\tFEATURES\t%s\n", strings.Join(p.Features, ", "))
+		}
+	}
+	if len(r.rtts) > 0 {
+		fmt.Fprintf(w, "\tLATENCY\t%s\n", testConnectLatency(r.rtts))
+	}
+	if r.err != nil {
+		fmt.Fprintf(w, "\tERROR\t%s: %s\n\n", r.stage, r.err)
+		return
+	}
+	fmt.Fprintf(w, "\tFILESYSTEMS\t%d\n", len(r.filesystems))
+	for _, fs := range r.filesystems {
+		var notes []string
+		if fs.GetIsPlaceholder() {
+			notes = append(notes, "placeholder")
+		}
+		if fs.GetResumeToken() != "" {
+			notes = append(notes, "resumable")
+		}
+		if len(notes) > 0 {
+			fmt.Fprintf(w, "\t\t%s\t(%s)\n", fs.GetPath(), strings.Join(notes, ", "))
+		} else {
+			fmt.Fprintf(w, "\t\t%s\n", fs.GetPath())
+		}
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+	"github.com/zrepl/zrepl/rpc/versionhandshake"
+)
+
+func TestTestConnectPeers(t *testing.T) {
+	conf, err := config.ParseConfigBytes([]byte(`
+jobs:
+- name: push
+  type: push
+  connect:
+    type: tcp
+    address: "backup1:8888"
+  destinations:
+  - name: offsite
+    connect:
+      type: ssh+stdinserver
+      host: offsite.example.com
+      user: root
+      port: 22
+      identity_file: /etc/zrepl/ssh/identity
+  filesystems: {"pool<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep_sender:
+    - type: last_n
+      count: 10
+    keep_receiver:
+    - type: last_n
+      count: 10
+- name: sink
+  type: sink
+  root_fs: pool/sink
+  serve:
+    type: tcp
+    listen: ":8888"
+    clients: {"10.0.0.1": "prod1"}
+`))
+	require.NoError(t, err)
+
+	peers, err := testConnectPeers(conf, "push")
+	require.NoError(t, err)
+	require.Len(t, peers, 2)
+	assert.Equal(t, "RECEIVER (connect)", peers[0].title)
+	assert.Equal(t, versionhandshake.PeerKey{Job: "push"}, peers[0].key)
+	assert.Equal(t, `DESTINATION "offsite"`, peers[1].title)
+	assert.Equal(t, versionhandshake.PeerKey{Job: "push", Peer: "offsite"}, peers[1].key)
+
+	transport, address := testConnectTransport(peers[0].connect)
+	assert.Equal(t, "tcp", transport)
+	assert.Equal(t, "backup1:8888", address)
+	transport, address = testConnectTransport(peers[1].connect)
+	assert.Equal(t, "ssh+stdinserver", transport)
+	assert.Equal(t, "root@offsite.example.com:22", address)
+
+	_, err = testConnectPeers(conf, "sink")
+	assert.Error(t, err)
+	_, err = testConnectPeers(conf, "nonexistent")
+	assert.Error(t, err)
+}
+
+func TestPrintTestConnect(t *testing.T) {
+	offset := 1500 * time.Millisecond
+	r := &testConnectReport{
+		title:     "RECEIVER (connect)",
+		transport: "tls",
+		address:   "backup1:8888",
+		dialed:    3 * time.Millisecond,
+		handshake: 12 * time.Millisecond,
+		tls: &tls.ConnectionState{
+			Version:     tls.VersionTLS13,
+			CipherSuite: tls.TLS_AES_128_GCM_SHA256,
+			PeerCertificates: []*x509.Certificate{{
+				Subject:  pkix.Name{CommonName: "backup1"},
+				Issuer:   pkix.Name{CommonName: "zrepl CA"},
+				NotAfter: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			}},
+		},
+		peer: &versionhandshake.PeerVersion{
+			ProtocolVersion: 7,
+			Version:         "v0.7.0",
+			Features:        []string{"peer-version"},
+			ZFSVersion:      "zfs-2.1.5-1",
+		},
+		clockOffset: &offset,
+		rtts:        []time.Duration{400 * time.Microsecond, 500 * time.Microsecond, 900 * time.Microsecond},
+		filesystems: []*pdu.Filesystem{
+			{Path: "pool/sink/prod1"},
+			{Path: "pool/sink/prod1/data", ResumeToken: "1-abc"},
+			{Path: "pool/sink/prod1/placeholder", IsPlaceholder: true},
+		},
+	}
+	var buf bytes.Buffer
+	printTestConnect(&buf, r)
+	assert.Equal(t, `RECEIVER (connect)
+	TRANSPORT	tls backup1:8888, connected in 3ms, handshake in 12ms
+	TLS	TLS 1.3, TLS_AES_128_GCM_SHA256, server certificate "backup1" issued by "zrepl CA", expires 2025-01-01T00:00:00Z
+	HANDSHAKE	protocol 7, zrepl v0.7.0, ZFS zfs-2.1.5-1, clock offset 1.5s
+	FEATURES	peer-version
+	LATENCY	min 400µs, avg 600µs, max 900µs (3 pings)
+	FILESYSTEMS	3
+		pool/sink/prod1
+		pool/sink/prod1/data	(resumable)
+		pool/sink/prod1/placeholder	(placeholder)
+
+`, buf.String())
+
+	buf.Reset()
+	printTestConnect(&buf, &testConnectReport{
+		title:     `DESTINATION "offsite"`,
+		transport: "tcp",
+		address:   "offsite:8888",
+		stage:     "dial",
+		err:       errors.New("connection refused"),
+	})
+	assert.Equal(t, `DESTINATION "offsite"
+	TRANSPORT	tcp offsite:8888
+	ERROR	dial: connection refused
+
+`, buf.String())
+
+	buf.Reset()
+	printTestConnect(&buf, &testConnectReport{
+		title:     "SENDER (connect)",
+		transport: "tcp",
+		address:   "prod1:8888",
+		dialed:    time.Millisecond,
+		handshake: time.Millisecond,
+		peer:      &versionhandshake.PeerVersion{ProtocolVersion: 7},
+		stage:     "rpc",
+		err:       errors.New("control rpc failed to respond to ping rpcs"),
+	})
+	assert.Contains(t, buf.String(), "\tHANDSHAKE\tprotocol 7, zrepl unknown, ZFS unknown, clock offset unknown\n\tERROR\trpc: control rpc failed")
+}
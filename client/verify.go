@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+)
+
+var verifyArgs struct {
+	verbose bool
+}
+
+var VerifyCmd = &cli.Subcommand{
+	Use:          "verify JOB",
+	CompleteArgs: cli.CompleteNthArg(0, cli.ConfigJobNames),
+	Short:        "check that the snapshots replicated by push or pull job JOB are present and consistent on the receiving side",
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.BoolVarP(&verifyArgs.verbose, "verbose", "v", false, "log debug messages to stderr")
+	},
+	Run: runVerifyCmd,
+}
+
+func runVerifyCmd(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("must specify exactly one job name as positional argument")
+	}
+	activeSide, err := activeSideForCLI(subcommand.Config(), args[0])
+	if err != nil {
+		return err
+	}
+	ctx = withCLILoggers(ctx, verifyArgs.verbose)
+
+	hadError := false
+	for _, r := range activeSide.Verify(ctx) {
+		switch {
+		case r.Destination != "":
+			fmt.Printf("DESTINATION %q\n", r.Destination)
+		case r.Source != "":
+			fmt.Printf("SOURCE %q\n", r.Source)
+		default:
+			fmt.Printf("RECEIVER (connect)\n")
+		}
+		if r.Error != nil {
+			hadError = true
+			fmt.Printf("\tERROR\t%s\n\n", r.Error)
+			continue
+		}
+		numFailed := 0
+		for _, fs := range r.Filesystems {
+			switch {
+			case fs.Error != nil:
+				fmt.Printf("\tERROR\t%s\t%s\n", fs.Filesystem, fs.Error)
+			case fs.NotReceived:
+				fmt.Printf("\tNOTRECEIVED\t%s\n", fs.Filesystem)
+				continue
+			case fs.Result.OK():
+				fmt.Printf("\tOK\t%s\n", fs.Filesystem)
+			default:
+				fmt.Printf("\tFAIL\t%s\n", fs.Filesystem)
+			}
+			if !fs.OK() {
+				numFailed++
+			}
+			if fs.Result == nil {
+				continue
+			}
+			for _, v := range fs.Result.Missing {
+				fmt.Printf("\t\tmissing\t%s\t(guid %d, sender createtxg %d)\n", v.RelName(), v.GetGuid(), v.GetCreateTXG())
+			}
+			for _, d := range fs.Result.Diverged {
+				fmt.Printf("\t\tdiverged\t%s\t(sender guid %d, receiver guid %d)\n", d.Receiver.RelName(), d.Sender.GetGuid(), d.Receiver.GetGuid())
+			}
+			for _, v := range fs.Result.OutOfOrder {
+				fmt.Printf("\t\tout-of-order\t%s\t(guid %d, receiver createtxg %d)\n", v.RelName(), v.GetGuid(), v.GetCreateTXG())
+			}
+			for _, v := range fs.Result.Extra {
+				fmt.Printf("\t\textra\t%s\t(guid %d, not present on sender)\n", v.RelName(), v.GetGuid())
+			}
+			for _, v := range fs.Result.NotPresent {
+				fmt.Printf("\t\tnot-present\t%s\t(guid %d, not replicated or pruned on receiver)\n", v.RelName(), v.GetGuid())
+			}
+		}
+		if numFailed > 0 {
+			hadError = true
+		}
+		fmt.Printf("\t%d filesystems, %d failed verification\n\n", len(r.Filesystems), numFailed)
+	}
+
+	if hadError {
+		return fmt.Errorf("verification failed")
+	}
+	return nil
+}
@@ -4,17 +4,21 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/pflag"
 
 	"github.com/zrepl/zrepl/cli"
 	"github.com/zrepl/zrepl/config"
 	"github.com/zrepl/zrepl/daemon"
+	"github.com/zrepl/zrepl/rpc/versionhandshake"
 	"github.com/zrepl/zrepl/version"
 )
 
 var versionArgs struct {
 	Show      string
+	Peers     bool
 	Config    *config.Config
 	ConfigErr error
 }
@@ -25,6 +29,7 @@ var VersionCmd = &cli.Subcommand{
 	NoRequireConfig: true,
 	SetupFlags: func(f *pflag.FlagSet) {
 		f.StringVar(&versionArgs.Show, "show", "", "version info to show (client|daemon)")
+		f.BoolVar(&versionArgs.Peers, "peers", false, "show the versions of the peers that the daemon has connected to or that have connected to it")
 	},
 	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
 		versionArgs.Config = subcommand.Config()
@@ -39,6 +44,12 @@ func runVersionCmd() error {
 	if args.Show != "daemon" && args.Show != "client" && args.Show != "" {
 		return fmt.Errorf("show flag must be 'client' or 'server' or be left empty")
 	}
+	if args.Peers {
+		if args.Show != "" {
+			return fmt.Errorf("--peers and --show are mutually exclusive")
+		}
+		return runVersionPeers()
+	}
 
 	var clientVersion, daemonVersion *version.ZreplVersionInformation
 	if args.Show == "client" || args.Show == "" {
@@ -51,7 +62,7 @@ func runVersionCmd() error {
 			return fmt.Errorf("config parsing error: %s", args.ConfigErr)
 		}
 
-		httpc, err := controlHttpClient(args.Config.Global.Control.SockPath)
+		httpc, err := controlHttpClient(args.Config.Global.Control)
 		if err != nil {
 			return fmt.Errorf("server: error: %s\n", err)
 		}
@@ -73,3 +84,53 @@ func runVersionCmd() error {
 
 	return nil
 }
+
+func runVersionPeers() error {
+	args := versionArgs
+	if args.ConfigErr != nil {
+		return fmt.Errorf("config parsing error: %s", args.ConfigErr)
+	}
+	httpc, err := controlHttpClient(args.Config.Global.Control)
+	if err != nil {
+		return fmt.Errorf("server: error: %s\n", err)
+	}
+	var peers []versionhandshake.PeerReport
+	if err := jsonRequestResponse(httpc, daemon.ControlJobEndpointPeers, "", &peers); err != nil {
+		return fmt.Errorf("server: error: %s\n", err)
+	}
+
+	fmt.Printf("daemon: %s\n", version.NewZreplVersionInformation().Version)
+	if len(peers) == 0 {
+		fmt.Println("no peers seen since the daemon started")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "JOB\tPEER\tDIRECTION\tVERSION\tPROTOCOL\tLAST SEEN\tCLOCK OFFSET")
+	for _, p := range peers {
+		direction := "outgoing"
+		if p.Incoming {
+			direction = "incoming"
+		}
+		peer, v := p.Peer, p.Version
+		if peer == "" {
+			peer = "-"
+		}
+		if v == "" {
+			v = "unknown"
+		}
+		offset := "unknown"
+		if p.ClockOffset != nil {
+			offset = p.ClockOffset.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", p.Job, peer, direction, v, p.ProtocolVersion, p.LastSeen.Format(time.RFC3339), offset)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for _, p := range peers {
+		if p.Skew != "" {
+			fmt.Fprintf(os.Stderr, "WARNING: job %s peer %q: %s\n", p.Job, p.Peer, p.Skew)
+		}
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
 
@@ -35,6 +36,8 @@ type zabsFilterFlags struct {
 	Job         JobIDFlag
 	Types       AbstractionTypesFlag
 	Concurrency int64
+	// 0 means any age
+	OlderThan time.Duration
 }
 
 // produce a query from the CLI flags
@@ -62,6 +65,26 @@ func (f *zabsFilterFlags) registerZabsFilterFlags(s *pflag.FlagSet, verb string)
 	s.Var(&f.Types, "type", fmt.Sprintf("only %s holds of the specified type [default: all] [comma-separated list of %s]", verb, variantsJoined))
 
 	s.Int64VarP(&f.Concurrency, "concurrency", "p", 1, "number of concurrently queried filesystems")
+	s.DurationVar(&f.OlderThan, "older-than", 0, fmt.Sprintf("only %s holds and bookmarks whose snapshot or bookmark was created more than this duration ago (e.g. 720h) [default: any age]", verb))
+}
+
+// matchesAge returns true if a is older than f.OlderThan at now.
+// The age of a hold is the age of the held snapshot, ZFS does not record when a hold was created.
+func (f zabsFilterFlags) matchesAge(a endpoint.Abstraction, now time.Time) bool {
+	if f.OlderThan <= 0 {
+		return true
+	}
+	return a.GetFilesystemVersion().Creation.Before(now.Add(-f.OlderThan))
+}
+
+func (f zabsFilterFlags) filterAge(abs []endpoint.Abstraction, now time.Time) []endpoint.Abstraction {
+	filtered := make([]endpoint.Abstraction, 0, len(abs))
+	for _, a := range abs {
+		if f.matchesAge(a, now) {
+			filtered = append(filtered, a)
+		}
+	}
+	return filtered
 }
 
 type JobIDFlag struct{ J *endpoint.JobID }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/pkg/errors"
@@ -25,6 +26,7 @@ var zabsCmdList = &cli.Subcommand{
 	Use:             "list",
 	Short:           `list zrepl ZFS abstractions`,
 	Run:             doZabsList,
+	CompleteFlags:   map[string]cli.CompletionFunc{"job": cli.ConfigJobNames},
 	NoRequireConfig: true,
 	SetupFlags: func(f *pflag.FlagSet) {
 		zabsListFlags.Filter.registerZabsFilterFlags(f, "list")
@@ -59,7 +61,11 @@ func doZabsList(ctx context.Context, sc *cli.Subcommand, args []string) error {
 	go func() {
 		defer wg.Done()
 		enc := json.NewEncoder(os.Stdout)
+		now := time.Now()
 		for a := range abstractions {
+			if !zabsListFlags.Filter.matchesAge(a, now) {
+				continue
+			}
 			func() {
 				defer line.Lock().Unlock()
 				if zabsListFlags.Json {
@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
 	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
 
@@ -16,15 +18,19 @@ import (
 
 // shared between release-all and release-step
 var zabsReleaseFlags struct {
-	Filter zabsFilterFlags
-	Json   bool
-	DryRun bool
+	Filter   zabsFilterFlags
+	Json     bool
+	DryRun   bool
+	Yes      bool
+	AuditLog string
 }
 
 func registerZabsReleaseFlags(s *pflag.FlagSet) {
 	zabsReleaseFlags.Filter.registerZabsFilterFlags(s, "release")
 	s.BoolVar(&zabsReleaseFlags.Json, "json", false, "emit json instead of pretty-printed")
-	s.BoolVar(&zabsReleaseFlags.DryRun, "dry-run", false, "do a dry-run")
+	s.BoolVar(&zabsReleaseFlags.DryRun, "dry-run", false, "do a dry-run: only show what releasing the abstractions enables")
+	s.BoolVarP(&zabsReleaseFlags.Yes, "yes", "y", false, "do not ask for confirmation (required if stdin is not a terminal)")
+	s.StringVar(&zabsReleaseFlags.AuditLog, "audit-log", zabsAuditLogSyslog, fmt.Sprintf("record each release in the audit log: %q or the path of a file to append JSON lines to", zabsAuditLogSyslog))
 }
 
 var zabsCmdReleaseAll = &cli.Subcommand{
@@ -33,6 +39,7 @@ var zabsCmdReleaseAll = &cli.Subcommand{
 	NoRequireConfig: true,
 	Short:           `(DANGEROUS) release ALL zrepl ZFS abstractions (mostly useful for uninstalling zrepl completely or for "de-zrepl-ing" a filesystem)`,
 	SetupFlags:      registerZabsReleaseFlags,
+	CompleteFlags:   map[string]cli.CompletionFunc{"job": cli.ConfigJobNames},
 }
 
 var zabsCmdReleaseStale = &cli.Subcommand{
@@ -41,6 +48,7 @@ var zabsCmdReleaseStale = &cli.Subcommand{
 	NoRequireConfig: true,
 	Short:           `release stale zrepl ZFS abstractions (useful if zrepl has a bug and does not do it by itself)`,
 	SetupFlags:      registerZabsReleaseFlags,
+	CompleteFlags:   map[string]cli.CompletionFunc{"job": cli.ConfigJobNames},
 }
 
 func doZabsReleaseAll(ctx context.Context, sc *cli.Subcommand, args []string) error {
@@ -64,7 +72,7 @@ func doZabsReleaseAll(ctx context.Context, sc *cli.Subcommand, args []string) er
 		// proceed anyways with rest of abstractions
 	}
 
-	return doZabsRelease_Common(ctx, abstractions)
+	return doZabsRelease_Common(ctx, sc, zabsReleaseFlags.Filter.filterAge(abstractions, time.Now()))
 }
 
 func doZabsReleaseStale(ctx context.Context, sc *cli.Subcommand, args []string) error {
@@ -85,14 +93,15 @@ func doZabsReleaseStale(ctx context.Context, sc *cli.Subcommand, args []string)
 		return err // context clear by invocation of command
 	}
 
-	return doZabsRelease_Common(ctx, stalenessInfo.Stale)
+	return doZabsRelease_Common(ctx, sc, zabsReleaseFlags.Filter.filterAge(stalenessInfo.Stale, time.Now()))
 }
 
-func doZabsRelease_Common(ctx context.Context, destroy []endpoint.Abstraction) error {
+func doZabsRelease_Common(ctx context.Context, sc *cli.Subcommand, destroy []endpoint.Abstraction) error {
 
+	preview := zabsReleasePreview(destroy)
 	if zabsReleaseFlags.DryRun {
 		if zabsReleaseFlags.Json {
-			m, err := json.MarshalIndent(destroy, "", "  ")
+			m, err := json.MarshalIndent(preview, "", "  ")
 			if err != nil {
 				panic(err)
 			}
@@ -101,14 +110,38 @@ func doZabsRelease_Common(ctx context.Context, destroy []endpoint.Abstraction) e
 			}
 			fmt.Println()
 		} else {
-			for _, a := range destroy {
-				fmt.Printf("would destroy %s\n", a)
-			}
+			printZabsReleasePreview(os.Stdout, preview)
 		}
 		return nil
 	}
 
+	if len(destroy) == 0 {
+		fmt.Fprintln(os.Stderr, "no abstractions to release")
+		return nil
+	}
+
+	if !zabsReleaseFlags.Yes {
+		if !isatty.IsTerminal(os.Stdin.Fd()) {
+			return errors.New("stdin is not a terminal, use --yes to release without confirmation")
+		}
+		printZabsReleasePreview(os.Stderr, preview)
+		ok, err := zabsConfirm(os.Stdin, os.Stderr, fmt.Sprintf("release these %d abstraction(s)?", len(destroy)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("aborted")
+		}
+	}
+
+	auditLog, err := openZabsAuditLog(zabsReleaseFlags.AuditLog)
+	if err != nil {
+		return err
+	}
+	defer auditLog.Close()
+
 	outcome := endpoint.BatchDestroy(ctx, destroy)
+	auditUser := zabsAuditUser()
 	hadErr := false
 
 	enc := json.NewEncoder(os.Stdout)
@@ -119,6 +152,19 @@ func doZabsRelease_Common(ctx context.Context, destroy []endpoint.Abstraction) e
 
 	for res := range outcome {
 		hadErr = hadErr || res.DestroyErr != nil
+		entry := zabsAuditEntry{
+			Time:        time.Now(),
+			User:        auditUser,
+			Command:     sc.Use,
+			Abstraction: endpoint.AbstractionJSON{Abstraction: res.Abstraction},
+		}
+		if res.DestroyErr != nil {
+			entry.Error = res.DestroyErr.Error()
+		}
+		if err := auditLog.Log(entry); err != nil {
+			colorErr.Fprintf(os.Stderr, "cannot write audit log entry for %s: %s\n", res.Abstraction, err)
+			hadErr = true
+		}
 		if zabsReleaseFlags.Json {
 			err := enc.Encode(res)
 			if err != nil {
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/syslog"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/endpoint"
+)
+
+// zabsReleaseEffect describes what releasing an abstraction enables.
+type zabsReleaseEffect struct {
+	Abstraction endpoint.AbstractionJSON
+	Effect      string
+}
+
+// zabsReleasePreview returns the effect of releasing each of abs, in the same order.
+// The holds on a snapshot are considered together: the snapshot can only be pruned
+// once all of its holds are released, including holds that are not managed by zrepl.
+func zabsReleasePreview(abs []endpoint.Abstraction) []zabsReleaseEffect {
+	releasedHolds := make(map[string]uint64) // by snapshot
+	for _, a := range abs {
+		if isZabsHold(a) {
+			releasedHolds[a.GetFullPath()]++
+		}
+	}
+	effects := make([]zabsReleaseEffect, len(abs))
+	for i, a := range abs {
+		effects[i] = zabsReleaseEffect{endpoint.AbstractionJSON{Abstraction: a}, zabsReleaseEffectString(a, releasedHolds)}
+	}
+	return effects
+}
+
+func isZabsHold(a endpoint.Abstraction) bool {
+	switch a.GetType() {
+	case endpoint.AbstractionStepHold, endpoint.AbstractionLastReceivedHold:
+		return true
+	default:
+		return false
+	}
+}
+
+func zabsReleaseEffectString(a endpoint.Abstraction, releasedHolds map[string]uint64) string {
+	switch a.GetType() {
+	case endpoint.AbstractionStepHold, endpoint.AbstractionLastReceivedHold:
+		var consequence string
+		if a.GetType() == endpoint.AbstractionStepHold {
+			consequence = "an interrupted replication step that uses it cannot be resumed"
+		} else {
+			consequence = "the next replication to this filesystem may not be incremental if the snapshot is destroyed"
+		}
+		refs := a.GetFilesystemVersion().UserRefs
+		if !refs.Valid {
+			return fmt.Sprintf("%s; unknown whether other holds keep %s", consequence, a.GetFullPath())
+		}
+		released := releasedHolds[a.GetFullPath()]
+		if refs.Value <= released {
+			return fmt.Sprintf("%s; %s is no longer held and can be destroyed by pruning", consequence, a.GetFullPath())
+		}
+		return fmt.Sprintf("%s; %s remains held by %d other hold(s)", consequence, a.GetFullPath(), refs.Value-released)
+	case endpoint.AbstractionReplicationCursorBookmarkV1, endpoint.AbstractionReplicationCursorBookmarkV2:
+		return "the sending side's pruning has no replication cursor for this filesystem and fails until the next successful replication"
+	case endpoint.AbstractionTentativeReplicationCursorBookmark:
+		return "an interrupted replication step may no longer be able to move the replication cursor"
+	default:
+		return "unknown abstraction type"
+	}
+}
+
+func printZabsReleasePreview(out io.Writer, effects []zabsReleaseEffect) {
+	for _, e := range effects {
+		fmt.Fprintf(out, "would release %s\n    => %s\n", e.Abstraction.Abstraction, e.Effect)
+	}
+}
+
+// zabsConfirm asks the user on out whether to proceed and reads the answer from in.
+func zabsConfirm(in io.Reader, out io.Writer, prompt string) (bool, error) {
+	fmt.Fprintf(out, "%s [y/N] ", prompt)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, errors.Wrap(err, "read answer")
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// zabsAuditEntry records the release of an abstraction in the audit log.
+type zabsAuditEntry struct {
+	Time        time.Time
+	User        string
+	Command     string
+	Abstraction endpoint.AbstractionJSON
+	// empty if the release succeeded
+	Error string
+}
+
+type zabsAuditLog interface {
+	Log(e zabsAuditEntry) error
+	Close() error
+}
+
+const zabsAuditLogSyslog = "syslog"
+
+// openZabsAuditLog opens the audit log at dest, which is either zabsAuditLogSyslog or the path of a file to append to.
+func openZabsAuditLog(dest string) (zabsAuditLog, error) {
+	if dest == zabsAuditLogSyslog {
+		w, err := syslog.New(syslog.LOG_NOTICE|syslog.LOG_AUTH, "zrepl-zfs-abstraction")
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot connect to syslog (use --audit-log PATH to log to a file)")
+		}
+		return &zabsAuditLogWriter{w: w, c: w}, nil
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open audit log")
+	}
+	return &zabsAuditLogWriter{w: f, c: f}, nil
+}
+
+// writes one JSON object per line
+type zabsAuditLogWriter struct {
+	w io.Writer
+	c io.Closer
+}
+
+func (l *zabsAuditLogWriter) Log(e zabsAuditEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = l.w.Write(append(b, '\n'))
+	return err
+}
+
+func (l *zabsAuditLogWriter) Close() error { return l.c.Close() }
+
+func zabsAuditUser() string {
+	u, err := user.Current()
+	if err != nil {
+		return fmt.Sprintf("uid=%d", os.Getuid())
+	}
+	if sudoUser := os.Getenv("SUDO_USER"); sudoUser != "" {
+		return fmt.Sprintf("%s (sudo by %s)", u.Username, sudoUser)
+	}
+	return u.Username
+}
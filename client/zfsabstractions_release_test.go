@@ -0,0 +1,110 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+type fakeAbstraction struct {
+	typ      endpoint.AbstractionType
+	fs, name string
+	fsv      zfs.FilesystemVersion
+}
+
+func (a fakeAbstraction) GetType() endpoint.AbstractionType { return a.typ }
+func (a fakeAbstraction) GetFS() string                     { return a.fs }
+func (a fakeAbstraction) GetName() string                   { return a.name }
+func (a fakeAbstraction) GetFullPath() string {
+	if a.typ == endpoint.AbstractionStepHold || a.typ == endpoint.AbstractionLastReceivedHold {
+		return a.fs + "@" + a.name
+	}
+	return a.fs + "#" + a.name
+}
+func (a fakeAbstraction) GetJobID() *endpoint.JobID                   { return nil }
+func (a fakeAbstraction) GetCreateTXG() uint64                        { return a.fsv.CreateTXG }
+func (a fakeAbstraction) GetFilesystemVersion() zfs.FilesystemVersion { return a.fsv }
+func (a fakeAbstraction) String() string                              { return string(a.typ) + " " + a.GetFullPath() }
+func (a fakeAbstraction) Destroy(context.Context) error               { return nil }
+func (a fakeAbstraction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(endpoint.AbstractionJSON{Abstraction: a})
+}
+
+func TestZabsReleasePreview(t *testing.T) {
+	refs := func(n uint64) zfs.FilesystemVersion {
+		return zfs.FilesystemVersion{UserRefs: zfs.OptionUint64{Valid: true, Value: n}}
+	}
+	abs := []endpoint.Abstraction{
+		// both holds of @a are released
+		fakeAbstraction{typ: endpoint.AbstractionStepHold, fs: "pool/fs", name: "a", fsv: refs(2)},
+		fakeAbstraction{typ: endpoint.AbstractionLastReceivedHold, fs: "pool/fs", name: "a", fsv: refs(2)},
+		// @b has a hold that is not released
+		fakeAbstraction{typ: endpoint.AbstractionStepHold, fs: "pool/fs", name: "b", fsv: refs(2)},
+		fakeAbstraction{typ: endpoint.AbstractionReplicationCursorBookmarkV2, fs: "pool/fs", name: "cursor"},
+	}
+	effects := zabsReleasePreview(abs)
+	require.Len(t, effects, len(abs))
+	assert.Contains(t, effects[0].Effect, "pool/fs@a is no longer held and can be destroyed by pruning")
+	assert.Contains(t, effects[1].Effect, "pool/fs@a is no longer held and can be destroyed by pruning")
+	assert.Contains(t, effects[2].Effect, "pool/fs@b remains held by 1 other hold(s)")
+	assert.Contains(t, effects[3].Effect, "replication cursor")
+}
+
+func TestZabsFilterAge(t *testing.T) {
+	now := time.Date(2022, 7, 23, 14, 0, 0, 0, time.UTC)
+	old := fakeAbstraction{typ: endpoint.AbstractionStepHold, name: "old", fsv: zfs.FilesystemVersion{Creation: now.Add(-48 * time.Hour)}}
+	young := fakeAbstraction{typ: endpoint.AbstractionStepHold, name: "young", fsv: zfs.FilesystemVersion{Creation: now.Add(-time.Hour)}}
+	abs := []endpoint.Abstraction{old, young}
+
+	assert.Equal(t, abs, zabsFilterFlags{}.filterAge(abs, now))
+	assert.Equal(t, []endpoint.Abstraction{old}, zabsFilterFlags{OlderThan: 24 * time.Hour}.filterAge(abs, now))
+}
+
+func TestZabsConfirm(t *testing.T) {
+	for answer, expect := range map[string]bool{"y\n": true, "YES\n": true, "n\n": false, "\n": false, "": false} {
+		var out bytes.Buffer
+		ok, err := zabsConfirm(strings.NewReader(answer), &out, "release?")
+		require.NoError(t, err)
+		assert.Equal(t, expect, ok, "%q", answer)
+		assert.Equal(t, "release? [y/N] ", out.String())
+	}
+}
+
+func TestZabsAuditLogFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-zabs-audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	a := fakeAbstraction{typ: endpoint.AbstractionStepHold, fs: "pool/fs", name: "a"}
+	for i := 0; i < 2; i++ {
+		l, err := openZabsAuditLog(path)
+		require.NoError(t, err)
+		require.NoError(t, l.Log(zabsAuditEntry{User: "root", Command: "release-stale", Abstraction: endpoint.AbstractionJSON{Abstraction: a}}))
+		require.NoError(t, l.Close())
+	}
+
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 2)
+	var e struct {
+		User        string
+		Abstraction struct{ FullPath string }
+	}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &e))
+	assert.Equal(t, "root", e.User)
+	assert.Equal(t, "pool/fs@a", e.Abstraction.FullPath)
+}
@@ -0,0 +1,234 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/logging"
+	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/zfs"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+	"github.com/zrepl/zrepl/zfs/zfshelper"
+)
+
+var ZFSHelperCmd = &cli.Subcommand{
+	Use:   "zfs-helper",
+	Short: "privileged helper that executes zfs and zpool for an unprivileged daemon (global.zfs_helper)",
+	SetupSubcommands: func() []*cli.Subcommand {
+		return []*cli.Subcommand{
+			zfsHelperServeCmd,
+			zfsHelperExecCmd,
+		}
+	},
+}
+
+var zfsHelperServeCmd = &cli.Subcommand{
+	Use:   "serve",
+	Short: "run the helper (as root)",
+	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+		return runZFSHelperServe(ctx, subcommand)
+	},
+}
+
+func runZFSHelperServe(ctx context.Context, subcommand *cli.Subcommand) error {
+	conf := subcommand.Config()
+	if conf.Global.ZFSHelper == nil {
+		return errors.New("`global.zfs_helper` is not configured")
+	}
+
+	outlets, err := logging.OutletsFromConfig(*conf.Global.Logging)
+	if err != nil {
+		return fmt.Errorf("cannot build logging from config: %s", err)
+	}
+	log := logger.NewLogger(outlets, 1*time.Second).WithField("subsystem", "zfs-helper")
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		cancel()
+	}()
+
+	policy, err := zfsHelperPolicyFromConfig(conf)
+	if err != nil {
+		return fmt.Errorf("cannot build policy from config: %s", err)
+	}
+	server, err := zfshelper.NewServer(log, policy)
+	if err != nil {
+		return err
+	}
+	l, err := zfshelper.Listen(conf.Global.ZFSHelper.SockPath, conf.Global.ZFSHelper.SocketGroup)
+	if err != nil {
+		return fmt.Errorf("cannot listen: %s", err)
+	}
+	log.WithField("sockpath", conf.Global.ZFSHelper.SockPath).Info("zfs helper listening")
+	return server.Serve(ctx, l)
+}
+
+// zfsHelperPolicyFromConfig permits the datasets, properties and cgroups of conf's jobs.
+func zfsHelperPolicyFromConfig(conf *config.Config) (*zfshelper.Policy, error) {
+	p := &zfshelper.Policy{
+		Properties: make(map[string]map[string]bool),
+		Cgroups:    make(map[string]zfshelper.CgroupWeights),
+	}
+	// without values, the property may only be inherited
+	allowProperty := func(prop string, values ...string) {
+		allowed, ok := p.Properties[prop]
+		if ok && allowed == nil {
+			return // any value
+		}
+		if allowed == nil {
+			allowed = make(map[string]bool, len(values))
+			p.Properties[prop] = allowed
+		}
+		for _, v := range values {
+			allowed[v] = true
+		}
+	}
+	allowPropertyAnyValue := func(prop string) {
+		p.Properties[prop] = nil
+	}
+	addFilter := func(in config.FilesystemsFilter) error {
+		f, err := filters.DatasetMapFilterFromConfig(in)
+		if err != nil {
+			return errors.Wrap(err, "invalid filesystems filter")
+		}
+		p.Filters = append(p.Filters, f)
+		return nil
+	}
+	addRoot := func(rootFS string, recv *config.RecvOptions) error {
+		root, err := zfs.NewDatasetPath(rootFS)
+		if err != nil {
+			return errors.Wrap(err, "invalid root_fs")
+		}
+		p.Roots = append(p.Roots, root)
+		if recv.ForceReceive != "never" {
+			p.RollbackRoots = append(p.RollbackRoots, root) // zfs rollback -r before a forced receive
+		}
+
+		if recv.Placeholder.Properties == nil {
+			for prop, value := range zfs.PlaceholderDefaultProperties {
+				allowProperty(prop, value)
+			}
+		}
+		for prop, value := range recv.Placeholder.Properties {
+			allowProperty(string(prop), value)
+		}
+		// placeholders with encryption off, encrypt_on_receive
+		allowProperty("encryption", "off", "on")
+		for prop, value := range recv.Properties.Override {
+			allowProperty(string(prop), value)
+		}
+		for _, prop := range recv.Properties.Inherit {
+			allowProperty(string(prop))
+		}
+		if recv.BrowseMounts != nil {
+			p.MountpointPrefixes = append(p.MountpointPrefixes, recv.BrowseMounts.Prefix)
+			allowProperty("readonly", "on")
+		}
+		return nil
+	}
+	addJob := func(j config.JobEnum) error {
+		var limits *config.ProcessLimits
+		switch v := j.Ret.(type) {
+		case *config.PushJob:
+			limits = v.ProcessLimits
+			if err := addFilter(v.Filesystems); err != nil {
+				return err
+			}
+		case *config.SourceJob:
+			limits = v.ProcessLimits
+			if err := addFilter(v.Filesystems); err != nil {
+				return err
+			}
+		case *config.SnapJob:
+			limits = v.ProcessLimits
+			if err := addFilter(v.Filesystems); err != nil {
+				return err
+			}
+		case *config.SinkJob:
+			limits = v.ProcessLimits
+			if err := addRoot(v.RootFS, v.Recv); err != nil {
+				return err
+			}
+			if v.ClientQuotas != nil {
+				for _, prop := range []string{"quota", "reservation"} {
+					allowPropertyAnyValue(prop)
+				}
+			}
+		case *config.PullJob:
+			limits = v.ProcessLimits
+			if err := addRoot(v.RootFS, v.Recv); err != nil {
+				return err
+			}
+			for _, s := range v.Sources {
+				if err := addRoot(s.RootFS, v.Recv); err != nil {
+					return errors.Wrapf(err, "source %q", s.Name)
+				}
+			}
+		}
+		l, err := job.ProcessLimitsFromConfig(limits)
+		if err != nil {
+			return errors.Wrap(err, "invalid process limits")
+		}
+		if l != nil && l.Cgroup != "" {
+			p.Cgroups[l.Cgroup] = zfshelper.CgroupWeights{CPUWeight: l.CPUWeight, IOWeight: l.IOWeight}
+		}
+		return nil
+	}
+
+	for _, j := range conf.Jobs {
+		if err := addJob(j); err != nil {
+			return nil, errors.Wrapf(err, "job %q", j.Name())
+		}
+	}
+	return p, nil
+}
+
+var zfsHelperExecArgs struct {
+	sockpath string
+	limits   string
+}
+
+var zfsHelperExecCmd = &cli.Subcommand{
+	Use:             "exec --sockpath PATH [--limits JSON] -- zfs|zpool ARGS...",
+	Short:           "execute a command through the helper (used by the daemon instead of zfs and zpool)",
+	NoRequireConfig: true,
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.StringVar(&zfsHelperExecArgs.sockpath, "sockpath", "", "socket of the helper")
+		f.StringVar(&zfsHelperExecArgs.limits, "limits", "", "JSON-encoded process limits")
+	},
+	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+		if zfsHelperExecArgs.sockpath == "" {
+			return errors.New("--sockpath is required")
+		}
+		var limits *zfscmd.ProcessLimits
+		if zfsHelperExecArgs.limits != "" {
+			limits = &zfscmd.ProcessLimits{}
+			if err := json.Unmarshal([]byte(zfsHelperExecArgs.limits), limits); err != nil {
+				return fmt.Errorf("invalid --limits: %s", err)
+			}
+		}
+		code, err := zfshelper.Exec(zfsHelperExecArgs.sockpath, limits, args)
+		if err != nil {
+			return err
+		}
+		// exit with the command's exit code, without writing to stderr which is the command's
+		os.Exit(code)
+		return nil
+	},
+}
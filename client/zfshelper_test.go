@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/zfs/zfshelper"
+)
+
+func TestZFSHelperPolicyFromConfig(t *testing.T) {
+	conf, err := config.ParseConfigBytes([]byte(`
+global:
+  zfs_helper: {}
+jobs:
+- name: sink
+  type: sink
+  root_fs: pool/sink
+  serve:
+    type: stdinserver
+    client_identities: [prod1]
+  recv:
+    placeholder:
+      properties:
+        canmount: "off"
+    browse_mounts:
+      prefix: /zrepl/browse
+  client_quotas:
+    default:
+      quota: 1 TiB
+  process_limits:
+    cgroup: zrepl/sink
+    cpu_weight: 50
+- name: pull
+  type: pull
+  root_fs: pool/pull
+  connect:
+    type: local
+    listener_name: pull
+    client_identity: pull
+  interval: manual
+  recv:
+    force_receive: never
+    placeholder:
+      properties:
+        canmount: "off"
+  pruning:
+    keep_sender:
+    - type: not_replicated
+    keep_receiver:
+    - type: last_n
+      count: 10
+- name: snap
+  type: snap
+  filesystems: {"pool/data<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep:
+    - type: last_n
+      count: 10
+`))
+	require.NoError(t, err)
+	p, err := zfsHelperPolicyFromConfig(conf)
+	require.NoError(t, err)
+
+	check := func(argv ...string) error {
+		return p.CheckCommand(context.Background(), argv)
+	}
+	assert.NoError(t, check("zfs", "create", "-o", "zrepl:placeholder=on", "-o", "canmount=off", "pool/sink/prod1"))
+	assert.Error(t, check("zfs", "create", "-o", "mountpoint=none", "pool/sink/prod1"), "placeholder properties are configured")
+	assert.NoError(t, check("zfs", "set", "mountpoint=/zrepl/browse/prod1/fs", "readonly=on", "pool/sink/prod1/fs"))
+	assert.NoError(t, check("zfs", "set", "quota=1099511627776", "pool/sink/prod1"))
+	assert.NoError(t, check("zfs", "rollback", "-r", "pool/sink/prod1/fs@a"))
+	assert.Error(t, check("zfs", "rollback", "-r", "pool/pull/fs@a"), "force_receive is never")
+	assert.NoError(t, check("zfs", "snapshot", "pool/data/a@b"))
+	assert.Error(t, check("zfs", "snapshot", "pool/other@b"))
+	assert.Equal(t, map[string]zfshelper.CgroupWeights{"/sys/fs/cgroup/zrepl/sink": {CPUWeight: 50}}, p.Cgroups)
+}
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"log/syslog"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/pkg/errors"
@@ -53,6 +54,8 @@ func (j JobEnum) Name() string {
 		name = v.Name
 	case *SourceJob:
 		name = v.Name
+	case *PoolMonitorJob:
+		name = v.Name
 	default:
 		panic(fmt.Sprintf("unknown job type %T", v))
 	}
@@ -66,6 +69,60 @@ type ActiveJob struct {
 	Pruning            PruningSenderReceiver `yaml:"pruning"`
 	Replication        *Replication          `yaml:"replication,optional,fromdefaults"`
 	ConflictResolution *ConflictResolution   `yaml:"conflict_resolution,optional,fromdefaults"`
+	Blackout           *BlackoutOptions      `yaml:"blackout,optional,fromdefaults"`
+	// hooks on replication lifecycle events, see HookSettingsCommon.Events
+	Hooks HookList `yaml:"hooks,optional"`
+	// names of active jobs whose successful invocations trigger this job's replication
+	After          []string               `yaml:"after,optional"`
+	ProcessLimits  *ProcessLimits         `yaml:"process_limits,optional"`
+	Maintenance    *MaintenanceOptions    `yaml:"maintenance,optional"`
+	CircuitBreaker *CircuitBreakerOptions `yaml:"circuit_breaker,optional,fromdefaults"`
+}
+
+// CircuitBreakerOptions suspends an active job after consecutive failed invocations.
+// The suspension doubles with every further failure, from InitialBackoff up to MaxBackoff,
+// and is lifted by a successful invocation or `zrepl signal reset`.
+type CircuitBreakerOptions struct {
+	// consecutive failed invocations after which the job is suspended, 0 disables the circuit breaker
+	Failures       int           `yaml:"failures,optional,zeropositive,default=0"`
+	InitialBackoff time.Duration `yaml:"initial_backoff,optional,positive,default=1m"`
+	MaxBackoff     time.Duration `yaml:"max_backoff,optional,positive,default=1h"`
+}
+
+// MaintenanceOptions specifies time windows during which new invocations of a job are skipped,
+// e.g., for scrubs or planned pool maintenance. Unlike blackout windows, invocations that are
+// running when a window begins complete, and skipped invocations are not made up for.
+type MaintenanceOptions struct {
+	Windows []*BlackoutWindow `yaml:"windows"`
+}
+
+// Scheduling priority of the zfs send/recv and hook processes that a job spawns.
+type ProcessLimits struct {
+	// niceness of the processes, -20 (highest priority) to 19
+	Nice *int `yaml:"nice,optional"`
+	// Linux I/O scheduling class: realtime, best-effort or idle
+	IOClass string `yaml:"io_class,optional"`
+	// priority within IOClass, 0 (highest) to 7, default 4
+	IOPriority *int `yaml:"io_priority,optional"`
+	// Linux cgroup v2 that the processes are moved to, relative to /sys/fs/cgroup, created if it does not exist
+	Cgroup string `yaml:"cgroup,optional"`
+	// cpu.weight and io.weight of Cgroup, 1 to 10000, 0 leaves the weight unchanged
+	CPUWeight int `yaml:"cpu_weight,optional,zeropositive"`
+	IOWeight  int `yaml:"io_weight,optional,zeropositive"`
+}
+
+// BlackoutOptions specifies time windows during which an active job must not replicate.
+type BlackoutOptions struct {
+	Windows []*BlackoutWindow `yaml:"windows,optional"`
+	// also defer pruning during blackout windows
+	Pruning bool `yaml:"pruning,optional,default=false"`
+}
+
+// A BlackoutWindow begins whenever Cron fires and lasts for Duration.
+// It is also the format of maintenance windows.
+type BlackoutWindow struct {
+	Cron     CronSpec      `yaml:"cron"`
+	Duration time.Duration `yaml:"duration,positive"`
 }
 
 type ConflictResolution struct {
@@ -73,17 +130,29 @@ type ConflictResolution struct {
 }
 
 type PassiveJob struct {
-	Type  string    `yaml:"type"`
-	Name  string    `yaml:"name"`
-	Serve ServeEnum `yaml:"serve"`
+	Type          string         `yaml:"type"`
+	Name          string         `yaml:"name"`
+	Serve         ServeEnum      `yaml:"serve"`
+	ProcessLimits *ProcessLimits `yaml:"process_limits,optional"`
 }
 
 type SnapJob struct {
-	Type         string            `yaml:"type"`
-	Name         string            `yaml:"name"`
-	Pruning      PruningLocal      `yaml:"pruning"`
-	Snapshotting SnapshottingEnum  `yaml:"snapshotting"`
-	Filesystems  FilesystemsFilter `yaml:"filesystems"`
+	Type          string              `yaml:"type"`
+	Name          string              `yaml:"name"`
+	Pruning       PruningLocal        `yaml:"pruning"`
+	Snapshotting  SnapshottingEnum    `yaml:"snapshotting"`
+	Filesystems   FilesystemsFilter   `yaml:"filesystems"`
+	ProcessLimits *ProcessLimits      `yaml:"process_limits,optional"`
+	Maintenance   *MaintenanceOptions `yaml:"maintenance,optional"`
+}
+
+// PoolMonitorJob periodically collects the state of zpools, it does not snapshot or replicate.
+type PoolMonitorJob struct {
+	Type string `yaml:"type"`
+	Name string `yaml:"name"`
+	// all imported pools if empty
+	Pools    []string      `yaml:"pools,optional"`
+	Interval time.Duration `yaml:"interval,optional,positive,default=1m"`
 }
 
 type SendOptions struct {
@@ -97,6 +166,13 @@ type SendOptions struct {
 	Saved            bool `yaml:"saved,optional,default=false"`
 
 	BandwidthLimit *BandwidthLimit `yaml:"bandwidth_limit,optional,fromdefaults"`
+
+	StepHolds *SendStepHolds `yaml:"step_holds,optional,fromdefaults"`
+}
+
+type SendStepHolds struct {
+	// how the `from` version of an incremental step is protected: `hold` or `bookmark`
+	From string `yaml:"from,optional,default=hold"`
 }
 
 type RecvOptions struct {
@@ -110,6 +186,17 @@ type RecvOptions struct {
 	BandwidthLimit *BandwidthLimit `yaml:"bandwidth_limit,optional,fromdefaults"`
 
 	Placeholder *PlaceholderRecvOptions `yaml:"placeholder,fromdefaults"`
+
+	// received plaintext filesystems inherit encryption from their parent
+	EncryptOnReceive bool `yaml:"encrypt_on_receive,optional,default=false"`
+
+	// refuse to receive if less space is available to the root filesystem
+	MinFreeSpace *datasizeunit.Bits `yaml:"min_free_space,optional"`
+
+	BrowseMounts *BrowseMountsRecvOptions `yaml:"browse_mounts,optional"`
+
+	// when zfs recv -F may roll back the receiving filesystem
+	ForceReceive string `yaml:"force_receive,optional,default=placeholders"`
 }
 
 var _ yaml.Unmarshaler = &datasizeunit.Bits{}
@@ -122,6 +209,8 @@ type BandwidthLimit struct {
 type Replication struct {
 	Protection  *ReplicationOptionsProtection  `yaml:"protection,optional,fromdefaults"`
 	Concurrency *ReplicationOptionsConcurrency `yaml:"concurrency,optional,fromdefaults"`
+	Retry       *ReplicationOptionsRetry       `yaml:"retry,optional,fromdefaults"`
+	Incremental *ReplicationOptionsIncremental `yaml:"incremental,optional,fromdefaults"`
 }
 
 type ReplicationOptionsProtection struct {
@@ -134,6 +223,20 @@ type ReplicationOptionsConcurrency struct {
 	SizeEstimates int `yaml:"size_estimates,optional,default=4"`
 }
 
+type ReplicationOptionsRetry struct {
+	MaxAttempts       int           `yaml:"max_attempts,optional,default=3"`
+	InitialDelay      time.Duration `yaml:"initial_delay,optional,zeropositive,default=0s"`
+	MaxDelay          time.Duration `yaml:"max_delay,optional,zeropositive,default=0s"`
+	BackoffMultiplier float64       `yaml:"backoff_multiplier,optional,default=2"`
+	Jitter            float64       `yaml:"jitter,optional,default=0"`
+	FilesystemRetries int           `yaml:"filesystem_retries,optional,default=3"`
+}
+
+type ReplicationOptionsIncremental struct {
+	// replicate only the most recent snapshot instead of every intermediate snapshot
+	SkipIntermediates bool `yaml:"skip_intermediates,optional,default=false"`
+}
+
 type PropertyRecvOptions struct {
 	Inherit  []zfsprop.Property          `yaml:"inherit,optional"`
 	Override map[zfsprop.Property]string `yaml:"override,optional"`
@@ -141,13 +244,28 @@ type PropertyRecvOptions struct {
 
 type PlaceholderRecvOptions struct {
 	Encryption string `yaml:"encryption,default=unspecified"`
+	// set on the placeholders that zrepl creates, nil means mountpoint=none
+	Properties map[zfsprop.Property]string `yaml:"properties,optional"`
+}
+
+type BrowseMountsRecvOptions struct {
+	// received filesystems are mounted read-only below this path
+	Prefix string `yaml:"prefix"`
 }
 
 type PushJob struct {
 	ActiveJob    `yaml:",inline"`
-	Snapshotting SnapshottingEnum  `yaml:"snapshotting"`
-	Filesystems  FilesystemsFilter `yaml:"filesystems"`
-	Send         *SendOptions      `yaml:"send,fromdefaults,optional"`
+	Snapshotting SnapshottingEnum   `yaml:"snapshotting"`
+	Filesystems  FilesystemsFilter  `yaml:"filesystems"`
+	Send         *SendOptions       `yaml:"send,fromdefaults,optional"`
+	Destinations []*PushDestination `yaml:"destinations,optional"`
+}
+
+// PushDestination is an additional sink that a push job replicates to,
+// next to the one specified in the job's `connect` field.
+type PushDestination struct {
+	Name    string      `yaml:"name"`
+	Connect ConnectEnum `yaml:"connect"`
 }
 
 func (j *PushJob) GetFilesystems() FilesystemsFilter { return j.Filesystems }
@@ -158,6 +276,17 @@ type PullJob struct {
 	RootFS    string                   `yaml:"root_fs"`
 	Interval  PositiveDurationOrManual `yaml:"interval"`
 	Recv      *RecvOptions             `yaml:"recv,fromdefaults,optional"`
+	Sources   []*PullSource            `yaml:"sources,optional"`
+}
+
+// PullSource is an additional source that a pull job replicates from,
+// next to the one specified in the job's `connect` field.
+type PullSource struct {
+	Name    string      `yaml:"name"`
+	Connect ConnectEnum `yaml:"connect"`
+	RootFS  string      `yaml:"root_fs"`
+	// defaults to the job's interval
+	Interval *PositiveDurationOrManual `yaml:"interval,optional"`
 }
 
 func (j *PullJob) GetRootFS() string             { return j.RootFS }
@@ -193,9 +322,52 @@ func (i *PositiveDurationOrManual) UnmarshalYAML(u func(interface{}, bool) error
 }
 
 type SinkJob struct {
-	PassiveJob `yaml:",inline"`
-	RootFS     string       `yaml:"root_fs"`
-	Recv       *RecvOptions `yaml:"recv,optional,fromdefaults"`
+	PassiveJob   `yaml:",inline"`
+	RootFS       string            `yaml:"root_fs"`
+	Recv         *RecvOptions      `yaml:"recv,optional,fromdefaults"`
+	ClientQuotas *SinkClientQuotas `yaml:"client_quotas,optional"`
+	ClientLimits *SinkClientLimits `yaml:"client_limits,optional"`
+	// refuse the sender's requests that would destroy or roll back received data
+	AppendOnly bool `yaml:"append_only,optional,default=false"`
+	// ordered rules that map the sender's filesystems to filesystems below root_fs
+	Mappings []*SinkMapping `yaml:"mappings,optional"`
+}
+
+// SinkMapping maps the sender's filesystems that match Match to Replace, relative to root_fs.
+// The first rule that applies to the client identity and matches the filesystem is used,
+// filesystems without a matching rule are received below root_fs/<client identity>.
+type SinkMapping struct {
+	// client identities the rule applies to, all clients if empty
+	Clients []string `yaml:"clients,optional"`
+	// regular expression that must match the whole filesystem path on the sender
+	Match string `yaml:"match"`
+	// path relative to root_fs, $1 or ${name} expand to submatches of Match, ${client} to the client identity
+	Replace string `yaml:"replace"`
+}
+
+type SinkClientQuotas struct {
+	Default *ClientQuota            `yaml:"default,optional"`
+	Clients map[string]*ClientQuota `yaml:"clients,optional"`
+}
+
+type SinkClientLimits struct {
+	Default *ClientLimits            `yaml:"default,optional"`
+	Clients map[string]*ClientLimits `yaml:"clients,optional"`
+}
+
+// ClientLimits restricts the receives of a client identity on a sink.
+type ClientLimits struct {
+	// 0 means no limit
+	MaxConcurrentReceives int `yaml:"max_concurrent_receives,optional,zeropositive"`
+	// shared by all receives of the client
+	BandwidthLimit *BandwidthLimit `yaml:"bandwidth_limit,optional"`
+	// receives may only start during these windows, at any time if empty
+	ReceiveWindows []*BlackoutWindow `yaml:"receive_windows,optional"`
+}
+
+type ClientQuota struct {
+	Quota       *datasizeunit.Bits `yaml:"quota,optional"`
+	Reservation *datasizeunit.Bits `yaml:"reservation,optional"`
 }
 
 func (j *SinkJob) GetRootFS() string             { return j.RootFS }
@@ -219,59 +391,178 @@ type SnapshottingEnum struct {
 }
 
 type SnapshottingPeriodic struct {
-	Type            string            `yaml:"type"`
-	Prefix          string            `yaml:"prefix"`
-	Interval        *PositiveDuration `yaml:"interval"`
-	Hooks           HookList          `yaml:"hooks,optional"`
-	TimestampFormat string            `yaml:"timestamp_format,optional,default=dense"`
+	Type             string                 `yaml:"type"`
+	Prefix           string                 `yaml:"prefix"`
+	Interval         *PositiveDuration      `yaml:"interval"`
+	Hooks            HookList               `yaml:"hooks,optional"`
+	TimestampFormat  string                 `yaml:"timestamp_format,optional,default=dense"`
+	NameTemplate     string                 `yaml:"name_template,optional"`
+	NameTimezone     string                 `yaml:"name_template_timezone,optional,default=UTC"`
+	SkipUnchanged    bool                   `yaml:"skip_unchanged,optional,default=false"`
+	Overrides        []SnapshottingOverride `yaml:"overrides,optional"`
+	HooksConcurrency int                    `yaml:"hooks_concurrency,optional,default=1"` // 1 means serially
+	Jitter           time.Duration          `yaml:"jitter,optional,zeropositive,default=0s"`
 }
 
 type CronSpec struct {
 	Schedule cron.Schedule
+	// as written in the config, see RenderYAML
+	specs []string
 }
 
 var _ yaml.Unmarshaler = &CronSpec{}
 
+// A CronSpec is either a single cron spec string or a list of them.
+// In the latter case, the schedule fires whenever any of the specs fires.
 func (s *CronSpec) UnmarshalYAML(unmarshal func(v interface{}, not_strict bool) error) error {
+	var specStrings []string
 	var specString string
-	if err := unmarshal(&specString, false); err != nil {
-		return err
+	if err := unmarshal(&specString, false); err == nil {
+		specStrings = []string{specString}
+	} else if err := unmarshal(&specStrings, false); err != nil {
+		return errors.New("cron spec must be a string or a list of strings")
+	}
+	if len(specStrings) == 0 {
+		return errors.New("cron spec list must not be empty")
 	}
 
-	// Use standard cron format.
-	// Disable the various "descriptors" (@daily, etc)
-	// They are just aliases to "top of hour", "midnight", etc.
-	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.SecondOptional)
+	// Use standard cron format, with optional seconds field.
+	// The descriptors (@daily, @hourly, @every 1h30m, etc.) are supported as well.
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.SecondOptional | cron.Descriptor)
 
-	sched, err := parser.Parse(specString)
-	if err != nil {
-		return errors.Wrap(err, "cron syntax invalid")
+	scheds := make(multiCronSchedule, len(specStrings))
+	for i, spec := range specStrings {
+		sched, err := parser.Parse(spec)
+		if err != nil {
+			return errors.Wrapf(err, "cron syntax invalid: %q", spec)
+		}
+		scheds[i] = sched
+	}
+	if len(scheds) == 1 {
+		s.Schedule = scheds[0]
+	} else {
+		s.Schedule = scheds
 	}
-	s.Schedule = sched
+	s.specs = specStrings
 	return nil
 }
 
+type multiCronSchedule []cron.Schedule
+
+func (m multiCronSchedule) Next(t time.Time) time.Time {
+	var next time.Time
+	for _, s := range m {
+		n := s.Next(t)
+		if n.IsZero() {
+			continue
+		}
+		if next.IsZero() || n.Before(next) {
+			next = n
+		}
+	}
+	return next
+}
+
 type SnapshottingCron struct {
-	Type            string   `yaml:"type"`
-	Prefix          string   `yaml:"prefix"`
-	Cron            CronSpec `yaml:"cron"`
-	Hooks           HookList `yaml:"hooks,optional"`
-	TimestampFormat string   `yaml:"timestamp_format,optional,default=dense"`
+	Type             string                 `yaml:"type"`
+	Prefix           string                 `yaml:"prefix"`
+	Cron             CronSpec               `yaml:"cron"`
+	Hooks            HookList               `yaml:"hooks,optional"`
+	TimestampFormat  string                 `yaml:"timestamp_format,optional,default=dense"`
+	NameTemplate     string                 `yaml:"name_template,optional"`
+	NameTimezone     string                 `yaml:"name_template_timezone,optional,default=UTC"`
+	SkipUnchanged    bool                   `yaml:"skip_unchanged,optional,default=false"`
+	Overrides        []SnapshottingOverride `yaml:"overrides,optional"`
+	HooksConcurrency int                    `yaml:"hooks_concurrency,optional,default=1"` // 1 means serially
+	Jitter           time.Duration          `yaml:"jitter,optional,zeropositive,default=0s"`
+}
+
+// Replaces the interval or cron schedule of the enclosing snapshotter for a subset of its filesystems.
+// Exactly one of Interval and Cron must be set.
+type SnapshottingOverride struct {
+	Filesystems FilesystemsFilter `yaml:"filesystems"`
+	Interval    *PositiveDuration `yaml:"interval,optional"`
+	Cron        *CronSpec         `yaml:"cron,optional"`
 }
 
 type SnapshottingManual struct {
 	Type string `yaml:"type"`
 }
 
+// Runs multiple periodic or cron snapshotters with different prefixes side by side.
+type SnapshottingMulti struct {
+	Type      string             `yaml:"type"`
+	Schedules []SnapshottingEnum `yaml:"schedules"`
+}
+
 type PruningSenderReceiver struct {
-	KeepSender   []PruningEnum `yaml:"keep_sender"`
-	KeepReceiver []PruningEnum `yaml:"keep_receiver"`
+	KeepSender   []PruningEnum                   `yaml:"keep_sender"`
+	KeepReceiver []PruningEnum                   `yaml:"keep_receiver"`
+	Overrides    []PruningSenderReceiverOverride `yaml:"overrides,optional"`
+	Bookmarks    *PruningBookmarksSenderReceiver `yaml:"bookmarks,optional"`
+	// If set, pruning runs on this schedule instead of after each replication.
+	Schedule *PruningSchedule `yaml:"schedule,optional"`
+	Destroy  *PruningDestroy  `yaml:"destroy,optional,fromdefaults"`
+	// snapshots younger than MinAge are never destroyed, regardless of the keep rules
+	MinAge time.Duration `yaml:"min_age,optional,zeropositive,default=0s"`
+}
+
+// Limits and batching of the destroy operations of a pruning run.
+type PruningDestroy struct {
+	// snapshots and bookmarks destroyed per pruning run, 0 means unlimited
+	MaxPerRun int `yaml:"max_per_run,optional,default=0"`
+	// snapshots or bookmarks per destroy operation, 0 means one operation per filesystem
+	BatchSize int `yaml:"batch_size,optional,default=0"`
+	// pause between destroy operations
+	Pacing time.Duration `yaml:"pacing,optional,zeropositive,default=0s"`
+	// destroy consecutive snapshots of a filesystem using the range syntax fs@first%last
+	Ranges bool `yaml:"ranges,optional,default=false"`
+	// if positive, snapshots are first marked for destruction and destroyed by
+	// the first run after the grace period has passed
+	GracePeriod time.Duration `yaml:"grace_period,optional,zeropositive,default=0s"`
+	// number of filesystems that are pruned in parallel
+	Concurrency int `yaml:"concurrency,optional,positive,default=1"`
+}
+
+// Exactly one of Interval and Cron must be set.
+type PruningSchedule struct {
+	Interval *PositiveDuration `yaml:"interval,optional"`
+	Cron     *CronSpec         `yaml:"cron,optional"`
+}
+
+// Keep rules for the bookmarks of the job's filesystems.
+// Bookmarks of a side are only pruned if its list is not nil.
+type PruningBookmarksSenderReceiver struct {
+	KeepSender   []PruningEnum `yaml:"keep_sender,optional"`
+	KeepReceiver []PruningEnum `yaml:"keep_receiver,optional"`
+}
+
+// Replaces the keep rules for a subset of the job's filesystems.
+// A nil list means that the job's rules for that side apply.
+type PruningSenderReceiverOverride struct {
+	Filesystems  FilesystemsFilter `yaml:"filesystems"`
+	KeepSender   []PruningEnum     `yaml:"keep_sender,optional"`
+	KeepReceiver []PruningEnum     `yaml:"keep_receiver,optional"`
 }
 
 type PruningLocal struct {
+	Keep      []PruningEnum          `yaml:"keep"`
+	Overrides []PruningLocalOverride `yaml:"overrides,optional"`
+	Bookmarks *PruningBookmarksLocal `yaml:"bookmarks,optional"`
+	Destroy   *PruningDestroy        `yaml:"destroy,optional,fromdefaults"`
+	// see PruningSenderReceiver.MinAge
+	MinAge time.Duration `yaml:"min_age,optional,zeropositive,default=0s"`
+}
+
+type PruningBookmarksLocal struct {
 	Keep []PruningEnum `yaml:"keep"`
 }
 
+type PruningLocalOverride struct {
+	Filesystems FilesystemsFilter `yaml:"filesystems"`
+	Keep        []PruningEnum     `yaml:"keep"`
+}
+
 type LoggingOutletEnumList []LoggingOutletEnum
 
 func (l *LoggingOutletEnumList) SetDefault() {
@@ -292,10 +583,21 @@ format: "human"
 var _ yaml.Defaulter = &LoggingOutletEnumList{}
 
 type Global struct {
-	Logging    *LoggingOutletEnumList `yaml:"logging,optional,fromdefaults"`
-	Monitoring []MonitoringEnum       `yaml:"monitoring,optional"`
-	Control    *GlobalControl         `yaml:"control,optional,fromdefaults"`
-	Serve      *GlobalServe           `yaml:"serve,optional,fromdefaults"`
+	Logging     *LoggingOutletEnumList `yaml:"logging,optional,fromdefaults"`
+	Monitoring  []MonitoringEnum       `yaml:"monitoring,optional"`
+	Control     *GlobalControl         `yaml:"control,optional,fromdefaults"`
+	Serve       *GlobalServe           `yaml:"serve,optional,fromdefaults"`
+	Concurrency *GlobalConcurrency     `yaml:"concurrency,optional,fromdefaults"`
+	Reload      *GlobalReload          `yaml:"reload,optional,fromdefaults"`
+	VersionSkew *GlobalVersionSkew     `yaml:"version_skew,optional,fromdefaults"`
+	// if set, the daemon executes zfs and zpool through `zrepl zfs-helper serve`, which runs as root
+	ZFSHelper *GlobalZFSHelper `yaml:"zfs_helper,optional"`
+}
+
+type GlobalZFSHelper struct {
+	SockPath string `yaml:"sockpath,default=/var/run/zrepl-zfs-helper/zfs-helper.sock"`
+	// group that may connect to the helper, i.e., the group of the daemon's user, empty for only root
+	SocketGroup string `yaml:"socket_group,optional"`
 }
 
 type ConnectEnum struct {
@@ -317,7 +619,7 @@ type TLSConnect struct {
 	Address       string        `yaml:"address,hostport"`
 	Ca            string        `yaml:"ca"`
 	Cert          string        `yaml:"cert"`
-	Key           string        `yaml:"key"`
+	Key           Secret        `yaml:"key"`
 	ServerCN      string        `yaml:"server_cn"`
 	DialTimeout   time.Duration `yaml:"dial_timeout,zeropositive,default=10s"`
 }
@@ -362,7 +664,7 @@ type TLSServe struct {
 	ListenFreeBind   bool          `yaml:"listen_freebind,default=false"`
 	Ca               string        `yaml:"ca"`
 	Cert             string        `yaml:"cert"`
-	Key              string        `yaml:"key"`
+	Key              Secret        `yaml:"key"`
 	ClientCNs        []string      `yaml:"client_cns"`
 	HandshakeTimeout time.Duration `yaml:"handshake_timeout,zeropositive,default=10s"`
 }
@@ -384,6 +686,9 @@ type PruningEnum struct {
 type PruneKeepNotReplicated struct {
 	Type                 string `yaml:"type"`
 	KeepSnapshotAtCursor bool   `yaml:"keep_snapshot_at_cursor,optional,default=true"`
+	// for push jobs with destinations: the number of destinations (including the job's sink)
+	// that a snapshot must have been replicated to, 0 means all destinations
+	MinDestinations int `yaml:"min_destinations,optional,zeropositive,default=0"`
 }
 
 type PruneKeepLastN struct {
@@ -392,6 +697,27 @@ type PruneKeepLastN struct {
 	Regex string `yaml:"regex,optional"`
 }
 
+type PruneKeepSpaceBudget struct {
+	Type   string            `yaml:"type"`
+	Budget datasizeunit.Bits `yaml:"budget"`
+	Regex  string            `yaml:"regex,optional"`
+}
+
+type PruneKeepHold struct {
+	Type string `yaml:"type"`
+	Tags string `yaml:"tags"` // regex
+}
+
+type PruneKeepTiered struct {
+	Type    string `yaml:"type"`
+	Hourly  int    `yaml:"hourly,optional,default=0"`
+	Daily   int    `yaml:"daily,optional,default=0"`
+	Weekly  int    `yaml:"weekly,optional,default=0"`
+	Monthly int    `yaml:"monthly,optional,default=0"`
+	Yearly  int    `yaml:"yearly,optional,default=0"`
+	Regex   string `yaml:"regex,optional"`
+}
+
 type PruneKeepRegex struct { // FIXME rename to KeepRegex
 	Type   string `yaml:"type"`
 	Regex  string `yaml:"regex"`
@@ -431,7 +757,7 @@ type TCPLoggingOutlet struct {
 type TCPLoggingOutletTLS struct {
 	CA   string `yaml:"ca"`
 	Cert string `yaml:"cert"`
-	Key  string `yaml:"key"`
+	Key  Secret `yaml:"key"`
 }
 
 type MonitoringEnum struct {
@@ -442,6 +768,72 @@ type PrometheusMonitoring struct {
 	Type           string `yaml:"type"`
 	Listen         string `yaml:"listen,hostport"`
 	ListenFreeBind bool   `yaml:"listen_freebind,default=false"`
+	// label replication byte counters with the filesystem (high cardinality for many filesystems)
+	PerFilesystemMetrics bool `yaml:"per_filesystem_metrics,optional,default=true"`
+}
+
+// Serves a read-only web UI with the status of the daemon's jobs.
+type WebUIMonitoring struct {
+	Type           string `yaml:"type"`
+	Listen         string `yaml:"listen,hostport"`
+	ListenFreeBind bool   `yaml:"listen_freebind,default=false"`
+}
+
+// Pushes the zrepl metrics to a StatsD server, for monitoring systems that cannot scrape the Prometheus endpoint.
+type StatsdMonitoring struct {
+	Type    string `yaml:"type"`
+	Address string `yaml:"address,hostport"`
+	// statsd puts the labels into the metric name, dogstatsd sends them as tags
+	Format string `yaml:"format,optional,default=statsd"`
+	Prefix string `yaml:"prefix,optional"`
+	// added to every metric, dogstatsd only
+	Tags     map[string]string `yaml:"tags,optional"`
+	Interval time.Duration     `yaml:"interval,optional,positive,default=10s"`
+	// name prefixes of the Prometheus metrics to send, all zrepl metrics if empty
+	Metrics []string `yaml:"metrics,optional"`
+}
+
+type MetricsPushCommon struct {
+	Type string `yaml:"type"`
+	URL  string `yaml:"url"`
+	Job  string `yaml:"job,optional,default=zrepl"`
+	// identify the daemon's series, instance=<hostname> if empty
+	Labels map[string]string `yaml:"labels,optional"`
+	// e.g., for authentication
+	Headers map[string]string `yaml:"headers,optional"`
+	Timeout time.Duration     `yaml:"timeout,optional,positive,default=30s"`
+	// name prefixes of the Prometheus metrics to push, all zrepl metrics if empty
+	Metrics []string `yaml:"metrics,optional"`
+}
+
+// Pushes the zrepl metrics to a Prometheus Pushgateway at the end of every job invocation.
+type PushgatewayMonitoring struct {
+	MetricsPushCommon `yaml:",inline"`
+}
+
+// Pushes the zrepl metrics to a Prometheus remote-write endpoint at the end of every job invocation.
+type RemoteWriteMonitoring struct {
+	MetricsPushCommon `yaml:",inline"`
+}
+
+// Emails a digest of the jobs' invocations since the previous digest, e.g. daily or weekly.
+type EmailDigestMonitoring struct {
+	Type string           `yaml:"type"`
+	Cron CronSpec         `yaml:"cron"`
+	SMTP *EmailDigestSMTP `yaml:"smtp"`
+	From string           `yaml:"from"`
+	To   []string         `yaml:"to"`
+	// "zrepl digest of <hostname>" if empty
+	Subject string `yaml:"subject,optional"`
+}
+
+type EmailDigestSMTP struct {
+	Address string `yaml:"address,hostport"`
+	// starttls requires the server to support STARTTLS, tls uses implicit TLS (usually port 465), none sends in plain text
+	TLS      string        `yaml:"tls,optional,default=starttls"`
+	Username string        `yaml:"username,optional"`
+	Password Secret        `yaml:"password,optional"`
+	Timeout  time.Duration `yaml:"timeout,optional,positive,default=30s"`
 }
 
 type SyslogFacility syslog.Priority
@@ -454,12 +846,61 @@ var _ yaml.Defaulter = (*SyslogFacility)(nil)
 
 type GlobalControl struct {
 	SockPath string `yaml:"sockpath,default=/var/run/zrepl/control"`
+	// persists the jobs disabled through `zrepl job disable` across daemon restarts
+	JobStatePath string `yaml:"job_state_path,optional,default=/var/lib/zrepl/jobstate.json"`
+	// additional control listener on TCP for remote CLI use
+	Listen *GlobalControlListen `yaml:"listen,optional"`
+	// used by the CLI instead of SockPath to control a remote daemon
+	Connect *GlobalControlConnect `yaml:"connect,optional"`
+}
+
+// Clients must authenticate with a token, a TLS client certificate, or both if both are configured.
+type GlobalControlListen struct {
+	Listen         string `yaml:"listen,hostport"`
+	ListenFreeBind bool   `yaml:"listen_freebind,default=false"`
+	// file that contains the bearer token clients must present
+	TokenFile Secret `yaml:"token_file,optional"`
+	// Ca enables client certificate authentication, restricted to ClientCNs if not empty
+	Cert      string   `yaml:"cert,optional"`
+	Key       Secret   `yaml:"key,optional"`
+	Ca        string   `yaml:"ca,optional"`
+	ClientCNs []string `yaml:"client_cns,optional"`
+}
+
+type GlobalControlConnect struct {
+	Address   string `yaml:"address,hostport"`
+	TokenFile Secret `yaml:"token_file,optional"`
+	// TLS is used if Ca is set, Cert and Key are the client certificate
+	Ca          string        `yaml:"ca,optional"`
+	Cert        string        `yaml:"cert,optional"`
+	Key         Secret        `yaml:"key,optional"`
+	ServerCN    string        `yaml:"server_cn,optional"`
+	DialTimeout time.Duration `yaml:"dial_timeout,zeropositive,default=10s"`
 }
 
 type GlobalServe struct {
 	StdinServer *GlobalStdinServer `yaml:"stdinserver,optional,fromdefaults"`
 }
 
+// How the daemon stops removed and changed jobs when it reloads the configuration.
+type GlobalReload struct {
+	// "wait": let running invocations of active and snap jobs complete, "abort": cancel them
+	StopPolicy string `yaml:"stop_policy,optional,default=wait"`
+	// with stop_policy "wait", jobs are cancelled if they have not stopped after StopTimeout
+	StopTimeout time.Duration `yaml:"stop_timeout,optional,positive,default=10m"`
+}
+
+type GlobalVersionSkew struct {
+	// warn if the zrepl minor versions of the two sides of a connection differ by more than this
+	MaxMinorDifference int `yaml:"max_minor_difference,optional,zeropositive,default=0"`
+}
+
+// daemon-wide limits, 0 means no limit
+type GlobalConcurrency struct {
+	ZFSSend int `yaml:"zfs_send,optional,default=0"`
+	ZFSRecv int `yaml:"zfs_recv,optional,default=0"`
+}
+
 type GlobalStdinServer struct {
 	SockDir string `yaml:"sockdir,default=/var/run/zrepl/stdinserver"`
 }
@@ -474,26 +915,74 @@ type HookCommand struct {
 	Path               string            `yaml:"path"`
 	Timeout            time.Duration     `yaml:"timeout,optional,positive,default=30s"`
 	Filesystems        FilesystemsFilter `yaml:"filesystems,optional,default={'<': true}"`
+	ContextFile        bool              `yaml:"context_file,optional,default=false"`
+	Protocol           string            `yaml:"protocol,optional,default=env"`
 	HookSettingsCommon `yaml:",inline"`
 }
 
 type HookPostgresCheckpoint struct {
-	HookSettingsCommon `yaml:",inline"`
-	DSN                string            `yaml:"dsn"`
-	Timeout            time.Duration     `yaml:"timeout,optional,positive,default=30s"`
-	Filesystems        FilesystemsFilter `yaml:"filesystems"` // required, user should not CHECKPOINT for every FS
+	HookSettingsCommon     `yaml:",inline"`
+	HookDatabaseConnection `yaml:",inline"`
+	Timeout                time.Duration         `yaml:"timeout,optional,positive,default=30s"`
+	Filesystems            FilesystemsFilter     `yaml:"filesystems"` // required, user should not CHECKPOINT for every FS
+	Databases              []HookDatabaseMapping `yaml:"databases,optional"`
 }
 
 type HookMySQLLockTables struct {
+	HookSettingsCommon     `yaml:",inline"`
+	HookDatabaseConnection `yaml:",inline"`
+	Timeout                time.Duration         `yaml:"timeout,optional,positive,default=30s"`
+	Filesystems            FilesystemsFilter     `yaml:"filesystems"`
+	Databases              []HookDatabaseMapping `yaml:"databases,optional"`
+}
+
+// Connection settings of the database hooks.
+// The explicit fields take precedence over the corresponding values in DSN.
+type HookDatabaseConnection struct {
+	DSN          string           `yaml:"dsn,optional"`
+	Host         string           `yaml:"host,optional"`
+	Port         uint16           `yaml:"port,optional"`
+	Socket       string           `yaml:"socket,optional"`
+	User         string           `yaml:"user,optional"`
+	PasswordFile Secret           `yaml:"password_file,optional"`
+	Database     string           `yaml:"database,optional"`
+	TLS          *HookDatabaseTLS `yaml:"tls,optional"`
+}
+
+type HookDatabaseTLS struct {
+	CA                 string `yaml:"ca,optional"` // system roots if empty
+	Cert               string `yaml:"cert,optional"`
+	Key                Secret `yaml:"key,optional"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,optional,default=false"`
+}
+
+// Filesystems matched by Filesystems use the hook's connection settings
+// with the non-empty fields of HookDatabaseConnection replaced.
+type HookDatabaseMapping struct {
+	Filesystems            FilesystemsFilter `yaml:"filesystems"`
+	HookDatabaseConnection `yaml:",inline"`
+}
+
+type HookWebhook struct {
 	HookSettingsCommon `yaml:",inline"`
-	DSN                string            `yaml:"dsn"`
-	Timeout            time.Duration     `yaml:"timeout,optional,positive,default=30s"`
-	Filesystems        FilesystemsFilter `yaml:"filesystems"`
+	URL                string            `yaml:"url"`
+	Headers            map[string]string `yaml:"headers,optional"`
+	Payload            string            `yaml:"payload,optional"` // Go text/template, default: JSON object of the hook environment
+	HMACSecretFile     Secret            `yaml:"hmac_secret_file,optional"`
+	Timeout            time.Duration     `yaml:"timeout,optional,positive,default=10s"`
+	Filesystems        FilesystemsFilter `yaml:"filesystems,optional,default={'<': true}"`
 }
 
 type HookSettingsCommon struct {
 	Type       string `yaml:"type"`
 	ErrIsFatal bool   `yaml:"err_is_fatal,optional,default=false"`
+	// warn, skip_filesystem or abort_job, default derived from ErrIsFatal
+	OnError string `yaml:"on_error,optional"`
+	// nil means the hook type's default (3 for webhook, 0 otherwise)
+	Retries       *int          `yaml:"retries,optional"`
+	RetryInterval time.Duration `yaml:"retry_interval,optional,positive,default=1s"`
+	// only for hooks on replication lifecycle events, empty means all events
+	Events []string `yaml:"events,optional"`
 }
 
 func enumUnmarshal(u func(interface{}, bool) error, types map[string]interface{}) (interface{}, error) {
@@ -517,132 +1006,157 @@ func enumUnmarshal(u func(interface{}, bool) error, types map[string]interface{}
 	return v, nil
 }
 
+func (t *JobEnum) enumTypes() map[string]interface{} {
+	return map[string]interface{}{
+		"snap":         &SnapJob{},
+		"push":         &PushJob{},
+		"sink":         &SinkJob{},
+		"pull":         &PullJob{},
+		"source":       &SourceJob{},
+		"pool_monitor": &PoolMonitorJob{},
+	}
+}
+
 func (t *JobEnum) UnmarshalYAML(u func(interface{}, bool) error) (err error) {
-	t.Ret, err = enumUnmarshal(u, map[string]interface{}{
-		"snap":   &SnapJob{},
-		"push":   &PushJob{},
-		"sink":   &SinkJob{},
-		"pull":   &PullJob{},
-		"source": &SourceJob{},
-	})
+	t.Ret, err = enumUnmarshal(u, t.enumTypes())
 	return
 }
 
-func (t *ConnectEnum) UnmarshalYAML(u func(interface{}, bool) error) (err error) {
-	t.Ret, err = enumUnmarshal(u, map[string]interface{}{
+func (t *ConnectEnum) enumTypes() map[string]interface{} {
+	return map[string]interface{}{
 		"tcp":             &TCPConnect{},
 		"tls":             &TLSConnect{},
 		"ssh+stdinserver": &SSHStdinserverConnect{},
 		"local":           &LocalConnect{},
-	})
+	}
+}
+
+func (t *ConnectEnum) UnmarshalYAML(u func(interface{}, bool) error) (err error) {
+	t.Ret, err = enumUnmarshal(u, t.enumTypes())
 	return
 }
 
-func (t *ServeEnum) UnmarshalYAML(u func(interface{}, bool) error) (err error) {
-	t.Ret, err = enumUnmarshal(u, map[string]interface{}{
+func (t *ServeEnum) enumTypes() map[string]interface{} {
+	return map[string]interface{}{
 		"tcp":         &TCPServe{},
 		"tls":         &TLSServe{},
 		"stdinserver": &StdinserverServer{},
 		"local":       &LocalServe{},
-	})
+	}
+}
+
+func (t *ServeEnum) UnmarshalYAML(u func(interface{}, bool) error) (err error) {
+	t.Ret, err = enumUnmarshal(u, t.enumTypes())
 	return
 }
 
-func (t *PruningEnum) UnmarshalYAML(u func(interface{}, bool) error) (err error) {
-	t.Ret, err = enumUnmarshal(u, map[string]interface{}{
+func (t *PruningEnum) enumTypes() map[string]interface{} {
+	return map[string]interface{}{
 		"not_replicated": &PruneKeepNotReplicated{},
 		"last_n":         &PruneKeepLastN{},
 		"grid":           &PruneGrid{},
 		"regex":          &PruneKeepRegex{},
-	})
+		"space_budget":   &PruneKeepSpaceBudget{},
+		"tiered":         &PruneKeepTiered{},
+		"hold":           &PruneKeepHold{},
+	}
+}
+
+func (t *PruningEnum) UnmarshalYAML(u func(interface{}, bool) error) (err error) {
+	t.Ret, err = enumUnmarshal(u, t.enumTypes())
 	return
 }
 
-func (t *SnapshottingEnum) UnmarshalYAML(u func(interface{}, bool) error) (err error) {
-	t.Ret, err = enumUnmarshal(u, map[string]interface{}{
+func (t *SnapshottingEnum) enumTypes() map[string]interface{} {
+	return map[string]interface{}{
 		"periodic": &SnapshottingPeriodic{},
 		"manual":   &SnapshottingManual{},
 		"cron":     &SnapshottingCron{},
-	})
+		"multi":    &SnapshottingMulti{},
+	}
+}
+
+func (t *SnapshottingEnum) UnmarshalYAML(u func(interface{}, bool) error) (err error) {
+	t.Ret, err = enumUnmarshal(u, t.enumTypes())
 	return
 }
 
-func (t *LoggingOutletEnum) UnmarshalYAML(u func(interface{}, bool) error) (err error) {
-	t.Ret, err = enumUnmarshal(u, map[string]interface{}{
+func (t *LoggingOutletEnum) enumTypes() map[string]interface{} {
+	return map[string]interface{}{
 		"stdout": &StdoutLoggingOutlet{},
 		"syslog": &SyslogLoggingOutlet{},
 		"tcp":    &TCPLoggingOutlet{},
-	})
+	}
+}
+
+func (t *LoggingOutletEnum) UnmarshalYAML(u func(interface{}, bool) error) (err error) {
+	t.Ret, err = enumUnmarshal(u, t.enumTypes())
 	return
 }
 
+func (t *MonitoringEnum) enumTypes() map[string]interface{} {
+	return map[string]interface{}{
+		"prometheus":   &PrometheusMonitoring{},
+		"webui":        &WebUIMonitoring{},
+		"statsd":       &StatsdMonitoring{},
+		"pushgateway":  &PushgatewayMonitoring{},
+		"remote_write": &RemoteWriteMonitoring{},
+		"email_digest": &EmailDigestMonitoring{},
+	}
+}
+
 func (t *MonitoringEnum) UnmarshalYAML(u func(interface{}, bool) error) (err error) {
-	t.Ret, err = enumUnmarshal(u, map[string]interface{}{
-		"prometheus": &PrometheusMonitoring{},
-	})
+	t.Ret, err = enumUnmarshal(u, t.enumTypes())
 	return
 }
 
+var syslogFacilities = map[string]syslog.Priority{
+	"kern":     syslog.LOG_KERN,
+	"user":     syslog.LOG_USER,
+	"mail":     syslog.LOG_MAIL,
+	"daemon":   syslog.LOG_DAEMON,
+	"auth":     syslog.LOG_AUTH,
+	"syslog":   syslog.LOG_SYSLOG,
+	"lpr":      syslog.LOG_LPR,
+	"news":     syslog.LOG_NEWS,
+	"uucp":     syslog.LOG_UUCP,
+	"cron":     syslog.LOG_CRON,
+	"authpriv": syslog.LOG_AUTHPRIV,
+	"ftp":      syslog.LOG_FTP,
+	"local0":   syslog.LOG_LOCAL0,
+	"local1":   syslog.LOG_LOCAL1,
+	"local2":   syslog.LOG_LOCAL2,
+	"local3":   syslog.LOG_LOCAL3,
+	"local4":   syslog.LOG_LOCAL4,
+	"local5":   syslog.LOG_LOCAL5,
+	"local6":   syslog.LOG_LOCAL6,
+	"local7":   syslog.LOG_LOCAL7,
+}
+
 func (t *SyslogFacility) UnmarshalYAML(u func(interface{}, bool) error) (err error) {
 	var s string
 	if err := u(&s, true); err != nil {
 		return err
 	}
-	var level syslog.Priority
-	switch s {
-	case "kern":
-		level = syslog.LOG_KERN
-	case "user":
-		level = syslog.LOG_USER
-	case "mail":
-		level = syslog.LOG_MAIL
-	case "daemon":
-		level = syslog.LOG_DAEMON
-	case "auth":
-		level = syslog.LOG_AUTH
-	case "syslog":
-		level = syslog.LOG_SYSLOG
-	case "lpr":
-		level = syslog.LOG_LPR
-	case "news":
-		level = syslog.LOG_NEWS
-	case "uucp":
-		level = syslog.LOG_UUCP
-	case "cron":
-		level = syslog.LOG_CRON
-	case "authpriv":
-		level = syslog.LOG_AUTHPRIV
-	case "ftp":
-		level = syslog.LOG_FTP
-	case "local0":
-		level = syslog.LOG_LOCAL0
-	case "local1":
-		level = syslog.LOG_LOCAL1
-	case "local2":
-		level = syslog.LOG_LOCAL2
-	case "local3":
-		level = syslog.LOG_LOCAL3
-	case "local4":
-		level = syslog.LOG_LOCAL4
-	case "local5":
-		level = syslog.LOG_LOCAL5
-	case "local6":
-		level = syslog.LOG_LOCAL6
-	case "local7":
-		level = syslog.LOG_LOCAL7
-	default:
+	level, ok := syslogFacilities[s]
+	if !ok {
 		return fmt.Errorf("invalid syslog level: %q", s)
 	}
 	*t = SyslogFacility(level)
 	return nil
 }
 
-func (t *HookEnum) UnmarshalYAML(u func(interface{}, bool) error) (err error) {
-	t.Ret, err = enumUnmarshal(u, map[string]interface{}{
+func (t *HookEnum) enumTypes() map[string]interface{} {
+	return map[string]interface{}{
 		"command":             &HookCommand{},
 		"postgres-checkpoint": &HookPostgresCheckpoint{},
 		"mysql-lock-tables":   &HookMySQLLockTables{},
-	})
+		"webhook":             &HookWebhook{},
+	}
+}
+
+func (t *HookEnum) UnmarshalYAML(u func(interface{}, bool) error) (err error) {
+	t.Ret, err = enumUnmarshal(u, t.enumTypes())
 	return
 }
 
@@ -675,10 +1189,78 @@ func ParseConfig(path string) (i *Config, err error) {
 		return
 	}
 
-	return ParseConfigBytes(bytes)
+	return parseConfigBytes(bytes, filepath.Dir(path), path)
 }
 
+// ParseConfigBytes resolves includes relative to the working directory.
 func ParseConfigBytes(bytes []byte) (*Config, error) {
+	return parseConfigBytes(bytes, "", "")
+}
+
+// parseConfigYAML parses a config document without includes.
+// Line numbers in errors are only reported if raw is the file.
+func parseConfigYAML(bytes []byte, file string, raw bool) (*Config, error) {
+	var doc map[interface{}]interface{}
+	if err := yaml.Unmarshal(bytes, &doc); err != nil || doc == nil {
+		// let the typed unmarshaling report the error
+		c, err := unmarshalConfig(bytes)
+		if err != nil {
+			var lines map[string]int
+			if raw {
+				lines = map[string]int{}
+			}
+			return nil, validationErrorsFromYAML(err, file, lines)
+		}
+		return c, nil
+	}
+	if !raw {
+		return parseConfigDoc(nil, doc, file)
+	}
+	return parseConfigDoc(bytes, doc, file)
+}
+
+// parseConfigDoc expands job templates and interpolates values in doc, the generic representation of raw.
+// If doc uses neither, raw is parsed as-is so that errors refer to its line numbers.
+// raw may be nil if doc does not correspond to a file.
+// Errors are returned as ValidationErrors, attributed to file.
+func parseConfigDoc(raw []byte, doc map[interface{}]interface{}, file string) (*Config, error) {
+	changed := raw == nil
+	if usesJobTemplates(doc) {
+		if err := expandJobTemplates(doc); err != nil {
+			return nil, withFile(err, file)
+		}
+		changed = true
+	}
+	interpolated, err := interpolateConfig(doc)
+	if err != nil {
+		return nil, withFile(err, file)
+	}
+	changed = changed || interpolated
+
+	parsed := raw
+	if changed {
+		parsed, err = yaml.Marshal(doc)
+		if err != nil {
+			return nil, withFile(errors.Wrap(err, "cannot marshal preprocessed config"), file)
+		}
+	}
+	c, err := unmarshalConfig(parsed)
+	if err == nil {
+		return c, nil
+	}
+
+	// The schema validation produces better errors, but for some errors, e.g. of custom types, only yaml-config knows.
+	var lines map[string]int
+	if !changed {
+		lines = yamlLineIndex(raw)
+	}
+	if verrs := validateConfigDoc(doc, file, lines); len(verrs) > 0 {
+		return nil, verrs
+	}
+	return nil, validationErrorsFromYAML(err, file, lines)
+}
+
+func unmarshalConfig(bytes []byte) (*Config, error) {
 	var c *Config
 	if err := yaml.UnmarshalStrict(bytes, &c); err != nil {
 		return nil, err
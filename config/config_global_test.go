@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log/syslog"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -73,6 +74,111 @@ global:
       listen: ':9811'
 `)
 	assert.Equal(t, ":9811", conf.Global.Monitoring[0].Ret.(*PrometheusMonitoring).Listen)
+	assert.True(t, conf.Global.Monitoring[0].Ret.(*PrometheusMonitoring).PerFilesystemMetrics)
+
+	conf = testValidGlobalSection(t, `
+global:
+  monitoring:
+    - type: prometheus
+      listen: ':9811'
+      per_filesystem_metrics: false
+`)
+	assert.False(t, conf.Global.Monitoring[0].Ret.(*PrometheusMonitoring).PerFilesystemMetrics)
+}
+
+func TestStatsdMonitoring(t *testing.T) {
+	conf := testValidGlobalSection(t, `
+global:
+  monitoring:
+    - type: statsd
+      address: 'localhost:8125'
+`)
+	s := conf.Global.Monitoring[0].Ret.(*StatsdMonitoring)
+	assert.Equal(t, "localhost:8125", s.Address)
+	assert.Equal(t, "statsd", s.Format)
+	assert.Equal(t, 10*time.Second, s.Interval)
+
+	conf = testValidGlobalSection(t, `
+global:
+  monitoring:
+    - type: statsd
+      address: 'localhost:8125'
+      format: dogstatsd
+      prefix: zrepl
+      tags:
+        env: prod
+      interval: 1m
+      metrics: ["zrepl_replication_"]
+`)
+	s = conf.Global.Monitoring[0].Ret.(*StatsdMonitoring)
+	assert.Equal(t, "dogstatsd", s.Format)
+	assert.Equal(t, map[string]string{"env": "prod"}, s.Tags)
+	assert.Equal(t, time.Minute, s.Interval)
+	assert.Equal(t, []string{"zrepl_replication_"}, s.Metrics)
+}
+
+func TestMetricsPushMonitoring(t *testing.T) {
+	conf := testValidGlobalSection(t, `
+global:
+  monitoring:
+    - type: pushgateway
+      url: 'http://pushgateway:9091'
+    - type: remote_write
+      url: 'https://prometheus/api/v1/write'
+      job: backup
+      labels:
+        instance: nas1
+      headers:
+        Authorization: Bearer secret
+      timeout: 1m
+`)
+	pg := conf.Global.Monitoring[0].Ret.(*PushgatewayMonitoring)
+	assert.Equal(t, "http://pushgateway:9091", pg.URL)
+	assert.Equal(t, "zrepl", pg.Job)
+	assert.Equal(t, 30*time.Second, pg.Timeout)
+	rw := conf.Global.Monitoring[1].Ret.(*RemoteWriteMonitoring)
+	assert.Equal(t, "remote_write", rw.Type)
+	assert.Equal(t, "backup", rw.Job)
+	assert.Equal(t, map[string]string{"instance": "nas1"}, rw.Labels)
+	assert.Equal(t, "Bearer secret", rw.Headers["Authorization"])
+	assert.Equal(t, time.Minute, rw.Timeout)
+}
+
+func TestEmailDigestMonitoring(t *testing.T) {
+	conf := testValidGlobalSection(t, `
+global:
+  monitoring:
+    - type: email_digest
+      cron: '@weekly'
+      smtp:
+        address: 'smtp.example.com:587'
+        username: zrepl
+        password: /etc/zrepl/smtp.pass
+      from: zrepl@nas1.example.com
+      to: [admin@example.com]
+`)
+	d := conf.Global.Monitoring[0].Ret.(*EmailDigestMonitoring)
+	assert.Equal(t, "starttls", d.SMTP.TLS)
+	assert.Equal(t, "/etc/zrepl/smtp.pass", d.SMTP.Password.File)
+	assert.Equal(t, 30*time.Second, d.SMTP.Timeout)
+	assert.Equal(t, []string{"admin@example.com"}, d.To)
+	assert.Equal(t, time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC),
+		d.Cron.Schedule.Next(time.Date(2026, 10, 14, 8, 0, 0, 0, time.UTC)))
+}
+
+func TestGlobalConcurrency(t *testing.T) {
+	conf := testValidGlobalSection(t, "")
+	assert.Equal(t, 0, conf.Global.Concurrency.ZFSSend)
+	assert.Equal(t, 0, conf.Global.Concurrency.ZFSRecv)
+
+	conf = testValidGlobalSection(t, `
+global:
+  concurrency:
+    zfs_send: 2
+    zfs_recv: 3
+`)
+	assert.Equal(t, 2, conf.Global.Concurrency.ZFSSend)
+	assert.Equal(t, 3, conf.Global.Concurrency.ZFSRecv)
 }
 
 func TestSyslogLoggingOutletFacility(t *testing.T) {
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/zrepl/yaml-config"
+)
+
+// The top-level `include` key of the main config file is a glob pattern or a list of glob patterns,
+// relative to the directory of the main config file.
+// Included files may only contain `jobs` and `job_templates`, which are appended
+// to those of the main config file in the order of the patterns and, per pattern, in lexical order.
+const includeKey = "include"
+
+var includedFileKeys = map[string]bool{"jobs": true, jobTemplatesKey: true}
+
+// parseConfigBytes parses a main config file.
+// dir is the directory relative to which includes are resolved, empty for the working directory.
+// file is the path of the main config file, for errors.
+func parseConfigBytes(bytes []byte, dir, file string) (*Config, error) {
+	var doc map[interface{}]interface{}
+	if err := yaml.Unmarshal(bytes, &doc); err != nil || doc == nil {
+		return parseConfigYAML(bytes, file, true)
+	}
+	if _, ok := doc[includeKey]; !ok {
+		return parseConfigDoc(bytes, doc, file)
+	}
+	c, err := parseConfigWithIncludes(doc, dir, file)
+	if err != nil {
+		return nil, withFile(err, file)
+	}
+	return c, nil
+}
+
+func parseConfigWithIncludes(doc map[interface{}]interface{}, dir, file string) (*Config, error) {
+
+	files, err := resolveIncludes(doc[includeKey], dir)
+	if err != nil {
+		return nil, errors.Wrap(err, includeKey)
+	}
+	delete(doc, includeKey)
+
+	type includedFile struct {
+		path string
+		doc  map[interface{}]interface{}
+		raw  []byte
+	}
+	included := make([]includedFile, 0, len(files))
+	var templates []interface{}
+	if t, ok := doc[jobTemplatesKey].([]interface{}); ok {
+		templates = append(templates, t...)
+	} else if doc[jobTemplatesKey] != nil {
+		return nil, errors.Errorf("%s: must be a list", jobTemplatesKey)
+	}
+	for _, path := range files {
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read included config file")
+		}
+		var fdoc map[interface{}]interface{}
+		if err := yaml.Unmarshal(raw, &fdoc); err != nil {
+			return nil, validationErrorsFromYAML(err, path, map[string]int{})
+		}
+		for k := range fdoc {
+			if ks, ok := k.(string); !ok || !includedFileKeys[ks] {
+				return nil, withFile(errors.Errorf("key %q is not allowed, only `jobs` and `%s` may be specified in included files",
+					k, jobTemplatesKey), path)
+			}
+		}
+		if t, ok := fdoc[jobTemplatesKey].([]interface{}); ok {
+			templates = append(templates, t...)
+		} else if fdoc[jobTemplatesKey] != nil {
+			return nil, withFile(errors.Errorf("%s: must be a list", jobTemplatesKey), path)
+		}
+		included = append(included, includedFile{path, fdoc, raw})
+	}
+
+	// Parse the jobs of each included file on their own so that errors are attributed to the file.
+	// Templates may be defined in any of the files.
+	var jobSources []string // path of the file that defines each included job, in order
+	jobs, _ := doc["jobs"].([]interface{})
+	for _, f := range included {
+		fdoc := map[interface{}]interface{}{"jobs": f.doc["jobs"]}
+		if usesJobTemplates(f.doc) {
+			fdoc[jobTemplatesKey] = templates
+		}
+		c, err := parseConfigDoc(f.raw, fdoc, f.path)
+		if err != nil {
+			return nil, err
+		}
+		for range c.Jobs {
+			jobSources = append(jobSources, f.path)
+		}
+		if fjobs, ok := f.doc["jobs"].([]interface{}); ok {
+			jobs = append(jobs, fjobs...)
+		} else if f.doc["jobs"] != nil {
+			return nil, withFile(errors.New("jobs: must be a list"), f.path)
+		}
+	}
+
+	doc["jobs"] = jobs
+	if len(templates) > 0 {
+		doc[jobTemplatesKey] = templates
+	}
+	merged, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal merged config")
+	}
+	c, err := parseConfigYAML(merged, file, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// the jobs of the main file come first
+	mainJobs := len(c.Jobs) - len(jobSources)
+	jobNames := make(map[string]string) // job name => source
+	for i, j := range c.Jobs {
+		source, sourceFile := "the main config file", file
+		if i >= mainJobs {
+			sourceFile = jobSources[i-mainJobs]
+			source = fmt.Sprintf("included config file %q", sourceFile)
+		}
+		if other, ok := jobNames[j.Name()]; ok {
+			return nil, withFile(errors.Errorf("job %q is already defined in %s", j.Name(), other), sourceFile)
+		}
+		jobNames[j.Name()] = source
+	}
+	return c, nil
+}
+
+func resolveIncludes(raw interface{}, dir string) ([]string, error) {
+	var patterns []string
+	switch v := raw.(type) {
+	case string:
+		patterns = []string{v}
+	case []interface{}:
+		for _, p := range v {
+			s, ok := p.(string)
+			if !ok {
+				return nil, errors.Errorf("must be a string or a list of strings")
+			}
+			patterns = append(patterns, s)
+		}
+	default:
+		return nil, errors.Errorf("must be a string or a list of strings")
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	for _, p := range patterns {
+		if p == "" {
+			return nil, errors.New("pattern must not be empty")
+		}
+		if !filepath.IsAbs(p) && dir != "" {
+			p = filepath.Join(dir, p)
+		}
+		matches, err := filepath.Glob(p) // sorted
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pattern %q", p)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(p, "*?[") {
+			return nil, errors.Errorf("included config file %q does not exist", p)
+		}
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				files = append(files, m)
+			}
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-config-include")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	write := func(name, content string) {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, ioutil.WriteFile(p, []byte(content), 0644))
+	}
+	snapJob := func(name string) string {
+		return `
+- name: ` + name + `
+  type: snap
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep:
+    - type: last_n
+      count: 10
+`
+	}
+
+	write("zrepl.yml", `
+global:
+  logging:
+  - type: stdout
+    level: info
+    format: human
+include:
+- conf.d/*.yml
+- extra.yml
+`+"jobs:"+snapJob("main"))
+	write("conf.d/b.yml", "jobs:"+snapJob("b"))
+	write("conf.d/a.yml", `
+job_templates:
+- name: t
+  job:
+    name: "tmpl_${id}"
+    type: snap
+    filesystems: {"<": true}
+    snapshotting:
+      type: manual
+    pruning:
+      keep:
+      - type: last_n
+        count: 10
+jobs:`+snapJob("a"))
+	write("extra.yml", `
+jobs:
+- template: t
+  instances: [{id: x}]
+`)
+	write("conf.d/ignored.yaml", "this is not matched by the pattern")
+
+	c, err := ParseConfig(filepath.Join(dir, "zrepl.yml"))
+	require.NoError(t, err)
+	var names []string
+	for _, j := range c.Jobs {
+		names = append(names, j.Name())
+	}
+	assert.Equal(t, []string{"main", "a", "b", "tmpl_x"}, names, "main file first, then in pattern and lexical order")
+	assert.Len(t, *c.Global.Logging, 1)
+
+	expectErr := func(file string, content string, contains ...string) {
+		t.Helper()
+		write(file, content)
+		_, err := ParseConfig(filepath.Join(dir, "zrepl.yml"))
+		require.Error(t, err)
+		for _, s := range contains {
+			assert.Contains(t, err.Error(), s)
+		}
+	}
+	// errors are attributed to the included file
+	expectErr("conf.d/b.yml", "jobs:\n- name: b\n  type: nonexistent\n", "conf.d/b.yml")
+	expectErr("conf.d/b.yml", "global: {}\n", "conf.d/b.yml", "not allowed")
+	expectErr("conf.d/b.yml", "jobs:"+snapJob("a"), `conf.d/b.yml`, `job "a" is already defined in included config file`, "conf.d/a.yml")
+	expectErr("conf.d/b.yml", "jobs:"+snapJob("main"), `conf.d/b.yml`, "main config file")
+	expectErr("conf.d/b.yml", "include: [other.yml]\n", "not allowed")
+	write("conf.d/b.yml", "jobs:"+snapJob("b"))
+
+	// an empty conf.d is fine, a missing file is not
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "conf.d")))
+	expectErr("zrepl.yml", "include: [conf.d/*.yml, missing.yml]\n", "missing.yml", "does not exist")
+	write("zrepl.yml", "include: conf.d/*.yml\n")
+	c, err = ParseConfig(filepath.Join(dir, "zrepl.yml"))
+	require.NoError(t, err)
+	assert.Empty(t, c.Jobs)
+}
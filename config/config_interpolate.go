@@ -0,0 +1,167 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Values of the following keys are interpolated on the YAML level before the config is parsed,
+// wherever the key occurs in the config, including map keys and list elements in the value:
+//
+//	${NAME}        the environment variable NAME, which must be set
+//	$(file:PATH)   the contents of the file at the absolute PATH, without trailing newlines
+//	$$             a literal $
+var interpolatedKeys = map[string]bool{
+	// hosts
+	"address": true,
+	"host":    true,
+	"user":    true,
+	"url":     true,
+	// identities
+	"client_identity":   true,
+	"client_identities": true,
+	"client_cns":        true,
+	"server_cn":         true,
+	"clients":           true,
+	// secrets and their files
+	"dsn":              true,
+	"headers":          true,
+	"identity_file":    true,
+	"password_file":    true,
+	"token_file":       true,
+	"hmac_secret_file": true,
+	"ca":               true,
+	"cert":             true,
+	"key":              true,
+	// datasets
+	"root_fs": true,
+}
+
+var interpolationRE = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)\}|\$\(file:([^)]*)\)`)
+
+// interpolateConfig interpolates the values of interpolatedKeys in doc in place.
+func interpolateConfig(doc map[interface{}]interface{}) (changed bool, err error) {
+	i := interpolator{lookupEnv: os.LookupEnv, readFile: ioutil.ReadFile}
+	if err := i.walk(doc, "", false); err != nil {
+		return false, err
+	}
+	return i.changed, nil
+}
+
+type interpolator struct {
+	lookupEnv func(string) (string, bool)
+	readFile  func(string) ([]byte, error)
+	changed   bool
+}
+
+// walk interpolates the strings below v if interpolate is true,
+// and the values of interpolatedKeys otherwise.
+func (i *interpolator) walk(v interface{}, path string, interpolate bool) error {
+	switch v := v.(type) {
+	case []interface{}:
+		for idx, e := range v {
+			p := fmt.Sprintf("%s[%d]", path, idx)
+			if s, ok := e.(string); ok && interpolate {
+				r, err := i.interpolate(s, p)
+				if err != nil {
+					return err
+				}
+				v[idx] = r
+				continue
+			}
+			if err := i.walk(e, p, interpolate); err != nil {
+				return err
+			}
+		}
+	case map[interface{}]interface{}:
+		// collect the keys first because interpolated keys are replaced
+		keys := make([]interface{}, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		for _, k := range keys {
+			e := v[k]
+			ks, isString := k.(string)
+			p := fmt.Sprintf("%s.%v", path, k)
+			if path == "" {
+				p = fmt.Sprint(k)
+			}
+			if interpolate && isString {
+				rk, err := i.interpolate(ks, p)
+				if err != nil {
+					return err
+				}
+				if rk != ks {
+					if _, ok := v[rk]; ok {
+						return errors.Errorf("%s: duplicate map key %q after interpolation", p, rk)
+					}
+					delete(v, k)
+					v[rk] = e
+					k = rk
+				}
+			}
+			childInterpolate := interpolate || isString && interpolatedKeys[ks]
+			if s, ok := e.(string); ok && childInterpolate {
+				r, err := i.interpolate(s, p)
+				if err != nil {
+					return err
+				}
+				v[k] = r
+				continue
+			}
+			if err := i.walk(e, p, childInterpolate); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (i *interpolator) interpolate(s, path string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var err error
+	ret := interpolationRE.ReplaceAllStringFunc(s, func(match string) string {
+		if err != nil {
+			return match
+		}
+		m := interpolationRE.FindStringSubmatch(match)
+		switch {
+		case match == "$$":
+			return "$"
+		case m[1] != "":
+			val, ok := i.lookupEnv(m[1])
+			if !ok {
+				err = errors.Errorf("%s: environment variable %q is not set", path, m[1])
+				return match
+			}
+			return val
+		default:
+			file := m[2]
+			if !filepath.IsAbs(file) {
+				err = errors.Errorf("%s: file path %q in $(file:...) must be absolute", path, file)
+				return match
+			}
+			content, readErr := i.readFile(file)
+			if readErr != nil {
+				err = errors.Wrapf(readErr, "%s: cannot read file for $(file:...)", path)
+				return match
+			}
+			return strings.TrimRight(string(content), "\r\n")
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	if ret != s {
+		i.changed = true
+	}
+	return ret, nil
+}
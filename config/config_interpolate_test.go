@@ -0,0 +1,105 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-config-interpolate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	secret := filepath.Join(dir, "identity")
+	require.NoError(t, ioutil.WriteFile(secret, []byte("prod-client\n"), 0600))
+
+	require.NoError(t, os.Setenv("ZREPL_TEST_BACKUP_HOST", "backup.prod.example.com"))
+	defer os.Unsetenv("ZREPL_TEST_BACKUP_HOST")
+	require.NoError(t, os.Setenv("ZREPL_TEST_SITE", "prod"))
+	defer os.Unsetenv("ZREPL_TEST_SITE")
+
+	c := testValidConfig(t, `
+jobs:
+- name: "push_${ZREPL_TEST_SITE}"
+  type: push
+  filesystems: {"<": true}
+  connect:
+    type: tcp
+    address: "${ZREPL_TEST_BACKUP_HOST}:8888"
+  snapshotting:
+    type: manual
+  pruning:
+    keep_sender:
+    - type: last_n
+      count: 10
+    keep_receiver:
+    - type: last_n
+      count: 10
+- name: sink
+  type: sink
+  root_fs: "pool/${ZREPL_TEST_SITE}/sink"
+  serve:
+    type: tcp
+    listen: ":8888"
+    clients: {
+      "10.0.0.1": "$(file:`+secret+`)",
+      "10.0.0.2": "price$$"
+    }
+`)
+	push := c.Jobs[0].Ret.(*PushJob)
+	assert.Equal(t, "push_${ZREPL_TEST_SITE}", push.Name, "only select fields are interpolated")
+	assert.Equal(t, "backup.prod.example.com:8888", push.Connect.Ret.(*TCPConnect).Address)
+	sink := c.Jobs[1].Ret.(*SinkJob)
+	assert.Equal(t, "pool/prod/sink", sink.RootFS)
+	assert.Equal(t, map[string]string{"10.0.0.1": "prod-client", "10.0.0.2": "price$"}, sink.Serve.Ret.(*TCPServe).Clients)
+}
+
+func TestInterpolationErrors(t *testing.T) {
+	i := interpolator{
+		lookupEnv: func(name string) (string, bool) { return "value", name == "SET" },
+		readFile:  func(path string) ([]byte, error) { return nil, os.ErrNotExist },
+	}
+	s, err := i.interpolate("${SET}-$$-$-$(file)", "p")
+	require.NoError(t, err)
+	assert.Equal(t, "value-$-$-$(file)", s)
+
+	_, err = i.interpolate("${UNSET}", "jobs[0].connect.address")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "jobs[0].connect.address")
+	assert.Contains(t, err.Error(), "UNSET")
+	_, err = i.interpolate("$(file:relative/path)", "p")
+	assert.Error(t, err)
+	_, err = i.interpolate("$(file:/nonexistent)", "p")
+	assert.Error(t, err)
+
+	// keys are interpolated below select fields only
+	doc := map[interface{}]interface{}{
+		"clients": map[interface{}]interface{}{"${SET}": "${SET}", "value": "x"},
+	}
+	assert.Error(t, i.walk(doc, "", false), "duplicate key after interpolation")
+}
+
+func TestJobTemplatesPassInterpolation(t *testing.T) {
+	require.NoError(t, os.Setenv("ZREPL_TEST_ROOT", "pool/backups"))
+	defer os.Unsetenv("ZREPL_TEST_ROOT")
+	c := testValidConfig(t, `
+job_templates:
+- name: sink
+  job:
+    type: sink
+    name: "sink_${port}"
+    root_fs: "$${ZREPL_TEST_ROOT}/${port}"
+    serve:
+      type: tcp
+      listen: ":${port}"
+      clients: {"10.0.0.1": "client"}
+jobs:
+- template: sink
+  instances: [{port: 8888}]
+`)
+	assert.Equal(t, "pool/backups/8888", c.Jobs[0].Ret.(*SinkJob).RootFS)
+}
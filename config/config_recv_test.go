@@ -132,3 +132,33 @@ jobs:
 	})
 
 }
+
+func TestRecvPlaceholderProperties(t *testing.T) {
+	tmpl := `
+jobs:
+- name: foo
+  type: sink
+  root_fs: "zreplplatformtest"
+  serve:
+    type: local
+    listener_name: foo
+  recv:
+    placeholder:
+      encryption: inherit
+%s
+`
+	placeholder := func(t *testing.T, s string) *PlaceholderRecvOptions {
+		c := testValidConfig(t, fmt.Sprintf(tmpl, s))
+		return c.Jobs[0].Ret.(*SinkJob).Recv.Placeholder
+	}
+
+	assert.Nil(t, placeholder(t, "").Properties, "nil means the default")
+	assert.Equal(t, map[zfsprop.Property]string{}, placeholder(t, "      properties: {}").Properties,
+		"an empty map sets no properties, i.e., the placeholders inherit their mountpoint")
+	p := placeholder(t, `
+      properties:
+        canmount: "off"
+        readonly: "on"
+`)
+	assert.Equal(t, map[zfsprop.Property]string{"canmount": "off", "readonly": "on"}, p.Properties)
+}
@@ -0,0 +1,264 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zrepl/yaml-config"
+
+	"github.com/zrepl/zrepl/util/datasizeunit"
+)
+
+// RenderYAML renders c in the syntax of the config file, with the defaults filled in
+// and includes, job templates and interpolations applied.
+// Parsing the output results in the same config, except for the redacted values.
+// The values of renderRedactedKeys are replaced by renderRedacted because they may contain passwords.
+func RenderYAML(c *Config) ([]byte, error) {
+	v, err := renderValue(reflect.ValueOf(c))
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(v)
+}
+
+const renderRedacted = "<redacted>"
+
+var renderRedactedKeys = map[string]bool{
+	"dsn":     true,
+	"headers": true,
+}
+
+var (
+	renderDurationType = reflect.TypeOf(time.Duration(0))
+	renderSpecialTypes = map[reflect.Type]func(v reflect.Value) interface{}{
+		// time.ParseDuration does not support the units d and w
+		renderDurationType: func(v reflect.Value) interface{} {
+			return renderDurationUnits(time.Duration(v.Int()), renderDurationUnitsGo)
+		},
+		reflect.TypeOf(Duration{}): func(v reflect.Value) interface{} {
+			return renderDuration(v.Interface().(Duration).Duration())
+		},
+		reflect.TypeOf(PositiveDuration{}): func(v reflect.Value) interface{} {
+			return renderDuration(v.Interface().(PositiveDuration).Duration())
+		},
+		reflect.TypeOf(PositiveDurationOrManual{}): func(v reflect.Value) interface{} {
+			i := v.Interface().(PositiveDurationOrManual)
+			if i.Manual {
+				return "manual"
+			}
+			return renderDuration(i.Interval)
+		},
+		reflect.TypeOf(CronSpec{}): func(v reflect.Value) interface{} {
+			specs := v.Interface().(CronSpec).specs
+			if len(specs) == 1 {
+				return specs[0]
+			}
+			return specs
+		},
+		reflect.TypeOf(RetentionIntervalList{}): func(v reflect.Value) interface{} {
+			return renderRetentionGrid(v.Interface().(RetentionIntervalList))
+		},
+		reflect.TypeOf(datasizeunit.Bits{}): func(v reflect.Value) interface{} {
+			return renderBits(v.Interface().(datasizeunit.Bits))
+		},
+		reflect.TypeOf(Secret{}): func(v reflect.Value) interface{} {
+			s := v.Interface().(Secret)
+			switch {
+			case s.IsZero():
+				return nil
+			case s.Env != "":
+				return yaml.MapSlice{{Key: "env", Value: s.Env}}
+			case len(s.Command) > 0:
+				return yaml.MapSlice{{Key: "command", Value: s.Command}}
+			default:
+				return s.File
+			}
+		},
+		reflect.TypeOf(SyslogFacility(0)): func(v reflect.Value) interface{} {
+			for name, f := range syslogFacilities {
+				if SyslogFacility(f) == v.Interface().(SyslogFacility) {
+					return name
+				}
+			}
+			return nil
+		},
+	}
+)
+
+// renderValue returns the YAML representation of v, nil if v is unset.
+func renderValue(v reflect.Value) (interface{}, error) {
+	if f, ok := renderSpecialTypes[v.Type()]; ok {
+		return f(v), nil
+	}
+	if v.Kind() != reflect.Ptr && reflect.PtrTo(v.Type()).Implements(schemaEnumType) {
+		// the enum value, which includes the `type` field
+		return renderValue(v.FieldByName("Ret"))
+	}
+	if v.Kind() != reflect.Ptr && reflect.PtrTo(v.Type()).Implements(schemaUnmarshalerType) {
+		return nil, fmt.Errorf("cannot render type %s", v.Type())
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return renderValue(v.Elem())
+	case reflect.Struct:
+		m := yaml.MapSlice{}
+		if err := renderStruct(v, &m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+		l := make([]interface{}, v.Len())
+		for i := range l {
+			e, err := renderValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			l[i] = e
+		}
+		return l, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		m := yaml.MapSlice{}
+		for _, k := range keys {
+			e, err := renderValue(v.MapIndex(k))
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, yaml.MapItem{Key: k.Interface(), Value: e})
+		}
+		return m, nil
+	default:
+		return v.Interface(), nil
+	}
+}
+
+// renderStruct appends the fields of v to m, using the yaml tags like yaml-config does.
+func renderStruct(v reflect.Value, m *yaml.MapSlice) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		tag := parseSchemaTag(f)
+		if tag.skip {
+			continue
+		}
+		if tag.inline {
+			if err := renderStruct(v.Field(i), m); err != nil {
+				return err
+			}
+			continue
+		}
+		e, err := renderValue(v.Field(i))
+		if err != nil {
+			return fmt.Errorf("%s: %s", tag.name, err)
+		}
+		if e == nil {
+			continue
+		}
+		if renderRedactedKeys[tag.name] && e != "" {
+			e = renderRedactedValue(e)
+		}
+		*m = append(*m, yaml.MapItem{Key: tag.name, Value: e})
+	}
+	return nil
+}
+
+// keeps the keys of maps
+func renderRedactedValue(e interface{}) interface{} {
+	if m, ok := e.(yaml.MapSlice); ok {
+		r := make(yaml.MapSlice, len(m))
+		for i, item := range m {
+			r[i] = yaml.MapItem{Key: item.Key, Value: renderRedacted}
+		}
+		return r
+	}
+	return renderRedacted
+}
+
+type renderDurationUnit struct {
+	suffix string
+	d      time.Duration
+}
+
+var (
+	// largest first
+	renderDurationUnitsGo    = []renderDurationUnit{{"h", time.Hour}, {"m", time.Minute}, {"s", time.Second}, {"ms", time.Millisecond}}
+	renderDurationUnitsZrepl = []renderDurationUnit{{"w", 7 * 24 * time.Hour}, {"d", 24 * time.Hour}, {"h", time.Hour}, {"m", time.Minute}, {"s", time.Second}}
+)
+
+// renderDuration returns d in the syntax of parseDuration.
+func renderDuration(d time.Duration) string {
+	return renderDurationUnits(d, renderDurationUnitsZrepl)
+}
+
+// renderDurationUnits returns d in the largest of units that represents it exactly.
+func renderDurationUnits(d time.Duration, units []renderDurationUnit) string {
+	if d == 0 {
+		return "0s"
+	}
+	for _, u := range units {
+		if d%u.d == 0 {
+			return fmt.Sprintf("%d%s", d/u.d, u.suffix)
+		}
+	}
+	return d.String()
+}
+
+func renderRetentionGrid(l RetentionIntervalList) string {
+	var parts []string
+	for i := 0; i < len(l); {
+		n := 1
+		for i+n < len(l) && l[i+n] == l[i] {
+			n++
+		}
+		part := fmt.Sprintf("%dx%s", n, renderDuration(l[i].length))
+		switch l[i].keepCount {
+		case 1:
+		case RetentionGridKeepCountAll:
+			part += "(keep=all)"
+		default:
+			part += fmt.Sprintf("(keep=%d)", l[i].keepCount)
+		}
+		parts = append(parts, part)
+		i += n
+	}
+	return strings.Join(parts, " | ")
+}
+
+func renderBits(b datasizeunit.Bits) string {
+	bytes := b.ToBytes()
+	if bytes != float64(int64(bytes)) {
+		return strconv.FormatFloat(b.ToBits(), 'f', -1, 64) + " bit"
+	}
+	n := int64(bytes)
+	for _, u := range []struct {
+		suffix string
+		n      int64
+	}{
+		{"TiB", 1 << 40},
+		{"GiB", 1 << 30},
+		{"MiB", 1 << 20},
+		{"KiB", 1 << 10},
+	} {
+		if n != 0 && n%u.n == 0 {
+			return fmt.Sprintf("%d %s", n/u.n, u.suffix)
+		}
+	}
+	return fmt.Sprintf("%d B", n)
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderYAMLSampleConfigsRoundTrip(t *testing.T) {
+	paths, err := filepath.Glob("./samples/*")
+	require.NoError(t, err)
+	paths = append(paths, "../packaging/systemd-default-zrepl.yml")
+
+	for _, p := range paths {
+		if path.Ext(p) != ".yml" {
+			continue
+		}
+		t.Run(p, func(t *testing.T) {
+			c, err := ParseConfig(p)
+			require.NoError(t, err)
+			rendered, err := RenderYAML(c)
+			require.NoError(t, err)
+
+			reparsed, err := ParseConfigBytes(rendered)
+			require.NoError(t, err, "%s", rendered)
+			if !strings.Contains(string(rendered), renderRedacted) {
+				assert.True(t, reflect.DeepEqual(c, reparsed), "%s", rendered)
+			}
+			again, err := RenderYAML(reparsed)
+			require.NoError(t, err)
+			assert.Equal(t, string(rendered), string(again))
+		})
+	}
+}
+
+func TestRenderYAML(t *testing.T) {
+	c := testValidConfig(t, `
+jobs:
+- name: foo
+  type: push
+  connect:
+    type: local
+    listener_name: foo
+    client_identity: bar
+  filesystems: {"<": true}
+  snapshotting:
+    type: cron
+    prefix: zrepl_
+    cron: ["0 * * * *", "30 12 * * *"]
+  replication:
+    concurrency:
+      steps: 2
+  hooks:
+  - type: webhook
+    url: https://example.com/hook
+    headers:
+      Authorization: Bearer secret-token
+  pruning:
+    keep_sender:
+    - type: not_replicated
+    keep_receiver:
+    - type: grid
+      grid: 1x1h(keep=all) | 24x1h | 14x1d | 2x7d(keep=3)
+      regex: "^zrepl_"
+`)
+	rendered, err := RenderYAML(c)
+	require.NoError(t, err)
+	r := string(rendered)
+	t.Log(r)
+
+	assert.NotContains(t, r, "ret:")
+	assert.Contains(t, r, "grid: 1x1h(keep=all) | 24x1h | 14x1d | 2x1w(keep=3)\n")
+	assert.Contains(t, r, "- 0 * * * *\n")
+	assert.Contains(t, r, "- 30 12 * * *\n")
+	assert.Contains(t, r, "Authorization: <redacted>\n")
+	assert.NotContains(t, r, "secret-token")
+	assert.Contains(t, r, "steps: 2\n")
+	// defaults
+	assert.Contains(t, r, "size_estimates: 4\n")
+	assert.Contains(t, r, "timestamp_format: dense\n")
+	assert.NotContains(t, r, "hmac_secret_file")
+
+	_, err = ParseConfigBytes(rendered)
+	require.NoError(t, err)
+}
+
+func TestRenderDuration(t *testing.T) {
+	assert.Equal(t, "0s", renderDuration(0))
+	assert.Equal(t, "90s", renderDuration(90e9))
+	assert.Equal(t, "2w", renderDuration(14*24*3600e9))
+	assert.Equal(t, "1500ms", renderDurationUnits(1500e6, renderDurationUnitsGo))
+}
@@ -0,0 +1,391 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zrepl/yaml-config"
+
+	"github.com/zrepl/zrepl/util/datasizeunit"
+)
+
+// Schema is a JSON Schema (draft-07) of the config file, see JSONSchema.
+// Only the keywords used for the config are supported.
+type Schema struct {
+	SchemaURI   string `json:"$schema,omitempty"`
+	Ref         string `json:"$ref,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// a string or a list of strings
+	Type       interface{}        `json:"type,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	// false or a *Schema
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Const                interface{}        `json:"const,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	Definitions          map[string]*Schema `json:"definitions,omitempty"`
+}
+
+const (
+	schemaTypeObject  = "object"
+	schemaTypeArray   = "array"
+	schemaTypeString  = "string"
+	schemaTypeInteger = "integer"
+	schemaTypeNumber  = "number"
+	schemaTypeBoolean = "boolean"
+)
+
+// enum types such as JobEnum implement enumType: the YAML key `type` selects one of enumTypes.
+type enumType interface {
+	enumTypes() map[string]interface{}
+}
+
+// JSONSchema returns the schema of the config file, including includes and job templates.
+func JSONSchema() *Schema {
+	g := &schemaGenerator{defs: make(map[string]*Schema)}
+	root := g.schemaOf(reflect.TypeOf(Config{}))
+	def := g.defs["Config"]
+
+	// keys that are handled before the config is parsed
+	def.Properties[includeKey] = &Schema{
+		Description: "glob pattern(s) of files with additional jobs and job templates",
+		AnyOf: []*Schema{
+			{Type: schemaTypeString},
+			{Type: schemaTypeArray, Items: &Schema{Type: schemaTypeString}},
+		},
+	}
+	def.Properties[jobTemplatesKey] = &Schema{
+		Type: schemaTypeArray,
+		Items: &Schema{
+			Type: schemaTypeObject,
+			Properties: map[string]*Schema{
+				"name": {Type: schemaTypeString},
+				"job":  {Type: schemaTypeObject, Description: "a job with ${VAR} placeholders"},
+			},
+			Required:             []string{"name", "job"},
+			AdditionalProperties: false,
+		},
+	}
+	def.Properties["jobs"].Items = &Schema{AnyOf: []*Schema{
+		def.Properties["jobs"].Items,
+		{
+			Type: schemaTypeObject,
+			Properties: map[string]*Schema{
+				jobTemplateRefKey: {Type: schemaTypeString},
+				jobTemplateInstances: {
+					Type:  schemaTypeArray,
+					Items: &Schema{Type: schemaTypeObject, Description: "the variables of the instance"},
+				},
+			},
+			Required:             []string{jobTemplateRefKey, jobTemplateInstances},
+			AdditionalProperties: false,
+		},
+	}}
+
+	root.SchemaURI = "http://json-schema.org/draft-07/schema#"
+	root.Title = "zrepl configuration"
+	root.Definitions = g.defs
+	return root
+}
+
+type schemaGenerator struct {
+	defs map[string]*Schema
+}
+
+var (
+	schemaDurationType = reflect.TypeOf(time.Duration(0))
+	schemaSpecialTypes = map[reflect.Type]func() *Schema{
+		schemaDurationType: func() *Schema {
+			return &Schema{Type: []string{schemaTypeString, schemaTypeInteger}, Description: "duration, e.g. 10s, 5m or 1h"}
+		},
+		reflect.TypeOf(Duration{}): func() *Schema { return &Schema{Type: schemaTypeString, Description: "duration, e.g. 10m, 1h or 30d"} },
+		reflect.TypeOf(PositiveDuration{}): func() *Schema {
+			return &Schema{Type: schemaTypeString, Description: "positive duration, e.g. 10m, 1h or 30d"}
+		},
+		reflect.TypeOf(PositiveDurationOrManual{}): func() *Schema {
+			return &Schema{Type: schemaTypeString, Description: `positive duration or "manual"`}
+		},
+		reflect.TypeOf(CronSpec{}): func() *Schema {
+			return &Schema{
+				Description: "cron spec or list of cron specs",
+				AnyOf: []*Schema{
+					{Type: schemaTypeString},
+					{Type: schemaTypeArray, Items: &Schema{Type: schemaTypeString}},
+				},
+			}
+		},
+		reflect.TypeOf(RetentionIntervalList{}): func() *Schema {
+			return &Schema{Type: schemaTypeString, Description: "retention grid, e.g. 1x1h(keep=all) | 24x1h | 14x1d"}
+		},
+		reflect.TypeOf(datasizeunit.Bits{}): func() *Schema {
+			return &Schema{Type: schemaTypeString, Description: "data size or rate, e.g. 10 MiB or 100 Mbit"}
+		},
+		reflect.TypeOf(Secret{}): func() *Schema {
+			source := func(key string, s *Schema) *Schema {
+				return &Schema{Type: schemaTypeObject, Properties: map[string]*Schema{key: s}, Required: []string{key}, AdditionalProperties: false}
+			}
+			return &Schema{
+				Description: "secret: file path, or one of {file: PATH}, {env: NAME}, {command: [PATH, ARGS]}",
+				AnyOf: []*Schema{
+					{Type: schemaTypeString},
+					source("file", &Schema{Type: schemaTypeString}),
+					source("env", &Schema{Type: schemaTypeString}),
+					source("command", &Schema{Type: schemaTypeArray, Items: &Schema{Type: schemaTypeString}}),
+				},
+			}
+		},
+		reflect.TypeOf(SyslogFacility(0)): func() *Schema {
+			s := &Schema{Type: schemaTypeString}
+			for _, f := range sortedKeys(syslogFacilities) {
+				s.Enum = append(s.Enum, f)
+			}
+			return s
+		},
+	}
+	schemaUnmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+	schemaEnumType        = reflect.TypeOf((*enumType)(nil)).Elem()
+)
+
+func (g *schemaGenerator) schemaOf(t reflect.Type) *Schema {
+	if f, ok := schemaSpecialTypes[t]; ok {
+		return f()
+	}
+	if reflect.PtrTo(t).Implements(schemaEnumType) {
+		return g.enumSchema(t)
+	}
+	if reflect.PtrTo(t).Implements(schemaUnmarshalerType) {
+		// custom format that the schema cannot express
+		return &Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schemaOf(t.Elem())
+	case reflect.Struct:
+		g.structSchema(t)
+		return &Schema{Ref: "#/definitions/" + t.Name()}
+	case reflect.Slice:
+		return &Schema{Type: schemaTypeArray, Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: schemaTypeObject, AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.String:
+		return &Schema{Type: schemaTypeString}
+	case reflect.Bool:
+		return &Schema{Type: schemaTypeBoolean}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: schemaTypeInteger}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &Schema{Type: schemaTypeInteger, Minimum: &zero}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: schemaTypeNumber}
+	default:
+		return &Schema{}
+	}
+}
+
+func (g *schemaGenerator) enumSchema(t reflect.Type) *Schema {
+	if _, ok := g.defs[t.Name()]; !ok {
+		types := reflect.New(t).Interface().(enumType).enumTypes()
+		def := &Schema{Description: "selected by `type`"}
+		g.defs[t.Name()] = def // before the recursion
+		for _, name := range sortedKeys(types) {
+			vt := reflect.TypeOf(types[name]).Elem()
+			// a definition per enum value because `type` is a constant
+			variant := g.structProperties(vt)
+			variant.Properties["type"] = &Schema{Const: name}
+			def.OneOf = append(def.OneOf, variant)
+		}
+	}
+	return &Schema{Ref: "#/definitions/" + t.Name()}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) {
+	if _, ok := g.defs[t.Name()]; ok {
+		return
+	}
+	g.defs[t.Name()] = &Schema{} // before the recursion
+	*g.defs[t.Name()] = *g.structProperties(t)
+}
+
+func (g *schemaGenerator) structProperties(t reflect.Type) *Schema {
+	s := &Schema{Type: schemaTypeObject, Properties: make(map[string]*Schema), AdditionalProperties: false}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		tag := parseSchemaTag(f)
+		if tag.skip {
+			continue
+		}
+		if tag.inline {
+			inner := g.structProperties(f.Type)
+			for k, v := range inner.Properties {
+				s.Properties[k] = v
+			}
+			s.Required = append(s.Required, inner.Required...)
+			continue
+		}
+		fs := g.schemaOf(f.Type)
+		if tag.defaultStr != nil {
+			fs = withSchemaDefault(fs, *tag.defaultStr)
+		}
+		if (tag.positive || tag.zeropositive) && isSchemaNumber(fs) {
+			bound := 0.0
+			fs = copySchema(fs)
+			if tag.positive {
+				fs.ExclusiveMinimum = &bound
+			} else {
+				fs.Minimum = &bound
+			}
+		}
+		s.Properties[tag.name] = fs
+		if !tag.optional {
+			s.Required = append(s.Required, tag.name)
+		}
+	}
+	sort.Strings(s.Required)
+	return s
+}
+
+func isSchemaNumber(s *Schema) bool {
+	return s.Type == schemaTypeInteger || s.Type == schemaTypeNumber
+}
+
+func copySchema(s *Schema) *Schema {
+	c := *s
+	return &c
+}
+
+// withSchemaDefault does not modify s because it may be shared.
+func withSchemaDefault(s *Schema, defaultStr string) *Schema {
+	var v interface{}
+	if err := yaml.Unmarshal([]byte(defaultStr), &v); err != nil || v == nil {
+		return s
+	}
+	s = copySchema(s)
+	s.Default = jsonCompatible(v)
+	return s
+}
+
+// jsonCompatible converts the maps decoded from YAML to maps with string keys.
+func jsonCompatible(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonCompatible(e)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = jsonCompatible(e)
+		}
+		return l
+	default:
+		return v
+	}
+}
+
+type schemaTag struct {
+	name                   string
+	skip, inline, optional bool
+	positive, zeropositive bool
+	defaultStr             *string
+}
+
+// parseSchemaTag interprets the yaml tag of f like yaml-config does.
+func parseSchemaTag(f reflect.StructField) (t schemaTag) {
+	tag := f.Tag.Get("yaml")
+	if tag == "-" {
+		t.skip = true
+		return t
+	}
+	fields := strings.Split(tag, ",")
+	t.name = fields[0]
+	if t.name == "" {
+		t.name = strings.ToLower(f.Name)
+	}
+	for _, flag := range fields[1:] {
+		switch {
+		case strings.HasPrefix(flag, "default="):
+			d := flag[len("default="):]
+			t.defaultStr = &d
+			t.optional = true
+		case flag == "inline":
+			t.inline = true
+		case flag == "optional", flag == "fromdefaults":
+			t.optional = true
+		case flag == "positive":
+			t.positive = true
+		case flag == "zeropositive":
+			t.zeropositive = true
+		}
+	}
+	return t
+}
+
+func sortedKeys(m interface{}) []string {
+	rv := reflect.ValueOf(m)
+	keys := make([]string, 0, rv.Len())
+	for _, k := range rv.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// describe returns a short description of the values that s accepts, for error messages.
+func (s *Schema) describe(root *Schema) string {
+	s = s.resolve(root)
+	switch {
+	case s.Const != nil:
+		return strconv.Quote(fmt.Sprint(s.Const))
+	case len(s.Enum) > 0:
+		quoted := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			quoted[i] = strconv.Quote(fmt.Sprint(e))
+		}
+		return "one of " + strings.Join(quoted, ", ")
+	case s.Type != nil:
+		var types []string
+		switch t := s.Type.(type) {
+		case string:
+			types = []string{t}
+		case []string:
+			types = t
+		}
+		d := strings.Join(types, " or ")
+		if s.Description != "" {
+			d += " (" + s.Description + ")"
+		}
+		return d
+	case len(s.AnyOf) > 0:
+		alts := make([]string, len(s.AnyOf))
+		for i, a := range s.AnyOf {
+			alts[i] = a.describe(root)
+		}
+		return strings.Join(alts, " or ")
+	default:
+		return s.Description
+	}
+}
+
+func (s *Schema) resolve(root *Schema) *Schema {
+	for s.Ref != "" {
+		s = root.Definitions[strings.TrimPrefix(s.Ref, "#/definitions/")]
+	}
+	return s
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zrepl/yaml-config"
+)
+
+func TestJSONSchemaAcceptsSampleConfigs(t *testing.T) {
+	_, err := json.Marshal(JSONSchema())
+	require.NoError(t, err)
+
+	paths, err := filepath.Glob("./samples/*")
+	require.NoError(t, err)
+	paths = append(paths, "../packaging/systemd-default-zrepl.yml")
+	for _, p := range paths {
+		if path.Ext(p) != ".yml" {
+			continue
+		}
+		t.Run(p, func(t *testing.T) {
+			raw, err := ioutil.ReadFile(p)
+			require.NoError(t, err)
+			var doc map[interface{}]interface{}
+			require.NoError(t, yaml.Unmarshal(raw, &doc))
+			assert.Empty(t, validateConfigDoc(doc, p, nil))
+		})
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	_, err := ParseConfigBytes([]byte(`
+jobs:
+- name: foo
+  type: snap
+  filesystems: {"<": true}
+  snapshotting:
+    type: periodic
+    prefix: zrepl_
+    interval: 10m
+    bogus: 1
+  pruning:
+    keep:
+    - type: nonexistent
+`))
+	require.Error(t, err)
+	verrs, ok := err.(ValidationErrors)
+	require.True(t, ok, "%T", err)
+	require.Len(t, verrs, 2)
+	assert.Equal(t, &ValidationError{
+		Line:     10,
+		Path:     "jobs[0].snapshotting.bogus",
+		Message:  `unknown field "bogus"`,
+		Expected: "one of the fields hooks, hooks_concurrency, interval, jitter, name_template, name_template_timezone, overrides, prefix, skip_unchanged, timestamp_format, type",
+	}, verrs[1])
+	assert.Equal(t, 13, verrs[0].Line)
+	assert.Equal(t, "jobs[0].pruning.keep[0].type", verrs[0].Path)
+	assert.Contains(t, verrs[0].Expected, `"last_n"`)
+
+	// errors that only yaml-config detects are passed through
+	_, err = ParseConfigBytes([]byte(`
+jobs:
+- name: foo
+  type: snap
+  filesystems: {"<": true}
+  snapshotting:
+    type: periodic
+    prefix: zrepl_
+    interval: 10x
+  pruning:
+    keep:
+    - type: last_n
+      count: 10
+`))
+	require.Error(t, err)
+	verrs = AsValidationErrors(err)
+	require.NotEmpty(t, verrs)
+	assert.Contains(t, verrs[0].Message, `"10x"`)
+	for _, e := range verrs[1:] {
+		assert.NotZero(t, e.Line, "%s", e)
+		assert.NotContains(t, e.Message, "line ")
+	}
+}
+
+func TestYAMLLineIndex(t *testing.T) {
+	lines := yamlLineIndex([]byte(`# comment
+global:
+  logging:
+    - type: stdout
+      level: info
+jobs:
+- name: a
+  hooks:
+  - type: command
+    path: |
+      not: a key
+- "name": b
+  filesystems: {"<": true}
+`))
+	assert.Equal(t, map[string]int{
+		"global":                  2,
+		"global.logging":          3,
+		"global.logging[0]":       4,
+		"global.logging[0].type":  4,
+		"global.logging[0].level": 5,
+		"jobs":                    6,
+		"jobs[0]":                 7,
+		"jobs[0].name":            7,
+		"jobs[0].hooks":           8,
+		"jobs[0].hooks[0]":        9,
+		"jobs[0].hooks[0].type":   9,
+		"jobs[0].hooks[0].path":   10,
+		"jobs[1]":                 12,
+		"jobs[1].name":            12,
+		"jobs[1].filesystems":     13,
+	}, lines)
+}
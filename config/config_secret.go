@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/zrepl/yaml-config"
+)
+
+// Secret references a key, password or token that is loaded when it is used, see package secret.
+// In YAML, it is either the path of a file or a map with exactly one of the keys:
+//
+//	file: PATH             the contents of the file at PATH
+//	env: NAME              the value of the environment variable NAME
+//	command: [PATH, ARGS]  the standard output of the command
+type Secret struct {
+	File    string   `yaml:"file,optional" json:",omitempty"`
+	Env     string   `yaml:"env,optional" json:",omitempty"`
+	Command []string `yaml:"command,optional" json:",omitempty"`
+}
+
+func (s *Secret) UnmarshalYAML(u func(interface{}, bool) error) error {
+	var path string
+	if err := u(&path, true); err == nil {
+		*s = Secret{File: path}
+		return nil
+	}
+	var in struct {
+		File    string   `yaml:"file,optional"`
+		Env     string   `yaml:"env,optional"`
+		Command []string `yaml:"command,optional"`
+	}
+	if err := u(&in, true); err != nil {
+		return errors.Wrap(err, "secret must be a file path or a map with one of the keys file, env or command")
+	}
+	*s = Secret(in)
+	sources := 0
+	for _, set := range []bool{s.File != "", s.Env != "", len(s.Command) > 0} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return errors.New("secret must specify exactly one of file, env or command")
+	}
+	return nil
+}
+
+var _ yaml.IsZeroer = Secret{}
+
+func (s Secret) IsZero() bool {
+	return s.File == "" && s.Env == "" && len(s.Command) == 0
+}
+
+// String describes the source of the secret, it never contains the secret.
+func (s Secret) String() string {
+	switch {
+	case s.File != "":
+		return fmt.Sprintf("file %q", s.File)
+	case s.Env != "":
+		return fmt.Sprintf("environment variable %q", s.Env)
+	case len(s.Command) > 0:
+		return fmt.Sprintf("command %q", s.Command[0])
+	default:
+		return "empty secret"
+	}
+}
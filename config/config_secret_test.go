@@ -0,0 +1,35 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zrepl/yaml-config"
+)
+
+func TestSecret(t *testing.T) {
+	parse := func(in string) (s struct {
+		S Secret `yaml:"s"`
+	}, err error) {
+		err = yaml.UnmarshalStrict([]byte(in), &s)
+		return s, err
+	}
+
+	s, err := parse("s: /etc/zrepl/key.pem")
+	require.NoError(t, err)
+	assert.Equal(t, Secret{File: "/etc/zrepl/key.pem"}, s.S)
+
+	s, err = parse("s: {env: KEY}")
+	require.NoError(t, err)
+	assert.Equal(t, Secret{Env: "KEY"}, s.S)
+
+	s, err = parse("s: {command: [/bin/get-secret, key]}")
+	require.NoError(t, err)
+	assert.Equal(t, Secret{Command: []string{"/bin/get-secret", "key"}}, s.S)
+
+	for _, invalid := range []string{"s: {}", "s: {env: A, file: /b}", "s: {unknown: x}", "s: [a]", "s: \"\""} {
+		_, err = parse(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
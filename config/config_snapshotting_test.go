@@ -44,6 +44,7 @@ jobs:
     prefix: zrepl_
     timestamp_format: human
     cron: "10 * * * *"
+    jitter: 5m
 `
 
 	periodicDaily := `
@@ -58,6 +59,7 @@ jobs:
     type: periodic
     prefix: zrepl_
     interval: 10m
+    hooks_concurrency: 4
     hooks:
     - type: command
       path: /tmp/path/to/command
@@ -74,6 +76,18 @@ jobs:
       filesystems: {
         "tank/mysql": true
       }
+    - type: mysql-lock-tables
+      socket: /run/mysqld/mysqld.sock
+      user: zrepl
+      password_file: /etc/zrepl/mysql.password
+      filesystems: {
+        "tank/mysql<": true
+      }
+      databases:
+      - filesystems: { "tank/mysql/remote": true }
+        host: db.example.com
+        tls:
+          ca: /etc/zrepl/mysql-ca.crt
 `
 
 	fillSnapshotting := func(s string) string { return fmt.Sprintf(tmpl, s) }
@@ -91,6 +105,8 @@ jobs:
 		assert.Equal(t, "periodic", snp.Type)
 		assert.Equal(t, 10*time.Minute, snp.Interval.Duration())
 		assert.Equal(t, "zrepl_", snp.Prefix)
+		assert.Equal(t, 1, snp.HooksConcurrency)
+		assert.Equal(t, time.Duration(0), snp.Jitter)
 	})
 
 	t.Run("periodicDaily", func(t *testing.T) {
@@ -108,15 +124,27 @@ jobs:
 		assert.Equal(t, "cron", snp.Type)
 		assert.Equal(t, "zrepl_", snp.Prefix)
 		assert.Equal(t, "human", snp.TimestampFormat)
+		assert.Equal(t, 5*time.Minute, snp.Jitter)
 	})
 
 	t.Run("hooks", func(t *testing.T) {
 		c = testValidConfig(t, fillSnapshotting(hooks))
-		hs := c.Jobs[0].Ret.(*PushJob).Snapshotting.Ret.(*SnapshottingPeriodic).Hooks
+		snp := c.Jobs[0].Ret.(*PushJob).Snapshotting.Ret.(*SnapshottingPeriodic)
+		assert.Equal(t, 4, snp.HooksConcurrency)
+		hs := snp.Hooks
 		assert.Equal(t, hs[0].Ret.(*HookCommand).Filesystems["<"], true)
 		assert.Equal(t, hs[1].Ret.(*HookCommand).Filesystems["zroot<"], true)
 		assert.Equal(t, hs[2].Ret.(*HookPostgresCheckpoint).Filesystems["tank/postgres/data11"], true)
 		assert.Equal(t, hs[3].Ret.(*HookMySQLLockTables).Filesystems["tank/mysql"], true)
+		my := hs[4].Ret.(*HookMySQLLockTables)
+		assert.Equal(t, "/run/mysqld/mysqld.sock", my.Socket)
+		assert.Equal(t, "zrepl", my.User)
+		assert.Equal(t, Secret{File: "/etc/zrepl/mysql.password"}, my.PasswordFile)
+		assert.Nil(t, my.TLS)
+		assert.Len(t, my.Databases, 1)
+		assert.Equal(t, "db.example.com", my.Databases[0].Host)
+		assert.Equal(t, "/etc/zrepl/mysql-ca.crt", my.Databases[0].TLS.CA)
+		assert.Equal(t, true, my.Databases[0].Filesystems["tank/mysql/remote"])
 	})
 
 }
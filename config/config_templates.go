@@ -0,0 +1,244 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Job templates are expanded on the YAML level before the config is parsed:
+//
+//   job_templates:
+//     - name: TEMPLATE
+//       job: { name: "push_${host}", type: push, ... }
+//   jobs:
+//     - template: TEMPLATE
+//       instances:
+//         - { host: a, ... }
+//
+// ${VAR} in a string of the template's job (map keys included) is replaced with the instance's variable VAR.
+// A string that consists of only ${VAR} is replaced with the variable's YAML value, e.g. a number or a list.
+// $$ is replaced with $, e.g. to pass ${ENV} to the interpolation of config values.
+
+const (
+	jobTemplatesKey      = "job_templates"
+	jobTemplateRefKey    = "template"
+	jobTemplateInstances = "instances"
+)
+
+var jobTemplateVarRE = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+func usesJobTemplates(doc map[interface{}]interface{}) bool {
+	if _, ok := doc[jobTemplatesKey]; ok {
+		return true
+	}
+	jobs, _ := doc["jobs"].([]interface{})
+	for _, j := range jobs {
+		if m, ok := j.(map[interface{}]interface{}); ok {
+			if _, ok := m[jobTemplateRefKey]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// expandJobTemplates expands the job templates of doc in place.
+func expandJobTemplates(doc map[interface{}]interface{}) error {
+	templates, err := parseJobTemplates(doc[jobTemplatesKey])
+	if err != nil {
+		return errors.Wrap(err, jobTemplatesKey)
+	}
+
+	jobs, _ := doc["jobs"].([]interface{})
+	var expanded []interface{}
+	for i, j := range jobs {
+		m, ok := j.(map[interface{}]interface{})
+		if !ok {
+			expanded = append(expanded, j)
+			continue
+		}
+		ref, ok := m[jobTemplateRefKey]
+		if !ok {
+			expanded = append(expanded, j)
+			continue
+		}
+		instances, err := expandJobTemplate(templates, ref, m)
+		if err != nil {
+			return errors.Wrapf(err, "job #%d", i)
+		}
+		expanded = append(expanded, instances...)
+	}
+
+	delete(doc, jobTemplatesKey)
+	doc["jobs"] = expanded
+	return nil
+}
+
+func parseJobTemplates(raw interface{}) (map[string]interface{}, error) {
+	templates := make(map[string]interface{})
+	if raw == nil {
+		return templates, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, errors.New("must be a list")
+	}
+	for i, t := range list {
+		m, ok := t.(map[interface{}]interface{})
+		if !ok {
+			return nil, errors.Errorf("template #%d: must be a map with keys `name` and `job`", i)
+		}
+		for k := range m {
+			if k != "name" && k != "job" {
+				return nil, errors.Errorf("template #%d: unknown key %q", i, k)
+			}
+		}
+		name, ok := m["name"].(string)
+		if !ok || name == "" {
+			return nil, errors.Errorf("template #%d: `name` must be a non-empty string", i)
+		}
+		if _, ok := templates[name]; ok {
+			return nil, errors.Errorf("template %q: duplicate name", name)
+		}
+		if _, ok := m["job"].(map[interface{}]interface{}); !ok {
+			return nil, errors.Errorf("template %q: `job` must be a map", name)
+		}
+		templates[name] = m["job"]
+	}
+	return templates, nil
+}
+
+func expandJobTemplate(templates map[string]interface{}, ref interface{}, job map[interface{}]interface{}) ([]interface{}, error) {
+	name, ok := ref.(string)
+	if !ok {
+		return nil, errors.Errorf("`%s` must be a string", jobTemplateRefKey)
+	}
+	tmpl, ok := templates[name]
+	if !ok {
+		return nil, errors.Errorf("template %q is not defined in `%s`", name, jobTemplatesKey)
+	}
+	for k := range job {
+		if k != jobTemplateRefKey && k != jobTemplateInstances {
+			return nil, errors.Errorf("template instantiation: unknown key %q, only `%s` and `%s` are allowed",
+				k, jobTemplateRefKey, jobTemplateInstances)
+		}
+	}
+	instances, ok := job[jobTemplateInstances].([]interface{})
+	if !ok || len(instances) == 0 {
+		return nil, errors.Errorf("template %q: `%s` must be a non-empty list", name, jobTemplateInstances)
+	}
+
+	ret := make([]interface{}, len(instances))
+	for i, inst := range instances {
+		rawVars, ok := inst.(map[interface{}]interface{})
+		if !ok {
+			return nil, errors.Errorf("template %q instance #%d: must be a map of variables", name, i)
+		}
+		vars := make(map[string]interface{}, len(rawVars))
+		for k, v := range rawVars {
+			ks, ok := k.(string)
+			if m := jobTemplateVarRE.FindStringSubmatch("${" + ks + "}"); !ok || m == nil || m[1] != ks {
+				return nil, errors.Errorf("template %q instance #%d: invalid variable name %v", name, i, k)
+			}
+			vars[ks] = v
+		}
+		used := make(map[string]bool, len(vars))
+		j, err := substituteJobTemplateVars(tmpl, vars, used)
+		if err != nil {
+			return nil, errors.Wrapf(err, "template %q instance #%d", name, i)
+		}
+		var unused []string
+		for v := range vars {
+			if !used[v] {
+				unused = append(unused, v)
+			}
+		}
+		if len(unused) > 0 {
+			sort.Strings(unused)
+			return nil, errors.Errorf("template %q instance #%d: variables not used by the template: %v", name, i, unused)
+		}
+		ret[i] = j
+	}
+	return ret, nil
+}
+
+// substituteJobTemplateVars returns a copy of v with the variables substituted.
+func substituteJobTemplateVars(v interface{}, vars map[string]interface{}, used map[string]bool) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return substituteJobTemplateString(v, vars, used)
+	case []interface{}:
+		ret := make([]interface{}, len(v))
+		for i, e := range v {
+			s, err := substituteJobTemplateVars(e, vars, used)
+			if err != nil {
+				return nil, err
+			}
+			ret[i] = s
+		}
+		return ret, nil
+	case map[interface{}]interface{}:
+		ret := make(map[interface{}]interface{}, len(v))
+		for k, e := range v {
+			sk, err := substituteJobTemplateVars(k, vars, used)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := sk.(string); !ok && sk != k {
+				return nil, errors.Errorf("map key %q must be substituted with a string", k)
+			}
+			if _, ok := ret[sk]; ok {
+				return nil, errors.Errorf("duplicate map key %q after substitution", sk)
+			}
+			se, err := substituteJobTemplateVars(e, vars, used)
+			if err != nil {
+				return nil, err
+			}
+			ret[sk] = se
+		}
+		return ret, nil
+	default:
+		return v, nil
+	}
+}
+
+func substituteJobTemplateString(s string, vars map[string]interface{}, used map[string]bool) (interface{}, error) {
+	if m := jobTemplateVarRE.FindStringSubmatch(s); m != nil && m[0] == s && m[1] != "" {
+		val, ok := vars[m[1]]
+		if !ok {
+			return nil, errors.Errorf("variable %q is not defined", m[1])
+		}
+		used[m[1]] = true
+		return val, nil
+	}
+	var err error
+	ret := jobTemplateVarRE.ReplaceAllStringFunc(s, func(match string) string {
+		if match == "$$" {
+			return "$"
+		}
+		name := match[2 : len(match)-1]
+		val, ok := vars[name]
+		if !ok {
+			if err == nil {
+				err = errors.Errorf("variable %q is not defined", name)
+			}
+			return match
+		}
+		used[name] = true
+		switch val.(type) {
+		case []interface{}, map[interface{}]interface{}:
+			if err == nil {
+				err = errors.Errorf("variable %q is a list or map and cannot be part of string %q", name, s)
+			}
+			return match
+		}
+		return fmt.Sprint(val)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
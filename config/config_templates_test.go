@@ -0,0 +1,105 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobTemplates(t *testing.T) {
+	c, err := ParseConfig("./samples/push_templates.yml")
+	require.NoError(t, err)
+	require.Len(t, c.Jobs, 3)
+
+	home := c.Jobs[0].Ret.(*PushJob)
+	assert.Equal(t, "push_home", home.Name)
+	assert.Equal(t, FilesystemsFilter{"zroot/home<": true, "zroot/home/tmp": false}, home.Filesystems)
+	assert.Equal(t, "backup-server.foo.bar:8888", home.Connect.Ret.(*TCPConnect).Address)
+	assert.Equal(t, "zrepl_home_", home.Snapshotting.Ret.(*SnapshottingPeriodic).Prefix)
+	assert.Equal(t, 10, home.Pruning.KeepSender[1].Ret.(*PruneKeepLastN).Count, "whole-string variables keep their YAML type")
+
+	vms := c.Jobs[1].Ret.(*PushJob)
+	assert.Equal(t, "push_vms", vms.Name)
+	assert.Equal(t, 3, vms.Pruning.KeepSender[1].Ret.(*PruneKeepLastN).Count)
+
+	assert.Equal(t, "snap_var", c.Jobs[2].Name())
+}
+
+func TestJobTemplatesErrors(t *testing.T) {
+	tmpl := `
+job_templates:
+- name: t
+  job:
+    type: snap
+    name: "snap_${name}"
+    filesystems: { "${fs}<": true }
+    snapshotting:
+      type: manual
+    pruning:
+      keep:
+      - type: last_n
+        count: 10
+`
+	tcs := map[string]string{
+		"undefined template": `
+jobs:
+- template: nonexistent
+  instances: [{name: a, fs: a}]
+`,
+		"undefined variable": `
+jobs:
+- template: t
+  instances: [{name: a}]
+`,
+		"unused variable": `
+jobs:
+- template: t
+  instances: [{name: a, fs: a, typo: b}]
+`,
+		"no instances": `
+jobs:
+- template: t
+  instances: []
+`,
+		"unknown key": `
+jobs:
+- template: t
+  name: foo
+  instances: [{name: a, fs: a}]
+`,
+		"list in string": `
+jobs:
+- template: t
+  instances: [{name: [a, b], fs: a}]
+`,
+		"duplicate key after substitution": `
+job_templates:
+- name: dup
+  job:
+    type: snap
+    name: "snap"
+    filesystems: { "${a}": true, "${b}": false }
+    snapshotting:
+      type: manual
+    pruning:
+      keep:
+      - type: last_n
+        count: 10
+jobs:
+- template: dup
+  instances: [{a: x, b: x}]
+`,
+	}
+	for name, jobs := range tcs {
+		t.Run(name, func(t *testing.T) {
+			input := jobs
+			if !strings.Contains(jobs, "job_templates:") {
+				input = tmpl + jobs
+			}
+			_, err := ParseConfigBytes([]byte(input))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -97,6 +97,10 @@ func TestCronSpec(t *testing.T) {
 		`"* * * * *"`,
 		`"0-10 * * * *"`,
 		`"* 0-5,8,12 * * *"`,
+		`"30 * * * * *"`,
+		`"@every 1h30m"`,
+		`"@daily"`,
+		`["0 0 * * *", "30 12 * * *"]`,
 	}
 
 	expectFail := []string{
@@ -104,9 +108,9 @@ func TestCronSpec(t *testing.T) {
 		``,
 		`23`,
 		`"@reboot"`,
-		`"@every 1h30m"`,
-		`"@daily"`,
 		`* * * * * *`,
+		`[]`,
+		`["0 0 * * *", "@reboot"]`,
 	}
 
 	for _, input := range expectAccept {
@@ -0,0 +1,418 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/zrepl/yaml-config"
+)
+
+// ValidationError is a config error in a form that editors and CI pipelines can consume.
+type ValidationError struct {
+	File string `json:"file,omitempty"`
+	// 1-based, 0 if unknown, e.g. because the job templates of the file were expanded
+	Line int `json:"line,omitempty"`
+	// YAML path of the offending value, e.g. jobs[0].connect.address, empty if unknown
+	Path     string `json:"path,omitempty"`
+	Message  string `json:"message"`
+	Expected string `json:"expected,omitempty"`
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	if e.File != "" {
+		b.WriteString(e.File)
+		if e.Line > 0 {
+			fmt.Fprintf(&b, ":%d", e.Line)
+		}
+		b.WriteString(": ")
+	} else if e.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", e.Line)
+	}
+	if e.Path != "" {
+		b.WriteString(e.Path)
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Message)
+	if e.Expected != "" {
+		fmt.Fprintf(&b, " (expected %s)", e.Expected)
+	}
+	return b.String()
+}
+
+// ValidationErrors is the error returned by ParseConfig and ParseConfigBytes if the config is invalid.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, v := range e {
+		msgs[i] = v.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// AsValidationErrors returns err as ValidationErrors, wrapping it in a single entry if necessary.
+func AsValidationErrors(err error) ValidationErrors {
+	if verrs, ok := errors.Cause(err).(ValidationErrors); ok {
+		return verrs
+	}
+	return ValidationErrors{{Message: err.Error()}}
+}
+
+// withFile returns err as ValidationErrors whose entries without a file are attributed to file.
+func withFile(err error, file string) ValidationErrors {
+	verrs := AsValidationErrors(err)
+	for _, e := range verrs {
+		if e.File == "" {
+			e.File = file
+		}
+	}
+	return verrs
+}
+
+// validateConfigDoc validates doc against JSONSchema.
+// lines maps YAML paths to lines (see yamlLineIndex), nil if they are unknown.
+func validateConfigDoc(doc map[interface{}]interface{}, file string, lines map[string]int) ValidationErrors {
+	root := JSONSchema()
+	verrs := validateSchema(root, root, doc, "")
+	for _, e := range verrs {
+		e.File = file
+		e.Line = lineOf(lines, e.Path)
+	}
+	return verrs
+}
+
+var yamlErrorLineRE = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// validationErrorsFromYAML converts the error of yaml.UnmarshalStrict.
+// The line numbers in err are only meaningful if lines is not nil.
+func validationErrorsFromYAML(err error, file string, lines map[string]int) ValidationErrors {
+	var msgs []string
+	if terr, ok := err.(*yaml.TypeError); ok {
+		msgs = terr.Errors
+	} else {
+		msgs = []string{err.Error()}
+	}
+	verrs := make(ValidationErrors, len(msgs))
+	for i, msg := range msgs {
+		e := &ValidationError{File: file, Message: msg}
+		if m := yamlErrorLineRE.FindStringSubmatch(msg); m != nil {
+			e.Message = m[2]
+			if lines != nil {
+				e.Line, _ = strconv.Atoi(m[1])
+				e.Path = pathOf(lines, e.Line)
+			}
+		}
+		verrs[i] = e
+	}
+	return verrs
+}
+
+func validateSchema(root, s *Schema, v interface{}, path string) ValidationErrors {
+	s = s.resolve(root)
+	errorf := func(expected string, format string, args ...interface{}) ValidationErrors {
+		return ValidationErrors{{Path: path, Message: fmt.Sprintf(format, args...), Expected: expected}}
+	}
+
+	if len(s.AnyOf) > 0 {
+		// report the alternative that matches best
+		var best ValidationErrors
+		for _, alt := range s.AnyOf {
+			verrs := validateSchema(root, alt, v, path)
+			if len(verrs) == 0 {
+				return nil
+			}
+			if best == nil || len(verrs) < len(best) {
+				best = verrs
+			}
+		}
+		return best
+	}
+
+	if len(s.OneOf) > 0 {
+		// the alternatives are enum variants, selected by `type`
+		consts := make([]string, len(s.OneOf))
+		for i, variant := range s.OneOf {
+			consts[i] = strconv.Quote(fmt.Sprint(variant.Properties["type"].Const))
+		}
+		expected := "one of " + strings.Join(consts, ", ")
+		m, ok := v.(map[interface{}]interface{})
+		if !ok {
+			return errorf("object", "must be an object with a `type` field")
+		}
+		t, ok := m["type"]
+		if !ok {
+			return errorf(expected, "missing required field %q", "type")
+		}
+		for _, variant := range s.OneOf {
+			if fmt.Sprint(variant.Properties["type"].Const) == fmt.Sprint(t) {
+				return validateSchema(root, variant, v, path)
+			}
+		}
+		// the path of the value at which the type name is located
+		return ValidationErrors{{Path: joinYAMLPath(path, "type"), Message: fmt.Sprintf("invalid type name %q", fmt.Sprint(t)), Expected: expected}}
+	}
+
+	if v == nil {
+		// an empty value, which unmarshals to the zero value of any type
+		return nil
+	}
+
+	if s.Type != nil && !matchesSchemaType(s.Type, v) {
+		return errorf(s.describe(root), "invalid value %s", describeYAMLValue(v))
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			found = found || fmt.Sprint(e) == fmt.Sprint(v)
+		}
+		if !found {
+			return errorf(s.describe(root), "invalid value %s", describeYAMLValue(v))
+		}
+	}
+	if n, ok := yamlNumber(v); ok {
+		if s.Minimum != nil && n < *s.Minimum {
+			return errorf(fmt.Sprintf("a number >= %v", *s.Minimum), "invalid value %v", v)
+		}
+		if s.ExclusiveMinimum != nil && n <= *s.ExclusiveMinimum {
+			return errorf(fmt.Sprintf("a number > %v", *s.ExclusiveMinimum), "invalid value %v", v)
+		}
+	}
+
+	var verrs ValidationErrors
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		for _, k := range sortedYAMLKeys(v) {
+			ks := fmt.Sprint(k)
+			p := joinYAMLPath(path, ks)
+			if ps, ok := s.Properties[ks]; ok {
+				verrs = append(verrs, validateSchema(root, ps, v[k], p)...)
+				continue
+			}
+			switch ap := s.AdditionalProperties.(type) {
+			case *Schema:
+				verrs = append(verrs, validateSchema(root, ap, v[k], p)...)
+			case bool:
+				if !ap {
+					verrs = append(verrs, &ValidationError{
+						Path:     p,
+						Message:  fmt.Sprintf("unknown field %q", ks),
+						Expected: "one of the fields " + strings.Join(sortedKeys(s.Properties), ", "),
+					})
+				}
+			}
+		}
+		for _, r := range s.Required {
+			if _, ok := v[r]; !ok {
+				verrs = append(verrs, &ValidationError{Path: path, Message: fmt.Sprintf("missing required field %q", r)})
+			}
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, e := range v {
+				verrs = append(verrs, validateSchema(root, s.Items, e, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	return verrs
+}
+
+func matchesSchemaType(types interface{}, v interface{}) bool {
+	var ts []string
+	switch t := types.(type) {
+	case string:
+		ts = []string{t}
+	case []string:
+		ts = t
+	}
+	for _, t := range ts {
+		switch v.(type) {
+		case map[interface{}]interface{}:
+			if t == schemaTypeObject {
+				return true
+			}
+		case []interface{}:
+			if t == schemaTypeArray {
+				return true
+			}
+		case bool:
+			// yaml-config decodes any scalar into a string
+			if t == schemaTypeBoolean || t == schemaTypeString {
+				return true
+			}
+		case int, int64, uint64:
+			if t == schemaTypeInteger || t == schemaTypeNumber || t == schemaTypeString {
+				return true
+			}
+		case float64:
+			if t == schemaTypeNumber || t == schemaTypeString {
+				return true
+			}
+		default:
+			if t == schemaTypeString {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func yamlNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+func describeYAMLValue(v interface{}) string {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		return "of type object"
+	case []interface{}:
+		return "of type array"
+	case string:
+		return strconv.Quote(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func sortedYAMLKeys(m map[interface{}]interface{}) []interface{} {
+	keys := make([]interface{}, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+	return keys
+}
+
+func joinYAMLPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// lineOf returns the line of path or, if path is not in lines, of its closest ancestor.
+func lineOf(lines map[string]int, path string) int {
+	for path != "" {
+		if l, ok := lines[path]; ok {
+			return l
+		}
+		i := strings.LastIndexAny(path, ".[")
+		if i == -1 {
+			break
+		}
+		path = path[:i]
+	}
+	return 0
+}
+
+// pathOf returns the path of the last key or list element that starts at or before line.
+func pathOf(lines map[string]int, line int) string {
+	var path string
+	best := 0
+	for p, l := range lines {
+		if l <= line && (l > best || l == best && len(p) > len(path)) {
+			path, best = p, l
+		}
+	}
+	return path
+}
+
+var yamlKeyRE = regexp.MustCompile(`^("[^"]*"|'[^']*'|[^\s"'#\-{}\[\],][^:#]*?|-[^\s:#][^:#]*?)\s*:(?:\s+(.*))?$`)
+
+// yamlLineIndex maps the YAML paths of the keys and list elements of a block-style YAML document
+// to their 1-based line numbers.
+// It is best-effort: flow-style collections are not descended into and anchors are not resolved.
+func yamlLineIndex(raw []byte) map[string]int {
+	type frame struct {
+		indent int
+		path   string
+		item   bool // list element, otherwise key
+		seq    int  // number of list elements below a key
+	}
+	lines := make(map[string]int)
+	var stack []*frame
+	blockScalarIndent := -1 // indent of the key of a multi-line scalar that is being skipped
+
+	for n, line := range strings.Split(string(raw), "\n") {
+		lineno := n + 1
+		content := strings.TrimLeft(line, " ")
+		indent := len(line) - len(content)
+		content = strings.TrimRight(content, " \r")
+		if blockScalarIndent >= 0 {
+			if content == "" || indent > blockScalarIndent {
+				continue
+			}
+			blockScalarIndent = -1
+		}
+		if content == "" || content[0] == '#' || content == "---" || content == "..." {
+			continue
+		}
+
+		for content != "" {
+			if content == "-" || strings.HasPrefix(content, "- ") {
+				for len(stack) > 0 {
+					top := stack[len(stack)-1]
+					if top.indent > indent || top.indent == indent && top.item {
+						stack = stack[:len(stack)-1]
+						continue
+					}
+					break
+				}
+				parent := &frame{indent: -1}
+				if len(stack) > 0 {
+					parent = stack[len(stack)-1]
+				}
+				p := fmt.Sprintf("%s[%d]", parent.path, parent.seq)
+				parent.seq++
+				lines[p] = lineno
+				stack = append(stack, &frame{indent: indent, path: p, item: true})
+				rest := strings.TrimLeft(strings.TrimPrefix(content, "-"), " ")
+				indent += len(content) - len(rest)
+				content = rest
+				continue
+			}
+			m := yamlKeyRE.FindStringSubmatch(content)
+			if m == nil {
+				break
+			}
+			for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+				stack = stack[:len(stack)-1]
+			}
+			parent := ""
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1].path
+			}
+			key := m[1]
+			if unquoted, err := strconv.Unquote(key); err == nil {
+				key = unquoted
+			} else if len(key) >= 2 && key[0] == '\'' {
+				key = key[1 : len(key)-1]
+			}
+			p := joinYAMLPath(parent, key)
+			if _, ok := lines[p]; !ok {
+				lines[p] = lineno
+			}
+			stack = append(stack, &frame{indent: indent, path: p})
+			if v := m[2]; strings.HasPrefix(v, "|") || strings.HasPrefix(v, ">") {
+				blockScalarIndent = indent
+			}
+			break
+		}
+	}
+	return lines
+}
@@ -0,0 +1,311 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/util/envconst"
+	"github.com/zrepl/zrepl/version"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// APIPrefixV1 is the path prefix of the versioned HTTP+JSON API for external dashboards and automation.
+// Unlike the other control endpoints, which are internal to the zrepl CLI,
+// paths and response formats of the API only change in backwards-compatible ways within a version.
+const APIPrefixV1 = "/api/v1/"
+
+type APIJob struct {
+	Name     string   `json:"name"`
+	Type     job.Type `json:"type"`
+	Disabled bool     `json:"disabled"`
+	// only set by GET jobs/{name}
+	Status *job.Status `json:"status,omitempty"`
+}
+
+type APIJobWakeupRequest struct {
+	Filesystems []string `json:"filesystems"`
+}
+
+type APIJobStateRequest struct {
+	Abort     bool `json:"abort"`
+	Temporary bool `json:"temporary"`
+}
+
+type APIConfigSummary struct {
+	Jobs []APIConfigJob `json:"jobs"`
+	// listen addresses of the monitoring endpoints
+	Monitoring []string `json:"monitoring"`
+	// empty if there is no control TCP listener
+	ControlListen string                   `json:"control_listen"`
+	Concurrency   config.GlobalConcurrency `json:"concurrency"`
+}
+
+type APIConfigJob struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// names of the jobs this job runs after, see config.ActiveJob.After
+	After []string `json:"after,omitempty"`
+}
+
+type APIHolds struct {
+	Abstractions []endpoint.Abstraction `json:"abstractions"`
+	Errors       []APIHoldsError        `json:"errors"`
+}
+
+type APIHoldsError struct {
+	FS    string `json:"fs"`
+	Snap  string `json:"snap"`
+	What  string `json:"what"`
+	Error string `json:"error"`
+}
+
+type apiError struct {
+	status int
+	err    error
+}
+
+func (e *apiError) Error() string { return e.err.Error() }
+
+func apiErrorf(status int, format string, args ...interface{}) error {
+	return &apiError{status, errors.Errorf(format, args...)}
+}
+
+type apiV1 struct {
+	log  Logger
+	jobs *jobs
+	conf func() *config.Config
+}
+
+func (a *apiV1) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	res, err := a.route(r)
+	status := http.StatusOK
+	if err != nil {
+		status = http.StatusInternalServerError
+		if e, ok := err.(*apiError); ok {
+			status = e.status
+		}
+		if status == http.StatusInternalServerError {
+			a.log.WithError(err).Error("api handler error")
+		}
+		res = struct {
+			Error string `json:"error"`
+		}{err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		a.log.WithError(err).Error("api handler io error")
+	}
+}
+
+func (a *apiV1) route(r *http.Request) (interface{}, error) {
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, APIPrefixV1), "/"), "/")
+	method := func(m string) error {
+		if r.Method != m {
+			return apiErrorf(http.StatusMethodNotAllowed, "method %s not allowed, use %s", r.Method, m)
+		}
+		return nil
+	}
+	switch {
+	case len(path) == 1 && path[0] == "version":
+		if err := method(http.MethodGet); err != nil {
+			return nil, err
+		}
+		return version.NewZreplVersionInformation(), nil
+	case len(path) == 1 && path[0] == "status":
+		if err := method(http.MethodGet); err != nil {
+			return nil, err
+		}
+		s := a.jobs.daemonStatus()
+		s.Global.OsEnviron = nil // not needed by dashboards, but potentially sensitive
+		return s, nil
+	case len(path) == 1 && path[0] == "config":
+		if err := method(http.MethodGet); err != nil {
+			return nil, err
+		}
+		return configSummary(a.conf()), nil
+	case len(path) == 1 && path[0] == "holds":
+		if err := method(http.MethodGet); err != nil {
+			return nil, err
+		}
+		return a.holds(r)
+	case len(path) == 1 && path[0] == "jobs":
+		if err := method(http.MethodGet); err != nil {
+			return nil, err
+		}
+		return a.jobList(), nil
+	case len(path) == 2 && path[0] == "jobs":
+		if err := method(http.MethodGet); err != nil {
+			return nil, err
+		}
+		return a.job(path[1])
+	case len(path) == 3 && path[0] == "jobs":
+		if err := method(http.MethodPost); err != nil {
+			return nil, err
+		}
+		return struct{}{}, a.jobAction(r, path[1], path[2])
+	default:
+		return nil, apiErrorf(http.StatusNotFound, "unknown API endpoint %q", r.URL.Path)
+	}
+}
+
+func (a *apiV1) jobList() []APIJob {
+	statuses := a.jobs.status()
+	list := make([]APIJob, 0, len(statuses))
+	for name, st := range statuses {
+		if IsInternalJobName(name) || st == nil {
+			continue
+		}
+		list = append(list, APIJob{Name: name, Type: st.Type, Disabled: st.Disabled})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func (a *apiV1) job(name string) (*APIJob, error) {
+	st, ok := a.jobs.status()[name]
+	if !ok || IsInternalJobName(name) || st == nil {
+		return nil, apiErrorf(http.StatusNotFound, "job %q does not exist", name)
+	}
+	return &APIJob{Name: name, Type: st.Type, Disabled: st.Disabled, Status: st}, nil
+}
+
+func (a *apiV1) jobAction(r *http.Request, name, action string) error {
+	a.jobs.m.RLock()
+	_, ok := a.jobs.jobs[name]
+	a.jobs.m.RUnlock()
+	if !ok || IsInternalJobName(name) {
+		return apiErrorf(http.StatusNotFound, "job %q does not exist", name)
+	}
+	var err error
+	switch action {
+	case "wakeup":
+		var req APIJobWakeupRequest
+		if r.Body != nil {
+			if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil && decodeErr != io.EOF {
+				return apiErrorf(http.StatusBadRequest, "cannot decode request body: %s", decodeErr)
+			}
+		}
+		err = a.jobs.wakeup(name, req.Filesystems...)
+	case "reset":
+		err = a.jobs.reset(name)
+	case "enable", "disable":
+		var req APIJobStateRequest
+		if r.Body != nil {
+			if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil && decodeErr != io.EOF {
+				return apiErrorf(http.StatusBadRequest, "cannot decode request body: %s", decodeErr)
+			}
+		}
+		err = a.jobs.setJobState(a.log, JobStateRequest{
+			Name:      name,
+			Disabled:  action == "disable",
+			Abort:     req.Abort,
+			Temporary: req.Temporary,
+		})
+	default:
+		return apiErrorf(http.StatusNotFound, "unknown job action %q, must be wakeup, reset, enable or disable", action)
+	}
+	if err != nil {
+		// the job exists, so errors are caused by its state or the request
+		return &apiError{http.StatusConflict, err}
+	}
+	return nil
+}
+
+var apiHoldsTimeout = envconst.Duration("ZREPL_DAEMON_API_HOLDS_TIMEOUT", 1*time.Minute)
+
+// GET holds?fs=FS&job=JOB&type=TYPE,... lists zrepl's holds and bookmarks, see `zrepl zfs-abstraction list`
+func (a *apiV1) holds(r *http.Request) (*APIHolds, error) {
+	params := r.URL.Query()
+	q := endpoint.ListZFSHoldsAndBookmarksQuery{
+		FS:          endpoint.ListZFSHoldsAndBookmarksQueryFilesystemFilter{Filter: zfs.NoFilter()},
+		What:        endpoint.AbstractionTypesAll,
+		Concurrency: 1,
+	}
+	if fs := params.Get("fs"); fs != "" {
+		q.FS = endpoint.ListZFSHoldsAndBookmarksQueryFilesystemFilter{FS: &fs}
+	}
+	if jobName := params.Get("job"); jobName != "" {
+		jobID, err := endpoint.MakeJobID(jobName)
+		if err != nil {
+			return nil, apiErrorf(http.StatusBadRequest, "invalid job: %s", err)
+		}
+		q.JobID = &jobID
+	}
+	if types := params.Get("type"); types != "" {
+		what, err := endpoint.AbstractionTypeSetFromStrings(strings.Split(types, ","))
+		if err != nil {
+			return nil, apiErrorf(http.StatusBadRequest, "invalid type: %s", err)
+		}
+		q.What = what
+	}
+	if err := q.Validate(); err != nil {
+		return nil, apiErrorf(http.StatusBadRequest, "invalid query: %s", err)
+	}
+
+	ctx, endTask := trace.WithTaskFromStack(r.Context())
+	defer endTask()
+	ctx, cancel := context.WithTimeout(ctx, apiHoldsTimeout)
+	defer cancel()
+	abs, absErrs, err := endpoint.ListAbstractions(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	res := &APIHolds{Abstractions: abs, Errors: make([]APIHoldsError, len(absErrs))}
+	if res.Abstractions == nil {
+		res.Abstractions = []endpoint.Abstraction{}
+	}
+	for i, e := range absErrs {
+		res.Errors[i] = APIHoldsError{FS: e.FS, Snap: e.Snap, What: e.What, Error: e.Err.Error()}
+	}
+	return res, nil
+}
+
+func configSummary(c *config.Config) *APIConfigSummary {
+	s := &APIConfigSummary{
+		Jobs:        make([]APIConfigJob, 0, len(c.Jobs)),
+		Monitoring:  []string{},
+		Concurrency: *c.Global.Concurrency,
+	}
+	for _, j := range c.Jobs {
+		var t string
+		switch v := j.Ret.(type) {
+		case *config.SnapJob:
+			t = v.Type
+		case *config.PushJob:
+			t = v.Type
+		case *config.PullJob:
+			t = v.Type
+		case *config.SinkJob:
+			t = v.Type
+		case *config.SourceJob:
+			t = v.Type
+		case *config.PoolMonitorJob:
+			t = v.Type
+		}
+		s.Jobs = append(s.Jobs, APIConfigJob{Name: j.Name(), Type: t, After: jobAfter(j)})
+	}
+	for _, m := range c.Global.Monitoring {
+		switch v := m.Ret.(type) {
+		case *config.PrometheusMonitoring:
+			s.Monitoring = append(s.Monitoring, v.Listen)
+		case *config.WebUIMonitoring:
+			s.Monitoring = append(s.Monitoring, v.Listen)
+		}
+	}
+	if c.Global.Control.Listen != nil {
+		s.ControlListen = c.Global.Control.Listen.Listen
+	}
+	return s
+}
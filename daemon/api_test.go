@@ -0,0 +1,108 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/job/disable"
+	"github.com/zrepl/zrepl/logger"
+)
+
+func TestAPIV1(t *testing.T) {
+	c, err := config.ParseConfigBytes([]byte(`
+jobs:
+- name: snapjob
+  type: snap
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep:
+      - type: last_n
+        count: 10
+`))
+	require.NoError(t, err)
+	js, err := job.JobsFromConfig(c, config.ParseFlagsNone)
+	require.NoError(t, err)
+
+	s := newJobs()
+	s.jobs["snapjob"] = js[0]
+	s.switches["snapjob"] = &disable.Switch{}
+	s.jobs[jobNameControl] = &controlJob{}
+	s.switches[jobNameControl] = &disable.Switch{}
+	api := &apiV1{log: logger.NewNullLogger(), jobs: s, conf: func() *config.Config { return c }}
+
+	do := func(method, path, body string) (int, map[string]interface{}, []byte) {
+		var r *http.Request
+		if body != "" {
+			r = httptest.NewRequest(method, APIPrefixV1+path, strings.NewReader(body))
+		} else {
+			r = httptest.NewRequest(method, APIPrefixV1+path, nil)
+		}
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, r)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		var res map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &res) // not all responses are objects
+		return w.Code, res, w.Body.Bytes()
+	}
+
+	code, _, body := do(http.MethodGet, "jobs", "")
+	require.Equal(t, http.StatusOK, code)
+	var list []APIJob
+	require.NoError(t, json.Unmarshal(body, &list))
+	require.Len(t, list, 1, "internal jobs are not listed")
+	assert.Equal(t, "snapjob", list[0].Name)
+	assert.Equal(t, job.TypeSnap, list[0].Type)
+	assert.False(t, list[0].Disabled)
+
+	code, res, _ := do(http.MethodGet, "jobs/snapjob", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.NotNil(t, res["status"])
+
+	code, _, _ = do(http.MethodGet, "jobs/"+jobNameControl, "")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, res, _ = do(http.MethodGet, "jobs/nonexistent", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Contains(t, res["error"], "nonexistent")
+
+	code, _, _ = do(http.MethodGet, "jobs/snapjob/disable", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+	code, _, _ = do(http.MethodPost, "jobs/snapjob/disable", `{"abort": true}`)
+	assert.Equal(t, http.StatusConflict, code, "abort is only supported for active jobs")
+	code, _, _ = do(http.MethodPost, "jobs/snapjob/disable", "{broken")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _, _ = do(http.MethodPost, "jobs/snapjob/disable", `{"temporary": true}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, s.switches["snapjob"].Disabled())
+	code, _, _ = do(http.MethodPost, "jobs/snapjob/enable", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.False(t, s.switches["snapjob"].Disabled())
+	code, _, _ = do(http.MethodPost, "jobs/snapjob/frobnicate", "")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, res, _ = do(http.MethodGet, "status", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Nil(t, res["Global"].(map[string]interface{})["OsEnviron"])
+
+	code, _, body = do(http.MethodGet, "config", "")
+	require.Equal(t, http.StatusOK, code)
+	var summary APIConfigSummary
+	require.NoError(t, json.Unmarshal(body, &summary))
+	assert.Equal(t, []APIConfigJob{{Name: "snapjob", Type: "snap"}}, summary.Jobs)
+	assert.Empty(t, summary.ControlListen)
+
+	code, _, _ = do(http.MethodGet, "holds?type=nonexistent", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _, _ = do(http.MethodGet, "nonexistent", "")
+	assert.Equal(t, http.StatusNotFound, code)
+}
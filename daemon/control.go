@@ -8,36 +8,50 @@ import (
 	"io"
 	"net"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/zrepl/zrepl/config"
 	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/job/ready"
+	"github.com/zrepl/zrepl/daemon/logging/trace"
 	"github.com/zrepl/zrepl/daemon/nethelpers"
+	"github.com/zrepl/zrepl/daemon/snapper"
 	"github.com/zrepl/zrepl/endpoint"
 	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/rpc/versionhandshake"
+	"github.com/zrepl/zrepl/transport/ssh"
 	"github.com/zrepl/zrepl/util/envconst"
 	"github.com/zrepl/zrepl/version"
 	"github.com/zrepl/zrepl/zfs"
-	"github.com/zrepl/zrepl/zfs/zfscmd"
 )
 
 type controlJob struct {
 	sockaddr *net.UnixAddr
+	listener *controlListener // nil if no TCP listener is configured
 	jobs     *jobs
+	conf     func() *config.Config // the running config, for the API
 }
 
-func newControlJob(sockpath string, jobs *jobs) (j *controlJob, err error) {
-	j = &controlJob{jobs: jobs}
+func newControlJob(conf *config.GlobalControl, jobs *jobs, runningConf func() *config.Config) (j *controlJob, err error) {
+	j = &controlJob{jobs: jobs, conf: runningConf}
 
-	j.sockaddr, err = net.ResolveUnixAddr("unix", sockpath)
+	j.sockaddr, err = net.ResolveUnixAddr("unix", conf.SockPath)
 	if err != nil {
 		err = errors.Wrap(err, "cannot resolve unix address")
 		return
 	}
 
+	if conf.Listen != nil {
+		j.listener, err = newControlListener(conf.Listen)
+		if err != nil {
+			err = errors.Wrap(err, "cannot build control listener")
+			return
+		}
+	}
+
 	return
 }
 
@@ -49,6 +63,8 @@ func (j *controlJob) OwnedDatasetSubtreeRoot() (p *zfs.DatasetPath, ok bool) { r
 
 func (j *controlJob) SenderConfig() *endpoint.SenderConfig { return nil }
 
+func (j *controlJob) Snapper() (snapper.Snapper, zfs.DatasetFilter, bool) { return nil, nil, false }
+
 var promControl struct {
 	requestBegin    *prometheus.CounterVec
 	requestFinished *prometheus.HistogramVec
@@ -74,10 +90,17 @@ func (j *controlJob) RegisterMetrics(registerer prometheus.Registerer) {
 }
 
 const (
-	ControlJobEndpointPProf   string = "/debug/pprof"
-	ControlJobEndpointVersion string = "/version"
-	ControlJobEndpointStatus  string = "/status"
-	ControlJobEndpointSignal  string = "/signal"
+	ControlJobEndpointPProf       string = "/debug/pprof"
+	ControlJobEndpointVersion     string = "/version"
+	ControlJobEndpointPeers       string = "/peers"
+	ControlJobEndpointStdinserver string = "/stdinserver"
+	ControlJobEndpointStatus      string = "/status"
+	ControlJobEndpointSignal      string = "/signal"
+	ControlJobEndpointSnap        string = "/snap"
+	ControlJobEndpointJobState    string = "/jobstate"
+	ControlJobEndpointMaintenance string = "/maintenance"
+	ControlJobEndpointMetrics     string = "/metrics"
+	ControlJobEndpointLogs        string = "/logs"
 )
 
 func (j *controlJob) Run(ctx context.Context) {
@@ -90,6 +113,16 @@ func (j *controlJob) Run(ctx context.Context) {
 		log.WithError(err).Error("error listening")
 		return
 	}
+	var tcpListener net.Listener
+	if j.listener != nil {
+		tcpListener, err = j.listener.Listen()
+		if err != nil {
+			log.WithError(err).Error("error listening on control TCP address")
+			l.Close()
+			return
+		}
+	}
+	ready.Signal(ctx)
 
 	pprofServer := NewPProfServer(ctx)
 	if listen := envconst.String("ZREPL_DAEMON_AUTOSTART_PPROF_SERVER", ""); listen != "" {
@@ -116,20 +149,20 @@ func (j *controlJob) Run(ctx context.Context) {
 			return version.NewZreplVersionInformation(), nil
 		}}})
 
+	mux.Handle(ControlJobEndpointPeers,
+		requestLogger{log: log, handler: jsonResponder{log, func() (interface{}, error) {
+			return versionhandshake.Peers(), nil
+		}}})
+
+	mux.Handle(ControlJobEndpointStdinserver,
+		requestLogger{log: log, handler: jsonResponder{log, func() (interface{}, error) {
+			return ssh.StdinserverSockets(), nil
+		}}})
+
 	mux.Handle(ControlJobEndpointStatus,
 		// don't log requests to status endpoint, too spammy
 		jsonResponder{log, func() (interface{}, error) {
-			jobs := j.jobs.status()
-			globalZFS := zfscmd.GetReport()
-			envconstReport := envconst.GetReport()
-			s := Status{
-				Jobs: jobs,
-				Global: GlobalStatus{
-					ZFSCmds:   globalZFS,
-					Envconst:  envconstReport,
-					OsEnviron: os.Environ(),
-				}}
-			return s, nil
+			return j.jobs.daemonStatus(), nil
 		}})
 
 	mux.Handle(ControlJobEndpointSignal,
@@ -137,6 +170,8 @@ func (j *controlJob) Run(ctx context.Context) {
 			type reqT struct {
 				Name string
 				Op   string
+				// wakeup only
+				Filesystems []string
 			}
 			var req reqT
 			if decoder(&req) != nil {
@@ -146,22 +181,93 @@ func (j *controlJob) Run(ctx context.Context) {
 			var err error
 			switch req.Op {
 			case "wakeup":
-				err = j.jobs.wakeup(req.Name)
+				err = j.jobs.wakeup(req.Name, req.Filesystems...)
 			case "reset":
 				err = j.jobs.reset(req.Name)
+			case "abort":
+				err = j.jobs.abort(req.Name)
 			default:
 				err = fmt.Errorf("operation %q is invalid", req.Op)
 			}
 
 			return struct{}{}, err
 		}}})
+
+	mux.Handle(ControlJobEndpointJobState,
+		requestLogger{log: log, handler: jsonRequestResponder{log, func(decoder jsonDecoder) (interface{}, error) {
+			var req JobStateRequest
+			if decoder(&req) != nil {
+				return nil, errors.Errorf("decode failed")
+			}
+			return struct{}{}, j.jobs.setJobState(log, req)
+		}}})
+
+	mux.Handle(ControlJobEndpointMaintenance,
+		requestLogger{log: log, handler: jsonRequestResponder{log, func(decoder jsonDecoder) (interface{}, error) {
+			var req JobMaintenanceRequest
+			if decoder(&req) != nil {
+				return nil, errors.Errorf("decode failed")
+			}
+			return struct{}{}, j.jobs.setJobMaintenance(log, req)
+		}}})
+
+	// the Prometheus text format, for hosts without a monitoring job
+	registerGlobalMetrics()
+	mux.Handle(ControlJobEndpointMetrics,
+		requestLogger{log: log, handler: metricsHandler()})
+
+	mux.Handle(ControlJobEndpointLogs,
+		requestLogger{log: log, handlerFunc: func(w http.ResponseWriter, r *http.Request) {
+			serveLogStream(ctx, log, j.jobs, w, r)
+		}})
+
+	snapTimeout := envconst.Duration("ZREPL_DAEMON_CONTROL_SNAP_TIMEOUT", 10*time.Minute)
+	mux.Handle(ControlJobEndpointSnap,
+		requestLogger{log: log, handler: jsonRequestResponder{log, func(decoder jsonDecoder) (interface{}, error) {
+			var req SnapRequest
+			if decoder(&req) != nil {
+				return nil, errors.Errorf("decode failed")
+			}
+			ctx, endTask := trace.WithTaskFromStack(ctx)
+			defer endTask()
+			ctx, cancel := context.WithTimeout(ctx, snapTimeout)
+			defer cancel()
+			return j.jobs.snap(ctx, req.Name, req.OnDemandRequest)
+		}}})
+
+	mux.Handle(APIPrefixV1,
+		requestLogger{log: log, handler: &apiV1{log: log, jobs: j.jobs, conf: j.conf}})
+
+	// control socket is local, 1s timeout should be more than sufficient, even on a loaded system
+	writeTimeout := envconst.Duration("ZREPL_DAEMON_CONTROL_SERVER_WRITE_TIMEOUT", 1*time.Second)
+	if writeTimeout < snapTimeout {
+		// the snap endpoint only responds after the snapshots have been taken
+		writeTimeout = snapTimeout
+	}
 	server := http.Server{
-		Handler: mux,
-		// control socket is local, 1s timeout should be more than sufficient, even on a loaded system
-		WriteTimeout: envconst.Duration("ZREPL_DAEMON_CONTROL_SERVER_WRITE_TIMEOUT", 1*time.Second),
+		Handler:      mux,
+		WriteTimeout: writeTimeout,
 		ReadTimeout:  envconst.Duration("ZREPL_DAEMON_CONTROL_SERVER_READ_TIMEOUT", 1*time.Second),
 	}
 
+	if tcpListener != nil {
+		tcpServer := http.Server{
+			Handler:      j.listener.Handler(log, mux),
+			WriteTimeout: server.WriteTimeout,
+			ReadTimeout:  server.ReadTimeout,
+		}
+		go func() {
+			if err := tcpServer.Serve(tcpListener); err != http.ErrServerClosed {
+				log.WithError(err).Error("error serving on control TCP address")
+			}
+		}()
+		defer func() {
+			if err := tcpServer.Shutdown(context.Background()); err != nil {
+				log.WithError(err).Error("cannot shutdown control TCP server")
+			}
+		}()
+	}
+
 outer:
 	for {
 
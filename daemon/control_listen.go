@@ -0,0 +1,156 @@
+package daemon
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/secret"
+	"github.com/zrepl/zrepl/tlsconf"
+	"github.com/zrepl/zrepl/util/tcpsock"
+)
+
+// controlListener serves the control endpoints in ControlListenerEndpoints on TCP for remote CLI use,
+// see config.GlobalControlListen.
+type controlListener struct {
+	listen    string
+	freeBind  bool
+	token     []byte      // nil if token authentication is disabled
+	tlsConf   *tls.Config // nil if TLS is disabled
+	clientCNs map[string]bool
+}
+
+// ControlListenerEndpoints are the control endpoints that controlListener serves,
+// i.e., those of `zrepl status`, `zrepl signal`, `zrepl job`, `zrepl snap` and `zrepl version`.
+// The others, e.g., pprof and log streaming, are only served on the control socket.
+var ControlListenerEndpoints = map[string]bool{
+	ControlJobEndpointVersion:     true,
+	ControlJobEndpointPeers:       true,
+	ControlJobEndpointStatus:      true,
+	ControlJobEndpointSignal:      true,
+	ControlJobEndpointJobState:    true,
+	ControlJobEndpointMaintenance: true,
+	ControlJobEndpointSnap:        true,
+}
+
+func newControlListener(in *config.GlobalControlListen) (*controlListener, error) {
+	l := &controlListener{listen: in.Listen, freeBind: in.ListenFreeBind}
+
+	if !in.TokenFile.IsZero() {
+		token, err := secret.Load(in.TokenFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot load token")
+		}
+		l.token = bytes.TrimSpace(token)
+	}
+
+	if (in.Cert == "") != in.Key.IsZero() {
+		return nil, errors.New("cert and key must be specified together")
+	}
+	if in.Ca != "" && in.Cert == "" {
+		return nil, errors.New("client certificate authentication (ca) requires cert and key")
+	}
+	if len(in.ClientCNs) > 0 && in.Ca == "" {
+		return nil, errors.New("client_cns requires ca")
+	}
+	if l.token == nil && in.Ca == "" {
+		return nil, errors.New("must specify token_file or ca, or both")
+	}
+	if l.token != nil && in.Cert == "" && !isLoopbackListen(in.Listen) {
+		return nil, errors.New("token_file requires TLS (cert and key) unless listen is a loopback address, the token would be sent in cleartext")
+	}
+	if in.Cert != "" {
+		cert, err := secret.LoadX509KeyPair(in.Cert, in.Key)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot load server certificate")
+		}
+		l.tlsConf = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+	if in.Ca != "" {
+		ca, err := tlsconf.ParseCAFile(in.Ca)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot parse ca file")
+		}
+		l.tlsConf.ClientCAs = ca
+		l.tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
+		l.clientCNs = make(map[string]bool, len(in.ClientCNs))
+		for _, cn := range in.ClientCNs {
+			l.clientCNs[cn] = true
+		}
+	}
+	return l, nil
+}
+
+// isLoopbackListen returns true if listen, a host:port, only accepts connections from the local host.
+func isLoopbackListen(listen string) bool {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (l *controlListener) Listen() (net.Listener, error) {
+	tcpListener, err := tcpsock.Listen(l.listen, l.freeBind)
+	if err != nil {
+		return nil, err
+	}
+	if l.tlsConf == nil {
+		return tcpListener, nil
+	}
+	return tls.NewListener(tcpListener, l.tlsConf), nil
+}
+
+// Handler wraps h so that it only serves authenticated requests for ControlListenerEndpoints.
+func (l *controlListener) Handler(log Logger, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := l.authenticate(r); err != nil {
+			log.WithField("remote_addr", r.RemoteAddr).WithError(err).
+				Warn("rejected unauthenticated control request")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !ControlListenerEndpoints[r.URL.Path] {
+			http.Error(w, "endpoint is only served on the control socket", http.StatusNotFound)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (l *controlListener) authenticate(r *http.Request) error {
+	if l.token != nil {
+		const prefix = "Bearer "
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, prefix) {
+			return errors.New("no bearer token")
+		}
+		if subtle.ConstantTimeCompare([]byte(auth[len(prefix):]), l.token) != 1 {
+			return errors.New("invalid token")
+		}
+	}
+	if len(l.clientCNs) > 0 {
+		// the certificate chain has been verified during the TLS handshake
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			return errors.New("no client certificate")
+		}
+		cn := r.TLS.PeerCertificates[0].Subject.CommonName
+		if !l.clientCNs[cn] {
+			return errors.Errorf("client certificate CN %q is not in client_cns", cn)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package daemon
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/logger"
+)
+
+func TestControlListener(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-control-listen")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("s3cret\n"), 0600))
+
+	for name, c := range map[string]config.GlobalControlListen{
+		"noAuth":                {Listen: ":9811"},
+		"certWithoutKey":        {Listen: ":9811", TokenFile: config.Secret{File: tokenFile}, Cert: "cert.pem"},
+		"caWithoutCert":         {Listen: ":9811", Ca: "ca.pem"},
+		"clientCNsWithoutCa":    {Listen: ":9811", TokenFile: config.Secret{File: tokenFile}, ClientCNs: []string{"admin"}},
+		"missingTokenFile":      {Listen: ":9811", TokenFile: config.Secret{File: filepath.Join(dir, "nonexistent")}},
+		"tokenWithoutTLS":       {Listen: ":9811", TokenFile: config.Secret{File: tokenFile}},
+		"tokenWithoutTLSOnHost": {Listen: "backup1.example.com:9811", TokenFile: config.Secret{File: tokenFile}},
+	} {
+		_, err := newControlListener(&c)
+		assert.Error(t, err, name)
+	}
+
+	// the token is not sent over the network on loopback addresses
+	for _, listen := range []string{"127.0.0.1:9811", "[::1]:9811", "localhost:9811"} {
+		_, err := newControlListener(&config.GlobalControlListen{Listen: listen, TokenFile: config.Secret{File: tokenFile}})
+		assert.NoError(t, err, listen)
+	}
+
+	l, err := newControlListener(&config.GlobalControlListen{Listen: "127.0.0.1:9811", TokenFile: config.Secret{File: tokenFile}})
+	require.NoError(t, err)
+	assert.Nil(t, l.tlsConf)
+	h := l.Handler(logger.NewNullLogger(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for auth, expStatus := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"s3cret":        http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"Bearer s3cret": http.StatusOK,
+	} {
+		r := httptest.NewRequest("POST", "/status", nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, expStatus, w.Code, "Authorization: %q", auth)
+	}
+
+	// client_cns restricts the verified client certificates
+	l.token = nil
+	l.clientCNs = map[string]bool{"admin": true}
+	for cn, expStatus := range map[string]int{"admin": http.StatusOK, "other": http.StatusUnauthorized} {
+		r := httptest.NewRequest("POST", "/status", nil)
+		r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: cn}}}}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, expStatus, w.Code, cn)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/status", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// the listener only serves the endpoints of the remote CLI commands
+	for endpoint, expStatus := range map[string]int{
+		ControlJobEndpointStatus: http.StatusOK,
+		ControlJobEndpointSignal: http.StatusOK,
+		ControlJobEndpointPProf:  http.StatusNotFound,
+		ControlJobEndpointLogs:   http.StatusNotFound,
+		APIPrefixV1 + "jobs":     http.StatusNotFound,
+	} {
+		r := httptest.NewRequest("POST", endpoint, nil)
+		r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "admin"}}}}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, expStatus, w.Code, endpoint)
+	}
+}
@@ -19,16 +19,27 @@ import (
 	"github.com/zrepl/zrepl/util/envconst"
 
 	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/hooks"
 	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/job/abort"
+	"github.com/zrepl/zrepl/daemon/job/disable"
+	"github.com/zrepl/zrepl/daemon/job/finished"
+	"github.com/zrepl/zrepl/daemon/job/maintenance"
+	"github.com/zrepl/zrepl/daemon/job/ready"
 	"github.com/zrepl/zrepl/daemon/job/reset"
+	"github.com/zrepl/zrepl/daemon/job/stop"
 	"github.com/zrepl/zrepl/daemon/job/wakeup"
 	"github.com/zrepl/zrepl/daemon/logging"
+	"github.com/zrepl/zrepl/daemon/snapper"
 	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/rpc"
+	"github.com/zrepl/zrepl/rpc/versionhandshake"
 	"github.com/zrepl/zrepl/version"
+	"github.com/zrepl/zrepl/zfs"
 	"github.com/zrepl/zrepl/zfs/zfscmd"
 )
 
-func Run(ctx context.Context, conf *config.Config) error {
+func Run(ctx context.Context, conf *config.Config, reloadConfig func() (*config.Config, error)) error {
 	ctx, cancel := context.WithCancel(ctx)
 
 	defer cancel()
@@ -38,6 +49,8 @@ func Run(ctx context.Context, conf *config.Config) error {
 		<-sigChan
 		cancel()
 	}()
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
 
 	// The math/rand package is used presently for generating trace IDs, we
 	// seed it with the current time and pid so that the IDs are mostly
@@ -45,21 +58,40 @@ func Run(ctx context.Context, conf *config.Config) error {
 	rand.Seed(time.Now().UnixNano())
 	rand.Seed(int64(os.Getpid()))
 
-	outlets, err := logging.OutletsFromConfig(*conf.Global.Logging)
+	outlets, err := outletsFromConfig(conf)
 	if err != nil {
-		return errors.Wrap(err, "cannot build logging from config")
+		return err
 	}
-	outlets.Add(newPrometheusLogOutlet(), logger.Debug)
 
-	confJobs, err := job.JobsFromConfig(conf, config.ParseFlagsNone)
+	if err := checkConcurrencyLimits(conf); err != nil {
+		return err
+	}
+	endpoint.SetSendRecvConcurrencyLimits(conf.Global.Concurrency.ZFSSend, conf.Global.Concurrency.ZFSRecv)
+	versionhandshake.SetMaxMinorDifference(conf.Global.VersionSkew.MaxMinorDifference)
+	if err := setZFSHelper(conf); err != nil {
+		return err
+	}
+
+	confJobs, err := jobsFromConfig(conf)
+	if err != nil {
+		return err
+	}
+	jobs := newJobs()
+	monitoringJobs, err := monitoringJobsFromConfig(conf, jobs)
 	if err != nil {
-		return errors.Wrap(err, "cannot build jobs from config")
+		return err
 	}
 
 	log := logger.NewLogger(outlets, 1*time.Second)
 	log.Info(version.NewZreplVersionInformation().String())
 
 	ctx = logging.WithLoggers(ctx, logging.SubsystemLoggersWithUniversalLogger(log))
+	// announced to peers in the version handshake, e.g. for `zrepl test connect`
+	if v, err := zfs.ZFSVersion(ctx); err != nil {
+		log.WithError(err).Info("cannot determine the ZFS version, it is not announced to peers")
+	} else {
+		versionhandshake.SetZFSVersion(v)
+	}
 	trace.RegisterCallback(trace.Callback{
 		OnBegin: func(ctx context.Context) { logging.GetLogger(ctx, logging.SubsysTraceData).Debug("begin span") },
 		OnEnd: func(ctx context.Context, spanInfo trace.SpanInfo) {
@@ -70,43 +102,32 @@ func Run(ctx context.Context, conf *config.Config) error {
 		},
 	})
 
-	for _, job := range confJobs {
-		if IsInternalJobName(job.Name()) {
-			panic(fmt.Sprintf("internal job name used for config job '%s'", job.Name())) //FIXME
-		}
+	jobs.statePath = conf.Global.Control.JobStatePath
+	if err := jobs.loadJobState(); err != nil {
+		log.WithError(err).Error("cannot load job state, all jobs are enabled")
 	}
 
-	jobs := newJobs()
+	r := &reloader{conf: conf, parse: reloadConfig, jobs: jobs, outlets: outlets}
 
 	// start control socket
-	controlJob, err := newControlJob(conf.Global.Control.SockPath, jobs)
+	controlJob, err := newControlJob(conf.Global.Control, jobs, r.config)
 	if err != nil {
-		panic(err) // FIXME
+		return errors.Wrap(err, "cannot build control job")
 	}
 	jobs.start(ctx, controlJob, true)
 
-	for i, jc := range conf.Global.Monitoring {
-		var (
-			job job.Job
-			err error
-		)
-		switch v := jc.Ret.(type) {
-		case *config.PrometheusMonitoring:
-			job, err = newPrometheusJobFromConfig(v)
-		default:
-			return errors.Errorf("unknown monitoring job #%d (type %T)", i, v)
-		}
-		if err != nil {
-			return errors.Wrapf(err, "cannot build monitoring job #%d", i)
-		}
+	for _, job := range monitoringJobs {
 		jobs.start(ctx, job, true)
 	}
 
 	// register global (=non job-local) metrics
 	version.PrometheusRegister(prometheus.DefaultRegisterer)
 	zfscmd.RegisterMetrics(prometheus.DefaultRegisterer)
+	hooks.RegisterMetrics(prometheus.DefaultRegisterer)
 	trace.RegisterMetrics(prometheus.DefaultRegisterer)
 	endpoint.RegisterMetrics(prometheus.DefaultRegisterer)
+	rpc.RegisterMetrics(prometheus.DefaultRegisterer)
+	versionhandshake.RegisterMetrics(prometheus.DefaultRegisterer)
 
 	log.Info("starting daemon")
 
@@ -114,12 +135,27 @@ func Run(ctx context.Context, conf *config.Config) error {
 	for _, j := range confJobs {
 		jobs.start(ctx, j, false)
 	}
-
-	select {
-	case <-jobs.wait():
-		log.Info("all jobs finished")
-	case <-ctx.Done():
-		log.WithError(ctx.Err()).Info("context finished")
+	jobs.m.Lock()
+	jobs.configLoaded = true
+	jobs.m.Unlock()
+	go jobs.notifyServiceManager(ctx, log)
+
+	allDone := jobs.wait()
+outer:
+	for {
+		select {
+		case <-allDone:
+			log.Info("all jobs finished")
+			break outer
+		case <-ctx.Done():
+			log.WithError(ctx.Err()).Info("context finished")
+			break outer
+		case <-hupChan:
+			log.Info("received SIGHUP, reloading configuration")
+			if err := r.reload(ctx, log); err != nil {
+				log.WithError(err).Error("cannot reload configuration, continuing with the running configuration")
+			}
+		}
 	}
 	log.Info("waiting for jobs to finish")
 	<-jobs.wait()
@@ -127,6 +163,86 @@ func Run(ctx context.Context, conf *config.Config) error {
 	return nil
 }
 
+func outletsFromConfig(conf *config.Config) (*logger.Outlets, error) {
+	outlets, err := logging.OutletsFromConfig(*conf.Global.Logging)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot build logging from config")
+	}
+	outlets.Add(newPrometheusLogOutlet(), logger.Debug)
+	outlets.Add(logStreams, logger.Debug)
+	return outlets, nil
+}
+
+func checkConcurrencyLimits(conf *config.Config) error {
+	if conf.Global.Concurrency.ZFSSend < 0 || conf.Global.Concurrency.ZFSRecv < 0 {
+		return errors.New("`global.concurrency` limits must not be negative")
+	}
+	return nil
+}
+
+// setZFSHelper makes zfscmd execute zfs and zpool through `zrepl zfs-helper exec` if `global.zfs_helper` is set.
+func setZFSHelper(conf *config.Config) error {
+	if conf.Global.ZFSHelper == nil {
+		return nil
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return errors.Wrap(err, "cannot determine path of zrepl binary for `global.zfs_helper`")
+	}
+	zfscmd.SetHelper([]string{self, "zfs-helper", "exec", "--sockpath", conf.Global.ZFSHelper.SockPath})
+	return nil
+}
+
+func jobsFromConfig(conf *config.Config) ([]job.Job, error) {
+	confJobs, err := job.JobsFromConfig(conf, config.ParseFlagsNone)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot build jobs from config")
+	}
+	for _, job := range confJobs {
+		if IsInternalJobName(job.Name()) {
+			return nil, errors.Errorf("internal job name used for config job '%s'", job.Name())
+		}
+	}
+	return confJobs, nil
+}
+
+func monitoringJobsFromConfig(conf *config.Config, running *jobs) ([]job.Job, error) {
+	var jobs []job.Job
+	for i, jc := range conf.Global.Monitoring {
+		var (
+			job job.Job
+			err error
+		)
+		switch v := jc.Ret.(type) {
+		case *config.PrometheusMonitoring:
+			job, err = newPrometheusJobFromConfig(v, running)
+		case *config.WebUIMonitoring:
+			job, err = newWebUIJobFromConfig(v, running)
+		case *config.StatsdMonitoring:
+			job, err = newStatsdJobFromConfig(v)
+		case *config.PushgatewayMonitoring:
+			var p *metricsPush
+			if p, err = newMetricsPush(&v.MetricsPushCommon, false); err == nil {
+				job = newPushJob(jobNamePushgateway, p, running)
+			}
+		case *config.RemoteWriteMonitoring:
+			var p *metricsPush
+			if p, err = newMetricsPush(&v.MetricsPushCommon, true); err == nil {
+				job = newPushJob(jobNameRemoteWrite, p, running)
+			}
+		case *config.EmailDigestMonitoring:
+			job, err = newEmailDigestJobFromConfig(v, running)
+		default:
+			return nil, errors.Errorf("unknown monitoring job #%d (type %T)", i, v)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot build monitoring job #%d", i)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
 type jobs struct {
 	wg sync.WaitGroup
 
@@ -134,14 +250,46 @@ type jobs struct {
 	m       sync.RWMutex
 	wakeups map[string]wakeup.Func // by Job.Name
 	resets  map[string]reset.Func  // by Job.Name
+	aborts  map[string]abort.Func  // by Job.Name
 	jobs    map[string]job.Job
+	ready   map[string]<-chan struct{} // by Job.Name, closed once the job signals readiness
+	exited  map[string]chan struct{}   // by Job.Name, closed once Run returns
+	cancels map[string]context.CancelFunc
+	stops   map[string]stop.Func
+	metrics map[string]*jobRegisterer
+	// by Job.Name, see `zrepl job disable`
+	switches map[string]*disable.Switch
+	// by job name, value is true if the state is persisted, survives stopJob, see jobstate.go
+	disabled map[string]bool
+	// by Job.Name, see `zrepl job maintenance`
+	maintenanceSwitches map[string]*maintenance.Switch
+	// by job name, maintenance started with `zrepl job maintenance start`, survives stopJob, see jobstate.go
+	maintenance map[string]jobMaintenance
+	// file that persists disabled and maintenance, empty if the state is not persisted
+	statePath string
+	// set once the jobs of the config were started, see handleReadyz
+	configLoaded bool
+	// by monitoring job name, woken up when an invocation finishes, see pushJob
+	finishedListeners map[string]chan<- struct{}
 }
 
 func newJobs() *jobs {
 	return &jobs{
-		wakeups: make(map[string]wakeup.Func),
-		resets:  make(map[string]reset.Func),
-		jobs:    make(map[string]job.Job),
+		wakeups:  make(map[string]wakeup.Func),
+		resets:   make(map[string]reset.Func),
+		aborts:   make(map[string]abort.Func),
+		jobs:     make(map[string]job.Job),
+		ready:    make(map[string]<-chan struct{}),
+		exited:   make(map[string]chan struct{}),
+		cancels:  make(map[string]context.CancelFunc),
+		stops:    make(map[string]stop.Func),
+		metrics:  make(map[string]*jobRegisterer),
+		switches: make(map[string]*disable.Switch),
+		disabled: make(map[string]bool),
+
+		maintenanceSwitches: make(map[string]*maintenance.Switch),
+		maintenance:         make(map[string]jobMaintenance),
+		finishedListeners:   make(map[string]chan<- struct{}),
 	}
 }
 
@@ -165,6 +313,18 @@ type GlobalStatus struct {
 	OsEnviron []string
 }
 
+// daemonStatus is the response of ControlJobEndpointStatus.
+func (s *jobs) daemonStatus() Status {
+	return Status{
+		Jobs: s.status(),
+		Global: GlobalStatus{
+			ZFSCmds:   zfscmd.GetReport(),
+			Envconst:  envconst.GetReport(),
+			OsEnviron: os.Environ(),
+		},
+	}
+}
+
 func (s *jobs) status() map[string]*job.Status {
 	s.m.RLock()
 	defer s.m.RUnlock()
@@ -186,20 +346,35 @@ func (s *jobs) status() map[string]*job.Status {
 	close(c)
 	ret := make(map[string]*job.Status, len(s.jobs))
 	for res := range c {
+		if res.status != nil {
+			res.status.Disabled = s.switches[res.name].Disabled()
+			if ms, ok := s.maintenanceSwitches[res.name]; ok {
+				res.status.Maintenance = ms.State(time.Now())
+			}
+		}
 		ret[res.name] = res.status
 	}
 	return ret
 }
 
-func (s *jobs) wakeup(job string) error {
+// wakeup wakes up job, limited to the filesystems that match one of the patterns if not empty.
+func (s *jobs) wakeup(jobName string, filesystems ...string) error {
 	s.m.RLock()
 	defer s.m.RUnlock()
 
-	wu, ok := s.wakeups[job]
+	wu, ok := s.wakeups[jobName]
 	if !ok {
-		return errors.Errorf("Job %s does not exist", job)
+		return errors.Errorf("Job %s does not exist", jobName)
 	}
-	return wu()
+	if len(filesystems) > 0 {
+		if _, ok := s.jobs[jobName].(*job.ActiveSide); !ok {
+			return errors.Errorf("job %s is not a push or pull job, cannot wake it up for a subset of its filesystems", jobName)
+		}
+		if _, err := job.WakeupFilesystemsFilter(filesystems); err != nil {
+			return err
+		}
+	}
+	return wu(filesystems...)
 }
 
 func (s *jobs) reset(job string) error {
@@ -213,9 +388,49 @@ func (s *jobs) reset(job string) error {
 	return wu()
 }
 
+func (s *jobs) abort(jobName string) error {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	j, ok := s.jobs[jobName]
+	if !ok {
+		return errors.Errorf("Job %s does not exist", jobName)
+	}
+	if _, ok := j.(*job.ActiveSide); !ok {
+		return errors.Errorf("Job %s cannot be aborted: only active jobs replicate", jobName)
+	}
+	return s.aborts[jobName]()
+}
+
+type SnapRequest struct {
+	Name string
+	snapper.OnDemandRequest
+}
+
+func (s *jobs) snap(ctx context.Context, jobName string, req snapper.OnDemandRequest) ([]*snapper.ReportFilesystem, error) {
+	s.m.RLock()
+	j, ok := s.jobs[jobName]
+	s.m.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("Job %s does not exist", jobName)
+	}
+	snap, fsf, ok := j.Snapper()
+	if !ok {
+		return nil, errors.Errorf("Job %s does not create snapshots", jobName)
+	}
+	ctx = logging.WithInjectedField(ctx, logging.JobField, jobName)
+	ctx = zfscmd.WithJobID(ctx, jobName)
+	return snapper.OnDemand(ctx, snap, fsf, req)
+}
+
 const (
-	jobNamePrometheus = "_prometheus"
-	jobNameControl    = "_control"
+	jobNamePrometheus  = "_prometheus"
+	jobNameWebUI       = "_webui"
+	jobNameStatsd      = "_statsd"
+	jobNamePushgateway = "_pushgateway"
+	jobNameRemoteWrite = "_remote_write"
+	jobNameEmailDigest = "_email_digest"
+	jobNameControl     = "_control"
 )
 
 func IsInternalJobName(s string) bool {
@@ -239,20 +454,134 @@ func (s *jobs) start(ctx context.Context, j job.Job, internal bool) {
 		panic(fmt.Sprintf("duplicate job name %s", jobName))
 	}
 
-	j.RegisterMetrics(prometheus.DefaultRegisterer)
+	metrics := &jobRegisterer{Registerer: prometheus.DefaultRegisterer}
+	j.RegisterMetrics(metrics)
 
 	s.jobs[jobName] = j
 	ctx = zfscmd.WithJobID(ctx, j.Name())
+	ctx, cancel := context.WithCancel(ctx)
 	ctx, wakeup := wakeup.Context(ctx)
 	ctx, resetFunc := reset.Context(ctx)
+	ctx, abortFunc := abort.Context(ctx)
+	ctx, readyChan := ready.Context(ctx)
+	ctx, stopFunc := stop.Context(ctx)
+	ctx, disableSwitch := disable.Context(ctx)
+	if _, ok := s.disabled[jobName]; ok {
+		disableSwitch.Set(true)
+		job.GetLogger(ctx).Info("job is disabled, invocations are skipped until it is enabled")
+	}
+	ctx, maintenanceSwitch := maintenance.Context(ctx)
+	ctx = finished.Context(ctx, s.invocationFinished)
+	if m, ok := s.maintenance[jobName]; ok && !m.expired(time.Now()) {
+		maintenanceSwitch.Start(m.Until)
+		job.GetLogger(ctx).WithField("until", m.Until).Info("job is in maintenance, invocations are skipped until it ends")
+	}
+	switch j.(type) {
+	case *job.ActiveSide, *job.SnapJob:
+		metrics.MustRegister(maintenanceGauge(jobName, maintenanceSwitch))
+	}
+	exited := make(chan struct{})
 	s.wakeups[jobName] = wakeup
 	s.resets[jobName] = resetFunc
+	s.aborts[jobName] = abortFunc
+	s.ready[jobName] = readyChan
+	s.exited[jobName] = exited
+	s.cancels[jobName] = cancel
+	s.stops[jobName] = stopFunc
+	s.metrics[jobName] = metrics
+	s.switches[jobName] = disableSwitch
+	s.maintenanceSwitches[jobName] = maintenanceSwitch
 
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
+		defer close(exited)
+		defer cancel()
 		job.GetLogger(ctx).Info("starting job")
 		defer job.GetLogger(ctx).Info("job exited")
 		j.Run(ctx)
 	}()
 }
+
+// stopJob stops the job with the given name and removes it from s once it has exited.
+// If graceful is true, the job is asked to stop after its current invocation
+// and only cancelled if it has not exited after timeout.
+// Jobs that do not support graceful stops (e.g. passive jobs) are cancelled right away.
+func (s *jobs) stopJob(log logger.Logger, name string, graceful bool, timeout time.Duration) {
+	s.m.RLock()
+	j, ok := s.jobs[name]
+	exited, stopFunc, cancel := s.exited[name], s.stops[name], s.cancels[name]
+	s.m.RUnlock()
+	if !ok {
+		return
+	}
+	log = log.WithField("job", name)
+
+	switch j.(type) {
+	case *job.ActiveSide, *job.SnapJob:
+	default:
+		graceful = false
+	}
+	if graceful {
+		log.WithField("timeout", timeout).Info("waiting for job to stop gracefully")
+		stopFunc()
+		select {
+		case <-exited:
+		case <-time.After(timeout):
+			log.Warn("job did not stop within timeout, cancelling it")
+		}
+	}
+	cancel()
+	<-exited
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.metrics[name].unregisterAll()
+	delete(s.jobs, name)
+	delete(s.wakeups, name)
+	delete(s.resets, name)
+	delete(s.aborts, name)
+	delete(s.ready, name)
+	delete(s.exited, name)
+	delete(s.cancels, name)
+	delete(s.stops, name)
+	delete(s.metrics, name)
+	delete(s.switches, name)
+	delete(s.maintenanceSwitches, name)
+	log.Info("job stopped")
+}
+
+// jobRegisterer records the collectors that a job registers
+// so that they can be unregistered when the job is stopped.
+type jobRegisterer struct {
+	prometheus.Registerer
+	mtx        sync.Mutex
+	collectors []prometheus.Collector
+}
+
+func (r *jobRegisterer) Register(c prometheus.Collector) error {
+	if err := r.Registerer.Register(c); err != nil {
+		return err
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.collectors = append(r.collectors, c)
+	return nil
+}
+
+func (r *jobRegisterer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (r *jobRegisterer) unregisterAll() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for _, c := range r.collectors {
+		r.Registerer.Unregister(c)
+	}
+	r.collectors = nil
+}
@@ -0,0 +1,367 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/robfig/cron/v3"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/job/ready"
+	"github.com/zrepl/zrepl/daemon/snapper"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/secret"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// digestMaxErrors limits the errors listed per job in a digest
+const digestMaxErrors = 5
+
+// emailDigestJob emails a digest of the jobs' invocations on a cron schedule, see config.EmailDigestMonitoring.
+// It collects the invocations of the jobs' status whenever an invocation finishes
+// because the jobs only keep the invocations of the last 24 hours.
+type emailDigestJob struct {
+	schedule cron.Schedule
+	smtp     *digestSMTP
+	from     string
+	to       []string
+	subject  string
+	hostname string
+	jobs     *jobs
+	digest   *digestCollector
+	// buffered, see pushJob.finished
+	finished chan struct{}
+}
+
+type digestSMTP struct {
+	address  string
+	tls      string // starttls, tls or none
+	username string
+	password string
+	timeout  time.Duration
+}
+
+func newEmailDigestJobFromConfig(in *config.EmailDigestMonitoring, jobs *jobs) (*emailDigestJob, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get hostname")
+	}
+	j := &emailDigestJob{
+		schedule: in.Cron.Schedule,
+		to:       in.To,
+		subject:  in.Subject,
+		hostname: hostname,
+		jobs:     jobs,
+		digest:   globalDigest,
+		finished: make(chan struct{}, 1),
+	}
+	if j.subject == "" {
+		j.subject = "zrepl digest of " + hostname
+	}
+	from, err := mail.ParseAddress(in.From)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid from address %q", in.From)
+	}
+	j.from = from.Address
+	if len(in.To) == 0 {
+		return nil, errors.New("to must not be empty")
+	}
+	for _, to := range in.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return nil, errors.Wrapf(err, "invalid to address %q", to)
+		}
+	}
+
+	j.smtp = &digestSMTP{
+		address:  in.SMTP.Address,
+		tls:      in.SMTP.TLS,
+		username: in.SMTP.Username,
+		timeout:  in.SMTP.Timeout,
+	}
+	switch j.smtp.tls {
+	case "starttls", "tls", "none":
+	default:
+		return nil, errors.Errorf("invalid smtp tls mode %q, must be one of starttls, tls, none", j.smtp.tls)
+	}
+	if !in.SMTP.Password.IsZero() {
+		if j.smtp.username == "" {
+			return nil, errors.New("smtp password requires a username")
+		}
+		password, err := secret.Load(in.SMTP.Password)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot load smtp password")
+		}
+		j.smtp.password = string(password)
+	}
+	return j, nil
+}
+
+func (j *emailDigestJob) Name() string { return jobNameEmailDigest }
+
+func (j *emailDigestJob) Status() *job.Status { return &job.Status{Type: job.TypeInternal} }
+
+func (j *emailDigestJob) OwnedDatasetSubtreeRoot() (p *zfs.DatasetPath, ok bool) { return nil, false }
+
+func (j *emailDigestJob) SenderConfig() *endpoint.SenderConfig { return nil }
+
+func (j *emailDigestJob) Snapper() (snapper.Snapper, zfs.DatasetFilter, bool) { return nil, nil, false }
+
+func (j *emailDigestJob) RegisterMetrics(registerer prometheus.Registerer) {}
+
+func (j *emailDigestJob) Run(ctx context.Context) {
+	log := job.GetLogger(ctx)
+
+	j.jobs.addFinishedListener(jobNameEmailDigest, j.finished)
+	defer j.jobs.removeFinishedListener(jobNameEmailDigest)
+	ready.Signal(ctx)
+
+	for {
+		next := j.schedule.Next(time.Now())
+		log.WithField("next", next).Debug("waiting for next digest")
+		t := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-j.finished:
+			t.Stop()
+			j.digest.collect(j.jobs.status())
+			continue
+		case <-t.C:
+		}
+		j.digest.collect(j.jobs.status())
+		now := time.Now()
+		msg := j.message(j.digest.text(j.hostname, now), now)
+		if err := j.smtp.send(j.from, j.to, msg); err != nil {
+			// the next digest covers the invocations of this one, too
+			log.WithError(err).Error("cannot send email digest")
+			continue
+		}
+		j.digest.reset(now)
+		log.WithField("to", j.to).Info("sent email digest")
+	}
+}
+
+func (j *emailDigestJob) message(body string, now time.Time) []byte {
+	var b bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&b, "%s: %s\r\n", k, v) }
+	header("From", j.from)
+	header("To", strings.Join(j.to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", j.subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	b.WriteString("\r\n")
+	b.WriteString(strings.Replace(body, "\n", "\r\n", -1))
+	return b.Bytes()
+}
+
+func (s *digestSMTP) send(from string, to []string, msg []byte) error {
+	host, _, err := net.SplitHostPort(s.address)
+	if err != nil {
+		return err
+	}
+	tlsConfig := &tls.Config{ServerName: host}
+	var conn net.Conn
+	if s.tls == "tls" {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: s.timeout}, "tcp", s.address, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", s.address, s.timeout)
+	}
+	if err != nil {
+		return errors.Wrap(err, "cannot connect to smtp server")
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if s.tls == "starttls" {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("smtp server does not support STARTTLS")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return errors.Wrap(err, "STARTTLS")
+		}
+	}
+	if s.username != "" {
+		// PlainAuth refuses to send the password over a connection without TLS, except to localhost
+		if err := c.Auth(smtp.PlainAuth("", s.username, s.password, host)); err != nil {
+			return errors.Wrap(err, "smtp authentication")
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return errors.Wrapf(err, "recipient %q", addr)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// digestCollector accumulates the invocations of the jobs since the last digest.
+type digestCollector struct {
+	mtx   sync.Mutex
+	since time.Time
+	jobs  map[string]*digestJobSummary // by job name
+	// by job name, the FinishAt of the last collected invocation, survives reset
+	collected map[string]time.Time
+}
+
+type digestJobSummary struct {
+	typ                 string
+	invocations, failed int
+	bytesReplicated     uint64
+	pruned              int
+	// the most recent errors, oldest first, at most digestMaxErrors
+	errs []digestError
+}
+
+type digestError struct {
+	at  time.Time
+	err string
+}
+
+// globalDigest outlives the job, which is restarted if the monitoring config changes on reload,
+// so that the restart does not lose the invocations collected so far.
+var globalDigest = newDigestCollector(time.Now())
+
+func newDigestCollector(since time.Time) *digestCollector {
+	return &digestCollector{
+		since:     since,
+		jobs:      make(map[string]*digestJobSummary),
+		collected: make(map[string]time.Time),
+	}
+}
+
+func (d *digestCollector) collect(statuses map[string]*job.Status) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	for name, s := range statuses {
+		var invocations []*job.InvocationSummary
+		switch js := s.JobSpecific.(type) {
+		case *job.ActiveSideStatus:
+			invocations = js.RecentInvocations
+		case *job.SnapJobStatus:
+			invocations = js.RecentInvocations
+		default:
+			continue
+		}
+		summary, ok := d.jobs[name]
+		if !ok {
+			summary = &digestJobSummary{typ: string(s.Type)}
+			d.jobs[name] = summary
+		}
+		for _, inv := range invocations {
+			if !inv.FinishAt.After(d.collected[name]) {
+				continue
+			}
+			d.collected[name] = inv.FinishAt
+			summary.invocations++
+			summary.bytesReplicated += inv.BytesReplicated
+			summary.pruned += inv.Pruned
+			if inv.Error != "" {
+				summary.failed++
+				summary.errs = append(summary.errs, digestError{inv.FinishAt, inv.Error})
+				if len(summary.errs) > digestMaxErrors {
+					summary.errs = summary.errs[1:]
+				}
+			}
+		}
+	}
+}
+
+// reset starts the next digest at since, i.e., discards the invocations collected so far.
+func (d *digestCollector) reset(since time.Time) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.since = since
+	d.jobs = make(map[string]*digestJobSummary)
+}
+
+// text renders the digest as the plain-text body of the email.
+func (d *digestCollector) text(hostname string, now time.Time) string {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	const timeFormat = "2006-01-02 15:04"
+
+	names := make([]string, 0, len(d.jobs))
+	failed := 0
+	for name, j := range d.jobs {
+		names = append(names, name)
+		if j.failed > 0 {
+			failed++
+		}
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "zrepl digest of %s from %s to %s\n", hostname, d.since.Format(timeFormat), now.Format(timeFormat))
+	fmt.Fprintf(&b, "%d job(s), %d with failed invocations\n", len(names), failed)
+	for _, name := range names {
+		j := d.jobs[name]
+		fmt.Fprintf(&b, "\n%s (%s): %d invocation(s), %d failed", name, j.typ, j.invocations, j.failed)
+		if j.typ != string(job.TypeSnap) {
+			fmt.Fprintf(&b, ", %s replicated", digestByteCount(j.bytesReplicated))
+		}
+		fmt.Fprintf(&b, ", %d snapshot(s) pruned\n", j.pruned)
+		if j.invocations == 0 {
+			b.WriteString("  No invocations, check the job's schedule, e.g., with `zrepl status`.\n")
+		}
+		if len(j.errs) > 0 {
+			if j.failed > len(j.errs) {
+				fmt.Fprintf(&b, "  Last %d errors:\n", len(j.errs))
+			} else {
+				b.WriteString("  Errors:\n")
+			}
+			for _, e := range j.errs {
+				fmt.Fprintf(&b, "    - %s: %s\n", e.at.Format(timeFormat), strings.Replace(e.err, "\n", "\n      ", -1))
+			}
+		}
+	}
+	return b.String()
+}
+
+// digestByteCount is like viewmodel.ByteCountBinaryUint, which the daemon cannot import.
+func digestByteCount(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
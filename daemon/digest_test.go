@@ -0,0 +1,141 @@
+package daemon
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/job"
+)
+
+func TestDigestCollector(t *testing.T) {
+	start := time.Date(2026, 10, 7, 8, 0, 0, 0, time.UTC)
+	at := func(h time.Duration) time.Time { return start.Add(h * time.Hour) }
+	push := &job.ActiveSideStatus{}
+	snap := &job.SnapJobStatus{}
+	statuses := map[string]*job.Status{
+		"prod_to_backups": {Type: job.TypePush, JobSpecific: push},
+		"snap":            {Type: job.TypeSnap, JobSpecific: snap},
+		"idle":            {Type: job.TypePull, JobSpecific: &job.ActiveSideStatus{}},
+		"_control":        {Type: job.TypeInternal},
+	}
+
+	d := newDigestCollector(start)
+	push.RecentInvocations = []*job.InvocationSummary{
+		{FinishAt: at(1), BytesReplicated: 1 << 30, Pruned: 3},
+		{FinishAt: at(2), BytesReplicated: 1 << 29, Error: "replication: 1 filesystem(s) failed"},
+	}
+	d.collect(statuses)
+	push.RecentInvocations = append(push.RecentInvocations, &job.InvocationSummary{FinishAt: at(3), Pruned: 1})
+	snap.RecentInvocations = []*job.InvocationSummary{{FinishAt: at(3), Pruned: 10}}
+	d.collect(statuses)
+
+	assert.Equal(t, `zrepl digest of nas1 from 2026-10-07 08:00 to 2026-10-14 08:00
+3 job(s), 1 with failed invocations
+
+idle (pull): 0 invocation(s), 0 failed, 0 B replicated, 0 snapshot(s) pruned
+  No invocations, check the job's schedule, e.g., with `+"`zrepl status`"+`.
+
+prod_to_backups (push): 3 invocation(s), 1 failed, 1.5 GiB replicated, 4 snapshot(s) pruned
+  Errors:
+    - 2026-10-07 10:00: replication: 1 filesystem(s) failed
+
+snap (snap): 1 invocation(s), 0 failed, 10 snapshot(s) pruned
+`, d.text("nas1", at(7*24)))
+
+	d.reset(at(7 * 24))
+	d.collect(statuses)
+	assert.Contains(t, d.text("nas1", at(8*24)), "prod_to_backups (push): 0 invocation(s)",
+		"invocations of the previous digest are not collected again")
+
+	for i := 0; i < digestMaxErrors+2; i++ {
+		push.RecentInvocations = append(push.RecentInvocations, &job.InvocationSummary{FinishAt: at(7*24 + time.Duration(i+1)), Error: "err"})
+	}
+	d.collect(statuses)
+	text := d.text("nas1", at(8*24))
+	assert.Contains(t, text, "Last 5 errors:")
+	assert.Equal(t, digestMaxErrors, strings.Count(text, ": err\n"))
+}
+
+// fakeSMTPServer accepts one message without TLS and returns the commands and the data it received.
+func fakeSMTPServer(t *testing.T) (addr string, received <-chan []string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	c := make(chan []string, 1)
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+		var lines []string
+		reply("220 localhost ESMTP")
+		for data := false; ; {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				c <- lines
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			lines = append(lines, line)
+			switch {
+			case data && line == ".":
+				data = false
+				reply("250 OK")
+			case data:
+			case strings.HasPrefix(line, "EHLO"):
+				reply("250 localhost")
+			case line == "DATA":
+				data = true
+				reply("354 go ahead")
+			case line == "QUIT":
+				reply("221 bye")
+				c <- lines
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return l.Addr().String(), c
+}
+
+func TestEmailDigestSend(t *testing.T) {
+	addr, received := fakeSMTPServer(t)
+	j, err := newEmailDigestJobFromConfig(&config.EmailDigestMonitoring{
+		From:    "zrepl <zrepl@nas1.example.com>",
+		To:      []string{"admin@example.com", "backup@example.com"},
+		Subject: "Backups",
+		SMTP:    &config.EmailDigestSMTP{Address: addr, TLS: "none", Timeout: 10 * time.Second},
+	}, newJobs())
+	require.NoError(t, err)
+
+	now := time.Date(2026, 10, 14, 8, 0, 0, 0, time.UTC)
+	require.NoError(t, j.smtp.send(j.from, j.to, j.message("line 1\nline 2\n", now)))
+	lines := <-received
+	assert.Contains(t, lines, "MAIL FROM:<zrepl@nas1.example.com>")
+	assert.Contains(t, lines, "RCPT TO:<admin@example.com>")
+	assert.Contains(t, lines, "RCPT TO:<backup@example.com>")
+	assert.Contains(t, lines, "Subject: Backups")
+	assert.Contains(t, lines, "Date: Wed, 14 Oct 2026 08:00:00 +0000")
+	assert.Contains(t, lines, "line 2")
+
+	_, err = newEmailDigestJobFromConfig(&config.EmailDigestMonitoring{
+		From: "zrepl@nas1", To: []string{"admin@example.com"},
+		SMTP: &config.EmailDigestSMTP{Address: addr, TLS: "ssl"},
+	}, newJobs())
+	assert.Error(t, err)
+	_, err = newEmailDigestJobFromConfig(&config.EmailDigestMonitoring{
+		From: "zrepl@nas1", SMTP: &config.EmailDigestSMTP{Address: addr, TLS: "none"},
+	}, newJobs())
+	assert.Error(t, err, "no recipients")
+}
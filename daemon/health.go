@@ -0,0 +1,74 @@
+package daemon
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zrepl/zrepl/util/envconst"
+)
+
+var healthzTimeout = envconst.Duration("ZREPL_DAEMON_HEALTHZ_TIMEOUT", 10*time.Second)
+
+// notReady returns the names of the jobs that have not signalled readiness yet
+// and of those that exited, e.g., because they could not bind their listeners.
+// loaded is false until the daemon has started the jobs of the config.
+func (s *jobs) notReady() (loaded bool, starting, exited []string) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	for name := range s.jobs {
+		select {
+		case <-s.exited[name]:
+			exited = append(exited, name)
+			continue
+		default:
+		}
+		select {
+		case <-s.ready[name]:
+		default:
+			starting = append(starting, name)
+		}
+	}
+	sort.Strings(starting)
+	sort.Strings(exited)
+	return s.configLoaded, starting, exited
+}
+
+// handleHealthz reports the liveness of the daemon:
+// it fails if the jobs' status cannot be collected within healthzTimeout, i.e., if the daemon is hung.
+func (s *jobs) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	collected := make(chan struct{})
+	go func() {
+		s.status()
+		close(collected)
+	}()
+	select {
+	case <-collected:
+		fmt.Fprintln(w, "ok")
+	case <-time.After(healthzTimeout):
+		http.Error(w, fmt.Sprintf("collecting job status timed out after %s, daemon might be hung", healthzTimeout), http.StatusServiceUnavailable)
+	}
+}
+
+// handleReadyz reports the readiness of the daemon:
+// the config is loaded, all jobs have signalled readiness (e.g., their listeners are bound), and none has exited.
+func (s *jobs) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	loaded, starting, exited := s.notReady()
+	if loaded && len(starting) == 0 && len(exited) == 0 {
+		fmt.Fprintln(w, "ok")
+		return
+	}
+	var reasons []string
+	if !loaded {
+		reasons = append(reasons, "config not loaded")
+	}
+	if len(starting) > 0 {
+		reasons = append(reasons, "jobs starting: "+strings.Join(starting, ", "))
+	}
+	if len(exited) > 0 {
+		reasons = append(reasons, "jobs exited: "+strings.Join(exited, ", "))
+	}
+	http.Error(w, "not ready: "+strings.Join(reasons, "; "), http.StatusServiceUnavailable)
+}
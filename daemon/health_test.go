@@ -0,0 +1,75 @@
+package daemon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/job/disable"
+)
+
+type hungStatusJob struct {
+	prometheusJob
+	unblock chan struct{}
+}
+
+func (j *hungStatusJob) Status() *job.Status {
+	<-j.unblock
+	return j.prometheusJob.Status()
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	s := newJobs()
+	add := func(name string, j job.Job) (ready, exited chan struct{}) {
+		ready, exited = make(chan struct{}), make(chan struct{})
+		s.jobs[name], s.ready[name], s.exited[name] = j, ready, exited
+		s.switches[name] = &disable.Switch{}
+		return ready, exited
+	}
+	do := func(h http.HandlerFunc) (int, string) {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w.Code, w.Body.String()
+	}
+
+	controlReady, _ := add(jobNameControl, &controlJob{})
+	close(controlReady)
+	code, body := do(s.handleReadyz)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not ready: config not loaded\n", body)
+
+	_, sinkExited := add("sink", &prometheusJob{})
+	pushReady, _ := add("push", &prometheusJob{})
+	s.configLoaded = true
+	code, body = do(s.handleReadyz)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not ready: jobs starting: push, sink\n", body)
+
+	close(pushReady)
+	close(sinkExited)
+	code, body = do(s.handleReadyz)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not ready: jobs exited: sink\n", body)
+
+	delete(s.jobs, "sink")
+	code, body = do(s.handleReadyz)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok\n", body)
+
+	code, body = do(s.handleHealthz)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok\n", body)
+
+	defer func(timeout time.Duration) { healthzTimeout = timeout }(healthzTimeout)
+	healthzTimeout = 10 * time.Millisecond
+	hung := &hungStatusJob{unblock: make(chan struct{})}
+	defer close(hung.unblock)
+	add("hung", hung)
+	code, body = do(s.handleHealthz)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, body, "daemon might be hung")
+}
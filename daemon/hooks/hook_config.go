@@ -10,18 +10,50 @@ import (
 type List []Hook
 
 func HookFromConfig(in config.HookEnum) (Hook, error) {
+	var h Hook
+	var err error
 	switch v := in.Ret.(type) {
 	case *config.HookCommand:
-		return NewCommandHook(v)
+		h, err = NewCommandHook(v)
 	case *config.HookPostgresCheckpoint:
-		return PgChkptHookFromConfig(v)
+		h, err = PgChkptHookFromConfig(v)
 	case *config.HookMySQLLockTables:
-		return MyLockTablesFromConfig(v)
+		h, err = MyLockTablesFromConfig(v)
+	case *config.HookWebhook:
+		h, err = WebhookFromConfig(v)
 	default:
 		return nil, fmt.Errorf("unknown hook type %T", v)
 	}
+	if err != nil {
+		return nil, err
+	}
+	common := hookSettingsCommon(in)
+	if _, ok := in.Ret.(*config.HookWebhook); ok {
+		common.Retries = nil // WebhookHook only retries temporary errors itself
+	}
+	policy, err := hookPolicyFromConfig(common, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &policyHook{h, policy, common.Type}, nil
+}
+
+func hookSettingsCommon(in config.HookEnum) config.HookSettingsCommon {
+	switch v := in.Ret.(type) {
+	case *config.HookCommand:
+		return v.HookSettingsCommon
+	case *config.HookPostgresCheckpoint:
+		return v.HookSettingsCommon
+	case *config.HookMySQLLockTables:
+		return v.HookSettingsCommon
+	case *config.HookWebhook:
+		return v.HookSettingsCommon
+	default:
+		panic(fmt.Sprintf("unknown hook type %T", v))
+	}
 }
 
+// ListFromConfig builds the hooks of snapshotting.hooks.
 func ListFromConfig(in *config.HookList) (r *List, err error) {
 	hl := make(List, len(*in))
 
@@ -30,6 +62,9 @@ func ListFromConfig(in *config.HookList) (r *List, err error) {
 		if err != nil {
 			return nil, fmt.Errorf("create hook #%d: %s", i+1, err)
 		}
+		if len(hookSettingsCommon(h).Events) > 0 {
+			return nil, fmt.Errorf("create hook #%d: `events` is only supported for replication hooks", i+1)
+		}
 	}
 
 	return &hl, nil
@@ -0,0 +1,315 @@
+package hooks
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql/driver"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/secret"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// dbConnectors selects the database connection of a database hook for a filesystem.
+type dbConnectors struct {
+	// nil if the hook's connection is only configured in `databases`
+	fallback driver.Connector
+	mappings []dbConnectorMapping
+}
+
+type dbConnectorMapping struct {
+	filesystems zfs.DatasetFilter
+	connector   driver.Connector
+}
+
+type newDBConnectorFunc func(c config.HookDatabaseConnection) (driver.Connector, error)
+
+func dbConnectorsFromConfig(base config.HookDatabaseConnection, databases []config.HookDatabaseMapping, newConnector newDBConnectorFunc) (*dbConnectors, error) {
+	cs := &dbConnectors{}
+	if dbConnectionHasTarget(base) {
+		cn, err := newConnector(base)
+		if err != nil {
+			return nil, err
+		}
+		cs.fallback = cn
+	} else if len(databases) == 0 {
+		return nil, fmt.Errorf("one of `dsn`, `host` or `socket` must be specified")
+	}
+	for i, m := range databases {
+		f, err := filters.DatasetMapFilterFromConfig(m.Filesystems)
+		if err != nil {
+			return nil, errors.Wrapf(err, "`databases` entry #%d: `filesystems` invalid", i+1)
+		}
+		c := mergeDBConnection(base, m.HookDatabaseConnection)
+		if !dbConnectionHasTarget(c) {
+			return nil, fmt.Errorf("`databases` entry #%d: one of `dsn`, `host` or `socket` must be specified", i+1)
+		}
+		cn, err := newConnector(c)
+		if err != nil {
+			return nil, errors.Wrapf(err, "`databases` entry #%d", i+1)
+		}
+		cs.mappings = append(cs.mappings, dbConnectorMapping{f, cn})
+	}
+	return cs, nil
+}
+
+// The first entry of `databases` that matches fs wins.
+func (cs *dbConnectors) forFilesystem(fs *zfs.DatasetPath) (driver.Connector, error) {
+	for _, m := range cs.mappings {
+		pass, err := m.filesystems.Filter(fs)
+		if err != nil {
+			return nil, err
+		}
+		if pass {
+			return m.connector, nil
+		}
+	}
+	if cs.fallback == nil {
+		return nil, fmt.Errorf("no entry in `databases` matches filesystem %q", fs.ToString())
+	}
+	return cs.fallback, nil
+}
+
+func dbConnectionHasTarget(c config.HookDatabaseConnection) bool {
+	return c.DSN != "" || c.Host != "" || c.Socket != ""
+}
+
+// The non-empty fields of override replace those of base.
+func mergeDBConnection(base, override config.HookDatabaseConnection) config.HookDatabaseConnection {
+	c := base
+	if override.DSN != "" {
+		c.DSN = override.DSN
+	}
+	if override.Host != "" || override.Socket != "" {
+		c.Host, c.Port, c.Socket = override.Host, override.Port, override.Socket
+	} else if override.Port != 0 {
+		c.Port = override.Port
+	}
+	if override.User != "" {
+		c.User = override.User
+	}
+	if !override.PasswordFile.IsZero() {
+		c.PasswordFile = override.PasswordFile
+	}
+	if override.Database != "" {
+		c.Database = override.Database
+	}
+	if override.TLS != nil {
+		c.TLS = override.TLS
+	}
+	return c
+}
+
+func loadDBPassword(s config.Secret) (string, error) {
+	pw, err := secret.Load(s)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot load `password_file`")
+	}
+	return string(pw), nil
+}
+
+func checkDBConnection(c config.HookDatabaseConnection) error {
+	if c.Host != "" && c.Socket != "" {
+		return fmt.Errorf("`host` and `socket` are mutually exclusive")
+	}
+	if !c.PasswordFile.IsZero() && c.User == "" && c.DSN == "" {
+		return fmt.Errorf("`password_file` requires `user`")
+	}
+	return nil
+}
+
+func pgConnector(c config.HookDatabaseConnection) (driver.Connector, error) {
+	dsn, err := pgDSN(c)
+	if err != nil {
+		return nil, err
+	}
+	cn, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid connection settings")
+	}
+	return cn, nil
+}
+
+// Builds a key=value connection string for lib/pq, see
+// https://www.postgresql.org/docs/current/libpq-connect.html#LIBPQ-CONNSTRING
+// Later keys override earlier ones, thus the explicit settings are appended to the DSN.
+func pgDSN(c config.HookDatabaseConnection) (string, error) {
+	if err := checkDBConnection(c); err != nil {
+		return "", err
+	}
+	dsn := c.DSN
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		var err error
+		if dsn, err = pq.ParseURL(dsn); err != nil {
+			return "", errors.Wrap(err, "`dsn` invalid")
+		}
+	}
+	var opts []string
+	add := func(k, v string) {
+		v = strings.Replace(v, `\`, `\\`, -1)
+		v = strings.Replace(v, `'`, `\'`, -1)
+		opts = append(opts, fmt.Sprintf("%s='%s'", k, v))
+	}
+	if c.Host != "" {
+		add("host", c.Host)
+	}
+	if c.Socket != "" {
+		// libpq expects the directory that contains the socket
+		add("host", c.Socket)
+	}
+	if c.Port != 0 {
+		add("port", strconv.Itoa(int(c.Port)))
+	}
+	if c.User != "" {
+		add("user", c.User)
+	}
+	if !c.PasswordFile.IsZero() {
+		pw, err := loadDBPassword(c.PasswordFile)
+		if err != nil {
+			return "", err
+		}
+		add("password", pw)
+	}
+	if c.Database != "" {
+		add("dbname", c.Database)
+	}
+	if c.TLS != nil {
+		if c.TLS.InsecureSkipVerify {
+			add("sslmode", "require")
+		} else {
+			add("sslmode", "verify-full")
+		}
+		if c.TLS.CA != "" {
+			add("sslrootcert", c.TLS.CA)
+		}
+		if c.TLS.Cert != "" {
+			add("sslcert", c.TLS.Cert)
+		}
+		if !c.TLS.Key.IsZero() {
+			// libpq reads the key itself
+			if c.TLS.Key.File == "" {
+				return "", fmt.Errorf("`tls.key` must be a file for postgres")
+			}
+			add("sslkey", c.TLS.Key.File)
+		}
+	}
+	if len(opts) > 0 {
+		dsn = strings.TrimSpace(dsn + " " + strings.Join(opts, " "))
+	}
+	return dsn, nil
+}
+
+// go-sql-driver/mysql only accepts custom TLS configs in a global registry.
+var mysqlTLSConfigCount uint32
+
+func mysqlConnector(c config.HookDatabaseConnection) (driver.Connector, error) {
+	conf, err := mysqlConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	cn, err := mysql.NewConnector(conf)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid connection settings")
+	}
+	return cn, nil
+}
+
+func mysqlConfig(c config.HookDatabaseConnection) (*mysql.Config, error) {
+	if err := checkDBConnection(c); err != nil {
+		return nil, err
+	}
+	conf := mysql.NewConfig()
+	if c.DSN != "" {
+		var err error
+		if conf, err = mysql.ParseDSN(c.DSN); err != nil {
+			return nil, errors.Wrap(err, "`dsn` invalid")
+		}
+	}
+	if c.Socket != "" {
+		conf.Net, conf.Addr = "unix", c.Socket
+	}
+	if c.Socket == "" && (c.Host != "" || c.Port != 0) {
+		host, port := c.Host, c.Port
+		if conf.Net == "tcp" && conf.Addr != "" {
+			h, p, err := net.SplitHostPort(conf.Addr)
+			if err != nil {
+				return nil, errors.Wrap(err, "`dsn` invalid")
+			}
+			if host == "" {
+				host = h
+			}
+			if port == 0 {
+				pn, _ := strconv.ParseUint(p, 10, 16)
+				port = uint16(pn)
+			}
+		}
+		if host == "" {
+			host = "localhost"
+		}
+		if port == 0 {
+			port = 3306
+		}
+		conf.Net, conf.Addr = "tcp", net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	if c.User != "" {
+		conf.User = c.User
+	}
+	if !c.PasswordFile.IsZero() {
+		pw, err := loadDBPassword(c.PasswordFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.Passwd = pw
+	}
+	if c.Database != "" {
+		conf.DBName = c.Database
+	}
+	if c.TLS != nil {
+		tlsConf, err := dbTLSConfig(c.TLS)
+		if err != nil {
+			return nil, errors.Wrap(err, "`tls` invalid")
+		}
+		name := fmt.Sprintf("zrepl-hook-%d", atomic.AddUint32(&mysqlTLSConfigCount, 1))
+		if err := mysql.RegisterTLSConfig(name, tlsConf); err != nil {
+			return nil, err
+		}
+		conf.TLSConfig = name
+	}
+	return conf, nil
+}
+
+func dbTLSConfig(in *config.HookDatabaseTLS) (*tls.Config, error) {
+	conf := &tls.Config{InsecureSkipVerify: in.InsecureSkipVerify}
+	if in.CA != "" {
+		pem, err := ioutil.ReadFile(in.CA)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read `ca`")
+		}
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("`ca` %q contains no PEM certificates", in.CA)
+		}
+	}
+	if (in.Cert == "") != in.Key.IsZero() {
+		return nil, fmt.Errorf("`cert` and `key` must be specified together")
+	}
+	if in.Cert != "" {
+		cert, err := secret.LoadX509KeyPair(in.Cert, in.Key)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot load client certificate")
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
@@ -0,0 +1,114 @@
+package hooks
+
+import (
+	"database/sql/driver"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestPgDSN(t *testing.T) {
+	pwFile, err := ioutil.TempFile("", "zrepl-hook-password")
+	require.NoError(t, err)
+	defer os.Remove(pwFile.Name())
+	_, err = pwFile.WriteString("it's secret\n")
+	require.NoError(t, err)
+	require.NoError(t, pwFile.Close())
+
+	dsn, err := pgDSN(config.HookDatabaseConnection{
+		DSN:          "postgres://postgres@db.example.com:5433/postgres",
+		Socket:       "/run/postgresql",
+		User:         "zrepl",
+		PasswordFile: config.Secret{File: pwFile.Name()},
+		TLS:          &config.HookDatabaseTLS{CA: "/etc/zrepl/ca.crt"},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, dsn, "host='/run/postgresql' user='zrepl' password='it\\'s secret' sslmode='verify-full' sslrootcert='/etc/zrepl/ca.crt'")
+	_, err = pgConnector(config.HookDatabaseConnection{DSN: dsn})
+	assert.NoError(t, err)
+
+	_, err = pgDSN(config.HookDatabaseConnection{Host: "localhost", Socket: "/run/postgresql"})
+	assert.Error(t, err)
+}
+
+func TestMysqlConfig(t *testing.T) {
+	conf, err := mysqlConfig(config.HookDatabaseConnection{DSN: "root@tcp(db.example.com:3307)/", User: "zrepl"})
+	require.NoError(t, err)
+	assert.Equal(t, "zrepl", conf.User)
+	assert.Equal(t, "db.example.com:3307", conf.Addr)
+
+	conf, err = mysqlConfig(config.HookDatabaseConnection{DSN: "root@tcp(db.example.com:3307)/", Port: 3308})
+	require.NoError(t, err)
+	assert.Equal(t, "tcp", conf.Net)
+	assert.Equal(t, "db.example.com:3308", conf.Addr)
+
+	conf, err = mysqlConfig(config.HookDatabaseConnection{Socket: "/run/mysqld/mysqld.sock", User: "zrepl"})
+	require.NoError(t, err)
+	assert.Equal(t, "unix", conf.Net)
+	assert.Equal(t, "/run/mysqld/mysqld.sock", conf.Addr)
+
+	_, err = mysqlConfig(config.HookDatabaseConnection{Host: "localhost", PasswordFile: config.Secret{File: "/nonexistent"}})
+	assert.Error(t, err)
+
+	_, err = mysqlConfig(config.HookDatabaseConnection{Host: "localhost", TLS: &config.HookDatabaseTLS{Cert: "/etc/zrepl/client.crt"}})
+	assert.Error(t, err, "cert without key")
+}
+
+func TestDBConnectorsForFilesystem(t *testing.T) {
+	var targets []config.HookDatabaseConnection
+	newConnector := func(c config.HookDatabaseConnection) (driver.Connector, error) {
+		targets = append(targets, c)
+		return mysqlConnector(c)
+	}
+
+	base := config.HookDatabaseConnection{Socket: "/run/mysqld/mysqld.sock", User: "zrepl"}
+	cs, err := dbConnectorsFromConfig(base, []config.HookDatabaseMapping{
+		{
+			Filesystems:            config.FilesystemsFilter{"tank/mysql/remote<": true},
+			HookDatabaseConnection: config.HookDatabaseConnection{Host: "db.example.com"},
+		},
+		{
+			Filesystems:            config.FilesystemsFilter{"tank/mysql<": true},
+			HookDatabaseConnection: config.HookDatabaseConnection{Database: "app"},
+		},
+	}, newConnector)
+	require.NoError(t, err)
+	require.Len(t, targets, 3)
+	assert.Equal(t, config.HookDatabaseConnection{Host: "db.example.com", User: "zrepl"}, targets[1])
+	assert.Equal(t, config.HookDatabaseConnection{Socket: "/run/mysqld/mysqld.sock", User: "zrepl", Database: "app"}, targets[2])
+
+	for fs, expect := range map[string]driver.Connector{
+		"tank/mysql/remote/a": cs.mappings[0].connector,
+		"tank/mysql/remote":   cs.mappings[0].connector,
+		"tank/mysql/local":    cs.mappings[1].connector,
+		"tank/other":          cs.fallback,
+	} {
+		p, err := zfs.NewDatasetPath(fs)
+		require.NoError(t, err)
+		cn, err := cs.forFilesystem(p)
+		require.NoError(t, err)
+		assert.True(t, cn == expect, "fs=%s", fs)
+	}
+
+	// without a connection outside of `databases`, every filesystem must be mapped
+	cs, err = dbConnectorsFromConfig(config.HookDatabaseConnection{}, []config.HookDatabaseMapping{
+		{
+			Filesystems:            config.FilesystemsFilter{"tank/mysql<": true},
+			HookDatabaseConnection: config.HookDatabaseConnection{Socket: "/run/mysqld/mysqld.sock"},
+		},
+	}, mysqlConnector)
+	require.NoError(t, err)
+	p, err := zfs.NewDatasetPath("tank/other")
+	require.NoError(t, err)
+	_, err = cs.forFilesystem(p)
+	assert.Error(t, err)
+
+	_, err = dbConnectorsFromConfig(config.HookDatabaseConnection{}, nil, mysqlConnector)
+	assert.Error(t, err)
+}
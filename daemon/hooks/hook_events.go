@@ -0,0 +1,138 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// Event is a replication lifecycle event of an active job.
+// It is passed to hooks as EnvType.
+type Event string
+
+const (
+	// Before replication starts. A failure of a hook with ErrIsFatal()
+	// prevents the replication of this invocation.
+	EventReplicationStart Event = "replication_start"
+	// After the replication of a filesystem, for each filesystem of the last replication attempt
+	// and each destination. EnvReplicationState and EnvError describe the outcome.
+	EventFilesystemDone Event = "replication_filesystem_done"
+	// After replication and pruning of an invocation (only replication if pruning has its own schedule).
+	EventJobSuccess Event = "job_success"
+	EventJobFailure Event = "job_failure" // EnvError describes the failure
+)
+
+var events = []Event{EventReplicationStart, EventFilesystemDone, EventJobSuccess, EventJobFailure}
+
+const PhaseReplication = Phase("replication")
+
+// EventHooks runs hooks on replication lifecycle events.
+// Unlike a Plan, there is no pairing of pre- and post-edges:
+// each event invokes the subscribed hooks once, in configuration order.
+type EventHooks struct {
+	hooks []eventHook
+}
+
+type eventHook struct {
+	Hook
+	events map[Event]bool
+}
+
+func EventHooksFromConfig(in config.HookList) (*EventHooks, error) {
+	eh := &EventHooks{}
+	for i, c := range in {
+		switch c.Ret.(type) {
+		case *config.HookCommand, *config.HookWebhook:
+		default:
+			return nil, fmt.Errorf("create hook #%d: hook type %q is not supported for replication hooks", i+1, hookSettingsCommon(c).Type)
+		}
+		h, err := HookFromConfig(c)
+		if err != nil {
+			return nil, fmt.Errorf("create hook #%d: %s", i+1, err)
+		}
+		subscribed := make(map[Event]bool)
+		for _, e := range hookSettingsCommon(c).Events {
+			if !isEvent(Event(e)) {
+				return nil, fmt.Errorf("create hook #%d: invalid event %q, must be one of %q", i+1, e, events)
+			}
+			subscribed[Event(e)] = true
+		}
+		if len(subscribed) == 0 {
+			for _, e := range events {
+				subscribed[e] = true
+			}
+		}
+		eh.hooks = append(eh.hooks, eventHook{h, subscribed})
+	}
+	return eh, nil
+}
+
+func isEvent(e Event) bool {
+	for _, ev := range events {
+		if e == ev {
+			return true
+		}
+	}
+	return false
+}
+
+func (eh *EventHooks) Empty() bool { return eh == nil || len(eh.hooks) == 0 }
+
+// Run invokes the hooks subscribed to event. If extra contains EnvFS,
+// only the hooks whose filesystems filter matches it are invoked.
+// Errors are logged, and fatal is true if a hook with ErrIsFatal() failed.
+func (eh *EventHooks) Run(ctx context.Context, event Event, extra Env) (fatal bool) {
+	if eh.Empty() {
+		return false
+	}
+	ctx, endTask := trace.WithTaskFromStack(ctx)
+	defer endTask()
+
+	edge := Post
+	if event == EventReplicationStart {
+		edge = Pre
+	}
+	env := Env{EnvType: string(event)}
+	for k, v := range extra {
+		env[k] = v
+	}
+	var fs *zfs.DatasetPath
+	if env[EnvFS] != "" {
+		var err error
+		if fs, err = zfs.NewDatasetPath(env[EnvFS]); err != nil {
+			panic(err)
+		}
+	}
+
+	for _, h := range eh.hooks {
+		if !h.events[event] {
+			continue
+		}
+		l := getLogger(ctx).WithField("hook", h.String()).WithField("event", string(event))
+		if fs != nil {
+			pass, err := h.Filesystems().Filter(fs)
+			if err != nil {
+				l.WithError(err).Error("cannot apply filesystems filter of hook")
+				continue
+			}
+			if !pass {
+				continue
+			}
+		}
+		r := h.Run(ctx, edge, PhaseReplication, false, env, make(map[interface{}]interface{}))
+		if !r.HadError() {
+			l.WithField("report", r.String()).Debug("hook succeeded")
+			continue
+		}
+		l.WithError(r).Error("hook failed")
+		abort := false
+		if rr, ok := r.(hookResultReport); ok && rr.HookResult() != nil {
+			abort = rr.HookResult().Action == HookActionAbort
+		}
+		fatal = fatal || h.ErrIsFatal() || abort
+	}
+	return fatal
+}
@@ -0,0 +1,96 @@
+package hooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+)
+
+func TestEventHooksFromConfig(t *testing.T) {
+	_, err := hooks.EventHooksFromConfig(config.HookList{{Ret: &config.HookPostgresCheckpoint{
+		HookSettingsCommon: config.HookSettingsCommon{Type: "postgres-checkpoint"},
+	}}})
+	assert.Error(t, err)
+
+	c := webhookTestConfig("http://localhost")
+	c.Events = []string{"replication_done"}
+	_, err = hooks.EventHooksFromConfig(config.HookList{{Ret: c}})
+	assert.Error(t, err)
+
+	c.Events = nil
+	_, err = hooks.ListFromConfig(&config.HookList{{Ret: c}})
+	assert.NoError(t, err)
+	c.Events = []string{string(hooks.EventJobFailure)}
+	_, err = hooks.ListFromConfig(&config.HookList{{Ret: c}})
+	assert.Error(t, err, "events are not supported for snapshot hooks")
+
+	eh, err := hooks.EventHooksFromConfig(nil)
+	require.NoError(t, err)
+	assert.True(t, eh.Empty())
+}
+
+func TestEventHooksRun(t *testing.T) {
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+
+	s := newWebhookTestServer()
+	defer s.Close()
+
+	all := webhookTestConfig(s.URL)
+	failures := webhookTestConfig(s.URL)
+	failures.Events = []string{string(hooks.EventJobFailure)}
+	failures.Filesystems = config.FilesystemsFilter{"pool/fs": true}
+	eh, err := hooks.EventHooksFromConfig(config.HookList{{Ret: all}, {Ret: failures}})
+	require.NoError(t, err)
+
+	payloads := func() (ps []map[string]interface{}) {
+		for _, b := range s.bodies {
+			var p map[string]interface{}
+			require.NoError(t, json.Unmarshal(b, &p))
+			ps = append(ps, p)
+		}
+		s.bodies = nil
+		return ps
+	}
+
+	fatal := eh.Run(ctx, hooks.EventReplicationStart, hooks.Env{hooks.EnvJob: "testjob"})
+	assert.False(t, fatal)
+	ps := payloads()
+	require.Len(t, ps, 1)
+	assert.Equal(t, "replication_start", ps[0]["ZREPL_HOOKTYPE"])
+
+	// the filesystems filter applies if the event concerns a filesystem
+	eh.Run(ctx, hooks.EventFilesystemDone, hooks.Env{hooks.EnvFS: "pool/other"})
+	assert.Len(t, payloads(), 1)
+
+	eh.Run(ctx, hooks.EventJobFailure, hooks.Env{
+		hooks.EnvJob:               "testjob",
+		hooks.EnvError:             "replication: 1 filesystem(s) failed",
+		hooks.EnvFailedFilesystems: "pool/fs",
+	})
+	ps = payloads()
+	require.Len(t, ps, 2)
+	for _, p := range ps {
+		assert.Equal(t, "job_failure", p["ZREPL_HOOKTYPE"])
+		assert.Equal(t, "replication: 1 filesystem(s) failed", p["ZREPL_ERROR"])
+		assert.Equal(t, []interface{}{"pool/fs"}, p["ZREPL_FAILED_FILESYSTEMS"])
+	}
+
+	t.Run("fatal", func(t *testing.T) {
+		s := newWebhookTestServer(http.StatusBadRequest)
+		defer s.Close()
+		c := webhookTestConfig(s.URL)
+		c.OnError = string(hooks.OnErrorAbortJob)
+		eh, err := hooks.EventHooksFromConfig(config.HookList{{Ret: c}})
+		require.NoError(t, err)
+		assert.True(t, eh.Run(ctx, hooks.EventReplicationStart, nil))
+	})
+}
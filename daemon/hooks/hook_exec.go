@@ -62,6 +62,7 @@ const (
 	StepErr
 	StepSkippedDueToFatalErr
 	StepSkippedDueToPreErr
+	StepSkippedByHook
 )
 
 type HookReport interface {
@@ -183,6 +184,26 @@ func (r PlanReport) HadFatalError() bool {
 	return false
 }
 
+// A hook with OnErrorAbortJob failed.
+func (r PlanReport) AbortJob() bool {
+	for _, e := range r {
+		if e.Edge != Callback && e.Status == StepErr && hookOnError(e.Hook) == OnErrorAbortJob {
+			return true
+		}
+	}
+	return false
+}
+
+// A pre-edge requested to skip the callback, see HookActionSkip.
+func (r PlanReport) SkippedByHook() bool {
+	for _, e := range r {
+		if e.Edge == Callback && e.Status == StepSkippedByHook {
+			return true
+		}
+	}
+	return false
+}
+
 func (r PlanReport) String() string {
 	stepStrings := make([]string, len(r))
 	for i, e := range r {
@@ -222,21 +243,53 @@ func (p *Plan) Run(ctx context.Context, dryRun bool) {
 	// it's a stack, execute until we reach the end of the list (last item in)
 	// or fail inbetween
 	l.Info("run pre-edges in configuration order")
+	var annotations map[string]string
+	skipped := false
 	next := 0
 	for ; next < len(p.pre); next++ {
 		e := p.pre[next]
 		l := l.WithField("hook", e.Hook)
 		r := runHook(e, ctx, Pre)
+		var result *CommandHookResult
+		if rr, ok := r.(hookResultReport); ok {
+			result = rr.HookResult()
+		}
 		if r.HadError() {
 			l.WithError(r).Error("hook invocation failed for pre-edge")
-			if e.Hook.ErrIsFatal() {
+			if e.Hook.ErrIsFatal() || (result != nil && result.Action == HookActionAbort) {
 				l.Error("the hook run was aborted due to a fatal error in this hook")
 				break
 			}
+			continue
+		}
+		if result == nil {
+			continue
+		}
+		for k, v := range result.Annotations {
+			if annotations == nil {
+				annotations = make(map[string]string)
+			}
+			annotations[k] = v
+		}
+		if result.Action == HookActionSkip {
+			l.WithField("message", result.Message).Info("hook requested to skip the callback")
+			skipped = true
+			next++ // this pre-edge was successful, run its post-edge
+			break
 		}
 	}
 
-	hadFatalErr := next != len(p.pre)
+	if skipped {
+		w(func() {
+			for i := next; i < len(p.pre); i++ {
+				p.pre[i].Status = StepSkippedByHook
+				p.post[i].Status = StepSkippedByHook
+			}
+			p.cb.Status = StepSkippedByHook
+		})
+	}
+
+	hadFatalErr := !skipped && next != len(p.pre)
 	if hadFatalErr {
 		l.Error("fatal error in a pre-snapshot hook invocation")
 		l.Error("no snapshot will be taken")
@@ -252,10 +305,12 @@ func (p *Plan) Run(ctx context.Context, dryRun bool) {
 		return
 	}
 
-	l.Info("running callback")
-	cbR := runHook(p.cb, ctx, Callback)
-	if cbR.HadError() {
-		l.WithError(cbR).Error("callback failed")
+	if !skipped {
+		l.Info("running callback")
+		cbR := runHook(p.cb, contextWithAnnotations(ctx, annotations), Callback)
+		if cbR.HadError() {
+			l.WithError(cbR).Error("callback failed")
+		}
 	}
 
 	l.Info("run post-edges for successful pre-edges in reverse configuration order")
@@ -0,0 +1,110 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/zrepl/zrepl/config"
+)
+
+// OnError determines the consequences of a failed hook.
+type OnError string
+
+const (
+	// Log the error and continue.
+	OnErrorWarn OnError = "warn"
+	// Do not snapshot the filesystem, same as err_is_fatal: true.
+	// Only relevant for pre-edges.
+	OnErrorSkipFilesystem OnError = "skip_filesystem"
+	// Do not snapshot the filesystem and do not snapshot the
+	// remaining filesystems of the job in this snapshot run.
+	OnErrorAbortJob OnError = "abort_job"
+)
+
+type hookPolicy struct {
+	onError       OnError
+	retries       int
+	retryInterval time.Duration
+}
+
+func hookPolicyFromConfig(in config.HookSettingsCommon, defaultRetries int) (p hookPolicy, err error) {
+	p.onError = OnError(in.OnError)
+	switch p.onError {
+	case "":
+		p.onError = OnErrorWarn
+		if in.ErrIsFatal {
+			p.onError = OnErrorSkipFilesystem
+		}
+	case OnErrorWarn:
+		if in.ErrIsFatal {
+			return p, fmt.Errorf("`on_error: %s` contradicts `err_is_fatal: true`", p.onError)
+		}
+	case OnErrorSkipFilesystem, OnErrorAbortJob:
+	default:
+		return p, fmt.Errorf("invalid `on_error` %q, must be one of %q, %q or %q",
+			in.OnError, OnErrorWarn, OnErrorSkipFilesystem, OnErrorAbortJob)
+	}
+	p.retries = defaultRetries
+	if in.Retries != nil {
+		p.retries = *in.Retries
+	}
+	if p.retries < 0 {
+		return p, fmt.Errorf("`retries` must not be negative")
+	}
+	p.retryInterval = in.RetryInterval
+	return p, nil
+}
+
+// policyHook applies a hookPolicy to a hook that does not implement it itself.
+// Failed runs are retried, with the retry interval doubled after each attempt.
+// Each run is recorded in the metrics, see observeRun.
+type policyHook struct {
+	Hook
+	hookPolicy
+	hookType string // the type of the hook in the config
+}
+
+func (h *policyHook) ErrIsFatal() bool { return h.onError != OnErrorWarn }
+func (h *policyHook) OnError() OnError { return h.onError }
+
+func (h *policyHook) Run(ctx context.Context, edge Edge, phase Phase, dryRun bool, extra Env, state map[interface{}]interface{}) HookReport {
+	begin := time.Now()
+	r := h.runWithRetries(ctx, edge, phase, dryRun, extra, state)
+	if !dryRun {
+		observeRun(h.hookType, edge, phase, extra, r, time.Since(begin))
+	}
+	return r
+}
+
+func (h *policyHook) runWithRetries(ctx context.Context, edge Edge, phase Phase, dryRun bool, extra Env, state map[interface{}]interface{}) HookReport {
+	l := getLogger(ctx).WithField("hook", h.Hook.String())
+	backoff := h.retryInterval
+	for attempt := 0; ; attempt++ {
+		r := h.Hook.Run(ctx, edge, phase, dryRun, extra, state)
+		if !r.HadError() || attempt >= h.retries {
+			return r
+		}
+		if rr, ok := r.(hookResultReport); ok && rr.HookResult() != nil && rr.HookResult().Action == HookActionAbort {
+			return r // the hook failed on purpose
+		}
+		l.WithError(r).WithField("attempt", attempt+2).WithField("backoff", backoff).Warn("retrying hook")
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return r
+		}
+		backoff *= 2
+	}
+}
+
+// hookOnError returns the OnError of h, which may be a policyHook.
+func hookOnError(h Hook) OnError {
+	if ph, ok := h.(interface{ OnError() OnError }); ok {
+		return ph.OnError()
+	}
+	if h.ErrIsFatal() {
+		return OnErrorSkipFilesystem
+	}
+	return OnErrorWarn
+}
@@ -0,0 +1,73 @@
+package hooks
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var metrics struct {
+	runs     *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// labels of the metrics, hook_type is the type in the config,
+// event is ZREPL_HOOKTYPE (e.g. pre_snapshot or replication_start)
+// and outcome is one of success, failure or timeout
+var metricsLabels = []string{"zrepl_job", "hook_type", "event", "outcome"}
+
+func init() {
+	metrics.runs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "zrepl",
+		Subsystem: "hooks",
+		Name:      "runs",
+		Help:      "number of hook runs, including their retries",
+	}, metricsLabels)
+	metrics.duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "zrepl",
+		Subsystem: "hooks",
+		Name:      "duration",
+		Help:      "seconds a hook run took, including its retries",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 600},
+	}, metricsLabels)
+}
+
+func RegisterMetrics(r prometheus.Registerer) {
+	r.MustRegister(metrics.runs)
+	r.MustRegister(metrics.duration)
+}
+
+// observeRun records a run of a hook of type hookType that took d and reported r.
+func observeRun(hookType string, edge Edge, phase Phase, extra Env, r HookReport, d time.Duration) {
+	event := extra[EnvType] // set by EventHooks
+	if event == "" {
+		event = strings.ToLower(edge.StringForPhase(phase))
+	}
+	labels := []string{extra[EnvJob], hookType, event, reportOutcome(r)}
+	metrics.runs.WithLabelValues(labels...).Inc()
+	metrics.duration.WithLabelValues(labels...).Observe(d.Seconds())
+}
+
+// reportOutcome returns the outcome label of r, see metricsLabels.
+func reportOutcome(r HookReport) string {
+	if !r.HadError() {
+		return "success"
+	}
+	if t, ok := r.(interface{ TimedOut() bool }); ok && t.TimedOut() {
+		return "timeout"
+	}
+	return "failure"
+}
+
+// isTimeout returns true if err is caused by an exceeded deadline, e.g. the hook's timeout.
+func isTimeout(err error) bool {
+	cause := errors.Cause(err)
+	if cause == context.DeadlineExceeded {
+		return true
+	}
+	t, ok := cause.(interface{ Timeout() bool })
+	return ok && t.Timeout()
+}
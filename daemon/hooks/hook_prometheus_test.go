@@ -0,0 +1,69 @@
+package hooks_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+)
+
+func TestHookMetrics(t *testing.T) {
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+
+	r := prometheus.NewRegistry()
+	hooks.RegisterMetrics(r)
+	// the metrics are global, count the runs of this test only
+	runs := func(job, event, outcome string) (n uint64) {
+		mfs, err := r.Gather()
+		require.NoError(t, err)
+		for _, mf := range mfs {
+			if mf.GetName() != "zrepl_hooks_duration" {
+				continue
+			}
+		metrics:
+			for _, m := range mf.GetMetric() {
+				want := map[string]string{"zrepl_job": job, "hook_type": "command", "event": event, "outcome": outcome}
+				for _, l := range m.GetLabel() {
+					if want[l.GetName()] != l.GetValue() {
+						continue metrics
+					}
+				}
+				n += m.GetHistogram().GetSampleCount()
+			}
+		}
+		return n
+	}
+
+	run := func(script string, edge hooks.Edge, extra hooks.Env) {
+		h, err := hooks.HookFromConfig(config.HookEnum{Ret: &config.HookCommand{
+			Path:               cwd + "/test/" + script,
+			Timeout:            time.Second,
+			Filesystems:        config.FilesystemsFilter{"<": true},
+			Protocol:           "env",
+			HookSettingsCommon: config.HookSettingsCommon{Type: "command"},
+		}})
+		require.NoError(t, err)
+		h.Run(ctx, edge, hooks.PhaseSnapshot, false, extra, make(map[interface{}]interface{}))
+	}
+	env := hooks.Env{hooks.EnvJob: "metrics_test", hooks.EnvFS: "pool/fs", hooks.EnvSnapshot: "zrepl_1"}
+	run("test-report-env.sh", hooks.Pre, env)
+	run("test-error.sh", hooks.Post, env)
+	run("test-timeout.sh", hooks.Pre, env)
+	run("test-error.sh", hooks.Post, hooks.Env{hooks.EnvJob: "metrics_test", hooks.EnvType: string(hooks.EventJobFailure)})
+
+	assert.Equal(t, uint64(1), runs("metrics_test", "pre_snapshot", "success"))
+	assert.Equal(t, uint64(1), runs("metrics_test", "post_snapshot", "failure"))
+	assert.Equal(t, uint64(1), runs("metrics_test", "pre_snapshot", "timeout"))
+	assert.Equal(t, uint64(1), runs("metrics_test", "job_failure", "failure"), "replication hooks are labelled with the event")
+}
@@ -1,9 +1,12 @@
 package hooks
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
 	"os"
 	"os/exec"
@@ -12,21 +15,36 @@ import (
 	"sync"
 	"time"
 
+	"github.com/pkg/errors"
+
 	"github.com/zrepl/zrepl/config"
 	"github.com/zrepl/zrepl/daemon/filters"
 	"github.com/zrepl/zrepl/logger"
 	"github.com/zrepl/zrepl/util/circlog"
 	"github.com/zrepl/zrepl/util/envconst"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
 )
 
 type HookEnvVar string
 
 const (
-	EnvType     HookEnvVar = "ZREPL_HOOKTYPE"
-	EnvDryRun   HookEnvVar = "ZREPL_DRYRUN"
-	EnvFS       HookEnvVar = "ZREPL_FS"
-	EnvSnapshot HookEnvVar = "ZREPL_SNAPNAME"
-	EnvTimeout  HookEnvVar = "ZREPL_TIMEOUT"
+	EnvType        HookEnvVar = "ZREPL_HOOKTYPE"
+	EnvDryRun      HookEnvVar = "ZREPL_DRYRUN"
+	EnvFS          HookEnvVar = "ZREPL_FS"
+	EnvSnapshot    HookEnvVar = "ZREPL_SNAPNAME"
+	EnvTimeout     HookEnvVar = "ZREPL_TIMEOUT"
+	EnvJob         HookEnvVar = "ZREPL_JOB"
+	EnvMountpoint  HookEnvVar = "ZREPL_FS_MOUNTPOINT"
+	EnvEncryption  HookEnvVar = "ZREPL_FS_ENCRYPTION"
+	EnvFilesystems HookEnvVar = "ZREPL_FILESYSTEMS" // newline-separated
+	EnvContextFile HookEnvVar = "ZREPL_HOOK_CONTEXT_FILE"
+
+	// replication lifecycle events, see EventHooks
+	EnvDestination       HookEnvVar = "ZREPL_DESTINATION"
+	EnvSource            HookEnvVar = "ZREPL_SOURCE"
+	EnvReplicationState  HookEnvVar = "ZREPL_REPLICATION_STATE"
+	EnvError             HookEnvVar = "ZREPL_ERROR"
+	EnvFailedFilesystems HookEnvVar = "ZREPL_FAILED_FILESYSTEMS" // newline-separated
 )
 
 type Env map[HookEnvVar]string
@@ -55,11 +73,13 @@ func NewHookEnv(edge Edge, phase Phase, dryRun bool, timeout time.Duration, extr
 }
 
 type CommandHook struct {
-	edge       Edge
-	filter     Filter
-	errIsFatal bool
-	command    string
-	timeout    time.Duration
+	edge        Edge
+	filter      Filter
+	errIsFatal  bool
+	command     string
+	timeout     time.Duration
+	contextFile bool
+	protocol    CommandHookProtocol
 }
 
 type CommandHookReport struct {
@@ -68,8 +88,12 @@ type CommandHookReport struct {
 	Env                          Env
 	Err                          error
 	CapturedStdoutStderrCombined []byte
+	Result                       *CommandHookResult // protocol json only
+	timedOut                     bool
 }
 
+func (r *CommandHookReport) HookResult() *CommandHookResult { return r.Result }
+
 func (r *CommandHookReport) String() string {
 	// Reproduces a POSIX shell-compatible command line
 	var cmdLine strings.Builder
@@ -94,7 +118,9 @@ func (r *CommandHookReport) String() string {
 	}
 
 	var msg string
-	if r.Err == nil {
+	if r.Err == nil && r.Result != nil && r.Result.Action == HookActionSkip {
+		msg = fmt.Sprintf("command hook requested skip (%q)", r.Result.Message)
+	} else if r.Err == nil {
 		msg = "command hook"
 	} else {
 		msg = fmt.Sprintf("command hook failed with %q", r.Err)
@@ -113,11 +139,21 @@ func (r *CommandHookReport) HadError() bool {
 	return r.Err != nil
 }
 
+func (r *CommandHookReport) TimedOut() bool { return r.timedOut }
+
 func NewCommandHook(in *config.HookCommand) (r *CommandHook, err error) {
 	r = &CommandHook{
-		errIsFatal: in.ErrIsFatal,
-		command:    in.Path,
-		timeout:    in.Timeout,
+		errIsFatal:  in.ErrIsFatal,
+		command:     in.Path,
+		timeout:     in.Timeout,
+		contextFile: in.ContextFile,
+		protocol:    CommandHookProtocol(in.Protocol),
+	}
+
+	switch r.protocol {
+	case CommandHookProtocolEnv, CommandHookProtocolJSON:
+	default:
+		return nil, fmt.Errorf("invalid protocol %q, must be %q or %q", in.Protocol, CommandHookProtocolEnv, CommandHookProtocolJSON)
 	}
 
 	r.filter, err = filters.DatasetMapFilterFromConfig(in.Filesystems)
@@ -151,6 +187,18 @@ func (h *CommandHook) Run(ctx context.Context, edge Edge, phase Phase, dryRun bo
 	cmdExec := exec.CommandContext(cmdCtx, h.command)
 
 	hookEnv := NewHookEnv(edge, phase, dryRun, h.timeout, extra)
+	if h.contextFile {
+		path, err := writeHookContextFile(hookEnv)
+		if err != nil {
+			return &CommandHookReport{Command: h.command, Env: hookEnv, Err: err}
+		}
+		defer func() {
+			if err := os.Remove(path); err != nil {
+				l.WithError(err).WithField("path", path).Warn("cannot remove hook context file")
+			}
+		}()
+		hookEnv[EnvContextFile] = path
+	}
 	cmdEnv := os.Environ()
 	for k, v := range hookEnv {
 		cmdEnv = append(cmdEnv, fmt.Sprintf("%s=%s", k, v))
@@ -170,6 +218,20 @@ func (h *CommandHook) Run(ctx context.Context, edge Edge, phase Phase, dryRun bo
 	cmdExec.Stderr = io.MultiWriter(logErrWriter, combinedOutput)
 	cmdExec.Stdout = io.MultiWriter(logOutWriter, combinedOutput)
 
+	var stdout *circlog.CircularLog
+	if h.protocol == CommandHookProtocolJSON {
+		event, err := json.Marshal(newCommandHookEvent(edge, phase, hookEnv))
+		if err != nil {
+			return &CommandHookReport{Command: h.command, Env: hookEnv, Err: err}
+		}
+		cmdExec.Stdin = bytes.NewReader(append(event, '\n'))
+		stdout, err = circlog.NewCircularLog(envconst.Int("ZREPL_MAX_HOOK_LOG_SIZE", MAX_HOOK_LOG_SIZE_DEFAULT))
+		if err != nil {
+			return &CommandHookReport{Err: err}
+		}
+		cmdExec.Stdout = io.MultiWriter(logOutWriter, combinedOutput, stdout)
+	}
+
 	report := &CommandHookReport{
 		Command: h.command,
 		Env:     hookEnv,
@@ -181,6 +243,9 @@ func (h *CommandHook) Run(ctx context.Context, edge Edge, phase Phase, dryRun bo
 		report.Err = err
 		return report
 	}
+	if err := zfscmd.ApplyProcessLimits(ctx, cmdExec.Process); err != nil {
+		l.WithError(err).Warn("cannot apply process limits")
+	}
 
 	err = cmdExec.Wait()
 	combinedOutputBytes := combinedOutput.Bytes()
@@ -189,11 +254,69 @@ func (h *CommandHook) Run(ctx context.Context, edge Edge, phase Phase, dryRun bo
 	if err != nil {
 		if cmdCtx.Err() == context.DeadlineExceeded {
 			report.Err = fmt.Errorf("timed out after %s: %s", h.timeout, err)
+			report.timedOut = true
 			return report
 		}
 		report.Err = err
 		return report
 	}
 
+	if stdout != nil {
+		if stdout.TotalWritten() > stdout.Size() {
+			report.Err = fmt.Errorf("hook result exceeds %d bytes", stdout.Size())
+			return report
+		}
+		report.Result, err = parseCommandHookResult(stdout.Bytes())
+		if err != nil {
+			report.Err = err
+			return report
+		}
+		if edge == Pre && report.Result.Action == HookActionAbort {
+			report.Err = fmt.Errorf("hook requested abort: %s", report.Result.Message)
+		}
+	}
+
 	return report
 }
+
+// hookContext returns hookEnv as a JSON-serializable map,
+// with EnvDryRun as a boolean and EnvFilesystems and EnvFailedFilesystems as lists.
+func hookContext(hookEnv Env) map[HookEnvVar]interface{} {
+	ctx := make(map[HookEnvVar]interface{}, len(hookEnv))
+	for k, v := range hookEnv {
+		ctx[k] = v
+	}
+	ctx[EnvDryRun] = hookEnv[EnvDryRun] != ""
+	for _, k := range []HookEnvVar{EnvFilesystems, EnvFailedFilesystems} {
+		if fss, ok := hookEnv[k]; ok {
+			list := []string{}
+			if fss != "" {
+				list = strings.Split(fss, "\n")
+			}
+			ctx[k] = list
+		}
+	}
+	return ctx
+}
+
+// The context file contains hookContext(hookEnv) as a JSON object.
+func writeHookContextFile(hookEnv Env) (path string, err error) {
+	ctx := hookContext(hookEnv)
+
+	f, err := ioutil.TempFile("", "zrepl-hook-context-*.json")
+	if err != nil {
+		return "", errors.Wrap(err, "cannot create hook context file")
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil && closeErr != nil {
+			err = errors.Wrap(closeErr, "cannot write hook context file")
+		}
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+	if err := json.NewEncoder(f).Encode(ctx); err != nil {
+		return "", errors.Wrap(err, "cannot write hook context file")
+	}
+	return f.Name(), nil
+}
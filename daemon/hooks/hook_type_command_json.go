@@ -0,0 +1,143 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CommandHookProtocol selects how a command hook receives the hook event.
+type CommandHookProtocol string
+
+const (
+	// Environment variables only.
+	CommandHookProtocolEnv CommandHookProtocol = "env"
+	// Environment variables, a CommandHookEvent on stdin,
+	// and an optional CommandHookResult on stdout.
+	CommandHookProtocolJSON CommandHookProtocol = "json"
+)
+
+// Written to the stdin of command hooks with protocol json.
+type CommandHookEvent struct {
+	Version     int      `json:"version"`
+	HookType    string   `json:"hook_type"` // e.g. pre_snapshot
+	Edge        string   `json:"edge"`      // pre or post
+	Phase       string   `json:"phase"`
+	Job         string   `json:"job"`
+	Filesystem  string   `json:"filesystem"`
+	Snapshot    string   `json:"snapshot"`
+	Filesystems []string `json:"filesystems"`
+	Mountpoint  string   `json:"mountpoint"`
+	Encryption  string   `json:"encryption"`
+	DryRun      bool     `json:"dry_run"`
+	Timeout     int      `json:"timeout_seconds"`
+
+	// replication lifecycle events only
+	Destination       string   `json:"destination,omitempty"`
+	Source            string   `json:"source,omitempty"`
+	ReplicationState  string   `json:"replication_state,omitempty"`
+	Error             string   `json:"error,omitempty"`
+	FailedFilesystems []string `json:"failed_filesystems,omitempty"`
+}
+
+const CommandHookEventVersion = 1
+
+type HookAction string
+
+const (
+	HookActionContinue HookAction = "continue"
+	// Do not take the snapshot. Not an error, post-edges of successful pre-edges run.
+	HookActionSkip HookAction = "skip"
+	// Do not take the snapshot, the hook fails as if err_is_fatal was set.
+	HookActionAbort HookAction = "abort"
+)
+
+// The stdout of command hooks with protocol json must be empty or a CommandHookResult.
+// The result is only evaluated for pre-edges.
+type CommandHookResult struct {
+	Action  HookAction `json:"action"` // empty means HookActionContinue
+	Message string     `json:"message"`
+	// Set as ZFS user properties on the snapshot, thus keys must contain a colon.
+	Annotations map[string]string `json:"annotations"`
+}
+
+func newCommandHookEvent(edge Edge, phase Phase, env Env) *CommandHookEvent {
+	timeout, _ := strconv.Atoi(env[EnvTimeout])
+	var fss, failed []string
+	if env[EnvFilesystems] != "" {
+		fss = strings.Split(env[EnvFilesystems], "\n")
+	}
+	if env[EnvFailedFilesystems] != "" {
+		failed = strings.Split(env[EnvFailedFilesystems], "\n")
+	}
+	return &CommandHookEvent{
+		Version:     CommandHookEventVersion,
+		HookType:    env[EnvType],
+		Edge:        strings.ToLower(edge.String()),
+		Phase:       phase.String(),
+		Job:         env[EnvJob],
+		Filesystem:  env[EnvFS],
+		Snapshot:    env[EnvSnapshot],
+		Filesystems: fss,
+		Mountpoint:  env[EnvMountpoint],
+		Encryption:  env[EnvEncryption],
+		DryRun:      env[EnvDryRun] != "",
+		Timeout:     timeout,
+
+		Destination:       env[EnvDestination],
+		Source:            env[EnvSource],
+		ReplicationState:  env[EnvReplicationState],
+		Error:             env[EnvError],
+		FailedFilesystems: failed,
+	}
+}
+
+func parseCommandHookResult(stdout []byte) (*CommandHookResult, error) {
+	stdout = bytes.TrimSpace(stdout)
+	if len(stdout) == 0 {
+		return &CommandHookResult{Action: HookActionContinue}, nil
+	}
+	var r CommandHookResult
+	dec := json.NewDecoder(bytes.NewReader(stdout))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&r); err != nil {
+		return nil, fmt.Errorf("stdout is not a valid hook result: %s", err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("stdout contains data after the hook result")
+	}
+	switch r.Action {
+	case "":
+		r.Action = HookActionContinue
+	case HookActionContinue, HookActionSkip, HookActionAbort:
+	default:
+		return nil, fmt.Errorf("invalid hook result action %q", r.Action)
+	}
+	for k := range r.Annotations {
+		if !strings.Contains(k, ":") {
+			return nil, fmt.Errorf("annotation %q is not a valid ZFS user property name, must contain a colon", k)
+		}
+	}
+	return &r, nil
+}
+
+// Implemented by HookReports that carry a result for the hook plan.
+type hookResultReport interface {
+	HookResult() *CommandHookResult // nil if the hook did not return a result
+}
+
+type annotationsContextKey struct{}
+
+func contextWithAnnotations(ctx context.Context, annotations map[string]string) context.Context {
+	return context.WithValue(ctx, annotationsContextKey{}, annotations)
+}
+
+// AnnotationsFromContext returns the annotations that pre-edge hooks returned
+// to the callback of a Plan. The result may be nil.
+func AnnotationsFromContext(ctx context.Context) map[string]string {
+	a, _ := ctx.Value(annotationsContextKey{}).(map[string]string)
+	return a
+}
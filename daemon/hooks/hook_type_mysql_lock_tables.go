@@ -5,10 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
-
-	sqldriver "database/sql/driver"
-
-	"github.com/go-sql-driver/mysql"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -35,7 +32,8 @@ import (
 
 type MySQLLockTables struct {
 	errIsFatal  bool
-	connector   sqldriver.Connector
+	connectors  *dbConnectors
+	timeout     time.Duration
 	filesystems Filter
 }
 
@@ -45,14 +43,18 @@ const (
 	myLockTablesConnection myLockTablesStateKey = 1 + iota
 )
 
+// The global read lock is held by the session that acquired it,
+// thus pre- and post-edge must use the same connection.
+type myLockTablesLock struct {
+	db           *sql.DB
+	conn         *sql.Conn
+	connectionID uint64
+}
+
 func MyLockTablesFromConfig(in *config.HookMySQLLockTables) (*MySQLLockTables, error) {
-	conf, err := mysql.ParseDSN(in.DSN)
+	cs, err := dbConnectorsFromConfig(in.HookDatabaseConnection, in.Databases, mysqlConnector)
 	if err != nil {
-		return nil, errors.Wrap(err, "`dsn` invalid")
-	}
-	cn, err := mysql.NewConnector(conf)
-	if err != nil {
-		return nil, errors.Wrap(err, "`connect` invalid")
+		return nil, err
 	}
 
 	filesystems, err := filters.DatasetMapFilterFromConfig(in.Filesystems)
@@ -62,7 +64,8 @@ func MyLockTablesFromConfig(in *config.HookMySQLLockTables) (*MySQLLockTables, e
 
 	return &MySQLLockTables{
 		in.ErrIsFatal,
-		cn,
+		cs,
+		in.Timeout,
 		filesystems,
 	}, nil
 }
@@ -77,6 +80,7 @@ type MyLockTablesReport struct {
 }
 
 func (r *MyLockTablesReport) HadError() bool { return r.Err != nil }
+func (r *MyLockTablesReport) TimedOut() bool { return isTimeout(r.Err) }
 func (r *MyLockTablesReport) Error() string  { return r.String() }
 func (r *MyLockTablesReport) String() string {
 	var s strings.Builder
@@ -115,31 +119,79 @@ func (h *MySQLLockTables) Run(ctx context.Context, edge Edge, phase Phase, dryRu
 }
 
 func (h *MySQLLockTables) doRunPre(ctx context.Context, fs *zfs.DatasetPath, dry bool, state map[interface{}]interface{}) (err error) {
-	db := sql.OpenDB(h.connector)
+	cn, err := h.connectors.forFilesystem(fs)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	db := sql.OpenDB(cn)
 	defer func(err *error) {
-		if *err != nil {
+		if *err != nil || dry {
 			db.Close()
 		}
 	}(&err)
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func(err *error) {
+		if *err != nil || dry {
+			conn.Close()
+		}
+	}(&err)
 
-	getLogger(ctx).Debug("do FLUSH TABLES WITH READ LOCK")
-	_, err = db.ExecContext(ctx, "FLUSH TABLES WITH READ LOCK")
+	if dry {
+		getLogger(ctx).Debug("dry-run - use ping instead of FLUSH TABLES WITH READ LOCK")
+		return conn.PingContext(ctx)
+	}
+
+	var id uint64
+	if err = conn.QueryRowContext(ctx, "SELECT CONNECTION_ID()").Scan(&id); err != nil {
+		return
+	}
+
+	getLogger(ctx).WithField("connection_id", id).Debug("do FLUSH TABLES WITH READ LOCK")
+	_, err = conn.ExecContext(ctx, "FLUSH TABLES WITH READ LOCK")
 	if err != nil {
 		return
 	}
 
-	state[myLockTablesConnection] = db
+	state[myLockTablesConnection] = &myLockTablesLock{db, conn, id}
 
 	return nil
 }
 
 func (h *MySQLLockTables) doRunPost(ctx context.Context, fs *zfs.DatasetPath, dry bool, state map[interface{}]interface{}) error {
 
-	db := state[myLockTablesConnection].(*sql.DB)
-	defer db.Close()
+	lock, ok := state[myLockTablesConnection].(*myLockTablesLock)
+	if !ok {
+		if dry {
+			return nil
+		}
+		return fmt.Errorf("pre-edge did not acquire a lock")
+	}
+	delete(state, myLockTablesConnection)
+	defer lock.db.Close()
+	defer lock.conn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	// The lock is only released by UNLOCK TABLES or the end of the session.
+	// If the session is still the one that acquired the lock, it was held across the snapshot.
+	var id uint64
+	if err := lock.conn.QueryRowContext(ctx, "SELECT CONNECTION_ID()").Scan(&id); err != nil {
+		return errors.Wrap(err, "cannot verify that the lock was held during the snapshot, the snapshot may be inconsistent")
+	}
+	if id != lock.connectionID {
+		return fmt.Errorf("lock was not held during the snapshot, the snapshot may be inconsistent: connection id changed from %d to %d", lock.connectionID, id)
+	}
 
 	getLogger(ctx).Debug("do UNLOCK TABLES")
-	_, err := db.ExecContext(ctx, "UNLOCK TABLES")
+	_, err := lock.conn.ExecContext(ctx, "UNLOCK TABLES")
 	if err != nil {
 		return err
 	}
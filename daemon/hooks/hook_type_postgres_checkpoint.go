@@ -7,7 +7,6 @@ import (
 	"math"
 	"time"
 
-	"github.com/lib/pq"
 	"github.com/pkg/errors"
 
 	"github.com/zrepl/zrepl/config"
@@ -17,7 +16,8 @@ import (
 
 type PgChkptHook struct {
 	errIsFatal  bool
-	connector   *pq.Connector
+	connectors  *dbConnectors
+	timeout     time.Duration
 	filesystems Filter
 }
 
@@ -26,14 +26,15 @@ func PgChkptHookFromConfig(in *config.HookPostgresCheckpoint) (*PgChkptHook, err
 	if err != nil {
 		return nil, errors.Wrap(err, "`filesystems` invalid")
 	}
-	cn, err := pq.NewConnector(in.DSN)
+	cs, err := dbConnectorsFromConfig(in.HookDatabaseConnection, in.Databases, pgConnector)
 	if err != nil {
-		return nil, errors.Wrap(err, "`dsn` invalid")
+		return nil, err
 	}
 
 	return &PgChkptHook{
 		in.ErrIsFatal,
-		cn,
+		cs,
+		in.Timeout,
 		filesystems,
 	}, nil
 }
@@ -45,6 +46,7 @@ func (h *PgChkptHook) String() string      { return "postgres checkpoint" }
 type PgChkptHookReport struct{ Err error }
 
 func (r *PgChkptHookReport) HadError() bool { return r.Err != nil }
+func (r *PgChkptHookReport) TimedOut() bool { return isTimeout(r.Err) }
 func (r *PgChkptHookReport) Error() string  { return r.Err.Error() }
 func (r *PgChkptHookReport) String() string {
 	if r.Err != nil {
@@ -77,22 +79,36 @@ func (h *PgChkptHook) doRunPre(ctx context.Context, fs *zfs.DatasetPath, dry boo
 		return err
 	}
 
-	db := sql.OpenDB(h.connector)
+	cn, err := h.connectors.forFilesystem(fs)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	db := sql.OpenDB(cn)
 	defer db.Close()
+	// SET applies to the session, thus use the same connection for CHECKPOINT
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
 	dl, ok := ctx.Deadline()
 	if ok {
 		timeout := uint64(math.Floor(time.Until(dl).Seconds() * 1000)) // TODO go1.13 milliseconds
 		getLogger(ctx).WithField("statement_timeout", timeout).Debug("setting statement timeout for CHECKPOINT")
-		_, err := db.ExecContext(ctx, "SET statement_timeout TO ?", timeout)
+		_, err := conn.ExecContext(ctx, fmt.Sprintf("SET statement_timeout TO %d", timeout))
 		if err != nil {
 			return err
 		}
 	}
 	if dry {
 		getLogger(ctx).Debug("dry-run - use ping instead of CHECKPOINT")
-		return db.PingContext(ctx)
+		return conn.PingContext(ctx)
 	}
 	getLogger(ctx).Info("execute CHECKPOINT command")
-	_, err := db.ExecContext(ctx, "CHECKPOINT")
+	_, err = conn.ExecContext(ctx, "CHECKPOINT")
 	return err
 }
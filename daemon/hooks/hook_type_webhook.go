@@ -0,0 +1,232 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/secret"
+)
+
+// Set on webhook requests if `hmac_secret_file` is configured.
+// The value is `sha256=` followed by the hex-encoded HMAC-SHA256 of the request body.
+const WebhookSignatureHeader = "X-Zrepl-Signature-256"
+
+type WebhookHook struct {
+	errIsFatal    bool
+	filesystems   Filter
+	url           string
+	headers       map[string]string
+	payload       *template.Template // nil means hookContext as JSON
+	hmacSecret    []byte             // nil if requests are not signed
+	timeout       time.Duration
+	retries       int
+	retryInterval time.Duration
+}
+
+// The data passed to the `payload` template.
+type webhookTemplateData struct {
+	HookType    string
+	Job         string
+	Filesystem  string
+	Snapshot    string
+	DryRun      bool
+	Filesystems []string
+	Error       string // replication lifecycle events only
+	Env         Env
+}
+
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func WebhookFromConfig(in *config.HookWebhook) (*WebhookHook, error) {
+	filesystems, err := filters.DatasetMapFilterFromConfig(in.Filesystems)
+	if err != nil {
+		return nil, errors.Wrap(err, "`filesystems` invalid")
+	}
+	u, err := url.Parse(in.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "`url` invalid")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("`url` must be a http or https URL, got %q", in.URL)
+	}
+	// network errors are retried by the hook itself, see post()
+	policy, err := hookPolicyFromConfig(in.HookSettingsCommon, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &WebhookHook{
+		errIsFatal:    in.ErrIsFatal,
+		filesystems:   filesystems,
+		url:           in.URL,
+		headers:       in.Headers,
+		timeout:       in.Timeout,
+		retries:       policy.retries,
+		retryInterval: policy.retryInterval,
+	}
+
+	if in.Payload != "" {
+		h.payload, err = template.New("payload").Funcs(webhookTemplateFuncs).Option("missingkey=error").Parse(in.Payload)
+		if err != nil {
+			return nil, errors.Wrap(err, "`payload` invalid")
+		}
+		// catch template errors and templates that do not produce JSON at startup
+		if _, err := h.renderPayload(Env{EnvType: "pre_snapshot", EnvFS: "pool/fs", EnvSnapshot: "zrepl_snap", EnvFilesystems: "pool/fs"}); err != nil {
+			return nil, errors.Wrap(err, "`payload` invalid")
+		}
+	}
+
+	if !in.HMACSecretFile.IsZero() {
+		hmacSecret, err := secret.Load(in.HMACSecretFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot load `hmac_secret_file`")
+		}
+		h.hmacSecret = hmacSecret
+	}
+
+	return h, nil
+}
+
+func (h *WebhookHook) ErrIsFatal() bool    { return h.errIsFatal }
+func (h *WebhookHook) Filesystems() Filter { return h.filesystems }
+func (h *WebhookHook) String() string      { return fmt.Sprintf("webhook %s", h.url) }
+
+type WebhookHookReport struct {
+	URL        string
+	Attempts   int
+	StatusCode int // of the last attempt, 0 if no response was received
+	Err        error
+}
+
+func (r *WebhookHookReport) HadError() bool { return r.Err != nil }
+func (r *WebhookHookReport) TimedOut() bool { return isTimeout(r.Err) }
+func (r *WebhookHookReport) Error() string  { return r.String() }
+func (r *WebhookHookReport) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("webhook %s failed after %d attempt(s): %s", r.URL, r.Attempts, r.Err)
+	}
+	if r.Attempts == 0 {
+		return fmt.Sprintf("webhook %s not sent (dry run)", r.URL)
+	}
+	return fmt.Sprintf("webhook %s: HTTP %d", r.URL, r.StatusCode)
+}
+
+func (h *WebhookHook) renderPayload(env Env) ([]byte, error) {
+	if h.payload == nil {
+		return json.Marshal(hookContext(env))
+	}
+	var fss []string
+	if env[EnvFilesystems] != "" {
+		fss = strings.Split(env[EnvFilesystems], "\n")
+	}
+	data := webhookTemplateData{
+		HookType:    env[EnvType],
+		Job:         env[EnvJob],
+		Filesystem:  env[EnvFS],
+		Snapshot:    env[EnvSnapshot],
+		DryRun:      env[EnvDryRun] != "",
+		Filesystems: fss,
+		Error:       env[EnvError],
+		Env:         env,
+	}
+	var buf bytes.Buffer
+	if err := h.payload.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("payload is not valid JSON: %q", buf.String())
+	}
+	return buf.Bytes(), nil
+}
+
+func (h *WebhookHook) Run(ctx context.Context, edge Edge, phase Phase, dryRun bool, extra Env, state map[interface{}]interface{}) HookReport {
+	l := getLogger(ctx).WithField("url", h.url)
+	report := &WebhookHookReport{URL: h.url}
+
+	env := NewHookEnv(edge, phase, dryRun, h.timeout, extra)
+	body, err := h.renderPayload(env)
+	if err != nil {
+		report.Err = errors.Wrap(err, "cannot render payload")
+		return report
+	}
+	if dryRun {
+		l.WithField("payload", string(body)).Info("dry run, not sending webhook")
+		return report
+	}
+
+	backoff := h.retryInterval
+	for attempt := 0; attempt <= h.retries; attempt++ {
+		if attempt > 0 {
+			l.WithError(report.Err).WithField("attempt", attempt+1).Warn("retrying webhook")
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				report.Err = ctx.Err()
+				return report
+			}
+			backoff *= 2
+		}
+		report.Attempts++
+		var retry bool
+		report.StatusCode, retry, report.Err = h.post(ctx, body)
+		if report.Err == nil || !retry {
+			break
+		}
+	}
+	return report
+}
+
+// retry is true for errors that may be temporary, i.e., network errors, 5xx and 429 Too Many Requests.
+func (h *WebhookHook) post(ctx context.Context, body []byte) (statusCode int, retry bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range h.headers {
+		req.Header.Set(k, v)
+	}
+	if h.hmacSecret != nil {
+		mac := hmac.New(sha256.New, h.hmacSecret)
+		mac.Write(body)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, true, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(res.Body, 1<<16))
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return res.StatusCode, false, nil
+	}
+	retry = res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests
+	return res.StatusCode, retry, fmt.Errorf("unexpected HTTP status %q", res.Status)
+}
@@ -0,0 +1,188 @@
+package hooks_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+)
+
+type webhookTestServer struct {
+	*httptest.Server
+	mtx      sync.Mutex
+	statuses []int // responses to the next requests, 200 after that
+	bodies   [][]byte
+	headers  []http.Header
+}
+
+func newWebhookTestServer(statuses ...int) *webhookTestServer {
+	s := &webhookTestServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		s.bodies = append(s.bodies, body)
+		s.headers = append(s.headers, r.Header)
+		status := http.StatusOK
+		if len(s.statuses) > 0 {
+			status, s.statuses = s.statuses[0], s.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	return s
+}
+
+func webhookTestConfig(url string) *config.HookWebhook {
+	retries := 2
+	return &config.HookWebhook{
+		HookSettingsCommon: config.HookSettingsCommon{
+			Retries:       &retries,
+			RetryInterval: time.Millisecond,
+		},
+		URL:         url,
+		Timeout:     5 * time.Second,
+		Filesystems: config.FilesystemsFilter{"<": true},
+	}
+}
+
+var webhookTestEnv = hooks.Env{
+	hooks.EnvFS:          "pool/fs",
+	hooks.EnvSnapshot:    "zrepl_testsnap",
+	hooks.EnvJob:         "testjob",
+	hooks.EnvFilesystems: "pool/fs\npool/other",
+}
+
+func TestWebhook(t *testing.T) {
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+
+	t.Run("default_payload_and_signature", func(t *testing.T) {
+		s := newWebhookTestServer()
+		defer s.Close()
+
+		secretFile, err := ioutil.TempFile("", "zrepl-webhook-secret")
+		require.NoError(t, err)
+		defer os.Remove(secretFile.Name())
+		_, err = secretFile.WriteString("s3cr3t\n")
+		require.NoError(t, err)
+		require.NoError(t, secretFile.Close())
+		c := webhookTestConfig(s.URL)
+		c.HMACSecretFile = config.Secret{File: secretFile.Name()}
+		c.Headers = map[string]string{"Authorization": "Bearer token"}
+		h, err := hooks.WebhookFromConfig(c)
+		require.NoError(t, err)
+
+		r := h.Run(ctx, hooks.Pre, hooks.PhaseSnapshot, false, webhookTestEnv, nil)
+		require.False(t, r.HadError(), "%s", r)
+
+		require.Len(t, s.bodies, 1)
+		var payload map[string]interface{}
+		require.NoError(t, json.Unmarshal(s.bodies[0], &payload))
+		assert.Equal(t, "pre_snapshot", payload["ZREPL_HOOKTYPE"])
+		assert.Equal(t, "pool/fs", payload["ZREPL_FS"])
+		assert.Equal(t, false, payload["ZREPL_DRYRUN"])
+		assert.Equal(t, []interface{}{"pool/fs", "pool/other"}, payload["ZREPL_FILESYSTEMS"])
+
+		mac := hmac.New(sha256.New, []byte("s3cr3t"))
+		mac.Write(s.bodies[0])
+		assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), s.headers[0].Get(hooks.WebhookSignatureHeader))
+		assert.Equal(t, "Bearer token", s.headers[0].Get("Authorization"))
+		assert.Equal(t, "application/json", s.headers[0].Get("Content-Type"))
+	})
+
+	t.Run("templated_payload", func(t *testing.T) {
+		s := newWebhookTestServer()
+		defer s.Close()
+		c := webhookTestConfig(s.URL)
+		c.Payload = `{"text": {{ json (printf "%s: %s@%s" .HookType .Filesystem .Snapshot) }}, "job": {{ json .Job }}, "count": {{ len .Filesystems }}}`
+		h, err := hooks.WebhookFromConfig(c)
+		require.NoError(t, err)
+
+		r := h.Run(ctx, hooks.Post, hooks.PhaseSnapshot, false, webhookTestEnv, nil)
+		require.False(t, r.HadError(), "%s", r)
+		require.Len(t, s.bodies, 1)
+		assert.JSONEq(t, `{"text": "post_snapshot: pool/fs@zrepl_testsnap", "job": "testjob", "count": 2}`, string(s.bodies[0]))
+	})
+
+	t.Run("retries_on_server_error", func(t *testing.T) {
+		s := newWebhookTestServer(http.StatusServiceUnavailable, http.StatusInternalServerError)
+		defer s.Close()
+		h, err := hooks.WebhookFromConfig(webhookTestConfig(s.URL))
+		require.NoError(t, err)
+
+		r := h.Run(ctx, hooks.Pre, hooks.PhaseSnapshot, false, webhookTestEnv, nil)
+		require.False(t, r.HadError(), "%s", r)
+		assert.Equal(t, 3, r.(*hooks.WebhookHookReport).Attempts)
+	})
+
+	t.Run("retries_exhausted", func(t *testing.T) {
+		s := newWebhookTestServer(500, 500, 500, 500)
+		defer s.Close()
+		h, err := hooks.WebhookFromConfig(webhookTestConfig(s.URL))
+		require.NoError(t, err)
+
+		r := h.Run(ctx, hooks.Pre, hooks.PhaseSnapshot, false, webhookTestEnv, nil)
+		require.True(t, r.HadError())
+		assert.Equal(t, 3, r.(*hooks.WebhookHookReport).Attempts)
+		assert.Len(t, s.bodies, 3)
+	})
+
+	t.Run("no_retry_on_client_error", func(t *testing.T) {
+		s := newWebhookTestServer(http.StatusBadRequest)
+		defer s.Close()
+		h, err := hooks.WebhookFromConfig(webhookTestConfig(s.URL))
+		require.NoError(t, err)
+
+		r := h.Run(ctx, hooks.Pre, hooks.PhaseSnapshot, false, webhookTestEnv, nil)
+		require.True(t, r.HadError())
+		assert.Equal(t, 1, r.(*hooks.WebhookHookReport).Attempts)
+		assert.Equal(t, http.StatusBadRequest, r.(*hooks.WebhookHookReport).StatusCode)
+	})
+
+	t.Run("dry_run", func(t *testing.T) {
+		s := newWebhookTestServer()
+		defer s.Close()
+		h, err := hooks.WebhookFromConfig(webhookTestConfig(s.URL))
+		require.NoError(t, err)
+
+		r := h.Run(ctx, hooks.Pre, hooks.PhaseSnapshot, true, webhookTestEnv, nil)
+		require.False(t, r.HadError(), "%s", r)
+		assert.Empty(t, s.bodies)
+	})
+
+	t.Run("invalid_config", func(t *testing.T) {
+		for name, modify := range map[string]func(c *config.HookWebhook){
+			"url_scheme":       func(c *config.HookWebhook) { c.URL = "ftp://example.com" },
+			"negative_retries": func(c *config.HookWebhook) { r := -1; c.Retries = &r },
+			"template_syntax":  func(c *config.HookWebhook) { c.Payload = `{{ .Filesystem ` },
+			"template_field":   func(c *config.HookWebhook) { c.Payload = `{{ .NoSuchField }}` },
+			"not_json":         func(c *config.HookWebhook) { c.Payload = `fs={{ .Filesystem }}` },
+			"secret_missing": func(c *config.HookWebhook) {
+				c.HMACSecretFile = config.Secret{File: "/nonexistent/zrepl-webhook-secret"}
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				c := webhookTestConfig("http://localhost:1")
+				modify(c)
+				_, err := hooks.WebhookFromConfig(c)
+				t.Logf("error: %s", err)
+				assert.Error(t, err)
+			})
+		}
+	})
+}
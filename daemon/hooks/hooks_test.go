@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"testing"
 	"text/template"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -39,6 +40,7 @@ type testCase struct {
 	ExpectCallbackSkipped bool
 	ExpectHadFatalErr     bool
 	ExpectHadError        bool
+	ExpectAbortJob        bool
 	ExpectStepReports     []expectStep
 }
 
@@ -151,6 +153,21 @@ jobs:
 			},
 		},
 
+		testCase{
+			Name:   "check_context_file",
+			Config: []string{`{type: command, path: {{.WorkDir}}/test/test-report-context-file.sh, context_file: true}`},
+			ExpectStepReports: []expectStep{
+				expectStep{
+					ExpectedEdge: hooks.Pre,
+					ExpectStatus: hooks.StepOk,
+					OutputTest: regexpTest(fmt.Sprintf(`(?s)^TEST pre_testing TestHooks\n.*"ZREPL_DRYRUN":false,"ZREPL_FILESYSTEMS":\["%s"\],"ZREPL_FS":"%s","ZREPL_HOOKTYPE":"pre_testing","ZREPL_JOB":"TestHooks","ZREPL_SNAPNAME":"%s"`,
+						testFSName, testFSName, testSnapshotName)),
+				},
+				expectStep{ExpectedEdge: hooks.Callback, ExpectStatus: hooks.StepOk},
+				expectStep{ExpectedEdge: hooks.Post, ExpectStatus: hooks.StepOk},
+			},
+		},
+
 		testCase{
 			Name:                  "nonfatal_pre_error_continues",
 			ExpectCallbackSkipped: false,
@@ -310,6 +327,118 @@ jobs:
 			},
 		},
 
+		testCase{
+			Name:                  "json_protocol_skip",
+			ExpectCallbackSkipped: true,
+			Config: []string{
+				`{type: command, path: {{.WorkDir}}/test/test-report-env.sh}`,
+				`{type: command, path: {{.WorkDir}}/test/test-json-skip.sh, protocol: json}`,
+				`{type: command, path: {{.WorkDir}}/test/test-report-env.sh}`,
+			},
+			ExpectStepReports: []expectStep{
+				expectStep{
+					ExpectedEdge: hooks.Pre,
+					ExpectStatus: hooks.StepOk,
+					OutputTest:   containsTest(fmt.Sprintf("TEST pre_testing %s@%s", testFSName, testSnapshotName)),
+				},
+				expectStep{
+					ExpectedEdge: hooks.Pre,
+					ExpectStatus: hooks.StepOk,
+					// stdout and stderr may be interleaved in any order
+					OutputTest: regexpTest(fmt.Sprintf(`\{"version":1,"hook_type":"pre_testing","edge":"pre","phase":"testing","job":"TestHooks","filesystem":"%s","snapshot":"%s",`,
+						testFSName, testSnapshotName)),
+				},
+				expectStep{ExpectedEdge: hooks.Pre, ExpectStatus: hooks.StepSkippedByHook},
+				expectStep{ExpectedEdge: hooks.Callback, ExpectStatus: hooks.StepSkippedByHook},
+				expectStep{ExpectedEdge: hooks.Post, ExpectStatus: hooks.StepSkippedByHook},
+				expectStep{
+					ExpectedEdge: hooks.Post,
+					ExpectStatus: hooks.StepOk,
+					OutputTest:   containsTest(`"edge":"post"`),
+				},
+				expectStep{
+					ExpectedEdge: hooks.Post,
+					ExpectStatus: hooks.StepOk,
+					OutputTest:   containsTest(fmt.Sprintf("TEST post_testing %s@%s", testFSName, testSnapshotName)),
+				},
+			},
+		},
+
+		testCase{
+			Name:                  "json_protocol_abort_is_fatal",
+			ExpectCallbackSkipped: true,
+			ExpectHadError:        true,
+			ExpectHadFatalErr:     true,
+			Config: []string{
+				`{type: command, path: {{.WorkDir}}/test/test-json-abort.sh, protocol: json}`,
+			},
+			ExpectStepReports: []expectStep{
+				expectStep{
+					ExpectedEdge: hooks.Pre,
+					ExpectStatus: hooks.StepErr,
+					ErrorTest:    regexpTest("hook requested abort: TEST abort$"),
+				},
+				expectStep{ExpectedEdge: hooks.Callback, ExpectStatus: hooks.StepSkippedDueToFatalErr},
+				expectStep{ExpectedEdge: hooks.Post, ExpectStatus: hooks.StepSkippedDueToFatalErr},
+			},
+		},
+
+		testCase{
+			Name:           "json_protocol_invalid_result",
+			ExpectHadError: true,
+			Config: []string{
+				`{type: command, path: {{.WorkDir}}/test/test-json-invalid.sh, protocol: json}`,
+			},
+			ExpectStepReports: []expectStep{
+				expectStep{
+					ExpectedEdge: hooks.Pre,
+					ExpectStatus: hooks.StepErr,
+					OutputTest:   containsTest("TEST not json"),
+					ErrorTest:    regexpTest("stdout is not a valid hook result"),
+				},
+				expectStep{ExpectedEdge: hooks.Callback, ExpectStatus: hooks.StepOk},
+				expectStep{ExpectedEdge: hooks.Post, ExpectStatus: hooks.StepSkippedDueToPreErr},
+			},
+		},
+
+		testCase{
+			Name:   "retries",
+			Config: []string{`{type: command, path: {{.WorkDir}}/test/test-fail-once.sh, err_is_fatal: true, retries: 1, retry_interval: 10ms}`},
+			ExpectStepReports: []expectStep{
+				expectStep{
+					ExpectedEdge: hooks.Pre,
+					ExpectStatus: hooks.StepOk,
+					OutputTest:   containsTest("TEST pre_testing second attempt"),
+				},
+				expectStep{ExpectedEdge: hooks.Callback, ExpectStatus: hooks.StepOk},
+				expectStep{
+					ExpectedEdge: hooks.Post,
+					ExpectStatus: hooks.StepOk,
+					OutputTest:   containsTest("TEST post_testing second attempt"),
+				},
+			},
+		},
+
+		testCase{
+			Name:                  "on_error_abort_job",
+			ExpectCallbackSkipped: true,
+			ExpectHadError:        true,
+			ExpectHadFatalErr:     true,
+			ExpectAbortJob:        true,
+			Config: []string{
+				`{type: command, path: {{.WorkDir}}/test/test-error.sh, on_error: abort_job}`,
+			},
+			ExpectStepReports: []expectStep{
+				expectStep{
+					ExpectedEdge: hooks.Pre,
+					ExpectStatus: hooks.StepErr,
+					ErrorTest:    regexpTest("^command hook failed.*exit status 1$"),
+				},
+				expectStep{ExpectedEdge: hooks.Callback, ExpectStatus: hooks.StepSkippedDueToFatalErr},
+				expectStep{ExpectedEdge: hooks.Post, ExpectStatus: hooks.StepSkippedDueToFatalErr},
+			},
+		},
+
 		testCase{
 			Name:              "exceed_buffer_limit",
 			SuppressOutput:    true,
@@ -401,8 +530,10 @@ jobs:
 	})
 
 	hookEnvExtra := hooks.Env{
-		hooks.EnvFS:       fs.ToString(),
-		hooks.EnvSnapshot: testSnapshotName,
+		hooks.EnvFS:          fs.ToString(),
+		hooks.EnvSnapshot:    testSnapshotName,
+		hooks.EnvJob:         "TestHooks",
+		hooks.EnvFilesystems: fs.ToString(),
 	}
 
 	for _, tt := range testTable {
@@ -450,6 +581,7 @@ jobs:
 			// Check if a fatal run error occurred and was expected
 			require.Equal(t, tt.ExpectHadFatalErr, report.HadFatalError(), "non-matching HadFatalError")
 			require.Equal(t, tt.ExpectHadError, report.HadError(), "non-matching HadError")
+			require.Equal(t, tt.ExpectAbortJob, report.AbortJob(), "non-matching AbortJob")
 
 			if tt.ExpectHadFatalErr {
 				require.True(t, tt.ExpectHadError, "ExpectHadFatalErr implies ExpectHadError")
@@ -487,3 +619,64 @@ jobs:
 		})
 	}
 }
+
+func TestCommandHookJSONProtocolAnnotations(t *testing.T) {
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	var hookList hooks.List
+	for i := 0; i < 2; i++ {
+		h, err := hooks.NewCommandHook(&config.HookCommand{
+			Path:     cwd + "/test/test-json-annotate.sh",
+			Timeout:  10 * time.Second,
+			Protocol: "json",
+		})
+		require.NoError(t, err)
+		hookList = append(hookList, h)
+	}
+
+	fs, err := zfs.NewDatasetPath("testpool/testdataset")
+	require.NoError(t, err)
+	var annotations map[string]string
+	cb := hooks.NewCallbackHookForFilesystem("testcallback", fs, func(ctx context.Context) error {
+		annotations = hooks.AnnotationsFromContext(ctx)
+		return nil
+	})
+	plan, err := hooks.NewPlan(&hookList, hooks.PhaseTesting, cb, hooks.Env{hooks.EnvFS: fs.ToString()})
+	require.NoError(t, err)
+	plan.Run(ctx, false)
+	report := plan.Report()
+	t.Logf("report:\n%s", report)
+
+	require.False(t, report.HadError())
+	require.Equal(t, map[string]string{"com.example:hooktype": "pre_testing"}, annotations)
+}
+
+func TestHookPolicyFromConfig(t *testing.T) {
+	negative := -1
+	for name, common := range map[string]config.HookSettingsCommon{
+		"invalid_on_error":   {OnError: "panic"},
+		"contradicting":      {OnError: "warn", ErrIsFatal: true},
+		"negative_retries":   {Retries: &negative},
+		"abort_job_is_valid": {OnError: "abort_job"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			h, err := hooks.HookFromConfig(config.HookEnum{Ret: &config.HookCommand{
+				Path:               "/bin/true",
+				Timeout:            time.Second,
+				Filesystems:        config.FilesystemsFilter{"<": true},
+				Protocol:           "env",
+				HookSettingsCommon: common,
+			}})
+			if name == "abort_job_is_valid" {
+				require.NoError(t, err)
+				require.True(t, h.ErrIsFatal())
+				return
+			}
+			t.Logf("error: %s", err)
+			require.Error(t, err)
+		})
+	}
+}
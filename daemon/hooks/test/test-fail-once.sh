@@ -0,0 +1,11 @@
+#!/bin/sh -eu
+
+# fails on the first invocation per edge, succeeds on the second
+marker="${TMPDIR:-/tmp}/zrepl-hook-test-fail-once-$PPID-$ZREPL_HOOKTYPE"
+if [ ! -e "$marker" ]; then
+    touch "$marker"
+    echo "TEST ERROR first attempt $ZREPL_HOOKTYPE"
+    exit 1
+fi
+rm "$marker"
+echo "TEST $ZREPL_HOOKTYPE second attempt"
@@ -0,0 +1,6 @@
+#!/bin/sh -eu
+
+cat >&2
+case "$ZREPL_HOOKTYPE" in
+pre_*) echo '{"action": "abort", "message": "TEST abort"}' ;;
+esac
@@ -0,0 +1,6 @@
+#!/bin/sh -eu
+
+event="$(cat)"
+case "$event" in
+*'"edge":"pre"'*) echo "{\"annotations\": {\"com.example:hooktype\": \"$ZREPL_HOOKTYPE\"}}" ;;
+esac
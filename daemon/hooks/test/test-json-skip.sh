@@ -0,0 +1,7 @@
+#!/bin/sh -eu
+
+# the event is echoed to stderr, stdout is reserved for the result
+cat >&2
+case "$ZREPL_HOOKTYPE" in
+pre_*) echo '{"action": "skip", "message": "TEST skip"}' ;;
+esac
@@ -0,0 +1,4 @@
+#!/bin/sh -eu
+
+echo "TEST $ZREPL_HOOKTYPE $ZREPL_JOB"
+cat "$ZREPL_HOOK_CONTEXT_FILE"
@@ -0,0 +1,37 @@
+package abort
+
+import (
+	"context"
+	"errors"
+)
+
+type contextKey int
+
+const contextKeyAbort contextKey = iota
+
+// Wait returns a channel that receives when the current invocation shall be aborted.
+// Only running invocations wait on it.
+func Wait(ctx context.Context) <-chan struct{} {
+	wc, ok := ctx.Value(contextKeyAbort).(chan struct{})
+	if !ok {
+		wc = make(chan struct{})
+	}
+	return wc
+}
+
+type Func func() error
+
+var NotRunning = errors.New("job has no running invocation")
+
+func Context(ctx context.Context) (context.Context, Func) {
+	wc := make(chan struct{})
+	af := func() error {
+		select {
+		case wc <- struct{}{}:
+			return nil
+		default:
+			return NotRunning
+		}
+	}
+	return context.WithValue(ctx, contextKeyAbort, wc), af
+}
@@ -3,6 +3,7 @@ package job
 import (
 	"context"
 	"fmt"
+	"os"
 	"sync"
 	"time"
 
@@ -14,7 +15,14 @@ import (
 	"github.com/zrepl/zrepl/util/envconst"
 
 	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/job/abort"
+	"github.com/zrepl/zrepl/daemon/job/disable"
+	"github.com/zrepl/zrepl/daemon/job/maintenance"
+	"github.com/zrepl/zrepl/daemon/job/ready"
 	"github.com/zrepl/zrepl/daemon/job/reset"
+	"github.com/zrepl/zrepl/daemon/job/stop"
 	"github.com/zrepl/zrepl/daemon/job/wakeup"
 	"github.com/zrepl/zrepl/daemon/pruner"
 	"github.com/zrepl/zrepl/daemon/snapper"
@@ -24,9 +32,11 @@ import (
 	"github.com/zrepl/zrepl/replication/logic"
 	"github.com/zrepl/zrepl/replication/report"
 	"github.com/zrepl/zrepl/rpc"
+	"github.com/zrepl/zrepl/rpc/versionhandshake"
 	"github.com/zrepl/zrepl/transport"
 	"github.com/zrepl/zrepl/transport/fromconfig"
 	"github.com/zrepl/zrepl/zfs"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
 )
 
 type ActiveSide struct {
@@ -38,14 +48,43 @@ type ActiveSide struct {
 
 	prunerFactory *pruner.PrunerFactory
 
+	blackout *blackout // nil if no blackout windows are configured
+	// nil if no maintenance windows are configured
+	maintenanceWindows *blackout
+
+	breaker *circuitBreaker // nil if not configured
+
+	pruneSchedule pruneSchedule // nil if pruning follows replication
+
+	hooks *hooks.EventHooks
+
+	processLimits *zfscmd.ProcessLimits // nil if not configured
+
+	deps *jobDependencies
+
 	promRepStateSecs      *prometheus.HistogramVec // labels: state
+	promRepStepSecs       *prometheus.HistogramVec // labels: step_type
 	promPruneSecs         *prometheus.HistogramVec // labels: prune_side
+	promPhaseSecs         *prometheus.HistogramVec // labels: phase
+	promSnapshots         *pruner.SnapshotMetrics  // labels: prune_side, peer, filesystem
 	promBytesReplicated   *prometheus.CounterVec   // labels: filesystem
+	promBytesResumed      *prometheus.CounterVec   // labels: filesystem
+	promReconnects        *prometheus.CounterVec   // labels: peer, outcome
 	promReplicationErrors prometheus.Gauge
 	promLastSuccessful    prometheus.Gauge
+	promTransfer          *transferMetrics
+	// labels: filesystem, peer; nil if per-filesystem metrics are disabled
+	promFilesystemLastSuccessful *prometheus.GaugeVec
 
 	tasksMtx sync.Mutex
 	tasks    activeSideTasks
+	// protected by tasksMtx, zero if no replication succeeded since the job was started
+	lastSuccessfulReplication time.Time
+
+	// of the replication with the peer in `connect`
+	fsErrors filesystemErrorHistory
+
+	invocations invocationHistory
 }
 
 //go:generate enumer -type=ActiveSideState
@@ -70,6 +109,12 @@ type activeSideTasks struct {
 
 	// valid for state ActiveSidePruneReceiver, ActiveSideDone
 	prunerSenderCancel, prunerReceiverCancel context.CancelFunc
+
+	// non-zero while an invocation is waiting for a blackout window to end
+	blackoutWaitUntil time.Time
+
+	// non-zero if the latest invocation was aborted by `zrepl signal abort`
+	abortedAt time.Time
 }
 
 func (a *ActiveSide) updateTasks(u func(*activeSideTasks)) activeSideTasks {
@@ -102,6 +147,7 @@ type modePush struct {
 	senderConfig  *endpoint.SenderConfig
 	plannerPolicy *logic.PlannerPolicy
 	snapper       snapper.Snapper
+	destinations  []*pushDestination
 }
 
 func (m *modePush) ConnectEndpoints(ctx context.Context, connecter transport.Connecter) {
@@ -111,7 +157,10 @@ func (m *modePush) ConnectEndpoints(ctx context.Context, connecter transport.Con
 		panic("inconsistent use of ConnectEndpoints and DisconnectEndpoints")
 	}
 	m.sender = endpoint.NewSender(*m.senderConfig)
-	m.receiver = rpc.NewClient(connecter, rpc.GetLoggersOrPanic(ctx))
+	m.receiver = rpc.NewClient(connecter, rpc.GetLoggersOrPanic(ctx), versionhandshake.PeerKey{Job: m.senderConfig.JobID.String()})
+	for _, d := range m.destinations {
+		d.ConnectEndpoints(ctx)
+	}
 }
 
 func (m *modePush) DisconnectEndpoints() {
@@ -120,6 +169,9 @@ func (m *modePush) DisconnectEndpoints() {
 	m.receiver.Close()
 	m.sender = nil
 	m.receiver = nil
+	for _, d := range m.destinations {
+		d.DisconnectEndpoints()
+	}
 }
 
 func (m *modePush) SenderReceiver() (logic.Sender, logic.Receiver) {
@@ -147,13 +199,16 @@ func (m *modePush) ResetConnectBackoff() {
 	if m.receiver != nil {
 		m.receiver.ResetConnectBackoff()
 	}
+	for _, d := range m.destinations {
+		d.ResetConnectBackoff()
+	}
 }
 
-func modePushFromConfig(g *config.Global, in *config.PushJob, jobID endpoint.JobID) (*modePush, error) {
+func modePushFromConfig(g *config.Global, in *config.PushJob, jobID endpoint.JobID, parseFlags config.ParseFlags) (*modePush, error) {
 	m := &modePush{}
 	var err error
 
-	m.senderConfig, err = buildSenderConfig(in, jobID)
+	m.senderConfig, err = buildSenderConfig(g, in, jobID)
 	if err != nil {
 		return nil, errors.Wrap(err, "sender config")
 	}
@@ -172,15 +227,21 @@ func modePushFromConfig(g *config.Global, in *config.PushJob, jobID endpoint.Job
 		ConflictResolution:        conflictResolution,
 		ReplicationConfig:         replicationConfig,
 		SizeEstimationConcurrency: in.Replication.Concurrency.SizeEstimates,
+		SkipIntermediateSnapshots: in.Replication.Incremental.SkipIntermediates,
+		PerFilesystemMetrics:      perFilesystemMetricsFromConfig(g),
 	}
 	if err := m.plannerPolicy.Validate(); err != nil {
 		return nil, errors.Wrap(err, "cannot build planner policy")
 	}
 
-	if m.snapper, err = snapper.FromConfig(g, m.senderConfig.FSF, in.Snapshotting); err != nil {
+	if m.snapper, err = snapper.FromConfig(g, jobID.String(), m.senderConfig.FSF, in.Snapshotting); err != nil {
 		return nil, errors.Wrap(err, "cannot build snapper")
 	}
 
+	if m.destinations, err = pushDestinationsFromConfig(g, in.Destinations, m.senderConfig, parseFlags); err != nil {
+		return nil, errors.Wrap(err, "field `destinations`")
+	}
+
 	return m, nil
 }
 
@@ -191,6 +252,8 @@ type modePull struct {
 	sender         *rpc.Client
 	plannerPolicy  *logic.PlannerPolicy
 	interval       config.PositiveDurationOrManual
+	sources        []*pullSource
+	schedule       pullSchedule
 }
 
 func (m *modePull) ConnectEndpoints(ctx context.Context, connecter transport.Connecter) {
@@ -200,7 +263,10 @@ func (m *modePull) ConnectEndpoints(ctx context.Context, connecter transport.Con
 		panic("inconsistent use of ConnectEndpoints and DisconnectEndpoints")
 	}
 	m.receiver = endpoint.NewReceiver(m.receiverConfig)
-	m.sender = rpc.NewClient(connecter, rpc.GetLoggersOrPanic(ctx))
+	m.sender = rpc.NewClient(connecter, rpc.GetLoggersOrPanic(ctx), versionhandshake.PeerKey{Job: m.receiverConfig.JobID.String()})
+	for _, s := range m.sources {
+		s.ConnectEndpoints(ctx)
+	}
 }
 
 func (m *modePull) DisconnectEndpoints() {
@@ -209,6 +275,9 @@ func (m *modePull) DisconnectEndpoints() {
 	m.sender.Close()
 	m.sender = nil
 	m.receiver = nil
+	for _, s := range m.sources {
+		s.DisconnectEndpoints()
+	}
 }
 
 func (m *modePull) SenderReceiver() (logic.Sender, logic.Receiver) {
@@ -222,28 +291,36 @@ func (*modePull) Type() Type { return TypePull }
 func (m *modePull) PlannerPolicy() logic.PlannerPolicy { return *m.plannerPolicy }
 
 func (m *modePull) RunPeriodic(ctx context.Context, wakeUpCommon chan<- struct{}) {
-	if m.interval.Manual {
+	// sources with the same interval share a ticker
+	var intervals []time.Duration
+	due := make(map[time.Duration][]string)
+	addSource := func(name string, interval config.PositiveDurationOrManual) {
+		if interval.Manual {
+			return
+		}
+		if _, ok := due[interval.Interval]; !ok {
+			intervals = append(intervals, interval.Interval)
+		}
+		due[interval.Interval] = append(due[interval.Interval], name)
+	}
+	addSource("", m.interval)
+	for _, s := range m.sources {
+		addSource(s.name, s.interval)
+	}
+	if len(intervals) == 0 {
 		GetLogger(ctx).Info("manual pull configured, periodic pull disabled")
 		// "waiting for wakeups" is printed in common ActiveSide.do
 		return
 	}
-	t := time.NewTicker(m.interval.Interval)
-	defer t.Stop()
-	for {
-		select {
-		case <-t.C:
-			select {
-			case wakeUpCommon <- struct{}{}:
-			default:
-				GetLogger(ctx).
-					WithField("pull_interval", m.interval).
-					Warn("pull job took longer than pull interval")
-				wakeUpCommon <- struct{}{} // block anyways, to queue up the wakeup
-			}
-		case <-ctx.Done():
-			return
-		}
+	var wg sync.WaitGroup
+	for _, interval := range intervals {
+		wg.Add(1)
+		go func(interval time.Duration) {
+			defer wg.Done()
+			runPullInterval(ctx, interval, &m.schedule, due[interval], wakeUpCommon)
+		}(interval)
 	}
+	wg.Wait()
 }
 
 func (m *modePull) SnapperReport() *snapper.Report {
@@ -256,9 +333,12 @@ func (m *modePull) ResetConnectBackoff() {
 	if m.sender != nil {
 		m.sender.ResetConnectBackoff()
 	}
+	for _, s := range m.sources {
+		s.ResetConnectBackoff()
+	}
 }
 
-func modePullFromConfig(g *config.Global, in *config.PullJob, jobID endpoint.JobID) (m *modePull, err error) {
+func modePullFromConfig(g *config.Global, in *config.PullJob, jobID endpoint.JobID, parseFlags config.ParseFlags) (m *modePull, err error) {
 	m = &modePull{}
 	m.interval = in.Interval
 
@@ -276,24 +356,41 @@ func modePullFromConfig(g *config.Global, in *config.PullJob, jobID endpoint.Job
 		ConflictResolution:        conflictResolution,
 		ReplicationConfig:         replicationConfig,
 		SizeEstimationConcurrency: in.Replication.Concurrency.SizeEstimates,
+		SkipIntermediateSnapshots: in.Replication.Incremental.SkipIntermediates,
+		PerFilesystemMetrics:      perFilesystemMetricsFromConfig(g),
 	}
 	if err := m.plannerPolicy.Validate(); err != nil {
 		return nil, errors.Wrap(err, "cannot build planner policy")
 	}
 
-	m.receiverConfig, err = buildReceiverConfig(in, jobID)
+	m.receiverConfig, err = buildReceiverConfig(g, in, jobID)
 	if err != nil {
 		return nil, err
 	}
 
+	if m.sources, err = pullSourcesFromConfig(g, in, jobID, parseFlags); err != nil {
+		return nil, errors.Wrap(err, "field `sources`")
+	}
+
 	return m, nil
 }
 
+// Deprecated by replication.retry.max_attempts, still takes precedence if set.
+const replicationMaxAttemptsEnvVar = "ZREPL_REPLICATION_MAX_ATTEMPTS"
+
 func replicationDriverConfigFromConfig(in *config.Replication) (c driver.Config, err error) {
 	c = driver.Config{
 		StepQueueConcurrency:     in.Concurrency.Steps,
-		MaxAttempts:              envconst.Int("ZREPL_REPLICATION_MAX_ATTEMPTS", 3),
+		MaxAttempts:              in.Retry.MaxAttempts,
 		ReconnectHardFailTimeout: envconst.Duration("ZREPL_REPLICATION_RECONNECT_HARD_FAIL_TIMEOUT", 10*time.Minute),
+		RetryInitialDelay:        in.Retry.InitialDelay,
+		RetryMaxDelay:            in.Retry.MaxDelay,
+		RetryBackoffMultiplier:   in.Retry.BackoffMultiplier,
+		RetryJitter:              in.Retry.Jitter,
+		MaxFilesystemRetries:     in.Retry.FilesystemRetries,
+	}
+	if os.Getenv(replicationMaxAttemptsEnvVar) != "" {
+		c.MaxAttempts = envconst.Int(replicationMaxAttemptsEnvVar, c.MaxAttempts)
 	}
 	err = c.Validate()
 	return c, err
@@ -309,9 +406,9 @@ func activeSide(g *config.Global, in *config.ActiveJob, configJob interface{}, p
 
 	switch v := configJob.(type) {
 	case *config.PushJob:
-		j.mode, err = modePushFromConfig(g, v, j.name) // shadow
+		j.mode, err = modePushFromConfig(g, v, j.name, parseFlags) // shadow
 	case *config.PullJob:
-		j.mode, err = modePullFromConfig(g, v, j.name) // shadow
+		j.mode, err = modePullFromConfig(g, v, j.name, parseFlags) // shadow
 	default:
 		panic(fmt.Sprintf("implementation error: unknown job type %T", v))
 	}
@@ -319,6 +416,10 @@ func activeSide(g *config.Global, in *config.ActiveJob, configJob interface{}, p
 		return nil, err // no wrapping required
 	}
 
+	if j.processLimits, err = ProcessLimitsFromConfig(in.ProcessLimits); err != nil {
+		return nil, errors.Wrap(err, "invalid `process_limits`")
+	}
+
 	j.promRepStateSecs = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Namespace:   "zrepl",
 		Subsystem:   "replication",
@@ -326,6 +427,15 @@ func activeSide(g *config.Global, in *config.ActiveJob, configJob interface{}, p
 		Help:        "seconds spent during replication",
 		ConstLabels: prometheus.Labels{"zrepl_job": j.name.String()},
 	}, []string{"state"})
+	j.promRepStepSecs = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   "zrepl",
+		Subsystem:   "replication",
+		Name:        "step_time",
+		Help:        "seconds spent in successful replication steps",
+		ConstLabels: prometheus.Labels{"zrepl_job": j.name.String()},
+		// replication steps take anywhere from seconds to days
+		Buckets: prometheus.ExponentialBuckets(1, 4, 10),
+	}, []string{"step_type"})
 	j.promBytesReplicated = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Namespace:   "zrepl",
 		Subsystem:   "replication",
@@ -333,6 +443,20 @@ func activeSide(g *config.Global, in *config.ActiveJob, configJob interface{}, p
 		Help:        "number of bytes replicated from sender to receiver per filesystem",
 		ConstLabels: prometheus.Labels{"zrepl_job": j.name.String()},
 	}, []string{"filesystem"})
+	j.promBytesResumed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "zrepl",
+		Subsystem:   "replication",
+		Name:        "bytes_resumed",
+		Help:        "number of bytes that resumed replication steps had already transferred before their interruption, per filesystem",
+		ConstLabels: prometheus.Labels{"zrepl_job": j.name.String()},
+	}, []string{"filesystem"})
+	j.promReconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "zrepl",
+		Subsystem:   "replication",
+		Name:        "reconnects",
+		Help:        "number of reconnects to the peer after connectivity-related replication errors",
+		ConstLabels: prometheus.Labels{"zrepl_job": j.name.String()},
+	}, []string{"peer", "outcome"})
 	j.promReplicationErrors = prometheus.NewGauge(prometheus.GaugeOpts{
 		Namespace:   "zrepl",
 		Subsystem:   "replication",
@@ -347,6 +471,16 @@ func activeSide(g *config.Global, in *config.ActiveJob, configJob interface{}, p
 		Help:        "timestamp of last successful replication",
 		ConstLabels: prometheus.Labels{"zrepl_job": j.name.String()},
 	})
+	j.promTransfer = newTransferMetrics(j.name.String(), j.mode.Type())
+	if perFilesystemMetricsFromConfig(g) {
+		j.promFilesystemLastSuccessful = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   "zrepl",
+			Subsystem:   "replication",
+			Name:        "filesystem_last_successful",
+			Help:        "timestamp of the last replication of the filesystem without error, per peer (empty for the peer in connect, else the name of the destination or source)",
+			ConstLabels: prometheus.Labels{"zrepl_job": j.name.String()},
+		}, []string{"filesystem", "peer"})
+	}
 
 	j.connecter, err = fromconfig.ConnecterFromConfig(g, in.Connect, parseFlags)
 	if err != nil {
@@ -360,25 +494,81 @@ func activeSide(g *config.Global, in *config.ActiveJob, configJob interface{}, p
 		Help:        "seconds spent in pruner",
 		ConstLabels: prometheus.Labels{"zrepl_job": j.name.String()},
 	}, []string{"prune_side"})
-	j.prunerFactory, err = pruner.NewPrunerFactory(in.Pruning, j.promPruneSecs)
+	j.promPhaseSecs = newPhaseSecs(j.name.String())
+	j.promSnapshots = pruner.NewSnapshotMetrics(j.name.String(), perFilesystemMetricsFromConfig(g))
+	j.prunerFactory, err = pruner.NewPrunerFactory(in.Pruning, j.promPruneSecs, j.promSnapshots)
 	if err != nil {
 		return nil, err
 	}
+	// the job's receiver plus the additional destinations of a push job
+	if err := j.prunerFactory.ValidateDestinations(1 + len(j.pushDestinations())); err != nil {
+		return nil, errors.Wrap(err, "field `pruning`")
+	}
+	j.pruneSchedule, err = pruneScheduleFromConfig(in.Pruning.Schedule)
+	if err != nil {
+		return nil, errors.Wrap(err, "field `pruning.schedule`")
+	}
 
 	j.replicationDriverConfig, err = replicationDriverConfigFromConfig(in.Replication)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot build replication driver config")
 	}
 
+	j.blackout, err = blackoutFromConfig(in.Blackout)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot build blackout config")
+	}
+
+	j.maintenanceWindows, err = maintenanceWindowsFromConfig(in.Maintenance)
+	if err != nil {
+		return nil, errors.Wrap(err, "field `maintenance`")
+	}
+
+	j.breaker, err = circuitBreakerFromConfig(in.CircuitBreaker)
+	if err != nil {
+		return nil, errors.Wrap(err, "field `circuit_breaker`")
+	}
+	if j.breaker != nil {
+		j.breaker.promSuspended = prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   "zrepl",
+			Subsystem:   "replication",
+			Name:        "circuit_breaker_suspended_until",
+			Help:        "timestamp until which the circuit breaker suspends the job, 0 if it is not suspended",
+			ConstLabels: prometheus.Labels{"zrepl_job": j.name.String()},
+		})
+	}
+
+	j.hooks, err = hooks.EventHooksFromConfig(in.Hooks)
+	if err != nil {
+		return nil, errors.Wrap(err, "field `hooks`")
+	}
+
+	j.deps, err = newJobDependencies(in.After)
+	if err != nil {
+		return nil, errors.Wrap(err, "field `after`")
+	}
+
 	return j, nil
 }
 
 func (j *ActiveSide) RegisterMetrics(registerer prometheus.Registerer) {
 	registerer.MustRegister(j.promRepStateSecs)
+	registerer.MustRegister(j.promRepStepSecs)
 	registerer.MustRegister(j.promPruneSecs)
+	registerer.MustRegister(j.promPhaseSecs)
+	j.promSnapshots.Register(registerer)
 	registerer.MustRegister(j.promBytesReplicated)
+	registerer.MustRegister(j.promBytesResumed)
+	registerer.MustRegister(j.promReconnects)
 	registerer.MustRegister(j.promReplicationErrors)
 	registerer.MustRegister(j.promLastSuccessful)
+	j.promTransfer.register(registerer)
+	if j.promFilesystemLastSuccessful != nil {
+		registerer.MustRegister(j.promFilesystemLastSuccessful)
+	}
+	if j.breaker != nil {
+		registerer.MustRegister(j.breaker.promSuspended)
+	}
 }
 
 func (j *ActiveSide) Name() string { return j.name.String() }
@@ -387,12 +577,50 @@ type ActiveSideStatus struct {
 	Replication                    *report.Report
 	PruningSender, PruningReceiver *pruner.Report
 	Snapshotting                   *snapper.Report
+	// additional destinations of a push job, keyed by destination name
+	Destinations map[string]*ActiveSideDestinationStatus `json:",omitempty"`
+	// additional sources of a pull job, keyed by source name
+	Sources map[string]*ActiveSideSourceStatus `json:",omitempty"`
+	// non-zero while the next invocation is waiting for a blackout window to end
+	WaitBlackoutUntil time.Time
+	// non-zero if the latest invocation was aborted by `zrepl signal abort`
+	AbortedAt time.Time `json:",omitempty"`
+	// the jobs that trigger this job (config field `after`), the jobs it triggers,
+	// and the jobs in After that have not completed successfully since the last trigger
+	After, Dependents, WaitingFor []string `json:",omitempty"`
+	// the versions of the job's peers, see versionhandshake.Peers
+	Peers []versionhandshake.PeerReport `json:",omitempty"`
+	// nil unless the latest invocation failed and a circuit breaker is configured
+	CircuitBreaker *CircuitBreakerStatus `json:",omitempty"`
+	// end of the latest replication without failed filesystems (to the peer in `connect`),
+	// zero if there was none since the daemon started
+	LastSuccessfulReplication time.Time
+	// the most recent replication errors of each filesystem since the daemon started, oldest first,
+	// keyed by filesystem (to the peer in `connect`)
+	FilesystemErrors map[string][]*report.FilesystemError `json:",omitempty"`
+	// the invocations that finished within the last 24 hours (ZREPL_JOB_INVOCATION_HISTORY_RETENTION), oldest first
+	RecentInvocations []*InvocationSummary `json:",omitempty"`
+}
+
+type ActiveSideDestinationStatus struct {
+	Replication      *report.Report
+	PruningReceiver  *pruner.Report
+	FilesystemErrors map[string][]*report.FilesystemError `json:",omitempty"`
+}
+
+type ActiveSideSourceStatus struct {
+	Replication                    *report.Report
+	PruningSender, PruningReceiver *pruner.Report
+	FilesystemErrors               map[string][]*report.FilesystemError `json:",omitempty"`
 }
 
 func (j *ActiveSide) Status() *Status {
 	tasks := j.updateTasks(nil)
 
 	s := &ActiveSideStatus{}
+	j.tasksMtx.Lock()
+	s.LastSuccessfulReplication = j.lastSuccessfulReplication
+	j.tasksMtx.Unlock()
 	t := j.mode.Type()
 	if tasks.replicationReport != nil {
 		s.Replication = tasks.replicationReport()
@@ -404,9 +632,38 @@ func (j *ActiveSide) Status() *Status {
 		s.PruningReceiver = tasks.prunerReceiver.Report()
 	}
 	s.Snapshotting = j.mode.SnapperReport()
+	s.WaitBlackoutUntil = tasks.blackoutWaitUntil
+	s.AbortedAt = tasks.abortedAt
+	s.After, s.Dependents, s.WaitingFor = j.deps.after, j.dependentNames(), j.deps.waitingFor()
+	s.Peers = versionhandshake.JobPeers(j.Name())
+	s.CircuitBreaker = j.breaker.status()
+	s.FilesystemErrors = j.fsErrors.status()
+	s.RecentInvocations = j.invocations.status(time.Now())
+	if dsts := j.pushDestinations(); len(dsts) > 0 {
+		s.Destinations = make(map[string]*ActiveSideDestinationStatus, len(dsts))
+		for _, d := range dsts {
+			s.Destinations[d.name] = d.Status()
+		}
+	}
+	if srcs := j.pullSources(); len(srcs) > 0 {
+		s.Sources = make(map[string]*ActiveSideSourceStatus, len(srcs))
+		for _, src := range srcs {
+			s.Sources[src.name] = src.Status()
+		}
+	}
 	return &Status{Type: t, JobSpecific: s}
 }
 
+// Additional destinations of a push job. Returns nil for pull jobs.
+func (j *ActiveSide) pushDestinations() []*pushDestination {
+	push, ok := j.mode.(*modePush)
+	if !ok {
+		_ = j.mode.(*modePull) // make sure we didn't introduce a new job type
+		return nil
+	}
+	return push.destinations
+}
+
 func (j *ActiveSide) OwnedDatasetSubtreeRoot() (rfs *zfs.DatasetPath, ok bool) {
 	pull, ok := j.mode.(*modePull)
 	if !ok {
@@ -425,6 +682,15 @@ func (j *ActiveSide) SenderConfig() *endpoint.SenderConfig {
 	return push.senderConfig
 }
 
+func (j *ActiveSide) Snapper() (snapper.Snapper, zfs.DatasetFilter, bool) {
+	push, ok := j.mode.(*modePush)
+	if !ok {
+		_ = j.mode.(*modePull) // make sure we didn't introduce a new job type
+		return nil, nil, false
+	}
+	return push.snapper, push.senderConfig.FSF, true
+}
+
 // The active side of a replication uses one end (sender or receiver)
 // directly by method invocation, without going through a transport that
 // provides a client identity.
@@ -437,12 +703,23 @@ func FakeActiveSideDirectMethodInvocationClientIdentity(jobId endpoint.JobID) st
 func (j *ActiveSide) Run(ctx context.Context) {
 	ctx, endTask := trace.WithTaskAndSpan(ctx, "active-side-job", j.Name())
 	defer endTask()
+	ctx = zfscmd.WithProcessLimits(ctx, j.processLimits)
+	ctx = snapper.WithDurationObserver(ctx, j.promPhaseSecs.WithLabelValues(phaseSnapshotting))
+	if j.maintenanceWindows != nil {
+		maintenance.SetWindows(ctx, j.maintenanceWindows.windowEnd)
+	}
 
 	ctx = context.WithValue(ctx, endpoint.ClientIdentityKey, FakeActiveSideDirectMethodInvocationClientIdentity(j.name))
 
 	log := GetLogger(ctx)
 
+	if os.Getenv(replicationMaxAttemptsEnvVar) != "" {
+		log.WithField("max_attempts", j.replicationDriverConfig.MaxAttempts).
+			Warn(replicationMaxAttemptsEnvVar + " is deprecated, use `replication.retry.max_attempts` instead")
+	}
+
 	defer log.Info("job exiting")
+	ready.Signal(ctx)
 
 	periodicDone := make(chan struct{})
 	ctx, cancel := context.WithCancel(ctx)
@@ -451,27 +728,126 @@ func (j *ActiveSide) Run(ctx context.Context) {
 	defer endTask()
 	go j.mode.RunPeriodic(periodicCtx, periodicDone)
 
-	invocationCount := 0
+	var pruneDue chan struct{} // nil if pruning follows replication
+	if j.pruneSchedule != nil {
+		pruneDue = make(chan struct{})
+		pruneScheduleCtx, endPruneScheduleTask := trace.WithTask(ctx, "prune-schedule")
+		defer endPruneScheduleTask()
+		go runPruneSchedule(pruneScheduleCtx, j.pruneSchedule, pruneDue)
+	}
+
+	invocationCount, pruneCount := 0, 0
 outer:
 	for {
 		log.Info("wait for wakeups")
+		wokenUp := false
 		select {
 		case <-ctx.Done():
 			log.WithError(ctx.Err()).Info("context")
 			break outer
 
+		case <-stop.Requested(ctx):
+			log.Info("graceful stop requested")
+			break outer
+
 		case <-wakeup.Wait(ctx):
+			wokenUp = true
 			j.mode.ResetConnectBackoff()
+			j.markAllSourcesDue()
+		case <-reset.Wait(ctx):
+			if j.breaker.reset() {
+				log.Info("reset received, circuit breaker reset")
+			}
+			continue outer
 		case <-periodicDone:
+		case <-j.deps.trigger:
+			log.WithField("after", j.deps.after).Info("dependencies completed, starting invocation")
+			j.markAllSourcesDue()
+		case <-pruneDue:
+			if disable.Disabled(ctx) {
+				log.Info("job is disabled, skipping scheduled pruning")
+				continue outer
+			}
+			if m := maintenance.Get(ctx); m.Active {
+				log.WithField("until", m.Until).Info("job is in maintenance, skipping scheduled pruning")
+				continue outer
+			}
+			pruneCount++
+			pruneCtx, endSpan := trace.WithSpan(ctx, fmt.Sprintf("scheduled-pruning-%d", pruneCount))
+			j.doScheduledPrune(pruneCtx)
+			endSpan()
+			continue outer
+		}
+		if disable.Disabled(ctx) {
+			log.Info("job is disabled, skipping invocation")
+			continue outer
+		}
+		if m := maintenance.Get(ctx); m.Active {
+			log.WithField("until", m.Until).Info("job is in maintenance, skipping invocation")
+			continue outer
 		}
 		invocationCount++
-		invocationCtx, endSpan := trace.WithSpan(ctx, fmt.Sprintf("invocation-%d", invocationCount))
-		j.do(invocationCtx)
-		endSpan()
+		var filesystems zfs.DatasetFilter // nil replicates all filesystems
+		for attempt := 0; ; attempt++ {
+			if err := j.waitForCircuitBreaker(ctx, periodicDone); err != nil {
+				log.WithError(err).Info("context")
+				break outer
+			}
+			if err := j.waitForBlackoutWindowEnd(ctx, periodicDone); err != nil {
+				log.WithError(err).Info("context")
+				break outer
+			}
+			if attempt == 0 {
+				// includes the wakeups that were queued while waiting,
+				// the filesystems of wakeups only apply to invocations they triggered
+				patterns := wakeup.Filesystems(ctx)
+				if wokenUp && len(patterns) > 0 {
+					var err error
+					if filesystems, err = WakeupFilesystemsFilter(patterns); err != nil {
+						log.WithError(err).Error("invalid filesystems of wakeup, replicating all filesystems")
+						filesystems = nil
+					} else {
+						log.WithField("filesystems", patterns).Info("wakeup only replicates the matching filesystems")
+					}
+				}
+			}
+			invocationCtx, endSpan := trace.WithSpan(ctx, fmt.Sprintf("invocation-%d", invocationCount))
+			interrupted := j.do(invocationCtx, filesystems, newInvocationOutcome())
+			endSpan()
+			if !interrupted {
+				break
+			}
+			log.Info("invocation interrupted by blackout window, continuing once the window has ended")
+		}
+	}
+}
+
+// WakeupFilesystemsFilter returns the filter for the filesystem patterns of a targeted wakeup.
+// The patterns use the syntax of the `filesystems` field, e.g. `pool/data` or `pool/data<`,
+// and are matched against the names of the filesystems on the sending side.
+func WakeupFilesystemsFilter(patterns []string) (zfs.DatasetFilter, error) {
+	f := filters.NewDatasetMapFilter(len(patterns), true)
+	for _, p := range patterns {
+		if err := f.Add(p, "ok"); err != nil {
+			return nil, errors.Wrapf(err, "invalid filesystem pattern %q", p)
+		}
 	}
+	return f, nil
 }
 
-func (j *ActiveSide) do(ctx context.Context) {
+// plannerPolicy returns the job's planner policy, limited to filesystems if not nil.
+func (j *ActiveSide) plannerPolicy(filesystems zfs.DatasetFilter) logic.PlannerPolicy {
+	p := j.mode.PlannerPolicy()
+	p.Filesystems = filesystems
+	return p
+}
+
+// Returns true if the invocation was interrupted because a blackout window began.
+//
+// If filesystems is not nil, only the matching filesystems are replicated and pruning is skipped,
+// the next invocation that replicates all filesystems prunes them.
+func (j *ActiveSide) do(ctx context.Context, filesystems zfs.DatasetFilter, outcome *invocationOutcome) (interruptedByBlackout bool) {
+	defer outcome.phases.observe(j.promPhaseSecs)
 
 	j.mode.ConnectEndpoints(ctx, j.connecter)
 	defer j.mode.DisconnectEndpoints()
@@ -479,72 +855,216 @@ func (j *ActiveSide) do(ctx context.Context) {
 	// allow cancellation of an invocation (this function)
 	ctx, cancelThisRun := context.WithCancel(ctx)
 	defer cancelThisRun()
+	aborted := make(chan struct{})
 	go func() {
 		select {
 		case <-reset.Wait(ctx):
 			log.Info("reset received, cancelling current invocation")
+			j.breaker.reset()
+			cancelThisRun()
+		case <-abort.Wait(ctx):
+			GetLogger(ctx).Info("abort received, cancelling current invocation, interrupted steps resume in the next invocation")
+			close(aborted)
 			cancelThisRun()
 		case <-ctx.Done():
 		}
 	}()
+	defer func() {
+		select {
+		case <-aborted:
+			j.markAborted()
+		default:
+		}
+	}()
 
 	sender, receiver := j.mode.SenderReceiver()
 
-	{
+	// Replication (and pruning, if configured) must not run during blackout windows.
+	invocationCtx := ctx
+	ctx, cancelBlackout, blackoutBegan := j.blackout.interruptAtNextWindow(ctx)
+	defer cancelBlackout()
+	pruningCtx := invocationCtx
+	if j.blackout != nil && j.blackout.pruning {
+		pruningCtx = ctx
+	}
+	interrupted := func() bool {
+		select {
+		case <-blackoutBegan:
+			GetLogger(ctx).Info("blackout window began, interrupting invocation")
+			return true
+		default:
+			return false
+		}
+	}
+
+	// hooks are not interrupted by blackout windows
+	if j.hooks.Run(invocationCtx, hooks.EventReplicationStart, j.hookEnv()) {
+		GetLogger(ctx).Error("replication_start hook failed, not replicating in this invocation")
+		outcome.errs = append(outcome.errs, "replication_start hook failed")
+		j.jobDone(invocationCtx, outcome)
+		j.updateTasks(func(tasks *activeSideTasks) {
+			*tasks = activeSideTasks{state: ActiveSideDone}
+		})
+		return false
+	}
+
+	endReplication := outcome.phases.start(phaseReplication)
+	defer endReplication()
+	replicatePrimary, srcs := j.dueSources()
+	if replicatePrimary {
 		select {
 		case <-ctx.Done():
-			return
+			return interrupted()
 		default:
 		}
 		ctx, endSpan := trace.WithSpan(ctx, "replication")
 		ctx, repCancel := context.WithCancel(ctx)
+		var repReport driver.ReportFunc
 		var repWait driver.WaitFunc
 		j.updateTasks(func(tasks *activeSideTasks) {
 			// reset it
+			prev := *tasks
 			*tasks = activeSideTasks{}
+			if j.pruneSchedule != nil {
+				// keep the reports of the latest scheduled pruning
+				tasks.prunerSender, tasks.prunerSenderCancel = prev.prunerSender, prev.prunerSenderCancel
+				tasks.prunerReceiver, tasks.prunerReceiverCancel = prev.prunerReceiver, prev.prunerReceiverCancel
+			}
 			tasks.replicationCancel = func() { repCancel(); endSpan() }
 			tasks.replicationReport, repWait = replication.Do(
-				ctx, j.replicationDriverConfig, logic.NewPlanner(j.promRepStateSecs, j.promBytesReplicated, sender, receiver, j.mode.PlannerPolicy()),
+				ctx, j.replicationDriverConfig, j.newPlanner(outcome, replicationPeer{}, sender, receiver, filesystems),
 			)
+			repReport = tasks.replicationReport
 			tasks.state = ActiveSideReplicating
 		})
 		GetLogger(ctx).Info("start replication")
+		stopTransfer := j.promTransfer.watch(replicationPeer{}, repReport)
 		repWait(true) // wait blocking
-		repCancel()   // always cancel to free up context resources
+		stopTransfer()
+		repCancel() // always cancel to free up context resources
 
 		replicationReport := j.tasks.replicationReport()
 		var numErrors = replicationReport.GetFailedFilesystemsCountInLatestAttempt()
 		j.promReplicationErrors.Set(float64(numErrors))
-		if numErrors == 0 {
+		if numErrors == 0 && filesystems == nil {
 			j.promLastSuccessful.SetToCurrentTime()
+			j.tasksMtx.Lock()
+			j.lastSuccessfulReplication = time.Now()
+			j.tasksMtx.Unlock()
 		}
+		j.fsErrors.record(replicationReport)
+		j.replicationDone(invocationCtx, outcome, replicationPeer{}, replicationReport)
 
 		endSpan()
 	}
 
+	dsts := j.pushDestinations()
+	for _, d := range dsts {
+		select {
+		case <-ctx.Done():
+			return interrupted()
+		default:
+		}
+		ctx, endSpan := trace.WithSpan(ctx, fmt.Sprintf("replication-destination-%s", d.name))
+		ctx, repCancel := context.WithCancel(ctx)
+		dsender, dreceiver := d.SenderReceiver()
+		var repReport driver.ReportFunc
+		var repWait driver.WaitFunc
+		d.updateTasks(func(tasks *pushDestinationTasks) {
+			// reset it
+			prev := *tasks
+			*tasks = pushDestinationTasks{}
+			if j.pruneSchedule != nil {
+				tasks.prunerReceiver, tasks.prunerReceiverCancel = prev.prunerReceiver, prev.prunerReceiverCancel
+			}
+			tasks.replicationCancel = func() { repCancel(); endSpan() }
+			tasks.replicationReport, repWait = replication.Do(
+				ctx, j.replicationDriverConfig, j.newPlanner(outcome, replicationPeer{destination: d.name}, dsender, dreceiver, filesystems),
+			)
+			repReport = tasks.replicationReport
+		})
+		GetLogger(ctx).WithField("destination", d.name).Info("start replication to destination")
+		stopTransfer := j.promTransfer.watch(replicationPeer{destination: d.name}, repReport)
+		repWait(true) // wait blocking
+		stopTransfer()
+		repCancel() // always cancel to free up context resources
+		dreport := d.updateTasks(nil).replicationReport()
+		d.fsErrors.record(dreport)
+		j.replicationDone(invocationCtx, outcome, replicationPeer{destination: d.name}, dreport)
+		endSpan()
+	}
+
+	j.replicateSources(ctx, invocationCtx, srcs, filesystems, outcome)
+	endReplication()
+
+	// replication continues after the window, pruning follows then
+	if interrupted() {
+		return true
+	}
+
+	// with a prune schedule, pruning is invoked by Run instead
+	if j.pruneSchedule == nil && filesystems != nil {
+		GetLogger(ctx).Info("skipping pruning because the invocation only replicated some filesystems")
+	} else if j.pruneSchedule == nil {
+		if replicatePrimary && j.prune(pruningCtx, sender, receiver, outcome) {
+			return interrupted()
+		}
+		if j.pruneSources(pruningCtx, srcs, outcome) {
+			return interrupted()
+		}
+	}
+
+	j.updateTasks(func(tasks *activeSideTasks) {
+		tasks.state = ActiveSideDone
+	})
+
+	if j.blackout != nil && j.blackout.pruning && interrupted() {
+		return true
+	}
+	j.jobDone(invocationCtx, outcome)
+	return false
+}
+
+// Records that the invocation was aborted and has stopped.
+// Replication steps that were interrupted keep the receiver's partial receive state
+// and the sender's step holds, the next invocation resumes them.
+func (j *ActiveSide) markAborted() {
+	j.updateTasks(func(tasks *activeSideTasks) {
+		tasks.state = ActiveSideDone
+		tasks.abortedAt = time.Now()
+	})
+}
+
+// Prunes the sender, the receiver and the receivers of additional destinations, in that order.
+// Returns true if ctx was done before all of them were pruned.
+func (j *ActiveSide) prune(ctx context.Context, sender logic.Sender, receiver logic.Receiver, outcome *invocationOutcome) (ctxDone bool) {
+	defer outcome.phases.start(phasePruning)()
+	dsts := j.pushDestinations()
 	{
 		select {
 		case <-ctx.Done():
-			return
+			return true
 		default:
 		}
+		cursorSender := pruneCursorSender(sender, dsts)
 		ctx, endSpan := trace.WithSpan(ctx, "prune_sender")
 		ctx, senderCancel := context.WithCancel(ctx)
 		tasks := j.updateTasks(func(tasks *activeSideTasks) {
-			tasks.prunerSender = j.prunerFactory.BuildSenderPruner(ctx, sender, sender)
+			tasks.prunerSender = j.prunerFactory.BuildSenderPruner(ctx, sender, cursorSender)
 			tasks.prunerSenderCancel = func() { senderCancel(); endSpan() }
 			tasks.state = ActiveSidePruneSender
 		})
 		GetLogger(ctx).Info("start pruning sender")
 		tasks.prunerSender.Prune()
 		GetLogger(ctx).Info("finished pruning sender")
+		outcome.pruningDone("sender", tasks.prunerSender.Report())
 		senderCancel()
 		endSpan()
 	}
 	{
 		select {
 		case <-ctx.Done():
-			return
+			return true
 		default:
 		}
 		ctx, endSpan := trace.WithSpan(ctx, "prune_recever")
@@ -557,12 +1077,30 @@ func (j *ActiveSide) do(ctx context.Context) {
 		GetLogger(ctx).Info("start pruning receiver")
 		tasks.prunerReceiver.Prune()
 		GetLogger(ctx).Info("finished pruning receiver")
+		outcome.pruningDone("receiver", tasks.prunerReceiver.Report())
 		receiverCancel()
 		endSpan()
 	}
 
-	j.updateTasks(func(tasks *activeSideTasks) {
-		tasks.state = ActiveSideDone
-	})
-
+	for _, d := range dsts {
+		select {
+		case <-ctx.Done():
+			return true
+		default:
+		}
+		ctx, endSpan := trace.WithSpan(ctx, fmt.Sprintf("prune_receiver-destination-%s", d.name))
+		ctx, receiverCancel := context.WithCancel(ctx)
+		dsender, dreceiver := d.SenderReceiver()
+		tasks := d.updateTasks(func(tasks *pushDestinationTasks) {
+			tasks.prunerReceiver = j.prunerFactory.BuildReceiverPruner(pruner.WithPeer(ctx, d.name), dreceiver, dsender)
+			tasks.prunerReceiverCancel = func() { receiverCancel(); endSpan() }
+		})
+		GetLogger(ctx).WithField("destination", d.name).Info("start pruning receiver of destination")
+		tasks.prunerReceiver.Prune()
+		GetLogger(ctx).WithField("destination", d.name).Info("finished pruning receiver of destination")
+		outcome.pruningDone(fmt.Sprintf("receiver of destination %q", d.name), tasks.prunerReceiver.Report())
+		receiverCancel()
+		endSpan()
+	}
+	return false
 }
@@ -0,0 +1,187 @@
+package job
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/job/reset"
+	"github.com/zrepl/zrepl/daemon/job/wakeup"
+	"github.com/zrepl/zrepl/util/suspendresumesafetimer"
+)
+
+// circuitBreaker suspends an active side after consecutive failed invocations
+// so that a broken peer is not contacted on every wakeup.
+// A nil *circuitBreaker never suspends the job.
+type circuitBreaker struct {
+	failures                   int
+	initialBackoff, maxBackoff time.Duration
+
+	promSuspended prometheus.Gauge
+
+	mtx            sync.Mutex
+	consecutive    int
+	suspendedUntil time.Time
+}
+
+func circuitBreakerFromConfig(in *config.CircuitBreakerOptions) (*circuitBreaker, error) {
+	if in == nil || in.Failures == 0 {
+		return nil, nil
+	}
+	if in.MaxBackoff < in.InitialBackoff {
+		return nil, errors.New("`max_backoff` must not be less than `initial_backoff`")
+	}
+	return &circuitBreaker{
+		failures:       in.Failures,
+		initialBackoff: in.InitialBackoff,
+		maxBackoff:     in.MaxBackoff,
+	}, nil
+}
+
+// backoff returns the suspension after the given number of consecutive failures,
+// which is zero below the threshold.
+func (b *circuitBreaker) backoff(consecutive int) time.Duration {
+	if consecutive < b.failures {
+		return 0
+	}
+	d := b.initialBackoff
+	for i := b.failures; i < consecutive && d < b.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > b.maxBackoff {
+		d = b.maxBackoff
+	}
+	return d
+}
+
+// record updates the breaker with the outcome of an invocation that ended at now.
+// It returns the end of the suspension, or the zero value if the job is not suspended.
+func (b *circuitBreaker) record(now time.Time, err error) time.Time {
+	if b == nil {
+		return time.Time{}
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if err == nil {
+		b.consecutive = 0
+		b.suspendedUntil = time.Time{}
+	} else {
+		b.consecutive++
+		if d := b.backoff(b.consecutive); d > 0 {
+			b.suspendedUntil = now.Add(d)
+		}
+	}
+	b.updateMetrics()
+	return b.suspendedUntil
+}
+
+// reset lifts the suspension and forgets previous failures.
+// Returns false if there were none.
+func (b *circuitBreaker) reset() bool {
+	if b == nil {
+		return false
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	hadFailures := b.consecutive > 0
+	b.consecutive = 0
+	b.suspendedUntil = time.Time{}
+	b.updateMetrics()
+	return hadFailures
+}
+
+// suspended returns the end of the suspension if the job is suspended at now.
+func (b *circuitBreaker) suspended(now time.Time) (until time.Time, ok bool) {
+	if b == nil {
+		return time.Time{}, false
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if !now.Before(b.suspendedUntil) {
+		return time.Time{}, false
+	}
+	return b.suspendedUntil, true
+}
+
+// Blocks until the circuit breaker's suspension (if any) has ended or `zrepl signal reset` lifts it.
+// Wakeups and periodic invocations that arrive in the meantime are coalesced into the
+// invocation that follows, which decides whether the job stays suspended.
+// The returned error is non-nil only if ctx is done.
+func (j *ActiveSide) waitForCircuitBreaker(ctx context.Context, periodicDone <-chan struct{}) error {
+	until, ok := j.breaker.suspended(time.Now())
+	if !ok {
+		return nil
+	}
+	log := GetLogger(ctx).WithField("until", until)
+	log.Info("job is suspended by circuit breaker, waiting for the suspension to end")
+
+	sleepCtx, cancelSleep := context.WithCancel(ctx)
+	defer cancelSleep()
+	sleepDone := make(chan struct{})
+	go func() {
+		defer close(sleepDone)
+		_ = suspendresumesafetimer.SleepUntil(sleepCtx, until)
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-reset.Wait(ctx):
+			j.breaker.reset()
+			log.Info("reset received, lifting circuit breaker suspension")
+			return nil
+		case <-wakeup.Wait(ctx):
+			j.markAllSourcesDue()
+			log.Info("wakeup while suspended by circuit breaker, invocation is queued until the suspension ends")
+		case <-periodicDone:
+			log.Debug("periodic invocation while suspended by circuit breaker, coalesced into queued invocation")
+		case <-sleepDone:
+			log.Info("circuit breaker suspension ended, retrying")
+			return nil
+		}
+	}
+}
+
+func (b *circuitBreaker) status() *CircuitBreakerStatus {
+	if b == nil {
+		return nil
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if b.consecutive == 0 {
+		return nil
+	}
+	return &CircuitBreakerStatus{
+		ConsecutiveFailures: b.consecutive,
+		Threshold:           b.failures,
+		SuspendedUntil:      b.suspendedUntil,
+	}
+}
+
+func (b *circuitBreaker) updateMetrics() {
+	if b.promSuspended == nil {
+		return
+	}
+	if b.suspendedUntil.IsZero() {
+		b.promSuspended.Set(0)
+	} else {
+		b.promSuspended.Set(float64(b.suspendedUntil.Unix()))
+	}
+}
+
+type CircuitBreakerStatus struct {
+	ConsecutiveFailures int
+	// number of consecutive failures after which the job is suspended
+	Threshold int
+	// the zero value if the job is not suspended
+	SuspendedUntil time.Time
+}
+
+// Suspended returns true if the job was suspended at now.
+func (s *CircuitBreakerStatus) Suspended(now time.Time) bool {
+	return s != nil && now.Before(s.SuspendedUntil)
+}
@@ -0,0 +1,69 @@
+package job
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b, err := circuitBreakerFromConfig(&config.CircuitBreakerOptions{
+		Failures:       3,
+		InitialBackoff: time.Minute,
+		MaxBackoff:     5 * time.Minute,
+	})
+	require.NoError(t, err)
+
+	now := time.Date(2022, 7, 23, 14, 0, 0, 0, time.UTC)
+	failed := errors.New("failed")
+
+	assert.True(t, b.record(now, failed).IsZero())
+	assert.True(t, b.record(now, failed).IsZero())
+	_, suspended := b.suspended(now)
+	assert.False(t, suspended)
+	assert.Equal(t, &CircuitBreakerStatus{ConsecutiveFailures: 2, Threshold: 3}, b.status())
+
+	// exponential backoff up to the cap
+	for _, expect := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute} {
+		assert.Equal(t, now.Add(expect), b.record(now, failed))
+		until, suspended := b.suspended(now)
+		assert.True(t, suspended)
+		assert.Equal(t, now.Add(expect), until)
+		_, suspended = b.suspended(until)
+		assert.False(t, suspended, "the suspension ends at until")
+	}
+	assert.Equal(t, 7, b.status().ConsecutiveFailures)
+	assert.True(t, b.status().Suspended(now))
+
+	// a successful invocation closes the breaker
+	assert.True(t, b.record(now, nil).IsZero())
+	assert.Nil(t, b.status())
+	assert.True(t, b.record(now, failed).IsZero(), "failures are counted from zero again")
+
+	assert.True(t, b.reset())
+	assert.False(t, b.reset())
+	assert.Nil(t, b.status())
+}
+
+func TestCircuitBreakerFromConfig(t *testing.T) {
+	b, err := circuitBreakerFromConfig(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, b)
+	b, err = circuitBreakerFromConfig(&config.CircuitBreakerOptions{Failures: 0, InitialBackoff: time.Minute, MaxBackoff: time.Hour})
+	assert.NoError(t, err)
+	assert.Nil(t, b, "failures: 0 disables the circuit breaker")
+	_, err = circuitBreakerFromConfig(&config.CircuitBreakerOptions{Failures: 1, InitialBackoff: time.Hour, MaxBackoff: time.Minute})
+	assert.Error(t, err)
+
+	// a nil breaker never suspends
+	assert.True(t, b.record(time.Now(), errors.New("failed")).IsZero())
+	_, suspended := b.suspended(time.Now())
+	assert.False(t, suspended)
+	assert.Nil(t, b.status())
+	assert.False(t, b.status().Suspended(time.Now()))
+}
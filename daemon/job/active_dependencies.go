@@ -0,0 +1,158 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// jobDependencies tracks the dependencies of an active job (config field `after`):
+// the job's replication is triggered once each of the jobs it depends on
+// has completed an invocation successfully since the last trigger.
+type jobDependencies struct {
+	after      []string      // names of the jobs this job depends on, sorted
+	dependents []*ActiveSide // jobs that depend on this job
+	trigger    chan struct{} // buffered, receives when all dependencies are done
+
+	mtx  sync.Mutex
+	done map[string]bool // dependencies that completed successfully since the last trigger
+}
+
+func newJobDependencies(after []string) (*jobDependencies, error) {
+	seen := make(map[string]bool, len(after))
+	for _, a := range after {
+		if seen[a] {
+			return nil, errors.Errorf("duplicate job %q", a)
+		}
+		seen[a] = true
+	}
+	sorted := append([]string(nil), after...)
+	sort.Strings(sorted)
+	return &jobDependencies{
+		after:   sorted,
+		trigger: make(chan struct{}, 1),
+		done:    make(map[string]bool, len(after)),
+	}, nil
+}
+
+// Records that dependency completed successfully.
+// Returns true if that triggered the job.
+func (d *jobDependencies) dependencyDone(dependency string) (triggered bool) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.done[dependency] = true
+	for _, a := range d.after {
+		if !d.done[a] {
+			return false
+		}
+	}
+	d.done = make(map[string]bool, len(d.after))
+	select {
+	case d.trigger <- struct{}{}:
+	default: // already triggered, but the job has not yet started an invocation
+	}
+	return true
+}
+
+// Returns the dependencies that have not completed successfully since the last trigger.
+func (d *jobDependencies) waitingFor() []string {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	var w []string
+	for _, a := range d.after {
+		if !d.done[a] {
+			w = append(w, a)
+		}
+	}
+	return w
+}
+
+// Links the active jobs in js according to their `after` dependencies.
+// Returns an error if a dependency is not an active job of js or if the dependencies form a cycle.
+func linkJobDependencies(js []Job) error {
+	active := make(map[string]*ActiveSide)
+	for _, j := range js {
+		if a, ok := j.(*ActiveSide); ok {
+			active[a.Name()] = a
+		}
+	}
+	names := make([]string, 0, len(active))
+	for name, j := range active {
+		names = append(names, name)
+		for _, dep := range j.deps.after {
+			if dep == name {
+				return errors.Errorf("job %q: `after` must not contain the job itself", name)
+			}
+			d, ok := active[dep]
+			if !ok {
+				return errors.Errorf("job %q: job %q in `after` does not exist or is not a push or pull job", name, dep)
+			}
+			d.deps.dependents = append(d.deps.dependents, j)
+		}
+	}
+	// deterministic order for error messages and status
+	sort.Strings(names)
+	for _, j := range active {
+		sort.Slice(j.deps.dependents, func(a, b int) bool {
+			return j.deps.dependents[a].Name() < j.deps.dependents[b].Name()
+		})
+	}
+
+	const (
+		unvisited = iota
+		inProgress
+		finished
+	)
+	state := make(map[string]int, len(active))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		path = append(path, name)
+		switch state[name] {
+		case inProgress:
+			for i := range path {
+				if path[i] == name {
+					path = path[i:]
+					break
+				}
+			}
+			return fmt.Errorf("job dependency cycle: %s", strings.Join(path, " -> "))
+		case finished:
+			return nil
+		}
+		state[name] = inProgress
+		for _, dep := range active[name].deps.after {
+			if err := visit(dep, path); err != nil {
+				return err
+			}
+		}
+		state[name] = finished
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Notifies the jobs that depend on j about a successful invocation of j.
+func (j *ActiveSide) notifyDependents(ctx context.Context) {
+	for _, d := range j.deps.dependents {
+		if d.deps.dependencyDone(j.Name()) {
+			GetLogger(ctx).WithField("dependent_job", d.Name()).Info("triggering replication of dependent job")
+		}
+	}
+}
+
+func (j *ActiveSide) dependentNames() []string {
+	names := make([]string, len(j.deps.dependents))
+	for i, d := range j.deps.dependents {
+		names[i] = d.Name()
+	}
+	return names
+}
@@ -0,0 +1,233 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/replication/driver"
+	"github.com/zrepl/zrepl/replication/logic"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+	"github.com/zrepl/zrepl/rpc"
+	"github.com/zrepl/zrepl/rpc/versionhandshake"
+	"github.com/zrepl/zrepl/transport"
+	"github.com/zrepl/zrepl/transport/fromconfig"
+)
+
+// pushDestination is an additional sink that a push job replicates to
+// (config field `destinations`).
+//
+// Each destination has its own connection and its own sender JobID
+// (see pushDestinationJobID) so that replication cursors and step holds
+// are tracked independently per destination.
+type pushDestination struct {
+	job          string
+	name         string
+	connecter    transport.Connecter
+	senderConfig *endpoint.SenderConfig
+
+	setupMtx sync.Mutex
+	sender   *endpoint.Sender
+	receiver *rpc.Client
+
+	tasksMtx sync.Mutex
+	tasks    pushDestinationTasks
+
+	fsErrors filesystemErrorHistory
+}
+
+type pushDestinationTasks struct {
+	replicationReport driver.ReportFunc
+	replicationCancel context.CancelFunc
+
+	prunerReceiver       *pruner.Pruner
+	prunerReceiverCancel context.CancelFunc
+}
+
+func (d *pushDestination) updateTasks(u func(*pushDestinationTasks)) pushDestinationTasks {
+	d.tasksMtx.Lock()
+	defer d.tasksMtx.Unlock()
+	copy := d.tasks
+	if u == nil {
+		return copy
+	}
+	u(&copy)
+	d.tasks = copy
+	return copy
+}
+
+func (d *pushDestination) ConnectEndpoints(ctx context.Context) {
+	d.setupMtx.Lock()
+	defer d.setupMtx.Unlock()
+	if d.receiver != nil || d.sender != nil {
+		panic("inconsistent use of ConnectEndpoints and DisconnectEndpoints")
+	}
+	d.sender = endpoint.NewSender(*d.senderConfig)
+	d.receiver = rpc.NewClient(d.connecter, rpc.GetLoggersOrPanic(ctx), versionhandshake.PeerKey{Job: d.job, Peer: d.name})
+}
+
+func (d *pushDestination) DisconnectEndpoints() {
+	d.setupMtx.Lock()
+	defer d.setupMtx.Unlock()
+	d.receiver.Close()
+	d.sender = nil
+	d.receiver = nil
+}
+
+func (d *pushDestination) SenderReceiver() (*endpoint.Sender, *rpc.Client) {
+	d.setupMtx.Lock()
+	defer d.setupMtx.Unlock()
+	return d.sender, d.receiver
+}
+
+func (d *pushDestination) ResetConnectBackoff() {
+	d.setupMtx.Lock()
+	defer d.setupMtx.Unlock()
+	if d.receiver != nil {
+		d.receiver.ResetConnectBackoff()
+	}
+}
+
+func (d *pushDestination) Status() *ActiveSideDestinationStatus {
+	tasks := d.updateTasks(nil)
+	s := &ActiveSideDestinationStatus{}
+	if tasks.replicationReport != nil {
+		s.Replication = tasks.replicationReport()
+	}
+	if tasks.prunerReceiver != nil {
+		s.PruningReceiver = tasks.prunerReceiver.Report()
+	}
+	s.FilesystemErrors = d.fsErrors.status()
+	return s
+}
+
+// The sender JobID used for replication to destination `destination` of push job `jobID`.
+//
+// Note that it shares the namespace with regular job names, see validateJobIDsDoNotCollide.
+func pushDestinationJobID(jobID endpoint.JobID, destination string) (endpoint.JobID, error) {
+	return endpoint.MakeJobID(fmt.Sprintf("%s_%s", jobID.String(), destination))
+}
+
+func pushDestinationsFromConfig(g *config.Global, in []*config.PushDestination, primary *endpoint.SenderConfig, parseFlags config.ParseFlags) ([]*pushDestination, error) {
+	dsts := make([]*pushDestination, 0, len(in))
+	seen := make(map[string]bool, len(in))
+	for i, d := range in {
+		if d.Name == "" {
+			return nil, errors.Errorf("destination #%d: name must not be empty", i)
+		}
+		if seen[d.Name] {
+			return nil, errors.Errorf("duplicate destination name %q", d.Name)
+		}
+		seen[d.Name] = true
+
+		jobID, err := pushDestinationJobID(primary.JobID, d.Name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "destination %q: invalid name", d.Name)
+		}
+		senderConfig := *primary
+		senderConfig.JobID = jobID
+		if err := senderConfig.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "destination %q: cannot build sender config", d.Name)
+		}
+
+		connecter, err := fromconfig.ConnecterFromConfig(g, d.Connect, parseFlags)
+		if err != nil {
+			return nil, errors.Wrapf(err, "destination %q: cannot build client", d.Name)
+		}
+
+		dsts = append(dsts, &pushDestination{
+			job:          primary.JobID.String(),
+			name:         d.Name,
+			connecter:    connecter,
+			senderConfig: &senderConfig,
+		})
+	}
+	return dsts, nil
+}
+
+// pruneCursorSender returns the pruner.Sender for the sender-side pruner of a job
+// that replicates from sender to the job's receiver and dsts.
+// With multiple destinations, a snapshot only counts as replicated
+// once it has been replicated to all of them.
+func pruneCursorSender(sender logic.Sender, dsts []*pushDestination) pruner.Sender {
+	if len(dsts) == 0 {
+		return sender
+	}
+	senders := []*endpoint.Sender{sender.(*endpoint.Sender)}
+	for _, d := range dsts {
+		dsender, _ := d.SenderReceiver()
+		senders = append(senders, dsender)
+	}
+	return oldestCursorSender{senders}
+}
+
+// oldestCursorSender presents the replication cursor of the destination that
+// lags behind the most to the sender-side pruner.
+// Thereby, the `not_replicated` keep rule keeps all snapshots that have not
+// yet been replicated to all destinations of a push job,
+// or to `min_destinations` of them (see ReplicationCursorOfDestinations).
+type oldestCursorSender struct {
+	// senders[0] is used for ListFilesystems and ListFilesystemVersions
+	senders []*endpoint.Sender
+}
+
+var _ pruner.FanOutSender = oldestCursorSender{}
+
+func (s oldestCursorSender) ListFilesystems(ctx context.Context, req *pdu.ListFilesystemReq) (*pdu.ListFilesystemRes, error) {
+	return s.senders[0].ListFilesystems(ctx, req)
+}
+
+func (s oldestCursorSender) ReplicationCursor(ctx context.Context, req *pdu.ReplicationCursorReq) (*pdu.ReplicationCursorRes, error) {
+	return s.ReplicationCursorOfDestinations(ctx, req, len(s.senders))
+}
+
+// If fewer than n destinations have a cursor, the result has Notexist set.
+func (s oldestCursorSender) ReplicationCursorOfDestinations(ctx context.Context, req *pdu.ReplicationCursorReq, n int) (*pdu.ReplicationCursorRes, error) {
+	if n < 1 || n > len(s.senders) {
+		return nil, errors.Errorf("`min_destinations` is %d but the job replicates to %d destinations", n, len(s.senders))
+	}
+	cursors := make([]*pdu.ReplicationCursorRes, 0, len(s.senders))
+	var notexist *pdu.ReplicationCursorRes
+	for _, sender := range s.senders {
+		res, err := sender.ReplicationCursor(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if res.GetNotexist() {
+			notexist = res
+			continue
+		}
+		cursors = append(cursors, res)
+	}
+	if len(cursors) < n {
+		return notexist, nil
+	}
+	if len(cursors) == 1 {
+		return cursors[0], nil
+	}
+
+	vres, err := s.senders[0].ListFilesystemVersions(ctx, &pdu.ListFilesystemVersionsReq{Filesystem: req.GetFilesystem()})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list filesystem versions to determine replication cursor of destinations")
+	}
+	createTXG := make(map[uint64]uint64, len(vres.GetVersions()))
+	for _, v := range vres.GetVersions() {
+		createTXG[v.GetGuid()] = v.GetCreateTXG()
+	}
+	for _, c := range cursors {
+		if _, ok := createTXG[c.GetGuid()]; !ok {
+			return nil, errors.Errorf("replication cursor (guid %d) not found in filesystem versions", c.GetGuid())
+		}
+	}
+	// most recent first, the n-th cursor has been reached by n destinations
+	sort.SliceStable(cursors, func(i, j int) bool {
+		return createTXG[cursors[i].GetGuid()] > createTXG[cursors[j].GetGuid()]
+	})
+	return cursors[n-1], nil
+}
@@ -0,0 +1,136 @@
+package job
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/replication/logic"
+	"github.com/zrepl/zrepl/replication/report"
+)
+
+// DryRunPlan is the result of planning the replication of an active side
+// to one of its receivers without transferring any data.
+type DryRunPlan struct {
+	// empty for the receiver in the job's `connect` field,
+	// the destination's name for additional destinations of a push job
+	Destination string
+	// the source's name for additional sources of a pull job
+	Source string
+	// non-nil if planning failed before any filesystem could be planned
+	Error       error
+	Filesystems []*DryRunFilesystemPlan
+}
+
+type DryRunFilesystemPlan struct {
+	Filesystem string
+	// non-nil if the filesystem cannot be replicated, e.g., because of a conflict
+	Error error
+	// empty if the filesystem is up to date
+	Steps []*report.StepInfo
+}
+
+// DryRunReplicationPlan connects to the job's peer(s) and runs the replication planner,
+// including size estimation, but does not replicate, prune, or hold any snapshots.
+//
+// Must not be called while the job is running.
+func (j *ActiveSide) DryRunReplicationPlan(ctx context.Context) []*DryRunPlan {
+	ctx = context.WithValue(ctx, endpoint.ClientIdentityKey, FakeActiveSideDirectMethodInvocationClientIdentity(j.name))
+
+	j.mode.ConnectEndpoints(ctx, j.connecter)
+	defer j.mode.DisconnectEndpoints()
+
+	sender, receiver := j.mode.SenderReceiver()
+	plans := []*DryRunPlan{
+		dryRunPlan(ctx, "", logic.NewPlanner(logic.PlannerMetrics{}, sender, receiver, j.mode.PlannerPolicy())),
+	}
+	for _, d := range j.pushDestinations() {
+		dsender, dreceiver := d.SenderReceiver()
+		plans = append(plans, dryRunPlan(ctx, d.name, logic.NewPlanner(logic.PlannerMetrics{}, dsender, dreceiver, j.mode.PlannerPolicy())))
+	}
+	for _, s := range j.pullSources() {
+		ssender, sreceiver := s.SenderReceiver()
+		p := dryRunPlan(ctx, "", logic.NewPlanner(logic.PlannerMetrics{}, ssender, sreceiver, j.mode.PlannerPolicy()))
+		p.Source = s.name
+		plans = append(plans, p)
+	}
+	return plans
+}
+
+func dryRunPlan(ctx context.Context, destination string, planner *logic.Planner) *DryRunPlan {
+	p := &DryRunPlan{Destination: destination}
+
+	if err := planner.WaitForConnectivity(ctx); err != nil {
+		p.Error = errors.Wrap(err, "cannot connect")
+		return p
+	}
+
+	fss, err := planner.Plan(ctx)
+	if err != nil {
+		p.Error = errors.Wrap(err, "cannot list filesystems")
+		return p
+	}
+	for _, fs := range fss {
+		fsp := &DryRunFilesystemPlan{Filesystem: fs.ReportInfo().Name}
+		steps, err := fs.PlanFS(ctx)
+		if err != nil {
+			fsp.Error = err
+		}
+		for _, s := range steps {
+			fsp.Steps = append(fsp.Steps, s.ReportInfo())
+		}
+		p.Filesystems = append(p.Filesystems, fsp)
+	}
+	return p
+}
+
+// DryRunPrunePlan is the result of evaluating the keep rules of one side of an active job.
+type DryRunPrunePlan struct {
+	Side string // "sender" or "receiver"
+	// the destination's name for the receivers of additional destinations of a push job
+	Destination string
+	// the source's name for the sender and receiver of additional sources of a pull job
+	Source string
+	Report *pruner.Report
+}
+
+// DryRunPrune connects to the job's peer(s) and evaluates the keep rules against the
+// snapshots of the sender and the receiver(s), but does not destroy any snapshots.
+//
+// Must not be called while the job is running.
+func (j *ActiveSide) DryRunPrune(ctx context.Context) []*DryRunPrunePlan {
+	ctx = context.WithValue(ctx, endpoint.ClientIdentityKey, FakeActiveSideDirectMethodInvocationClientIdentity(j.name))
+
+	j.mode.ConnectEndpoints(ctx, j.connecter)
+	defer j.mode.DisconnectEndpoints()
+
+	dryRun := func(p *pruner.Pruner) *pruner.Report {
+		p.DryRun()
+		return p.Report()
+	}
+
+	sender, receiver := j.mode.SenderReceiver()
+	dsts := j.pushDestinations()
+	plans := []*DryRunPrunePlan{
+		{Side: "sender", Report: dryRun(j.prunerFactory.BuildSenderPruner(ctx, sender, pruneCursorSender(sender, dsts)))},
+		{Side: "receiver", Report: dryRun(j.prunerFactory.BuildReceiverPruner(ctx, receiver, sender))},
+	}
+	for _, d := range dsts {
+		dsender, dreceiver := d.SenderReceiver()
+		plans = append(plans, &DryRunPrunePlan{
+			Side:        "receiver",
+			Destination: d.name,
+			Report:      dryRun(j.prunerFactory.BuildReceiverPruner(ctx, dreceiver, dsender)),
+		})
+	}
+	for _, s := range j.pullSources() {
+		ssender, sreceiver := s.SenderReceiver()
+		plans = append(plans,
+			&DryRunPrunePlan{Side: "sender", Source: s.name, Report: dryRun(j.prunerFactory.BuildSenderPruner(ctx, ssender, ssender))},
+			&DryRunPrunePlan{Side: "receiver", Source: s.name, Report: dryRun(j.prunerFactory.BuildReceiverPruner(ctx, sreceiver, ssender))},
+		)
+	}
+	return plans
+}
@@ -0,0 +1,60 @@
+package job
+
+import (
+	"sync"
+
+	"github.com/zrepl/zrepl/replication/report"
+	"github.com/zrepl/zrepl/util/envconst"
+)
+
+var filesystemErrorHistoryLength = envconst.Int("ZREPL_JOB_FILESYSTEM_ERROR_HISTORY_LENGTH", 10)
+
+// filesystemErrorHistory keeps the most recent errors of each filesystem replicated with a peer.
+// Unlike the replication report, which only covers the latest invocation, it spans all invocations
+// since the daemon started.
+//
+// The zero value is an empty history.
+type filesystemErrorHistory struct {
+	mtx  sync.Mutex
+	byFS map[string][]*report.FilesystemError
+}
+
+// record adds the errors of all attempts in rep.
+func (h *filesystemErrorHistory) record(rep *report.Report) {
+	if rep == nil || filesystemErrorHistoryLength <= 0 {
+		return
+	}
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	for _, a := range rep.Attempts {
+		for _, fs := range a.Filesystems {
+			errs := fs.Errors()
+			if len(errs) == 0 {
+				continue
+			}
+			if h.byFS == nil {
+				h.byFS = make(map[string][]*report.FilesystemError)
+			}
+			l := append(h.byFS[fs.Info.Name], errs...)
+			if len(l) > filesystemErrorHistoryLength {
+				l = append([]*report.FilesystemError(nil), l[len(l)-filesystemErrorHistoryLength:]...)
+			}
+			h.byFS[fs.Info.Name] = l
+		}
+	}
+}
+
+// status returns a copy of the history, keyed by filesystem, oldest error first.
+// Returns nil if there are no errors.
+func (h *filesystemErrorHistory) status() map[string][]*report.FilesystemError {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if len(h.byFS) == 0 {
+		return nil
+	}
+	s := make(map[string][]*report.FilesystemError, len(h.byFS))
+	for fs, errs := range h.byFS {
+		s[fs] = append([]*report.FilesystemError(nil), errs...)
+	}
+	return s
+}
@@ -0,0 +1,61 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/replication/report"
+)
+
+func TestFilesystemErrorHistory(t *testing.T) {
+	defer func(l int) { filesystemErrorHistoryLength = l }(filesystemErrorHistoryLength)
+	filesystemErrorHistoryLength = 3
+
+	now := time.Now()
+	invocation := func(errs ...string) *report.Report {
+		a := &report.AttemptReport{}
+		for _, e := range errs {
+			a.Filesystems = append(a.Filesystems, &report.FilesystemReport{
+				Info:        &report.FilesystemInfo{Name: "pool/a"},
+				State:       report.FilesystemSteppingErrored,
+				StepError:   report.NewTimedError(e, now),
+				CurrentStep: 0,
+				Steps:       []*report.StepReport{{Info: &report.StepInfo{To: "@" + e}}},
+				RetryErrors: []*report.FilesystemError{{Err: "retry " + e, Time: now}},
+			})
+		}
+		a.Filesystems = append(a.Filesystems, &report.FilesystemReport{
+			Info:  &report.FilesystemInfo{Name: "pool/ok"},
+			State: report.FilesystemDone,
+		})
+		return &report.Report{Attempts: []*report.AttemptReport{a}}
+	}
+
+	var h filesystemErrorHistory
+	assert.Nil(t, h.status())
+
+	h.record(invocation("1"))
+	h.record(invocation("2"))
+	h.record(nil)
+	s := h.status()
+	require.Len(t, s, 1, "filesystems without errors are omitted")
+	errs := s["pool/a"]
+	var msgs []string
+	for _, e := range errs {
+		msgs = append(msgs, e.Err)
+	}
+	assert.Equal(t, []string{"1", "retry 2", "2"}, msgs, "the oldest errors are dropped")
+	assert.Equal(t, "@2", errs[2].Step.To)
+
+	// status returns a copy
+	s["pool/a"][0] = nil
+	assert.NotNil(t, h.status()["pool/a"][0])
+
+	filesystemErrorHistoryLength = 0
+	var disabled filesystemErrorHistory
+	disabled.record(invocation("3"))
+	assert.Nil(t, disabled.status())
+}
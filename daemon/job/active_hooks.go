@@ -0,0 +1,168 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/job/finished"
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/replication/report"
+)
+
+// invocationOutcome collects the failures of an invocation of an active job
+// for the job_success / job_failure hooks.
+type invocationOutcome struct {
+	startAt           time.Time
+	failedFilesystems map[string]bool
+	errs              []string
+	// for the invocation history, see InvocationSummary.BytesReplicated and Pruned
+	bytesReplicated uint64
+	pruned          int
+	// for the zrepl_job_phase_time metric
+	phases phaseTimes
+}
+
+func newInvocationOutcome() *invocationOutcome {
+	return &invocationOutcome{startAt: time.Now(), failedFilesystems: make(map[string]bool)}
+}
+
+// err returns nil if the invocation succeeded.
+func (o *invocationOutcome) err() error {
+	if len(o.errs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(o.errs, "; "))
+}
+
+func (j *ActiveSide) hookEnv() hooks.Env {
+	return hooks.Env{hooks.EnvJob: j.name.String()}
+}
+
+// replicationPeer identifies the peer of a replication,
+// the zero value is the peer in the job's `connect` field.
+type replicationPeer struct {
+	// an additional destination of a push job
+	destination string
+	// an additional source of a pull job
+	source string
+}
+
+// name is the value of the peer label of the per-peer metrics, empty for the peer in `connect`.
+func (p replicationPeer) name() string {
+	if p.destination != "" {
+		return p.destination
+	}
+	return p.source
+}
+
+// Runs the replication_filesystem_done hooks for the filesystems of the latest attempt in rep
+// and updates the filesystem_last_successful metric of those that were replicated without error.
+func (j *ActiveSide) replicationDone(ctx context.Context, o *invocationOutcome, peer replicationPeer, rep *report.Report) {
+	prefix := "replication"
+	switch {
+	case peer.destination != "":
+		prefix = fmt.Sprintf("replication to destination %q", peer.destination)
+	case peer.source != "":
+		prefix = fmt.Sprintf("replication from source %q", peer.source)
+	}
+	if len(rep.Attempts) == 0 {
+		o.errs = append(o.errs, fmt.Sprintf("%s: no attempt was made", prefix))
+		return
+	}
+	for _, a := range rep.Attempts {
+		for _, fs := range a.Filesystems {
+			for _, step := range fs.Steps {
+				o.bytesReplicated += step.Info.BytesReplicated
+			}
+		}
+	}
+	a := rep.Attempts[len(rep.Attempts)-1]
+	if a.State == report.AttemptPlanningError {
+		errStr := "planning failed"
+		if a.PlanError != nil {
+			errStr = a.PlanError.Err
+		}
+		o.errs = append(o.errs, fmt.Sprintf("%s: %s", prefix, errStr))
+	}
+	numFailed := 0
+	for _, fs := range a.Filesystems {
+		env := j.hookEnv()
+		env[hooks.EnvFS] = fs.Info.Name
+		env[hooks.EnvDestination] = peer.destination
+		env[hooks.EnvSource] = peer.source
+		env[hooks.EnvReplicationState] = string(fs.State)
+		if err := fs.Error(); err != nil {
+			env[hooks.EnvError] = err.Err
+			o.failedFilesystems[fs.Info.Name] = true
+			numFailed++
+		} else if fs.State == report.FilesystemDone && j.promFilesystemLastSuccessful != nil {
+			j.promFilesystemLastSuccessful.WithLabelValues(fs.Info.Name, peer.name()).SetToCurrentTime()
+		}
+		j.hooks.Run(ctx, hooks.EventFilesystemDone, env)
+	}
+	if numFailed > 0 {
+		o.errs = append(o.errs, fmt.Sprintf("%s: %d filesystem(s) failed", prefix, numFailed))
+	}
+}
+
+func (o *invocationOutcome) pruningDone(side string, rep *pruner.Report) {
+	if rep.Error != "" {
+		o.errs = append(o.errs, fmt.Sprintf("pruning %s: %s", side, rep.Error))
+	}
+	numFailed := 0
+	for _, fs := range rep.Completed {
+		if fs.LastError != "" {
+			numFailed++
+		}
+		o.pruned += fs.Destroyed
+	}
+	if numFailed > 0 {
+		o.errs = append(o.errs, fmt.Sprintf("pruning %s: %d filesystem(s) failed", side, numFailed))
+	}
+}
+
+func (o *invocationOutcome) summary() *InvocationSummary {
+	s := &InvocationSummary{
+		StartAt:           o.startAt,
+		FinishAt:          time.Now(),
+		BytesReplicated:   o.bytesReplicated,
+		FailedFilesystems: len(o.failedFilesystems),
+		Pruned:            o.pruned,
+	}
+	if err := o.err(); err != nil {
+		s.Error = err.Error()
+	}
+	return s
+}
+
+func (j *ActiveSide) jobDone(ctx context.Context, o *invocationOutcome) {
+	defer finished.Notify(ctx)
+	env := j.hookEnv()
+	failed := make([]string, 0, len(o.failedFilesystems))
+	for fs := range o.failedFilesystems {
+		failed = append(failed, fs)
+	}
+	sort.Strings(failed)
+	env[hooks.EnvFailedFilesystems] = strings.Join(failed, "\n")
+	err := o.err()
+	j.invocations.record(o.summary())
+	// cancelled invocations (reset, shutdown) say nothing about the peer
+	if ctx.Err() == nil {
+		if until := j.breaker.record(time.Now(), err); !until.IsZero() {
+			GetLogger(ctx).WithField("until", until).WithField("consecutive_failures", j.breaker.status().ConsecutiveFailures).
+				Warn("circuit breaker suspends the job after consecutive failed invocations")
+		}
+	}
+	if err == nil {
+		j.hooks.Run(ctx, hooks.EventJobSuccess, env)
+		j.notifyDependents(ctx)
+		return
+	}
+	env[hooks.EnvError] = err.Error()
+	j.hooks.Run(ctx, hooks.EventJobFailure, env)
+}
@@ -0,0 +1,62 @@
+package job
+
+import (
+	"sync"
+	"time"
+
+	"github.com/zrepl/zrepl/util/envconst"
+)
+
+var invocationHistoryRetention = envconst.Duration("ZREPL_JOB_INVOCATION_HISTORY_RETENTION", 24*time.Hour)
+
+// InvocationSummary summarizes a finished invocation of an active or snap job,
+// see ActiveSideStatus.RecentInvocations and SnapJobStatus.RecentInvocations.
+type InvocationSummary struct {
+	StartAt, FinishAt time.Time
+	// the bytes sent in all replication attempts of the invocation, to all peers,
+	// not including the bytes that resumed steps had transferred before
+	BytesReplicated uint64
+	// the number of filesystems that failed to replicate to any of the peers
+	FailedFilesystems int
+	// the number of snapshots and bookmarks that pruning destroyed, on all sides
+	Pruned int `json:",omitempty"`
+	// empty if the invocation succeeded
+	Error string `json:",omitempty"`
+}
+
+// invocationHistory keeps the summaries of the invocations that finished within invocationHistoryRetention.
+//
+// The zero value is an empty history.
+type invocationHistory struct {
+	mtx         sync.Mutex
+	invocations []*InvocationSummary
+}
+
+func (h *invocationHistory) record(s *InvocationSummary) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.invocations = append(h.invocations, s)
+	h.expire(s.FinishAt)
+}
+
+// caller must hold h.mtx
+func (h *invocationHistory) expire(now time.Time) {
+	i := 0
+	for i < len(h.invocations) && now.Sub(h.invocations[i].FinishAt) > invocationHistoryRetention {
+		i++
+	}
+	if i > 0 {
+		h.invocations = append([]*InvocationSummary(nil), h.invocations[i:]...)
+	}
+}
+
+// status returns the invocations that finished within invocationHistoryRetention, oldest first.
+func (h *invocationHistory) status(now time.Time) []*InvocationSummary {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.expire(now)
+	if len(h.invocations) == 0 {
+		return nil
+	}
+	return append([]*InvocationSummary(nil), h.invocations...)
+}
@@ -0,0 +1,28 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvocationHistory(t *testing.T) {
+	now := time.Now()
+	var h invocationHistory
+	assert.Nil(t, h.status(now))
+
+	h.record(&InvocationSummary{FinishAt: now.Add(-25 * time.Hour), BytesReplicated: 1})
+	h.record(&InvocationSummary{FinishAt: now.Add(-23 * time.Hour), BytesReplicated: 2})
+	h.record(&InvocationSummary{FinishAt: now, BytesReplicated: 3, Error: "failed"})
+
+	s := h.status(now)
+	require.Len(t, s, 2, "invocations older than the retention are dropped")
+	assert.Equal(t, uint64(2), s[0].BytesReplicated)
+	assert.Equal(t, "failed", s[1].Error)
+
+	s = h.status(now.Add(2 * time.Hour))
+	require.Len(t, s, 1)
+	assert.Equal(t, uint64(3), s[0].BytesReplicated)
+}
@@ -0,0 +1,49 @@
+package job
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zrepl/zrepl/replication/driver"
+	"github.com/zrepl/zrepl/replication/logic"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// newPlanner returns the planner of the replication with peer in the invocation of outcome.
+func (j *ActiveSide) newPlanner(outcome *invocationOutcome, peer replicationPeer, sender logic.Sender, receiver logic.Receiver, filesystems zfs.DatasetFilter) driver.Planner {
+	metrics := logic.PlannerMetrics{
+		SecsPerState:    j.promRepStateSecs,
+		StepSecs:        j.promRepStepSecs,
+		BytesReplicated: j.promBytesReplicated,
+		BytesResumed:    j.promBytesResumed,
+	}
+	return instrumentedPlanner{
+		Planner:    logic.NewPlanner(metrics, sender, receiver, j.plannerPolicy(filesystems)),
+		phases:     &outcome.phases,
+		reconnects: j.promReconnects.MustCurryWith(prometheus.Labels{"peer": peer.name()}),
+	}
+}
+
+// instrumentedPlanner adds the time spent in Plan, i.e., once per replication attempt, to the planning phase,
+// and counts the calls of WaitForConnectivity, i.e., the replication driver's reconnects after connectivity-related errors.
+type instrumentedPlanner struct {
+	driver.Planner
+	phases     *phaseTimes
+	reconnects *prometheus.CounterVec // labels: outcome
+}
+
+func (p instrumentedPlanner) Plan(ctx context.Context) ([]driver.FS, error) {
+	defer p.phases.start(phasePlanning)()
+	return p.Planner.Plan(ctx)
+}
+
+func (p instrumentedPlanner) WaitForConnectivity(ctx context.Context) error {
+	err := p.Planner.WaitForConnectivity(ctx)
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	p.reconnects.WithLabelValues(outcome).Inc()
+	return err
+}
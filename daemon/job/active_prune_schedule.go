@@ -0,0 +1,126 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/job/abort"
+	"github.com/zrepl/zrepl/daemon/job/reset"
+	"github.com/zrepl/zrepl/util/suspendresumesafetimer"
+)
+
+// pruneSchedule determines when an active side prunes if pruning is decoupled from replication.
+// cron.Schedule implements it.
+type pruneSchedule interface {
+	Next(t time.Time) time.Time
+}
+
+type pruneInterval time.Duration
+
+func (i pruneInterval) Next(t time.Time) time.Time { return t.Add(time.Duration(i)) }
+
+// Returns nil if in is nil, i.e., if pruning follows replication.
+func pruneScheduleFromConfig(in *config.PruningSchedule) (pruneSchedule, error) {
+	if in == nil {
+		return nil, nil
+	}
+	switch {
+	case in.Interval != nil && in.Cron == nil:
+		return pruneInterval(in.Interval.Duration()), nil
+	case in.Cron != nil && in.Interval == nil:
+		if in.Cron.Schedule == nil {
+			return nil, errors.New("`cron` must not be empty")
+		}
+		return in.Cron.Schedule, nil
+	default:
+		return nil, errors.New("exactly one of `interval` and `cron` must be specified")
+	}
+}
+
+// Sends on due whenever s fires.
+// Activations while the previous one has not been received yet are coalesced.
+func runPruneSchedule(ctx context.Context, s pruneSchedule, due chan<- struct{}) {
+	log := GetLogger(ctx)
+	for {
+		next := s.Next(time.Now())
+		if next.IsZero() {
+			log.Warn("prune schedule does not fire anymore")
+			return
+		}
+		log.WithField("next", next).Debug("waiting for scheduled pruning")
+		if err := suspendresumesafetimer.SleepUntil(ctx, next); err != nil {
+			return
+		}
+		select {
+		case due <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Prunes without replicating first, see config.PruningSenderReceiver.Schedule.
+// Scheduled pruning that is due during a blackout window that covers pruning is skipped.
+func (j *ActiveSide) doScheduledPrune(ctx context.Context) {
+	log := GetLogger(ctx)
+
+	blackoutPruning := j.blackout != nil && j.blackout.pruning
+	if until := j.blackout.windowEnd(time.Now()); blackoutPruning && !until.IsZero() {
+		log.WithField("until", until).Info("in blackout window, skipping scheduled pruning")
+		return
+	}
+
+	j.mode.ConnectEndpoints(ctx, j.connecter)
+	defer j.mode.DisconnectEndpoints()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	aborted := make(chan struct{})
+	go func() {
+		select {
+		case <-reset.Wait(ctx):
+			log.Info("reset received, cancelling scheduled pruning")
+			cancel()
+		case <-abort.Wait(ctx):
+			log.Info("abort received, cancelling scheduled pruning")
+			close(aborted)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	defer func() {
+		select {
+		case <-aborted:
+			j.markAborted()
+		default:
+		}
+	}()
+	if blackoutPruning {
+		var cancelBlackout context.CancelFunc
+		ctx, cancelBlackout, _ = j.blackout.interruptAtNextWindow(ctx)
+		defer cancelBlackout()
+	}
+
+	j.updateTasks(func(tasks *activeSideTasks) {
+		tasks.abortedAt = time.Time{}
+	})
+	sender, receiver := j.mode.SenderReceiver()
+	outcome := newInvocationOutcome()
+	defer outcome.phases.observe(j.promPhaseSecs)
+	log.Info("start scheduled pruning")
+	if j.prune(ctx, sender, receiver, outcome) || j.pruneSources(ctx, j.pullSources(), outcome) {
+		log.Info("scheduled pruning interrupted")
+		return
+	}
+	j.updateTasks(func(tasks *activeSideTasks) {
+		tasks.state = ActiveSideDone
+	})
+	if len(outcome.errs) > 0 {
+		log.WithField("errors", outcome.errs).Error("scheduled pruning failed")
+		return
+	}
+	log.Info("finished scheduled pruning")
+}
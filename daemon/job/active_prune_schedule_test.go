@@ -0,0 +1,38 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zrepl/yaml-config"
+
+	"github.com/zrepl/zrepl/config"
+)
+
+func TestPruneScheduleFromConfig(t *testing.T) {
+	parse := func(s string) *config.PruningSchedule {
+		var v config.PruningSchedule
+		require.NoError(t, yaml.UnmarshalStrict([]byte(s), &v))
+		return &v
+	}
+	at := time.Date(2022, 7, 23, 12, 30, 0, 0, time.UTC)
+
+	s, err := pruneScheduleFromConfig(nil)
+	require.NoError(t, err)
+	assert.Nil(t, s)
+
+	s, err = pruneScheduleFromConfig(parse("interval: 6h\n"))
+	require.NoError(t, err)
+	assert.Equal(t, at.Add(6*time.Hour), s.Next(at))
+
+	s, err = pruneScheduleFromConfig(parse("cron: \"0 3 * * *\"\n"))
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2022, 7, 24, 3, 0, 0, 0, time.UTC), s.Next(at))
+
+	_, err = pruneScheduleFromConfig(parse("interval: 6h\ncron: \"0 3 * * *\"\n"))
+	assert.Error(t, err)
+	_, err = pruneScheduleFromConfig(&config.PruningSchedule{})
+	assert.Error(t, err)
+}
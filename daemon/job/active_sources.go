@@ -0,0 +1,365 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/replication"
+	"github.com/zrepl/zrepl/replication/driver"
+	"github.com/zrepl/zrepl/rpc"
+	"github.com/zrepl/zrepl/rpc/versionhandshake"
+	"github.com/zrepl/zrepl/transport"
+	"github.com/zrepl/zrepl/transport/fromconfig"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// pullSource is an additional source that a pull job replicates from
+// (config field `sources`).
+//
+// Each source has its own connection, its own root_fs and its own receiver JobID
+// (see pullSourceJobID). The job's replication, pruning and hook settings apply
+// to all sources.
+type pullSource struct {
+	job            string
+	name           string
+	connecter      transport.Connecter
+	receiverConfig endpoint.ReceiverConfig
+	interval       config.PositiveDurationOrManual
+
+	setupMtx sync.Mutex
+	receiver *endpoint.Receiver
+	sender   *rpc.Client
+
+	tasksMtx sync.Mutex
+	tasks    pullSourceTasks
+
+	fsErrors filesystemErrorHistory
+}
+
+type pullSourceTasks struct {
+	replicationReport driver.ReportFunc
+	replicationCancel context.CancelFunc
+
+	prunerSender, prunerReceiver             *pruner.Pruner
+	prunerSenderCancel, prunerReceiverCancel context.CancelFunc
+}
+
+func (s *pullSource) updateTasks(u func(*pullSourceTasks)) pullSourceTasks {
+	s.tasksMtx.Lock()
+	defer s.tasksMtx.Unlock()
+	copy := s.tasks
+	if u == nil {
+		return copy
+	}
+	u(&copy)
+	s.tasks = copy
+	return copy
+}
+
+func (s *pullSource) ConnectEndpoints(ctx context.Context) {
+	s.setupMtx.Lock()
+	defer s.setupMtx.Unlock()
+	if s.receiver != nil || s.sender != nil {
+		panic("inconsistent use of ConnectEndpoints and DisconnectEndpoints")
+	}
+	s.receiver = endpoint.NewReceiver(s.receiverConfig)
+	s.sender = rpc.NewClient(s.connecter, rpc.GetLoggersOrPanic(ctx), versionhandshake.PeerKey{Job: s.job, Peer: s.name})
+}
+
+func (s *pullSource) DisconnectEndpoints() {
+	s.setupMtx.Lock()
+	defer s.setupMtx.Unlock()
+	s.sender.Close()
+	s.sender = nil
+	s.receiver = nil
+}
+
+func (s *pullSource) SenderReceiver() (*rpc.Client, *endpoint.Receiver) {
+	s.setupMtx.Lock()
+	defer s.setupMtx.Unlock()
+	return s.sender, s.receiver
+}
+
+func (s *pullSource) ResetConnectBackoff() {
+	s.setupMtx.Lock()
+	defer s.setupMtx.Unlock()
+	if s.sender != nil {
+		s.sender.ResetConnectBackoff()
+	}
+}
+
+func (s *pullSource) Status() *ActiveSideSourceStatus {
+	tasks := s.updateTasks(nil)
+	st := &ActiveSideSourceStatus{}
+	if tasks.replicationReport != nil {
+		st.Replication = tasks.replicationReport()
+	}
+	if tasks.prunerSender != nil {
+		st.PruningSender = tasks.prunerSender.Report()
+	}
+	if tasks.prunerReceiver != nil {
+		st.PruningReceiver = tasks.prunerReceiver.Report()
+	}
+	st.FilesystemErrors = s.fsErrors.status()
+	return st
+}
+
+// The receiver JobID used for replication from source `source` of pull job `jobID`.
+//
+// Note that it shares the namespace with regular job names, see validateJobIDsDoNotCollide.
+func pullSourceJobID(jobID endpoint.JobID, source string) (endpoint.JobID, error) {
+	return endpoint.MakeJobID(fmt.Sprintf("%s_%s", jobID.String(), source))
+}
+
+// pullSourceReceivingJobConfig is the ReceivingJobConfig of a source:
+// the job's receive options with the source's root_fs.
+type pullSourceReceivingJobConfig struct {
+	*config.PullJob
+	rootFS string
+}
+
+func (c pullSourceReceivingJobConfig) GetRootFS() string { return c.rootFS }
+
+func pullSourcesFromConfig(g *config.Global, in *config.PullJob, jobID endpoint.JobID, parseFlags config.ParseFlags) ([]*pullSource, error) {
+	srcs := make([]*pullSource, 0, len(in.Sources))
+	seen := make(map[string]bool, len(in.Sources))
+	rootFSs := []string{in.RootFS}
+	for i, s := range in.Sources {
+		if s.Name == "" {
+			return nil, errors.Errorf("source #%d: name must not be empty", i)
+		}
+		if seen[s.Name] {
+			return nil, errors.Errorf("duplicate source name %q", s.Name)
+		}
+		seen[s.Name] = true
+
+		srcJobID, err := pullSourceJobID(jobID, s.Name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "source %q: invalid name", s.Name)
+		}
+		receiverConfig, err := buildReceiverConfig(g, pullSourceReceivingJobConfig{in, s.RootFS}, srcJobID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "source %q", s.Name)
+		}
+		rootFSs = append(rootFSs, receiverConfig.RootWithoutClientComponent.ToString())
+
+		connecter, err := fromconfig.ConnecterFromConfig(g, s.Connect, parseFlags)
+		if err != nil {
+			return nil, errors.Wrapf(err, "source %q: cannot build client", s.Name)
+		}
+
+		interval := in.Interval
+		if s.Interval != nil {
+			interval = *s.Interval
+		}
+
+		srcs = append(srcs, &pullSource{
+			job:            jobID.String(),
+			name:           s.Name,
+			connecter:      connecter,
+			receiverConfig: receiverConfig,
+			interval:       interval,
+		})
+	}
+	if err := validateReceivingSidesDoNotOverlap(rootFSs); err != nil {
+		return nil, errors.New("the root_fs of the job and its sources must not overlap")
+	}
+	return srcs, nil
+}
+
+// pullSchedule tracks which of the sources of a pull job are due for replication.
+// The job's own source (config field `connect`) has the empty name.
+type pullSchedule struct {
+	mtx sync.Mutex
+	due map[string]bool
+	all bool
+}
+
+func (s *pullSchedule) markDue(names ...string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.due == nil {
+		s.due = make(map[string]bool)
+	}
+	for _, n := range names {
+		s.due[n] = true
+	}
+}
+
+// markAllDue makes all sources due, e.g. for wakeups.
+func (s *pullSchedule) markAllDue() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.all = true
+}
+
+// takeDue returns the sources that are due and clears them.
+// If none are due, all sources are due, so that an invocation never does nothing.
+func (s *pullSchedule) takeDue(all []string) map[string]bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	due := s.due
+	if s.all || len(due) == 0 {
+		due = make(map[string]bool, len(all))
+		for _, n := range all {
+			due[n] = true
+		}
+	}
+	s.due, s.all = nil, false
+	return due
+}
+
+// runPullInterval signals wakeUpCommon every interval after marking the given sources as due.
+func runPullInterval(ctx context.Context, interval time.Duration, schedule *pullSchedule, sources []string, wakeUpCommon chan<- struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			schedule.markDue(sources...)
+			select {
+			case wakeUpCommon <- struct{}{}:
+			default:
+				GetLogger(ctx).
+					WithField("pull_interval", interval).
+					Warn("pull job took longer than pull interval")
+				wakeUpCommon <- struct{}{} // block anyways, to queue up the wakeup
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Additional sources of a pull job. Returns nil for push jobs.
+func (j *ActiveSide) pullSources() []*pullSource {
+	pull, ok := j.mode.(*modePull)
+	if !ok {
+		_ = j.mode.(*modePush) // make sure we didn't introduce a new job type
+		return nil
+	}
+	return pull.sources
+}
+
+// Makes all sources of a pull job due for replication in the next invocation.
+func (j *ActiveSide) markAllSourcesDue() {
+	if pull, ok := j.mode.(*modePull); ok {
+		pull.schedule.markAllDue()
+	}
+}
+
+// dueSources returns whether the job's own peer is due for replication in this invocation,
+// and the additional sources of a pull job that are due.
+// Push jobs always replicate to all destinations.
+func (j *ActiveSide) dueSources() (primary bool, srcs []*pullSource) {
+	pull, ok := j.mode.(*modePull)
+	if !ok || len(pull.sources) == 0 {
+		return true, nil
+	}
+	names := []string{""}
+	for _, s := range pull.sources {
+		names = append(names, s.name)
+	}
+	due := pull.schedule.takeDue(names)
+	for _, s := range pull.sources {
+		if due[s.name] {
+			srcs = append(srcs, s)
+		}
+	}
+	return due[""], srcs
+}
+
+func (j *ActiveSide) replicateSources(ctx, invocationCtx context.Context, srcs []*pullSource, filesystems zfs.DatasetFilter, outcome *invocationOutcome) {
+	for _, s := range srcs {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		ctx, endSpan := trace.WithSpan(ctx, fmt.Sprintf("replication-source-%s", s.name))
+		ctx, repCancel := context.WithCancel(ctx)
+		ssender, sreceiver := s.SenderReceiver()
+		var repReport driver.ReportFunc
+		var repWait driver.WaitFunc
+		s.updateTasks(func(tasks *pullSourceTasks) {
+			// reset it
+			prev := *tasks
+			*tasks = pullSourceTasks{}
+			if j.pruneSchedule != nil {
+				tasks.prunerSender, tasks.prunerSenderCancel = prev.prunerSender, prev.prunerSenderCancel
+				tasks.prunerReceiver, tasks.prunerReceiverCancel = prev.prunerReceiver, prev.prunerReceiverCancel
+			}
+			tasks.replicationCancel = func() { repCancel(); endSpan() }
+			tasks.replicationReport, repWait = replication.Do(
+				ctx, j.replicationDriverConfig, j.newPlanner(outcome, replicationPeer{source: s.name}, ssender, sreceiver, filesystems),
+			)
+			repReport = tasks.replicationReport
+		})
+		GetLogger(ctx).WithField("source", s.name).Info("start replication from source")
+		stopTransfer := j.promTransfer.watch(replicationPeer{source: s.name}, repReport)
+		repWait(true) // wait blocking
+		stopTransfer()
+		repCancel() // always cancel to free up context resources
+		sreport := s.updateTasks(nil).replicationReport()
+		s.fsErrors.record(sreport)
+		j.replicationDone(invocationCtx, outcome, replicationPeer{source: s.name}, sreport)
+		endSpan()
+	}
+}
+
+// Prunes the sender and the receiver of each of srcs.
+// Returns true if ctx was done before all of them were pruned.
+func (j *ActiveSide) pruneSources(ctx context.Context, srcs []*pullSource, outcome *invocationOutcome) (interrupted bool) {
+	defer outcome.phases.start(phasePruning)()
+	for _, s := range srcs {
+		ssender, sreceiver := s.SenderReceiver()
+		log := GetLogger(ctx).WithField("source", s.name)
+		{
+			select {
+			case <-ctx.Done():
+				return true
+			default:
+			}
+			ctx, endSpan := trace.WithSpan(ctx, fmt.Sprintf("prune_sender-source-%s", s.name))
+			ctx, senderCancel := context.WithCancel(ctx)
+			tasks := s.updateTasks(func(tasks *pullSourceTasks) {
+				tasks.prunerSender = j.prunerFactory.BuildSenderPruner(pruner.WithPeer(ctx, s.name), ssender, ssender)
+				tasks.prunerSenderCancel = func() { senderCancel(); endSpan() }
+			})
+			log.Info("start pruning sender of source")
+			tasks.prunerSender.Prune()
+			log.Info("finished pruning sender of source")
+			outcome.pruningDone(fmt.Sprintf("sender of source %q", s.name), tasks.prunerSender.Report())
+			senderCancel()
+			endSpan()
+		}
+		{
+			select {
+			case <-ctx.Done():
+				return true
+			default:
+			}
+			ctx, endSpan := trace.WithSpan(ctx, fmt.Sprintf("prune_receiver-source-%s", s.name))
+			ctx, receiverCancel := context.WithCancel(ctx)
+			tasks := s.updateTasks(func(tasks *pullSourceTasks) {
+				tasks.prunerReceiver = j.prunerFactory.BuildReceiverPruner(pruner.WithPeer(ctx, s.name), sreceiver, ssender)
+				tasks.prunerReceiverCancel = func() { receiverCancel(); endSpan() }
+			})
+			log.Info("start pruning receiver of source")
+			tasks.prunerReceiver.Prune()
+			log.Info("finished pruning receiver of source")
+			outcome.pruningDone(fmt.Sprintf("receiver of source %q", s.name), tasks.prunerReceiver.Report())
+			receiverCancel()
+			endSpan()
+		}
+	}
+	return false
+}
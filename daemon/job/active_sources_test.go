@@ -0,0 +1,28 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPullSchedule(t *testing.T) {
+	all := []string{"", "host2", "host3"}
+	allDue := map[string]bool{"": true, "host2": true, "host3": true}
+
+	var s pullSchedule
+	// e.g. a queued periodic invocation whose sources were taken by the previous one
+	assert.Equal(t, allDue, s.takeDue(all))
+
+	s.markDue("host2")
+	s.markDue("host2", "host3")
+	assert.Equal(t, map[string]bool{"host2": true, "host3": true}, s.takeDue(all))
+	assert.Equal(t, allDue, s.takeDue(all))
+
+	s.markDue("host3")
+	s.markAllDue()
+	assert.Equal(t, allDue, s.takeDue(all))
+
+	s.markDue("")
+	assert.Equal(t, map[string]bool{"": true}, s.takeDue(all))
+}
@@ -1,13 +1,20 @@
 package job
 
 import (
+	"context"
 	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/replication/report"
 	"github.com/zrepl/zrepl/transport"
+	"github.com/zrepl/zrepl/zfs"
 )
 
 func TestFakeActiveSideDirectMethodInvocationClientIdentityDoesNotPassValidityTest(t *testing.T) {
@@ -18,3 +25,60 @@ func TestFakeActiveSideDirectMethodInvocationClientIdentityDoesNotPassValidityTe
 	err = transport.ValidateClientIdentity(clientIdentity)
 	assert.Error(t, err)
 }
+
+func TestWakeupFilesystemsFilter(t *testing.T) {
+	f, err := WakeupFilesystemsFilter([]string{"pool/a", "pool/b<"})
+	require.NoError(t, err)
+	for path, expected := range map[string]bool{
+		"pool":       false,
+		"pool/a":     true,
+		"pool/a/x":   false,
+		"pool/b":     true,
+		"pool/b/x/y": true,
+		"pool/c":     false,
+	} {
+		p, err := zfs.NewDatasetPath(path)
+		require.NoError(t, err)
+		pass, err := f.Filter(p)
+		require.NoError(t, err)
+		assert.Equal(t, expected, pass, path)
+	}
+
+	_, err = WakeupFilesystemsFilter([]string{"pool/<a"})
+	assert.Error(t, err)
+}
+
+func TestReplicationDoneFilesystemLastSuccessful(t *testing.T) {
+	jobid, err := endpoint.MakeJobID("push")
+	require.NoError(t, err)
+	j := &ActiveSide{
+		name:                         jobid,
+		promFilesystemLastSuccessful: prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test"}, []string{"filesystem", "peer"}),
+	}
+	rep := &report.Report{
+		Attempts: []*report.AttemptReport{{
+			State: report.AttemptFanOutError,
+			Filesystems: []*report.FilesystemReport{
+				{Info: &report.FilesystemInfo{Name: "pool/done"}, State: report.FilesystemDone},
+				{Info: &report.FilesystemInfo{Name: "pool/failed"}, State: report.FilesystemSteppingErrored, StepError: report.NewTimedError("broken pipe", time.Now())},
+				{Info: &report.FilesystemInfo{Name: "pool/pending"}, State: report.FilesystemPlanning},
+			},
+		}},
+	}
+	before := float64(time.Now().Unix())
+	o := newInvocationOutcome()
+	j.replicationDone(context.Background(), o, replicationPeer{destination: "offsite"}, rep)
+	assert.Equal(t, map[string]bool{"pool/failed": true}, o.failedFilesystems)
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(j.promFilesystemLastSuccessful)
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	require.Len(t, mfs, 1)
+	assert.Len(t, mfs[0].GetMetric(), 1, "only pool/done")
+	assert.True(t, testutil.ToFloat64(j.promFilesystemLastSuccessful.WithLabelValues("pool/done", "offsite")) >= before)
+
+	// per_filesystem_metrics: false
+	j.promFilesystemLastSuccessful = nil
+	j.replicationDone(context.Background(), newInvocationOutcome(), replicationPeer{}, rep)
+}
@@ -0,0 +1,108 @@
+package job
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zrepl/zrepl/replication/driver"
+	"github.com/zrepl/zrepl/replication/report"
+	"github.com/zrepl/zrepl/util/envconst"
+)
+
+var transferRateInterval = envconst.Duration("ZREPL_JOB_TRANSFER_RATE_INTERVAL", 10*time.Second)
+
+// transferMetrics track the bytes of the send streams that a job transfers with its peers
+// while replication is running, labels: peer (see replicationPeer.name), direction.
+type transferMetrics struct {
+	// sent for push jobs, received for pull jobs
+	direction string
+	bytes     *prometheus.CounterVec
+	rate      *prometheus.GaugeVec
+}
+
+func newTransferMetrics(jobName string, typ Type) *transferMetrics {
+	m := &transferMetrics{direction: "received"}
+	if typ == TypePush {
+		m.direction = "sent"
+	}
+	m.bytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "zrepl",
+		Subsystem:   "replication",
+		Name:        "transferred_bytes",
+		Help:        "number of bytes of send streams transferred with the peer, counted during replication",
+		ConstLabels: prometheus.Labels{"zrepl_job": jobName},
+	}, []string{"peer", "direction"})
+	m.rate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   "zrepl",
+		Subsystem:   "replication",
+		Name:        "transfer_rate_bytes",
+		Help:        "bytes per second transferred with the peer, averaged over ZREPL_JOB_TRANSFER_RATE_INTERVAL, 0 if not replicating",
+		ConstLabels: prometheus.Labels{"zrepl_job": jobName},
+	}, []string{"peer", "direction"})
+	return m
+}
+
+func (m *transferMetrics) register(registerer prometheus.Registerer) {
+	registerer.MustRegister(m.bytes)
+	registerer.MustRegister(m.rate)
+}
+
+// watch updates the metrics of peer from the reports of a running replication
+// every transferRateInterval until stop is called.
+func (m *transferMetrics) watch(peer replicationPeer, rep driver.ReportFunc) (stop func()) {
+	bytes := m.bytes.WithLabelValues(peer.name(), m.direction)
+	rate := m.rate.WithLabelValues(peer.name(), m.direction)
+
+	var last uint64
+	lastAt := time.Now()
+	update := func() {
+		n, now := bytesTransferred(rep()), time.Now()
+		var delta uint64
+		if n > last {
+			delta = n - last
+			bytes.Add(float64(delta))
+		}
+		if elapsed := now.Sub(lastAt).Seconds(); elapsed > 0 {
+			rate.Set(float64(delta) / elapsed)
+		}
+		last, lastAt = n, now
+	}
+
+	stopped, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		t := time.NewTicker(transferRateInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				update()
+			case <-stopped:
+				update()
+				rate.Set(0)
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stopped)
+		<-done
+	}
+}
+
+// bytesTransferred returns the bytes of the send streams of all attempts in rep,
+// excluding the bytes that resumed steps had transferred before.
+func bytesTransferred(rep *report.Report) (n uint64) {
+	if rep == nil {
+		return 0
+	}
+	for _, a := range rep.Attempts {
+		for _, fs := range a.Filesystems {
+			for _, step := range fs.Steps {
+				n += step.Info.BytesReplicated
+			}
+		}
+	}
+	return n
+}
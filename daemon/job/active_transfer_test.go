@@ -0,0 +1,50 @@
+package job
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zrepl/zrepl/replication/report"
+)
+
+func TestTransferMetrics(t *testing.T) {
+	defer func(i time.Duration) { transferRateInterval = i }(transferRateInterval)
+	transferRateInterval = 10 * time.Millisecond
+
+	var replicated uint64
+	rep := func() *report.Report {
+		return &report.Report{
+			Attempts: []*report.AttemptReport{
+				{Filesystems: []*report.FilesystemReport{
+					{Steps: []*report.StepReport{{Info: &report.StepInfo{BytesReplicated: 1000, BytesResumed: 500}}}},
+				}},
+				{Filesystems: []*report.FilesystemReport{
+					{Steps: []*report.StepReport{{Info: &report.StepInfo{BytesReplicated: atomic.LoadUint64(&replicated)}}}},
+					{},
+				}},
+			},
+		}
+	}
+
+	m := newTransferMetrics("job", TypePush)
+	stop := m.watch(replicationPeer{destination: "offsite"}, rep)
+	rate := m.rate.WithLabelValues("offsite", "sent")
+	sawRate := false
+	for deadline := time.Now().Add(5 * time.Second); !sawRate && time.Now().Before(deadline); {
+		atomic.AddUint64(&replicated, 1<<20)
+		time.Sleep(transferRateInterval / 2)
+		sawRate = testutil.ToFloat64(rate) > 0
+	}
+	assert.True(t, sawRate, "rate while replicating")
+	stop()
+
+	assert.Equal(t, float64(1000+atomic.LoadUint64(&replicated)), testutil.ToFloat64(m.bytes.WithLabelValues("offsite", "sent")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(rate))
+
+	assert.Equal(t, "received", newTransferMetrics("job", TypePull).direction)
+	assert.Equal(t, uint64(0), bytesTransferred(nil))
+}
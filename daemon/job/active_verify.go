@@ -0,0 +1,112 @@
+package job
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/replication/logic"
+	"github.com/zrepl/zrepl/replication/logic/diff"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+)
+
+// VerifyReport is the result of comparing the snapshots on the sending side
+// of an active side with those on one of its receivers.
+type VerifyReport struct {
+	// empty for the receiver in the job's `connect` field,
+	// the destination's name for additional destinations of a push job
+	Destination string
+	// the source's name for additional sources of a pull job
+	Source string
+	// non-nil if verification failed before any filesystem could be verified
+	Error       error
+	Filesystems []*VerifyFilesystemReport
+}
+
+type VerifyFilesystemReport struct {
+	Filesystem string
+	// non-nil if the filesystem could not be verified
+	Error error
+	// the filesystem does not exist on the receiver or is a placeholder
+	NotReceived bool
+	Result      *diff.VerifyResult // nil if Error != nil or NotReceived
+}
+
+func (r *VerifyFilesystemReport) OK() bool {
+	return r.Error == nil && (r.NotReceived || r.Result.OK())
+}
+
+// Verify connects to the job's peer(s) and compares the snapshots of all filesystems
+// on sending and receiving side (see diff.Verify). It does not modify either side.
+//
+// Must not be called while the job is running.
+func (j *ActiveSide) Verify(ctx context.Context) []*VerifyReport {
+	ctx = context.WithValue(ctx, endpoint.ClientIdentityKey, FakeActiveSideDirectMethodInvocationClientIdentity(j.name))
+
+	j.mode.ConnectEndpoints(ctx, j.connecter)
+	defer j.mode.DisconnectEndpoints()
+
+	sender, receiver := j.mode.SenderReceiver()
+	reports := []*VerifyReport{verify(ctx, "", sender, receiver)}
+	for _, d := range j.pushDestinations() {
+		dsender, dreceiver := d.SenderReceiver()
+		reports = append(reports, verify(ctx, d.name, dsender, dreceiver))
+	}
+	for _, s := range j.pullSources() {
+		ssender, sreceiver := s.SenderReceiver()
+		r := verify(ctx, "", ssender, sreceiver)
+		r.Source = s.name
+		reports = append(reports, r)
+	}
+	return reports
+}
+
+func verify(ctx context.Context, destination string, sender logic.Sender, receiver logic.Receiver) *VerifyReport {
+	r := &VerifyReport{Destination: destination}
+
+	sfss, err := sender.ListFilesystems(ctx, &pdu.ListFilesystemReq{})
+	if err != nil {
+		r.Error = errors.Wrap(err, "cannot list sender filesystems")
+		return r
+	}
+	rfss, err := receiver.ListFilesystems(ctx, &pdu.ListFilesystemReq{})
+	if err != nil {
+		r.Error = errors.Wrap(err, "cannot list receiver filesystems")
+		return r
+	}
+	rfsByPath := make(map[string]*pdu.Filesystem, len(rfss.GetFilesystems()))
+	for _, rfs := range rfss.GetFilesystems() {
+		rfsByPath[rfs.GetPath()] = rfs
+	}
+
+	for _, sfs := range sfss.GetFilesystems() {
+		fsr := &VerifyFilesystemReport{Filesystem: sfs.GetPath()}
+		r.Filesystems = append(r.Filesystems, fsr)
+
+		rfs, ok := rfsByPath[sfs.GetPath()]
+		if !ok || rfs.GetIsPlaceholder() {
+			fsr.NotReceived = true
+			continue
+		}
+
+		svs, err := sender.ListFilesystemVersions(ctx, &pdu.ListFilesystemVersionsReq{Filesystem: sfs.GetPath()})
+		if err != nil {
+			fsr.Error = errors.Wrap(err, "cannot list sender filesystem versions")
+			continue
+		}
+		rvs, err := receiver.ListFilesystemVersions(ctx, &pdu.ListFilesystemVersionsReq{Filesystem: sfs.GetPath()})
+		if err != nil {
+			fsr.Error = errors.Wrap(err, "cannot list receiver filesystem versions")
+			continue
+		}
+		cursor, err := sender.ReplicationCursor(ctx, &pdu.ReplicationCursorReq{Filesystem: sfs.GetPath()})
+		if err != nil {
+			fsr.Error = errors.Wrap(err, "cannot get replication cursor")
+			continue
+		}
+
+		fsr.Result = diff.Verify(rvs.GetVersions(), svs.GetVersions(), cursor.GetGuid(), !cursor.GetNotexist())
+	}
+	return r
+}
@@ -0,0 +1,191 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/job/wakeup"
+	"github.com/zrepl/zrepl/util/suspendresumesafetimer"
+)
+
+type blackoutWindow struct {
+	schedule cron.Schedule
+	duration time.Duration
+}
+
+// start of the window instance that contains t, if any
+func (w blackoutWindow) containing(t time.Time) (start time.Time, ok bool) {
+	// Next returns the first activation strictly after its argument,
+	// i.e., start > t - duration  <=>  start + duration > t
+	start = w.schedule.Next(t.Add(-w.duration))
+	if start.After(t) {
+		return time.Time{}, false
+	}
+	return start, true
+}
+
+// blackout is the set of time windows during which an active side must not replicate
+// (and optionally not prune). A nil *blackout has no windows.
+type blackout struct {
+	windows []blackoutWindow
+	pruning bool
+}
+
+func blackoutFromConfig(in *config.BlackoutOptions) (*blackout, error) {
+	if in == nil || len(in.Windows) == 0 {
+		if in != nil && in.Pruning {
+			return nil, errors.New("`pruning` requires at least one entry in `windows`")
+		}
+		return nil, nil
+	}
+	b := &blackout{pruning: in.Pruning}
+	for i, w := range in.Windows {
+		if w.Cron.Schedule == nil {
+			return nil, errors.Errorf("window #%d: `cron` must be specified", i)
+		}
+		b.windows = append(b.windows, blackoutWindow{schedule: w.Cron.Schedule, duration: w.Duration})
+	}
+	return b, nil
+}
+
+// the number of adjacent or overlapping window instances that windowEnd chains together
+// before it gives up (e.g. for windows that cover all of the time)
+const blackoutMaxChainedWindows = 1000
+
+// windowEnd returns the time at which the blackout that t is in ends,
+// taking into account windows that overlap or directly follow each other.
+// Returns the zero value if t is not in a blackout window.
+func (b *blackout) windowEnd(t time.Time) time.Time {
+	if b == nil {
+		return time.Time{}
+	}
+	var end time.Time
+	at := t
+	for i := 0; i < blackoutMaxChainedWindows; i++ {
+		extended := false
+		for _, w := range b.windows {
+			start, ok := w.containing(at)
+			if !ok {
+				continue
+			}
+			if e := start.Add(w.duration); e.After(end) {
+				end = e
+				extended = true
+			}
+		}
+		if !extended {
+			break
+		}
+		at = end
+	}
+	return end
+}
+
+// nextWindowStart returns the start of the first window instance that begins after t.
+// Returns the zero value if b has no windows.
+func (b *blackout) nextWindowStart(t time.Time) time.Time {
+	if b == nil {
+		return time.Time{}
+	}
+	var next time.Time
+	for _, w := range b.windows {
+		n := w.schedule.Next(t)
+		if next.IsZero() || n.Before(next) {
+			next = n
+		}
+	}
+	return next
+}
+
+// Returns a context that is cancelled once the next blackout window begins.
+// The returned channel is closed if that is the reason for the cancellation.
+func (b *blackout) interruptAtNextWindow(ctx context.Context) (context.Context, context.CancelFunc, <-chan struct{}) {
+	ctx, cancel := context.WithCancel(ctx)
+	began := make(chan struct{})
+	if b == nil {
+		return ctx, cancel, began
+	}
+	next := b.nextWindowStart(time.Now())
+	go func() {
+		if err := suspendresumesafetimer.SleepUntil(ctx, next); err != nil {
+			return
+		}
+		close(began)
+		cancel()
+	}()
+	return ctx, cancel, began
+}
+
+// Blocks until the current blackout window (if any) has ended.
+// Wakeups and periodic invocations that arrive in the meantime are coalesced into the
+// invocation that follows.
+// The returned error is non-nil only if ctx is done.
+func (j *ActiveSide) waitForBlackoutWindowEnd(ctx context.Context, periodicDone <-chan struct{}) error {
+	log := GetLogger(ctx)
+	defer j.updateTasks(func(tasks *activeSideTasks) {
+		tasks.blackoutWaitUntil = time.Time{}
+	})
+	for {
+		until := j.blackout.windowEnd(time.Now())
+		if until.IsZero() {
+			return nil
+		}
+		j.updateTasks(func(tasks *activeSideTasks) {
+			tasks.blackoutWaitUntil = until
+		})
+		log.WithField("until", until).Info("in blackout window, waiting for it to end")
+
+		sleepCtx, cancelSleep := context.WithCancel(ctx)
+		sleepDone := make(chan struct{})
+		go func() {
+			defer close(sleepDone)
+			_ = suspendresumesafetimer.SleepUntil(sleepCtx, until)
+		}()
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				cancelSleep()
+				return ctx.Err()
+			case <-wakeup.Wait(ctx):
+				j.mode.ResetConnectBackoff()
+				j.markAllSourcesDue()
+				log.Info("wakeup during blackout window, invocation is queued until the window ends")
+			case <-periodicDone:
+				log.Debug("periodic invocation during blackout window, coalesced into queued invocation")
+			case <-sleepDone:
+				break wait
+			}
+		}
+		cancelSleep()
+	}
+}
+
+// allowedWindows implements endpoint.ReceiveWindows for windows during which something is allowed
+// instead of forbidden.
+type allowedWindows struct {
+	*blackout
+}
+
+func (w allowedWindows) Contains(t time.Time) (ok bool, next time.Time) {
+	if !w.windowEnd(t).IsZero() {
+		return true, time.Time{}
+	}
+	return false, w.nextWindowStart(t)
+}
+
+// maintenanceWindowsFromConfig returns the windows of in as a *blackout to compute their ends, nil if in is nil.
+// Unlike blackout windows, maintenance windows do not interrupt invocations, see maintenance.Switch.
+func maintenanceWindowsFromConfig(in *config.MaintenanceOptions) (*blackout, error) {
+	if in == nil {
+		return nil, nil
+	}
+	if len(in.Windows) == 0 {
+		return nil, errors.New("`windows` must not be empty")
+	}
+	return blackoutFromConfig(&config.BlackoutOptions{Windows: in.Windows})
+}
@@ -0,0 +1,73 @@
+package job
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zrepl/yaml-config"
+
+	"github.com/zrepl/zrepl/config"
+)
+
+func TestBlackoutWindows(t *testing.T) {
+
+	window := func(spec string, d time.Duration) *config.BlackoutWindow {
+		var v struct {
+			Spec config.CronSpec
+		}
+		err := yaml.UnmarshalStrict([]byte(fmt.Sprintf("spec: %q\n", spec)), &v)
+		require.NoError(t, err)
+		return &config.BlackoutWindow{Cron: v.Spec, Duration: d}
+	}
+	// 2022-07-23 is a saturday
+	dhm := func(day, hour, minutes int) time.Time {
+		return time.Date(2022, 7, day, hour, minutes, 0, 0, time.UTC)
+	}
+
+	b, err := blackoutFromConfig(&config.BlackoutOptions{
+		Windows: []*config.BlackoutWindow{
+			window("0 8 * * 1-5", 10*time.Hour), // weekdays 08:00 - 18:00
+			window("0 17 * * 5", 2*time.Hour),   // extends friday until 19:00
+			window("0 19 * * 5", 1*time.Hour),   // directly followed by 19:00 - 20:00
+		},
+	})
+	require.NoError(t, err)
+
+	type testCase struct {
+		at        time.Time
+		expectEnd time.Time // zero if not in a window
+		nextStart time.Time
+	}
+	tcs := []testCase{
+		{dhm(18, 7, 59), time.Time{}, dhm(18, 8, 0)},
+		{dhm(18, 8, 0), dhm(18, 18, 0), dhm(19, 8, 0)},
+		{dhm(18, 17, 59), dhm(18, 18, 0), dhm(19, 8, 0)},
+		{dhm(18, 18, 0), time.Time{}, dhm(19, 8, 0)},
+		{dhm(22, 9, 0), dhm(22, 20, 0), dhm(22, 17, 0)},
+		{dhm(22, 19, 30), dhm(22, 20, 0), dhm(25, 8, 0)},
+		{dhm(23, 12, 0), time.Time{}, dhm(25, 8, 0)},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.at.String(), func(t *testing.T) {
+			assert.Equal(t, tc.expectEnd, b.windowEnd(tc.at))
+			assert.Equal(t, tc.nextStart, b.nextWindowStart(tc.at))
+		})
+	}
+
+	// windows that cover all of the time must not loop forever
+	always, err := blackoutFromConfig(&config.BlackoutOptions{
+		Windows: []*config.BlackoutWindow{window("* * * * *", time.Hour)},
+	})
+	require.NoError(t, err)
+	assert.True(t, always.windowEnd(dhm(18, 0, 0)).After(dhm(18, 0, 0)))
+
+	var none *blackout
+	assert.True(t, none.windowEnd(dhm(18, 8, 0)).IsZero())
+	assert.True(t, none.nextWindowStart(dhm(18, 8, 0)).IsZero())
+
+	_, err = blackoutFromConfig(&config.BlackoutOptions{Pruning: true})
+	assert.Error(t, err)
+}
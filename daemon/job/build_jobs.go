@@ -8,6 +8,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/endpoint"
 	"github.com/zrepl/zrepl/util/bandwidthlimit"
 )
 
@@ -24,6 +25,14 @@ func JobsFromConfig(c *config.Config, parseFlags config.ParseFlags) ([]Job, erro
 		js[i] = j
 	}
 
+	if err := validateJobIDsDoNotCollide(js); err != nil {
+		return nil, err
+	}
+
+	if err := linkJobDependencies(js); err != nil {
+		return nil, err
+	}
+
 	return js, nil
 }
 
@@ -58,6 +67,11 @@ func buildJob(c *config.Global, in config.JobEnum, parseFlags config.ParseFlags)
 		if err != nil {
 			return cannotBuildJob(err, v.Name)
 		}
+	case *config.PoolMonitorJob:
+		j, err = poolMonitorJobFromConfig(v)
+		if err != nil {
+			return cannotBuildJob(err, v.Name)
+		}
 	default:
 		panic(fmt.Sprintf("implementation error: unknown job type %T", v))
 	}
@@ -65,6 +79,42 @@ func buildJob(c *config.Global, in config.JobEnum, parseFlags config.ParseFlags)
 
 }
 
+// The JobIDs of push destinations and pull sources are derived from the job name
+// (see pushDestinationJobID and pullSourceJobID) and share the namespace with
+// regular job names, e.g. job `a` with destination `b_c` and job `a_b` with destination `c`.
+// Replication cursors, step holds and last-received-holds would be shared among
+// the colliding jobs, so we refuse such configs.
+func validateJobIDsDoNotCollide(js []Job) error {
+	owner := make(map[string]string, len(js))
+	for _, j := range js {
+		owner[j.Name()] = fmt.Sprintf("job %q", j.Name())
+	}
+	claim := func(jobID endpoint.JobID, what string) error {
+		if other, ok := owner[jobID.String()]; ok {
+			return errors.Errorf("%s uses job ID %q, which is already used by %s", what, jobID.String(), other)
+		}
+		owner[jobID.String()] = what
+		return nil
+	}
+	for _, j := range js {
+		a, ok := j.(*ActiveSide)
+		if !ok {
+			continue
+		}
+		for _, d := range a.pushDestinations() {
+			if err := claim(d.senderConfig.JobID, fmt.Sprintf("destination %q of job %q", d.name, d.job)); err != nil {
+				return err
+			}
+		}
+		for _, s := range a.pullSources() {
+			if err := claim(s.receiverConfig.JobID, fmt.Sprintf("source %q of job %q", s.name, s.job)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func validateReceivingSidesDoNotOverlap(receivingRootFSs []string) error {
 	if len(receivingRootFSs) == 0 {
 		return nil
@@ -103,3 +153,13 @@ func buildBandwidthLimitConfig(in *config.BandwidthLimit) (c bandwidthlimit.Conf
 		BucketCapacity: int64(in.BucketCapacity.ToBytes()),
 	}, nil
 }
+
+// Metrics are labelled with the filesystem unless a prometheus monitoring endpoint disables it.
+func perFilesystemMetricsFromConfig(g *config.Global) bool {
+	for _, m := range g.Monitoring {
+		if p, ok := m.Ret.(*config.PrometheusMonitoring); ok && !p.PerFilesystemMetrics {
+			return false
+		}
+	}
+	return true
+}
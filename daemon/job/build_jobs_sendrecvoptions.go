@@ -1,11 +1,16 @@
 package job
 
 import (
+	"fmt"
+	"regexp"
+
 	"github.com/pkg/errors"
 
 	"github.com/zrepl/zrepl/config"
 	"github.com/zrepl/zrepl/daemon/filters"
 	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/util/bandwidthlimit"
+	"github.com/zrepl/zrepl/util/datasizeunit"
 	"github.com/zrepl/zrepl/util/nodefault"
 	"github.com/zrepl/zrepl/zfs"
 )
@@ -15,7 +20,7 @@ type SendingJobConfig interface {
 	GetSendOptions() *config.SendOptions // must not be nil
 }
 
-func buildSenderConfig(in SendingJobConfig, jobID endpoint.JobID) (*endpoint.SenderConfig, error) {
+func buildSenderConfig(g *config.Global, in SendingJobConfig, jobID endpoint.JobID) (*endpoint.SenderConfig, error) {
 
 	fsf, err := filters.DatasetMapFilterFromConfig(in.GetFilesystems())
 	if err != nil {
@@ -27,6 +32,16 @@ func buildSenderConfig(in SendingJobConfig, jobID endpoint.JobID) (*endpoint.Sen
 		return nil, errors.Wrap(err, "cannot build bandwith limit config")
 	}
 
+	var stepHoldFromBookmark bool
+	switch sendOpts.StepHolds.From {
+	case "hold":
+		stepHoldFromBookmark = false
+	case "bookmark":
+		stepHoldFromBookmark = true
+	default:
+		return nil, errors.Errorf("field `step_holds.from` must be one of `hold` or `bookmark`, got %q", sendOpts.StepHolds.From)
+	}
+
 	sc := &endpoint.SenderConfig{
 		FSF:   fsf,
 		JobID: jobID,
@@ -41,6 +56,10 @@ func buildSenderConfig(in SendingJobConfig, jobID endpoint.JobID) (*endpoint.Sen
 		SendSaved:            sendOpts.Saved,
 
 		BandwidthLimit: bwlim,
+
+		StepHoldFromBookmark: stepHoldFromBookmark,
+
+		PerFilesystemMetrics: perFilesystemMetricsFromConfig(g),
 	}
 
 	if err := sc.Validate(); err != nil {
@@ -56,7 +75,7 @@ type ReceivingJobConfig interface {
 	GetRecvOptions() *config.RecvOptions
 }
 
-func buildReceiverConfig(in ReceivingJobConfig, jobID endpoint.JobID) (rc endpoint.ReceiverConfig, err error) {
+func buildReceiverConfig(g *config.Global, in ReceivingJobConfig, jobID endpoint.JobID) (rc endpoint.ReceiverConfig, err error) {
 	rootFs, err := zfs.NewDatasetPath(in.GetRootFS())
 	if err != nil {
 		return rc, errors.New("root_fs is not a valid zfs filesystem path")
@@ -82,6 +101,11 @@ func buildReceiverConfig(in ReceivingJobConfig, jobID endpoint.JobID) (rc endpoi
 			recvOpts.Placeholder.Encryption, options)
 	}
 
+	forceReceive, err := endpoint.ForceReceivePolicyFromConfig(recvOpts.ForceReceive)
+	if err != nil {
+		return rc, errors.Wrap(err, "field `force_receive`")
+	}
+
 	rc = endpoint.ReceiverConfig{
 		JobID:                      jobID,
 		RootWithoutClientComponent: rootFs,
@@ -93,6 +117,21 @@ func buildReceiverConfig(in ReceivingJobConfig, jobID endpoint.JobID) (rc endpoi
 		BandwidthLimit: bwlim,
 
 		PlaceholderEncryption: placeholderEncryption,
+		PlaceholderProperties: recvOpts.Placeholder.Properties,
+		EncryptOnReceive:      recvOpts.EncryptOnReceive,
+
+		ForceReceive: forceReceive,
+
+		PerFilesystemMetrics: perFilesystemMetricsFromConfig(g),
+	}
+	if recvOpts.MinFreeSpace != nil {
+		if recvOpts.MinFreeSpace.ToBytes() < 0 {
+			return rc, errors.New("`min_free_space` must not be negative")
+		}
+		rc.MinFreeSpace = uint64(recvOpts.MinFreeSpace.ToBytes())
+	}
+	if recvOpts.BrowseMounts != nil {
+		rc.BrowseMountPrefix = recvOpts.BrowseMounts.Prefix
 	}
 	if err := rc.Validate(); err != nil {
 		return rc, errors.Wrap(err, "cannot build receiver config")
@@ -100,3 +139,98 @@ func buildReceiverConfig(in ReceivingJobConfig, jobID endpoint.JobID) (rc endpoi
 
 	return rc, nil
 }
+
+func buildClientQuota(in *config.ClientQuota) (q endpoint.ClientQuota, _ error) {
+	fields := []struct {
+		name string
+		in   *datasizeunit.Bits
+		out  *uint64
+	}{
+		{"quota", in.Quota, &q.Quota},
+		{"reservation", in.Reservation, &q.Reservation},
+	}
+	for _, f := range fields {
+		if f.in == nil {
+			continue
+		}
+		if f.in.ToBytes() < 1 {
+			return q, errors.Errorf("`%s` must be at least one byte", f.name)
+		}
+		*f.out = uint64(f.in.ToBytes())
+	}
+	return q, nil
+}
+
+func buildClientQuotas(in *config.SinkClientQuotas) (def *endpoint.ClientQuota, perClient map[string]endpoint.ClientQuota, _ error) {
+	if in.Default != nil {
+		q, err := buildClientQuota(in.Default)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "field `default`")
+		}
+		def = &q
+	}
+	perClient = make(map[string]endpoint.ClientQuota, len(in.Clients))
+	for clientIdentity, cq := range in.Clients {
+		q, err := buildClientQuota(cq)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "client %q", clientIdentity)
+		}
+		perClient[clientIdentity] = q
+	}
+	return def, perClient, nil
+}
+
+func buildSinkMappings(rootFS *zfs.DatasetPath, in []*config.SinkMapping) ([]endpoint.FilesystemMapping, error) {
+	mappings := make([]endpoint.FilesystemMapping, len(in))
+	for i, m := range in {
+		// the expression must match the whole filesystem path
+		re, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", m.Match))
+		if err != nil {
+			return nil, errors.Wrapf(err, "mapping #%d: invalid `match`", i)
+		}
+		for _, c := range m.Clients {
+			if err := endpoint.TestClientIdentity(rootFS, c); err != nil {
+				return nil, errors.Wrapf(err, "mapping #%d: invalid client identity %q", i, c)
+			}
+		}
+		mappings[i] = endpoint.FilesystemMapping{Clients: m.Clients, Match: re, Replace: m.Replace}
+	}
+	return mappings, nil
+}
+
+func buildClientLimit(in *config.ClientLimits) (l endpoint.ClientLimits, err error) {
+	l.MaxConcurrentReceives = in.MaxConcurrentReceives
+	l.BandwidthLimit = bandwidthlimit.NoLimitConfig()
+	if in.BandwidthLimit != nil {
+		if l.BandwidthLimit, err = buildBandwidthLimitConfig(in.BandwidthLimit); err != nil {
+			return l, errors.Wrap(err, "field `bandwidth_limit`")
+		}
+	}
+	if len(in.ReceiveWindows) > 0 {
+		windows, err := blackoutFromConfig(&config.BlackoutOptions{Windows: in.ReceiveWindows})
+		if err != nil {
+			return l, errors.Wrap(err, "field `receive_windows`")
+		}
+		l.ReceiveWindows = allowedWindows{windows}
+	}
+	return l, nil
+}
+
+func buildClientLimits(in *config.SinkClientLimits) (def *endpoint.ClientLimits, perClient map[string]endpoint.ClientLimits, _ error) {
+	if in.Default != nil {
+		l, err := buildClientLimit(in.Default)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "field `default`")
+		}
+		def = &l
+	}
+	perClient = make(map[string]endpoint.ClientLimits, len(in.Clients))
+	for clientIdentity, cl := range in.Clients {
+		l, err := buildClientLimit(cl)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "client %q", clientIdentity)
+		}
+		perClient[clientIdentity] = l
+	}
+	return def, perClient, nil
+}
@@ -2,15 +2,19 @@ package job
 
 import (
 	"fmt"
+	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/kr/pretty"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/endpoint"
 )
 
 func TestValidateReceivingSidesDoNotOverlap(t *testing.T) {
@@ -285,6 +289,86 @@ jobs:
 `,
 			expectError: true,
 		},
+		{
+			name: "retry_defaults",
+			input: `
+  replication: {}
+`,
+			expectOk: func(t *testing.T, a *ActiveSide, m *modePush) {
+				assert.Equal(t, 3, a.replicationDriverConfig.MaxAttempts)
+				assert.Equal(t, time.Duration(0), a.replicationDriverConfig.RetryInitialDelay)
+				assert.Equal(t, 3, a.replicationDriverConfig.MaxFilesystemRetries)
+			},
+		},
+		{
+			name: "retry_custom_values",
+			input: `
+  replication:
+    retry:
+      max_attempts: 5
+      initial_delay: 30s
+      max_delay: 10m
+      backoff_multiplier: 1.5
+      jitter: 0.2
+      filesystem_retries: 0
+`,
+			expectOk: func(t *testing.T, a *ActiveSide, m *modePush) {
+				c := a.replicationDriverConfig
+				assert.Equal(t, 5, c.MaxAttempts)
+				assert.Equal(t, 30*time.Second, c.RetryInitialDelay)
+				assert.Equal(t, 10*time.Minute, c.RetryMaxDelay)
+				assert.Equal(t, 1.5, c.RetryBackoffMultiplier)
+				assert.Equal(t, 0.2, c.RetryJitter)
+				assert.Equal(t, 0, c.MaxFilesystemRetries)
+			},
+		},
+		{
+			name: "retry_jitter_too_large",
+			input: `
+  replication:
+    retry:
+      jitter: 1.5
+`,
+			expectError: true,
+		},
+		{
+			name: "retry_multiplier_too_small",
+			input: `
+  replication:
+    retry:
+      backoff_multiplier: 0.5
+`,
+			expectError: true,
+		},
+		{
+			name: "retry_filesystem_retries_negative",
+			input: `
+  replication:
+    retry:
+      filesystem_retries: -1
+`,
+			expectError: true,
+		},
+		{
+			name: "incremental_defaults",
+			input: `
+  replication: {}
+`,
+			expectOk: func(t *testing.T, a *ActiveSide, m *modePush) {
+				assert.False(t, m.plannerPolicy.SkipIntermediateSnapshots)
+			},
+		},
+		{
+			name: "incremental_skip_intermediates",
+			input: `
+  replication:
+    incremental:
+      skip_intermediates: true
+`,
+			expectOk: func(t *testing.T, a *ActiveSide, m *modePush) {
+				assert.True(t, m.plannerPolicy.SkipIntermediateSnapshots)
+			},
+		},
 	}
 
 	fill := func(s string) string { return fmt.Sprintf(tmpl, s) }
@@ -316,3 +400,977 @@ jobs:
 	}
 
 }
+
+func TestReplicationMaxAttemptsDeprecatedEnvVar(t *testing.T) {
+	require.NoError(t, os.Setenv(replicationMaxAttemptsEnvVar, "7"))
+	defer os.Unsetenv(replicationMaxAttemptsEnvVar)
+
+	c := &config.Replication{
+		Concurrency: &config.ReplicationOptionsConcurrency{Steps: 1, SizeEstimates: 1},
+		Retry:       &config.ReplicationOptionsRetry{MaxAttempts: 5, BackoffMultiplier: 2, FilesystemRetries: 3},
+	}
+	dc, err := replicationDriverConfigFromConfig(c)
+	require.NoError(t, err)
+	assert.Equal(t, 7, dc.MaxAttempts, "the environment variable takes precedence over retry.max_attempts")
+}
+
+func TestPushDestinations(t *testing.T) {
+	tmpl := `
+jobs:
+- name: foo
+  type: push
+  connect:
+    type: local
+    listener_name: foo
+    client_identity: bar
+  filesystems: {"<": true}
+  %s
+  snapshotting:
+    type: manual
+  pruning:
+    keep_sender:
+    - type: not_replicated
+    keep_receiver:
+    - type: last_n
+      count: 10
+`
+
+	type Test struct {
+		name        string
+		input       string
+		expectOk    func(t *testing.T, a *ActiveSide, m *modePush)
+		expectError bool
+	}
+
+	tests := []Test{
+		{
+			name:  "none",
+			input: ``,
+			expectOk: func(t *testing.T, a *ActiveSide, m *modePush) {
+				assert.Empty(t, m.destinations)
+			},
+		},
+		{
+			name: "two",
+			input: `
+  destinations:
+  - name: offsite
+    connect:
+      type: local
+      listener_name: offsite
+      client_identity: bar
+  - name: usb
+    connect:
+      type: local
+      listener_name: usb
+      client_identity: bar
+`,
+			expectOk: func(t *testing.T, a *ActiveSide, m *modePush) {
+				require.Len(t, m.destinations, 2)
+				assert.Equal(t, "offsite", m.destinations[0].name)
+				assert.Equal(t, "foo_offsite", m.destinations[0].senderConfig.JobID.String())
+				assert.Equal(t, "usb", m.destinations[1].name)
+				assert.Equal(t, "foo_usb", m.destinations[1].senderConfig.JobID.String())
+				// primary sender config must not be affected
+				assert.Equal(t, "foo", m.senderConfig.JobID.String())
+			},
+		},
+		{
+			name: "duplicate_name",
+			input: `
+  destinations:
+  - name: offsite
+    connect:
+      type: local
+      listener_name: offsite
+      client_identity: bar
+  - name: offsite
+    connect:
+      type: local
+      listener_name: usb
+      client_identity: bar
+`,
+			expectError: true,
+		},
+		{
+			name: "invalid_name",
+			input: `
+  destinations:
+  - name: "off/site"
+    connect:
+      type: local
+      listener_name: offsite
+      client_identity: bar
+`,
+			expectError: true,
+		},
+	}
+
+	fill := func(s string) string { return fmt.Sprintf(tmpl, s) }
+
+	for _, ts := range tests {
+		t.Run(ts.name, func(t *testing.T) {
+			assert.True(t, (ts.expectError) != (ts.expectOk != nil))
+
+			cstr := fill(ts.input)
+			t.Logf("testing config:\n%s", cstr)
+			c, err := config.ParseConfigBytes([]byte(cstr))
+			require.NoError(t, err)
+			jobs, err := JobsFromConfig(c, config.ParseFlagsNone)
+			if ts.expectOk != nil {
+				require.NoError(t, err)
+				require.Len(t, jobs, 1)
+				a := jobs[0].(*ActiveSide)
+				m := a.mode.(*modePush)
+				ts.expectOk(t, a, m)
+			} else {
+				t.Logf("error: %s", err)
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestMinDestinationsAreValidatedAgainstDestinations(t *testing.T) {
+	build := func(jobType string, minDestinations int) error {
+		extra := `
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  destinations:
+  - name: offsite
+    connect:
+      type: local
+      listener_name: offsite
+      client_identity: bar`
+		if jobType == "pull" {
+			extra = `
+  root_fs: zroot/pull
+  interval: manual`
+		}
+		c, err := config.ParseConfigBytes([]byte(fmt.Sprintf(`
+jobs:
+- name: foo
+  type: %s
+  connect:
+    type: local
+    listener_name: foo
+    client_identity: bar%s
+  pruning:
+    keep_sender:
+    - type: not_replicated
+      min_destinations: %d
+    keep_receiver:
+    - type: last_n
+      count: 10
+`, jobType, extra, minDestinations)))
+		require.NoError(t, err)
+		_, err = JobsFromConfig(c, config.ParseFlagsNone)
+		return err
+	}
+	assert.NoError(t, build("push", 2))
+	assert.Error(t, build("push", 3), "the sink and one destination")
+	assert.NoError(t, build("pull", 1))
+	assert.Error(t, build("pull", 2), "pull jobs replicate to a single destination")
+}
+
+func TestPullSources(t *testing.T) {
+	tmpl := `
+jobs:
+- name: foo
+  type: pull
+  connect:
+    type: local
+    listener_name: foo
+    client_identity: bar
+  root_fs: zroot/pull/host1
+  interval: 10m
+  %s
+  pruning:
+    keep_sender:
+    - type: not_replicated
+    keep_receiver:
+    - type: last_n
+      count: 10
+`
+
+	type Test struct {
+		name        string
+		input       string
+		expectOk    func(t *testing.T, a *ActiveSide, m *modePull)
+		expectError bool
+	}
+
+	tests := []Test{
+		{
+			name:  "none",
+			input: ``,
+			expectOk: func(t *testing.T, a *ActiveSide, m *modePull) {
+				assert.Empty(t, m.sources)
+			},
+		},
+		{
+			name: "two",
+			input: `
+  sources:
+  - name: host2
+    connect:
+      type: local
+      listener_name: host2
+      client_identity: bar
+    root_fs: zroot/pull/host2
+  - name: host3
+    connect:
+      type: local
+      listener_name: host3
+      client_identity: bar
+    root_fs: zroot/pull/host3
+    interval: manual
+`,
+			expectOk: func(t *testing.T, a *ActiveSide, m *modePull) {
+				require.Len(t, m.sources, 2)
+				assert.Equal(t, "host2", m.sources[0].name)
+				assert.Equal(t, "foo_host2", m.sources[0].receiverConfig.JobID.String())
+				assert.Equal(t, "zroot/pull/host2", m.sources[0].receiverConfig.RootWithoutClientComponent.ToString())
+				assert.Equal(t, 10*time.Minute, m.sources[0].interval.Interval)
+				assert.Equal(t, "host3", m.sources[1].name)
+				assert.True(t, m.sources[1].interval.Manual)
+				// primary receiver config must not be affected
+				assert.Equal(t, "foo", m.receiverConfig.JobID.String())
+				assert.Equal(t, "zroot/pull/host1", m.receiverConfig.RootWithoutClientComponent.ToString())
+			},
+		},
+		{
+			name: "duplicate_name",
+			input: `
+  sources:
+  - name: host2
+    connect:
+      type: local
+      listener_name: host2
+      client_identity: bar
+    root_fs: zroot/pull/host2
+  - name: host2
+    connect:
+      type: local
+      listener_name: host3
+      client_identity: bar
+    root_fs: zroot/pull/host3
+`,
+			expectError: true,
+		},
+		{
+			name: "overlapping_root_fs",
+			input: `
+  sources:
+  - name: host2
+    connect:
+      type: local
+      listener_name: host2
+      client_identity: bar
+    root_fs: zroot/pull/host1/host2
+`,
+			expectError: true,
+		},
+		{
+			name: "invalid_name",
+			input: `
+  sources:
+  - name: "host/2"
+    connect:
+      type: local
+      listener_name: host2
+      client_identity: bar
+    root_fs: zroot/pull/host2
+`,
+			expectError: true,
+		},
+	}
+
+	fill := func(s string) string { return fmt.Sprintf(tmpl, s) }
+
+	for _, ts := range tests {
+		t.Run(ts.name, func(t *testing.T) {
+			assert.True(t, (ts.expectError) != (ts.expectOk != nil))
+
+			cstr := fill(ts.input)
+			t.Logf("testing config:\n%s", cstr)
+			c, err := config.ParseConfigBytes([]byte(cstr))
+			require.NoError(t, err)
+			jobs, err := JobsFromConfig(c, config.ParseFlagsNone)
+			if ts.expectOk != nil {
+				require.NoError(t, err)
+				require.Len(t, jobs, 1)
+				a := jobs[0].(*ActiveSide)
+				m := a.mode.(*modePull)
+				ts.expectOk(t, a, m)
+			} else {
+				t.Logf("error: %s", err)
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestSinkClientQuotas(t *testing.T) {
+	tmpl := `
+jobs:
+- name: sink
+  type: sink
+  root_fs: "pool/sink"
+  serve:
+    type: local
+    listener_name: sink
+  %s
+`
+	type Test struct {
+		name        string
+		input       string
+		expectOk    func(t *testing.T, m *modeSink)
+		expectError bool
+	}
+
+	tests := []Test{
+		{
+			name:  "none",
+			input: ``,
+			expectOk: func(t *testing.T, m *modeSink) {
+				assert.Nil(t, m.receiverConfig.DefaultClientQuota)
+				assert.Empty(t, m.receiverConfig.ClientQuotas)
+			},
+		},
+		{
+			name: "default_and_per_client",
+			input: `
+  client_quotas:
+    default:
+      quota: 1 GiB
+    clients:
+      laptop:
+        quota: 2 GiB
+        reservation: 1 MiB
+`,
+			expectOk: func(t *testing.T, m *modeSink) {
+				require.NotNil(t, m.receiverConfig.DefaultClientQuota)
+				assert.Equal(t, uint64(1<<30), m.receiverConfig.DefaultClientQuota.Quota)
+				assert.Equal(t, uint64(0), m.receiverConfig.DefaultClientQuota.Reservation)
+				require.Contains(t, m.receiverConfig.ClientQuotas, "laptop")
+				assert.Equal(t, uint64(2<<30), m.receiverConfig.ClientQuotas["laptop"].Quota)
+				assert.Equal(t, uint64(1<<20), m.receiverConfig.ClientQuotas["laptop"].Reservation)
+			},
+		},
+		{
+			name: "zero_quota",
+			input: `
+  client_quotas:
+    default:
+      quota: 0 B
+`,
+			expectError: true,
+		},
+		{
+			name: "invalid_client_identity",
+			input: `
+  client_quotas:
+    clients:
+      "with/slash":
+        quota: 1 GiB
+`,
+			expectError: true,
+		},
+	}
+
+	fill := func(s string) string { return fmt.Sprintf(tmpl, s) }
+
+	for _, ts := range tests {
+		t.Run(ts.name, func(t *testing.T) {
+			assert.True(t, (ts.expectError) != (ts.expectOk != nil))
+
+			cstr := fill(ts.input)
+			t.Logf("testing config:\n%s", cstr)
+			c, err := config.ParseConfigBytes([]byte(cstr))
+			require.NoError(t, err)
+			jobs, err := JobsFromConfig(c, config.ParseFlagsNone)
+			if ts.expectOk != nil {
+				require.NoError(t, err)
+				require.Len(t, jobs, 1)
+				p := jobs[0].(*PassiveSide)
+				m := p.mode.(*modeSink)
+				ts.expectOk(t, m)
+			} else {
+				t.Logf("error: %s", err)
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestSinkClientLimits(t *testing.T) {
+	tmpl := `
+jobs:
+- name: sink
+  type: sink
+  root_fs: "pool/sink"
+  serve:
+    type: local
+    listener_name: sink
+  client_limits:
+%s
+`
+	type Test struct {
+		name        string
+		input       string
+		expectOk    func(t *testing.T, m *modeSink)
+		expectError bool
+	}
+
+	tests := []Test{
+		{
+			name: "default_and_per_client",
+			input: `
+    default:
+      max_concurrent_receives: 2
+    clients:
+      laptop:
+        bandwidth_limit:
+          max: 10 MiB
+        receive_windows:
+          - cron: "0 22 * * *"
+            duration: 8h
+`,
+			expectOk: func(t *testing.T, m *modeSink) {
+				require.NotNil(t, m.receiverConfig.DefaultClientLimits)
+				assert.Equal(t, 2, m.receiverConfig.DefaultClientLimits.MaxConcurrentReceives)
+				assert.Equal(t, int64(-1), m.receiverConfig.DefaultClientLimits.BandwidthLimit.Max)
+				assert.Nil(t, m.receiverConfig.DefaultClientLimits.ReceiveWindows)
+				require.Contains(t, m.receiverConfig.ClientLimits, "laptop")
+				l := m.receiverConfig.ClientLimits["laptop"]
+				assert.Equal(t, 0, l.MaxConcurrentReceives)
+				assert.Equal(t, int64(10<<20), l.BandwidthLimit.Max)
+				require.NotNil(t, l.ReceiveWindows)
+				ok, _ := l.ReceiveWindows.Contains(time.Date(2022, 7, 23, 23, 0, 0, 0, time.Local))
+				assert.True(t, ok)
+				ok, next := l.ReceiveWindows.Contains(time.Date(2022, 7, 23, 12, 0, 0, 0, time.Local))
+				assert.False(t, ok)
+				assert.Equal(t, time.Date(2022, 7, 23, 22, 0, 0, 0, time.Local), next)
+			},
+		},
+		{
+			name: "negative_concurrency",
+			input: `
+    default:
+      max_concurrent_receives: -1
+`,
+			expectError: true,
+		},
+		{
+			name: "invalid_client_identity",
+			input: `
+    clients:
+      "with/slash":
+        max_concurrent_receives: 1
+`,
+			expectError: true,
+		},
+	}
+
+	fill := func(s string) string { return fmt.Sprintf(tmpl, s) }
+
+	for _, ts := range tests {
+		t.Run(ts.name, func(t *testing.T) {
+			assert.True(t, (ts.expectError) != (ts.expectOk != nil))
+
+			cstr := fill(ts.input)
+			t.Logf("testing config:\n%s", cstr)
+			c, err := config.ParseConfigBytes([]byte(cstr))
+			if err != nil && ts.expectError {
+				t.Logf("error: %s", err)
+				return
+			}
+			require.NoError(t, err)
+			jobs, err := JobsFromConfig(c, config.ParseFlagsNone)
+			if ts.expectOk != nil {
+				require.NoError(t, err)
+				require.Len(t, jobs, 1)
+				p := jobs[0].(*PassiveSide)
+				m := p.mode.(*modeSink)
+				ts.expectOk(t, m)
+			} else {
+				t.Logf("error: %s", err)
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestSinkMappings(t *testing.T) {
+	tmpl := `
+jobs:
+- name: sink
+  type: sink
+  root_fs: "pool/sink"
+  serve:
+    type: local
+    listener_name: sink
+  mappings:
+%s
+`
+	type Test struct {
+		name        string
+		input       string
+		expectOk    func(t *testing.T, m *modeSink)
+		expectError bool
+	}
+
+	tests := []Test{
+		{
+			name: "ordered_rules",
+			input: `
+  - match: "tank/(.*)"
+    replace: "${client}/$1"
+  - clients: [db1]
+    match: "zroot/var/db(/.*)?"
+    replace: "db$1"
+`,
+			expectOk: func(t *testing.T, m *modeSink) {
+				require.Len(t, m.receiverConfig.Mappings, 2)
+				assert.Empty(t, m.receiverConfig.Mappings[0].Clients)
+				assert.True(t, m.receiverConfig.Mappings[0].Match.MatchString("tank/a"))
+				assert.False(t, m.receiverConfig.Mappings[0].Match.MatchString("other/tank/a"), "match must be anchored")
+				assert.Equal(t, []string{"db1"}, m.receiverConfig.Mappings[1].Clients)
+				assert.Equal(t, "db$1", m.receiverConfig.Mappings[1].Replace)
+			},
+		},
+		{
+			name: "shared_replace_without_client",
+			input: `
+  - clients: [db1, db2]
+    match: "zroot/var/db(/.*)?"
+    replace: "db$1"
+`,
+			expectError: true,
+		},
+		{
+			name: "all_clients_replace_without_client",
+			input: `
+  - match: "tank/(.*)"
+    replace: "$1"
+`,
+			expectError: true,
+		},
+		{
+			name: "invalid_regex",
+			input: `
+  - match: "tank/("
+    replace: "$1"
+`,
+			expectError: true,
+		},
+		{
+			name: "invalid_client_identity",
+			input: `
+  - clients: ["with/slash"]
+    match: "tank/(.*)"
+    replace: "$1"
+`,
+			expectError: true,
+		},
+	}
+
+	fill := func(s string) string { return fmt.Sprintf(tmpl, s) }
+
+	for _, ts := range tests {
+		t.Run(ts.name, func(t *testing.T) {
+			assert.True(t, (ts.expectError) != (ts.expectOk != nil))
+
+			cstr := fill(ts.input)
+			t.Logf("testing config:\n%s", cstr)
+			c, err := config.ParseConfigBytes([]byte(cstr))
+			require.NoError(t, err)
+			jobs, err := JobsFromConfig(c, config.ParseFlagsNone)
+			if ts.expectOk != nil {
+				require.NoError(t, err)
+				require.Len(t, jobs, 1)
+				p := jobs[0].(*PassiveSide)
+				m := p.mode.(*modeSink)
+				ts.expectOk(t, m)
+			} else {
+				t.Logf("error: %s", err)
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestSinkRecvPropertiesInheritAndOverrideConflict(t *testing.T) {
+	tmpl := `
+jobs:
+- name: sink
+  type: sink
+  root_fs: "pool/sink"
+  serve:
+    type: local
+    listener_name: sink
+  recv:
+    properties:
+      inherit: [ "mountpoint", "sharenfs" ]
+      override: { %s }
+`
+	for _, tc := range []struct {
+		override    string
+		expectError bool
+	}{
+		{`"canmount": "off"`, false},
+		{`"mountpoint": "none"`, true},
+	} {
+		t.Run(tc.override, func(t *testing.T) {
+			c, err := config.ParseConfigBytes([]byte(fmt.Sprintf(tmpl, tc.override)))
+			require.NoError(t, err)
+			_, err = JobsFromConfig(c, config.ParseFlagsNone)
+			if tc.expectError {
+				t.Logf("error: %s", err)
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSendStepHoldsFrom(t *testing.T) {
+	tmpl := `
+jobs:
+- name: foo
+  type: push
+  connect:
+    type: local
+    listener_name: foo
+    client_identity: bar
+  filesystems: {"<": true}
+  %s
+  snapshotting:
+    type: manual
+  pruning:
+    keep_sender:
+    - type: not_replicated
+    keep_receiver:
+    - type: last_n
+      count: 10
+`
+	for _, tc := range []struct {
+		input          string
+		expectBookmark bool
+		expectError    bool
+	}{
+		{``, false, false},
+		{"send:\n    step_holds:\n      from: hold", false, false},
+		{"send:\n    step_holds:\n      from: bookmark", true, false},
+		{"send:\n    step_holds:\n      from: nothing", false, true},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			c, err := config.ParseConfigBytes([]byte(fmt.Sprintf(tmpl, tc.input)))
+			require.NoError(t, err)
+			jobs, err := JobsFromConfig(c, config.ParseFlagsNone)
+			if tc.expectError {
+				t.Logf("error: %s", err)
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			m := jobs[0].(*ActiveSide).mode.(*modePush)
+			assert.Equal(t, tc.expectBookmark, m.senderConfig.StepHoldFromBookmark)
+		})
+	}
+}
+
+func TestRecvMinFreeSpace(t *testing.T) {
+	tmpl := `
+jobs:
+- name: sink
+  type: sink
+  root_fs: "pool/sink"
+  serve:
+    type: local
+    listener_name: sink
+  %s
+`
+	for _, tc := range []struct {
+		input  string
+		expect uint64
+	}{
+		{``, 0},
+		{"recv:\n    min_free_space: 10 GiB", 10 << 30},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			c, err := config.ParseConfigBytes([]byte(fmt.Sprintf(tmpl, tc.input)))
+			require.NoError(t, err)
+			jobs, err := JobsFromConfig(c, config.ParseFlagsNone)
+			require.NoError(t, err)
+			m := jobs[0].(*PassiveSide).mode.(*modeSink)
+			assert.Equal(t, tc.expect, m.receiverConfig.MinFreeSpace)
+		})
+	}
+}
+
+func TestRecvForceReceive(t *testing.T) {
+	tmpl := `
+jobs:
+- name: sink
+  type: sink
+  root_fs: "pool/sink"
+  serve:
+    type: local
+    listener_name: sink
+  %s
+`
+	for _, tc := range []struct {
+		input       string
+		expect      endpoint.ForceReceivePolicy
+		expectError bool
+	}{
+		{``, endpoint.ForceReceivePlaceholders, false},
+		{"recv:\n    force_receive: never", endpoint.ForceReceiveNever, false},
+		{"recv:\n    force_receive: no_newer_snapshots", endpoint.ForceReceiveNoNewerSnapshots, false},
+		{"recv:\n    force_receive: always", endpoint.ForceReceiveAlways, false},
+		{"recv:\n    force_receive: sometimes", 0, true},
+		{"append_only: true\n  recv:\n    force_receive: no_newer_snapshots", endpoint.ForceReceiveNoNewerSnapshots, false},
+		{"append_only: true\n  recv:\n    force_receive: always", 0, true},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			c, err := config.ParseConfigBytes([]byte(fmt.Sprintf(tmpl, tc.input)))
+			require.NoError(t, err)
+			jobs, err := JobsFromConfig(c, config.ParseFlagsNone)
+			if tc.expectError {
+				t.Logf("error: %s", err)
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			m := jobs[0].(*PassiveSide).mode.(*modeSink)
+			assert.Equal(t, tc.expect, m.receiverConfig.ForceReceive)
+		})
+	}
+}
+
+func TestRecvEncryptOnReceive(t *testing.T) {
+	tmpl := `
+jobs:
+- name: sink
+  type: sink
+  root_fs: "pool/sink"
+  serve:
+    type: local
+    listener_name: sink
+  %s
+`
+	for _, tc := range []struct {
+		input       string
+		expect      bool
+		expectError bool
+	}{
+		{``, false, false},
+		{"recv:\n    encrypt_on_receive: true", true, false},
+		{"recv:\n    encrypt_on_receive: true\n    placeholder:\n      encryption: inherit", true, false},
+		{"recv:\n    encrypt_on_receive: true\n    placeholder:\n      encryption: off", false, true},
+		{"recv:\n    encrypt_on_receive: true\n    properties:\n      inherit: [encryption]", false, true},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			c, err := config.ParseConfigBytes([]byte(fmt.Sprintf(tmpl, tc.input)))
+			require.NoError(t, err)
+			jobs, err := JobsFromConfig(c, config.ParseFlagsNone)
+			if tc.expectError {
+				t.Logf("error: %s", err)
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			m := jobs[0].(*PassiveSide).mode.(*modeSink)
+			assert.Equal(t, tc.expect, m.receiverConfig.EncryptOnReceive)
+		})
+	}
+}
+
+func TestPerFilesystemMetrics(t *testing.T) {
+	tmpl := `
+%s
+jobs:
+- name: foo
+  type: push
+  connect:
+    type: local
+    listener_name: sink
+    client_identity: bar
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep_sender:
+    - type: not_replicated
+    keep_receiver:
+    - type: last_n
+      count: 10
+- name: sink
+  type: sink
+  root_fs: "pool/sink"
+  serve:
+    type: local
+    listener_name: sink
+`
+	for _, tc := range []struct {
+		input  string
+		expect bool
+	}{
+		{``, true},
+		{"global:\n  monitoring:\n  - type: prometheus\n    listen: ':9811'", true},
+		{"global:\n  monitoring:\n  - type: prometheus\n    listen: ':9811'\n    per_filesystem_metrics: false", false},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			c, err := config.ParseConfigBytes([]byte(fmt.Sprintf(tmpl, tc.input)))
+			require.NoError(t, err)
+			jobs, err := JobsFromConfig(c, config.ParseFlagsNone)
+			require.NoError(t, err)
+			push := jobs[0].(*ActiveSide).mode.(*modePush)
+			assert.Equal(t, tc.expect, push.senderConfig.PerFilesystemMetrics)
+			assert.Equal(t, tc.expect, push.plannerPolicy.PerFilesystemMetrics)
+			sink := jobs[1].(*PassiveSide).mode.(*modeSink)
+			assert.Equal(t, tc.expect, sink.receiverConfig.PerFilesystemMetrics)
+		})
+	}
+}
+
+func TestJobDependencies(t *testing.T) {
+	job := func(name string, after ...string) string {
+		afterStr := ""
+		if len(after) > 0 {
+			afterStr = fmt.Sprintf("  after: [%s]\n", strings.Join(after, ", "))
+		}
+		return fmt.Sprintf(`
+- name: %s
+  type: push
+  connect:
+    type: local
+    listener_name: %s
+    client_identity: bar
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep_sender:
+    - type: not_replicated
+    keep_receiver:
+    - type: last_n
+      count: 10
+%s`, name, name, afterStr)
+	}
+	build := func(jobs ...string) ([]Job, error) {
+		c, err := config.ParseConfigBytes([]byte("jobs:" + strings.Join(jobs, "")))
+		require.NoError(t, err)
+		return JobsFromConfig(c, config.ParseFlagsNone)
+	}
+
+	jobs, err := build(job("local"), job("offsite", "local"), job("archive", "local", "offsite"))
+	require.NoError(t, err)
+	local, offsite, archive := jobs[0].(*ActiveSide), jobs[1].(*ActiveSide), jobs[2].(*ActiveSide)
+	assert.Equal(t, []string{"archive", "offsite"}, local.dependentNames())
+	assert.Equal(t, []string{"local", "offsite"}, archive.deps.after)
+
+	// archive is triggered once both of its dependencies completed successfully
+	assert.True(t, offsite.deps.dependencyDone("local"))
+	assert.False(t, archive.deps.dependencyDone("local"))
+	assert.Equal(t, []string{"offsite"}, archive.deps.waitingFor())
+	assert.True(t, archive.deps.dependencyDone("offsite"))
+	assert.Len(t, archive.deps.trigger, 1)
+	assert.Equal(t, []string{"local", "offsite"}, archive.deps.waitingFor())
+
+	for name, jobs := range map[string][]string{
+		"unknown":   {job("a", "b")},
+		"self":      {job("a", "a")},
+		"duplicate": {job("a"), job("b", "a", "a")},
+		"cycle":     {job("a", "c"), job("b", "a"), job("c", "b")},
+	} {
+		_, err := build(jobs...)
+		t.Logf("%s: %s", name, err)
+		assert.Error(t, err, name)
+	}
+	_, err = build(job("a", "c"), job("b", "a"), job("c", "b"))
+	assert.Contains(t, err.Error(), "a -> c -> b -> a")
+}
+
+func TestDerivedJobIDCollisions(t *testing.T) {
+	push := func(name string, destinations ...string) string {
+		s := fmt.Sprintf(`
+- name: %s
+  type: push
+  connect:
+    type: local
+    listener_name: %s
+    client_identity: bar
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep_sender:
+    - type: not_replicated
+    keep_receiver:
+    - type: last_n
+      count: 10
+`, name, name)
+		if len(destinations) > 0 {
+			s += "  destinations:\n"
+		}
+		for _, d := range destinations {
+			s += fmt.Sprintf(`  - name: %s
+    connect:
+      type: local
+      listener_name: %s
+      client_identity: bar
+`, d, d)
+		}
+		return s
+	}
+	pull := func(name, source string) string {
+		return fmt.Sprintf(`
+- name: %s
+  type: pull
+  connect:
+    type: local
+    listener_name: %s
+    client_identity: bar
+  root_fs: zroot/pull/%s
+  interval: manual
+  sources:
+  - name: %s
+    connect:
+      type: local
+      listener_name: %s
+      client_identity: bar
+    root_fs: zroot/pull/%s_%s
+  pruning:
+    keep_sender:
+    - type: not_replicated
+    keep_receiver:
+    - type: last_n
+      count: 10
+`, name, name, name, source, source, name, source)
+	}
+	build := func(jobs ...string) ([]Job, error) {
+		c, err := config.ParseConfigBytes([]byte("jobs:" + strings.Join(jobs, "")))
+		require.NoError(t, err)
+		return JobsFromConfig(c, config.ParseFlagsNone)
+	}
+
+	_, err := build(push("a", "b"), push("a_c", "d"), pull("p", "q"))
+	require.NoError(t, err)
+
+	for name, jobs := range map[string][]string{
+		"destination_vs_job":         {push("a", "b_c"), push("a_b_c")},
+		"job_vs_destination":         {push("a_b_c"), push("a", "b_c")},
+		"destination_vs_destination": {push("a", "b_c"), push("a_b", "c")},
+		"source_vs_job":              {pull("p", "q"), push("p_q")},
+		"source_vs_destination":      {pull("p", "q_r"), push("p_q", "r")},
+	} {
+		_, err := build(jobs...)
+		t.Logf("%s: %s", name, err)
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,38 @@
+// Package disable lets the daemon suspend the scheduling of a job at runtime.
+package disable
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type contextKey int
+
+const contextKeyDisable contextKey = iota
+
+// Switch disables and enables the job of the context returned by Context.
+type Switch struct {
+	disabled int32
+}
+
+// Set returns false if the job already was in the requested state.
+func (s *Switch) Set(disabled bool) (changed bool) {
+	var v int32
+	if disabled {
+		v = 1
+	}
+	return atomic.SwapInt32(&s.disabled, v) != v
+}
+
+func (s *Switch) Disabled() bool { return atomic.LoadInt32(&s.disabled) == 1 }
+
+// Disabled returns true if the job of ctx must skip its scheduled and manually triggered invocations.
+func Disabled(ctx context.Context) bool {
+	s, ok := ctx.Value(contextKeyDisable).(*Switch)
+	return ok && s.Disabled()
+}
+
+func Context(ctx context.Context) (context.Context, *Switch) {
+	s := &Switch{}
+	return context.WithValue(ctx, contextKeyDisable, s), s
+}
@@ -0,0 +1,21 @@
+// Package finished lets jobs notify the daemon that an invocation has finished,
+// e.g., so that the metrics can be pushed to a Pushgateway.
+package finished
+
+import "context"
+
+type contextKey int
+
+const contextKeyFinished contextKey = iota
+
+// Notify reports the end of an invocation of the job of ctx, regardless of its outcome.
+func Notify(ctx context.Context) {
+	if f, ok := ctx.Value(contextKeyFinished).(func()); ok {
+		f()
+	}
+}
+
+// Context returns a context for a job whose calls to Notify call f.
+func Context(ctx context.Context, f func()) context.Context {
+	return context.WithValue(ctx, contextKeyFinished, f)
+}
@@ -7,7 +7,9 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/zrepl/zrepl/daemon/job/maintenance"
 	"github.com/zrepl/zrepl/daemon/logging"
+	"github.com/zrepl/zrepl/daemon/snapper"
 	"github.com/zrepl/zrepl/endpoint"
 	"github.com/zrepl/zrepl/logger"
 	"github.com/zrepl/zrepl/zfs"
@@ -28,6 +30,9 @@ type Job interface {
 	// must return the root of that subtree as rfs and ok = true
 	OwnedDatasetSubtreeRoot() (rfs *zfs.DatasetPath, ok bool)
 	SenderConfig() *endpoint.SenderConfig
+	// Jobs that create snapshots must return their snapper
+	// and the filter for the snapshotted filesystems and ok = true
+	Snapper() (s snapper.Snapper, fsf zfs.DatasetFilter, ok bool)
 }
 
 type Type string
@@ -39,11 +44,17 @@ const (
 	TypeSink     Type = "sink"
 	TypePull     Type = "pull"
 	TypeSource   Type = "source"
+	// see PoolMonitorJob
+	TypePoolMonitor Type = "pool_monitor"
 )
 
 type Status struct {
 	Type        Type
 	JobSpecific interface{}
+	// set by the daemon, see `zrepl job disable`
+	Disabled bool
+	// set by the daemon, see `zrepl job maintenance`
+	Maintenance maintenance.State
 }
 
 func (s *Status) MarshalJSON() ([]byte, error) {
@@ -59,6 +70,16 @@ func (s *Status) MarshalJSON() ([]byte, error) {
 		"type":         typeJson,
 		string(s.Type): jobJSON,
 	}
+	if s.Disabled {
+		m["disabled"] = json.RawMessage("true")
+	}
+	if s.Maintenance.Active {
+		maintenanceJSON, err := json.Marshal(s.Maintenance)
+		if err != nil {
+			return nil, err
+		}
+		m["maintenance"] = maintenanceJSON
+	}
 	return json.Marshal(m)
 }
 
@@ -74,6 +95,16 @@ func (s *Status) UnmarshalJSON(in []byte) (err error) {
 	if err := json.Unmarshal(tJSON, &s.Type); err != nil {
 		return err
 	}
+	if dJSON, ok := m["disabled"]; ok {
+		if err := json.Unmarshal(dJSON, &s.Disabled); err != nil {
+			return err
+		}
+	}
+	if mJSON, ok := m["maintenance"]; ok {
+		if err := json.Unmarshal(mJSON, &s.Maintenance); err != nil {
+			return err
+		}
+	}
 	key := string(s.Type)
 	jobJSON, ok := m[key]
 	if !ok {
@@ -99,6 +130,11 @@ func (s *Status) UnmarshalJSON(in []byte) (err error) {
 		err = json.Unmarshal(jobJSON, &st)
 		s.JobSpecific = &st
 
+	case TypePoolMonitor:
+		var st PoolMonitorStatus
+		err = json.Unmarshal(jobJSON, &st)
+		s.JobSpecific = &st
+
 	case TypeInternal:
 		// internal jobs do not report specifics
 	default:
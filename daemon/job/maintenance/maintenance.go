@@ -0,0 +1,95 @@
+// Package maintenance lets the daemon and the job's configured windows put a job into maintenance mode,
+// during which new invocations are skipped while in-flight invocations complete.
+package maintenance
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type contextKey int
+
+const contextKeyMaintenance contextKey = iota
+
+// State is the maintenance state of a job at a point in time.
+type State struct {
+	Active bool
+	// the end of the maintenance, zero if it lasts until `zrepl job maintenance end`
+	Until time.Time `json:",omitempty"`
+	// the maintenance is due to a configured window, not `zrepl job maintenance start`
+	Scheduled bool `json:",omitempty"`
+}
+
+// Switch is the maintenance state of the job of the context returned by Context.
+type Switch struct {
+	mtx         sync.Mutex
+	manual      bool
+	manualUntil time.Time
+	// returns the end of the configured window that contains t, or the zero value
+	windowEnd func(t time.Time) time.Time
+}
+
+// Start puts the job into maintenance until the given time, or until End if until is zero.
+func (s *Switch) Start(until time.Time) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.manual, s.manualUntil = true, until
+}
+
+// End ends the maintenance started with Start. Configured windows are not affected.
+// Returns false if the job was not in maintenance started with Start.
+func (s *Switch) End() (changed bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	changed = s.manualActive(time.Now())
+	s.manual, s.manualUntil = false, time.Time{}
+	return changed
+}
+
+func (s *Switch) manualActive(now time.Time) bool {
+	return s.manual && (s.manualUntil.IsZero() || now.Before(s.manualUntil))
+}
+
+// SetWindows sets the job's configured maintenance windows, see State.
+func (s *Switch) SetWindows(windowEnd func(t time.Time) time.Time) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.windowEnd = windowEnd
+}
+
+// State returns the state at now. Maintenance started with Start takes precedence over configured windows.
+func (s *Switch) State(now time.Time) State {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.manualActive(now) {
+		return State{Active: true, Until: s.manualUntil}
+	}
+	if s.windowEnd != nil {
+		if end := s.windowEnd(now); !end.IsZero() {
+			return State{Active: true, Until: end, Scheduled: true}
+		}
+	}
+	return State{}
+}
+
+// Get returns the current maintenance state of the job of ctx.
+func Get(ctx context.Context) State {
+	s, ok := ctx.Value(contextKeyMaintenance).(*Switch)
+	if !ok {
+		return State{}
+	}
+	return s.State(time.Now())
+}
+
+// SetWindows sets the configured maintenance windows of the job of ctx, if the daemon manages its maintenance state.
+func SetWindows(ctx context.Context, windowEnd func(t time.Time) time.Time) {
+	if s, ok := ctx.Value(contextKeyMaintenance).(*Switch); ok {
+		s.SetWindows(windowEnd)
+	}
+}
+
+func Context(ctx context.Context) (context.Context, *Switch) {
+	s := &Switch{}
+	return context.WithValue(ctx, contextKeyMaintenance, s), s
+}
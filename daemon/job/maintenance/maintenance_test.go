@@ -0,0 +1,52 @@
+package maintenance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSwitch(t *testing.T) {
+	now := time.Now()
+	windowEnd := now.Add(time.Hour)
+	inWindow := false
+	s := &Switch{}
+	s.SetWindows(func(t time.Time) time.Time {
+		if inWindow {
+			return windowEnd
+		}
+		return time.Time{}
+	})
+
+	assert.Equal(t, State{}, s.State(now))
+	inWindow = true
+	assert.Equal(t, State{Active: true, Until: windowEnd, Scheduled: true}, s.State(now))
+	inWindow = false
+
+	s.Start(time.Time{})
+	assert.Equal(t, State{Active: true}, s.State(now))
+	assert.True(t, s.End())
+	assert.False(t, s.End())
+	assert.Equal(t, State{}, s.State(now))
+
+	until := now.Add(time.Minute)
+	s.Start(until)
+	assert.Equal(t, State{Active: true, Until: until}, s.State(now))
+	assert.Equal(t, State{}, s.State(until), "maintenance ends at until")
+
+	// manual maintenance takes precedence over windows
+	inWindow = true
+	assert.Equal(t, State{Active: true, Until: until}, s.State(now))
+}
+
+func TestGet(t *testing.T) {
+	assert.Equal(t, State{}, Get(context.Background()))
+	SetWindows(context.Background(), nil) // must not panic without a Switch
+
+	ctx, s := Context(context.Background())
+	assert.False(t, Get(ctx).Active)
+	s.Start(time.Time{})
+	assert.True(t, Get(ctx).Active)
+}
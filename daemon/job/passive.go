@@ -10,19 +10,26 @@ import (
 	"github.com/zrepl/zrepl/daemon/logging/trace"
 
 	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/job/ready"
 	"github.com/zrepl/zrepl/daemon/logging"
 	"github.com/zrepl/zrepl/daemon/snapper"
 	"github.com/zrepl/zrepl/endpoint"
 	"github.com/zrepl/zrepl/rpc"
+	"github.com/zrepl/zrepl/rpc/versionhandshake"
 	"github.com/zrepl/zrepl/transport"
 	"github.com/zrepl/zrepl/transport/fromconfig"
 	"github.com/zrepl/zrepl/zfs"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
 )
 
 type PassiveSide struct {
 	mode   passiveMode
 	name   endpoint.JobID
 	listen transport.AuthenticatedListenerFactory
+
+	processLimits *zfscmd.ProcessLimits // nil if not configured
+
+	promPhaseSecs *prometheus.HistogramVec // labels: phase
 }
 
 type passiveMode interface {
@@ -34,6 +41,7 @@ type passiveMode interface {
 
 type modeSink struct {
 	receiverConfig endpoint.ReceiverConfig
+	usage          *clientUsage
 }
 
 func (m *modeSink) Type() Type { return TypeSink }
@@ -42,17 +50,56 @@ func (m *modeSink) Handler() rpc.Handler {
 	return endpoint.NewReceiver(m.receiverConfig)
 }
 
-func (m *modeSink) RunPeriodic(_ context.Context)  {}
-func (m *modeSink) SnapperReport() *snapper.Report { return nil }
+func (m *modeSink) RunPeriodic(ctx context.Context) { m.usage.run(ctx) }
+func (m *modeSink) SnapperReport() *snapper.Report  { return nil }
 
 func modeSinkFromConfig(g *config.Global, in *config.SinkJob, jobID endpoint.JobID) (m *modeSink, err error) {
 	m = &modeSink{}
 
-	m.receiverConfig, err = buildReceiverConfig(in, jobID)
+	m.receiverConfig, err = buildReceiverConfig(g, in, jobID)
 	if err != nil {
 		return nil, err
 	}
 
+	if in.ClientQuotas != nil {
+		m.receiverConfig.DefaultClientQuota, m.receiverConfig.ClientQuotas, err = buildClientQuotas(in.ClientQuotas)
+		if err != nil {
+			return nil, errors.Wrap(err, "field `client_quotas`")
+		}
+		if err := m.receiverConfig.Validate(); err != nil {
+			return nil, errors.Wrap(err, "cannot build receiver config")
+		}
+	}
+
+	if in.ClientLimits != nil {
+		m.receiverConfig.DefaultClientLimits, m.receiverConfig.ClientLimits, err = buildClientLimits(in.ClientLimits)
+		if err != nil {
+			return nil, errors.Wrap(err, "field `client_limits`")
+		}
+		if err := m.receiverConfig.Validate(); err != nil {
+			return nil, errors.Wrap(err, "cannot build receiver config")
+		}
+	}
+
+	if len(in.Mappings) > 0 {
+		m.receiverConfig.Mappings, err = buildSinkMappings(m.receiverConfig.RootWithoutClientComponent, in.Mappings)
+		if err != nil {
+			return nil, errors.Wrap(err, "field `mappings`")
+		}
+		if err := m.receiverConfig.Validate(); err != nil {
+			return nil, errors.Wrap(err, "cannot build receiver config")
+		}
+	}
+
+	if in.AppendOnly {
+		m.receiverConfig.AppendOnly = true
+		if err := m.receiverConfig.Validate(); err != nil {
+			return nil, errors.Wrap(err, "field `append_only`")
+		}
+	}
+
+	m.usage = newClientUsage(jobID.String(), m.receiverConfig)
+
 	return m, nil
 }
 
@@ -65,12 +112,12 @@ func modeSourceFromConfig(g *config.Global, in *config.SourceJob, jobID endpoint
 	// FIXME exact dedup of modePush
 	m = &modeSource{}
 
-	m.senderConfig, err = buildSenderConfig(in, jobID)
+	m.senderConfig, err = buildSenderConfig(g, in, jobID)
 	if err != nil {
 		return nil, errors.Wrap(err, "send options")
 	}
 
-	if m.snapper, err = snapper.FromConfig(g, m.senderConfig.FSF, in.Snapshotting); err != nil {
+	if m.snapper, err = snapper.FromConfig(g, jobID.String(), m.senderConfig.FSF, in.Snapshotting); err != nil {
 		return nil, errors.Wrap(err, "cannot build snapper")
 	}
 
@@ -100,6 +147,7 @@ func passiveSideFromConfig(g *config.Global, in *config.PassiveJob, configJob in
 	if err != nil {
 		return nil, errors.Wrap(err, "invalid job name")
 	}
+	s.promPhaseSecs = newPhaseSecs(s.name.String())
 
 	switch v := configJob.(type) {
 	case *config.SinkJob:
@@ -115,6 +163,10 @@ func passiveSideFromConfig(g *config.Global, in *config.PassiveJob, configJob in
 		return nil, errors.Wrap(err, "cannot build listener factory")
 	}
 
+	if s.processLimits, err = ProcessLimitsFromConfig(in.ProcessLimits); err != nil {
+		return nil, errors.Wrap(err, "invalid `process_limits`")
+	}
+
 	return s, nil
 }
 
@@ -122,11 +174,19 @@ func (j *PassiveSide) Name() string { return j.name.String() }
 
 type PassiveStatus struct {
 	Snapper *snapper.Report
+	// the versions of the clients that have connected, see versionhandshake.Peers
+	Peers []versionhandshake.PeerReport `json:",omitempty"`
+	// only for sink jobs
+	ClientUsage *ClientUsageStatus `json:",omitempty"`
 }
 
 func (s *PassiveSide) Status() *Status {
 	st := &PassiveStatus{
 		Snapper: s.mode.SnapperReport(),
+		Peers:   versionhandshake.JobPeers(s.Name()),
+	}
+	if sink, ok := s.mode.(*modeSink); ok {
+		st.ClientUsage = sink.usage.report()
 	}
 	return &Status{Type: s.mode.Type(), JobSpecific: st}
 }
@@ -149,11 +209,28 @@ func (j *PassiveSide) SenderConfig() *endpoint.SenderConfig {
 	return source.senderConfig
 }
 
-func (*PassiveSide) RegisterMetrics(registerer prometheus.Registerer) {}
+func (j *PassiveSide) Snapper() (snapper.Snapper, zfs.DatasetFilter, bool) {
+	source, ok := j.mode.(*modeSource)
+	if !ok {
+		_ = j.mode.(*modeSink) // make sure we didn't introduce a new job type
+		return nil, nil, false
+	}
+	return source.snapper, source.senderConfig.FSF, true
+}
+
+func (j *PassiveSide) RegisterMetrics(registerer prometheus.Registerer) {
+	registerer.MustRegister(j.promPhaseSecs)
+	if sink, ok := j.mode.(*modeSink); ok {
+		sink.usage.metrics.register(registerer)
+	}
+}
 
 func (j *PassiveSide) Run(ctx context.Context) {
 	ctx, endTask := trace.WithTaskAndSpan(ctx, "passive-side-job", j.Name())
 	defer endTask()
+	ctx = zfscmd.WithProcessLimits(ctx, j.processLimits)
+	// only source jobs snapshot
+	ctx = snapper.WithDurationObserver(ctx, j.promPhaseSecs.WithLabelValues(phaseSnapshotting))
 	log := GetLogger(ctx)
 	defer log.Info("job exiting")
 	{
@@ -173,6 +250,7 @@ func (j *PassiveSide) Run(ctx context.Context) {
 		// the handlerCtx is clean => need to inherit logging and tracing config from job context
 		handlerCtx = logging.WithInherit(handlerCtx, ctx)
 		handlerCtx = trace.WithInherit(handlerCtx, ctx)
+		handlerCtx = zfscmd.WithProcessLimits(handlerCtx, j.processLimits)
 
 		handlerCtx, endTask := trace.WithTaskAndSpan(handlerCtx, "handler", fmt.Sprintf("job=%q client=%q method=%q", j.Name(), info.ClientIdentity(), info.FullMethod()))
 		defer endTask()
@@ -180,13 +258,14 @@ func (j *PassiveSide) Run(ctx context.Context) {
 	}
 
 	rpcLoggers := rpc.GetLoggersOrPanic(ctx) // WithSubsystemLoggers above
-	server := rpc.NewServer(handler, rpcLoggers, ctxInterceptor)
+	server := rpc.NewServer(j.Name(), handler, rpcLoggers, ctxInterceptor)
 
 	listener, err := j.listen()
 	if err != nil {
 		log.WithError(err).Error("cannot listen")
 		return
 	}
+	ready.Signal(ctx)
 
 	server.Serve(ctx, listener)
 }
@@ -0,0 +1,129 @@
+package job
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/util/envconst"
+)
+
+var clientUsageInterval = envconst.Duration("ZREPL_SINK_CLIENT_USAGE_INTERVAL", 5*time.Minute)
+
+// ClientUsageStatus is the space consumed by the client identities of a sink job.
+type ClientUsageStatus struct {
+	// zero before the first collection
+	LastCollection time.Time
+	// sorted by client identity, the clients of the latest successful collection if the last one failed
+	Clients []endpoint.ClientUsage `json:",omitempty"`
+	// set if the last collection failed
+	Error string `json:",omitempty"`
+}
+
+// clientUsage periodically collects the ClientUsageStatus of a sink job.
+type clientUsage struct {
+	receiverConfig endpoint.ReceiverConfig
+	metrics        *clientUsageMetrics
+	// for tests
+	list func(ctx context.Context, c endpoint.ReceiverConfig) ([]endpoint.ClientUsage, error)
+
+	mtx    sync.Mutex
+	status ClientUsageStatus
+}
+
+func newClientUsage(jobName string, c endpoint.ReceiverConfig) *clientUsage {
+	return &clientUsage{
+		receiverConfig: c,
+		metrics:        newClientUsageMetrics(jobName),
+		list:           endpoint.ListClientUsage,
+	}
+}
+
+func (u *clientUsage) run(ctx context.Context) {
+	t := time.NewTicker(clientUsageInterval)
+	defer t.Stop()
+	for {
+		u.collect(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (u *clientUsage) collect(ctx context.Context) {
+	clients, err := u.list(ctx, u.receiverConfig)
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	u.status.LastCollection = time.Now()
+	if err != nil {
+		GetLogger(ctx).WithError(err).Error("cannot collect client usage")
+		u.status.Error = err.Error()
+		return
+	}
+	u.status.Error = ""
+	u.status.Clients = clients
+	u.metrics.update(clients)
+}
+
+func (u *clientUsage) report() *ClientUsageStatus {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	s := u.status
+	return &s
+}
+
+// clientUsageMetrics are the gauges of the client identities of a sink job, labels: client.
+type clientUsageMetrics struct {
+	used, usedBySnapshots, logicalReferenced *prometheus.GaugeVec
+
+	// clients of the last update, to delete the series of vanished clients
+	reported map[string]bool
+}
+
+func newClientUsageMetrics(jobName string) *clientUsageMetrics {
+	gauge := func(name, help string) *prometheus.GaugeVec {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   "zrepl",
+			Subsystem:   "sink",
+			Name:        name,
+			Help:        help,
+			ConstLabels: prometheus.Labels{"zrepl_job": jobName},
+		}, []string{"client"})
+	}
+	return &clientUsageMetrics{
+		used:              gauge("client_used_bytes", "used property of the client identity's client root, including descendants and snapshots"),
+		usedBySnapshots:   gauge("client_used_by_snapshots_bytes", "sum of the usedbysnapshots property of the filesystems below the client root"),
+		logicalReferenced: gauge("client_logical_referenced_bytes", "sum of the logicalreferenced property of the filesystems below the client root"),
+		reported:          make(map[string]bool),
+	}
+}
+
+func (m *clientUsageMetrics) register(registerer prometheus.Registerer) {
+	registerer.MustRegister(m.used)
+	registerer.MustRegister(m.usedBySnapshots)
+	registerer.MustRegister(m.logicalReferenced)
+}
+
+// update is only called by collect, i.e., not concurrently
+func (m *clientUsageMetrics) update(clients []endpoint.ClientUsage) {
+	reported := make(map[string]bool, len(clients))
+	for _, c := range clients {
+		reported[c.ClientIdentity] = true
+		m.used.WithLabelValues(c.ClientIdentity).Set(float64(c.Used))
+		m.usedBySnapshots.WithLabelValues(c.ClientIdentity).Set(float64(c.UsedBySnapshots))
+		m.logicalReferenced.WithLabelValues(c.ClientIdentity).Set(float64(c.LogicalReferenced))
+	}
+	for client := range m.reported {
+		if !reported[client] {
+			m.used.DeleteLabelValues(client)
+			m.usedBySnapshots.DeleteLabelValues(client)
+			m.logicalReferenced.DeleteLabelValues(client)
+		}
+	}
+	m.reported = reported
+}
@@ -0,0 +1,53 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/endpoint"
+)
+
+func TestClientUsage(t *testing.T) {
+	clients := []endpoint.ClientUsage{
+		{ClientIdentity: "laptop", Used: 300, UsedBySnapshots: 10, LogicalReferenced: 400},
+		{ClientIdentity: "prod", Used: 600, UsedBySnapshots: 250, LogicalReferenced: 900},
+	}
+	var listErr error
+	u := newClientUsage("sink", endpoint.ReceiverConfig{})
+	u.list = func(ctx context.Context, c endpoint.ReceiverConfig) ([]endpoint.ClientUsage, error) {
+		return clients, listErr
+	}
+	r := prometheus.NewRegistry()
+	u.metrics.register(r)
+
+	assert.True(t, u.report().LastCollection.IsZero())
+	u.collect(context.Background())
+	st := u.report()
+	assert.Equal(t, clients, st.Clients)
+	assert.Empty(t, st.Error)
+	assert.Equal(t, 600.0, testutil.ToFloat64(u.metrics.used.WithLabelValues("prod")))
+	assert.Equal(t, 250.0, testutil.ToFloat64(u.metrics.usedBySnapshots.WithLabelValues("prod")))
+	assert.Equal(t, 400.0, testutil.ToFloat64(u.metrics.logicalReferenced.WithLabelValues("laptop")))
+
+	listErr = errors.New("zfs list failed")
+	u.collect(context.Background())
+	st = u.report()
+	assert.Equal(t, "zfs list failed", st.Error)
+	assert.Len(t, st.Clients, 2, "the clients of the last successful collection are kept")
+
+	listErr = nil
+	clients = clients[1:]
+	u.collect(context.Background())
+	assert.Empty(t, u.report().Error)
+	mfs, err := r.Gather()
+	require.NoError(t, err)
+	for _, mf := range mfs {
+		assert.Len(t, mf.GetMetric(), 1, "the series of vanished clients are deleted: %s", mf.GetName())
+	}
+}
@@ -0,0 +1,65 @@
+package job
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The values of the phase label of the zrepl_job_phase_time histogram.
+const (
+	phaseSnapshotting = "snapshotting"
+	phasePlanning     = "planning"
+	phaseReplication  = "replication"
+	phasePruning      = "pruning"
+)
+
+// newPhaseSecs returns the histogram of the seconds an invocation of the job spends in each phase, labels: phase.
+func newPhaseSecs(jobName string) *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   "zrepl",
+		Subsystem:   "job",
+		Name:        "phase_time",
+		Help:        "seconds an invocation spent in the phase (snapshotting, planning, replication, pruning)",
+		ConstLabels: prometheus.Labels{"zrepl_job": jobName},
+		// like the replication steps, phases take anywhere from seconds to days
+		Buckets: prometheus.ExponentialBuckets(1, 4, 10),
+	}, []string{"phase"})
+}
+
+// phaseTimes accumulates the time an invocation spends in each phase,
+// e.g. the replication phase of a push job covers the replication to all of its destinations.
+// Planning happens during replication and thus is part of the replication phase, too.
+type phaseTimes struct {
+	mtx  sync.Mutex
+	secs map[string]float64 // by phase
+}
+
+// start returns the function that ends the phase, it may be called more than once.
+func (t *phaseTimes) start(phase string) (end func()) {
+	start := time.Now()
+	var once sync.Once
+	return func() {
+		once.Do(func() { t.add(phase, time.Since(start)) })
+	}
+}
+
+func (t *phaseTimes) add(phase string, d time.Duration) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.secs == nil {
+		t.secs = make(map[string]float64)
+	}
+	t.secs[phase] += d.Seconds()
+}
+
+// observe observes the time of each phase that was entered since the last call of observe.
+func (t *phaseTimes) observe(h *prometheus.HistogramVec) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	for phase, secs := range t.secs {
+		h.WithLabelValues(phase).Observe(secs)
+	}
+	t.secs = nil
+}
@@ -0,0 +1,74 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/replication/driver"
+)
+
+type sleepingPlanner struct{ d time.Duration }
+
+func (p sleepingPlanner) Plan(ctx context.Context) ([]driver.FS, error) {
+	time.Sleep(p.d)
+	return nil, errors.New("planning failed")
+}
+
+func (p sleepingPlanner) WaitForConnectivity(ctx context.Context) error { return nil }
+
+func TestPhaseTimes(t *testing.T) {
+	h := newPhaseSecs("job")
+	r := prometheus.NewRegistry()
+	r.MustRegister(h)
+	sample := func(phase string) (n uint64, sum float64) {
+		mfs, err := r.Gather()
+		require.NoError(t, err)
+		for _, mf := range mfs {
+			for _, m := range mf.GetMetric() {
+				for _, l := range m.GetLabel() {
+					if l.GetName() == "phase" && l.GetValue() == phase {
+						return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+					}
+				}
+			}
+		}
+		return 0, 0
+	}
+
+	var phases phaseTimes
+	endReplication := phases.start(phaseReplication)
+	reconnects := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "reconnects"}, []string{"outcome"})
+	planner := instrumentedPlanner{sleepingPlanner{10 * time.Millisecond}, &phases, reconnects}
+	for i := 0; i < 2; i++ {
+		_, err := planner.Plan(context.Background())
+		assert.Error(t, err, "the wrapped planner's result is returned")
+	}
+	assert.NoError(t, planner.WaitForConnectivity(context.Background()))
+	assert.Equal(t, 1.0, testutil.ToFloat64(reconnects.WithLabelValues("success")))
+	endReplication()
+	endReplication()
+	phases.observe(h)
+
+	n, planning := sample(phasePlanning)
+	assert.Equal(t, uint64(1), n, "the attempts of an invocation are a single observation")
+	assert.True(t, planning >= 0.02, "%v", planning)
+	n, replication := sample(phaseReplication)
+	assert.Equal(t, uint64(1), n, "ending a phase twice adds it once")
+	assert.True(t, replication >= planning, "planning is part of replication")
+	assert.Equal(t, 2, testutil.CollectAndCount(h), "phases that were not entered are not observed")
+
+	// observe resets the phases
+	phases.start(phasePruning)()
+	phases.observe(h)
+	n, _ = sample(phaseReplication)
+	assert.Equal(t, uint64(1), n)
+	n, _ = sample(phasePruning)
+	assert.Equal(t, uint64(1), n)
+}
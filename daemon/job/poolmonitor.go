@@ -0,0 +1,297 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/job/disable"
+	"github.com/zrepl/zrepl/daemon/job/ready"
+	"github.com/zrepl/zrepl/daemon/job/wakeup"
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/daemon/snapper"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// PoolMonitorJob periodically collects the state of zpools for its status and metrics.
+type PoolMonitorJob struct {
+	name endpoint.JobID
+	// all imported pools if empty
+	pools    []string
+	interval time.Duration
+	metrics  *poolMetrics
+
+	// for tests
+	getStatus func(ctx context.Context, pool string) (*zfs.ZPoolStatus, error)
+	listPools func(ctx context.Context) ([]string, error)
+
+	mtx    sync.Mutex
+	status *PoolMonitorStatus
+}
+
+type PoolMonitorStatus struct {
+	// zero before the first collection
+	LastCollection time.Time
+	// the pools of the last collection, in the order of the config or sorted by name if the job collects all pools
+	Pools []*PoolMonitorPool `json:",omitempty"`
+	// set if the imported pools could not be listed
+	Error string `json:",omitempty"`
+}
+
+type PoolMonitorPool struct {
+	Name string
+	// nil if the pool's state could not be collected, see Error
+	Status *zfs.ZPoolStatus `json:",omitempty"`
+	Error  string           `json:",omitempty"`
+}
+
+// Problems returns the collection errors, the pools that are not ONLINE
+// and the pools whose latest scan found errors.
+func (s *PoolMonitorStatus) Problems() []string {
+	var problems []string
+	if s.Error != "" {
+		problems = append(problems, s.Error)
+	}
+	for _, p := range s.Pools {
+		switch {
+		case p.Status == nil:
+			problems = append(problems, fmt.Sprintf("pool %q: %s", p.Name, p.Error))
+		case p.Status.Health != "ONLINE":
+			problems = append(problems, fmt.Sprintf("pool %q is %s", p.Name, p.Status.Health))
+		}
+		if p.Status != nil && p.Status.Scan != nil && p.Status.Scan.Errors > 0 {
+			problems = append(problems, fmt.Sprintf("%s of pool %q found %d error(s)", p.Status.Scan.Function, p.Name, p.Status.Scan.Errors))
+		}
+	}
+	return problems
+}
+
+func poolMonitorJobFromConfig(in *config.PoolMonitorJob) (j *PoolMonitorJob, err error) {
+	j = &PoolMonitorJob{
+		pools:     in.Pools,
+		interval:  in.Interval,
+		getStatus: zfs.ZPoolGetStatus,
+		listPools: zfs.ZPoolList,
+		status:    &PoolMonitorStatus{},
+	}
+	j.name, err = endpoint.MakeJobID(in.Name)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid job name")
+	}
+	for _, pool := range in.Pools {
+		if err := zfs.ComponentNamecheck(pool); err != nil {
+			return nil, errors.Wrapf(err, "invalid pool name %q", pool)
+		}
+	}
+	j.metrics = newPoolMetrics(j.name.String())
+	return j, nil
+}
+
+func (j *PoolMonitorJob) Name() string { return j.name.String() }
+
+func (j *PoolMonitorJob) Type() Type { return TypePoolMonitor }
+
+func (j *PoolMonitorJob) RegisterMetrics(registerer prometheus.Registerer) {
+	j.metrics.register(registerer)
+}
+
+func (j *PoolMonitorJob) Status() *Status {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	s := *j.status
+	return &Status{Type: j.Type(), JobSpecific: &s}
+}
+
+func (j *PoolMonitorJob) OwnedDatasetSubtreeRoot() (rfs *zfs.DatasetPath, ok bool) {
+	return nil, false
+}
+
+func (j *PoolMonitorJob) SenderConfig() *endpoint.SenderConfig { return nil }
+
+func (j *PoolMonitorJob) Snapper() (snapper.Snapper, zfs.DatasetFilter, bool) { return nil, nil, false }
+
+func (j *PoolMonitorJob) Run(ctx context.Context) {
+	ctx, endTask := trace.WithTaskAndSpan(ctx, "pool-monitor-job", j.Name())
+	defer endTask()
+	log := GetLogger(ctx)
+
+	defer log.Info("job exiting")
+	ready.Signal(ctx)
+
+	t := time.NewTicker(j.interval)
+	defer t.Stop()
+	for {
+		if disable.Disabled(ctx) {
+			log.Info("job is disabled, skipping collection")
+		} else {
+			j.collect(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			log.WithError(ctx.Err()).Info("context")
+			return
+		case <-wakeup.Wait(ctx):
+		case <-t.C:
+		}
+	}
+}
+
+func (j *PoolMonitorJob) collect(ctx context.Context) {
+	log := GetLogger(ctx)
+	s := &PoolMonitorStatus{LastCollection: time.Now()}
+	pools := j.pools
+	if len(pools) == 0 {
+		var err error
+		if pools, err = j.listPools(ctx); err != nil {
+			log.WithError(err).Error("cannot list pools")
+			s.Error = err.Error()
+		}
+	}
+	for _, pool := range pools {
+		p := &PoolMonitorPool{Name: pool}
+		if st, err := j.getStatus(ctx, pool); err != nil {
+			log.WithError(err).WithField("pool", pool).Error("cannot collect pool state")
+			p.Error = err.Error()
+		} else {
+			p.Status = st
+		}
+		s.Pools = append(s.Pools, p)
+	}
+	if s.Error == "" {
+		// keep the series of the pools if they could not be listed
+		j.metrics.update(s.Pools)
+	}
+
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	j.status = s
+}
+
+var poolScanFunctions = []string{"scrub", "resilver"}
+
+// poolMetrics are the gauges of the pools of a PoolMonitorJob, labels: pool.
+type poolMetrics struct {
+	size, allocated, free, capacity, fragmentation *prometheus.GaugeVec
+	health                                         *prometheus.GaugeVec // labels: pool, state
+	scanInProgress, scanEnd                        *prometheus.GaugeVec // labels: pool, function
+	scanProgress                                   *prometheus.GaugeVec
+	collected                                      *prometheus.GaugeVec
+
+	// pools of the last update, to delete the series of vanished pools
+	reported map[string]bool
+}
+
+func newPoolMetrics(jobName string) *poolMetrics {
+	gauge := func(name, help string, labels ...string) *prometheus.GaugeVec {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   "zrepl",
+			Subsystem:   "pool",
+			Name:        name,
+			Help:        help,
+			ConstLabels: prometheus.Labels{"zrepl_job": jobName},
+		}, append([]string{"pool"}, labels...))
+	}
+	return &poolMetrics{
+		size:           gauge("size_bytes", "size of the pool"),
+		allocated:      gauge("allocated_bytes", "allocated space of the pool"),
+		free:           gauge("free_bytes", "free space of the pool"),
+		capacity:       gauge("capacity_ratio", "fraction of the pool's space that is allocated"),
+		fragmentation:  gauge("fragmentation_ratio", "fragmentation of the pool's free space, absent if the pool does not report it"),
+		health:         gauge("health", "1 for the pool's current health state, 0 for the others", "state"),
+		scanInProgress: gauge("scan_in_progress", "1 if a scrub or resilver is in progress or paused", "function"),
+		scanProgress:   gauge("scan_progress_ratio", "fraction of the scrub or resilver in progress or paused that is done"),
+		scanEnd:        gauge("scan_end_timestamp", "end time of the latest finished scrub or resilver since the job started", "function"),
+		collected:      gauge("collected", "1 if the pool's state could be collected by the last collection, 0 otherwise"),
+		reported:       make(map[string]bool),
+	}
+}
+
+func (m *poolMetrics) register(registerer prometheus.Registerer) {
+	registerer.MustRegister(m.size)
+	registerer.MustRegister(m.allocated)
+	registerer.MustRegister(m.free)
+	registerer.MustRegister(m.capacity)
+	registerer.MustRegister(m.fragmentation)
+	registerer.MustRegister(m.health)
+	registerer.MustRegister(m.scanInProgress)
+	registerer.MustRegister(m.scanProgress)
+	registerer.MustRegister(m.scanEnd)
+	registerer.MustRegister(m.collected)
+}
+
+// update is only called by the job's collect, i.e., not concurrently
+func (m *poolMetrics) update(pools []*PoolMonitorPool) {
+	reported := make(map[string]bool, len(pools))
+	for _, p := range pools {
+		reported[p.Name] = true
+		if p.Status == nil {
+			// stale values would hide that the pool cannot be collected, e.g. because it is not imported
+			m.collected.WithLabelValues(p.Name).Set(0)
+			m.delete(p.Name)
+			continue
+		}
+		s := p.Status
+		m.collected.WithLabelValues(p.Name).Set(1)
+		m.size.WithLabelValues(p.Name).Set(float64(s.Size))
+		m.allocated.WithLabelValues(p.Name).Set(float64(s.Allocated))
+		m.free.WithLabelValues(p.Name).Set(float64(s.Free))
+		m.capacity.WithLabelValues(p.Name).Set(float64(s.Capacity) / 100)
+		if s.Fragmentation >= 0 {
+			m.fragmentation.WithLabelValues(p.Name).Set(float64(s.Fragmentation) / 100)
+		} else {
+			m.fragmentation.DeleteLabelValues(p.Name)
+		}
+		for _, state := range zfs.ZPoolHealthStates {
+			v := 0.0
+			if s.Health == state {
+				v = 1
+			}
+			m.health.WithLabelValues(p.Name, state).Set(v)
+		}
+		for _, f := range poolScanFunctions {
+			v := 0.0
+			if s.Scan.InProgress() && s.Scan.Function == f {
+				v = 1
+			}
+			m.scanInProgress.WithLabelValues(p.Name, f).Set(v)
+		}
+		if s.Scan.InProgress() {
+			m.scanProgress.WithLabelValues(p.Name).Set(s.Scan.Progress)
+		} else {
+			m.scanProgress.DeleteLabelValues(p.Name)
+		}
+		if s.Scan != nil && s.Scan.State == "finished" && !s.Scan.Time.IsZero() {
+			m.scanEnd.WithLabelValues(p.Name, s.Scan.Function).Set(float64(s.Scan.Time.Unix()))
+		}
+	}
+	for pool := range m.reported {
+		if !reported[pool] {
+			m.collected.DeleteLabelValues(pool)
+			m.delete(pool)
+		}
+	}
+	m.reported = reported
+}
+
+// delete deletes the series of pool except for collected
+func (m *poolMetrics) delete(pool string) {
+	m.size.DeleteLabelValues(pool)
+	m.allocated.DeleteLabelValues(pool)
+	m.free.DeleteLabelValues(pool)
+	m.capacity.DeleteLabelValues(pool)
+	m.fragmentation.DeleteLabelValues(pool)
+	for _, state := range zfs.ZPoolHealthStates {
+		m.health.DeleteLabelValues(pool, state)
+	}
+	for _, f := range poolScanFunctions {
+		m.scanInProgress.DeleteLabelValues(pool, f)
+		m.scanEnd.DeleteLabelValues(pool, f)
+	}
+	m.scanProgress.DeleteLabelValues(pool)
+}
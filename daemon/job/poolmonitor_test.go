@@ -0,0 +1,86 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestPoolMonitorJob(t *testing.T) {
+	scrubEnd := time.Date(2022, 7, 23, 14, 0, 0, 0, time.UTC)
+	pools := map[string]*zfs.ZPoolStatus{
+		"tank": {Name: "tank", Health: "ONLINE", Size: 1000, Allocated: 600, Free: 400, Capacity: 60, Fragmentation: 12,
+			Scan: &zfs.ZPoolScan{Function: "scrub", State: "finished", Time: scrubEnd}},
+		"backup": {Name: "backup", Health: "DEGRADED", Size: 2000, Fragmentation: -1,
+			Scan: &zfs.ZPoolScan{Function: "resilver", State: "in_progress", Progress: 0.5}},
+	}
+	j, err := poolMonitorJobFromConfig(&config.PoolMonitorJob{Name: "pools", Interval: time.Minute})
+	require.NoError(t, err)
+	j.listPools = func(ctx context.Context) ([]string, error) {
+		var names []string
+		for _, name := range []string{"backup", "tank"} {
+			if _, ok := pools[name]; ok {
+				names = append(names, name)
+			}
+		}
+		return names, nil
+	}
+	j.getStatus = func(ctx context.Context, pool string) (*zfs.ZPoolStatus, error) {
+		if s, ok := pools[pool]; ok && s != nil {
+			return s, nil
+		}
+		return nil, errors.New("no such pool")
+	}
+	r := prometheus.NewRegistry()
+	j.RegisterMetrics(r)
+	gathered := func() (n int) {
+		mfs, err := r.Gather()
+		require.NoError(t, err)
+		for _, mf := range mfs {
+			n += len(mf.GetMetric())
+		}
+		return n
+	}
+	m := j.metrics
+
+	assert.True(t, j.Status().JobSpecific.(*PoolMonitorStatus).LastCollection.IsZero())
+	j.collect(context.Background())
+	st := j.Status().JobSpecific.(*PoolMonitorStatus)
+	require.Len(t, st.Pools, 2)
+	assert.Equal(t, "backup", st.Pools[0].Name)
+	assert.Equal(t, []string{`pool "backup" is DEGRADED`}, st.Problems())
+
+	assert.Equal(t, 0.6, testutil.ToFloat64(m.capacity.WithLabelValues("tank")))
+	assert.Equal(t, 0.12, testutil.ToFloat64(m.fragmentation.WithLabelValues("tank")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.health.WithLabelValues("backup", "DEGRADED")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.health.WithLabelValues("backup", "ONLINE")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.scanInProgress.WithLabelValues("backup", "resilver")))
+	assert.Equal(t, 0.5, testutil.ToFloat64(m.scanProgress.WithLabelValues("backup")))
+	assert.Equal(t, float64(scrubEnd.Unix()), testutil.ToFloat64(m.scanEnd.WithLabelValues("tank", "scrub")))
+	perPool := 1 + 4 + len(zfs.ZPoolHealthStates) + len(poolScanFunctions)
+	assert.Equal(t, 2*perPool+1+1+1, gathered(), "fragmentation of tank only, progress of backup only, scan end of tank only")
+
+	// the pool is exported
+	delete(pools, "backup")
+	j.collect(context.Background())
+	assert.Equal(t, perPool+1+1, gathered(), "the series of vanished pools are deleted")
+
+	// the pool cannot be collected
+	pools["tank"] = nil
+	j.collect(context.Background())
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.collected.WithLabelValues("tank")))
+	assert.Equal(t, 1, gathered(), "only the collected series of pools that cannot be collected")
+	assert.Equal(t, []string{`pool "tank": no such pool`}, j.Status().JobSpecific.(*PoolMonitorStatus).Problems())
+
+	_, err = poolMonitorJobFromConfig(&config.PoolMonitorJob{Name: "pools", Pools: []string{"tank/data"}, Interval: time.Minute})
+	assert.Error(t, err, "pool names have a single component")
+}
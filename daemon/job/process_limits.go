@@ -0,0 +1,66 @@
+package job
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+var ioClasses = map[string]zfscmd.IOClass{
+	"realtime":    zfscmd.IOClassRealtime,
+	"best-effort": zfscmd.IOClassBestEffort,
+	"idle":        zfscmd.IOClassIdle,
+}
+
+// ProcessLimitsFromConfig returns nil if in is nil.
+func ProcessLimitsFromConfig(in *config.ProcessLimits) (*zfscmd.ProcessLimits, error) {
+	if in == nil {
+		return nil, nil
+	}
+	l := &zfscmd.ProcessLimits{Nice: in.Nice, CPUWeight: in.CPUWeight, IOWeight: in.IOWeight}
+	if in.Nice != nil && (*in.Nice < -20 || *in.Nice > 19) {
+		return nil, fmt.Errorf("`nice` must be between -20 and 19")
+	}
+
+	if in.IOClass != "" {
+		var ok bool
+		if l.IOClass, ok = ioClasses[in.IOClass]; !ok {
+			return nil, fmt.Errorf("invalid `io_class` %q, must be realtime, best-effort or idle", in.IOClass)
+		}
+	}
+	l.IOPriority = 4
+	if in.IOPriority != nil {
+		if in.IOClass == "" || in.IOClass == "idle" {
+			return nil, fmt.Errorf("`io_priority` requires `io_class` realtime or best-effort")
+		}
+		if *in.IOPriority < 0 || *in.IOPriority > 7 {
+			return nil, fmt.Errorf("`io_priority` must be between 0 and 7")
+		}
+		l.IOPriority = *in.IOPriority
+	}
+	if l.IOClass == zfscmd.IOClassIdle {
+		l.IOPriority = 0
+	}
+
+	if in.Cgroup != "" {
+		cg := filepath.Clean(in.Cgroup)
+		if filepath.IsAbs(cg) || cg == "." || strings.HasPrefix(cg, "..") {
+			return nil, fmt.Errorf("`cgroup` must be a path relative to %s", cgroupRoot)
+		}
+		l.Cgroup = filepath.Join(cgroupRoot, cg)
+	}
+	if in.Cgroup == "" && (in.CPUWeight != 0 || in.IOWeight != 0) {
+		return nil, fmt.Errorf("`cpu_weight` and `io_weight` require `cgroup`")
+	}
+	for name, w := range map[string]int{"cpu_weight": in.CPUWeight, "io_weight": in.IOWeight} {
+		if w > 10000 {
+			return nil, fmt.Errorf("`%s` must be between 1 and 10000", name)
+		}
+	}
+	return l, nil
+}
@@ -0,0 +1,54 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+func TestProcessLimitsFromConfig(t *testing.T) {
+	l, err := ProcessLimitsFromConfig(nil)
+	require.NoError(t, err)
+	assert.Nil(t, l)
+
+	intp := func(i int) *int { return &i }
+	l, err = ProcessLimitsFromConfig(&config.ProcessLimits{
+		Nice:      intp(10),
+		IOClass:   "best-effort",
+		Cgroup:    "zrepl.slice/bulk",
+		CPUWeight: 50,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 10, *l.Nice)
+	assert.Equal(t, zfscmd.IOClassBestEffort, l.IOClass)
+	assert.Equal(t, 4, l.IOPriority)
+	assert.Equal(t, "/sys/fs/cgroup/zrepl.slice/bulk", l.Cgroup)
+	assert.Equal(t, 50, l.CPUWeight)
+
+	l, err = ProcessLimitsFromConfig(&config.ProcessLimits{IOClass: "idle"})
+	require.NoError(t, err)
+	assert.Equal(t, zfscmd.IOClassIdle, l.IOClass)
+	assert.Equal(t, 0, l.IOPriority)
+
+	for name, in := range map[string]config.ProcessLimits{
+		"nice_range":           {Nice: intp(20)},
+		"io_class":             {IOClass: "background"},
+		"io_priority_no_class": {IOPriority: intp(2)},
+		"io_priority_idle":     {IOClass: "idle", IOPriority: intp(2)},
+		"io_priority_range":    {IOClass: "realtime", IOPriority: intp(8)},
+		"cgroup_abs":           {Cgroup: "/sys/fs/cgroup/zrepl"},
+		"cgroup_escape":        {Cgroup: "../zrepl"},
+		"weight_no_cgroup":     {IOWeight: 10},
+		"weight_range":         {Cgroup: "zrepl", CPUWeight: 10001},
+	} {
+		in := in
+		t.Run(name, func(t *testing.T) {
+			_, err := ProcessLimitsFromConfig(&in)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,27 @@
+// Package ready lets jobs signal to the daemon that they have started up,
+// e.g., that their listeners are up.
+package ready
+
+import (
+	"context"
+	"sync"
+)
+
+type contextKey int
+
+const contextKeyReady contextKey = iota
+
+// Signal marks the job of ctx as ready. Subsequent calls have no effect.
+func Signal(ctx context.Context) {
+	if s, ok := ctx.Value(contextKeyReady).(func()); ok {
+		s()
+	}
+}
+
+// Context returns a context for a job and a channel that is closed once the job calls Signal.
+func Context(ctx context.Context) (context.Context, <-chan struct{}) {
+	c := make(chan struct{})
+	var once sync.Once
+	signal := func() { once.Do(func() { close(c) }) }
+	return context.WithValue(ctx, contextKeyReady, signal), c
+}
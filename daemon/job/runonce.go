@@ -0,0 +1,108 @@
+package job
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/daemon/snapper"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/zfs"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+// OneShot is implemented by jobs that can perform a single invocation
+// in the foreground, see `zrepl run --once`.
+type OneShot interface {
+	Job
+	// RunOnce takes snapshots (unless snapshotting is manual), replicates and prunes once.
+	// The returned error describes the failures of the invocation.
+	RunOnce(ctx context.Context) error
+}
+
+var _ OneShot = (*ActiveSide)(nil)
+var _ OneShot = (*SnapJob)(nil)
+
+// snapshotOnce takes the snapshots of an invocation of the periodic snapper s.
+func snapshotOnce(ctx context.Context, s snapper.Snapper, fsf zfs.DatasetFilter) error {
+	if s.Report().Type == snapper.TypeManual {
+		GetLogger(ctx).Info("manual snapshotting configured, not taking snapshots")
+		return nil
+	}
+	ctx, endSpan := trace.WithSpan(ctx, "snapshot")
+	defer endSpan()
+	GetLogger(ctx).Info("start snapshotting")
+	reports, err := snapper.OnDemand(ctx, s, fsf, snapper.OnDemandRequest{})
+	if err != nil {
+		return errors.Wrap(err, "snapshotting")
+	}
+	failed := 0
+	for _, fs := range reports {
+		if fs.State != snapper.SnapDone {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("snapshotting: %d of %d filesystems failed", failed, len(reports))
+	}
+	GetLogger(ctx).WithField("filesystems", len(reports)).Info("finished snapshotting")
+	return nil
+}
+
+// RunOnce does not wait for a blackout window to end,
+// and fails if a blackout window begins during the invocation.
+// Pruning follows replication even if the job has a prune schedule.
+func (j *ActiveSide) RunOnce(ctx context.Context) error {
+	ctx, endTask := trace.WithTaskAndSpan(ctx, "active-side-job-once", j.Name())
+	defer endTask()
+	ctx = zfscmd.WithProcessLimits(ctx, j.processLimits)
+	ctx = snapper.WithDurationObserver(ctx, j.promPhaseSecs.WithLabelValues(phaseSnapshotting))
+	ctx = context.WithValue(ctx, endpoint.ClientIdentityKey, FakeActiveSideDirectMethodInvocationClientIdentity(j.name))
+
+	if snap, fsf, ok := j.Snapper(); ok {
+		if err := snapshotOnce(ctx, snap, fsf); err != nil {
+			return err
+		}
+	}
+
+	outcome := newInvocationOutcome()
+	if j.do(ctx, nil, outcome) {
+		return errors.New("invocation interrupted by blackout window")
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if j.pruneSchedule != nil {
+		func() {
+			j.mode.ConnectEndpoints(ctx, j.connecter)
+			defer j.mode.DisconnectEndpoints()
+			sender, receiver := j.mode.SenderReceiver()
+			j.prune(ctx, sender, receiver, outcome)
+		}()
+		outcome.phases.observe(j.promPhaseSecs)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return outcome.err()
+}
+
+func (j *SnapJob) RunOnce(ctx context.Context) error {
+	ctx, endTask := trace.WithTaskAndSpan(ctx, "snap-job-once", j.Name())
+	defer endTask()
+	ctx = zfscmd.WithProcessLimits(ctx, j.processLimits)
+	ctx = snapper.WithDurationObserver(ctx, j.promPhaseSecs.WithLabelValues(phaseSnapshotting))
+
+	if err := snapshotOnce(ctx, j.snapper, j.fsfilter); err != nil {
+		return err
+	}
+	outcome := newInvocationOutcome()
+	j.doPrune(ctx, outcome)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	outcome.pruningDone("snapshots", j.pruner.Report())
+	j.invocations.record(outcome.summary())
+	return outcome.err()
+}
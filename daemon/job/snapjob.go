@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
@@ -15,12 +16,18 @@ import (
 
 	"github.com/zrepl/zrepl/config"
 	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/daemon/job/disable"
+	"github.com/zrepl/zrepl/daemon/job/finished"
+	"github.com/zrepl/zrepl/daemon/job/maintenance"
+	"github.com/zrepl/zrepl/daemon/job/ready"
+	"github.com/zrepl/zrepl/daemon/job/stop"
 	"github.com/zrepl/zrepl/daemon/job/wakeup"
 	"github.com/zrepl/zrepl/daemon/pruner"
 	"github.com/zrepl/zrepl/daemon/snapper"
 	"github.com/zrepl/zrepl/endpoint"
 	"github.com/zrepl/zrepl/replication/logic/pdu"
 	"github.com/zrepl/zrepl/zfs"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
 )
 
 type SnapJob struct {
@@ -31,9 +38,16 @@ type SnapJob struct {
 	prunerFactory *pruner.LocalPrunerFactory
 
 	promPruneSecs *prometheus.HistogramVec // labels: prune_side
+	promPhaseSecs *prometheus.HistogramVec // labels: phase
+	promSnapshots *pruner.SnapshotMetrics  // labels: prune_side, peer, filesystem
 
 	prunerMtx sync.Mutex
 	pruner    *pruner.Pruner
+
+	invocations invocationHistory
+
+	processLimits      *zfscmd.ProcessLimits // nil if not configured
+	maintenanceWindows *blackout             // nil if not configured
 }
 
 func (j *SnapJob) Name() string { return j.name.String() }
@@ -48,9 +62,15 @@ func snapJobFromConfig(g *config.Global, in *config.SnapJob) (j *SnapJob, err er
 	}
 	j.fsfilter = fsf
 
-	if j.snapper, err = snapper.FromConfig(g, fsf, in.Snapshotting); err != nil {
+	if j.snapper, err = snapper.FromConfig(g, in.Name, fsf, in.Snapshotting); err != nil {
 		return nil, errors.Wrap(err, "cannot build snapper")
 	}
+	if j.processLimits, err = ProcessLimitsFromConfig(in.ProcessLimits); err != nil {
+		return nil, errors.Wrap(err, "invalid `process_limits`")
+	}
+	if j.maintenanceWindows, err = maintenanceWindowsFromConfig(in.Maintenance); err != nil {
+		return nil, errors.Wrap(err, "field `maintenance`")
+	}
 	j.name, err = endpoint.MakeJobID(in.Name)
 	if err != nil {
 		return nil, errors.Wrap(err, "invalid job name")
@@ -62,7 +82,9 @@ func snapJobFromConfig(g *config.Global, in *config.SnapJob) (j *SnapJob, err er
 		Help:        "seconds spent in pruner",
 		ConstLabels: prometheus.Labels{"zrepl_job": j.name.String()},
 	}, []string{"prune_side"})
-	j.prunerFactory, err = pruner.NewLocalPrunerFactory(in.Pruning, j.promPruneSecs)
+	j.promPhaseSecs = newPhaseSecs(j.name.String())
+	j.promSnapshots = pruner.NewSnapshotMetrics(j.name.String(), perFilesystemMetricsFromConfig(g))
+	j.prunerFactory, err = pruner.NewLocalPrunerFactory(in.Pruning, j.promPruneSecs, j.promSnapshots)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot build snapjob pruning rules")
 	}
@@ -71,11 +93,15 @@ func snapJobFromConfig(g *config.Global, in *config.SnapJob) (j *SnapJob, err er
 
 func (j *SnapJob) RegisterMetrics(registerer prometheus.Registerer) {
 	registerer.MustRegister(j.promPruneSecs)
+	registerer.MustRegister(j.promPhaseSecs)
+	j.promSnapshots.Register(registerer)
 }
 
 type SnapJobStatus struct {
 	Pruning      *pruner.Report
 	Snapshotting *snapper.Report // may be nil
+	// the invocations that finished within the last 24 hours (ZREPL_JOB_INVOCATION_HISTORY_RETENTION), oldest first
+	RecentInvocations []*InvocationSummary `json:",omitempty"`
 }
 
 func (j *SnapJob) Status() *Status {
@@ -88,6 +114,7 @@ func (j *SnapJob) Status() *Status {
 	j.prunerMtx.Unlock()
 	r := j.snapper.Report()
 	s.Snapshotting = &r
+	s.RecentInvocations = j.invocations.status(time.Now())
 	return &Status{Type: t, JobSpecific: s}
 }
 
@@ -97,12 +124,22 @@ func (j *SnapJob) OwnedDatasetSubtreeRoot() (rfs *zfs.DatasetPath, ok bool) {
 
 func (j *SnapJob) SenderConfig() *endpoint.SenderConfig { return nil }
 
+func (j *SnapJob) Snapper() (snapper.Snapper, zfs.DatasetFilter, bool) {
+	return j.snapper, j.fsfilter, true
+}
+
 func (j *SnapJob) Run(ctx context.Context) {
 	ctx, endTask := trace.WithTaskAndSpan(ctx, "snap-job", j.Name())
 	defer endTask()
+	ctx = zfscmd.WithProcessLimits(ctx, j.processLimits)
+	ctx = snapper.WithDurationObserver(ctx, j.promPhaseSecs.WithLabelValues(phaseSnapshotting))
+	if j.maintenanceWindows != nil {
+		maintenance.SetWindows(ctx, j.maintenanceWindows.windowEnd)
+	}
 	log := GetLogger(ctx)
 
 	defer log.Info("job exiting")
+	ready.Signal(ctx)
 
 	periodicDone := make(chan struct{})
 	ctx, cancel := context.WithCancel(ctx)
@@ -120,13 +157,29 @@ outer:
 			log.WithError(ctx.Err()).Info("context")
 			break outer
 
+		case <-stop.Requested(ctx):
+			log.Info("graceful stop requested")
+			break outer
+
 		case <-wakeup.Wait(ctx):
 		case <-periodicDone:
 		}
+		if disable.Disabled(ctx) {
+			log.Info("job is disabled, skipping invocation")
+			continue outer
+		}
+		if m := maintenance.Get(ctx); m.Active {
+			log.WithField("until", m.Until).Info("job is in maintenance, skipping invocation")
+			continue outer
+		}
 		invocationCount++
 
 		invocationCtx, endSpan := trace.WithSpan(ctx, fmt.Sprintf("invocation-%d", invocationCount))
-		j.doPrune(invocationCtx)
+		outcome := newInvocationOutcome()
+		j.doPrune(invocationCtx, outcome)
+		outcome.pruningDone("snapshots", j.pruner.Report())
+		j.invocations.record(outcome.summary())
+		finished.Notify(invocationCtx)
 		endSpan()
 	}
 }
@@ -174,9 +227,11 @@ func (h alwaysUpToDateReplicationCursorHistory) ListFilesystems(ctx context.Cont
 	return h.target.ListFilesystems(ctx, req)
 }
 
-func (j *SnapJob) doPrune(ctx context.Context) {
+func (j *SnapJob) doPrune(ctx context.Context, outcome *invocationOutcome) {
 	ctx, endSpan := trace.WithSpan(ctx, "snap-job-do-prune")
 	defer endSpan()
+	defer outcome.phases.observe(j.promPhaseSecs)
+	defer outcome.phases.start(phasePruning)()
 	log := GetLogger(ctx)
 	sender := endpoint.NewSender(endpoint.SenderConfig{
 		JobID: j.name,
@@ -0,0 +1,31 @@
+// Package stop lets the daemon request a job to stop gracefully,
+// i.e., after its current invocation, as opposed to cancelling the job's context.
+package stop
+
+import (
+	"context"
+	"sync"
+)
+
+type contextKey int
+
+const contextKeyStop contextKey = iota
+
+// Requested returns a channel that is closed once a graceful stop of the job of ctx is requested.
+func Requested(ctx context.Context) <-chan struct{} {
+	c, ok := ctx.Value(contextKeyStop).(chan struct{})
+	if !ok {
+		return nil // never
+	}
+	return c
+}
+
+type Func func()
+
+// Context returns a context for a job and a Func that requests the job to stop gracefully.
+// Calling the Func multiple times has no additional effect.
+func Context(ctx context.Context) (context.Context, Func) {
+	c := make(chan struct{})
+	var once sync.Once
+	return context.WithValue(ctx, contextKeyStop, c), func() { once.Do(func() { close(c) }) }
+}
@@ -3,33 +3,71 @@ package wakeup
 import (
 	"context"
 	"errors"
+	"sync"
 )
 
 type contextKey int
 
 const contextKeyWakeup contextKey = iota
 
+type wakeup struct {
+	c chan struct{}
+
+	mtx sync.Mutex
+	// filesystem patterns of the wakeups since the last call to Filesystems,
+	// all is true if any of them was not targeted
+	filesystems []string
+	all         bool
+}
+
 func Wait(ctx context.Context) <-chan struct{} {
-	wc, ok := ctx.Value(contextKeyWakeup).(chan struct{})
+	w, ok := ctx.Value(contextKeyWakeup).(*wakeup)
+	if !ok {
+		return make(chan struct{})
+	}
+	return w.c
+}
+
+// Filesystems returns the filesystem patterns of the wakeups received since the last call
+// and clears them.
+// It returns nil if any of the wakeups was not targeted, i.e., all filesystems are to be replicated.
+func Filesystems(ctx context.Context) []string {
+	w, ok := ctx.Value(contextKeyWakeup).(*wakeup)
 	if !ok {
-		wc = make(chan struct{})
+		return nil
+	}
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	fss := w.filesystems
+	if w.all {
+		fss = nil
 	}
-	return wc
+	w.filesystems, w.all = nil, false
+	return fss
 }
 
-type Func func() error
+// A Func wakes up the job. If filesystems is not empty, the invocation
+// is limited to the filesystems that match one of the patterns.
+type Func func(filesystems ...string) error
 
 var AlreadyWokenUp = errors.New("already woken up")
 
 func Context(ctx context.Context) (context.Context, Func) {
-	wc := make(chan struct{})
-	wuf := func() error {
+	w := &wakeup{c: make(chan struct{})}
+	wuf := func(filesystems ...string) error {
+		// hold the lock while sending so that the job only calls Filesystems after the update
+		w.mtx.Lock()
+		defer w.mtx.Unlock()
 		select {
-		case wc <- struct{}{}:
+		case w.c <- struct{}{}:
+			if len(filesystems) == 0 {
+				w.all = true
+			}
+			w.filesystems = append(w.filesystems, filesystems...)
 			return nil
 		default:
 			return AlreadyWokenUp
 		}
 	}
-	return context.WithValue(ctx, contextKeyWakeup, wc), wuf
+	return context.WithValue(ctx, contextKeyWakeup, w), wuf
 }
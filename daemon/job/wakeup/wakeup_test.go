@@ -0,0 +1,40 @@
+package wakeup
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilesystems(t *testing.T) {
+	ctx, wu := Context(context.Background())
+
+	// wakes up the job waiting for wakeups
+	wakeUp := func(filesystems ...string) error {
+		received := make(chan struct{})
+		go func() {
+			<-Wait(ctx)
+			close(received)
+		}()
+		var err error
+		for err = wu(filesystems...); err == AlreadyWokenUp; err = wu(filesystems...) {
+		}
+		<-received
+		return err
+	}
+
+	assert.Nil(t, Filesystems(ctx), "no wakeup")
+
+	assert.NoError(t, wakeUp("pool/a"))
+	assert.NoError(t, wakeUp("pool/b<"))
+	assert.Equal(t, []string{"pool/a", "pool/b<"}, Filesystems(ctx))
+	assert.Nil(t, Filesystems(ctx), "cleared")
+
+	assert.NoError(t, wakeUp("pool/a"))
+	assert.NoError(t, wakeUp())
+	assert.Nil(t, Filesystems(ctx), "untargeted wakeup replicates all filesystems")
+
+	assert.Equal(t, AlreadyWokenUp, wu("pool/c"), "nobody waits")
+	assert.Nil(t, Filesystems(ctx), "failed wakeups are not recorded")
+}
@@ -0,0 +1,215 @@
+package daemon
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/job/abort"
+	"github.com/zrepl/zrepl/daemon/job/maintenance"
+)
+
+// JobStateRequest is the request of the ControlJobEndpointJobState endpoint.
+type JobStateRequest struct {
+	Name     string
+	Disabled bool
+	// when disabling, also cancel the current invocation (active jobs only)
+	Abort bool
+	// when disabling, do not persist the state, i.e., the job is enabled again on daemon restart
+	Temporary bool
+}
+
+// JobMaintenanceRequest is the request of the ControlJobEndpointMaintenance endpoint.
+type JobMaintenanceRequest struct {
+	Name string
+	// start or end the maintenance
+	Start bool
+	// when starting, the end of the maintenance, zero to last until it is ended
+	Until time.Time
+	// when starting, do not persist the state, i.e., the maintenance ends on daemon restart
+	Temporary bool
+}
+
+type jobMaintenance struct {
+	Until     time.Time
+	Persisted bool
+}
+
+func (m jobMaintenance) expired(now time.Time) bool {
+	return !m.Until.IsZero() && !now.Before(m.Until)
+}
+
+// the contents of jobs.statePath
+type jobStateFile struct {
+	Disabled []string `json:"disabled"`
+	// by job name, the value is the end of the maintenance or the zero value
+	Maintenance map[string]time.Time `json:"maintenance,omitempty"`
+}
+
+func (s *jobs) setJobState(log Logger, req JobStateRequest) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	j, ok := s.jobs[req.Name]
+	if !ok {
+		return errors.Errorf("Job %s does not exist", req.Name)
+	}
+	switch j.(type) {
+	case *job.ActiveSide, *job.SnapJob:
+	default:
+		return errors.Errorf("Job %s cannot be disabled: only snap and active jobs are scheduled", req.Name)
+	}
+	if req.Abort {
+		if _, ok := j.(*job.ActiveSide); !ok || !req.Disabled {
+			return errors.New("abort is only supported when disabling active jobs")
+		}
+	}
+
+	wasPersisted := s.disabled[req.Name]
+	if req.Disabled {
+		s.disabled[req.Name] = !req.Temporary
+	} else {
+		delete(s.disabled, req.Name)
+	}
+	s.switches[req.Name].Set(req.Disabled)
+	log = log.WithField("job", req.Name)
+	if req.Disabled {
+		log.WithField("temporary", req.Temporary).Info("job disabled")
+	} else {
+		log.Info("job enabled")
+	}
+
+	if req.Abort {
+		if err := s.aborts[req.Name](); err != nil && err != abort.NotRunning {
+			return err
+		}
+	}
+
+	if s.disabled[req.Name] != wasPersisted {
+		return s.saveJobState()
+	}
+	return nil
+}
+
+func (s *jobs) setJobMaintenance(log Logger, req JobMaintenanceRequest) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	j, ok := s.jobs[req.Name]
+	if !ok {
+		return errors.Errorf("Job %s does not exist", req.Name)
+	}
+	switch j.(type) {
+	case *job.ActiveSide, *job.SnapJob:
+	default:
+		return errors.Errorf("Job %s cannot be put into maintenance: only snap and active jobs are scheduled", req.Name)
+	}
+	if req.Start && !req.Until.IsZero() && !req.Until.After(time.Now()) {
+		return errors.New("end of maintenance must be in the future")
+	}
+
+	wasPersisted := s.maintenance[req.Name].Persisted
+	log = log.WithField("job", req.Name)
+	if req.Start {
+		s.maintenance[req.Name] = jobMaintenance{Until: req.Until, Persisted: !req.Temporary}
+		s.maintenanceSwitches[req.Name].Start(req.Until)
+		log.WithField("until", req.Until).WithField("temporary", req.Temporary).
+			Info("job maintenance started, running invocations complete")
+	} else {
+		delete(s.maintenance, req.Name)
+		s.maintenanceSwitches[req.Name].End()
+		log.Info("job maintenance ended")
+	}
+
+	if s.maintenance[req.Name].Persisted || wasPersisted {
+		return s.saveJobState()
+	}
+	return nil
+}
+
+func maintenanceGauge(jobName string, sw *maintenance.Switch) prometheus.Collector {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   "zrepl",
+		Subsystem:   "job",
+		Name:        "maintenance",
+		Help:        "1 if the job is in maintenance, i.e., new invocations are skipped",
+		ConstLabels: prometheus.Labels{"zrepl_job": jobName},
+	}, func() float64 {
+		if sw.State(time.Now()).Active {
+			return 1
+		}
+		return 0
+	})
+}
+
+// loadJobState reads the persisted state of s.statePath.
+// A missing file is not an error.
+func (s *jobs) loadJobState() error {
+	if s.statePath == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(s.statePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var f jobStateFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return errors.Wrapf(err, "cannot parse %s", s.statePath)
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	for _, name := range f.Disabled {
+		s.disabled[name] = true
+	}
+	for name, until := range f.Maintenance {
+		s.maintenance[name] = jobMaintenance{Until: until, Persisted: true}
+	}
+	return nil
+}
+
+// saveJobState atomically replaces the file at s.statePath. Callers must hold s.m.
+func (s *jobs) saveJobState() error {
+	if s.statePath == "" {
+		return nil
+	}
+	f := jobStateFile{Disabled: []string{}}
+	for name, persisted := range s.disabled {
+		if persisted {
+			f.Disabled = append(f.Disabled, name)
+		}
+	}
+	sort.Strings(f.Disabled)
+	now := time.Now()
+	for name, m := range s.maintenance {
+		if m.Persisted && !m.expired(now) {
+			if f.Maintenance == nil {
+				f.Maintenance = make(map[string]time.Time)
+			}
+			f.Maintenance[name] = m.Until
+		}
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.statePath), 0700); err != nil {
+		return errors.Wrap(err, "cannot create directory of job state file")
+	}
+	tmp := s.statePath + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return errors.Wrap(err, "cannot write job state file")
+	}
+	if err := os.Rename(tmp, s.statePath); err != nil {
+		return errors.Wrap(err, "cannot write job state file")
+	}
+	return nil
+}
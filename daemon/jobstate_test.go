@@ -0,0 +1,133 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/job/disable"
+	"github.com/zrepl/zrepl/daemon/job/maintenance"
+	"github.com/zrepl/zrepl/logger"
+)
+
+func TestJobState(t *testing.T) {
+	c, err := config.ParseConfigBytes([]byte(`
+jobs:
+- name: snapjob
+  type: snap
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep:
+      - type: last_n
+        count: 10
+`))
+	require.NoError(t, err)
+	js, err := job.JobsFromConfig(c, config.ParseFlagsNone)
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "zrepl-jobstate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	statePath := filepath.Join(dir, "state", "jobstate.json")
+
+	log := logger.NewNullLogger()
+	newTestJobs := func() *jobs {
+		s := newJobs()
+		s.statePath = statePath
+		require.NoError(t, s.loadJobState())
+		s.jobs["snapjob"] = js[0]
+		s.switches["snapjob"] = &disable.Switch{}
+		if _, ok := s.disabled["snapjob"]; ok {
+			s.switches["snapjob"].Set(true)
+		}
+		return s
+	}
+
+	s := newTestJobs()
+	assert.Error(t, s.setJobState(log, JobStateRequest{Name: "nonexistent", Disabled: true}))
+	assert.Error(t, s.setJobState(log, JobStateRequest{Name: "snapjob", Disabled: true, Abort: true}), "abort is only supported for active jobs")
+
+	// a temporary disable is not persisted
+	require.NoError(t, s.setJobState(log, JobStateRequest{Name: "snapjob", Disabled: true, Temporary: true}))
+	assert.True(t, s.switches["snapjob"].Disabled())
+	_, err = os.Stat(statePath)
+	assert.True(t, os.IsNotExist(err))
+	assert.False(t, newTestJobs().switches["snapjob"].Disabled())
+
+	require.NoError(t, s.setJobState(log, JobStateRequest{Name: "snapjob", Disabled: true}))
+	s = newTestJobs()
+	assert.True(t, s.switches["snapjob"].Disabled())
+	assert.True(t, s.status()["snapjob"].Disabled)
+
+	require.NoError(t, s.setJobState(log, JobStateRequest{Name: "snapjob", Disabled: false}))
+	assert.False(t, s.switches["snapjob"].Disabled())
+	assert.False(t, newTestJobs().switches["snapjob"].Disabled())
+}
+
+func TestJobMaintenance(t *testing.T) {
+	c, err := config.ParseConfigBytes([]byte(`
+jobs:
+- name: snapjob
+  type: snap
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep:
+      - type: last_n
+        count: 10
+`))
+	require.NoError(t, err)
+	js, err := job.JobsFromConfig(c, config.ParseFlagsNone)
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "zrepl-jobstate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	statePath := filepath.Join(dir, "jobstate.json")
+
+	log := logger.NewNullLogger()
+	newTestJobs := func() *jobs {
+		s := newJobs()
+		s.statePath = statePath
+		require.NoError(t, s.loadJobState())
+		s.jobs["snapjob"] = js[0]
+		s.switches["snapjob"] = &disable.Switch{}
+		s.maintenanceSwitches["snapjob"] = &maintenance.Switch{}
+		if m, ok := s.maintenance["snapjob"]; ok && !m.expired(time.Now()) {
+			s.maintenanceSwitches["snapjob"].Start(m.Until)
+		}
+		return s
+	}
+	active := func(s *jobs) bool { return s.status()["snapjob"].Maintenance.Active }
+
+	s := newTestJobs()
+	assert.Error(t, s.setJobMaintenance(log, JobMaintenanceRequest{Name: "nonexistent", Start: true}))
+	assert.Error(t, s.setJobMaintenance(log, JobMaintenanceRequest{Name: "snapjob", Start: true, Until: time.Now().Add(-time.Minute)}))
+
+	// a temporary maintenance is not persisted
+	require.NoError(t, s.setJobMaintenance(log, JobMaintenanceRequest{Name: "snapjob", Start: true, Temporary: true}))
+	assert.True(t, active(s))
+	_, err = os.Stat(statePath)
+	assert.True(t, os.IsNotExist(err))
+	assert.False(t, active(newTestJobs()))
+
+	until := time.Now().Add(time.Hour).Round(time.Second)
+	require.NoError(t, s.setJobMaintenance(log, JobMaintenanceRequest{Name: "snapjob", Start: true, Until: until}))
+	s = newTestJobs()
+	assert.True(t, active(s))
+	assert.True(t, until.Equal(s.status()["snapjob"].Maintenance.Until))
+
+	require.NoError(t, s.setJobMaintenance(log, JobMaintenanceRequest{Name: "snapjob", Start: false}))
+	assert.False(t, active(s))
+	assert.False(t, active(newTestJobs()))
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/zrepl/zrepl/config"
 	"github.com/zrepl/zrepl/daemon/logging/trace"
 	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/secret"
 	"github.com/zrepl/zrepl/tlsconf"
 )
 
@@ -261,7 +262,7 @@ func parseTCPOutlet(in *config.TCPLoggingOutlet, formatter EntryFormatter) (out
 	var tlsConfig *tls.Config
 	if in.TLS != nil {
 		tlsConfig, err = func(m *config.TCPLoggingOutletTLS, host string) (*tls.Config, error) {
-			clientCert, err := tls.LoadX509KeyPair(m.Cert, m.Key)
+			clientCert, err := secret.LoadX509KeyPair(m.Cert, m.Key)
 			if err != nil {
 				return nil, errors.Wrap(err, "cannot load client cert")
 			}
@@ -0,0 +1,42 @@
+package trace
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ExemplarLabels returns the labels of an OpenMetrics exemplar that links
+// a metric observation to the activity of ctx:
+// trace_id is the id of the task and span_id the id of the span (or task) of ctx.
+// Both are part of the span stack in the `span` field of log entries (see StackKindId).
+//
+// Returns nil if ctx has no trace node or if the labels would exceed prometheus.ExemplarMaxRunes.
+func ExemplarLabels(ctx context.Context) prometheus.Labels {
+	nI := ctx.Value(contextKeyTraceNode)
+	if nI == nil {
+		return nil
+	}
+	n := nI.(*traceNode)
+	l := prometheus.Labels{"trace_id": n.task().id, "span_id": n.id}
+	runes := 0
+	for k, v := range l {
+		runes += utf8.RuneCountInString(k) + utf8.RuneCountInString(v)
+	}
+	if runes > prometheus.ExemplarMaxRunes {
+		return nil
+	}
+	return l
+}
+
+// ObserveWithExemplar observes v, with the ExemplarLabels of ctx if o supports exemplars.
+func ObserveWithExemplar(ctx context.Context, o prometheus.Observer, v float64) {
+	if e, ok := o.(prometheus.ExemplarObserver); ok {
+		if l := ExemplarLabels(ctx); l != nil {
+			e.ObserveWithExemplar(v, l)
+			return
+		}
+	}
+	o.Observe(v)
+}
@@ -0,0 +1,44 @@
+package trace
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExemplarLabels(t *testing.T) {
+	assert.Nil(t, ExemplarLabels(context.Background()))
+
+	root, endRoot := WithTask(context.Background(), "root")
+	defer endRoot()
+	task, endTask := WithTask(root, "task")
+	defer endTask()
+	span, endSpan := WithSpan(task, "span")
+	defer endSpan()
+
+	taskNode := task.Value(contextKeyTraceNode).(*traceNode)
+	spanNode := span.Value(contextKeyTraceNode).(*traceNode)
+	assert.Equal(t, prometheus.Labels{"trace_id": taskNode.id, "span_id": taskNode.id}, ExemplarLabels(task))
+	l := ExemplarLabels(span)
+	assert.Equal(t, prometheus.Labels{"trace_id": taskNode.id, "span_id": spanNode.id}, l)
+	stack := GetSpanStackOrDefault(span, *StackKindId, "")
+	assert.True(t, strings.HasSuffix(stack, "$"+l["trace_id"]+"."+l["span_id"]), "the ids are part of the span stack in the logs: %s", stack)
+
+	h := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test", Buckets: []float64{1, 10}})
+	ObserveWithExemplar(span, h, 5)
+	ObserveWithExemplar(context.Background(), h, 0.5)
+	r := prometheus.NewRegistry()
+	r.MustRegister(h)
+	mfs, err := r.Gather()
+	require.NoError(t, err)
+	buckets := mfs[0].GetMetric()[0].GetHistogram().GetBucket()
+	assert.Nil(t, buckets[0].GetExemplar())
+	e := buckets[1].GetExemplar()
+	require.NotNil(t, e)
+	assert.Equal(t, 5.0, e.GetValue())
+	assert.Len(t, e.GetLabel(), 2)
+}
@@ -0,0 +1,176 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zrepl/zrepl/daemon/logging"
+	"github.com/zrepl/zrepl/logger"
+)
+
+// LogStreamRequest is the request of the ControlJobEndpointLogs endpoint.
+type LogStreamRequest struct {
+	// only stream the entries of this job, all entries if empty
+	Job   string
+	Level logger.Level
+}
+
+// LogStreamEntry is a log entry of the ControlJobEndpointLogs endpoint, which responds with one JSON object per line.
+// The fields are formatted as strings because their values are arbitrary Go types.
+type LogStreamEntry struct {
+	Time    time.Time
+	Level   logger.Level
+	Message string
+	Fields  map[string]string
+}
+
+func (e LogStreamEntry) Entry() logger.Entry {
+	fields := make(logger.Fields, len(e.Fields))
+	for k, v := range e.Fields {
+		fields[k] = v
+	}
+	return logger.Entry{Level: e.Level, Message: e.Message, Time: e.Time, Fields: fields}
+}
+
+// logStreamBuffer is the number of entries buffered per client.
+// Entries are dropped if the client does not keep up, the outlet must not block the logger.
+const logStreamBuffer = 1024
+
+// logStreams is added to the outlets of the daemon by outletsFromConfig,
+// i.e., it also receives the entries after the logging config was reloaded.
+var logStreams = &logStreamOutlet{subscribers: make(map[*logStreamSubscriber]struct{})}
+
+type logStreamOutlet struct {
+	mtx         sync.Mutex
+	subscribers map[*logStreamSubscriber]struct{}
+}
+
+type logStreamSubscriber struct {
+	req     LogStreamRequest
+	entries chan logger.Entry
+	dropped uint64 // atomic
+}
+
+var _ logger.Outlet = (*logStreamOutlet)(nil)
+
+func (o *logStreamOutlet) WriteEntry(e logger.Entry) error {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	for s := range o.subscribers {
+		if e.Level < s.req.Level {
+			continue
+		}
+		if s.req.Job != "" && e.Fields[logging.JobField] != s.req.Job {
+			continue
+		}
+		select {
+		case s.entries <- e:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+	return nil
+}
+
+func (o *logStreamOutlet) subscribe(req LogStreamRequest) *logStreamSubscriber {
+	s := &logStreamSubscriber{req: req, entries: make(chan logger.Entry, logStreamBuffer)}
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	o.subscribers[s] = struct{}{}
+	return s
+}
+
+func (o *logStreamOutlet) unsubscribe(s *logStreamSubscriber) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	delete(o.subscribers, s)
+}
+
+func newLogStreamEntry(e logger.Entry) LogStreamEntry {
+	fields := make(map[string]string, len(e.Fields))
+	for k, v := range e.Fields {
+		fields[k] = fmt.Sprint(v)
+	}
+	return LogStreamEntry{Time: e.Time, Level: e.Level, Message: e.Message, Fields: fields}
+}
+
+// serveLogStream streams the log entries that match the request until the client disconnects or ctx is done.
+// It takes over the connection because the write timeout of the control server would end the stream.
+func serveLogStream(ctx context.Context, log Logger, jobs *jobs, w http.ResponseWriter, r *http.Request) {
+	var req LogStreamRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "decode failed", http.StatusBadRequest)
+		return
+	}
+	if req.Job != "" {
+		jobs.m.RLock()
+		_, ok := jobs.jobs[req.Job]
+		jobs.m.RUnlock()
+		if !ok {
+			http.Error(w, fmt.Sprintf("Job %s does not exist", req.Job), http.StatusBadRequest)
+			return
+		}
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection does not support streaming", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		log.WithError(err).Error("cannot take over connection for log stream")
+		return
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		log.WithError(err).Error("cannot reset deadline of log stream connection")
+		return
+	}
+
+	s := logStreams.subscribe(req)
+	defer logStreams.unsubscribe(s)
+
+	// the client sends nothing after the request, reading returns when it disconnects
+	disconnected := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(ioutil.Discard, rw)
+		close(disconnected)
+	}()
+
+	_, err = io.WriteString(rw, "HTTP/1.1 200 OK\r\nContent-Type: application/x-ndjson\r\nConnection: close\r\n\r\n")
+	if err == nil {
+		err = rw.Flush()
+	}
+	enc := json.NewEncoder(rw)
+	for err == nil {
+		select {
+		case <-ctx.Done():
+			return
+		case <-disconnected:
+			return
+		case e := <-s.entries:
+			if dropped := atomic.SwapUint64(&s.dropped, 0); dropped > 0 {
+				err = enc.Encode(LogStreamEntry{
+					Time:    time.Now(),
+					Level:   logger.Warn,
+					Message: fmt.Sprintf("dropped %d log entries because the client did not keep up", dropped),
+				})
+				if err != nil {
+					break
+				}
+			}
+			if err = enc.Encode(newLogStreamEntry(e)); err == nil {
+				err = rw.Flush()
+			}
+		}
+	}
+	log.WithError(err).Debug("log stream ended")
+}
@@ -0,0 +1,73 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/job/disable"
+	"github.com/zrepl/zrepl/daemon/logging"
+	"github.com/zrepl/zrepl/logger"
+)
+
+func TestLogStream(t *testing.T) {
+	s := newJobs()
+	s.jobs["foo"] = &controlJob{}
+	s.switches["foo"] = &disable.Switch{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveLogStream(ctx, logger.NewNullLogger(), s, w, r)
+	}))
+	defer srv.Close()
+
+	subscribers := func() int {
+		logStreams.mtx.Lock()
+		defer logStreams.mtx.Unlock()
+		return len(logStreams.subscribers)
+	}
+	waitSubscribers := func(n int) {
+		for i := 0; subscribers() != n; i++ {
+			require.True(t, i < 100, "subscribers: %d, expected %d", subscribers(), n)
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(`{"Job": "nonexistent"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = http.Post(srv.URL, "application/json", strings.NewReader(`{"Job": "foo", "Level": "info"}`))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	waitSubscribers(1)
+
+	entry := func(level logger.Level, job string, msg string) logger.Entry {
+		return logger.Entry{Level: level, Message: msg, Time: time.Now(), Fields: logger.Fields{
+			logging.JobField:  job,
+			logger.FieldError: fmt.Errorf("some error"),
+		}}
+	}
+	require.NoError(t, logStreams.WriteEntry(entry(logger.Debug, "foo", "too verbose")))
+	require.NoError(t, logStreams.WriteEntry(entry(logger.Error, "bar", "other job")))
+	require.NoError(t, logStreams.WriteEntry(entry(logger.Info, "foo", "streamed")))
+
+	var e LogStreamEntry
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&e))
+	assert.Equal(t, logger.Info, e.Level)
+	assert.Equal(t, "streamed", e.Message)
+	assert.Equal(t, map[string]string{logging.JobField: "foo", logger.FieldError: "some error"}, e.Fields)
+
+	resp.Body.Close()
+	waitSubscribers(0)
+}
@@ -3,6 +3,9 @@ package daemon
 import (
 	"context"
 
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
 	"github.com/zrepl/zrepl/cli"
 	"github.com/zrepl/zrepl/logger"
 )
@@ -13,6 +16,27 @@ var DaemonCmd = &cli.Subcommand{
 	Use:   "daemon",
 	Short: "run the zrepl daemon",
 	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
-		return Run(ctx, subcommand.Config())
+		return Run(ctx, subcommand.Config(), subcommand.ReloadConfig)
+	},
+}
+
+var runJobArgs struct {
+	once    bool
+	verbose bool
+}
+
+var RunJobCmd = &cli.Subcommand{
+	Use:          "run [--once] JOB",
+	CompleteArgs: cli.CompleteNthArg(0, cli.ConfigJobNames),
+	Short:        "run a single job in the foreground, without daemon, logging to stdout",
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.BoolVar(&runJobArgs.once, "once", false, "snapshot, replicate and prune once, then exit (exit code 1: invocation failed, 2: job cannot be run, 3: interrupted)")
+		f.BoolVarP(&runJobArgs.verbose, "verbose", "v", false, "log debug messages")
+	},
+	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+		if len(args) != 1 {
+			return &cli.ExitCodeError{Code: RunJobExitCannotRun, Err: errors.New("Expected 1 argument: JOB")}
+		}
+		return RunJob(ctx, subcommand.Config(), args[0], runJobArgs.once, runJobArgs.verbose)
 	},
 }
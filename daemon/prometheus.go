@@ -4,13 +4,17 @@ import (
 	"context"
 	"net"
 	"net/http"
+	"strings"
+	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/zrepl/zrepl/config"
 	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/job/ready"
 	"github.com/zrepl/zrepl/daemon/logging"
+	"github.com/zrepl/zrepl/daemon/snapper"
 	"github.com/zrepl/zrepl/endpoint"
 	"github.com/zrepl/zrepl/logger"
 	"github.com/zrepl/zrepl/rpc/dataconn/frameconn"
@@ -21,13 +25,15 @@ import (
 type prometheusJob struct {
 	listen   string
 	freeBind bool
+	// for the /healthz and /readyz endpoints
+	jobs *jobs
 }
 
-func newPrometheusJobFromConfig(in *config.PrometheusMonitoring) (*prometheusJob, error) {
+func newPrometheusJobFromConfig(in *config.PrometheusMonitoring, jobs *jobs) (*prometheusJob, error) {
 	if _, _, err := net.SplitHostPort(in.Listen); err != nil {
 		return nil, err
 	}
-	return &prometheusJob{in.Listen, in.ListenFreeBind}, nil
+	return &prometheusJob{in.Listen, in.ListenFreeBind, jobs}, nil
 }
 
 var prom struct {
@@ -52,17 +58,48 @@ func (j *prometheusJob) OwnedDatasetSubtreeRoot() (p *zfs.DatasetPath, ok bool)
 
 func (j *prometheusJob) SenderConfig() *endpoint.SenderConfig { return nil }
 
+func (j *prometheusJob) Snapper() (snapper.Snapper, zfs.DatasetFilter, bool) { return nil, nil, false }
+
 func (j *prometheusJob) RegisterMetrics(registerer prometheus.Registerer) {}
 
-func (j *prometheusJob) Run(ctx context.Context) {
+// registerGlobalMetricsOnce guards the registration of global metrics
+// because the job is restarted if the monitoring config changes on reload,
+// and because the control job serves them, too.
+var registerGlobalMetricsOnce sync.Once
+
+func registerGlobalMetrics() {
+	registerGlobalMetricsOnce.Do(func() {
+		if err := zfs.PrometheusRegister(prometheus.DefaultRegisterer); err != nil {
+			panic(err)
+		}
+
+		if err := frameconn.PrometheusRegister(prometheus.DefaultRegisterer); err != nil {
+			panic(err)
+		}
+	})
+}
 
-	if err := zfs.PrometheusRegister(prometheus.DefaultRegisterer); err != nil {
-		panic(err)
-	}
+// metricsHandler serves the metrics of the default registry,
+// in the OpenMetrics format with exemplars (see trace.ExemplarLabels) if the scraper accepts it.
+func metricsHandler() http.Handler {
+	return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+}
 
-	if err := frameconn.PrometheusRegister(prometheus.DefaultRegisterer); err != nil {
-		panic(err)
+// metricsMatch returns true if name starts with one of prefixes,
+// see the metrics field of config.StatsdMonitoring and config.MetricsPushCommon.
+func metricsMatch(name string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
 	}
+	return false
+}
+
+func (j *prometheusJob) Run(ctx context.Context) {
+
+	registerGlobalMetrics()
 
 	log := job.GetLogger(ctx)
 
@@ -71,13 +108,16 @@ func (j *prometheusJob) Run(ctx context.Context) {
 		log.WithError(err).Error("cannot listen")
 		return
 	}
+	ready.Signal(ctx)
 	go func() {
 		<-ctx.Done()
 		l.Close()
 	}()
 
 	mux := http.NewServeMux()
-	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/metrics", metricsHandler())
+	mux.HandleFunc("/healthz", j.jobs.handleHealthz)
+	mux.HandleFunc("/readyz", j.jobs.handleReadyz)
 
 	err = http.Serve(l, mux)
 	if err != nil && ctx.Err() == nil {
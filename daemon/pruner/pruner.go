@@ -6,6 +6,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/zrepl/zrepl/config"
 	"github.com/zrepl/zrepl/daemon/logging"
+	"github.com/zrepl/zrepl/endpoint"
 	"github.com/zrepl/zrepl/logger"
 	"github.com/zrepl/zrepl/pruning"
 	"github.com/zrepl/zrepl/replication/logic/pdu"
@@ -30,6 +32,16 @@ type Sender interface {
 	ListFilesystems(ctx context.Context, req *pdu.ListFilesystemReq) (*pdu.ListFilesystemRes, error)
 }
 
+// A FanOutSender is a Sender that replicates to multiple destinations,
+// e.g., a push job with `destinations`.
+// Its ReplicationCursor is the cursor of the destination that lags behind the most.
+type FanOutSender interface {
+	Sender
+	// ReplicationCursorOfDestinations returns the most recent replication cursor
+	// that at least n destinations have reached.
+	ReplicationCursorOfDestinations(ctx context.Context, req *pdu.ReplicationCursorReq, n int) (*pdu.ReplicationCursorRes, error)
+}
+
 // The pruning target, i.e., on which snapshots are destroyed.
 // This can be a replication sender or receiver.
 //
@@ -38,6 +50,7 @@ type Target interface {
 	ListFilesystems(ctx context.Context, req *pdu.ListFilesystemReq) (*pdu.ListFilesystemRes, error)
 	ListFilesystemVersions(ctx context.Context, req *pdu.ListFilesystemVersionsReq) (*pdu.ListFilesystemVersionsRes, error)
 	DestroySnapshots(ctx context.Context, req *pdu.DestroySnapshotsReq) (*pdu.DestroySnapshotsRes, error)
+	SetPruneMarks(ctx context.Context, req *pdu.SetPruneMarksReq) (*pdu.SetPruneMarksRes, error)
 }
 
 type Logger = logger.Logger
@@ -46,21 +59,36 @@ type contextKey int
 
 const (
 	contextKeyPruneSide contextKey = 1 + iota
+	contextKeyPeer
 )
 
+// WithPeer returns a context for building pruners whose SnapshotMetrics have the given peer label,
+// e.g., the name of a destination or source of a job.
+func WithPeer(ctx context.Context, peer string) context.Context {
+	return context.WithValue(ctx, contextKeyPeer, peer)
+}
+
 func GetLogger(ctx context.Context) Logger {
 	pruneSide := ctx.Value(contextKeyPruneSide).(string)
 	return logging.GetLogger(ctx, logging.SubsysPruning).WithField("prune_side", pruneSide)
 }
 
 type args struct {
-	ctx                            context.Context
-	target                         Target
-	sender                         Sender
-	rules                          []pruning.KeepRule
-	retryWait                      time.Duration
-	considerSnapAtCursorReplicated bool
-	promPruneSecs                  prometheus.Observer
+	ctx           context.Context
+	target        Target
+	sender        Sender
+	rules         *keepRules
+	bookmarkRules *ruleSet // nil if bookmarks are not pruned
+	minAge        time.Duration
+	retryWait     time.Duration
+	destroy       destroyOptions
+	promPruneSecs prometheus.Observer
+	snapshots     *SnapshotMetrics // nil if not exported
+	dryRun        bool
+
+	// number of destroy requests sent so far, for destroyOptions.pacing
+	// (accessed atomically because filesystems are pruned concurrently)
+	destroyRequests int64
 }
 
 type Pruner struct {
@@ -78,68 +106,150 @@ type Pruner struct {
 }
 
 type PrunerFactory struct {
-	senderRules                    []pruning.KeepRule
-	receiverRules                  []pruning.KeepRule
-	retryWait                      time.Duration
-	considerSnapAtCursorReplicated bool
-	promPruneSecs                  *prometheus.HistogramVec
+	senderRules           *keepRules
+	receiverRules         *keepRules
+	senderBookmarkRules   *ruleSet
+	receiverBookmarkRules *ruleSet
+	minAge                time.Duration
+	retryWait             time.Duration
+	destroy               destroyOptions
+	promPruneSecs         *prometheus.HistogramVec
+	snapshots             *SnapshotMetrics
 }
 
 type LocalPrunerFactory struct {
-	keepRules     []pruning.KeepRule
+	keepRules     *keepRules
+	bookmarkRules *ruleSet
+	minAge        time.Duration
 	retryWait     time.Duration
+	destroy       destroyOptions
 	promPruneSecs *prometheus.HistogramVec
+	snapshots     *SnapshotMetrics
 }
 
-func NewLocalPrunerFactory(in config.PruningLocal, promPruneSecs *prometheus.HistogramVec) (*LocalPrunerFactory, error) {
-	rules, err := pruning.RulesFromConfig(in.Keep)
+func NewLocalPrunerFactory(in config.PruningLocal, promPruneSecs *prometheus.HistogramVec, snapshots *SnapshotMetrics) (*LocalPrunerFactory, error) {
+	rs, err := localRuleSetFromConfig("keep", in.Keep)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot build pruning rules")
 	}
-	for _, r := range in.Keep {
-		if _, ok := r.Ret.(*config.PruneKeepNotReplicated); ok {
-			// rule NotReplicated  for a local pruner doesn't make sense
-			// because no replication happens with that job type
-			return nil, fmt.Errorf("single-site pruner cannot support `not_replicated` keep rule")
+	rules := &keepRules{dflt: rs}
+	for i, o := range in.Overrides {
+		f, err := overrideFilterFromConfig(i, o.Filesystems)
+		if err != nil {
+			return nil, err
+		}
+		rs, err := localRuleSetFromConfig(fmt.Sprintf("overrides[%d].keep", i), o.Keep)
+		if err != nil {
+			return nil, errors.Wrapf(err, "override #%d: cannot build pruning rules", i)
 		}
+		rules.overrides = append(rules.overrides, keepRulesOverride{f, rs})
+	}
+	var bookmarkRules *ruleSet
+	if in.Bookmarks != nil {
+		if bookmarkRules, err = bookmarkRuleSetFromConfig("bookmarks.keep", in.Bookmarks.Keep); err != nil {
+			return nil, errors.Wrap(err, "cannot build bookmark pruning rules")
+		}
+	}
+	destroy, err := destroyOptionsFromConfig(in.Destroy)
+	if err != nil {
+		return nil, errors.Wrap(err, "field `destroy`")
 	}
 	f := &LocalPrunerFactory{
 		keepRules:     rules,
+		bookmarkRules: bookmarkRules,
+		minAge:        in.MinAge,
 		retryWait:     envconst.Duration("ZREPL_PRUNER_RETRY_INTERVAL", 10*time.Second),
+		destroy:       destroy,
 		promPruneSecs: promPruneSecs,
+		snapshots:     snapshots,
 	}
 	return f, nil
 }
 
-func NewPrunerFactory(in config.PruningSenderReceiver, promPruneSecs *prometheus.HistogramVec) (*PrunerFactory, error) {
-	keepRulesReceiver, err := pruning.RulesFromConfig(in.KeepReceiver)
+func NewPrunerFactory(in config.PruningSenderReceiver, promPruneSecs *prometheus.HistogramVec, snapshots *SnapshotMetrics) (*PrunerFactory, error) {
+	rsReceiver, err := receiverRuleSetFromConfig("keep_receiver", in.KeepReceiver)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot build receiver pruning rules")
 	}
 
-	keepRulesSender, err := pruning.RulesFromConfig(in.KeepSender)
+	rsSender, err := senderRuleSetFromConfig("keep_sender", in.KeepSender)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot build sender pruning rules")
 	}
 
-	considerSnapAtCursorReplicated := false
-	for _, r := range in.KeepSender {
-		knr, ok := r.Ret.(*config.PruneKeepNotReplicated)
-		if !ok {
-			continue
+	senderRules, receiverRules := &keepRules{dflt: rsSender}, &keepRules{dflt: rsReceiver}
+	for i, o := range in.Overrides {
+		if o.KeepSender == nil && o.KeepReceiver == nil {
+			return nil, fmt.Errorf("override #%d: at least one of `keep_sender` or `keep_receiver` must be specified", i)
+		}
+		f, err := overrideFilterFromConfig(i, o.Filesystems)
+		if err != nil {
+			return nil, err
+		}
+		// an override without rules for a side must still shadow later overrides for that side
+		rsSender, rsReceiver := senderRules.dflt, receiverRules.dflt
+		if o.KeepSender != nil {
+			if rsSender, err = senderRuleSetFromConfig(fmt.Sprintf("overrides[%d].keep_sender", i), o.KeepSender); err != nil {
+				return nil, errors.Wrapf(err, "override #%d: cannot build sender pruning rules", i)
+			}
+		}
+		if o.KeepReceiver != nil {
+			if rsReceiver, err = receiverRuleSetFromConfig(fmt.Sprintf("overrides[%d].keep_receiver", i), o.KeepReceiver); err != nil {
+				return nil, errors.Wrapf(err, "override #%d: cannot build receiver pruning rules", i)
+			}
 		}
-		considerSnapAtCursorReplicated = considerSnapAtCursorReplicated || !knr.KeepSnapshotAtCursor
+		senderRules.overrides = append(senderRules.overrides, keepRulesOverride{f, rsSender})
+		receiverRules.overrides = append(receiverRules.overrides, keepRulesOverride{f, rsReceiver})
+	}
+
+	var senderBookmarkRules, receiverBookmarkRules *ruleSet
+	if in.Bookmarks != nil {
+		if senderBookmarkRules, err = bookmarkRuleSetFromConfig("bookmarks.keep_sender", in.Bookmarks.KeepSender); err != nil {
+			return nil, errors.Wrap(err, "cannot build sender bookmark pruning rules")
+		}
+		if receiverBookmarkRules, err = bookmarkRuleSetFromConfig("bookmarks.keep_receiver", in.Bookmarks.KeepReceiver); err != nil {
+			return nil, errors.Wrap(err, "cannot build receiver bookmark pruning rules")
+		}
+	}
+
+	destroy, err := destroyOptionsFromConfig(in.Destroy)
+	if err != nil {
+		return nil, errors.Wrap(err, "field `destroy`")
 	}
 	f := &PrunerFactory{
-		senderRules:                    keepRulesSender,
-		receiverRules:                  keepRulesReceiver,
-		retryWait:                      envconst.Duration("ZREPL_PRUNER_RETRY_INTERVAL", 10*time.Second),
-		considerSnapAtCursorReplicated: considerSnapAtCursorReplicated,
-		promPruneSecs:                  promPruneSecs,
+		senderRules:           senderRules,
+		receiverRules:         receiverRules,
+		senderBookmarkRules:   senderBookmarkRules,
+		receiverBookmarkRules: receiverBookmarkRules,
+		minAge:                in.MinAge,
+		retryWait:             envconst.Duration("ZREPL_PRUNER_RETRY_INTERVAL", 10*time.Second),
+		destroy:               destroy,
+		promPruneSecs:         promPruneSecs,
+		snapshots:             snapshots,
 	}
 	return f, nil
 }
 
+// ValidateDestinations returns an error if the `min_destinations` of a sender-side
+// `not_replicated` keep rule exceeds the number of destinations that the job replicates to.
+func (f *PrunerFactory) ValidateDestinations(destinations int) error {
+	check := func(field string, rs ruleSet) error {
+		if rs.minDestinations > destinations {
+			return errors.Errorf("%s: `min_destinations` is %d but the job replicates to %d destination(s)", field, rs.minDestinations, destinations)
+		}
+		return nil
+	}
+	if err := check("keep_sender", f.senderRules.dflt); err != nil {
+		return err
+	}
+	for i, o := range f.senderRules.overrides {
+		if err := check(fmt.Sprintf("overrides[%d].keep_sender", i), o.ruleSet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (f *PrunerFactory) BuildSenderPruner(ctx context.Context, target Target, sender Sender) *Pruner {
 	p := &Pruner{
 		args: args{
@@ -147,9 +257,14 @@ func (f *PrunerFactory) BuildSenderPruner(ctx context.Context, target Target, se
 			target,
 			sender,
 			f.senderRules,
+			f.senderBookmarkRules,
+			f.minAge,
 			f.retryWait,
-			f.considerSnapAtCursorReplicated,
+			f.destroy,
 			f.promPruneSecs.WithLabelValues("sender"),
+			f.snapshots,
+			false,
+			0,
 		},
 		state: Plan,
 	}
@@ -163,9 +278,14 @@ func (f *PrunerFactory) BuildReceiverPruner(ctx context.Context, target Target,
 			target,
 			sender,
 			f.receiverRules,
+			f.receiverBookmarkRules,
+			f.minAge,
 			f.retryWait,
-			false, // senseless here anyways
+			f.destroy,
 			f.promPruneSecs.WithLabelValues("receiver"),
+			f.snapshots,
+			false,
+			0,
 		},
 		state: Plan,
 	}
@@ -179,9 +299,14 @@ func (f *LocalPrunerFactory) BuildLocalPruner(ctx context.Context, target Target
 			target,
 			history,
 			f.keepRules,
+			f.bookmarkRules,
+			f.minAge,
 			f.retryWait,
-			false, // considerSnapAtCursorReplicated is not relevant for local pruning
+			f.destroy,
 			f.promPruneSecs.WithLabelValues("local"),
+			f.snapshots,
+			false,
+			0,
 		},
 		state: Plan,
 	}
@@ -215,6 +340,14 @@ func (p *Pruner) Prune() {
 	p.prune(p.args)
 }
 
+// DryRun plans like Prune, but does not destroy any snapshots.
+// Afterwards, the Completed filesystems of Report() contain the plan.
+func (p *Pruner) DryRun() {
+	args := p.args
+	args.dryRun = true
+	p.prune(args)
+}
+
 func (p *Pruner) prune(args args) {
 	u := func(f func(*Pruner)) {
 		p.mtx.Lock()
@@ -238,14 +371,23 @@ type Report struct {
 type FSReport struct {
 	Filesystem                string
 	SnapshotList, DestroyList []SnapshotReport
-	SkipReason                FSSkipReason
-	LastError                 string
+	// empty if bookmarks are not pruned
+	BookmarkList, BookmarkDestroyList []SnapshotReport
+	SkipReason                        FSSkipReason
+	LastError                         string
+	// progress of the destroy lists, the remaining entries are pending
+	Destroyed, DestroyFailed int
+	// only for Report.Pending: the filesystem is being pruned right now
+	Executing bool
 }
 
 type SnapshotReport struct {
 	Name       string
 	Replicated bool
 	Date       time.Time
+	// The keep rules that keep the snapshot, empty if it is destroyed.
+	// Only set for the entries of FSReport.SnapshotList and FSReport.BookmarkList.
+	KeptBy []string
 }
 
 func (p *Pruner) Report() *Report {
@@ -285,14 +427,28 @@ type fs struct {
 	// snapshots presented by target
 	// (type snapshot)
 	snaps []pruning.Snapshot
+	// summary of the snapshots presented by target, nil if they were not listed
+	listed *snapshotStats
 	// destroy list returned by pruning.PruneSnapshots(snaps)
 	// (type snapshot)
 	destroyList []pruning.Snapshot
+	// bookmarks presented by target that are subject to pruning
+	// (type snapshot), nil if bookmarks are not pruned
+	bookmarks           []pruning.Snapshot
+	bookmarkDestroyList []pruning.Snapshot
+	// snapshots to mark for destruction and to unmark, see destroyOptions.gracePeriod
+	// (type snapshot)
+	markList, unmarkList []pruning.Snapshot
+	// names of the rules that keep the snapshots and bookmarks not in the destroy lists
+	keptBy map[pruning.Snapshot][]string
 
 	mtx sync.RWMutex
 
 	// only during Exec state, also used by execQueue
 	execErrLast error
+	// number of entries of destroyList and bookmarkDestroyList
+	// that were destroyed or failed to be destroyed
+	destroyed, destroyFailed int
 }
 
 type FSSkipReason string
@@ -323,10 +479,12 @@ func (f *fs) Report() FSReport {
 	} else if f.execErrLast != nil {
 		r.LastError = f.execErrLast.Error()
 	}
+	r.Destroyed, r.DestroyFailed = f.destroyed, f.destroyFailed
 
 	r.SnapshotList = make([]SnapshotReport, len(f.snaps))
 	for i, snap := range f.snaps {
 		r.SnapshotList[i] = snap.(snapshot).Report()
+		r.SnapshotList[i].KeptBy = f.keptBy[snap]
 	}
 
 	r.DestroyList = make([]SnapshotReport, len(f.destroyList))
@@ -334,9 +492,95 @@ func (f *fs) Report() FSReport {
 		r.DestroyList[i] = snap.(snapshot).Report()
 	}
 
+	for _, bm := range f.bookmarks {
+		rep := bm.(snapshot).Report()
+		rep.KeptBy = f.keptBy[bm]
+		r.BookmarkList = append(r.BookmarkList, rep)
+	}
+	for _, bm := range f.bookmarkDestroyList {
+		r.BookmarkDestroyList = append(r.BookmarkDestroyList, bm.(snapshot).Report())
+	}
+
 	return r
 }
 
+func (f *fs) addKeptBy(rs *ruleSet, keptBy map[pruning.Snapshot][]int) {
+	if f.keptBy == nil {
+		f.keptBy = make(map[pruning.Snapshot][]string, len(keptBy))
+	}
+	for snap, ruleIdxs := range keptBy {
+		for _, i := range ruleIdxs {
+			f.keptBy[snap] = append(f.keptBy[snap], rs.names[i])
+		}
+	}
+}
+
+// KeptByCloneOrigin is the SnapshotReport.KeptBy entry of snapshots that the
+// keep rules would destroy but that are retained because they are the origin of a clone.
+const KeptByCloneOrigin = "retained: clone origin"
+
+// retainCloneOrigins removes the origins of clones from f.destroyList:
+// zfs refuses to destroy them, and we do not want to destroy or promote the clones.
+func (f *fs) retainCloneOrigins() (retained []pruning.Snapshot) {
+	return f.retain(KeptByCloneOrigin, func(s snapshot) bool {
+		return s.fsv.GetIsCloneOrigin()
+	})
+}
+
+// KeptByMinAge is the SnapshotReport.KeptBy entry of snapshots that the
+// keep rules would destroy but that are retained because they are younger than `min_age`.
+const KeptByMinAge = "retained: min_age"
+
+// retainYoungerThan removes the snapshots created less than minAge before now from f.destroyList.
+// It protects against keep rules that are misconfigured to destroy recent snapshots.
+func (f *fs) retainYoungerThan(minAge time.Duration, now time.Time) (retained []pruning.Snapshot) {
+	return f.retain(KeptByMinAge, func(s snapshot) bool {
+		return now.Sub(s.date) < minAge
+	})
+}
+
+// retain removes the snapshots for which keep returns true from f.destroyList
+// and records keptBy for them.
+func (f *fs) retain(keptBy string, keep func(snapshot) bool) (retained []pruning.Snapshot) {
+	destroyList := make([]pruning.Snapshot, 0, len(f.destroyList))
+	for _, snap := range f.destroyList {
+		if !keep(snap.(snapshot)) {
+			destroyList = append(destroyList, snap)
+			continue
+		}
+		if f.keptBy == nil {
+			f.keptBy = make(map[pruning.Snapshot][]string)
+		}
+		f.keptBy[snap] = append(f.keptBy[snap], keptBy)
+		retained = append(retained, snap)
+	}
+	f.destroyList = destroyList
+	return retained
+}
+
+// planBookmarks applies rs to the bookmarks in tfsvs.
+// Replication cursor bookmarks are not subject to pruning.
+func (f *fs) planBookmarks(rs *ruleSet, tfsvs []*pdu.FilesystemVersion) error {
+	f.bookmarks = make([]pruning.Snapshot, 0)
+	for _, tfsv := range tfsvs {
+		if tfsv.Type != pdu.FilesystemVersion_Bookmark {
+			continue
+		}
+		if endpoint.IsReplicationCursorBookmarkName(tfsv.Name) {
+			continue
+		}
+		creation, err := tfsv.CreationAsTime()
+		if err != nil {
+			return fmt.Errorf("%s: %s", tfsv.RelName(), err)
+		}
+		f.bookmarks = append(f.bookmarks, snapshot{date: creation, fsv: tfsv})
+	}
+	var keptBy map[pruning.Snapshot][]int
+	f.bookmarkDestroyList, keptBy = pruning.PruneSnapshotsExplain(f.bookmarks, rs.rules)
+	f.addKeptBy(rs, keptBy)
+	return nil
+}
+
 type snapshot struct {
 	replicated bool
 	date       time.Time
@@ -359,6 +603,10 @@ func (s snapshot) Replicated() bool { return s.replicated }
 
 func (s snapshot) Date() time.Time { return s.date }
 
+func (s snapshot) Used() uint64 { return s.fsv.GetUsed() }
+
+func (s snapshot) Holds() []string { return s.fsv.GetHolds() }
+
 func doOneAttempt(a *args, u updater) {
 
 	ctx, target, sender := a.ctx, a.target, a.sender
@@ -415,12 +663,19 @@ tfss_loop:
 			l.WithField("orig_err_type", t).WithError(err).Error(fmt.Sprintf("%s: plan error, skipping filesystem", message))
 		}
 
-		tfsvsres, err := target.ListFilesystemVersions(ctx, &pdu.ListFilesystemVersionsReq{Filesystem: tfs.Path})
+		rs, err := a.rules.forFilesystem(tfs.Path)
+		if err != nil {
+			pfsPlanErrAndLog(err, "cannot determine pruning rules")
+			continue tfss_loop
+		}
+
+		tfsvsres, err := target.ListFilesystemVersions(ctx, &pdu.ListFilesystemVersionsReq{Filesystem: tfs.Path, WithHolds: rs.withHolds})
 		if err != nil {
 			pfsPlanErrAndLog(err, "cannot list filesystem versions")
 			continue tfss_loop
 		}
 		tfsvs := tfsvsres.GetVersions()
+		pfs.listed = snapshotStatsOfVersions(tfsvs)
 		// no progress here since we could run in a live-lock (must have used target AND receiver before progress)
 
 		pfs.snaps = make([]pruning.Snapshot, 0, len(tfsvs))
@@ -428,7 +683,7 @@ tfss_loop:
 		rcReq := &pdu.ReplicationCursorReq{
 			Filesystem: tfs.Path,
 		}
-		rc, err := sender.ReplicationCursor(ctx, rcReq)
+		rc, err := replicationCursor(ctx, sender, rcReq, rs.minDestinations)
 		if err != nil {
 			pfsPlanErrAndLog(err, "cannot get replication cursor bookmark")
 			continue tfss_loop
@@ -468,7 +723,7 @@ tfss_loop:
 			atCursor := tfsv.Guid == rc.GetGuid()
 			preCursor = preCursor && !atCursor
 			pfs.snaps = append(pfs.snaps, snapshot{
-				replicated: preCursor || (a.considerSnapAtCursorReplicated && atCursor),
+				replicated: preCursor || (rs.considerSnapAtCursorReplicated && atCursor),
 				date:       creation,
 				fsv:        tfsv,
 			})
@@ -479,26 +734,53 @@ tfss_loop:
 		}
 
 		// Apply prune rules
-		pfs.destroyList = pruning.PruneSnapshots(pfs.snaps, a.rules)
+		rules := pruning.RulesForFilesystem(rs.rules, pruning.Filesystem{UsedBySnapshots: tfs.GetUsedBySnapshots()})
+		var keptBy map[pruning.Snapshot][]int
+		pfs.destroyList, keptBy = pruning.PruneSnapshotsExplain(pfs.snaps, rules)
+		pfs.addKeptBy(&rs, keptBy)
+		for _, snap := range pfs.retainCloneOrigins() {
+			l.WithField("snap", snap.Name()).Info("retaining snapshot that is the origin of a clone")
+		}
+		if a.minAge > 0 {
+			if retained := pfs.retainYoungerThan(a.minAge, time.Now()); len(retained) > 0 {
+				l.WithField("min_age", a.minAge).
+					WithField("count", len(retained)).
+					Warn("keep rules destroy snapshots younger than min_age, retaining them")
+			}
+		}
+		if a.destroy.gracePeriod > 0 {
+			pfs.applyGracePeriod(a.destroy.gracePeriod, time.Now())
+		}
+
+		if a.bookmarkRules != nil {
+			if err := pfs.planBookmarks(a.bookmarkRules, tfsvs); err != nil {
+				pfsPlanErrAndLog(err, "cannot plan bookmark pruning")
+				continue tfss_loop
+			}
+		}
+	}
+
+	if deferred := limitDestroys(pfss, a.destroy.maxPerRun); deferred > 0 {
+		GetLogger(ctx).
+			WithField("max_per_run", a.destroy.maxPerRun).
+			WithField("deferred", deferred).
+			Info("destroy limit reached, deferring remaining destroys to a later run")
 	}
 
 	u(func(pruner *Pruner) {
 		pruner.execQueue = newExecQueue(len(pfss))
 		for _, pfs := range pfss {
-			pruner.execQueue.Put(pfs, nil, false)
+			// in dry-run mode, the plan is the final result
+			pruner.execQueue.Put(pfs, nil, a.dryRun)
 		}
 		pruner.state = Exec
 	})
 
-	for {
-		var pfs *fs
-		u(func(pruner *Pruner) {
-			pfs = pruner.execQueue.Pop()
-		})
-		if pfs == nil {
-			break
-		}
-		doOneAttemptExec(a, u, pfs)
+	doExec(a, u)
+
+	if a.snapshots != nil && !a.dryRun {
+		peer, _ := ctx.Value(contextKeyPeer).(string)
+		a.snapshots.update(ctx.Value(contextKeyPruneSide).(string), peer, pfss)
 	}
 
 	var rep *Report
@@ -527,46 +809,157 @@ tfss_loop:
 
 }
 
+// doExec executes the filesystems in the exec queue, destroy.concurrency of them in parallel.
+func doExec(a *args, u updater) {
+	concurrency := a.destroy.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				var pfs *fs
+				u(func(pruner *Pruner) {
+					pfs = pruner.execQueue.Pop()
+				})
+				if pfs == nil {
+					return
+				}
+				doOneAttemptExec(a, u, pfs)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// replicationCursor returns the cursor that at least minDestinations destinations of
+// sender have reached, or that all of them have reached if minDestinations is 0.
+func replicationCursor(ctx context.Context, sender Sender, req *pdu.ReplicationCursorReq, minDestinations int) (*pdu.ReplicationCursorRes, error) {
+	if minDestinations == 0 {
+		return sender.ReplicationCursor(ctx, req)
+	}
+	if fs, ok := sender.(FanOutSender); ok {
+		return fs.ReplicationCursorOfDestinations(ctx, req, minDestinations)
+	}
+	if minDestinations > 1 {
+		return nil, errors.Errorf("`min_destinations` is %d but the job replicates to a single destination", minDestinations)
+	}
+	return sender.ReplicationCursor(ctx, req)
+}
+
 // attempts to exec pfs, puts it back into the queue with the result
 func doOneAttemptExec(a *args, u updater, pfs *fs) {
+	err := setPruneMarks(a, pfs)
+	if err == nil {
+		err = destroyVersions(a, pfs, pfs.destroyList, "destroy_snap", "policy destroys snapshot")
+	}
+	// bookmarks are destroyed in a separate request so that snapshot pruning
+	// works with targets that do not support destroying bookmarks
+	if err == nil && len(pfs.bookmarkDestroyList) > 0 {
+		err = destroyVersions(a, pfs, pfs.bookmarkDestroyList, "destroy_bookmark", "policy destroys bookmark")
+	}
+	u(func(pruner *Pruner) {
+		pruner.execQueue.Put(pfs, err, err == nil)
+	})
+	if err != nil {
+		GetLogger(a.ctx).WithError(err).Error("target could not destroy snapshots")
+		return
+	}
+}
 
-	destroyList := make([]*pdu.FilesystemVersion, len(pfs.destroyList))
+func setPruneMarks(a *args, pfs *fs) error {
+	if len(pfs.markList) == 0 && len(pfs.unmarkList) == 0 {
+		return nil
+	}
+	l := GetLogger(a.ctx).WithField("fs", pfs.path)
+	req := pdu.SetPruneMarksReq{Filesystem: pfs.path}
+	for _, snap := range pfs.markList {
+		req.Mark = append(req.Mark, snap.(snapshot).fsv)
+		l.WithField("snap", snap.Name()).
+			WithField("grace_period", a.destroy.gracePeriod).
+			Info("marking snapshot for destruction after grace period")
+	}
+	for _, snap := range pfs.unmarkList {
+		req.Unmark = append(req.Unmark, snap.(snapshot).fsv)
+		l.WithField("snap", snap.Name()).Info("keep rules keep snapshot that is marked for destruction, removing mark")
+	}
+	res, err := a.target.SetPruneMarks(a.ctx, &req)
+	if err != nil {
+		return errors.Wrap(err, "cannot mark snapshots for destruction")
+	}
+	var failed []string
+	for _, r := range res.GetResults() {
+		if r.GetError() != "" {
+			failed = append(failed, fmt.Sprintf("(%s: %s)", r.GetSnapshot().RelName(), r.GetError()))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("marking snapshots for destruction failed: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func destroyVersions(a *args, pfs *fs, versions []pruning.Snapshot, logField, logMsg string) error {
+	if len(versions) == 0 {
+		return nil
+	}
+	for _, batch := range a.destroy.batches(versions) {
+		if atomic.AddInt64(&a.destroyRequests, 1) > 1 && a.destroy.pacing > 0 {
+			select {
+			case <-time.After(a.destroy.pacing):
+			case <-a.ctx.Done():
+				return a.ctx.Err()
+			}
+		}
+		if err := destroyBatch(a, pfs, batch, logField, logMsg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func destroyBatch(a *args, pfs *fs, versions []pruning.Snapshot, logField, logMsg string) error {
+
+	destroyList := make([]*pdu.FilesystemVersion, len(versions))
 	for i := range destroyList {
-		destroyList[i] = pfs.destroyList[i].(snapshot).fsv
+		destroyList[i] = versions[i].(snapshot).fsv
 		GetLogger(a.ctx).
 			WithField("fs", pfs.path).
-			WithField("destroy_snap", destroyList[i].Name).
-			Debug("policy destroys snapshot")
+			WithField(logField, destroyList[i].Name).
+			Debug(logMsg)
 	}
 	req := pdu.DestroySnapshotsReq{
 		Filesystem: pfs.path,
 		Snapshots:  destroyList,
+		Ranged:     a.destroy.ranged,
 	}
 	GetLogger(a.ctx).WithField("fs", pfs.path).Debug("destroying snapshots")
 	res, err := a.target.DestroySnapshots(a.ctx, &req)
 	if err != nil {
-		u(func(pruner *Pruner) {
-			pruner.execQueue.Put(pfs, err, false)
-		})
-		return
+		return err
 	}
 	// check if all snapshots were destroyed
 	destroyResults := make(map[string]*pdu.DestroySnapshotRes)
 	for _, fsres := range res.Results {
-		destroyResults[fsres.Snapshot.Name] = fsres
+		destroyResults[fsres.Snapshot.RelName()] = fsres
 	}
-	err = nil
 	destroyFails := make([]*pdu.DestroySnapshotRes, 0)
 	for _, reqDestroy := range destroyList {
-		res, ok := destroyResults[reqDestroy.Name]
+		res, ok := destroyResults[reqDestroy.RelName()]
 		if !ok {
-			err = fmt.Errorf("missing destroy-result for %s", reqDestroy.RelName())
-			break
+			return fmt.Errorf("missing destroy-result for %s", reqDestroy.RelName())
 		} else if res.Error != "" {
 			destroyFails = append(destroyFails, res)
 		}
 	}
-	if err == nil && len(destroyFails) > 0 {
+	pfs.mtx.Lock()
+	pfs.destroyed += len(destroyList) - len(destroyFails)
+	pfs.destroyFailed += len(destroyFails)
+	pfs.mtx.Unlock()
+	if len(destroyFails) > 0 {
 		names := make([]string, len(destroyFails))
 		pairs := make([]string, len(destroyFails))
 		allSame := true
@@ -578,17 +971,10 @@ func doOneAttemptExec(a *args, u updater, pfs *fs) {
 			pairs[i] = fmt.Sprintf("(%s: %s)", relname, destroyFails[i].Error)
 		}
 		if allSame {
-			err = fmt.Errorf("destroys failed %s: %s",
+			return fmt.Errorf("destroys failed %s: %s",
 				strings.Join(names, ", "), lastMsg)
-		} else {
-			err = fmt.Errorf("destroys failed: %s", strings.Join(pairs, ", "))
 		}
+		return fmt.Errorf("destroys failed: %s", strings.Join(pairs, ", "))
 	}
-	u(func(pruner *Pruner) {
-		pruner.execQueue.Put(pfs, err, err == nil)
-	})
-	if err != nil {
-		GetLogger(a.ctx).WithError(err).Error("target could not destroy snapshots")
-		return
-	}
+	return nil
 }
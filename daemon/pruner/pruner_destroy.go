@@ -0,0 +1,148 @@
+package pruner
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/pruning"
+)
+
+// The zero value destroys everything at once, in one destroy request per filesystem.
+type destroyOptions struct {
+	maxPerRun int // 0 means unlimited
+	batchSize int // 0 means one request per filesystem
+	pacing    time.Duration
+	ranged    bool
+	// 0 means that snapshots are destroyed without being marked first
+	gracePeriod time.Duration
+	// 0 means 1, i.e., filesystems are pruned one after another
+	concurrency int
+}
+
+func destroyOptionsFromConfig(in *config.PruningDestroy) (destroyOptions, error) {
+	if in == nil {
+		return destroyOptions{}, nil
+	}
+	if in.MaxPerRun < 0 {
+		return destroyOptions{}, errors.Errorf("`max_per_run` must not be negative, got %d", in.MaxPerRun)
+	}
+	if in.BatchSize < 0 {
+		return destroyOptions{}, errors.Errorf("`batch_size` must not be negative, got %d", in.BatchSize)
+	}
+	return destroyOptions{
+		maxPerRun: in.MaxPerRun,
+		batchSize: in.BatchSize,
+		pacing:    in.Pacing,
+		ranged:    in.Ranges,
+
+		gracePeriod: in.GracePeriod,
+		concurrency: in.Concurrency,
+	}, nil
+}
+
+// KeptByDestroyLimit is the SnapshotReport.KeptBy entry of snapshots and bookmarks that
+// the keep rules destroy but that exceed the destroy limit of the run.
+// They are destroyed by one of the following runs.
+const KeptByDestroyLimit = "deferred: destroy limit"
+
+// limitDestroys shortens the destroy lists of pfss to max versions in total.
+// The limit is distributed round-robin over the filesystems,
+// and within a filesystem, the oldest snapshots are destroyed first, then the oldest bookmarks.
+// Returns the number of versions that are deferred to a later run.
+func limitDestroys(pfss []*fs, max int) (deferred int) {
+	if max <= 0 {
+		return 0
+	}
+	quota := make([]int, len(pfss))
+	for remaining := max; remaining > 0; {
+		progress := false
+		for i, f := range pfss {
+			if remaining > 0 && quota[i] < len(f.destroyList)+len(f.bookmarkDestroyList) {
+				quota[i]++
+				remaining--
+				progress = true
+			}
+		}
+		if !progress {
+			break
+		}
+	}
+	for i, f := range pfss {
+		var d int
+		f.destroyList, d = f.deferDestroys(f.destroyList, quota[i])
+		deferred += d
+		quota[i] -= len(f.destroyList)
+		f.bookmarkDestroyList, d = f.deferDestroys(f.bookmarkDestroyList, quota[i])
+		deferred += d
+	}
+	return deferred
+}
+
+// Returns the n oldest versions of destroyList and marks the others as kept by KeptByDestroyLimit.
+func (f *fs) deferDestroys(destroyList []pruning.Snapshot, n int) (limited []pruning.Snapshot, deferred int) {
+	if len(destroyList) <= n {
+		return destroyList, 0
+	}
+	sorted := make([]pruning.Snapshot, len(destroyList))
+	copy(sorted, destroyList)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Date().Before(sorted[j].Date())
+	})
+	if f.keptBy == nil {
+		f.keptBy = make(map[pruning.Snapshot][]string)
+	}
+	for _, snap := range sorted[n:] {
+		f.keptBy[snap] = append(f.keptBy[snap], KeptByDestroyLimit)
+	}
+	return sorted[:n], len(sorted) - n
+}
+
+// batches splits versions into the batches destroyed by one request each.
+func (o destroyOptions) batches(versions []pruning.Snapshot) [][]pruning.Snapshot {
+	if o.batchSize <= 0 || len(versions) <= o.batchSize {
+		return [][]pruning.Snapshot{versions}
+	}
+	var batches [][]pruning.Snapshot
+	for len(versions) > o.batchSize {
+		batches = append(batches, versions[:o.batchSize])
+		versions = versions[o.batchSize:]
+	}
+	return append(batches, versions)
+}
+
+// KeptByGracePeriod is the SnapshotReport.KeptBy entry of snapshots that the keep rules
+// destroy but whose grace period has not passed yet.
+const KeptByGracePeriod = "marked: grace period"
+
+// applyGracePeriod removes the snapshots that are not marked for destruction since at least
+// gracePeriod from f.destroyList and determines the snapshots to mark and unmark.
+// Marks of snapshots that are kept by the keep rules are removed, i.e., they are rescued.
+func (f *fs) applyGracePeriod(gracePeriod time.Duration, now time.Time) {
+	destroy := make(map[pruning.Snapshot]bool, len(f.destroyList))
+	destroyList := make([]pruning.Snapshot, 0, len(f.destroyList))
+	for _, snap := range f.destroyList {
+		destroy[snap] = true
+		// a mark that does not parse is treated as no mark, i.e., it delays the destroy
+		markedAt, _ := snap.(snapshot).fsv.PruneMarkedAtAsTime()
+		if !markedAt.IsZero() && !now.Before(markedAt.Add(gracePeriod)) {
+			destroyList = append(destroyList, snap)
+			continue
+		}
+		if f.keptBy == nil {
+			f.keptBy = make(map[pruning.Snapshot][]string)
+		}
+		f.keptBy[snap] = append(f.keptBy[snap], KeptByGracePeriod)
+		if markedAt.IsZero() {
+			f.markList = append(f.markList, snap)
+		}
+	}
+	f.destroyList = destroyList
+	for _, snap := range f.snaps {
+		if !destroy[snap] && snap.(snapshot).fsv.GetPruneMarkedAt() != "" {
+			f.unmarkList = append(f.unmarkList, snap)
+		}
+	}
+}
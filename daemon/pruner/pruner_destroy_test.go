@@ -0,0 +1,158 @@
+package pruner
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/pruning"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+)
+
+func TestLimitDestroys(t *testing.T) {
+	v := func(typ pdu.FilesystemVersion_VersionType, name string, creation int64) pruning.Snapshot {
+		return snapshot{date: time.Unix(creation, 0), fsv: &pdu.FilesystemVersion{Type: typ, Name: name}}
+	}
+	snap := func(name string, creation int64) pruning.Snapshot {
+		return v(pdu.FilesystemVersion_Snapshot, name, creation)
+	}
+	a1, a2, a3, b1 := snap("a1", 1), snap("a2", 2), snap("a3", 3), snap("b1", 1)
+	abm := v(pdu.FilesystemVersion_Bookmark, "a0", 0)
+
+	a := &fs{path: "pool/a", destroyList: []pruning.Snapshot{a3, a1, a2}, bookmarkDestroyList: []pruning.Snapshot{abm}}
+	b := &fs{path: "pool/b", destroyList: []pruning.Snapshot{b1}}
+	c := &fs{path: "pool/c"}
+
+	// round-robin: a1, b1, a2, a3; the bookmark of a is destroyed after its snapshots
+	assert.Equal(t, 1, limitDestroys([]*fs{a, b, c}, 4))
+	assert.ElementsMatch(t, []pruning.Snapshot{a1, a2, a3}, a.destroyList)
+	assert.Empty(t, a.bookmarkDestroyList)
+	assert.Equal(t, []string{KeptByDestroyLimit}, a.keptBy[abm])
+	assert.Equal(t, []pruning.Snapshot{b1}, b.destroyList)
+	assert.Empty(t, b.keptBy)
+
+	assert.Equal(t, 2, limitDestroys([]*fs{a, b}, 2))
+	assert.Equal(t, []pruning.Snapshot{a1}, a.destroyList)
+	assert.Equal(t, []string{KeptByDestroyLimit}, a.keptBy[a3])
+
+	assert.Equal(t, 0, limitDestroys([]*fs{a, b}, 0), "0 means unlimited")
+}
+
+func TestDestroyOptions(t *testing.T) {
+	_, err := destroyOptionsFromConfig(&config.PruningDestroy{MaxPerRun: -1})
+	assert.Error(t, err)
+	o, err := destroyOptionsFromConfig(&config.PruningDestroy{BatchSize: 2, Ranges: true})
+	require.NoError(t, err)
+	assert.True(t, o.ranged)
+
+	snaps := []pruning.Snapshot{snapshot{}, snapshot{}, snapshot{}, snapshot{}, snapshot{}}
+	var sizes []int
+	for _, b := range o.batches(snaps) {
+		sizes = append(sizes, len(b))
+	}
+	assert.Equal(t, []int{2, 2, 1}, sizes)
+	assert.Len(t, destroyOptions{}.batches(snaps), 1)
+}
+
+func TestApplyGracePeriod(t *testing.T) {
+	now := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	snap := func(name string, markedAt time.Time) pruning.Snapshot {
+		fsv := &pdu.FilesystemVersion{Type: pdu.FilesystemVersion_Snapshot, Name: name}
+		if !markedAt.IsZero() {
+			fsv.PruneMarkedAt = markedAt.Format(time.RFC3339)
+		}
+		return snapshot{fsv: fsv}
+	}
+	unmarked := snap("unmarked", time.Time{})
+	recent := snap("recent", now.Add(-time.Hour))
+	expired := snap("expired", now.Add(-25*time.Hour))
+	rescued := snap("rescued", now.Add(-25*time.Hour))
+	kept := snap("kept", time.Time{})
+	pfs := &fs{
+		path:        "pool/fs",
+		snaps:       []pruning.Snapshot{unmarked, recent, expired, rescued, kept},
+		destroyList: []pruning.Snapshot{unmarked, recent, expired},
+	}
+
+	pfs.applyGracePeriod(24*time.Hour, now)
+	assert.Equal(t, []pruning.Snapshot{expired}, pfs.destroyList)
+	assert.Equal(t, []pruning.Snapshot{unmarked}, pfs.markList)
+	assert.Equal(t, []pruning.Snapshot{rescued}, pfs.unmarkList)
+	assert.Equal(t, []string{KeptByGracePeriod}, pfs.keptBy[unmarked])
+	assert.Equal(t, []string{KeptByGracePeriod}, pfs.keptBy[recent])
+	assert.Empty(t, pfs.keptBy[kept])
+}
+
+type destroyTargetMock struct {
+	Target
+	mtx       sync.Mutex
+	fail      map[string]bool
+	destroyed []string
+}
+
+func (t *destroyTargetMock) DestroySnapshots(ctx context.Context, req *pdu.DestroySnapshotsReq) (*pdu.DestroySnapshotsRes, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	res := &pdu.DestroySnapshotsRes{}
+	for _, s := range req.Snapshots {
+		r := &pdu.DestroySnapshotRes{Snapshot: s}
+		if t.fail[s.Name] {
+			r.Error = "dataset is busy"
+		} else {
+			t.destroyed = append(t.destroyed, req.Filesystem+"@"+s.Name)
+		}
+		res.Results = append(res.Results, r)
+	}
+	return res, nil
+}
+
+func TestExecConcurrentProgress(t *testing.T) {
+	snap := func(name string) pruning.Snapshot {
+		return snapshot{fsv: &pdu.FilesystemVersion{Type: pdu.FilesystemVersion_Snapshot, Name: name, Creation: time.Unix(0, 0).Format(time.RFC3339)}}
+	}
+	target := &destroyTargetMock{fail: map[string]bool{"b2": true}}
+	a := &args{
+		ctx:     context.WithValue(context.Background(), contextKeyPruneSide, "test"),
+		target:  target,
+		destroy: destroyOptions{batchSize: 1, concurrency: 2},
+	}
+	pfss := []*fs{
+		{path: "pool/a", destroyList: []pruning.Snapshot{snap("a1"), snap("a2")}},
+		{path: "pool/b", destroyList: []pruning.Snapshot{snap("b1"), snap("b2"), snap("b3")}},
+		{path: "pool/c"},
+	}
+	p := &Pruner{execQueue: newExecQueue(len(pfss))}
+	for _, pfs := range pfss {
+		p.execQueue.Put(pfs, nil, false)
+	}
+	u := func(f func(*Pruner)) {
+		p.mtx.Lock()
+		defer p.mtx.Unlock()
+		f(p)
+	}
+
+	require.NotNil(t, p.execQueue.Pop())
+	pending, _ := p.execQueue.Report()
+	require.Len(t, pending, 3, "popped filesystems are still reported")
+	assert.True(t, pending[0].Executing)
+	p.execQueue.Put(pfss[0], nil, false)
+
+	doExec(a, u)
+	assert.ElementsMatch(t, []string{"pool/a@a1", "pool/a@a2", "pool/b@b1"}, target.destroyed)
+	pending, completed := p.execQueue.Report()
+	assert.Empty(t, pending)
+	require.Len(t, completed, 3)
+	byPath := make(map[string]FSReport)
+	for _, r := range completed {
+		byPath[r.Filesystem] = r
+	}
+	assert.Equal(t, 2, byPath["pool/a"].Destroyed)
+	assert.Equal(t, 1, byPath["pool/b"].Destroyed)
+	assert.Equal(t, 1, byPath["pool/b"].DestroyFailed)
+	assert.NotEmpty(t, byPath["pool/b"].LastError)
+}
@@ -0,0 +1,155 @@
+package pruner
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zrepl/zrepl/pruning"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+)
+
+// SnapshotMetrics are the gauges of the snapshots that remain on the prune targets
+// after each pruner run, labels: prune_side, peer (see WithPeer), filesystem.
+type SnapshotMetrics struct {
+	count, oldest, newest *prometheus.GaugeVec
+	// if false, the filesystem label is empty and the gauges aggregate all filesystems
+	perFilesystem bool
+
+	mtx sync.Mutex
+	// filesystems set by the last run, to delete the series of vanished filesystems
+	reported map[snapshotMetricsTarget]map[string]bool
+}
+
+type snapshotMetricsTarget struct{ pruneSide, peer string }
+
+func NewSnapshotMetrics(jobName string, perFilesystem bool) *SnapshotMetrics {
+	gauge := func(name, help string) *prometheus.GaugeVec {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   "zrepl",
+			Subsystem:   "pruning",
+			Name:        name,
+			Help:        help,
+			ConstLabels: prometheus.Labels{"zrepl_job": jobName},
+		}, []string{"prune_side", "peer", "filesystem"})
+	}
+	return &SnapshotMetrics{
+		count:         gauge("snapshots", "number of snapshots on the prune target after the last pruner run"),
+		oldest:        gauge("snapshot_oldest_timestamp", "creation time of the oldest snapshot on the prune target after the last pruner run"),
+		newest:        gauge("snapshot_newest_timestamp", "creation time of the newest snapshot on the prune target after the last pruner run"),
+		perFilesystem: perFilesystem,
+		reported:      make(map[snapshotMetricsTarget]map[string]bool),
+	}
+}
+
+func (m *SnapshotMetrics) Register(registerer prometheus.Registerer) {
+	registerer.MustRegister(m.count)
+	registerer.MustRegister(m.oldest)
+	registerer.MustRegister(m.newest)
+}
+
+type snapshotStats struct {
+	count          int
+	oldest, newest time.Time
+}
+
+func (s *snapshotStats) add(date time.Time) {
+	s.merge(snapshotStats{count: 1, oldest: date, newest: date})
+}
+
+func (s *snapshotStats) merge(o snapshotStats) {
+	if o.count == 0 {
+		return
+	}
+	if s.count == 0 || o.oldest.Before(s.oldest) {
+		s.oldest = o.oldest
+	}
+	if s.count == 0 || o.newest.After(s.newest) {
+		s.newest = o.newest
+	}
+	s.count += o.count
+}
+
+// snapshotStatsOfVersions summarizes the snapshots in fsvs, ignoring those with an invalid creation date.
+func snapshotStatsOfVersions(fsvs []*pdu.FilesystemVersion) *snapshotStats {
+	var s snapshotStats
+	for _, fsv := range fsvs {
+		if fsv.Type != pdu.FilesystemVersion_Snapshot {
+			continue
+		}
+		if creation, err := fsv.CreationAsTime(); err == nil {
+			s.add(creation)
+		}
+	}
+	return &s
+}
+
+// remainingSnapshots returns the stats of the snapshots of f that remain after the pruner run,
+// or nil if the target's snapshots of f are unknown.
+// If planning or destroying failed, these are the snapshots listed during planning.
+func (f *fs) remainingSnapshots() *snapshotStats {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if !f.skipReason.NotSkipped() {
+		return nil
+	}
+	if f.planErr != nil || f.execErrLast != nil {
+		return f.listed
+	}
+	destroyed := make(map[pruning.Snapshot]bool, len(f.destroyList))
+	for _, snap := range f.destroyList {
+		destroyed[snap] = true
+	}
+	var s snapshotStats
+	for _, snap := range f.snaps {
+		if !destroyed[snap] {
+			s.add(snap.Date())
+		}
+	}
+	return &s
+}
+
+func (m *SnapshotMetrics) update(pruneSide, peer string, pfss []*fs) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	set := func(fs string, s snapshotStats) {
+		m.count.WithLabelValues(pruneSide, peer, fs).Set(float64(s.count))
+		if s.count == 0 {
+			m.oldest.DeleteLabelValues(pruneSide, peer, fs)
+			m.newest.DeleteLabelValues(pruneSide, peer, fs)
+			return
+		}
+		m.oldest.WithLabelValues(pruneSide, peer, fs).Set(float64(s.oldest.Unix()))
+		m.newest.WithLabelValues(pruneSide, peer, fs).Set(float64(s.newest.Unix()))
+	}
+
+	if !m.perFilesystem {
+		var total snapshotStats
+		for _, pfs := range pfss {
+			if s := pfs.remainingSnapshots(); s != nil {
+				total.merge(*s)
+			}
+		}
+		set("", total)
+		return
+	}
+
+	reported := make(map[string]bool, len(pfss))
+	for _, pfs := range pfss {
+		if s := pfs.remainingSnapshots(); s != nil {
+			set(pfs.path, *s)
+			reported[pfs.path] = true
+		}
+	}
+	target := snapshotMetricsTarget{pruneSide, peer}
+	for fs := range m.reported[target] {
+		if !reported[fs] {
+			m.count.DeleteLabelValues(pruneSide, peer, fs)
+			m.oldest.DeleteLabelValues(pruneSide, peer, fs)
+			m.newest.DeleteLabelValues(pruneSide, peer, fs)
+		}
+	}
+	m.reported[target] = reported
+}
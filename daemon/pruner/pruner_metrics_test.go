@@ -0,0 +1,59 @@
+package pruner
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/pruning"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+)
+
+func TestSnapshotMetrics(t *testing.T) {
+	t0 := time.Date(2022, 7, 23, 14, 0, 0, 0, time.UTC)
+	snap := func(name string, hoursAgo int) pruning.Snapshot {
+		return snapshot{date: t0.Add(-time.Duration(hoursAgo) * time.Hour), fsv: &pdu.FilesystemVersion{Type: pdu.FilesystemVersion_Snapshot, Name: name}}
+	}
+	a, b, c := snap("a", 3), snap("b", 2), snap("c", 1)
+	pruned := &fs{path: "pool/pruned", snaps: []pruning.Snapshot{a, b, c}, destroyList: []pruning.Snapshot{a}}
+	failed := &fs{path: "pool/failed", snaps: []pruning.Snapshot{a, b}, destroyList: []pruning.Snapshot{a}, execErrLast: errors.New("dataset is busy")}
+	failed.listed = &snapshotStats{count: 2, oldest: a.Date(), newest: b.Date()}
+	empty := &fs{path: "pool/empty", listed: &snapshotStats{}}
+	placeholder := &fs{path: "pool", skipReason: SkipPlaceholder}
+	unlisted := &fs{path: "pool/unlisted", planErr: errors.New("cannot list filesystem versions")}
+
+	gathered := func(r *prometheus.Registry) (n int) {
+		mfs, err := r.Gather()
+		require.NoError(t, err)
+		for _, mf := range mfs {
+			n += len(mf.GetMetric())
+		}
+		return n
+	}
+
+	m := NewSnapshotMetrics("job", true)
+	r := prometheus.NewRegistry()
+	m.Register(r)
+	m.update("receiver", "offsite", []*fs{pruned, failed, empty, placeholder, unlisted})
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.count.WithLabelValues("receiver", "offsite", "pool/pruned")))
+	assert.Equal(t, float64(b.Date().Unix()), testutil.ToFloat64(m.oldest.WithLabelValues("receiver", "offsite", "pool/pruned")))
+	assert.Equal(t, float64(c.Date().Unix()), testutil.ToFloat64(m.newest.WithLabelValues("receiver", "offsite", "pool/pruned")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.count.WithLabelValues("receiver", "offsite", "pool/failed")), "snapshots listed during planning")
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.count.WithLabelValues("receiver", "offsite", "pool/empty")))
+	assert.Equal(t, 3+3+1, gathered(r), "no timestamps for pool/empty, nothing for pool and pool/unlisted")
+
+	m.update("sender", "", []*fs{pruned})
+	m.update("receiver", "offsite", []*fs{failed})
+	assert.Equal(t, 3+3, gathered(r), "vanished filesystems of the receiver are deleted, the sender is unaffected")
+
+	m = NewSnapshotMetrics("job", false)
+	m.update("receiver", "offsite", []*fs{pruned, failed, empty, placeholder, unlisted})
+	assert.Equal(t, 4.0, testutil.ToFloat64(m.count.WithLabelValues("receiver", "offsite", "")))
+	assert.Equal(t, float64(a.Date().Unix()), testutil.ToFloat64(m.oldest.WithLabelValues("receiver", "offsite", "")))
+	assert.Equal(t, float64(c.Date().Unix()), testutil.ToFloat64(m.newest.WithLabelValues("receiver", "offsite", "")))
+}
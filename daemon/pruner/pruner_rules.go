@@ -0,0 +1,175 @@
+package pruner
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/pruning"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// The keep rules that apply to a filesystem.
+type ruleSet struct {
+	rules                          []pruning.KeepRule
+	names                          []string // for reports, rules[i] is described by names[i]
+	considerSnapAtCursorReplicated bool
+	withHolds                      bool // rules need the hold tags of snapshots
+	// see config.PruneKeepNotReplicated.MinDestinations, 0 means all destinations
+	minDestinations int
+}
+
+// keepRules selects the ruleSet of the first override whose filter matches
+// a filesystem, or the default ruleSet if no override matches.
+type keepRules struct {
+	dflt      ruleSet
+	overrides []keepRulesOverride
+}
+
+type keepRulesOverride struct {
+	filter zfs.DatasetFilter
+	ruleSet
+}
+
+func (r *keepRules) forFilesystem(path string) (ruleSet, error) {
+	if len(r.overrides) == 0 {
+		return r.dflt, nil
+	}
+	p, err := zfs.NewDatasetPath(path)
+	if err != nil {
+		return ruleSet{}, errors.Wrap(err, "invalid filesystem path")
+	}
+	for _, o := range r.overrides {
+		pass, err := o.filter.Filter(p)
+		if err != nil {
+			return ruleSet{}, errors.Wrap(err, "cannot apply filesystems filter of pruning override")
+		}
+		if pass {
+			return o.ruleSet, nil
+		}
+	}
+	return r.dflt, nil
+}
+
+// field is the configuration field of in, used to name the rules in reports
+func senderRuleSetFromConfig(field string, in []config.PruningEnum) (ruleSet, error) {
+	rules, err := pruning.RulesFromConfig(in)
+	if err != nil {
+		return ruleSet{}, err
+	}
+	rs := ruleSet{rules: rules, names: ruleNames(field, in), withHolds: needHolds(in)}
+	anyNotReplicated := false
+	for _, r := range in {
+		knr, ok := r.Ret.(*config.PruneKeepNotReplicated)
+		if !ok {
+			continue
+		}
+		rs.considerSnapAtCursorReplicated = rs.considerSnapAtCursorReplicated || !knr.KeepSnapshotAtCursor
+		if knr.MinDestinations < 0 {
+			return ruleSet{}, fmt.Errorf("`min_destinations` must not be negative")
+		}
+		// the strictest rule wins
+		if !anyNotReplicated || knr.MinDestinations == 0 || (rs.minDestinations != 0 && knr.MinDestinations > rs.minDestinations) {
+			rs.minDestinations = knr.MinDestinations
+		}
+		anyNotReplicated = true
+	}
+	return rs, nil
+}
+
+func receiverRuleSetFromConfig(field string, in []config.PruningEnum) (ruleSet, error) {
+	rules, err := pruning.RulesFromConfig(in)
+	if err != nil {
+		return ruleSet{}, err
+	}
+	for _, r := range in {
+		if knr, ok := r.Ret.(*config.PruneKeepNotReplicated); ok && knr.MinDestinations != 0 {
+			return ruleSet{}, fmt.Errorf("`min_destinations` of `not_replicated` keep rule is only supported for the sender")
+		}
+	}
+	// considerSnapAtCursorReplicated and minDestinations are senseless for the receiver
+	return ruleSet{rules: rules, names: ruleNames(field, in), withHolds: needHolds(in)}, nil
+}
+
+func localRuleSetFromConfig(field string, in []config.PruningEnum) (ruleSet, error) {
+	rules, err := pruning.RulesFromConfig(in)
+	if err != nil {
+		return ruleSet{}, err
+	}
+	for _, r := range in {
+		if _, ok := r.Ret.(*config.PruneKeepNotReplicated); ok {
+			// rule NotReplicated  for a local pruner doesn't make sense
+			// because no replication happens with that job type
+			return ruleSet{}, fmt.Errorf("single-site pruner cannot support `not_replicated` keep rule")
+		}
+	}
+	// considerSnapAtCursorReplicated is not relevant for local pruning
+	return ruleSet{rules: rules, names: ruleNames(field, in), withHolds: needHolds(in)}, nil
+}
+
+// Returns nil if in is nil, i.e., if bookmarks shall not be pruned.
+func bookmarkRuleSetFromConfig(field string, in []config.PruningEnum) (*ruleSet, error) {
+	if in == nil {
+		return nil, nil
+	}
+	for i, r := range in {
+		switch r.Ret.(type) {
+		case *config.PruneKeepNotReplicated, *config.PruneKeepSpaceBudget, *config.PruneKeepHold:
+			return nil, fmt.Errorf("cannot build rule #%d: %q keep rule is not supported for bookmarks", i, ruleType(r))
+		}
+	}
+	rules, err := pruning.RulesFromConfig(in)
+	if err != nil {
+		return nil, err
+	}
+	return &ruleSet{rules: rules, names: ruleNames(field, in)}, nil
+}
+
+func overrideFilterFromConfig(i int, in config.FilesystemsFilter) (zfs.DatasetFilter, error) {
+	f, err := filters.DatasetMapFilterFromConfig(in)
+	if err != nil {
+		return nil, errors.Wrapf(err, "override #%d: invalid filesystems filter", i)
+	}
+	return f, nil
+}
+
+// ruleNames describes the rules in in, e.g., "keep_receiver[1] (grid)".
+func ruleNames(field string, in []config.PruningEnum) []string {
+	names := make([]string, len(in))
+	for i, r := range in {
+		names[i] = fmt.Sprintf("%s[%d] (%s)", field, i, ruleType(r))
+	}
+	return names
+}
+
+func needHolds(in []config.PruningEnum) bool {
+	for _, r := range in {
+		if _, ok := r.Ret.(*config.PruneKeepHold); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func ruleType(in config.PruningEnum) string {
+	switch v := in.Ret.(type) {
+	case *config.PruneKeepNotReplicated:
+		return v.Type
+	case *config.PruneKeepLastN:
+		return v.Type
+	case *config.PruneKeepRegex:
+		return v.Type
+	case *config.PruneGrid:
+		return v.Type
+	case *config.PruneKeepSpaceBudget:
+		return v.Type
+	case *config.PruneKeepTiered:
+		return v.Type
+	case *config.PruneKeepHold:
+		return v.Type
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
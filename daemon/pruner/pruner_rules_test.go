@@ -0,0 +1,297 @@
+package pruner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/pruning"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+)
+
+func TestPrunerFactoryOverrides(t *testing.T) {
+	c, err := config.ParseConfigBytes([]byte(`
+jobs:
+- name: foo
+  type: push
+  connect:
+    type: local
+    listener_name: foo
+    client_identity: bar
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep_sender:
+    - type: not_replicated
+    - type: last_n
+      count: 10
+    keep_receiver:
+    - type: last_n
+      count: 14
+    overrides:
+    - filesystems: { "tank/db<": true }
+      keep_receiver:
+      - type: last_n
+        count: 90
+    - filesystems: { "tank/scratch<": true, "tank/db/tmp": true }
+      keep_sender:
+      - type: not_replicated
+        keep_snapshot_at_cursor: false
+`))
+	require.NoError(t, err)
+	f, err := NewPrunerFactory(c.Jobs[0].Ret.(*config.PushJob).Pruning, nil, nil)
+	require.NoError(t, err)
+
+	rule := func(rs ruleSet) pruning.KeepRule {
+		require.Len(t, rs.rules, 1)
+		return rs.rules[0]
+	}
+	lastN := func(n int) pruning.KeepRule { return pruning.MustKeepLastN(n, "") }
+	forFS := func(r *keepRules, fs string) ruleSet {
+		rs, err := r.forFilesystem(fs)
+		require.NoError(t, err)
+		return rs
+	}
+
+	assert.Equal(t, lastN(14), rule(forFS(f.receiverRules, "tank/home")))
+	assert.Equal(t, lastN(90), rule(forFS(f.receiverRules, "tank/db")))
+	// first match wins
+	assert.Equal(t, lastN(90), rule(forFS(f.receiverRules, "tank/db/tmp")))
+	assert.Equal(t, lastN(14), rule(forFS(f.receiverRules, "tank/scratch")))
+
+	assert.Len(t, forFS(f.senderRules, "tank/home").rules, 2)
+	assert.False(t, forFS(f.senderRules, "tank/home").considerSnapAtCursorReplicated)
+	// the first override has no keep_sender, but shadows the second one
+	assert.Len(t, forFS(f.senderRules, "tank/db/tmp").rules, 2)
+	assert.Len(t, forFS(f.senderRules, "tank/scratch/a").rules, 1)
+	assert.True(t, forFS(f.senderRules, "tank/scratch/a").considerSnapAtCursorReplicated)
+}
+
+func TestLocalPrunerFactoryOverrides(t *testing.T) {
+	parse := func(overrides string) (*LocalPrunerFactory, error) {
+		c, err := config.ParseConfigBytes([]byte(`
+jobs:
+- name: foo
+  type: snap
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep:
+    - type: last_n
+      count: 14
+    overrides:
+` + overrides))
+		require.NoError(t, err)
+		return NewLocalPrunerFactory(c.Jobs[0].Ret.(*config.SnapJob).Pruning, nil, nil)
+	}
+
+	f, err := parse(`
+    - filesystems: { "tank/db<": true }
+      keep:
+      - type: last_n
+        count: 90
+`)
+	require.NoError(t, err)
+	rs, err := f.keepRules.forFilesystem("tank/db/data")
+	require.NoError(t, err)
+	assert.Equal(t, []pruning.KeepRule{pruning.MustKeepLastN(90, "")}, rs.rules)
+	assert.False(t, rs.withHolds)
+
+	f, err = parse(`
+    - filesystems: { "tank/db<": true }
+      keep:
+      - type: hold
+        tags: "^admin_"
+`)
+	require.NoError(t, err)
+	rs, err = f.keepRules.forFilesystem("tank/db/data")
+	require.NoError(t, err)
+	assert.True(t, rs.withHolds)
+	assert.Equal(t, []string{"overrides[0].keep[0] (hold)"}, rs.names)
+
+	_, err = parse(`
+    - filesystems: { "tank/db<": true }
+      keep:
+      - type: not_replicated
+`)
+	assert.Error(t, err)
+}
+
+func TestPrunerFactoryBookmarks(t *testing.T) {
+	parse := func(bookmarks string) (*PrunerFactory, error) {
+		c, err := config.ParseConfigBytes([]byte(`
+jobs:
+- name: foo
+  type: push
+  connect:
+    type: local
+    listener_name: foo
+    client_identity: bar
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep_sender:
+    - type: not_replicated
+    keep_receiver:
+    - type: last_n
+      count: 14
+` + bookmarks))
+		require.NoError(t, err)
+		return NewPrunerFactory(c.Jobs[0].Ret.(*config.PushJob).Pruning, nil, nil)
+	}
+
+	f, err := parse("")
+	require.NoError(t, err)
+	assert.Nil(t, f.senderBookmarkRules)
+	assert.Nil(t, f.receiverBookmarkRules)
+
+	f, err = parse(`
+    bookmarks:
+      keep_sender:
+      - type: last_n
+        count: 5
+`)
+	require.NoError(t, err)
+	require.NotNil(t, f.senderBookmarkRules)
+	assert.Equal(t, []pruning.KeepRule{pruning.MustKeepLastN(5, "")}, f.senderBookmarkRules.rules)
+	assert.Equal(t, []string{"bookmarks.keep_sender[0] (last_n)"}, f.senderBookmarkRules.names)
+	assert.Nil(t, f.receiverBookmarkRules)
+
+	_, err = parse(`
+    bookmarks:
+      keep_sender:
+      - type: not_replicated
+`)
+	assert.Error(t, err)
+}
+
+func TestPlanBookmarks(t *testing.T) {
+	v := func(typ pdu.FilesystemVersion_VersionType, name string, creation int64) *pdu.FilesystemVersion {
+		return &pdu.FilesystemVersion{
+			Type:     typ,
+			Name:     name,
+			Creation: pdu.FilesystemVersionCreation(time.Unix(creation, 0)),
+		}
+	}
+	tfsvs := []*pdu.FilesystemVersion{
+		v(pdu.FilesystemVersion_Snapshot, "zrepl_1", 1),
+		v(pdu.FilesystemVersion_Bookmark, "zrepl_1", 1),
+		v(pdu.FilesystemVersion_Bookmark, "zrepl_2", 2),
+		v(pdu.FilesystemVersion_Bookmark, "zrepl_3", 3),
+		v(pdu.FilesystemVersion_Bookmark, "zrepl_CURSOR_G_0000000000000001_J_foo", 1),
+		v(pdu.FilesystemVersion_Bookmark, "zrepl_replication_cursor", 1),
+	}
+	rs := &ruleSet{rules: []pruning.KeepRule{pruning.MustKeepLastN(2, "")}, names: []string{"last_n"}}
+
+	pfs := &fs{path: "pool/fs"}
+	require.NoError(t, pfs.planBookmarks(rs, tfsvs))
+	r := pfs.Report()
+	names := func(l []SnapshotReport) (ns []string) {
+		for _, s := range l {
+			ns = append(ns, s.Name)
+		}
+		return ns
+	}
+	// replication cursors are never pruned
+	assert.Equal(t, []string{"zrepl_1", "zrepl_2", "zrepl_3"}, names(r.BookmarkList))
+	assert.Equal(t, []string{"zrepl_1"}, names(r.BookmarkDestroyList))
+	assert.Equal(t, []string{"last_n"}, r.BookmarkList[2].KeptBy)
+	assert.Empty(t, r.SnapshotList)
+}
+
+func TestRetainCloneOrigins(t *testing.T) {
+	snap := func(name string, isCloneOrigin bool) pruning.Snapshot {
+		return snapshot{fsv: &pdu.FilesystemVersion{Type: pdu.FilesystemVersion_Snapshot, Name: name, IsCloneOrigin: isCloneOrigin}}
+	}
+	a, b, c := snap("a", false), snap("b", true), snap("c", false)
+	pfs := &fs{path: "pool/fs", snaps: []pruning.Snapshot{a, b, c}, destroyList: []pruning.Snapshot{a, b}}
+
+	assert.Equal(t, []pruning.Snapshot{b}, pfs.retainCloneOrigins())
+	assert.Equal(t, []pruning.Snapshot{a}, pfs.destroyList)
+	r := pfs.Report()
+	assert.Equal(t, []string{KeptByCloneOrigin}, r.SnapshotList[1].KeptBy)
+	assert.Empty(t, r.SnapshotList[2].KeptBy)
+}
+
+func TestMinDestinations(t *testing.T) {
+	notReplicated := func(minDestinations int) config.PruningEnum {
+		return config.PruningEnum{Ret: &config.PruneKeepNotReplicated{Type: "not_replicated", MinDestinations: minDestinations}}
+	}
+	for _, tc := range []struct {
+		rules []config.PruningEnum
+		exp   int
+	}{
+		{[]config.PruningEnum{notReplicated(2)}, 2},
+		{[]config.PruningEnum{notReplicated(2), notReplicated(3)}, 3},
+		{[]config.PruningEnum{notReplicated(2), notReplicated(0)}, 0},
+		{[]config.PruningEnum{notReplicated(0), notReplicated(2)}, 0},
+	} {
+		rs, err := senderRuleSetFromConfig("keep_sender", tc.rules)
+		require.NoError(t, err)
+		assert.Equal(t, tc.exp, rs.minDestinations)
+	}
+
+	_, err := senderRuleSetFromConfig("keep_sender", []config.PruningEnum{notReplicated(-1)})
+	assert.Error(t, err)
+	_, err = receiverRuleSetFromConfig("keep_receiver", []config.PruningEnum{notReplicated(2)})
+	assert.Error(t, err)
+}
+
+func TestValidateDestinations(t *testing.T) {
+	f := &PrunerFactory{senderRules: &keepRules{
+		dflt:      ruleSet{minDestinations: 2},
+		overrides: []keepRulesOverride{{ruleSet: ruleSet{minDestinations: 0}}, {ruleSet: ruleSet{minDestinations: 3}}},
+	}}
+	assert.NoError(t, f.ValidateDestinations(3))
+	err := f.ValidateDestinations(2)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "overrides[1].keep_sender")
+	err = f.ValidateDestinations(1)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "keep_sender: ")
+}
+
+type fanOutSenderMock struct {
+	Sender
+	n int
+}
+
+func (s *fanOutSenderMock) ReplicationCursorOfDestinations(ctx context.Context, req *pdu.ReplicationCursorReq, n int) (*pdu.ReplicationCursorRes, error) {
+	s.n = n
+	return &pdu.ReplicationCursorRes{}, nil
+}
+
+func TestReplicationCursorOfDestinations(t *testing.T) {
+	ctx := context.Background()
+	req := &pdu.ReplicationCursorReq{Filesystem: "pool/fs"}
+
+	s := &fanOutSenderMock{}
+	_, err := replicationCursor(ctx, s, req, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.n)
+
+	// a sender that replicates to a single destination satisfies min_destinations 1 only
+	var single Sender = struct{ Sender }{}
+	_, err = replicationCursor(ctx, single, req, 2)
+	assert.Error(t, err)
+}
+
+func TestRetainYoungerThan(t *testing.T) {
+	now := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	snap := func(name string, age time.Duration) pruning.Snapshot {
+		return snapshot{date: now.Add(-age), fsv: &pdu.FilesystemVersion{Type: pdu.FilesystemVersion_Snapshot, Name: name}}
+	}
+	old, young := snap("old", 48*time.Hour), snap("young", time.Hour)
+	pfs := &fs{path: "pool/fs", snaps: []pruning.Snapshot{old, young}, destroyList: []pruning.Snapshot{old, young}}
+
+	assert.Equal(t, []pruning.Snapshot{young}, pfs.retainYoungerThan(24*time.Hour, now))
+	assert.Equal(t, []pruning.Snapshot{old}, pfs.destroyList)
+	assert.Equal(t, []string{KeptByMinAge}, pfs.keptBy[young])
+}
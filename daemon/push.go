@@ -0,0 +1,338 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/job/ready"
+	"github.com/zrepl/zrepl/daemon/snapper"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// pushJob pushes the metrics whenever an invocation of a job finishes (see package finished),
+// for daemons that cannot be scraped.
+type pushJob struct {
+	name string // jobNamePushgateway or jobNameRemoteWrite
+	push *metricsPush
+	jobs *jobs
+	// buffered, a pending push covers all invocations that finished in the meantime
+	finished chan struct{}
+}
+
+func newPushJob(name string, push *metricsPush, jobs *jobs) *pushJob {
+	return &pushJob{name: name, push: push, jobs: jobs, finished: make(chan struct{}, 1)}
+}
+
+func (j *pushJob) Name() string { return j.name }
+
+func (j *pushJob) Status() *job.Status { return &job.Status{Type: job.TypeInternal} }
+
+func (j *pushJob) OwnedDatasetSubtreeRoot() (p *zfs.DatasetPath, ok bool) { return nil, false }
+
+func (j *pushJob) SenderConfig() *endpoint.SenderConfig { return nil }
+
+func (j *pushJob) Snapper() (snapper.Snapper, zfs.DatasetFilter, bool) { return nil, nil, false }
+
+func (j *pushJob) RegisterMetrics(registerer prometheus.Registerer) {}
+
+func (j *pushJob) Run(ctx context.Context) {
+
+	registerGlobalMetrics()
+
+	log := job.GetLogger(ctx)
+
+	j.jobs.addFinishedListener(j.name, j.finished)
+	defer j.jobs.removeFinishedListener(j.name)
+	ready.Signal(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-j.finished:
+		}
+		if err := j.push.push(ctx); err != nil {
+			log.WithError(err).Error("cannot push metrics")
+			continue
+		}
+		log.Debug("pushed metrics")
+	}
+}
+
+// addFinishedListener makes the daemon's jobs wake up c when one of their invocations finishes.
+func (s *jobs) addFinishedListener(name string, c chan<- struct{}) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.finishedListeners[name] = c
+}
+
+func (s *jobs) removeFinishedListener(name string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	delete(s.finishedListeners, name)
+}
+
+func (s *jobs) invocationFinished() {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	for _, c := range s.finishedListeners {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// metricsPush pushes the metrics of the default registry to a Pushgateway,
+// or to a Prometheus remote-write endpoint if remoteWrite is true.
+type metricsPush struct {
+	url         string
+	job         string
+	labels      map[string]string
+	headers     map[string]string
+	timeout     time.Duration
+	metrics     []string // name prefixes
+	remoteWrite bool
+}
+
+func newMetricsPush(in *config.MetricsPushCommon, remoteWrite bool) (*metricsPush, error) {
+	u, err := url.Parse(in.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid url")
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.Errorf("url must be an http or https URL: %q", in.URL)
+	}
+	p := &metricsPush{
+		url:         in.URL,
+		job:         in.Job,
+		labels:      in.Labels,
+		headers:     in.Headers,
+		timeout:     in.Timeout,
+		metrics:     in.Metrics,
+		remoteWrite: remoteWrite,
+	}
+	if len(p.labels) == 0 {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot get hostname for the instance label")
+		}
+		p.labels = map[string]string{"instance": hostname}
+	}
+	for name := range p.labels {
+		if !model.LabelName(name).IsValid() || name == "job" {
+			return nil, errors.Errorf("invalid label name %q", name)
+		}
+	}
+	if len(p.metrics) == 0 {
+		p.metrics = []string{"zrepl_"}
+	}
+	return p, nil
+}
+
+// metricsPushersFromConfig returns the metricsPush of the push monitoring jobs of conf, see RunJob.
+func metricsPushersFromConfig(conf *config.Config) ([]*metricsPush, error) {
+	var pushers []*metricsPush
+	for i, jc := range conf.Global.Monitoring {
+		var (
+			p   *metricsPush
+			err error
+		)
+		switch v := jc.Ret.(type) {
+		case *config.PushgatewayMonitoring:
+			p, err = newMetricsPush(&v.MetricsPushCommon, false)
+		case *config.RemoteWriteMonitoring:
+			p, err = newMetricsPush(&v.MetricsPushCommon, true)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot build monitoring job #%d", i)
+		}
+		pushers = append(pushers, p)
+	}
+	return pushers, nil
+}
+
+func (p *metricsPush) push(ctx context.Context) error {
+	all, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return errors.Wrap(err, "cannot gather metrics")
+	}
+	var mfs []*dto.MetricFamily
+	for _, f := range all {
+		if metricsMatch(f.GetName(), p.metrics) {
+			mfs = append(mfs, f)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	client := &metricsPushClient{ctx, p.headers}
+	if p.remoteWrite {
+		return client.remoteWrite(p.url, remoteWriteRequest(remoteWriteSeries(mfs, p.job, p.labels, time.Now())))
+	}
+	pusher := push.New(p.url, p.job).
+		Gatherer(prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) { return mfs, nil })).
+		Client(client)
+	for name, value := range p.labels {
+		pusher = pusher.Grouping(name, value)
+	}
+	// replaces the metrics of previous pushes, i.e., the Pushgateway has the current state of the daemon
+	return pusher.Push()
+}
+
+type metricsPushClient struct {
+	ctx     context.Context
+	headers map[string]string
+}
+
+var _ push.HTTPDoer = (*metricsPushClient)(nil)
+
+func (c *metricsPushClient) Do(req *http.Request) (*http.Response, error) {
+	req = req.WithContext(c.ctx)
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	return http.DefaultClient.Do(req)
+}
+
+func (c *metricsPushClient) remoteWrite(url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	res, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1<<10))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected HTTP status %q: %s", res.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+type remoteWriteLabel struct{ name, value string }
+
+type remoteWriteSample struct {
+	labels    []remoteWriteLabel // sorted by name, including __name__
+	value     float64
+	timestamp int64 // milliseconds since the epoch
+}
+
+// remoteWriteSeries converts mfs to the samples of the series that Prometheus would scrape:
+// job and labels are added to the series that do not have labels of the same name,
+// labels with an empty value are omitted,
+// and histograms and summaries are split up into their _bucket or quantile, _sum and _count series.
+func remoteWriteSeries(mfs []*dto.MetricFamily, job string, labels map[string]string, now time.Time) []remoteWriteSample {
+	var samples []remoteWriteSample
+	for _, f := range mfs {
+		for _, m := range f.GetMetric() {
+			base := map[string]string{"job": job}
+			for name, value := range labels {
+				base[name] = value
+			}
+			for _, l := range m.GetLabel() {
+				base[l.GetName()] = l.GetValue()
+			}
+			timestamp := now.UnixNano() / int64(time.Millisecond)
+			if m.TimestampMs != nil {
+				timestamp = m.GetTimestampMs()
+			}
+			add := func(suffix string, value float64, extraName, extraValue string) {
+				s := remoteWriteSample{value: value, timestamp: timestamp}
+				s.labels = append(s.labels, remoteWriteLabel{model.MetricNameLabel, f.GetName() + suffix})
+				for name, value := range base {
+					if value != "" {
+						s.labels = append(s.labels, remoteWriteLabel{name, value})
+					}
+				}
+				if extraName != "" {
+					s.labels = append(s.labels, remoteWriteLabel{extraName, extraValue})
+				}
+				sort.Slice(s.labels, func(i, j int) bool { return s.labels[i].name < s.labels[j].name })
+				samples = append(samples, s)
+			}
+			format := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+			switch f.GetType() {
+			case dto.MetricType_COUNTER:
+				add("", m.GetCounter().GetValue(), "", "")
+			case dto.MetricType_GAUGE:
+				add("", m.GetGauge().GetValue(), "", "")
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					if !math.IsInf(b.GetUpperBound(), +1) {
+						add("_bucket", float64(b.GetCumulativeCount()), model.BucketLabel, format(b.GetUpperBound()))
+					}
+				}
+				add("_bucket", float64(h.GetSampleCount()), model.BucketLabel, "+Inf")
+				add("_sum", h.GetSampleSum(), "", "")
+				add("_count", float64(h.GetSampleCount()), "", "")
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add("", q.GetValue(), model.QuantileLabel, format(q.GetQuantile()))
+				}
+				add("_sum", s.GetSampleSum(), "", "")
+				add("_count", float64(s.GetSampleCount()), "", "")
+			default:
+				add("", m.GetUntyped().GetValue(), "", "")
+			}
+		}
+	}
+	return samples
+}
+
+// remoteWriteRequest returns the snappy-compressed protobuf encoding of the remote-write WriteRequest
+// with one TimeSeries per sample.
+func remoteWriteRequest(samples []remoteWriteSample) []byte {
+	bytesField := func(b []byte, num protowire.Number, v []byte) []byte {
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		return protowire.AppendBytes(b, v)
+	}
+	var req []byte
+	for _, s := range samples {
+		var ts []byte
+		for _, l := range s.labels {
+			var label []byte
+			label = bytesField(label, 1, []byte(l.name))
+			label = bytesField(label, 2, []byte(l.value))
+			ts = bytesField(ts, 1, label)
+		}
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(s.value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(s.timestamp))
+		ts = bytesField(ts, 2, sample)
+		req = bytesField(req, 1, ts)
+	}
+	return snappy.Encode(nil, req)
+}
@@ -0,0 +1,174 @@
+package daemon
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/zrepl/zrepl/config"
+)
+
+type pushRequest struct {
+	method, path string
+	header       http.Header
+	body         []byte
+}
+
+func pushTestServer(t *testing.T) (*httptest.Server, <-chan pushRequest) {
+	reqs := make(chan pushRequest, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		reqs <- pushRequest{r.Method, r.URL.Path, r.Header, body}
+	}))
+	return srv, reqs
+}
+
+func TestPushJobPushgateway(t *testing.T) {
+	g := prometheus.NewGauge(prometheus.GaugeOpts{Name: "zrepl_test_push"})
+	prometheus.MustRegister(g)
+	defer prometheus.Unregister(g)
+
+	srv, reqs := pushTestServer(t)
+	defer srv.Close()
+	p, err := newMetricsPush(&config.MetricsPushCommon{
+		URL:     srv.URL,
+		Job:     "zrepl",
+		Labels:  map[string]string{"instance": "host1"},
+		Headers: map[string]string{"Authorization": "Bearer secret"},
+		Timeout: 10 * time.Second,
+		Metrics: []string{"zrepl_test_"},
+	}, false)
+	require.NoError(t, err)
+
+	s := newJobs()
+	j := newPushJob(jobNamePushgateway, p, s)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		j.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	for registered := false; !registered; {
+		s.m.RLock()
+		_, registered = s.finishedListeners[jobNamePushgateway]
+		s.m.RUnlock()
+		time.Sleep(time.Millisecond)
+	}
+	s.invocationFinished()
+	select {
+	case r := <-reqs:
+		assert.Equal(t, http.MethodPut, r.method)
+		assert.Equal(t, "/metrics/job/zrepl/instance/host1", r.path)
+		assert.Equal(t, "Bearer secret", r.header.Get("Authorization"))
+		assert.Contains(t, string(r.body), "zrepl_test_push")
+	case <-time.After(10 * time.Second):
+		t.Fatal("no push after an invocation finished")
+	}
+}
+
+func TestPushRemoteWrite(t *testing.T) {
+	h := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "zrepl_test_remote_write", Buckets: []float64{1}}, []string{"zrepl_job", "empty"})
+	prometheus.MustRegister(h)
+	defer prometheus.Unregister(h)
+	h.WithLabelValues("push", "").Observe(0.5)
+
+	srv, reqs := pushTestServer(t)
+	defer srv.Close()
+	p, err := newMetricsPush(&config.MetricsPushCommon{
+		URL:     srv.URL + "/api/v1/write",
+		Job:     "zrepl",
+		Labels:  map[string]string{"instance": "host1"},
+		Timeout: 10 * time.Second,
+		Metrics: []string{"zrepl_test_"},
+	}, true)
+	require.NoError(t, err)
+	require.NoError(t, p.push(context.Background()))
+	r := <-reqs
+	assert.Equal(t, "/api/v1/write", r.path)
+	assert.Equal(t, "snappy", r.header.Get("Content-Encoding"))
+	assert.Equal(t, "0.1.0", r.header.Get("X-Prometheus-Remote-Write-Version"))
+
+	raw, err := snappy.Decode(nil, r.body)
+	require.NoError(t, err)
+	series := decodeTestWriteRequest(t, raw)
+	base := `instance="host1",job="zrepl"`
+	assert.Equal(t, map[string]float64{
+		`__name__="zrepl_test_remote_write_bucket",` + base + `,le="1",zrepl_job="push"`:    1,
+		`__name__="zrepl_test_remote_write_bucket",` + base + `,le="+Inf",zrepl_job="push"`: 1,
+		`__name__="zrepl_test_remote_write_sum",` + base + `,zrepl_job="push"`:              0.5,
+		`__name__="zrepl_test_remote_write_count",` + base + `,zrepl_job="push"`:            1,
+	}, series, "labels are sorted and empty labels are omitted")
+
+	_, err = newMetricsPush(&config.MetricsPushCommon{URL: "localhost:9091"}, true)
+	assert.Error(t, err)
+	_, err = newMetricsPush(&config.MetricsPushCommon{URL: "http://localhost:9091", Labels: map[string]string{"job": "x"}}, true)
+	assert.Error(t, err)
+}
+
+// decodeTestWriteRequest returns the value of each series of a remote-write WriteRequest,
+// by the series' labels in the order of the message.
+func decodeTestWriteRequest(t *testing.T, b []byte) map[string]float64 {
+	fields := func(b []byte, f func(num protowire.Number, typ protowire.Type, v []byte)) {
+		for len(b) > 0 {
+			num, typ, n := protowire.ConsumeTag(b)
+			require.True(t, n > 0)
+			b = b[n:]
+			m := protowire.ConsumeFieldValue(num, typ, b)
+			require.True(t, m > 0)
+			f(num, typ, b[:m])
+			b = b[m:]
+		}
+	}
+	bytesValue := func(v []byte) []byte {
+		s, n := protowire.ConsumeBytes(v)
+		require.True(t, n > 0)
+		return s
+	}
+	series := make(map[string]float64)
+	fields(b, func(_ protowire.Number, _ protowire.Type, v []byte) {
+		var labels string
+		var value float64
+		fields(bytesValue(v), func(num protowire.Number, _ protowire.Type, v []byte) {
+			switch num {
+			case 1:
+				var name, val string
+				fields(bytesValue(v), func(num protowire.Number, _ protowire.Type, v []byte) {
+					if num == 1 {
+						name = string(bytesValue(v))
+					} else {
+						val = string(bytesValue(v))
+					}
+				})
+				if labels != "" {
+					labels += ","
+				}
+				labels += name + `="` + val + `"`
+			case 2:
+				fields(bytesValue(v), func(num protowire.Number, _ protowire.Type, v []byte) {
+					if num == 1 {
+						bits, _ := protowire.ConsumeFixed64(v)
+						value = math.Float64frombits(bits)
+					}
+				})
+			}
+		})
+		series[labels] = value
+	})
+	return series
+}
@@ -0,0 +1,251 @@
+package daemon
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/rpc/versionhandshake"
+	"github.com/zrepl/zrepl/util/sdnotify"
+)
+
+const (
+	reloadStopPolicyWait  = "wait"
+	reloadStopPolicyAbort = "abort"
+)
+
+// reloader applies a changed config file to the running daemon.
+type reloader struct {
+	confMtx sync.Mutex
+	conf    *config.Config // the running config, protected by confMtx
+	parse   func() (*config.Config, error)
+	jobs    *jobs
+	outlets *logger.Outlets
+}
+
+// reloadPlan is built from a new config before any change is applied,
+// so that an invalid config leaves the running daemon untouched.
+type reloadPlan struct {
+	conf              *config.Config
+	outlets           *logger.Outlets // nil if logging is unchanged
+	restartMonitoring bool
+	monitoringJobs    []job.Job
+	stop              []string  // names of running jobs to stop
+	start             []job.Job // jobs to start after the stopped jobs have exited
+	graceful          bool
+	timeout           time.Duration
+}
+
+func (r *reloader) reload(ctx context.Context, log logger.Logger) error {
+	newConf, err := r.parse()
+	if err != nil {
+		return errors.Wrap(err, "cannot parse config")
+	}
+	p, err := r.plan(newConf)
+	if err != nil {
+		return err
+	}
+	log.WithField("stop", p.stop).WithField("start", len(p.start)).Info("applying configuration")
+	if sdnotify.Enabled() {
+		if _, err := sdnotify.Notify(sdnotify.Reloading); err != nil {
+			log.WithError(err).Error("cannot notify service manager")
+		}
+	}
+	r.apply(ctx, log, p)
+	r.confMtx.Lock()
+	r.conf = newConf
+	r.confMtx.Unlock()
+	if sdnotify.Enabled() {
+		if _, err := sdnotify.Notify(sdnotify.Ready + "\n" + sdnotify.Status(r.jobs.readinessText())); err != nil {
+			log.WithError(err).Error("cannot notify service manager")
+		}
+	}
+	log.Info("configuration reloaded")
+	return nil
+}
+
+// config returns the running config.
+func (r *reloader) config() *config.Config {
+	r.confMtx.Lock()
+	defer r.confMtx.Unlock()
+	return r.conf
+}
+
+func (r *reloader) plan(newConf *config.Config) (*reloadPlan, error) {
+	p := &reloadPlan{conf: newConf}
+
+	switch newConf.Global.Reload.StopPolicy {
+	case reloadStopPolicyWait:
+		p.graceful = true
+	case reloadStopPolicyAbort:
+	default:
+		return nil, errors.Errorf("`global.reload.stop_policy` must be %q or %q, got %q",
+			reloadStopPolicyWait, reloadStopPolicyAbort, newConf.Global.Reload.StopPolicy)
+	}
+	p.timeout = newConf.Global.Reload.StopTimeout
+
+	if err := checkConcurrencyLimits(newConf); err != nil {
+		return nil, err
+	}
+
+	if !reflect.DeepEqual(r.conf.Global.Logging, newConf.Global.Logging) {
+		outlets, err := outletsFromConfig(newConf)
+		if err != nil {
+			return nil, err
+		}
+		p.outlets = outlets
+	}
+
+	if !reflect.DeepEqual(r.conf.Global.Monitoring, newConf.Global.Monitoring) {
+		monitoringJobs, err := monitoringJobsFromConfig(newConf, r.jobs)
+		if err != nil {
+			return nil, err
+		}
+		p.restartMonitoring = true
+		p.monitoringJobs = monitoringJobs
+	}
+
+	newJobs, err := jobsFromConfig(newConf)
+	if err != nil {
+		return nil, err
+	}
+	var start map[string]bool
+	p.stop, start = diffJobs(r.conf, newConf)
+	for _, j := range newJobs {
+		if start[j.Name()] {
+			p.start = append(p.start, j)
+		}
+	}
+	return p, nil
+}
+
+func (r *reloader) apply(ctx context.Context, log logger.Logger, p *reloadPlan) {
+	if p.outlets != nil {
+		r.outlets.Replace(p.outlets)
+		log.Info("applied new logging configuration")
+	}
+
+	if !reflect.DeepEqual(r.conf.Global.Control, p.conf.Global.Control) {
+		log.Warn("changes to `global.control` require a daemon restart")
+	}
+	if !reflect.DeepEqual(r.conf.Global.ZFSHelper, p.conf.Global.ZFSHelper) {
+		log.Warn("changes to `global.zfs_helper` require a daemon restart")
+	}
+
+	endpoint.SetSendRecvConcurrencyLimits(p.conf.Global.Concurrency.ZFSSend, p.conf.Global.Concurrency.ZFSRecv)
+	versionhandshake.SetMaxMinorDifference(p.conf.Global.VersionSkew.MaxMinorDifference)
+
+	if p.restartMonitoring {
+		r.jobs.stopJob(log, jobNamePrometheus, false, 0)
+		r.jobs.stopJob(log, jobNameWebUI, false, 0)
+		r.jobs.stopJob(log, jobNameStatsd, false, 0)
+		r.jobs.stopJob(log, jobNamePushgateway, false, 0)
+		r.jobs.stopJob(log, jobNameRemoteWrite, false, 0)
+		r.jobs.stopJob(log, jobNameEmailDigest, false, 0)
+		for _, j := range p.monitoringJobs {
+			r.jobs.start(ctx, j, true)
+		}
+	}
+
+	done := make(chan struct{}, len(p.stop))
+	for _, name := range p.stop {
+		go func(name string) {
+			r.jobs.stopJob(log, name, p.graceful, p.timeout)
+			done <- struct{}{}
+		}(name)
+	}
+	for range p.stop {
+		<-done
+	}
+
+	for _, j := range p.start {
+		r.jobs.start(ctx, j, false)
+	}
+}
+
+// diffJobs compares the jobs of cur and next by name and returns the jobs of cur that must be stopped
+// and the jobs of next that must be started: all removed, added and changed jobs.
+// Jobs that are connected through `after` dependencies are restarted together
+// because the dependency links are established when the jobs are built.
+// If `global.serve` changed, all jobs are restarted.
+func diffJobs(cur, next *config.Config) (stop []string, start map[string]bool) {
+	curJobs, nextJobs := jobsByName(cur), jobsByName(next)
+
+	changed := make(map[string]bool)
+	serveChanged := !reflect.DeepEqual(cur.Global.Serve, next.Global.Serve)
+	for name, c := range curJobs {
+		n, ok := nextJobs[name]
+		if serveChanged || !ok || !reflect.DeepEqual(c.Ret, n.Ret) {
+			changed[name] = true
+		}
+	}
+	for name := range nextJobs {
+		if _, ok := curJobs[name]; !ok {
+			changed[name] = true
+		}
+	}
+
+	// undirected edges between dependent jobs of either config
+	edges := make(map[string][]string)
+	for _, js := range []map[string]config.JobEnum{curJobs, nextJobs} {
+		for name, j := range js {
+			for _, dep := range jobAfter(j) {
+				edges[name] = append(edges[name], dep)
+				edges[dep] = append(edges[dep], name)
+			}
+		}
+	}
+	queue := make([]string, 0, len(changed))
+	for name := range changed {
+		queue = append(queue, name)
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, dep := range edges[name] {
+			if !changed[dep] {
+				changed[dep] = true
+				queue = append(queue, dep)
+			}
+		}
+	}
+
+	start = make(map[string]bool)
+	for name := range changed {
+		if _, ok := curJobs[name]; ok {
+			stop = append(stop, name)
+		}
+		if _, ok := nextJobs[name]; ok {
+			start[name] = true
+		}
+	}
+	sort.Strings(stop)
+	return stop, start
+}
+
+func jobsByName(c *config.Config) map[string]config.JobEnum {
+	m := make(map[string]config.JobEnum, len(c.Jobs))
+	for _, j := range c.Jobs {
+		m[j.Name()] = j
+	}
+	return m
+}
+
+func jobAfter(j config.JobEnum) []string {
+	switch v := j.Ret.(type) {
+	case *config.PushJob:
+		return v.After
+	case *config.PullJob:
+		return v.After
+	default:
+		return nil
+	}
+}
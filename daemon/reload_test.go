@@ -0,0 +1,68 @@
+package daemon
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+)
+
+func TestDiffJobs(t *testing.T) {
+	job := func(name string, count int, after ...string) string {
+		afterStr := ""
+		if len(after) > 0 {
+			afterStr = fmt.Sprintf("  after: [%s]\n", strings.Join(after, ", "))
+		}
+		return fmt.Sprintf(`
+- name: %s
+  type: push
+  connect:
+    type: local
+    listener_name: %s
+    client_identity: bar
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep_sender:
+    - type: not_replicated
+    keep_receiver:
+    - type: last_n
+      count: %d
+%s`, name, name, count, afterStr)
+	}
+	conf := func(jobs ...string) *config.Config {
+		c, err := config.ParseConfigBytes([]byte("jobs:" + strings.Join(jobs, "")))
+		require.NoError(t, err)
+		return c
+	}
+	names := func(m map[string]bool) (ns []string) {
+		for n := range m {
+			ns = append(ns, n)
+		}
+		return ns
+	}
+
+	cur := conf(job("a", 10), job("b", 10), job("c", 10, "b"), job("d", 10))
+
+	stop, start := diffJobs(cur, conf(job("a", 10), job("b", 10), job("c", 10, "b"), job("d", 10)))
+	assert.Empty(t, stop)
+	assert.Empty(t, start)
+
+	// changed, removed and added jobs
+	stop, start = diffJobs(cur, conf(job("a", 20), job("b", 10), job("c", 10, "b"), job("e", 10)))
+	assert.Equal(t, []string{"a", "d"}, stop)
+	assert.ElementsMatch(t, []string{"a", "e"}, names(start))
+
+	// jobs connected through dependencies are restarted together
+	stop, start = diffJobs(cur, conf(job("a", 10), job("b", 20), job("c", 10, "b"), job("d", 10)))
+	assert.Equal(t, []string{"b", "c"}, stop)
+	assert.ElementsMatch(t, []string{"b", "c"}, names(start))
+	stop, start = diffJobs(cur, conf(job("a", 10), job("b", 10), job("c", 10, "b"), job("d", 10, "a")))
+	assert.Equal(t, []string{"a", "d"}, stop)
+	assert.ElementsMatch(t, []string{"a", "d"}, names(start))
+}
@@ -0,0 +1,164 @@
+package daemon
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/job/finished"
+	"github.com/zrepl/zrepl/daemon/job/ready"
+	"github.com/zrepl/zrepl/daemon/logging"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/rpc/versionhandshake"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+// exit codes of `zrepl run`
+const (
+	RunJobExitFailed      = 1 // the invocation had errors
+	RunJobExitCannotRun   = 2 // the job does not exist or cannot be run
+	RunJobExitInterrupted = 3 // SIGINT or SIGTERM
+)
+
+// RunJob runs job jobName of conf in the foreground, without control socket and monitoring endpoints,
+// and logs to stdout instead of the configured outlets.
+// The metrics are pushed at the end of every invocation if a pushgateway or remote_write monitoring job is configured.
+// If once is true, it performs a single invocation of the job (see job.OneShot) and returns,
+// otherwise, it runs the job's schedule until it receives SIGINT or SIGTERM.
+// Passive jobs that serve the local transport are run in the background
+// so that active jobs can connect to them.
+// The returned error is a *cli.ExitCodeError.
+func RunJob(ctx context.Context, conf *config.Config, jobName string, once, verbose bool) error {
+	cannotRun := func(err error) error { return &cli.ExitCodeError{Code: RunJobExitCannotRun, Err: err} }
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	go func() {
+		select {
+		case <-sigChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if _, err := conf.Job(jobName); err != nil {
+		return cannotRun(err)
+	}
+	confJobs, err := jobsFromConfig(conf)
+	if err != nil {
+		return cannotRun(err)
+	}
+	if err := checkConcurrencyLimits(conf); err != nil {
+		return cannotRun(err)
+	}
+	endpoint.SetSendRecvConcurrencyLimits(conf.Global.Concurrency.ZFSSend, conf.Global.Concurrency.ZFSRecv)
+	versionhandshake.SetMaxMinorDifference(conf.Global.VersionSkew.MaxMinorDifference)
+	if err := setZFSHelper(conf); err != nil {
+		return cannotRun(err)
+	}
+
+	level := "info"
+	if verbose {
+		level = "debug"
+	}
+	outlets, err := logging.OutletsFromConfig(config.LoggingOutletEnumList{{Ret: &config.StdoutLoggingOutlet{
+		LoggingOutletCommon: config.LoggingOutletCommon{Type: "stdout", Level: level, Format: "human"},
+		Time:                true,
+	}}})
+	if err != nil {
+		panic(err) // the config above is valid
+	}
+	log := logger.NewLogger(outlets, 1*time.Second)
+	ctx = logging.WithLoggers(ctx, logging.SubsystemLoggersWithUniversalLogger(log))
+
+	var j job.Job
+	for _, cj := range confJobs {
+		if cj.Name() == jobName {
+			j = cj
+		} else if servesLocally(conf, cj.Name()) {
+			peerCtx, readyChan := ready.Context(jobContext(ctx, cj.Name()))
+			go cj.Run(peerCtx)
+			select {
+			case <-readyChan:
+			case <-ctx.Done():
+			}
+		}
+	}
+	ctx = jobContext(ctx, jobName)
+
+	pushers, err := metricsPushersFromConfig(conf)
+	if err != nil {
+		return cannotRun(err)
+	}
+	if len(pushers) > 0 {
+		registerGlobalMetrics()
+		j.RegisterMetrics(prometheus.DefaultRegisterer)
+		hooks.RegisterMetrics(prometheus.DefaultRegisterer)
+		versionhandshake.RegisterMetrics(prometheus.DefaultRegisterer)
+	}
+	pushMetrics := func() {
+		for _, p := range pushers {
+			// not ctx, which is cancelled if the invocation was interrupted
+			if err := p.push(context.Background()); err != nil {
+				job.GetLogger(ctx).WithError(err).Error("cannot push metrics")
+			}
+		}
+	}
+
+	if !once {
+		j.Run(finished.Context(ctx, pushMetrics))
+		return nil
+	}
+	oneShot, ok := j.(job.OneShot)
+	if !ok {
+		return cannotRun(errors.Errorf("job %q cannot be run once, only snap, push and pull jobs can", jobName))
+	}
+	err = oneShot.RunOnce(ctx)
+	pushMetrics()
+	switch {
+	case ctx.Err() != nil:
+		return &cli.ExitCodeError{Code: RunJobExitInterrupted, Err: errors.New("interrupted")}
+	case err != nil:
+		job.GetLogger(ctx).WithError(err).Error("invocation failed")
+		return &cli.ExitCodeError{Code: RunJobExitFailed, Err: errors.Wrap(err, "invocation failed")}
+	}
+	job.GetLogger(ctx).Info("invocation succeeded")
+	return nil
+}
+
+func jobContext(ctx context.Context, jobName string) context.Context {
+	ctx = logging.WithInjectedField(ctx, logging.JobField, jobName)
+	return zfscmd.WithJobID(ctx, jobName)
+}
+
+// servesLocally returns true if jobName is a passive job that serves the local transport.
+func servesLocally(conf *config.Config, jobName string) bool {
+	confJob, err := conf.Job(jobName)
+	if err != nil {
+		return false
+	}
+	var serve config.ServeEnum
+	switch v := confJob.Ret.(type) {
+	case *config.SinkJob:
+		serve = v.Serve
+	case *config.SourceJob:
+		serve = v.Serve
+	default:
+		return false
+	}
+	_, ok := serve.Ret.(*config.LocalServe)
+	return ok
+}
@@ -0,0 +1,44 @@
+package daemon
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/config"
+)
+
+func TestRunJobCannotRun(t *testing.T) {
+	conf, err := config.ParseConfigBytes([]byte(`
+jobs:
+- name: sink
+  type: sink
+  root_fs: "pool/sink"
+  serve:
+    type: local
+    listener_name: sink
+- name: source
+  type: source
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  serve:
+    type: tcp
+    listen: ":8888"
+    clients:
+      "10.0.0.1": "client"
+`))
+	require.NoError(t, err)
+	assert.True(t, servesLocally(conf, "sink"))
+	assert.False(t, servesLocally(conf, "source"))
+	assert.False(t, servesLocally(conf, "nonexistent"))
+
+	for _, name := range []string{"nonexistent", "sink"} {
+		err := RunJob(context.Background(), conf, name, true, false)
+		require.IsType(t, &cli.ExitCodeError{}, err, name)
+		assert.Equal(t, RunJobExitCannotRun, err.(*cli.ExitCodeError).Code, name)
+	}
+}
@@ -0,0 +1,122 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/util/envconst"
+	"github.com/zrepl/zrepl/util/sdnotify"
+)
+
+// notifyServiceManager implements systemd's Type=notify and WatchdogSec= protocols:
+// it signals readiness once all jobs have signalled readiness (or exited),
+// and then periodically sends the status text and, if enabled, watchdog keepalives.
+// Keepalives are only sent while the jobs' status can be collected,
+// so that the service manager restarts a daemon that hangs.
+func (s *jobs) notifyServiceManager(ctx context.Context, log logger.Logger) {
+	if !sdnotify.Enabled() {
+		return
+	}
+	log = log.WithField("subsystem", "sdnotify")
+	notify := func(state ...string) {
+		if _, err := sdnotify.Notify(strings.Join(state, "\n")); err != nil {
+			log.WithError(err).Error("cannot notify service manager")
+		}
+	}
+
+	s.m.RLock()
+	readies, exited := make(map[string]<-chan struct{}, len(s.ready)), make(map[string]chan struct{}, len(s.exited))
+	for name := range s.ready {
+		readies[name], exited[name] = s.ready[name], s.exited[name]
+	}
+	s.m.RUnlock()
+	for name, r := range readies {
+		select {
+		case <-r:
+		case <-exited[name]:
+			log.WithField("job", name).Error("job exited before it was ready")
+		case <-ctx.Done():
+			return
+		}
+	}
+	notify(sdnotify.Ready, sdnotify.Status(s.readinessText()))
+	log.Info("notified service manager about readiness")
+
+	watchdogInterval, watchdog, err := sdnotify.WatchdogInterval()
+	if err != nil {
+		log.WithError(err).Error("cannot determine watchdog interval, watchdog disabled")
+	}
+	interval := envconst.Duration("ZREPL_SDNOTIFY_STATUS_INTERVAL", 30*time.Second)
+	if watchdog {
+		// as recommended by sd_watchdog_enabled(3)
+		interval = watchdogInterval / 2
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	var check chan struct{} // nil if no status collection is in flight
+	for {
+		select {
+		case <-ctx.Done():
+			notify(sdnotify.Stopping)
+			return
+		case <-t.C:
+		}
+		if check == nil {
+			check = make(chan struct{})
+			go func(done chan struct{}) {
+				s.status()
+				close(done)
+			}(check)
+		}
+		select {
+		case <-check:
+			check = nil
+		case <-time.After(interval / 2):
+			log.WithField("timeout", interval/2).Error("collecting job status timed out, daemon might be hung")
+			notify(sdnotify.Status("collecting job status timed out, daemon might be hung"))
+			continue
+		case <-ctx.Done():
+			continue
+		}
+		state := []string{sdnotify.Status(s.readinessText())}
+		if watchdog {
+			state = append(state, sdnotify.Watchdog)
+		}
+		notify(state...)
+	}
+}
+
+// readinessText describes how many of the configured (non-internal) jobs are ready and running.
+func (s *jobs) readinessText() string {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	var total, running int
+	var exited []string
+	for name := range s.jobs {
+		if IsInternalJobName(name) {
+			continue
+		}
+		total++
+		select {
+		case <-s.exited[name]:
+			exited = append(exited, name)
+			continue
+		default:
+		}
+		select {
+		case <-s.ready[name]:
+			running++
+		default:
+		}
+	}
+	text := fmt.Sprintf("%d of %d jobs running", running, total)
+	if len(exited) > 0 {
+		sort.Strings(exited)
+		text += fmt.Sprintf(", exited: %s", strings.Join(exited, ", "))
+	}
+	return text
+}
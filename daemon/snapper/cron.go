@@ -10,22 +10,35 @@ import (
 
 	"github.com/zrepl/zrepl/config"
 	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/job/disable"
+	"github.com/zrepl/zrepl/daemon/job/maintenance"
 	"github.com/zrepl/zrepl/util/suspendresumesafetimer"
 	"github.com/zrepl/zrepl/zfs"
 )
 
-func cronFromConfig(fsf zfs.DatasetFilter, in config.SnapshottingCron) (*Cron, error) {
+func cronFromConfig(jobName string, fsf zfs.DatasetFilter, in config.SnapshottingCron) (*Cron, error) {
 
 	hooksList, err := hooks.ListFromConfig(&in.Hooks)
 	if err != nil {
 		return nil, errors.Wrap(err, "hook config error")
 	}
+	if in.HooksConcurrency < 1 {
+		return nil, errors.New("field `hooks_concurrency` must be positive")
+	}
+	nameTemplate, err := nameTemplateFromConfig(in.NameTemplate, in.NameTimezone, in.Prefix, jobName)
+	if err != nil {
+		return nil, errors.Wrap(err, "field `name_template`")
+	}
 	planArgs := planArgs{
-		prefix:          in.Prefix,
-		timestampFormat: in.TimestampFormat,
-		hooks:           hooksList,
+		jobName:          jobName,
+		prefix:           in.Prefix,
+		timestampFormat:  in.TimestampFormat,
+		nameTemplate:     nameTemplate,
+		skipUnchanged:    in.SkipUnchanged,
+		hooks:            hooksList,
+		hooksConcurrency: in.HooksConcurrency,
 	}
-	return &Cron{config: in, fsf: fsf, planArgs: planArgs}, nil
+	return &Cron{config: in, fsf: fsf, planArgs: planArgs, jitterOffset: jitterOffset(jobName, in.Prefix, in.Jitter)}, nil
 }
 
 type Cron struct {
@@ -33,6 +46,9 @@ type Cron struct {
 	fsf      zfs.DatasetFilter
 	planArgs planArgs
 
+	// the schedule is shifted by jitterOffset
+	jitterOffset time.Duration
+
 	mtx sync.RWMutex
 
 	running                 bool
@@ -47,7 +63,11 @@ func (s *Cron) Run(ctx context.Context, snapshotsTaken chan<- struct{}) {
 	for {
 		now := time.Now()
 		s.mtx.Lock()
-		s.wakeupTime = s.config.Cron.Schedule.Next(now)
+		next := s.config.Cron.Schedule.Next(now.Add(-s.jitterOffset))
+		if !next.IsZero() {
+			next = next.Add(s.jitterOffset)
+		}
+		s.wakeupTime = next
 		s.mtx.Unlock()
 
 		ctxDone := suspendresumesafetimer.SleepUntil(ctx, s.wakeupTime)
@@ -56,6 +76,10 @@ func (s *Cron) Run(ctx context.Context, snapshotsTaken chan<- struct{}) {
 		}
 
 		getLogger(ctx).Debug("cron timer fired")
+		if disable.Disabled(ctx) || maintenance.Get(ctx).Active {
+			getLogger(ctx).Info("job is disabled or in maintenance, skipping snapshots")
+			continue
+		}
 		s.mtx.Lock()
 		if s.running {
 			getLogger(ctx).Warn("snapshotting triggered according to cron rules but previous snapshotting is not done; not taking a snapshot this time")
@@ -48,6 +48,14 @@ func TestCronLibraryWorks(t *testing.T) {
 		{"53 17,18,19 * * *", dhm(23, 17, 53), dhm(23, 18, 53)},
 		{"53 17,18,19 * * *", dhm(23, 18, 53), dhm(23, 19, 53)},
 		{"53 17,18,19 * * *", dhm(23, 19, 53), dhm(24 /* ! */, 17, 53)},
+
+		// optional seconds field
+		{"30 0 12 * * *", hm(11, 0), time.Date(2022, 7, 23, 12, 0, 30, 0, time.UTC)},
+
+		// descriptors
+		{"@daily", hm(11, 0), dhm(24, 0, 0)},
+		{"@hourly", hm(11, 5), hm(12, 0)},
+		{"@every 90m", hm(11, 0), hm(12, 30)},
 	}
 
 	for i, tc := range tcs {
@@ -66,3 +74,23 @@ func TestCronLibraryWorks(t *testing.T) {
 	}
 
 }
+
+func TestCronSpecList(t *testing.T) {
+	var s struct {
+		Cron config.CronSpec `yaml:"cron"`
+	}
+	// snapshots at 00:00 and 12:30, which a single cron spec cannot express
+	err := yaml.UnmarshalStrict([]byte(`cron: ["0 0 * * *", "30 12 * * *"]`), &s)
+	require.NoError(t, err)
+
+	at := func(day, hour, minutes int) time.Time {
+		return time.Date(2022, 7, day, hour, minutes, 0, 0, time.UTC)
+	}
+	assert.Equal(t, at(23, 12, 30), s.Cron.Schedule.Next(at(23, 1, 0)))
+	assert.Equal(t, at(24, 0, 0), s.Cron.Schedule.Next(at(23, 12, 30)))
+
+	err = yaml.UnmarshalStrict([]byte(`cron: ["0 0 * * *", "not a cron spec"]`), &s)
+	assert.Error(t, err)
+	err = yaml.UnmarshalStrict([]byte(`cron: []`), &s)
+	assert.Error(t, err)
+}
@@ -6,18 +6,29 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/pkg/errors"
+
 	"github.com/zrepl/zrepl/daemon/hooks"
 	"github.com/zrepl/zrepl/daemon/logging"
+	"github.com/zrepl/zrepl/daemon/logging/trace"
 	"github.com/zrepl/zrepl/util/chainlock"
+	"github.com/zrepl/zrepl/util/semaphore"
 	"github.com/zrepl/zrepl/zfs"
 )
 
 type planArgs struct {
+	jobName         string
 	prefix          string
 	timestampFormat string
+	nameTemplate    *nameTemplate // nil unless configured, takes precedence over prefix and timestampFormat
+	skipUnchanged   bool
+	fixedSuffix     string // if not empty, used instead of timestampFormat, for on-demand snapshots
 	hooks           *hooks.List
+	// maximum number of filesystems whose hooks and snapshot run in parallel, 0 means 1
+	hooksConcurrency int
 }
 
 type plan struct {
@@ -42,6 +53,8 @@ const (
 	SnapStarted
 	SnapDone
 	SnapError
+	SnapSkipped
+	SnapAborted // a hook with on_error: abort_job failed for another filesystem
 )
 
 // All fields protected by Snapper.mtx
@@ -53,7 +66,7 @@ type snapProgress struct {
 	startAt  time.Time
 	hookPlan *hooks.Plan
 
-	// SnapDone
+	// SnapDone, SnapSkipped
 	doneAt time.Time
 
 	// SnapErr TODO disambiguate state
@@ -75,91 +88,153 @@ func (plan *plan) formatNow(format string) string {
 	return now.Format(format)
 }
 
+// Returns the name of the snapshots of the plan.
+// It is rendered once per plan so that all filesystems get the same name,
+// uniqueSnapshotName only disambiguates it per filesystem.
+func (plan *plan) snapshotName() string {
+	if plan.args.nameTemplate != nil {
+		return plan.args.nameTemplate.expand(time.Now())
+	}
+	suffix := plan.args.fixedSuffix
+	if suffix == "" {
+		suffix = plan.formatNow(plan.args.timestampFormat)
+	}
+	return fmt.Sprintf("%s%s", plan.args.prefix, suffix)
+}
+
+// The written@ property is evaluated against the latest snapshot with our prefix
+// instead of the latest snapshot of any name. Otherwise, e.g. in a multi snapshotter,
+// a snapshot by another schedule would make us skip ours.
+func filesystemUnchangedSinceLatestSnapshot(ctx context.Context, fs *zfs.DatasetPath, prefix string) (bool, error) {
+	fsvs, err := zfs.ZFSListFilesystemVersions(ctx, fs, zfs.ListFilesystemVersionsOptions{
+		Types:           zfs.Snapshots,
+		ShortnamePrefix: prefix,
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "list filesystem versions")
+	}
+	if len(fsvs) == 0 {
+		return false, nil
+	}
+	latest := fsvs[0]
+	for _, v := range fsvs[1:] {
+		if v.CreateTXG > latest.CreateTXG {
+			latest = v
+		}
+	}
+	prop := "written@" + latest.Name
+	props, err := zfs.ZFSGet(ctx, fs, []string{prop})
+	if err != nil {
+		return false, errors.Wrapf(err, "get %q", prop)
+	}
+	written, err := strconv.ParseUint(props.Get(prop), 10, 64)
+	if err != nil {
+		return false, errors.Wrapf(err, "parse %q", prop)
+	}
+	return written == 0, nil
+}
+
+// Returns empty strings for properties that are not available,
+// e.g. the mountpoint of a volume or the encryption if the ZFS version does not support encryption.
+func hookEnvFilesystemProperties(ctx context.Context, fs *zfs.DatasetPath) (mountpoint, encryption string, err error) {
+	props := []string{"mountpoint"}
+	encSupported, err := zfs.EncryptionCLISupported(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	if encSupported {
+		props = append(props, "encryption")
+	}
+	res, err := zfs.ZFSGet(ctx, fs, props)
+	if err != nil {
+		return "", "", err
+	}
+	mountpoint = res.Get("mountpoint")
+	if mountpoint == "-" {
+		mountpoint = ""
+	}
+	if encSupported {
+		encryption = res.Get("encryption")
+	}
+	return mountpoint, encryption, nil
+}
+
 func (plan *plan) execute(ctx context.Context, dryRun bool) (ok bool) {
+	if !dryRun {
+		defer observeDuration(ctx, time.Now())
+	}
 
 	hookMatchCount := make(map[hooks.Hook]int, len(*plan.args.hooks))
 	for _, h := range *plan.args.hooks {
 		hookMatchCount[h] = 0
 	}
 
+	batch := make([]string, 0, len(plan.snaps))
+	for fs := range plan.snaps {
+		batch = append(batch, fs.ToString())
+	}
+	sort.Strings(batch)
+	batchFilesystems := strings.Join(batch, "\n")
+	snapname := plan.snapshotName()
+
+	concurrency := plan.args.hooksConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := semaphore.New(int64(concurrency))
+
+	var mtx sync.Mutex // protects the variables below and hookMatchCount
 	anyFsHadErr := false
+	jobAborted := false
+	var wg sync.WaitGroup
 	// TODO channel programs -> allow a little jitter?
 	for fs, progress := range plan.snaps {
-		suffix := plan.formatNow(plan.args.timestampFormat)
-		snapname := fmt.Sprintf("%s%s", plan.args.prefix, suffix)
-
-		ctx := logging.WithInjectedField(ctx, "fs", fs.ToString())
-		ctx = logging.WithInjectedField(ctx, "snap", snapname)
-
-		hookEnvExtra := hooks.Env{
-			hooks.EnvFS:       fs.ToString(),
-			hooks.EnvSnapshot: snapname,
+		guard, err := sem.Acquire(ctx)
+		if err != nil {
+			getLogger(ctx).WithError(err).WithField("fs", fs.ToString()).Error("cannot snapshot filesystem")
+			mtx.Lock()
+			anyFsHadErr = true
+			mtx.Unlock()
+			plan.mtx.HoldWhile(func() {
+				progress.doneAt = time.Now()
+				progress.state = SnapError
+			})
+			continue
 		}
-
-		jobCallback := hooks.NewCallbackHookForFilesystem("snapshot", fs, func(ctx context.Context) (err error) {
-			l := getLogger(ctx)
-			l.Debug("create snapshot")
-			err = zfs.ZFSSnapshot(ctx, fs, snapname, false) // TODO propagate context to ZFSSnapshot
-			if err != nil {
-				l.WithError(err).Error("cannot create snapshot")
-			}
-			return
-		})
-
-		fsHadErr := false
-		var hookPlanReport hooks.PlanReport
-		var hookPlan *hooks.Plan
-		{
-			filteredHooks, err := plan.args.hooks.CopyFilteredForFilesystem(fs)
-			if err != nil {
-				getLogger(ctx).WithError(err).Error("unexpected filter error")
-				fsHadErr = true
-				goto updateFSState
-			}
-			// account for running hooks
-			for _, h := range filteredHooks {
-				hookMatchCount[h] = hookMatchCount[h] + 1
-			}
-
-			var planErr error
-			hookPlan, planErr = hooks.NewPlan(&filteredHooks, hooks.PhaseSnapshot, jobCallback, hookEnvExtra)
-			if planErr != nil {
-				fsHadErr = true
-				getLogger(ctx).WithError(planErr).Error("cannot create job hook plan")
-				goto updateFSState
-			}
+		mtx.Lock()
+		aborted := jobAborted
+		mtx.Unlock()
+		if aborted {
+			guard.Release()
+			mtx.Lock()
+			anyFsHadErr = true
+			mtx.Unlock()
+			plan.mtx.HoldWhile(func() {
+				progress.doneAt = time.Now()
+				progress.state = SnapAborted
+			})
+			continue
 		}
-
-		plan.mtx.HoldWhile(func() {
-			progress.name = snapname
-			progress.startAt = time.Now()
-			progress.hookPlan = hookPlan
-			progress.state = SnapStarted
-		})
-
-		{
-			getLogger(ctx).WithField("report", hookPlan.Report().String()).Debug("begin run job plan")
-			hookPlan.Run(ctx, dryRun)
-			hookPlanReport = hookPlan.Report()
-			fsHadErr = hookPlanReport.HadError() // not just fatal errors
-			if fsHadErr {
-				getLogger(ctx).WithField("report", hookPlanReport.String()).Error("end run job plan with error")
-			} else {
-				getLogger(ctx).WithField("report", hookPlanReport.String()).Info("end run job plan successful")
-			}
-		}
-
-	updateFSState:
-		anyFsHadErr = anyFsHadErr || fsHadErr
-		plan.mtx.HoldWhile(func() {
-			progress.doneAt = time.Now()
-			progress.state = SnapDone
-			if fsHadErr {
-				progress.state = SnapError
-			}
-			progress.runResults = hookPlanReport
-		})
+		wg.Add(1)
+		go func(fs *zfs.DatasetPath, progress *snapProgress) {
+			defer wg.Done()
+			defer guard.Release()
+			ctx, endTask := trace.WithTaskAndSpan(ctx, "snapshot-fs", fs.ToString())
+			defer endTask()
+			fsHadErr, abortJob := plan.snapshotFilesystem(ctx, fs, snapname, progress, dryRun, batchFilesystems, func(matched hooks.List) {
+				mtx.Lock()
+				defer mtx.Unlock()
+				for _, h := range matched {
+					hookMatchCount[h] = hookMatchCount[h] + 1
+				}
+			})
+			mtx.Lock()
+			defer mtx.Unlock()
+			anyFsHadErr = anyFsHadErr || fsHadErr
+			jobAborted = jobAborted || abortJob
+		}(fs, progress)
 	}
+	wg.Wait()
 
 	for h, mc := range hookMatchCount {
 		if mc == 0 {
@@ -177,6 +252,128 @@ func (plan *plan) execute(ctx context.Context, dryRun bool) (ok bool) {
 	return !anyFsHadErr
 }
 
+// snapshotFilesystem runs the hooks and takes the snapshot of fs named snapname.
+// It may run concurrently for different filesystems of the plan, see planArgs.hooksConcurrency.
+// accountHooks is called with the hooks that match fs.
+func (plan *plan) snapshotFilesystem(ctx context.Context, fs *zfs.DatasetPath, snapname string, progress *snapProgress, dryRun bool, batchFilesystems string, accountHooks func(hooks.List)) (fsHadErr, abortJob bool) {
+	if plan.args.skipUnchanged {
+		ctx := logging.WithInjectedField(ctx, "fs", fs.ToString())
+		unchanged, err := filesystemUnchangedSinceLatestSnapshot(ctx, fs, plan.args.prefix)
+		if err != nil {
+			getLogger(ctx).WithError(err).Warn("cannot determine whether filesystem changed, creating snapshot anyway")
+		} else if unchanged {
+			getLogger(ctx).Debug("skip snapshot, filesystem unchanged since latest snapshot")
+			plan.mtx.HoldWhile(func() {
+				progress.doneAt = time.Now()
+				progress.state = SnapSkipped
+			})
+			return false, false
+		}
+	}
+
+	ctx = logging.WithInjectedField(ctx, "fs", fs.ToString())
+
+	if plan.args.nameTemplate != nil {
+		uniqueName, err := uniqueSnapshotName(ctx, fs, snapname)
+		if err != nil {
+			getLogger(ctx).WithError(err).WithField("snap", snapname).Error("cannot determine snapshot name")
+			plan.mtx.HoldWhile(func() {
+				progress.name = snapname
+				progress.doneAt = time.Now()
+				progress.state = SnapError
+			})
+			return true, false
+		}
+		snapname = uniqueName
+	}
+	ctx = logging.WithInjectedField(ctx, "snap", snapname)
+
+	hookEnvExtra := hooks.Env{
+		hooks.EnvFS:          fs.ToString(),
+		hooks.EnvSnapshot:    snapname,
+		hooks.EnvJob:         plan.args.jobName,
+		hooks.EnvFilesystems: batchFilesystems,
+	}
+	if len(*plan.args.hooks) > 0 {
+		mountpoint, encryption, err := hookEnvFilesystemProperties(ctx, fs)
+		if err != nil {
+			getLogger(ctx).WithError(err).Warn("cannot get filesystem properties for hook environment")
+		}
+		hookEnvExtra[hooks.EnvMountpoint] = mountpoint
+		hookEnvExtra[hooks.EnvEncryption] = encryption
+	}
+
+	jobCallback := hooks.NewCallbackHookForFilesystem("snapshot", fs, func(ctx context.Context) (err error) {
+		l := getLogger(ctx)
+		l.Debug("create snapshot")
+		err = zfs.ZFSSnapshotWithProperties(ctx, fs, snapname, false, hooks.AnnotationsFromContext(ctx))
+		if err != nil {
+			l.WithError(err).Error("cannot create snapshot")
+		}
+		return
+	})
+
+	fsSkipped := false
+	var hookPlanReport hooks.PlanReport
+	var hookPlan *hooks.Plan
+	{
+		filteredHooks, err := plan.args.hooks.CopyFilteredForFilesystem(fs)
+		if err != nil {
+			getLogger(ctx).WithError(err).Error("unexpected filter error")
+			fsHadErr = true
+			goto updateFSState
+		}
+		// account for running hooks
+		accountHooks(filteredHooks)
+
+		var planErr error
+		hookPlan, planErr = hooks.NewPlan(&filteredHooks, hooks.PhaseSnapshot, jobCallback, hookEnvExtra)
+		if planErr != nil {
+			fsHadErr = true
+			getLogger(ctx).WithError(planErr).Error("cannot create job hook plan")
+			goto updateFSState
+		}
+	}
+
+	plan.mtx.HoldWhile(func() {
+		progress.name = snapname
+		progress.startAt = time.Now()
+		progress.hookPlan = hookPlan
+		progress.state = SnapStarted
+	})
+
+	{
+		getLogger(ctx).WithField("report", hookPlan.Report().String()).Debug("begin run job plan")
+		hookPlan.Run(ctx, dryRun)
+		hookPlanReport = hookPlan.Report()
+		fsHadErr = hookPlanReport.HadError() // not just fatal errors
+		fsSkipped = hookPlanReport.SkippedByHook()
+		if fsHadErr {
+			getLogger(ctx).WithField("report", hookPlanReport.String()).Error("end run job plan with error")
+		} else {
+			getLogger(ctx).WithField("report", hookPlanReport.String()).Info("end run job plan successful")
+		}
+	}
+
+updateFSState:
+	if hookPlanReport.AbortJob() {
+		getLogger(ctx).Error("hook failed with on_error: abort_job, not snapshotting the remaining filesystems")
+		abortJob = true
+	}
+	plan.mtx.HoldWhile(func() {
+		progress.doneAt = time.Now()
+		progress.state = SnapDone
+		if fsSkipped {
+			progress.state = SnapSkipped
+		}
+		if fsHadErr {
+			progress.state = SnapError
+		}
+		progress.runResults = hookPlanReport
+	})
+	return fsHadErr, abortJob
+}
+
 type ReportFilesystem struct {
 	Path  string
 	State SnapState
@@ -0,0 +1,21 @@
+package snapper
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"time"
+)
+
+// jitterOffset returns a pseudo-random offset in [0, jitter).
+// It is derived from the host name, job name and prefix so that it is
+// stable across daemon restarts but differs between jobs and hosts.
+func jitterOffset(jobName, prefix string, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return 0
+	}
+	hostname, _ := os.Hostname()
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\x00%s\x00%s", hostname, jobName, prefix)
+	return time.Duration(h.Sum64() % uint64(jitter))
+}
@@ -0,0 +1,22 @@
+package snapper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJitterOffset(t *testing.T) {
+	assert.Equal(t, time.Duration(0), jitterOffset("job", "zrepl_", 0))
+
+	jitter := 10 * time.Minute
+	offsets := make(map[time.Duration]bool)
+	for _, job := range []string{"a", "b", "c", "d", "e"} {
+		o := jitterOffset(job, "zrepl_", jitter)
+		assert.True(t, o >= 0 && o < jitter, "offset %s out of range", o)
+		assert.Equal(t, o, jitterOffset(job, "zrepl_", jitter), "offset must be stable")
+		offsets[o] = true
+	}
+	assert.True(t, len(offsets) > 1, "offsets must differ between jobs")
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 )
 
-type manual struct{}
+type manual struct {
+	jobName string
+}
 
 func (s *manual) Run(ctx context.Context, wakeUpCommon chan<- struct{}) {
 	// nothing to do
@@ -0,0 +1,72 @@
+package snapper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// multi runs several periodic or cron snapshotters side by side,
+// e.g. `frequent_` snapshots every 15 minutes and `daily_` snapshots at midnight.
+type multi struct {
+	snappers []Snapper
+}
+
+func multiFromConfig(g *config.Global, jobName string, fsf zfs.DatasetFilter, in *config.SnapshottingMulti) (*multi, error) {
+	if len(in.Schedules) == 0 {
+		return nil, errors.New("`schedules` must not be empty")
+	}
+	m := &multi{}
+	var prefixes []string
+	for i, s := range in.Schedules {
+		var prefix string
+		switch v := s.Ret.(type) {
+		case *config.SnapshottingPeriodic:
+			prefix = v.Prefix
+		case *config.SnapshottingCron:
+			prefix = v.Prefix
+		default:
+			return nil, fmt.Errorf("schedule #%d: snapshotting type %T is not supported in a multi snapshotter, only periodic and cron", i, v)
+		}
+		// a periodic snapshotter determines its next snapshot from the most recent snapshot with its prefix
+		for _, p := range prefixes {
+			if strings.HasPrefix(p, prefix) || strings.HasPrefix(prefix, p) {
+				return nil, fmt.Errorf("schedule #%d: prefix %q overlaps with prefix %q of another schedule", i, prefix, p)
+			}
+		}
+		prefixes = append(prefixes, prefix)
+
+		snapper, err := FromConfig(g, jobName, fsf, s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "schedule #%d", i)
+		}
+		m.snappers = append(m.snappers, snapper)
+	}
+	return m, nil
+}
+
+func (m *multi) Run(ctx context.Context, snapshotsTaken chan<- struct{}) {
+	_, add, wait := trace.WithTaskGroup(ctx, "snapper-multi")
+	for _, s := range m.snappers {
+		s := s
+		add(func(ctx context.Context) {
+			s.Run(ctx, snapshotsTaken)
+		})
+	}
+	wait()
+}
+
+func (m *multi) Report() Report {
+	r := Report{Type: TypeMulti, Multi: make([]*Report, len(m.snappers))}
+	for i, s := range m.snappers {
+		sr := s.Report()
+		r.Multi[i] = &sr
+	}
+	return r
+}
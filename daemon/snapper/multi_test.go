@@ -0,0 +1,61 @@
+package snapper
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+)
+
+func TestMultiFromConfig(t *testing.T) {
+	tmpl := `
+jobs:
+- name: snapjob
+  type: snap
+  filesystems: {"tank<": true}
+  snapshotting:
+    type: multi
+    schedules:
+%s
+  pruning:
+    keep:
+    - type: last_n
+      count: 1
+`
+	tcs := []struct {
+		name        string
+		schedules   string
+		expectError bool
+	}{
+		{"periodic_and_cron", "    - {type: periodic, prefix: frequent_, interval: 15m}\n    - {type: cron, prefix: daily_, cron: '0 0 * * *'}", false},
+		{"overlapping_prefixes", "    - {type: periodic, prefix: zrepl_, interval: 15m}\n    - {type: cron, prefix: zrepl_daily_, cron: '0 0 * * *'}", true},
+		{"manual_not_allowed", "    - {type: manual}", true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := config.ParseConfigBytes([]byte(fmt.Sprintf(tmpl, tc.schedules)))
+			require.NoError(t, err)
+			snapJob := c.Jobs[0].Ret.(*config.SnapJob)
+			s, err := FromConfig(c.Global, snapJob.Name, nil, snapJob.Snapshotting)
+			if tc.expectError {
+				t.Logf("error: %s", err)
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			m := s.(*multi)
+			require.Len(t, m.snappers, 2)
+			assert.IsType(t, &Periodic{}, m.snappers[0])
+			assert.IsType(t, &Cron{}, m.snappers[1])
+
+			r := s.Report()
+			assert.Equal(t, TypeMulti, r.Type)
+			require.Len(t, r.Multi, 2)
+			assert.Equal(t, TypePeriodic, r.Multi[0].Type)
+			assert.Equal(t, TypeCron, r.Multi[1].Type)
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package snapper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// nameTemplate renders snapshot names from a template with strftime-style
+// placeholders (e.g. `%Y-%m-%d_%H:%M`) and the variables `%{job}` and `%{hostname}`,
+// which allows matching the naming conventions of other snapshot tools.
+type nameTemplate struct {
+	parts []func(t time.Time) string
+	loc   *time.Location
+}
+
+func strftimeLayout(layout string) func(t time.Time) string {
+	return func(t time.Time) string { return t.Format(layout) }
+}
+
+var strftimeConversions = map[byte]func(t time.Time) string{
+	'Y': strftimeLayout("2006"),
+	'y': strftimeLayout("06"),
+	'm': strftimeLayout("01"),
+	'd': strftimeLayout("02"),
+	'H': strftimeLayout("15"),
+	'I': strftimeLayout("03"),
+	'p': strftimeLayout("PM"),
+	'M': strftimeLayout("04"),
+	'S': strftimeLayout("05"),
+	'a': strftimeLayout("Mon"),
+	'A': strftimeLayout("Monday"),
+	'b': strftimeLayout("Jan"),
+	'B': strftimeLayout("January"),
+	'z': strftimeLayout("-0700"),
+	'Z': strftimeLayout("MST"),
+	'F': strftimeLayout("2006-01-02"),
+	'T': strftimeLayout("15:04:05"),
+	'j': func(t time.Time) string { return fmt.Sprintf("%03d", t.YearDay()) },
+	'u': func(t time.Time) string { return strconv.Itoa((int(t.Weekday())+6)%7 + 1) },
+	's': func(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) },
+}
+
+// Returns nil if tmpl is empty, i.e., the snapshot name is built from prefix and timestamp format.
+func nameTemplateFromConfig(tmpl, timezone, prefix, jobName string) (*nameTemplate, error) {
+	if tmpl == "" {
+		return nil, nil
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get hostname")
+	}
+	return parseNameTemplate(tmpl, timezone, prefix, jobName, hostname)
+}
+
+// The name rendered from tmpl must begin with prefix, so that snapshotters that
+// search for their most recent snapshot by prefix keep working.
+func parseNameTemplate(tmpl, timezone, prefix, jobName, hostname string) (*nameTemplate, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid time zone")
+	}
+	t := &nameTemplate{loc: loc}
+	literal := func(s string) func(time.Time) string {
+		return func(time.Time) string { return s }
+	}
+	hasTimePlaceholder := false
+	for i := 0; i < len(tmpl); i++ {
+		if tmpl[i] != '%' {
+			j := strings.IndexByte(tmpl[i:], '%')
+			if j == -1 {
+				j = len(tmpl) - i
+			}
+			t.parts = append(t.parts, literal(tmpl[i:i+j]))
+			i += j - 1
+			continue
+		}
+		if i+1 >= len(tmpl) {
+			return nil, fmt.Errorf("template must not end with a single '%%'")
+		}
+		i++
+		switch c := tmpl[i]; c {
+		case '%':
+			t.parts = append(t.parts, literal("%"))
+		case '{':
+			end := strings.IndexByte(tmpl[i:], '}')
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated variable at position %d", i-1)
+			}
+			switch v := tmpl[i+1 : i+end]; v {
+			case "job":
+				t.parts = append(t.parts, literal(jobName))
+			case "hostname":
+				t.parts = append(t.parts, literal(hostname))
+			default:
+				return nil, fmt.Errorf("unknown variable %q, must be one of `job` or `hostname`", v)
+			}
+			i += end
+		default:
+			conv, ok := strftimeConversions[c]
+			if !ok {
+				return nil, fmt.Errorf("unsupported conversion %q", "%"+string(c))
+			}
+			t.parts = append(t.parts, conv)
+			hasTimePlaceholder = true
+		}
+	}
+	if !hasTimePlaceholder {
+		return nil, fmt.Errorf("template must contain at least one time placeholder, otherwise all snapshots have the same name")
+	}
+
+	sample := t.expand(time.Now())
+	if !strings.HasPrefix(sample, prefix) {
+		return nil, fmt.Errorf("rendered name %q does not begin with prefix %q", sample, prefix)
+	}
+	if err := zfs.ComponentNamecheck(sample); err != nil {
+		return nil, errors.Wrapf(err, "rendered name %q is not a valid snapshot name", sample)
+	}
+	return t, nil
+}
+
+func (t *nameTemplate) expand(now time.Time) string {
+	now = now.In(t.loc)
+	var b strings.Builder
+	for _, p := range t.parts {
+		b.WriteString(p(now))
+	}
+	return b.String()
+}
+
+// Upper bound for the numeric suffix appended by uniqueSnapshotName.
+const nameTemplateMaxCollisions = 100
+
+// Templates with a coarse time resolution (e.g. minutes) can render the name of an existing
+// snapshot, for example if a snapshot is triggered manually right after a scheduled one.
+// uniqueSnapshotName appends `_1`, `_2`, ... to name until it does not exist on fs.
+func uniqueSnapshotName(ctx context.Context, fs *zfs.DatasetPath, name string) (string, error) {
+	candidate := name
+	for i := 1; ; i++ {
+		_, err := zfs.ZFSGetFilesystemVersion(ctx, fmt.Sprintf("%s@%s", fs.ToString(), candidate))
+		if _, ok := err.(*zfs.DatasetDoesNotExist); ok {
+			return candidate, nil
+		} else if err != nil {
+			return "", errors.Wrapf(err, "cannot check whether snapshot %q exists", candidate)
+		}
+		if i > nameTemplateMaxCollisions {
+			return "", fmt.Errorf("snapshot %q and %d suffixed variants already exist", name, nameTemplateMaxCollisions)
+		}
+		candidate = fmt.Sprintf("%s_%d", name, i)
+	}
+}
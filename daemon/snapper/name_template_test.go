@@ -0,0 +1,108 @@
+package snapper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNameTemplate(t *testing.T) {
+
+	now := time.Date(2021, time.March, 7, 14, 5, 9, 0, time.UTC)
+
+	type tc struct {
+		tmpl     string
+		timezone string
+		prefix   string
+		expand   string
+		err      string
+	}
+
+	tcs := map[string]tc{
+		"zfs-auto-snapshot": {
+			tmpl:   "zfs-auto-snap_hourly-%Y-%m-%d-%H%M",
+			prefix: "zfs-auto-snap_hourly-",
+			expand: "zfs-auto-snap_hourly-2021-03-07-1405",
+		},
+		"sanoid": {
+			tmpl:   "autosnap_%F_%T_daily",
+			prefix: "autosnap_",
+			expand: "autosnap_2021-03-07_14:05:09_daily",
+		},
+		"variables": {
+			tmpl:   "zrepl_%{job}_%{hostname}_%s",
+			prefix: "zrepl_",
+			expand: "zrepl_myjob_myhost_1615125909",
+		},
+		"escaped percent is invalid in snapshot names": {
+			tmpl:   "zrepl_%j_%%",
+			prefix: "zrepl_",
+			err:    "not a valid snapshot name",
+		},
+		"timezone": {
+			tmpl:     "zrepl_%H%M_%Z",
+			timezone: "Europe/Berlin",
+			prefix:   "zrepl_",
+			expand:   "zrepl_1505_CET",
+		},
+		"invalid timezone": {
+			tmpl:     "zrepl_%H",
+			timezone: "Nowhere/Special",
+			prefix:   "zrepl_",
+			err:      "time zone",
+		},
+		"no time placeholder": {
+			tmpl:   "zrepl_%{job}",
+			prefix: "zrepl_",
+			err:    "at least one time placeholder",
+		},
+		"unknown conversion": {
+			tmpl:   "zrepl_%Q",
+			prefix: "zrepl_",
+			err:    "unsupported conversion",
+		},
+		"unknown variable": {
+			tmpl:   "zrepl_%{pool}_%H",
+			prefix: "zrepl_",
+			err:    "unknown variable",
+		},
+		"unterminated variable": {
+			tmpl:   "zrepl_%H_%{job",
+			prefix: "zrepl_",
+			err:    "unterminated variable",
+		},
+		"trailing percent": {
+			tmpl:   "zrepl_%H%",
+			prefix: "zrepl_",
+			err:    "single '%'",
+		},
+		"prefix mismatch": {
+			tmpl:   "autosnap_%F",
+			prefix: "zrepl_",
+			err:    "does not begin with prefix",
+		},
+		"invalid characters": {
+			tmpl:   "zrepl_%F/%T",
+			prefix: "zrepl_",
+			err:    "not a valid snapshot name",
+		},
+	}
+
+	for name, c := range tcs {
+		t.Run(name, func(t *testing.T) {
+			if c.timezone == "" {
+				c.timezone = "UTC"
+			}
+			nt, err := parseNameTemplate(c.tmpl, c.timezone, c.prefix, "myjob", "myhost")
+			if c.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), c.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expand, nt.expand(now))
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package snapper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// OnDemandRequest describes a snapshot that is taken immediately, outside of the snapper's schedule.
+type OnDemandRequest struct {
+	// If empty, all filesystems matched by the job's filter are snapshotted.
+	// Otherwise, each filesystem must be matched by the job's filter.
+	Filesystems []string
+	// Overrides the snapper's prefix. Required for manual snapshotting.
+	Prefix string
+	// If not empty, the snapshot name is Prefix + Suffix instead of
+	// the snapper's timestamp format or name template.
+	Suffix string
+}
+
+type onDemandSnapper interface {
+	onDemandPlanArgs() (args planArgs, ok bool)
+}
+
+func (s *Periodic) onDemandPlanArgs() (planArgs, bool) { return s.args.planArgs, true }
+func (s *Cron) onDemandPlanArgs() (planArgs, bool)     { return s.planArgs, true }
+func (s *manual) onDemandPlanArgs() (planArgs, bool)   { return planArgs{jobName: s.jobName}, false }
+
+// The first schedule's prefix and hooks are used.
+func (m *multi) onDemandPlanArgs() (planArgs, bool) {
+	return m.snappers[0].(onDemandSnapper).onDemandPlanArgs()
+}
+
+// OnDemand snapshots the filesystems matched by fsf immediately,
+// running the hooks configured for snapper s.
+// The returned reports contain the name and state of each snapshot.
+// An error is only returned if snapshotting could not be started,
+// failures of individual filesystems are reported with state SnapError.
+func OnDemand(ctx context.Context, s Snapper, fsf zfs.DatasetFilter, req OnDemandRequest) ([]*ReportFilesystem, error) {
+	args, ok := s.(onDemandSnapper).onDemandPlanArgs()
+	if !ok {
+		args = planArgs{jobName: args.jobName, timestampFormat: "dense", hooks: &hooks.List{}}
+	}
+	args.skipUnchanged = false
+	if req.Prefix != "" {
+		args.prefix = req.Prefix
+		args.nameTemplate = nil
+	}
+	if args.prefix == "" {
+		return nil, errors.New("job uses manual snapshotting, prefix must be specified")
+	}
+	if req.Suffix != "" {
+		if err := zfs.ComponentNamecheck(args.prefix + req.Suffix); err != nil {
+			return nil, errors.Wrapf(err, "invalid snapshot name %q", args.prefix+req.Suffix)
+		}
+		args.fixedSuffix = req.Suffix
+		args.nameTemplate = nil
+	} else if err := zfs.ComponentNamecheck(args.prefix); err != nil {
+		return nil, errors.Wrapf(err, "invalid prefix %q", args.prefix)
+	}
+
+	fss, err := listFSes(ctx, fsf)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list filesystems")
+	}
+	if len(req.Filesystems) > 0 {
+		byName := make(map[string]*zfs.DatasetPath, len(fss))
+		for _, fs := range fss {
+			byName[fs.ToString()] = fs
+		}
+		selected := make([]*zfs.DatasetPath, 0, len(req.Filesystems))
+		for _, name := range req.Filesystems {
+			fs, ok := byName[name]
+			if !ok {
+				return nil, fmt.Errorf("filesystem %q does not exist or is not snapshotted by this job", name)
+			}
+			selected = append(selected, fs)
+		}
+		fss = selected
+	}
+
+	plan := makePlan(args, fss)
+	plan.execute(ctx, false)
+	return plan.report(), nil
+}
@@ -0,0 +1,53 @@
+package snapper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/hooks"
+)
+
+func TestOnDemandValidation(t *testing.T) {
+
+	cron := &Cron{planArgs: planArgs{prefix: "zrepl_", timestampFormat: "dense", hooks: &hooks.List{}}}
+
+	type tc struct {
+		s   Snapper
+		req OnDemandRequest
+		err string
+	}
+
+	tcs := map[string]tc{
+		"manual without prefix": {
+			s:   &manual{},
+			req: OnDemandRequest{Suffix: "pre-upgrade"},
+			err: "prefix must be specified",
+		},
+		"invalid suffix": {
+			s:   cron,
+			req: OnDemandRequest{Suffix: "pre/upgrade"},
+			err: "invalid snapshot name \"zrepl_pre/upgrade\"",
+		},
+		"invalid prefix": {
+			s:   &manual{},
+			req: OnDemandRequest{Prefix: "zrepl@"},
+			err: "invalid prefix",
+		},
+		"multi uses first schedule": {
+			s:   &multi{snappers: []Snapper{&manual{}, cron}},
+			req: OnDemandRequest{Suffix: "pre-upgrade"},
+			err: "prefix must be specified",
+		},
+	}
+
+	for name, c := range tcs {
+		t.Run(name, func(t *testing.T) {
+			_, err := OnDemand(context.Background(), c.s, nil, c.req)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), c.err)
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package snapper
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// overridesFromConfig builds a multi snapper for a periodic or cron snapshotter with overrides:
+// every override gets a snapper that inherits all settings but the interval / cron schedule from in.
+// A filesystem is snapshotted by the first override whose filter matches it, or by the
+// snapper built from in if no override matches.
+func overridesFromConfig(g *config.Global, jobName string, fsf zfs.DatasetFilter, in config.SnapshottingEnum) (*multi, error) {
+
+	var base config.SnapshottingPeriodic // common fields of periodic and cron
+	var overrides []config.SnapshottingOverride
+	switch v := in.Ret.(type) {
+	case *config.SnapshottingPeriodic:
+		base = *v
+		overrides = v.Overrides
+	case *config.SnapshottingCron:
+		base = config.SnapshottingPeriodic{
+			Prefix:           v.Prefix,
+			Hooks:            v.Hooks,
+			TimestampFormat:  v.TimestampFormat,
+			NameTemplate:     v.NameTemplate,
+			NameTimezone:     v.NameTimezone,
+			SkipUnchanged:    v.SkipUnchanged,
+			HooksConcurrency: v.HooksConcurrency,
+			Jitter:           v.Jitter,
+		}
+		overrides = v.Overrides
+	default:
+		panic(fmt.Sprintf("unexpected snapshotting type %T", v))
+	}
+
+	overrideFilters := make([]zfs.DatasetFilter, len(overrides))
+	for i, o := range overrides {
+		f, err := filters.DatasetMapFilterFromConfig(o.Filesystems)
+		if err != nil {
+			return nil, errors.Wrapf(err, "override #%d: invalid filesystems filter", i)
+		}
+		overrideFilters[i] = f
+	}
+
+	m := &multi{}
+
+	baseFilter := &overrideFilter{job: fsf, exclude: overrideFilters}
+	var s Snapper
+	var err error
+	switch v := in.Ret.(type) {
+	case *config.SnapshottingPeriodic:
+		c := *v
+		c.Overrides = nil
+		s, err = periodicFromConfig(g, jobName, baseFilter, &c)
+	case *config.SnapshottingCron:
+		c := *v
+		c.Overrides = nil
+		s, err = cronFromConfig(jobName, baseFilter, c)
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.snappers = append(m.snappers, s)
+
+	for i, o := range overrides {
+		f := &overrideFilter{job: fsf, include: overrideFilters[i], exclude: overrideFilters[:i]}
+		var s Snapper
+		var err error
+		switch {
+		case o.Interval != nil && o.Cron == nil:
+			c := base
+			c.Type = "periodic"
+			c.Interval = o.Interval
+			c.Overrides = nil
+			s, err = periodicFromConfig(g, jobName, f, &c)
+		case o.Cron != nil && o.Interval == nil:
+			s, err = cronFromConfig(jobName, f, config.SnapshottingCron{
+				Type:             "cron",
+				Prefix:           base.Prefix,
+				Cron:             *o.Cron,
+				Hooks:            base.Hooks,
+				TimestampFormat:  base.TimestampFormat,
+				NameTemplate:     base.NameTemplate,
+				NameTimezone:     base.NameTimezone,
+				SkipUnchanged:    base.SkipUnchanged,
+				HooksConcurrency: base.HooksConcurrency,
+				Jitter:           base.Jitter,
+			})
+		default:
+			return nil, fmt.Errorf("override #%d: exactly one of `interval` or `cron` must be specified", i)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "override #%d", i)
+		}
+		m.snappers = append(m.snappers, s)
+	}
+
+	return m, nil
+}
+
+// overrideFilter passes the filesystems of the job's filter that pass include
+// (nil passes all) and do not pass any of the exclude filters.
+type overrideFilter struct {
+	job     zfs.DatasetFilter
+	include zfs.DatasetFilter
+	exclude []zfs.DatasetFilter
+}
+
+var _ zfs.DatasetFilter = (*overrideFilter)(nil)
+
+func (f *overrideFilter) Filter(p *zfs.DatasetPath) (bool, error) {
+	pass, err := f.job.Filter(p)
+	if err != nil || !pass {
+		return false, err
+	}
+	if f.include != nil {
+		pass, err := f.include.Filter(p)
+		if err != nil || !pass {
+			return false, err
+		}
+	}
+	for _, e := range f.exclude {
+		pass, err := e.Filter(p)
+		if err != nil {
+			return false, err
+		}
+		if pass {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (f *overrideFilter) UserSpecifiedDatasets() zfs.UserSpecifiedDatasetsSet {
+	return f.job.UserSpecifiedDatasets()
+}
@@ -0,0 +1,108 @@
+package snapper
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestOverridesFromConfig(t *testing.T) {
+	tmpl := `
+jobs:
+- name: snapjob
+  type: snap
+  filesystems: {"tank<": true, "tank/tmp": false}
+  snapshotting:
+    type: periodic
+    prefix: zrepl_
+    interval: 1h
+    overrides:
+%s
+  pruning:
+    keep:
+    - type: last_n
+      count: 1
+`
+
+	t.Run("interval_and_cron", func(t *testing.T) {
+		c, err := config.ParseConfigBytes([]byte(fmt.Sprintf(tmpl, `
+    - filesystems: {"tank/db<": true}
+      interval: 5m
+    - filesystems: {"tank/db/logs": true, "tank/media<": true}
+      cron: "0 0 * * *"
+`)))
+		require.NoError(t, err)
+		snapJob := c.Jobs[0].Ret.(*config.SnapJob)
+		fsf, err := filters.DatasetMapFilterFromConfig(snapJob.Filesystems)
+		require.NoError(t, err)
+
+		s, err := FromConfig(c.Global, snapJob.Name, fsf, snapJob.Snapshotting)
+		require.NoError(t, err)
+		m := s.(*multi)
+		require.Len(t, m.snappers, 3)
+
+		base := m.snappers[0].(*Periodic)
+		assert.Equal(t, time.Hour, base.args.interval)
+		db := m.snappers[1].(*Periodic)
+		assert.Equal(t, 5*time.Minute, db.args.interval)
+		assert.Equal(t, "zrepl_", db.args.planArgs.prefix)
+		media := m.snappers[2].(*Cron)
+		assert.Equal(t, "zrepl_", media.planArgs.prefix)
+
+		// each filesystem is snapshotted by exactly one snapper, the first override wins
+		expect := map[string]Snapper{
+			"tank":         base,
+			"tank/home":    base,
+			"tank/db":      db,
+			"tank/db/logs": db,
+			"tank/media/a": media,
+			"tank/tmp":     nil,
+			"other":        nil,
+		}
+		filterOf := func(s Snapper) zfs.DatasetFilter {
+			switch s := s.(type) {
+			case *Periodic:
+				return s.args.fsf
+			case *Cron:
+				return s.fsf
+			}
+			panic(s)
+		}
+		for fs, expectSnapper := range expect {
+			p, err := zfs.NewDatasetPath(fs)
+			require.NoError(t, err)
+			for _, s := range m.snappers {
+				pass, err := filterOf(s).Filter(p)
+				require.NoError(t, err)
+				assert.Equal(t, s == expectSnapper, pass, "fs=%s", fs)
+			}
+		}
+	})
+
+	for name, overrides := range map[string]string{
+		"interval_and_cron_set": `
+    - filesystems: {"tank/db<": true}
+      interval: 5m
+      cron: "0 0 * * *"
+`,
+		"neither_interval_nor_cron": `
+    - filesystems: {"tank/db<": true}
+`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, err := config.ParseConfigBytes([]byte(fmt.Sprintf(tmpl, overrides)))
+			require.NoError(t, err)
+			snapJob := c.Jobs[0].Ret.(*config.SnapJob)
+			_, err = FromConfig(c.Global, snapJob.Name, zfs.NoFilter(), snapJob.Snapshotting)
+			t.Logf("error: %s", err)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -13,13 +13,15 @@ import (
 
 	"github.com/zrepl/zrepl/config"
 	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/job/disable"
+	"github.com/zrepl/zrepl/daemon/job/maintenance"
 	"github.com/zrepl/zrepl/daemon/logging"
 	"github.com/zrepl/zrepl/util/envconst"
 	"github.com/zrepl/zrepl/util/suspendresumesafetimer"
 	"github.com/zrepl/zrepl/zfs"
 )
 
-func periodicFromConfig(g *config.Global, fsf zfs.DatasetFilter, in *config.SnapshottingPeriodic) (*Periodic, error) {
+func periodicFromConfig(g *config.Global, jobName string, fsf zfs.DatasetFilter, in *config.SnapshottingPeriodic) (*Periodic, error) {
 	if in.Prefix == "" {
 		return nil, errors.New("prefix must not be empty")
 	}
@@ -31,14 +33,27 @@ func periodicFromConfig(g *config.Global, fsf zfs.DatasetFilter, in *config.Snap
 	if err != nil {
 		return nil, errors.Wrap(err, "hook config error")
 	}
+	if in.HooksConcurrency < 1 {
+		return nil, errors.New("field `hooks_concurrency` must be positive")
+	}
+
+	nameTemplate, err := nameTemplateFromConfig(in.NameTemplate, in.NameTimezone, in.Prefix, jobName)
+	if err != nil {
+		return nil, errors.Wrap(err, "field `name_template`")
+	}
 
 	args := periodicArgs{
-		interval: in.Interval.Duration(),
-		fsf:      fsf,
+		interval:     in.Interval.Duration(),
+		jitterOffset: jitterOffset(jobName, in.Prefix, in.Jitter),
+		fsf:          fsf,
 		planArgs: planArgs{
-			prefix:          in.Prefix,
-			timestampFormat: in.TimestampFormat,
-			hooks:           hookList,
+			jobName:          jobName,
+			prefix:           in.Prefix,
+			timestampFormat:  in.TimestampFormat,
+			nameTemplate:     nameTemplate,
+			skipUnchanged:    in.SkipUnchanged,
+			hooks:            hookList,
+			hooksConcurrency: in.HooksConcurrency,
 		},
 		// ctx and log is set in Run()
 	}
@@ -49,6 +64,7 @@ func periodicFromConfig(g *config.Global, fsf zfs.DatasetFilter, in *config.Snap
 type periodicArgs struct {
 	ctx            context.Context
 	interval       time.Duration
+	jitterOffset   time.Duration // delay of snapshots that are due immediately after sync-up
 	fsf            zfs.DatasetFilter
 	planArgs       planArgs
 	snapshotsTaken chan<- struct{}
@@ -170,6 +186,11 @@ func periodicStateSyncUp(a periodicArgs, u updater) state {
 	if err != nil {
 		return onErr(err, u)
 	}
+	// Existing snapshots already carry the offset of earlier runs.
+	// Only apply it if snapshots are due now, e.g. on the first run of many hosts started at once.
+	if now := time.Now(); a.jitterOffset > 0 && !syncPoint.After(now) {
+		syncPoint = now.Add(a.jitterOffset)
+	}
 	u(func(s *Periodic) {
 		s.sleepUntil = syncPoint
 	})
@@ -186,6 +207,14 @@ func periodicStatePlan(a periodicArgs, u updater) state {
 	u(func(snapper *Periodic) {
 		snapper.lastInvocation = time.Now()
 	})
+	if disable.Disabled(a.ctx) || maintenance.Get(a.ctx).Active {
+		getLogger(a.ctx).Info("job is disabled or in maintenance, skipping snapshots")
+		return u(func(s *Periodic) {
+			s.state = Waiting
+			s.plan = nil
+			s.err = nil
+		}).sf()
+	}
 	fss, err := listFSes(a.ctx, a.fsf)
 	if err != nil {
 		return onErr(err, u)
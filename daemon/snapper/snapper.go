@@ -14,6 +14,7 @@ const (
 	TypePeriodic Type = "periodic"
 	TypeCron     Type = "cron"
 	TypeManual   Type = "manual"
+	TypeMulti    Type = "multi"
 )
 
 type Snapper interface {
@@ -26,16 +27,26 @@ type Report struct {
 	Periodic *PeriodicReport
 	Cron     *CronReport
 	Manual   *struct{}
+	Multi    []*Report // one per schedule, in config order
 }
 
-func FromConfig(g *config.Global, fsf zfs.DatasetFilter, in config.SnapshottingEnum) (Snapper, error) {
+// jobName is used for the `%{job}` variable in snapshot name templates.
+func FromConfig(g *config.Global, jobName string, fsf zfs.DatasetFilter, in config.SnapshottingEnum) (Snapper, error) {
 	switch v := in.Ret.(type) {
 	case *config.SnapshottingPeriodic:
-		return periodicFromConfig(g, fsf, v)
+		if len(v.Overrides) > 0 {
+			return overridesFromConfig(g, jobName, fsf, in)
+		}
+		return periodicFromConfig(g, jobName, fsf, v)
 	case *config.SnapshottingCron:
-		return cronFromConfig(fsf, *v)
+		if len(v.Overrides) > 0 {
+			return overridesFromConfig(g, jobName, fsf, in)
+		}
+		return cronFromConfig(jobName, fsf, *v)
 	case *config.SnapshottingManual:
-		return &manual{}, nil
+		return &manual{jobName: jobName}, nil
+	case *config.SnapshottingMulti:
+		return multiFromConfig(g, jobName, fsf, v)
 	default:
 		return nil, fmt.Errorf("unknown snapshotting type %T", v)
 	}
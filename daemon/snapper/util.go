@@ -2,6 +2,9 @@ package snapper
 
 import (
 	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/zrepl/zrepl/daemon/logging"
 	"github.com/zrepl/zrepl/logger"
@@ -19,3 +22,19 @@ func errOrEmptyString(e error) string {
 	}
 	return ""
 }
+
+type contextKey int
+
+const contextKeyDurationObserver contextKey = iota
+
+// WithDurationObserver returns a context whose snapshotting runs observe their duration in seconds with o,
+// i.e., each run of the hooks and snapshots of all filesystems due at the same time.
+func WithDurationObserver(ctx context.Context, o prometheus.Observer) context.Context {
+	return context.WithValue(ctx, contextKeyDurationObserver, o)
+}
+
+func observeDuration(ctx context.Context, start time.Time) {
+	if o, ok := ctx.Value(contextKeyDurationObserver).(prometheus.Observer); ok {
+		o.Observe(time.Since(start).Seconds())
+	}
+}
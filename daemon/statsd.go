@@ -0,0 +1,262 @@
+package daemon
+
+import (
+	"context"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/job/ready"
+	"github.com/zrepl/zrepl/daemon/snapper"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/util/envconst"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// statsdMaxPacketSize keeps the packets below the MTU of common networks
+var statsdMaxPacketSize = envconst.Int("ZREPL_DAEMON_STATSD_MAX_PACKET_SIZE", 1432)
+
+// statsdJob periodically sends the metrics of the default registry to a StatsD server, see config.StatsdMonitoring.
+type statsdJob struct {
+	address   string
+	dogstatsd bool
+	prefix    string
+	tags      []string // dogstatsd tags of every metric, formatted and sorted
+	interval  time.Duration
+	metrics   []string // name prefixes
+	counters  *statsdCounters
+}
+
+func newStatsdJobFromConfig(in *config.StatsdMonitoring) (*statsdJob, error) {
+	if _, _, err := net.SplitHostPort(in.Address); err != nil {
+		return nil, err
+	}
+	j := &statsdJob{
+		address:  in.Address,
+		interval: in.Interval,
+		metrics:  in.Metrics,
+		counters: globalStatsdCounters,
+	}
+	switch in.Format {
+	case "statsd":
+		if len(in.Tags) > 0 {
+			return nil, errors.New("tags require format dogstatsd")
+		}
+	case "dogstatsd":
+		j.dogstatsd = true
+	default:
+		return nil, errors.Errorf("invalid format %q, must be one of statsd, dogstatsd", in.Format)
+	}
+	if in.Prefix != "" {
+		j.prefix = in.Prefix + "."
+	}
+	if len(j.metrics) == 0 {
+		j.metrics = []string{"zrepl_"}
+	}
+	for k, v := range in.Tags {
+		j.tags = append(j.tags, statsdTag(k, v))
+	}
+	sort.Strings(j.tags)
+	return j, nil
+}
+
+func (j *statsdJob) Name() string { return jobNameStatsd }
+
+func (j *statsdJob) Status() *job.Status { return &job.Status{Type: job.TypeInternal} }
+
+func (j *statsdJob) OwnedDatasetSubtreeRoot() (p *zfs.DatasetPath, ok bool) { return nil, false }
+
+func (j *statsdJob) SenderConfig() *endpoint.SenderConfig { return nil }
+
+func (j *statsdJob) Snapper() (snapper.Snapper, zfs.DatasetFilter, bool) { return nil, nil, false }
+
+func (j *statsdJob) RegisterMetrics(registerer prometheus.Registerer) {}
+
+func (j *statsdJob) Run(ctx context.Context) {
+
+	registerGlobalMetrics()
+
+	log := job.GetLogger(ctx)
+
+	conn, err := net.Dial("udp", j.address)
+	if err != nil {
+		log.WithError(err).Error("cannot connect to statsd server")
+		return
+	}
+	defer conn.Close()
+	ready.Signal(ctx)
+
+	t := time.NewTicker(j.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		mfs, err := prometheus.DefaultGatherer.Gather()
+		if err != nil {
+			// Gather returns the metrics it could collect despite the error
+			log.WithError(err).Warn("cannot gather all metrics")
+		}
+		for _, p := range statsdPackets(j.lines(mfs), statsdMaxPacketSize) {
+			if _, err := conn.Write(p); err != nil {
+				log.WithError(err).Warn("cannot send metrics to statsd server")
+				break
+			}
+		}
+	}
+}
+
+// statsdCounters tracks the last sent value of each counter series
+// because StatsD counters are sent as the increment since the last flush.
+type statsdCounters struct {
+	mtx  sync.Mutex
+	last map[string]float64 // by series, see statsdJob.lines
+}
+
+// globalStatsdCounters outlives the job, which is restarted if the monitoring config changes on reload,
+// so that the restart does not send the Prometheus counters' values again.
+var globalStatsdCounters = newStatsdCounters()
+
+func newStatsdCounters() *statsdCounters {
+	return &statsdCounters{last: make(map[string]float64)}
+}
+
+// delta returns the increment of series since the last call, or v if the series is new or was reset.
+func (c *statsdCounters) delta(series string, v float64) float64 {
+	last, ok := c.last[series]
+	c.last[series] = v
+	if !ok || v < last {
+		return v
+	}
+	return v - last
+}
+
+// lines converts the families whose name matches j.metrics to StatsD lines:
+// counters are sent as counters of the increment since the last call,
+// gauges and untyped metrics as gauges,
+// and histograms and summaries as counters of their _count and _sum.
+func (j *statsdJob) lines(mfs []*dto.MetricFamily) []string {
+	j.counters.mtx.Lock()
+	defer j.counters.mtx.Unlock()
+
+	var lines []string
+	for _, f := range mfs {
+		if !metricsMatch(f.GetName(), j.metrics) {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			name, tags := j.nameAndTags(f.GetName(), m.GetLabel())
+			series := f.GetName() + "{" + statsdSeriesLabels(m.GetLabel()) + "}"
+			counter := func(suffix string, v float64) {
+				if d := j.counters.delta(series+suffix, v); d != 0 {
+					lines = append(lines, j.line(name+suffix, d, "c", tags)...)
+				}
+			}
+			switch f.GetType() {
+			case dto.MetricType_COUNTER:
+				counter("", m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				lines = append(lines, j.line(name, m.GetGauge().GetValue(), "g", tags)...)
+			case dto.MetricType_HISTOGRAM:
+				counter("_count", float64(m.GetHistogram().GetSampleCount()))
+				counter("_sum", m.GetHistogram().GetSampleSum())
+			case dto.MetricType_SUMMARY:
+				counter("_count", float64(m.GetSummary().GetSampleCount()))
+				counter("_sum", m.GetSummary().GetSampleSum())
+			default:
+				lines = append(lines, j.line(name, m.GetUntyped().GetValue(), "g", tags)...)
+			}
+		}
+	}
+	return lines
+}
+
+func statsdSeriesLabels(labels []*dto.LabelPair) string {
+	pairs := make([]string, len(labels))
+	for i, l := range labels {
+		pairs[i] = l.GetName() + "=" + strconv.Quote(l.GetValue())
+	}
+	return strings.Join(pairs, ",")
+}
+
+// nameAndTags returns the StatsD name of a series and, for dogstatsd, its tags.
+// Plain StatsD has no tags, so the labels become components of the name.
+// Labels with an empty value are omitted, like Prometheus does.
+func (j *statsdJob) nameAndTags(name string, labels []*dto.LabelPair) (string, string) {
+	components := []string{j.prefix + statsdNameComponent(name)}
+	tags := append([]string(nil), j.tags...)
+	for _, l := range labels {
+		if l.GetValue() == "" {
+			continue
+		}
+		if j.dogstatsd {
+			tags = append(tags, statsdTag(l.GetName(), l.GetValue()))
+		} else {
+			components = append(components, statsdNameComponent(l.GetName()), statsdNameComponent(l.GetValue()))
+		}
+	}
+	if len(tags) == 0 {
+		return strings.Join(components, "."), ""
+	}
+	return strings.Join(components, "."), "|#" + strings.Join(tags, ",")
+}
+
+func (j *statsdJob) line(name string, v float64, typ string, tags string) []string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	value := func(v float64) string {
+		return name + ":" + strconv.FormatFloat(v, 'f', -1, 64) + "|" + typ + tags
+	}
+	if typ == "g" && v < 0 && !j.dogstatsd {
+		// StatsD interprets a signed gauge value as a change of the gauge
+		return []string{value(0), value(v)}
+	}
+	return []string{value(v)}
+}
+
+func statsdNameComponent(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+func statsdTag(k, v string) string {
+	sanitize := strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_")
+	return strings.Replace(sanitize.Replace(k), ":", "_", -1) + ":" + sanitize.Replace(v)
+}
+
+// statsdPackets joins lines into packets of at most maxSize bytes, unless a single line is longer.
+func statsdPackets(lines []string, maxSize int) [][]byte {
+	var packets [][]byte
+	var p []byte
+	for _, l := range lines {
+		if len(p) > 0 && len(p)+1+len(l) > maxSize {
+			packets = append(packets, p)
+			p = nil
+		}
+		if len(p) > 0 {
+			p = append(p, '\n')
+		}
+		p = append(p, l...)
+	}
+	if len(p) > 0 {
+		packets = append(packets, p)
+	}
+	return packets
+}
@@ -0,0 +1,80 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+)
+
+func TestStatsdLines(t *testing.T) {
+	r := prometheus.NewRegistry()
+	bytes := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "zrepl_bytes"}, []string{"filesystem", "zrepl_job"})
+	lag := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "zrepl_lag"}, []string{"zrepl_job"})
+	step := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "zrepl_step"})
+	other := prometheus.NewGauge(prometheus.GaugeOpts{Name: "go_other"})
+	r.MustRegister(bytes, lag, step, other)
+
+	lines := func(j *statsdJob) []string {
+		mfs, err := r.Gather()
+		require.NoError(t, err)
+		return j.lines(mfs)
+	}
+
+	bytes.WithLabelValues("pool/data", "push").Add(100)
+	lag.WithLabelValues("").Set(-2)
+	step.Observe(1.5)
+	other.Set(1)
+
+	j, err := newStatsdJobFromConfig(&config.StatsdMonitoring{Address: "localhost:8125", Format: "statsd", Prefix: "host1"})
+	require.NoError(t, err)
+	j.counters = newStatsdCounters()
+	assert.Equal(t, []string{
+		"host1.zrepl_bytes.filesystem.pool_data.zrepl_job.push:100|c",
+		"host1.zrepl_lag:0|g",
+		"host1.zrepl_lag:-2|g",
+		"host1.zrepl_step_count:1|c",
+		"host1.zrepl_step_sum:1.5|c",
+	}, lines(j))
+
+	bytes.WithLabelValues("pool/data", "push").Add(50)
+	lag.WithLabelValues("").Set(3)
+	assert.Equal(t, []string{
+		"host1.zrepl_bytes.filesystem.pool_data.zrepl_job.push:50|c",
+		"host1.zrepl_lag:3|g",
+	}, lines(j), "counters are sent as increments, unchanged counters are omitted")
+
+	j, err = newStatsdJobFromConfig(&config.StatsdMonitoring{
+		Address: "localhost:8125",
+		Format:  "dogstatsd",
+		Tags:    map[string]string{"env": "prod", "dc": "a,b"},
+		Metrics: []string{"zrepl_bytes", "go_"},
+	})
+	require.NoError(t, err)
+	j.counters = newStatsdCounters()
+	assert.Equal(t, []string{
+		"go_other:1|g|#dc:a_b,env:prod",
+		"zrepl_bytes:150|c|#dc:a_b,env:prod,filesystem:pool/data,zrepl_job:push",
+	}, lines(j))
+
+	_, err = newStatsdJobFromConfig(&config.StatsdMonitoring{Address: "localhost:8125", Format: "statsd", Tags: map[string]string{"env": "prod"}})
+	assert.Error(t, err)
+	_, err = newStatsdJobFromConfig(&config.StatsdMonitoring{Address: "localhost:8125", Format: "graphite"})
+	assert.Error(t, err)
+}
+
+func TestStatsdPackets(t *testing.T) {
+	assert.Nil(t, statsdPackets(nil, 10))
+	long := strings.Repeat("x", 12)
+	packets := statsdPackets([]string{"a:1|c", "b:2|c", long, "c:3|g"}, 11)
+	assert.Equal(t, []string{"a:1|c\nb:2|c", long, "c:3|g"}, func() (s []string) {
+		for _, p := range packets {
+			s = append(s, string(p))
+		}
+		return s
+	}())
+}
@@ -0,0 +1,376 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/job/ready"
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/daemon/snapper"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/replication/report"
+	"github.com/zrepl/zrepl/util/envconst"
+	"github.com/zrepl/zrepl/util/tcpsock"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// webUIJob serves a read-only dashboard of the jobs' status, see config.WebUIMonitoring.
+type webUIJob struct {
+	listen   string
+	freeBind bool
+	jobs     *jobs
+	tracker  *webUITracker
+}
+
+func newWebUIJobFromConfig(in *config.WebUIMonitoring, jobs *jobs) (*webUIJob, error) {
+	if _, _, err := net.SplitHostPort(in.Listen); err != nil {
+		return nil, err
+	}
+	return &webUIJob{
+		listen:   in.Listen,
+		freeBind: in.ListenFreeBind,
+		jobs:     jobs,
+		tracker:  newWebUITracker(),
+	}, nil
+}
+
+func (j *webUIJob) Name() string { return jobNameWebUI }
+
+func (j *webUIJob) Status() *job.Status { return &job.Status{Type: job.TypeInternal} }
+
+func (j *webUIJob) OwnedDatasetSubtreeRoot() (p *zfs.DatasetPath, ok bool) { return nil, false }
+
+func (j *webUIJob) SenderConfig() *endpoint.SenderConfig { return nil }
+
+func (j *webUIJob) Snapper() (snapper.Snapper, zfs.DatasetFilter, bool) { return nil, nil, false }
+
+func (j *webUIJob) RegisterMetrics(registerer prometheus.Registerer) {}
+
+func (j *webUIJob) Run(ctx context.Context) {
+	log := job.GetLogger(ctx)
+
+	l, err := tcpsock.Listen(j.listen, j.freeBind)
+	if err != nil {
+		log.WithError(err).Error("cannot listen")
+		return
+	}
+	ready.Signal(ctx)
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	// sample while nobody is looking at the UI so that the replication lag
+	// and the recent errors cover the time before the page was opened
+	go func() {
+		t := time.NewTicker(envconst.Duration("ZREPL_DAEMON_WEBUI_SAMPLE_INTERVAL", 10*time.Second))
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-t.C:
+				j.tracker.observe(j.jobs.status(), now)
+			}
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, webUIIndexHTML)
+	})
+	mux.HandleFunc("/status.json", func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		statuses := j.jobs.status()
+		j.tracker.observe(statuses, now)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(j.tracker.view(statuses, now)); err != nil {
+			log.WithError(err).Error("cannot encode web ui status")
+		}
+	})
+
+	err = http.Serve(l, mux)
+	if err != nil && ctx.Err() == nil {
+		log.WithError(err).Error("error while serving")
+	}
+}
+
+const (
+	webUIHealthOK          = "ok"
+	webUIHealthRunning     = "running"
+	webUIHealthError       = "error"
+	webUIHealthDisabled    = "disabled"
+	webUIHealthMaintenance = "maintenance"
+)
+
+type webUIStatus struct {
+	Time time.Time
+	Jobs []webUIJobStatus
+	// newest first
+	Errors []webUIError
+}
+
+type webUIJobStatus struct {
+	Name        string
+	Type        job.Type
+	Health      string
+	Filesystems []webUIFilesystem `json:",omitempty"`
+}
+
+type webUIFilesystem struct {
+	Name                           string
+	State                          report.FilesystemState
+	BytesExpected, BytesReplicated uint64
+	// nil if no successful replication of the filesystem has been observed yet
+	LastReplicated *time.Time
+}
+
+type webUIError struct {
+	Time   time.Time // zero if the report has no timestamp for the error
+	Job    string
+	Source string // e.g. replication, pruning sender
+	Error  string
+}
+
+type webUIRecordedError struct {
+	key      webUIError
+	observed time.Time
+}
+
+// webUITracker remembers what is not part of the jobs' current status:
+// when each filesystem was last replicated and the errors of previous invocations.
+type webUITracker struct {
+	mtx            sync.Mutex
+	lastReplicated map[string]map[string]time.Time // by job, by filesystem
+	errors         []webUIRecordedError            // oldest first
+	seenErrors     map[webUIError]bool
+}
+
+var webUIMaxErrors = envconst.Int("ZREPL_DAEMON_WEBUI_MAX_ERRORS", 50)
+
+func newWebUITracker() *webUITracker {
+	return &webUITracker{
+		lastReplicated: make(map[string]map[string]time.Time),
+		seenErrors:     make(map[webUIError]bool),
+	}
+}
+
+func (t *webUITracker) observe(statuses map[string]*job.Status, now time.Time) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	for name, st := range statuses {
+		if st == nil || IsInternalJobName(name) {
+			continue
+		}
+		switch s := st.JobSpecific.(type) {
+		case *job.ActiveSideStatus:
+			t.observeReplication(name, s.Replication, now)
+		}
+		for _, e := range webUIJobErrors(name, st) {
+			t.addError(e, now)
+		}
+	}
+}
+
+func (t *webUITracker) observeReplication(jobName string, rep *report.Report, now time.Time) {
+	if rep == nil || len(rep.Attempts) == 0 {
+		return
+	}
+	a := rep.Attempts[len(rep.Attempts)-1]
+	done := a.FinishAt
+	if done.IsZero() {
+		// the attempt is still running, the filesystem finished before now
+		done = now
+	}
+	for _, fs := range a.Filesystems {
+		if fs.State != report.FilesystemDone {
+			continue
+		}
+		if t.lastReplicated[jobName] == nil {
+			t.lastReplicated[jobName] = make(map[string]time.Time)
+		}
+		if done.After(t.lastReplicated[jobName][fs.Info.Name]) {
+			t.lastReplicated[jobName][fs.Info.Name] = done
+		}
+	}
+}
+
+// Errors are deduplicated because the same report is observed many times.
+// Errors without a timestamp are deduplicated by their message and recorded with the time they were first observed.
+func (t *webUITracker) addError(e webUIError, now time.Time) {
+	if t.seenErrors[e] {
+		return
+	}
+	t.seenErrors[e] = true
+	t.errors = append(t.errors, webUIRecordedError{e, now})
+	for len(t.errors) > webUIMaxErrors {
+		delete(t.seenErrors, t.errors[0].key)
+		t.errors = t.errors[1:]
+	}
+}
+
+func (t *webUITracker) view(statuses map[string]*job.Status, now time.Time) *webUIStatus {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	v := &webUIStatus{Time: now, Jobs: []webUIJobStatus{}, Errors: make([]webUIError, len(t.errors))}
+	for i, e := range t.errors {
+		ve := e.key
+		if ve.Time.IsZero() {
+			ve.Time = e.observed
+		}
+		v.Errors[len(t.errors)-1-i] = ve
+	}
+	for name, st := range statuses {
+		if st == nil || IsInternalJobName(name) {
+			continue
+		}
+		js := webUIJobStatus{Name: name, Type: st.Type, Health: webUIHealth(name, st)}
+		if s, ok := st.JobSpecific.(*job.ActiveSideStatus); ok && s.Replication != nil && len(s.Replication.Attempts) > 0 {
+			a := s.Replication.Attempts[len(s.Replication.Attempts)-1]
+			for _, fs := range a.Filesystems {
+				f := webUIFilesystem{Name: fs.Info.Name, State: fs.State}
+				f.BytesExpected, f.BytesReplicated, _ = fs.BytesSum()
+				if last, ok := t.lastReplicated[name][fs.Info.Name]; ok {
+					f.LastReplicated = &last
+				}
+				js.Filesystems = append(js.Filesystems, f)
+			}
+			sort.Slice(js.Filesystems, func(i, j int) bool { return js.Filesystems[i].Name < js.Filesystems[j].Name })
+		}
+		v.Jobs = append(v.Jobs, js)
+	}
+	sort.Slice(v.Jobs, func(i, j int) bool { return v.Jobs[i].Name < v.Jobs[j].Name })
+	return v
+}
+
+func webUIHealth(name string, st *job.Status) string {
+	if st.Disabled {
+		return webUIHealthDisabled
+	}
+	if st.Maintenance.Active {
+		return webUIHealthMaintenance
+	}
+	if len(webUIJobErrors(name, st)) > 0 {
+		return webUIHealthError
+	}
+	switch s := st.JobSpecific.(type) {
+	case *job.ActiveSideStatus:
+		if r := s.Replication; r != nil && !r.StartAt.IsZero() && r.FinishAt.IsZero() {
+			return webUIHealthRunning
+		}
+		if webUIPruning(s.PruningSender) || webUIPruning(s.PruningReceiver) {
+			return webUIHealthRunning
+		}
+	case *job.SnapJobStatus:
+		if webUIPruning(s.Pruning) {
+			return webUIHealthRunning
+		}
+	}
+	return webUIHealthOK
+}
+
+func webUIPruning(r *pruner.Report) bool {
+	return r != nil && r.State == pruner.Exec.String()
+}
+
+// webUIJobErrors returns the errors in the current status of a job.
+func webUIJobErrors(name string, st *job.Status) (errs []webUIError) {
+	add := func(t time.Time, source, err string) {
+		errs = append(errs, webUIError{Time: t, Job: name, Source: source, Error: err})
+	}
+	replication := func(source string, r *report.Report) {
+		if r == nil || len(r.Attempts) == 0 {
+			return
+		}
+		a := r.Attempts[len(r.Attempts)-1]
+		if a.PlanError != nil {
+			add(a.PlanError.Time, source, a.PlanError.Err)
+		}
+		for _, fs := range a.Filesystems {
+			if e := fs.Error(); e != nil {
+				add(e.Time, source, fmt.Sprintf("%s: %s", fs.Info.Name, e.Err))
+			}
+		}
+	}
+	pruning := func(source string, r *pruner.Report) {
+		if r == nil {
+			return
+		}
+		if r.Error != "" {
+			add(time.Time{}, source, r.Error)
+		}
+		for _, fs := range r.Completed {
+			if fs.LastError != "" {
+				add(time.Time{}, source, fmt.Sprintf("%s: %s", fs.Filesystem, fs.LastError))
+			}
+		}
+	}
+	var snapshotting func(r *snapper.Report)
+	snapshotting = func(r *snapper.Report) {
+		if r == nil {
+			return
+		}
+		if r.Periodic != nil && r.Periodic.Error != "" {
+			add(time.Time{}, "snapshotting", r.Periodic.Error)
+		}
+		if r.Cron != nil {
+			for _, e := range r.Cron.Errors {
+				add(time.Time{}, "snapshotting", e)
+			}
+		}
+		for _, m := range r.Multi {
+			snapshotting(m)
+		}
+	}
+
+	switch s := st.JobSpecific.(type) {
+	case *job.ActiveSideStatus:
+		replication("replication", s.Replication)
+		pruning("pruning sender", s.PruningSender)
+		pruning("pruning receiver", s.PruningReceiver)
+		snapshotting(s.Snapshotting)
+		dests := make([]string, 0, len(s.Destinations))
+		for d := range s.Destinations {
+			dests = append(dests, d)
+		}
+		sort.Strings(dests)
+		for _, d := range dests {
+			replication(fmt.Sprintf("replication to %s", d), s.Destinations[d].Replication)
+			pruning(fmt.Sprintf("pruning %s", d), s.Destinations[d].PruningReceiver)
+		}
+		srcs := make([]string, 0, len(s.Sources))
+		for src := range s.Sources {
+			srcs = append(srcs, src)
+		}
+		sort.Strings(srcs)
+		for _, src := range srcs {
+			replication(fmt.Sprintf("replication from %s", src), s.Sources[src].Replication)
+			pruning(fmt.Sprintf("pruning sender of %s", src), s.Sources[src].PruningSender)
+			pruning(fmt.Sprintf("pruning receiver of %s", src), s.Sources[src].PruningReceiver)
+		}
+	case *job.SnapJobStatus:
+		pruning("pruning", s.Pruning)
+		snapshotting(s.Snapshotting)
+	case *job.PoolMonitorStatus:
+		for _, p := range s.Problems() {
+			add(s.LastCollection, "pools", p)
+		}
+	}
+	return errs
+}
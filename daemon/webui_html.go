@@ -0,0 +1,127 @@
+package daemon
+
+// webUIIndexHTML is the page served by webUIJob.
+// It is self-contained so that it works on appliances without internet access.
+const webUIIndexHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>zrepl</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; color: #222; }
+h1 { font-size: 1.4em; }
+h2 { font-size: 1.1em; margin-bottom: 0.3em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { text-align: left; padding: 0.2em 0.8em; border-bottom: 1px solid #ddd; }
+progress { width: 12em; }
+.health { font-weight: bold; padding: 0.1em 0.5em; border-radius: 0.3em; color: #fff; }
+.health-ok { background: #2e7d32; }
+.health-running { background: #1565c0; }
+.health-error { background: #c62828; }
+.health-disabled { background: #757575; }
+.health-maintenance { background: #9e9e9e; }
+#updated { color: #757575; font-size: 0.9em; }
+#fetcherror { color: #c62828; }
+</style>
+</head>
+<body>
+<h1>zrepl</h1>
+<p id="updated"></p>
+<p id="fetcherror"></p>
+<div id="jobs"></div>
+<h2>Recent errors</h2>
+<table id="errors"></table>
+<script>
+"use strict";
+
+function el(tag, text, cls) {
+	var e = document.createElement(tag);
+	if (text !== undefined) { e.textContent = text; }
+	if (cls) { e.className = cls; }
+	return e;
+}
+
+function row(table, cells, header) {
+	var tr = el("tr");
+	cells.forEach(function (c) {
+		var td = el(header ? "th" : "td");
+		if (c instanceof Node) { td.appendChild(c); } else { td.textContent = c; }
+		tr.appendChild(td);
+	});
+	table.appendChild(tr);
+}
+
+function bytes(n) {
+	var units = ["B", "KiB", "MiB", "GiB", "TiB"], i = 0;
+	while (n >= 1024 && i < units.length - 1) { n /= 1024; i++; }
+	return n.toFixed(i === 0 ? 0 : 1) + " " + units[i];
+}
+
+function duration(ms) {
+	var s = Math.max(0, Math.round(ms / 1000));
+	if (s < 120) { return s + "s"; }
+	if (s < 7200) { return Math.round(s / 60) + "m"; }
+	if (s < 172800) { return Math.round(s / 3600) + "h"; }
+	return Math.round(s / 86400) + "d";
+}
+
+function render(st) {
+	var now = new Date(st.Time);
+	document.getElementById("updated").textContent = "updated " + now.toLocaleString();
+	var jobs = document.getElementById("jobs");
+	jobs.textContent = "";
+	st.Jobs.forEach(function (j) {
+		var h = el("h2", j.Name + " (" + j.Type + ") ");
+		h.appendChild(el("span", j.Health, "health health-" + j.Health));
+		jobs.appendChild(h);
+		if (!j.Filesystems) { return; }
+		var t = el("table");
+		row(t, ["Filesystem", "State", "Progress", "Replication lag"], true);
+		j.Filesystems.forEach(function (fs) {
+			var p = el("progress");
+			p.max = fs.BytesExpected > 0 ? fs.BytesExpected : 1;
+			p.value = fs.BytesExpected > 0 ? fs.BytesReplicated : (fs.State === "done" ? 1 : 0);
+			var progress = el("span");
+			progress.appendChild(p);
+			progress.appendChild(document.createTextNode(" " + bytes(fs.BytesReplicated) + " / " + bytes(fs.BytesExpected)));
+			var lag = fs.LastReplicated ? duration(now - new Date(fs.LastReplicated)) : "unknown";
+			row(t, [fs.Name, fs.State, progress, lag]);
+		});
+		jobs.appendChild(t);
+	});
+	var errors = document.getElementById("errors");
+	errors.textContent = "";
+	if (st.Errors.length === 0) {
+		row(errors, ["none"]);
+		return;
+	}
+	row(errors, ["Time", "Job", "Source", "Error"], true);
+	st.Errors.forEach(function (e) {
+		row(errors, [new Date(e.Time).toLocaleString(), e.Job, e.Source, e.Error]);
+	});
+}
+
+function update() {
+	var req = new XMLHttpRequest();
+	req.onload = function () {
+		if (req.status !== 200) {
+			document.getElementById("fetcherror").textContent = "cannot fetch status: HTTP " + req.status;
+			return;
+		}
+		document.getElementById("fetcherror").textContent = "";
+		render(JSON.parse(req.responseText));
+	};
+	req.onerror = function () {
+		document.getElementById("fetcherror").textContent = "cannot fetch status: daemon unreachable";
+	};
+	req.open("GET", "status.json");
+	req.send();
+}
+
+update();
+setInterval(update, 2000);
+</script>
+</body>
+</html>
+`
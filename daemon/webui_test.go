@@ -0,0 +1,81 @@
+package daemon
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/replication/report"
+)
+
+func TestWebUITracker(t *testing.T) {
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsDone := &report.FilesystemReport{
+		Info:  &report.FilesystemInfo{Name: "pool/a"},
+		State: report.FilesystemDone,
+		Steps: []*report.StepReport{{Info: &report.StepInfo{BytesExpected: 100, BytesReplicated: 100}}},
+	}
+	fsErr := &report.FilesystemReport{
+		Info:      &report.FilesystemInfo{Name: "pool/b"},
+		State:     report.FilesystemSteppingErrored,
+		StepError: report.NewTimedError("connection reset", t0.Add(time.Minute)),
+	}
+	statuses := func(finishAt time.Time) map[string]*job.Status {
+		return map[string]*job.Status{
+			"push": {Type: job.TypePush, JobSpecific: &job.ActiveSideStatus{
+				Replication: &report.Report{StartAt: t0, FinishAt: finishAt, Attempts: []*report.AttemptReport{{
+					StartAt:     t0,
+					FinishAt:    finishAt,
+					Filesystems: []*report.FilesystemReport{fsErr, fsDone},
+				}}},
+				PruningSender: &pruner.Report{Error: "pruning failed"},
+			}},
+			"snap":         {Type: job.TypeSnap, JobSpecific: &job.SnapJobStatus{}, Disabled: true},
+			jobNameControl: {Type: job.TypeInternal},
+		}
+	}
+
+	tr := newWebUITracker()
+	// the attempt is still running
+	tr.observe(statuses(time.Time{}), t0.Add(2*time.Minute))
+	tr.observe(statuses(time.Time{}), t0.Add(3*time.Minute))
+	v := tr.view(statuses(time.Time{}), t0.Add(3*time.Minute))
+
+	require.Len(t, v.Jobs, 2, "internal jobs are not shown")
+	push, snap := v.Jobs[0], v.Jobs[1]
+	assert.Equal(t, webUIHealthError, push.Health)
+	assert.Equal(t, webUIHealthDisabled, snap.Health)
+	require.Len(t, push.Filesystems, 2)
+	assert.Equal(t, "pool/a", push.Filesystems[0].Name)
+	assert.Equal(t, uint64(100), push.Filesystems[0].BytesReplicated)
+	require.NotNil(t, push.Filesystems[0].LastReplicated)
+	assert.Equal(t, t0.Add(3*time.Minute), *push.Filesystems[0].LastReplicated)
+	assert.Nil(t, push.Filesystems[1].LastReplicated)
+
+	// errors are deduplicated across observations, untimed errors keep the time they were first observed
+	require.Len(t, v.Errors, 2)
+	assert.Equal(t, "pruning sender", v.Errors[0].Source)
+	assert.Equal(t, t0.Add(2*time.Minute), v.Errors[0].Time)
+	assert.Equal(t, "replication", v.Errors[1].Source)
+	assert.Equal(t, "pool/b: connection reset", v.Errors[1].Error)
+	assert.Equal(t, t0.Add(time.Minute), v.Errors[1].Time)
+
+	// the finished attempt's timestamp does not move the last replication back
+	tr.observe(statuses(t0.Add(150*time.Second)), t0.Add(4*time.Minute))
+	v = tr.view(statuses(t0.Add(150*time.Second)), t0.Add(4*time.Minute))
+	assert.Equal(t, t0.Add(3*time.Minute), *v.Jobs[0].Filesystems[0].LastReplicated)
+}
+
+func TestWebUITrackerMaxErrors(t *testing.T) {
+	tr := newWebUITracker()
+	for i := 0; i < webUIMaxErrors+10; i++ {
+		tr.addError(webUIError{Job: "j", Error: fmt.Sprintf("error %d", i)}, time.Now())
+	}
+	assert.Len(t, tr.errors, webUIMaxErrors)
+	assert.Len(t, tr.seenErrors, webUIMaxErrors)
+}
@@ -7,7 +7,10 @@ import (
 	"fmt"
 	"io"
 	"path"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/kr/pretty"
 	"github.com/pkg/errors"
@@ -38,6 +41,15 @@ type SenderConfig struct {
 	SendSaved            bool
 
 	BandwidthLimit bandwidthlimit.Config
+
+	// If true, the `from` version of incremental steps with replication guarantee
+	// `resumability` is protected by a bookmark instead of a step hold,
+	// so that it does not block pruning on the sending side.
+	StepHoldFromBookmark bool
+
+	// If false, the byte counters of this sender are not labelled with the filesystem
+	// (see metricsFilesystemLabel).
+	PerFilesystemMetrics bool
 }
 
 func (c *SenderConfig) Validate() error {
@@ -100,16 +112,17 @@ func (s *Sender) filterCheckFS(fs string) (*zfs.DatasetPath, error) {
 func (s *Sender) ListFilesystems(ctx context.Context, r *pdu.ListFilesystemReq) (*pdu.ListFilesystemRes, error) {
 	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
 
-	fss, err := zfs.ZFSListMapping(ctx, s.FSFilter)
+	fss, err := zfs.ZFSListMappingProperties(ctx, s.FSFilter, []string{"usedbysnapshots"})
 	if err != nil {
 		return nil, err
 	}
 	rfss := make([]*pdu.Filesystem, len(fss))
 	for i := range fss {
 		rfss[i] = &pdu.Filesystem{
-			Path: fss[i].ToString(),
+			Path: fss[i].Path.ToString(),
 			// ResumeToken does not make sense from Sender
-			IsPlaceholder: false, // sender FSs are never placeholders
+			IsPlaceholder:   false, // sender FSs are never placeholders
+			UsedBySnapshots: parseUsedBySnapshots(fss[i].Fields[0]),
 		}
 	}
 	res := &pdu.ListFilesystemRes{Filesystems: rfss}
@@ -123,6 +136,10 @@ func (s *Sender) ListFilesystemVersions(ctx context.Context, r *pdu.ListFilesyst
 	if err != nil {
 		return nil, err
 	}
+	return doListFilesystemVersions(ctx, lp, r.GetWithHolds())
+}
+
+func doListFilesystemVersions(ctx context.Context, lp *zfs.DatasetPath, withHolds bool) (*pdu.ListFilesystemVersionsRes, error) {
 	fsvs, err := zfs.ZFSListFilesystemVersions(ctx, lp, zfs.ListFilesystemVersionsOptions{})
 	if err != nil {
 		return nil, err
@@ -130,10 +147,23 @@ func (s *Sender) ListFilesystemVersions(ctx context.Context, r *pdu.ListFilesyst
 	rfsvs := make([]*pdu.FilesystemVersion, len(fsvs))
 	for i := range fsvs {
 		rfsvs[i] = pdu.FilesystemVersionFromZFS(&fsvs[i])
+		if !withHolds || !fsvs[i].IsSnapshot() || !fsvs[i].UserRefs.Valid || fsvs[i].UserRefs.Value == 0 {
+			continue
+		}
+		if rfsvs[i].Holds, err = zfs.ZFSHolds(ctx, lp.ToString(), fsvs[i].Name); err != nil {
+			return nil, errors.Wrapf(err, "cannot list holds of %s", fsvs[i].ToAbsPath(lp))
+		}
 	}
-	res := &pdu.ListFilesystemVersionsRes{Versions: rfsvs}
-	return res, nil
+	return &pdu.ListFilesystemVersionsRes{Versions: rfsvs}, nil
+}
 
+// parseUsedBySnapshots parses the usedbysnapshots property for pdu.Filesystem, 0 if unknown.
+func parseUsedBySnapshots(prop string) uint64 {
+	v, err := strconv.ParseUint(prop, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return v
 }
 
 func uncheckedSendArgsFromPDU(fsv *pdu.FilesystemVersion) *zfs.ZFSSendArgVersion {
@@ -208,6 +238,10 @@ func (s *Sender) Send(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, io.Rea
 		return nil, nil, err
 	}
 	replicationGuaranteeStrategy := replicationGuaranteeOptions.Strategy(sendArgs.From != nil)
+	if g, ok := replicationGuaranteeStrategy.(ReplicationGuaranteeResumability); ok && s.config.StepHoldFromBookmark {
+		g.FromBookmark = true
+		replicationGuaranteeStrategy = g
+	}
 	liveAbs, err := replicationGuaranteeStrategy.SenderPreSend(ctx, s.jobId, &sendArgs)
 	if err != nil {
 		return nil, nil, err
@@ -304,16 +338,28 @@ func (s *Sender) Send(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, io.Rea
 		abstractionsCacheSingleton.TryBatchDestroy(ctx, s.jobId, sendArgs.FS, destroyTypes, keep, check)
 	}()
 
+	concurrencyGuard, err := acquireSendRecvConcurrency(ctx, false, s.jobId)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot acquire zfs send concurrency slot")
+	}
+
 	var sendStream io.ReadCloser
 	sendStream, err = zfs.ZFSSend(ctx, sendArgs)
 	if err != nil {
+		concurrencyGuard.Release()
 		// it's ok to not destroy the abstractions we just created here, a new send attempt will take care of it
 		return nil, nil, errors.Wrap(err, "zfs send failed")
 	}
+	sendStream = &sendRecvConcurrencyReleasingReadCloser{sendStream, concurrencyGuard}
 
 	// apply rate limit
 	sendStream = s.bwLimit.WrapReadCloser(sendStream)
 
+	sendStream = &metricsCountingReadCloser{
+		ReadCloser: sendStream,
+		counter:    replicationMetrics.bytesSent.WithLabelValues(s.jobId.String(), metricsFilesystemLabel(s.config.PerFilesystemMetrics, sendArgs.FS)),
+	}
+
 	res := &pdu.SendRes{
 		ExpectedSize:    0,
 		UsedResumeToken: r.ResumeToken != "",
@@ -406,7 +452,17 @@ func (p *Sender) DestroySnapshots(ctx context.Context, req *pdu.DestroySnapshots
 	if err != nil {
 		return nil, err
 	}
-	return doDestroySnapshots(ctx, dp, req.Snapshots)
+	return doDestroySnapshots(ctx, dp, req.Snapshots, req.GetRanged())
+}
+
+func (p *Sender) SetPruneMarks(ctx context.Context, req *pdu.SetPruneMarksReq) (*pdu.SetPruneMarksRes, error) {
+	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
+
+	dp, err := p.filterCheckFS(req.Filesystem)
+	if err != nil {
+		return nil, err
+	}
+	return doSetPruneMarks(ctx, dp, req), nil
 }
 
 func (p *Sender) Ping(ctx context.Context, req *pdu.PingReq) (*pdu.PingRes, error) {
@@ -479,6 +535,49 @@ type ReceiverConfig struct {
 	BandwidthLimit bandwidthlimit.Config
 
 	PlaceholderEncryption PlaceholderCreationEncryptionProperty
+	// If true, plaintext send streams are received such that the received filesystems inherit encryption
+	// from their parent, e.g., an encrypted RootWithoutClientComponent (see receive_EncryptOnReceive).
+	// Placeholders are created with PlaceholderCreationEncryptionPropertyInherit unless PlaceholderEncryption is specified.
+	EncryptOnReceive bool
+	// Set on the placeholders that the receiver creates, nil means zfs.PlaceholderDefaultProperties.
+	PlaceholderProperties map[zfsprop.Property]string
+
+	// Space limits for the client root datasets, keyed by client identity.
+	// Only effective if AppendClientIdentity is true.
+	ClientQuotas map[string]ClientQuota
+	// Applies to clients that have no entry in ClientQuotas, may be nil.
+	DefaultClientQuota *ClientQuota
+
+	// Concurrency, bandwidth and time limits for the receives of a client, keyed by client identity.
+	// Only effective if AppendClientIdentity is true.
+	ClientLimits map[string]ClientLimits
+	// Applies to clients that have no entry in ClientLimits, may be nil.
+	DefaultClientLimits *ClientLimits
+
+	// Ordered rules that map the sender's filesystems to filesystems below RootWithoutClientComponent
+	// instead of the client root. Only effective if AppendClientIdentity is true.
+	Mappings []FilesystemMapping
+
+	// If not empty, received filesystems are kept mounted read-only below this absolute path,
+	// at their path relative to RootWithoutClientComponent (see browseMountpoint).
+	BrowseMountPrefix string
+
+	// If true, the receiver refuses the requests that would destroy or roll back received data,
+	// i.e., DestroySnapshots, SetPruneMarks and forced receives into placeholders that have snapshots.
+	AppendOnly bool
+
+	// When Receive uses `zfs recv -F`, which rolls back the receiving filesystem (see ForceReceivePolicy).
+	// Receive logs an audit record for each forced receive.
+	ForceReceive ForceReceivePolicy
+
+	// Receive refuses to receive if the space available to the (client) root
+	// filesystem, less the expected size of the send stream, is below MinFreeSpace bytes.
+	// 0 disables the check.
+	MinFreeSpace uint64
+
+	// If false, the byte counters of this receiver are not labelled with the filesystem
+	// (see metricsFilesystemLabel).
+	PerFilesystemMetrics bool
 }
 
 //go:generate enumer -type=PlaceholderCreationEncryptionProperty -transform=kebab -trimprefix=PlaceholderCreationEncryptionProperty
@@ -503,6 +602,40 @@ func (c *ReceiverConfig) copyIn() {
 		pOverride[key] = value
 	}
 	c.OverrideProperties = pOverride
+
+	if c.PlaceholderProperties != nil {
+		pPlaceholder := make(map[zfsprop.Property]string, len(c.PlaceholderProperties))
+		for key, value := range c.PlaceholderProperties {
+			pPlaceholder[key] = value
+		}
+		c.PlaceholderProperties = pPlaceholder
+	}
+
+	clientQuotas := make(map[string]ClientQuota, len(c.ClientQuotas))
+	for clientIdentity, q := range c.ClientQuotas {
+		clientQuotas[clientIdentity] = q
+	}
+	c.ClientQuotas = clientQuotas
+
+	if c.DefaultClientQuota != nil {
+		q := *c.DefaultClientQuota
+		c.DefaultClientQuota = &q
+	}
+
+	clientLimits := make(map[string]ClientLimits, len(c.ClientLimits))
+	for clientIdentity, l := range c.ClientLimits {
+		clientLimits[clientIdentity] = l
+	}
+	c.ClientLimits = clientLimits
+
+	if c.DefaultClientLimits != nil {
+		l := *c.DefaultClientLimits
+		c.DefaultClientLimits = &l
+	}
+
+	mappings := make([]FilesystemMapping, len(c.Mappings))
+	copy(mappings, c.Mappings)
+	c.Mappings = mappings
 }
 
 func (c *ReceiverConfig) Validate() error {
@@ -522,6 +655,13 @@ func (c *ReceiverConfig) Validate() error {
 		}
 	}
 
+	// zfs recv refuses to both -x and -o the same property
+	for _, prop := range c.InheritProperties {
+		if _, ok := c.OverrideProperties[prop]; ok {
+			return errors.Errorf("property %q must not be both inherited and overridden", prop)
+		}
+	}
+
 	if c.RootWithoutClientComponent.Length() <= 0 {
 		return errors.New("RootWithoutClientComponent must not be an empty dataset path")
 	}
@@ -533,6 +673,46 @@ func (c *ReceiverConfig) Validate() error {
 	if !c.PlaceholderEncryption.IsAPlaceholderCreationEncryptionProperty() {
 		return errors.Errorf("`PlaceholderEncryption` field is invalid")
 	}
+	for prop := range c.PlaceholderProperties {
+		if err := prop.Validate(); err != nil {
+			return errors.Wrapf(err, "placeholder property %q", prop)
+		}
+		switch prop {
+		case "encryption":
+			return errors.New("placeholder property \"encryption\" is controlled by `PlaceholderEncryption`")
+		case zfsprop.Property(zfs.PlaceholderPropertyName):
+			return errors.Errorf("placeholder property %q is set by zrepl", prop)
+		}
+	}
+
+	if !c.AppendClientIdentity && (len(c.ClientQuotas) > 0 || c.DefaultClientQuota != nil) {
+		return errors.New("`ClientQuotas` and `DefaultClientQuota` require `AppendClientIdentity`")
+	}
+	for clientIdentity := range c.ClientQuotas {
+		if _, err := clientRoot(c.RootWithoutClientComponent, clientIdentity); err != nil {
+			return errors.Wrapf(err, "`ClientQuotas`: invalid client identity %q", clientIdentity)
+		}
+	}
+
+	if err := c.validateClientLimits(); err != nil {
+		return err
+	}
+
+	if err := c.validateMappings(); err != nil {
+		return err
+	}
+
+	if err := c.validateBrowseMounts(); err != nil {
+		return err
+	}
+
+	if err := c.validateForceReceive(); err != nil {
+		return err
+	}
+
+	if err := c.validateEncryptOnReceive(); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -547,6 +727,9 @@ type Receiver struct {
 
 	recvParentCreationMtx *chainlock.L
 
+	clientLimitersMtx sync.Mutex
+	clientLimiters    map[string]*clientLimiter
+
 	Test_OverrideClientIdentityFunc func() string // for use by platformtest
 }
 
@@ -559,6 +742,7 @@ func NewReceiver(config ReceiverConfig) *Receiver {
 		conf:                  config,
 		recvParentCreationMtx: chainlock.New(),
 		bwLimit:               bandwidthlimit.WrapperFromConfig(config.BandwidthLimit),
+		clientLimiters:        make(map[string]*clientLimiter),
 	}
 }
 
@@ -585,16 +769,7 @@ func (s *Receiver) clientRootFromCtx(ctx context.Context) *zfs.DatasetPath {
 		return s.conf.RootWithoutClientComponent.Copy()
 	}
 
-	var clientIdentity string
-	if s.Test_OverrideClientIdentityFunc != nil {
-		clientIdentity = s.Test_OverrideClientIdentityFunc()
-	} else {
-		var ok bool
-		clientIdentity, ok = ctx.Value(ClientIdentityKey).(string) // no shadow
-		if !ok {
-			panic("ClientIdentityKey context value must be set")
-		}
-	}
+	clientIdentity := s.clientIdentityFromCtx(ctx)
 
 	clientRoot, err := clientRoot(s.conf.RootWithoutClientComponent, clientIdentity)
 	if err != nil {
@@ -645,14 +820,28 @@ func (s *Receiver) ListFilesystems(ctx context.Context, req *pdu.ListFilesystemR
 	}
 
 	root := s.clientRootFromCtx(ctx)
-	filtered, err := zfs.ZFSListMapping(ctx, subroot{root})
+	listRoot := root
+	if len(s.conf.Mappings) > 0 {
+		// mapped filesystems can be anywhere below root_fs
+		listRoot = s.conf.RootWithoutClientComponent
+	}
+	filtered, err := zfs.ZFSListMappingProperties(ctx, subroot{listRoot}, []string{"usedbysnapshots"})
 	if err != nil {
 		return nil, err
 	}
 	// present filesystem without the root_fs prefix
 	fss := make([]*pdu.Filesystem, 0, len(filtered))
-	for _, a := range filtered {
+	for _, f := range filtered {
+		a := f.Path
 		l := getLogger(ctx).WithField("fs", a)
+		senderPath, ok, err := s.listFilesystems_SenderPath(ctx, root, a)
+		if err != nil {
+			l.WithError(err).Error("cannot map filesystem to sender filesystem")
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
 		ph, err := zfs.ZFSGetFilesystemPlaceholderState(ctx, a)
 		if err != nil {
 			l.WithError(err).Error("error getting placeholder state")
@@ -671,12 +860,11 @@ func (s *Receiver) ListFilesystems(ctx context.Context, req *pdu.ListFilesystemR
 		}
 		l.WithField("receive_resume_token", token).Debug("receive resume token")
 
-		a.TrimPrefix(root)
-
 		fs := &pdu.Filesystem{
-			Path:          a.ToString(),
-			IsPlaceholder: ph.IsPlaceholder,
-			ResumeToken:   token,
+			Path:            senderPath,
+			IsPlaceholder:   ph.IsPlaceholder,
+			ResumeToken:     token,
+			UsedBySnapshots: parseUsedBySnapshots(f.Fields[0]),
 		}
 		fss = append(fss, fs)
 	}
@@ -690,24 +878,11 @@ func (s *Receiver) ListFilesystems(ctx context.Context, req *pdu.ListFilesystemR
 func (s *Receiver) ListFilesystemVersions(ctx context.Context, req *pdu.ListFilesystemVersionsReq) (*pdu.ListFilesystemVersionsRes, error) {
 	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
 
-	root := s.clientRootFromCtx(ctx)
-	lp, err := subroot{root}.MapToLocal(req.GetFilesystem())
-	if err != nil {
-		return nil, err
-	}
-	// TODO share following code with sender
-
-	fsvs, err := zfs.ZFSListFilesystemVersions(ctx, lp, zfs.ListFilesystemVersionsOptions{})
+	lp, err := s.mapToLocal(ctx, req.GetFilesystem())
 	if err != nil {
 		return nil, err
 	}
-
-	rfsvs := make([]*pdu.FilesystemVersion, len(fsvs))
-	for i := range fsvs {
-		rfsvs[i] = pdu.FilesystemVersionFromZFS(&fsvs[i])
-	}
-
-	return &pdu.ListFilesystemVersionsRes{Versions: rfsvs}, nil
+	return doListFilesystemVersions(ctx, lp, req.GetWithHolds())
 }
 
 func (s *Receiver) Ping(ctx context.Context, req *pdu.PingReq) (*pdu.PingRes, error) {
@@ -744,6 +919,17 @@ func (s *Receiver) SendDry(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, e
 	return nil, fmt.Errorf("receiver does not implement SendDry()")
 }
 
+func (s *Receiver) placeholderProperties() map[string]string {
+	if s.conf.PlaceholderProperties == nil {
+		return zfs.PlaceholderDefaultProperties
+	}
+	props := make(map[string]string, len(s.conf.PlaceholderProperties))
+	for prop, value := range s.conf.PlaceholderProperties {
+		props[string(prop)] = value
+	}
+	return props
+}
+
 func (s *Receiver) receive_GetPlaceholderCreationEncryptionValue(client_root, path *zfs.DatasetPath) (zfs.FilesystemPlaceholderCreateEncryptionValue, error) {
 	if !s.conf.PlaceholderEncryption.IsAPlaceholderCreationEncryptionProperty() {
 		panic(s.conf.PlaceholderEncryption)
@@ -761,6 +947,10 @@ func (s *Receiver) receive_GetPlaceholderCreationEncryptionValue(client_root, pa
 
 	switch s.conf.PlaceholderEncryption {
 	case PlaceholderCreationEncryptionPropertyUnspecified:
+		if s.conf.EncryptOnReceive {
+			// placeholders with encryption=off would prevent the received filesystems below them from inheriting encryption
+			return zfs.FilesystemPlaceholderCreateEncryptionInherit, nil
+		}
 		return 0, fmt.Errorf("placeholder filesystem encryption handling is unspecified in receiver config")
 	case PlaceholderCreationEncryptionPropertyInherit:
 		return zfs.FilesystemPlaceholderCreateEncryptionInherit, nil
@@ -778,7 +968,7 @@ func (s *Receiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.
 	defer receive.Close()
 
 	root := s.clientRootFromCtx(ctx)
-	lp, err := subroot{root}.MapToLocal(req.Filesystem)
+	lp, err := s.mapToLocal(ctx, req.Filesystem)
 	if err != nil {
 		return nil, errors.Wrap(err, "`Filesystem` invalid")
 	}
@@ -791,6 +981,14 @@ func (s *Receiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.
 		return nil, errors.New("`To` must be a snapshot")
 	}
 
+	var clientLimiter *clientLimiter // nil if the client has no limits
+	if s.conf.AppendClientIdentity {
+		clientLimiter = s.clientLimiter(s.clientIdentityFromCtx(ctx))
+	}
+	if err := clientLimiter.checkReceiveWindow(ctx, time.Now()); err != nil {
+		return nil, err
+	}
+
 	// create placeholder parent filesystems as appropriate
 	//
 	// Manipulating the ZFS dataset hierarchy must happen exclusively.
@@ -849,7 +1047,7 @@ func (s *Receiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.
 				l := l.WithField("encryption", placeholderEncryption)
 
 				l.Debug("creating placeholder filesystem")
-				err = zfs.ZFSCreatePlaceholderFilesystem(ctx, v.Path, v.Parent.Path, placeholderEncryption)
+				err = zfs.ZFSCreatePlaceholderFilesystem(ctx, v.Path, v.Parent.Path, placeholderEncryption, s.placeholderProperties())
 				if err != nil {
 					l.WithError(err).Error("cannot create placeholder filesystem") // logger already contains path
 					visitErr = errors.Wrapf(err, "cannot create placeholder filesystem %s", v.Path.ToString())
@@ -868,10 +1066,24 @@ func (s *Receiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.
 		return nil, visitErr
 	}
 
+	// client quotas apply to the client root, which does not contain filesystems that Mappings map elsewhere
+	spaceRoot := s.conf.RootWithoutClientComponent
+	if lp.HasPrefix(root) {
+		if err := s.receive_EnforceClientQuota(ctx, root, req.GetExpectedSize()); err != nil {
+			return nil, err
+		}
+		spaceRoot = root
+	}
+
+	if err := s.receive_EnforceMinFreeSpace(ctx, spaceRoot, req.GetExpectedSize()); err != nil {
+		return nil, err
+	}
+
 	log := getLogger(ctx).WithField("proto_fs", req.GetFilesystem()).WithField("local_fs", lp.ToString())
 
 	// determine whether we need to rollback the filesystem / change its placeholder state
 	var clearPlaceholderProperty bool
+	var force *forceReceive // non-nil if the receive rolls back lp
 	var recvOpts zfs.RecvOptions
 	ph, err := zfs.ZFSGetFilesystemPlaceholderState(ctx, lp)
 	if err != nil {
@@ -881,19 +1093,26 @@ func (s *Receiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.
 
 	recvOpts.InheritProperties = s.conf.InheritProperties
 	recvOpts.OverrideProperties = s.conf.OverrideProperties
+	if len(s.conf.Mappings) > 0 {
+		recvOpts.OverrideProperties = make(map[zfsprop.Property]string, len(s.conf.OverrideProperties)+2)
+		for p, v := range s.conf.OverrideProperties {
+			recvOpts.OverrideProperties[p] = v
+		}
+		recvOpts.OverrideProperties[MappedFromPropertyName] = req.GetFilesystem()
+		recvOpts.OverrideProperties[MappedFromClientPropertyName] = s.clientIdentityFromCtx(ctx)
+	}
 
 	if ph.FSExists && ph.IsPlaceholder {
+		if err := s.receive_EnforceAppendOnly(ctx, lp); err != nil {
+			return nil, err
+		}
+		if force, err = s.receive_ForcePlaceholder(ctx, lp); err != nil {
+			return nil, err
+		}
 		recvOpts.RollbackAndForceRecv = true
 		clearPlaceholderProperty = true
 	}
 
-	if clearPlaceholderProperty {
-		log.Info("clearing placeholder property")
-		if err := zfs.ZFSSetPlaceholder(ctx, lp, false); err != nil {
-			return nil, fmt.Errorf("cannot clear placeholder property for forced receive: %s", err)
-		}
-	}
-
 	if req.ClearResumeToken && ph.FSExists {
 		log.Info("clearing resume token")
 		if err := zfs.ZFSRecvClearResumeToken(ctx, lp.ToString()); err != nil {
@@ -901,6 +1120,10 @@ func (s *Receiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.
 		}
 	}
 
+	// receive_BrowseMount mounts the filesystem after the receive,
+	// a failing mount must not fail the receive
+	recvOpts.NoMount = s.conf.BrowseMountPrefix != ""
+
 	recvOpts.SavePartialRecvState, err = zfs.ResumeRecvSupported(ctx, lp)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot determine whether we can use resumable send & recv")
@@ -908,6 +1131,12 @@ func (s *Receiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.
 
 	// apply rate limit
 	receive = s.bwLimit.WrapReadCloser(receive)
+	receive = clientLimiter.wrapReadCloser(receive)
+
+	receive = &metricsCountingReadCloser{
+		ReadCloser: receive,
+		counter:    replicationMetrics.bytesReceived.WithLabelValues(s.conf.JobID.String(), metricsFilesystemLabel(s.conf.PerFilesystemMetrics, lp.ToString())),
+	}
 
 	var peek bytes.Buffer
 	var MaxPeek = envconst.Int64("ZREPL_ENDPOINT_RECV_PEEK_SIZE", 1<<20)
@@ -920,8 +1149,43 @@ func (s *Receiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.
 		panic(peek.Len())
 	}
 
+	if ph.FSExists && !ph.IsPlaceholder {
+		if force, err = s.receive_ForceIncremental(ctx, lp, peek.Bytes()); err != nil {
+			return nil, err
+		}
+		recvOpts.Force = force != nil
+	}
+
+	if err := s.receive_EncryptOnReceive(ctx, lp, ph, peek.Bytes(), &recvOpts); err != nil {
+		return nil, err
+	}
+
+	// cleared after the checks that may refuse the receive so that a refused receive keeps the placeholder
+	if clearPlaceholderProperty {
+		log.Info("clearing placeholder property")
+		if err := zfs.ZFSSetPlaceholder(ctx, lp, false); err != nil {
+			return nil, fmt.Errorf("cannot clear placeholder property for forced receive: %s", err)
+		}
+	}
+
 	log.WithField("opts", fmt.Sprintf("%#v", recvOpts)).Debug("start receive command")
 
+	clientConcurrencyGuard, err := clientLimiter.acquireConcurrency(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot acquire client receive concurrency slot")
+	}
+	defer clientConcurrencyGuard.Release()
+
+	concurrencyGuard, err := acquireSendRecvConcurrency(ctx, true, s.conf.JobID)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot acquire zfs recv concurrency slot")
+	}
+	defer concurrencyGuard.Release()
+
+	if force != nil {
+		s.receive_AuditForceReceive(ctx, lp, force)
+	}
+
 	snapFullPath := to.FullPath(lp.ToString())
 	if err := zfs.ZFSRecv(ctx, lp.ToString(), to, chainedio.NewChainedReader(&peek, receive), recvOpts); err != nil {
 
@@ -1004,6 +1268,8 @@ func (s *Receiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.
 		return nil, errors.Wrap(err, msg)
 	}
 
+	s.receive_BrowseMount(ctx, lp)
+
 	replicationGuaranteeOptions, err := replicationGuaranteeOptionsFromPDU(req.GetReplicationConfig().Protection)
 	if err != nil {
 		return nil, err
@@ -1043,12 +1309,27 @@ func (s *Receiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.
 func (s *Receiver) DestroySnapshots(ctx context.Context, req *pdu.DestroySnapshotsReq) (*pdu.DestroySnapshotsRes, error) {
 	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
 
-	root := s.clientRootFromCtx(ctx)
-	lp, err := subroot{root}.MapToLocal(req.Filesystem)
+	lp, err := s.mapToLocal(ctx, req.Filesystem)
 	if err != nil {
 		return nil, err
 	}
-	return doDestroySnapshots(ctx, lp, req.Snapshots)
+	if err := s.refuseIfAppendOnly(ctx, lp, "destroy snapshots"); err != nil {
+		return nil, err
+	}
+	return doDestroySnapshots(ctx, lp, req.Snapshots, req.GetRanged())
+}
+
+func (s *Receiver) SetPruneMarks(ctx context.Context, req *pdu.SetPruneMarksReq) (*pdu.SetPruneMarksRes, error) {
+	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
+
+	lp, err := s.mapToLocal(ctx, req.Filesystem)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.refuseIfAppendOnly(ctx, lp, "mark snapshots for pruning"); err != nil {
+		return nil, err
+	}
+	return doSetPruneMarks(ctx, lp, req), nil
 }
 
 func (p *Receiver) SendCompleted(ctx context.Context, _ *pdu.SendCompletedReq) (*pdu.SendCompletedRes, error) {
@@ -1057,26 +1338,40 @@ func (p *Receiver) SendCompleted(ctx context.Context, _ *pdu.SendCompletedReq) (
 	return &pdu.SendCompletedRes{}, nil
 }
 
-func doDestroySnapshots(ctx context.Context, lp *zfs.DatasetPath, snaps []*pdu.FilesystemVersion) (*pdu.DestroySnapshotsRes, error) {
-	reqs := make([]*zfs.DestroySnapOp, len(snaps))
+// Destroys snaps, which may also contain bookmarks.
+// Replication cursor bookmarks are not destroyed, their results contain an error.
+func doDestroySnapshots(ctx context.Context, lp *zfs.DatasetPath, snaps []*pdu.FilesystemVersion, ranged bool) (*pdu.DestroySnapshotsRes, error) {
+	reqs := make([]*zfs.DestroySnapOp, 0, len(snaps))
 	ress := make([]*pdu.DestroySnapshotRes, len(snaps))
 	errs := make([]error, len(snaps))
+	var bookmarks []int
 	for i, fsv := range snaps {
-		if fsv.Type != pdu.FilesystemVersion_Snapshot {
-			return nil, fmt.Errorf("version %q is not a snapshot", fsv.Name)
-		}
 		ress[i] = &pdu.DestroySnapshotRes{
 			Snapshot: fsv,
 			// Error set after batch operation
 		}
-		reqs[i] = &zfs.DestroySnapOp{
-			Filesystem: lp.ToString(),
-			Name:       fsv.Name,
-			ErrOut:     &errs[i],
+		switch fsv.Type {
+		case pdu.FilesystemVersion_Snapshot:
+			reqs = append(reqs, &zfs.DestroySnapOp{
+				Filesystem: lp.ToString(),
+				Name:       fsv.Name,
+				ErrOut:     &errs[i],
+			})
+		case pdu.FilesystemVersion_Bookmark:
+			bookmarks = append(bookmarks, i)
+		default:
+			return nil, fmt.Errorf("version %q is neither a snapshot nor a bookmark", fsv.Name)
 		}
 	}
-	zfs.ZFSDestroyFilesystemVersions(ctx, reqs)
-	for i := range reqs {
+	if ranged {
+		zfs.ZFSDestroyFilesystemVersionsRanged(ctx, reqs)
+	} else {
+		zfs.ZFSDestroyFilesystemVersions(ctx, reqs)
+	}
+	for _, i := range bookmarks {
+		errs[i] = destroyPrunableBookmark(ctx, lp, snaps[i])
+	}
+	for i := range ress {
 		if errs[i] != nil {
 			if de, ok := errs[i].(*zfs.DestroySnapshotsError); ok && len(de.Reason) == 1 {
 				ress[i].Error = de.Reason[0]
@@ -1089,3 +1384,37 @@ func doDestroySnapshots(ctx context.Context, lp *zfs.DatasetPath, snaps []*pdu.F
 		Results: ress,
 	}, nil
 }
+
+// Sets zfs.PruneMarkProperty to the current time on req.Mark and clears it on req.Unmark.
+// Errors are reported per snapshot.
+func doSetPruneMarks(ctx context.Context, lp *zfs.DatasetPath, req *pdu.SetPruneMarksReq) *pdu.SetPruneMarksRes {
+	now := time.Now()
+	res := &pdu.SetPruneMarksRes{}
+	do := func(fsvs []*pdu.FilesystemVersion, f func(v *zfs.FilesystemVersion) error) {
+		for _, fsv := range fsvs {
+			r := &pdu.SetPruneMarkRes{Snapshot: fsv}
+			v, err := fsv.ZFSFilesystemVersion()
+			if err == nil {
+				err = f(v)
+			}
+			if err != nil {
+				r.Error = err.Error()
+			}
+			res.Results = append(res.Results, r)
+		}
+	}
+	do(req.GetMark(), func(v *zfs.FilesystemVersion) error { return zfs.ZFSSetPruneMark(ctx, lp, v, now) })
+	do(req.GetUnmark(), func(v *zfs.FilesystemVersion) error { return zfs.ZFSClearPruneMark(ctx, lp, v) })
+	return res
+}
+
+func destroyPrunableBookmark(ctx context.Context, lp *zfs.DatasetPath, fsv *pdu.FilesystemVersion) error {
+	if IsReplicationCursorBookmarkName(fsv.Name) {
+		return fmt.Errorf("replication cursor bookmarks must not be pruned")
+	}
+	v, err := fsv.ZFSFilesystemVersion()
+	if err != nil {
+		return err
+	}
+	return zfs.ZFSDestroyFilesystemVersion(ctx, lp, v)
+}
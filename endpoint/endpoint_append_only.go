@@ -0,0 +1,47 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// AppendOnlyError is returned by the operations of a Receiver with ReceiverConfig.AppendOnly
+// that would destroy or roll back received data.
+type AppendOnlyError struct {
+	Filesystem string
+	Operation  string
+}
+
+func (e *AppendOnlyError) Error() string {
+	return fmt.Sprintf("receiving side is append-only, refusing to %s on %s (prune with a receiver-local job instead)", e.Operation, e.Filesystem)
+}
+
+// Returns an *AppendOnlyError for operation on lp if the receiver is append-only.
+func (s *Receiver) refuseIfAppendOnly(ctx context.Context, lp *zfs.DatasetPath, operation string) error {
+	if !s.conf.AppendOnly {
+		return nil
+	}
+	err := &AppendOnlyError{Filesystem: lp.ToString(), Operation: operation}
+	getLogger(ctx).WithError(err).Error("refusing request")
+	return err
+}
+
+// Refuses to replace the placeholder lp by a forced receive if the receiver is append-only
+// and the forced receive would destroy snapshots of lp. Placeholders without snapshots contain no data.
+func (s *Receiver) receive_EnforceAppendOnly(ctx context.Context, lp *zfs.DatasetPath) error {
+	if !s.conf.AppendOnly {
+		return nil
+	}
+	snaps, err := zfs.ZFSListFilesystemVersions(ctx, lp, zfs.ListFilesystemVersionsOptions{Types: zfs.Snapshots})
+	if err != nil {
+		return errors.Wrap(err, "cannot list snapshots of placeholder")
+	}
+	if len(snaps) > 0 {
+		return s.refuseIfAppendOnly(ctx, lp, fmt.Sprintf("replace placeholder that has %d snapshot(s) by a forced receive", len(snaps)))
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package endpoint
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+	"github.com/zrepl/zrepl/util/bandwidthlimit"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestReceiverAppendOnly(t *testing.T) {
+	root, err := zfs.NewDatasetPath("backup")
+	require.NoError(t, err)
+	r := NewReceiver(ReceiverConfig{
+		JobID:                      MustMakeJobID("sink"),
+		RootWithoutClientComponent: root,
+		PlaceholderEncryption:      PlaceholderCreationEncryptionPropertyInherit,
+		BandwidthLimit:             bandwidthlimit.NoLimitConfig(),
+		AppendOnly:                 true,
+	})
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+	snaps := []*pdu.FilesystemVersion{{Type: pdu.FilesystemVersion_Snapshot, Name: "zrepl_1"}}
+
+	_, err = r.DestroySnapshots(ctx, &pdu.DestroySnapshotsReq{Filesystem: "pool/data", Snapshots: snaps})
+	require.IsType(t, &AppendOnlyError{}, err)
+	assert.Equal(t, "backup/pool/data", err.(*AppendOnlyError).Filesystem)
+
+	_, err = r.SetPruneMarks(ctx, &pdu.SetPruneMarksReq{Filesystem: "pool/data", Mark: snaps})
+	assert.IsType(t, &AppendOnlyError{}, err)
+}
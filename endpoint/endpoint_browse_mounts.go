@@ -0,0 +1,97 @@
+package endpoint
+
+import (
+	"context"
+	"path"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs"
+	zfsprop "github.com/zrepl/zrepl/zfs/property"
+)
+
+// The properties that receive_BrowseMount sets on the received filesystems.
+var browseMountProperties = []zfsprop.Property{"mountpoint", "readonly"}
+
+func (c *ReceiverConfig) validateBrowseMounts() error {
+	if c.BrowseMountPrefix == "" {
+		return nil
+	}
+	if !path.IsAbs(c.BrowseMountPrefix) {
+		return errors.Errorf("`BrowseMountPrefix` must be an absolute path, got %q", c.BrowseMountPrefix)
+	}
+	for _, prop := range browseMountProperties {
+		if _, ok := c.OverrideProperties[prop]; ok {
+			return errors.Errorf("property %q must not be overridden, it is controlled by `BrowseMountPrefix`", prop)
+		}
+		for _, p := range c.InheritProperties {
+			if p == prop {
+				return errors.Errorf("property %q must not be inherited, it is controlled by `BrowseMountPrefix`", prop)
+			}
+		}
+	}
+	return nil
+}
+
+// browseMountpoint returns the mountpoint of the received filesystem lp,
+// e.g. /backups/client/pool/data for prefix /backups, root_fs pool/sink and lp pool/sink/client/pool/data.
+func (s *Receiver) browseMountpoint(lp *zfs.DatasetPath) string {
+	rel := lp.Copy()
+	rel.TrimPrefix(s.conf.RootWithoutClientComponent)
+	return path.Join(s.conf.BrowseMountPrefix, rel.ToString())
+}
+
+// Sets the browse mount properties on the received filesystem lp and mounts it.
+// zfs set moves a filesystem that is already mounted elsewhere, e.g., at the mountpoint it was received with.
+//
+// The data has been received at this point, hence errors are logged but do not fail the receive.
+func (s *Receiver) receive_BrowseMount(ctx context.Context, lp *zfs.DatasetPath) {
+	if s.conf.BrowseMountPrefix == "" {
+		return
+	}
+	log := getLogger(ctx).WithField("local_fs", lp.ToString())
+
+	props, err := zfs.ZFSGet(ctx, lp, []string{"type", "canmount", "mountpoint", "readonly"})
+	if err != nil {
+		log.WithError(err).Warn("cannot get properties for browse mount")
+		return
+	}
+	if props.Get("type") != "filesystem" {
+		return // volumes have no mountpoint
+	}
+
+	mountpoint := s.browseMountpoint(lp)
+	set := make(map[string]string, len(browseMountProperties))
+	if props.Get("mountpoint") != mountpoint {
+		set["mountpoint"] = mountpoint
+	}
+	if props.Get("readonly") != "on" {
+		set["readonly"] = "on"
+	}
+	log = log.WithField("mountpoint", mountpoint)
+	if len(set) > 0 {
+		log.WithField("properties", set).Debug("set browse mount properties")
+		if err := zfs.ZFSSet(ctx, lp, set); err != nil {
+			log.WithError(err).Warn("cannot set browse mount properties")
+			return
+		}
+	}
+
+	if canmount := props.Get("canmount"); canmount != "on" {
+		log.WithField("canmount", canmount).Debug("not mounting filesystem because of its canmount property")
+		return
+	}
+	mounted, err := zfs.ZFSGetMountpoint(ctx, lp.ToString())
+	if err != nil {
+		log.WithError(err).Warn("cannot determine whether filesystem is mounted")
+		return
+	}
+	if mounted.Mounted {
+		return
+	}
+	if err := zfs.ZFSMount(ctx, lp.ToString()); err != nil {
+		log.WithError(err).Warn("cannot mount filesystem for browsing")
+		return
+	}
+	log.Info("mounted filesystem for browsing")
+}
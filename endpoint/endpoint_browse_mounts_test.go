@@ -0,0 +1,40 @@
+package endpoint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/util/bandwidthlimit"
+	"github.com/zrepl/zrepl/zfs"
+	zfsprop "github.com/zrepl/zrepl/zfs/property"
+)
+
+func TestReceiverBrowseMounts(t *testing.T) {
+	root, err := zfs.NewDatasetPath("pool/sink")
+	require.NoError(t, err)
+	c := ReceiverConfig{
+		JobID:                      MustMakeJobID("sink"),
+		RootWithoutClientComponent: root,
+		AppendClientIdentity:       true,
+		PlaceholderEncryption:      PlaceholderCreationEncryptionPropertyInherit,
+		BandwidthLimit:             bandwidthlimit.NoLimitConfig(),
+		BrowseMountPrefix:          "/backups/",
+	}
+	lp, err := zfs.NewDatasetPath("pool/sink/client/pool/data")
+	require.NoError(t, err)
+	assert.Equal(t, "/backups/client/pool/data", NewReceiver(c).browseMountpoint(lp))
+
+	c.BrowseMountPrefix = "backups"
+	assert.Error(t, c.Validate(), "the prefix must be absolute")
+
+	c.BrowseMountPrefix = "/backups"
+	c.InheritProperties = []zfsprop.Property{"mountpoint"}
+	assert.Error(t, c.Validate())
+	c.InheritProperties = nil
+	c.OverrideProperties = map[zfsprop.Property]string{"readonly": "off"}
+	assert.Error(t, c.Validate())
+	c.OverrideProperties = map[zfsprop.Property]string{"canmount": "on"}
+	assert.NoError(t, c.Validate())
+}
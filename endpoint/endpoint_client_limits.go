@@ -0,0 +1,146 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/util/bandwidthlimit"
+	"github.com/zrepl/zrepl/util/semaphore"
+)
+
+// ClientLimits restricts the receives of a client on a Receiver with AppendClientIdentity = true,
+// so that a single client cannot monopolize the receiving side.
+type ClientLimits struct {
+	// maximum number of concurrent receives of the client, 0 means no limit
+	MaxConcurrentReceives int
+	// shared by all receives of the client
+	BandwidthLimit bandwidthlimit.Config
+	// receives may only start during these windows, at any time if nil
+	ReceiveWindows ReceiveWindows
+}
+
+type ReceiveWindows interface {
+	// Contains returns true if t is in a window, and otherwise the start of the next window.
+	Contains(t time.Time) (ok bool, next time.Time)
+}
+
+// ClientReceiveWindowError is returned by Receiver.Receive if the client
+// is not allowed to receive outside of its ClientLimits.ReceiveWindows.
+type ClientReceiveWindowError struct {
+	ClientIdentity string
+	// zero if there is no next window
+	NextWindow time.Time
+}
+
+func (e *ClientReceiveWindowError) Error() string {
+	if e.NextWindow.IsZero() {
+		return fmt.Sprintf("client %q may only receive during its receive windows on receiving side, there is no next window", e.ClientIdentity)
+	}
+	return fmt.Sprintf("client %q may only receive during its receive windows on receiving side, the next window begins at %s",
+		e.ClientIdentity, e.NextWindow.Format(time.RFC3339))
+}
+
+func (c *ReceiverConfig) clientLimits(clientIdentity string) (ClientLimits, bool) {
+	if l, ok := c.ClientLimits[clientIdentity]; ok {
+		return l, true
+	}
+	if c.DefaultClientLimits != nil {
+		return *c.DefaultClientLimits, true
+	}
+	return ClientLimits{}, false
+}
+
+func (c *ReceiverConfig) validateClientLimits() error {
+	if !c.AppendClientIdentity && (len(c.ClientLimits) > 0 || c.DefaultClientLimits != nil) {
+		return errors.New("`ClientLimits` and `DefaultClientLimits` require `AppendClientIdentity`")
+	}
+	validate := func(l ClientLimits) error {
+		if l.MaxConcurrentReceives < 0 {
+			return errors.New("`MaxConcurrentReceives` must not be negative")
+		}
+		return errors.Wrap(bandwidthlimit.ValidateConfig(l.BandwidthLimit), "`BandwidthLimit` field invalid")
+	}
+	if c.DefaultClientLimits != nil {
+		if err := validate(*c.DefaultClientLimits); err != nil {
+			return errors.Wrap(err, "`DefaultClientLimits`")
+		}
+	}
+	for clientIdentity, l := range c.ClientLimits {
+		if _, err := clientRoot(c.RootWithoutClientComponent, clientIdentity); err != nil {
+			return errors.Wrapf(err, "`ClientLimits`: invalid client identity %q", clientIdentity)
+		}
+		if err := validate(l); err != nil {
+			return errors.Wrapf(err, "`ClientLimits` of client %q", clientIdentity)
+		}
+	}
+	return nil
+}
+
+// The state of the ClientLimits of a client that is shared by its receives.
+type clientLimiter struct {
+	clientIdentity string
+	limits         ClientLimits
+	concurrency    *semaphore.S // nil if not limited
+	bwLimit        bandwidthlimit.Wrapper
+}
+
+// Returns nil if the client has no limits.
+func (s *Receiver) clientLimiter(clientIdentity string) *clientLimiter {
+	limits, ok := s.conf.clientLimits(clientIdentity)
+	if !ok {
+		return nil
+	}
+	s.clientLimitersMtx.Lock()
+	defer s.clientLimitersMtx.Unlock()
+	if l, ok := s.clientLimiters[clientIdentity]; ok {
+		return l
+	}
+	l := &clientLimiter{
+		clientIdentity: clientIdentity,
+		limits:         limits,
+		bwLimit:        bandwidthlimit.WrapperFromConfig(limits.BandwidthLimit),
+	}
+	if limits.MaxConcurrentReceives > 0 {
+		l.concurrency = semaphore.New(int64(limits.MaxConcurrentReceives))
+	}
+	s.clientLimiters[clientIdentity] = l
+	return l
+}
+
+// Refuses the receive if it is outside of the client's receive windows.
+func (l *clientLimiter) checkReceiveWindow(ctx context.Context, now time.Time) error {
+	if l == nil || l.limits.ReceiveWindows == nil {
+		return nil
+	}
+	ok, next := l.limits.ReceiveWindows.Contains(now)
+	if ok {
+		return nil
+	}
+	err := &ClientReceiveWindowError{ClientIdentity: l.clientIdentity, NextWindow: next}
+	getLogger(ctx).WithError(err).Error("refusing receive")
+	return err
+}
+
+func (l *clientLimiter) wrapReadCloser(rc io.ReadCloser) io.ReadCloser {
+	if l == nil {
+		return rc
+	}
+	return l.bwLimit.WrapReadCloser(rc)
+}
+
+// Blocks until the client has fewer than MaxConcurrentReceives receives in progress.
+func (l *clientLimiter) acquireConcurrency(ctx context.Context) (sendRecvConcurrencyGuard, error) {
+	if l == nil || l.concurrency == nil {
+		return noSendRecvConcurrencyLimit{}, nil
+	}
+	getLogger(ctx).WithField("max_concurrent_receives", l.limits.MaxConcurrentReceives).Debug("acquire client receive concurrency slot")
+	guard, err := l.concurrency.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return guard, nil
+}
@@ -0,0 +1,98 @@
+package endpoint
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/util/bandwidthlimit"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+type fixedReceiveWindows struct {
+	ok   bool
+	next time.Time
+}
+
+func (w fixedReceiveWindows) Contains(time.Time) (bool, time.Time) { return w.ok, w.next }
+
+func TestClientLimiter(t *testing.T) {
+	root, err := zfs.NewDatasetPath("backup")
+	require.NoError(t, err)
+	next := time.Date(2022, 7, 23, 22, 0, 0, 0, time.UTC)
+	r := NewReceiver(ReceiverConfig{
+		JobID:                      MustMakeJobID("sink"),
+		RootWithoutClientComponent: root,
+		AppendClientIdentity:       true,
+		PlaceholderEncryption:      PlaceholderCreationEncryptionPropertyInherit,
+		BandwidthLimit:             bandwidthlimit.NoLimitConfig(),
+		DefaultClientLimits: &ClientLimits{
+			MaxConcurrentReceives: 1,
+			BandwidthLimit:        bandwidthlimit.NoLimitConfig(),
+		},
+		ClientLimits: map[string]ClientLimits{
+			"night": {
+				BandwidthLimit: bandwidthlimit.NoLimitConfig(),
+				ReceiveWindows: fixedReceiveWindows{ok: false, next: next},
+			},
+		},
+	})
+
+	rootCtx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+
+	a := r.clientLimiter("a")
+	require.NotNil(t, a)
+	assert.True(t, a == r.clientLimiter("a"), "receives of a client share its limiter")
+	assert.False(t, a == r.clientLimiter("b"), "each client gets its own instance of the default limits")
+	assert.NoError(t, a.checkReceiveWindow(rootCtx, time.Now()))
+
+	// the second receive of client a waits for the first one
+	g, err := a.acquireConcurrency(rootCtx)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(rootCtx, 10*time.Millisecond)
+	defer cancel()
+	_, err = a.acquireConcurrency(ctx)
+	assert.Error(t, err)
+	gb, err := r.clientLimiter("b").acquireConcurrency(rootCtx)
+	require.NoError(t, err, "clients do not share concurrency slots")
+	gb.Release()
+	g.Release()
+	g, err = a.acquireConcurrency(rootCtx)
+	require.NoError(t, err)
+	g.Release()
+
+	err = r.clientLimiter("night").checkReceiveWindow(rootCtx, time.Now())
+	require.IsType(t, &ClientReceiveWindowError{}, err)
+	assert.Equal(t, next, err.(*ClientReceiveWindowError).NextWindow)
+	assert.Contains(t, err.Error(), "2022-07-23T22:00:00Z")
+
+	// a nil limiter does not limit
+	var none *clientLimiter
+	assert.NoError(t, none.checkReceiveWindow(rootCtx, time.Now()))
+	g, err = none.acquireConcurrency(rootCtx)
+	require.NoError(t, err)
+	g.Release()
+}
+
+func TestReceiverConfigValidateClientLimits(t *testing.T) {
+	root, err := zfs.NewDatasetPath("backup")
+	require.NoError(t, err)
+	c := ReceiverConfig{
+		JobID:                      MustMakeJobID("sink"),
+		RootWithoutClientComponent: root,
+		AppendClientIdentity:       false,
+		PlaceholderEncryption:      PlaceholderCreationEncryptionPropertyInherit,
+		BandwidthLimit:             bandwidthlimit.NoLimitConfig(),
+		DefaultClientLimits:        &ClientLimits{BandwidthLimit: bandwidthlimit.NoLimitConfig()},
+	}
+	assert.Error(t, c.Validate(), "client limits require AppendClientIdentity")
+	c.AppendClientIdentity = true
+	assert.NoError(t, c.Validate())
+	c.ClientLimits = map[string]ClientLimits{"with/slash": {BandwidthLimit: bandwidthlimit.NoLimitConfig()}}
+	assert.Error(t, c.Validate())
+}
@@ -0,0 +1,139 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// ClientQuota limits the space that a client may occupy below its client root
+// on a Receiver with AppendClientIdentity = true.
+//
+// The values are applied as the ZFS properties of the same name to the client root dataset,
+// they cover the client's entire subtree.
+// A zero value leaves the corresponding property untouched.
+type ClientQuota struct {
+	Quota       uint64
+	Reservation uint64
+}
+
+func (q ClientQuota) properties() map[string]uint64 {
+	return map[string]uint64{
+		"quota":       q.Quota,
+		"reservation": q.Reservation,
+	}
+}
+
+// ClientQuotaExceededError is returned by Receiver.Receive if the client's subtree
+// already uses its quota, or would exceed it by receiving a send stream of the expected size.
+type ClientQuotaExceededError struct {
+	ClientIdentity string
+	ClientRoot     string
+	Used, Limit    uint64
+	// the sender's size estimate of the send stream, 0 if unknown
+	ExpectedSize uint64
+}
+
+func (e *ClientQuotaExceededError) Error() string {
+	if e.Used < e.Limit {
+		return fmt.Sprintf("receive would exceed quota of client %q on receiving side, refusing to receive (%d of %d bytes used on %s, send stream is expected to be %d bytes)",
+			e.ClientIdentity, e.Used, e.Limit, e.ClientRoot, e.ExpectedSize)
+	}
+	return fmt.Sprintf("client %q exceeds its quota on receiving side, refusing to receive (%d of %d bytes used on %s)",
+		e.ClientIdentity, e.Used, e.Limit, e.ClientRoot)
+}
+
+func (c *ReceiverConfig) clientQuota(clientIdentity string) (ClientQuota, bool) {
+	if q, ok := c.ClientQuotas[clientIdentity]; ok {
+		return q, true
+	}
+	if c.DefaultClientQuota != nil {
+		return *c.DefaultClientQuota, true
+	}
+	return ClientQuota{}, false
+}
+
+func (s *Receiver) clientIdentityFromCtx(ctx context.Context) string {
+	if s.Test_OverrideClientIdentityFunc != nil {
+		return s.Test_OverrideClientIdentityFunc()
+	}
+	clientIdentity, ok := ctx.Value(ClientIdentityKey).(string)
+	if !ok {
+		panic("ClientIdentityKey context value must be set")
+	}
+	return clientIdentity
+}
+
+// Applies the configured ClientQuota to the client root and refuses the receive
+// if the client has already used up its quota or would exceed it by receiving
+// a send stream of expectedSize bytes (0 if unknown).
+//
+// Must be called after the client root has been created.
+func (s *Receiver) receive_EnforceClientQuota(ctx context.Context, clientRoot *zfs.DatasetPath, expectedSize uint64) error {
+	if !s.conf.AppendClientIdentity {
+		return nil
+	}
+	clientIdentity := s.clientIdentityFromCtx(ctx)
+	quota, ok := s.conf.clientQuota(clientIdentity)
+	if !ok {
+		return nil
+	}
+
+	l := getLogger(ctx).WithField("client_root", clientRoot.ToString())
+
+	props, err := zfs.ZFSGet(ctx, clientRoot, []string{"quota", "reservation", "used"})
+	if err != nil {
+		return errors.Wrap(err, "cannot get space accounting properties of client root")
+	}
+	getUint := func(prop string) (uint64, error) {
+		v, err := strconv.ParseUint(props.Get(prop), 10, 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, "cannot parse property %q of client root", prop)
+		}
+		return v, nil
+	}
+
+	set := make(map[string]string)
+	for prop, want := range quota.properties() {
+		if want == 0 {
+			continue
+		}
+		have, err := getUint(prop)
+		if err != nil {
+			return err
+		}
+		if have != want {
+			set[prop] = strconv.FormatUint(want, 10)
+		}
+	}
+	if len(set) > 0 {
+		l.WithField("properties", set).Info("updating client quota properties")
+		if err := zfs.ZFSSet(ctx, clientRoot, set); err != nil {
+			return errors.Wrap(err, "cannot set client quota properties on client root")
+		}
+	}
+
+	if quota.Quota == 0 {
+		return nil
+	}
+	used, err := getUint("used")
+	if err != nil {
+		return err
+	}
+	if used >= quota.Quota || expectedSize > quota.Quota-used {
+		err := &ClientQuotaExceededError{
+			ClientIdentity: clientIdentity,
+			ClientRoot:     clientRoot.ToString(),
+			Used:           used,
+			Limit:          quota.Quota,
+			ExpectedSize:   expectedSize,
+		}
+		l.WithError(err).Error("refusing receive")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package endpoint
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// ClientUsage is the space that the subtree of a client identity's client root consumes,
+// e.g. for billing or alerting per client of a sink.
+type ClientUsage struct {
+	ClientIdentity string
+	// the used property of the client root, i.e., including its descendants and all snapshots
+	Used uint64
+	// the sums of the usedbysnapshots and logicalreferenced properties of the subtree's filesystems and volumes
+	UsedBySnapshots   uint64
+	LogicalReferenced uint64
+}
+
+var clientUsageProperties = []string{"name", "used", "usedbysnapshots", "logicalreferenced"}
+
+// ListClientUsage returns the usage of the client roots below c.RootWithoutClientComponent, sorted by client identity.
+// The children of the root that are the targets of c.Mappings are not client roots, hence not included.
+//
+// Only meaningful if c.AppendClientIdentity is true.
+func ListClientUsage(ctx context.Context, c ReceiverConfig) ([]ClientUsage, error) {
+	rows, err := zfs.ZFSList(ctx, clientUsageProperties, "-r", "-t", "filesystem,volume", c.RootWithoutClientComponent.ToString())
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list root filesystem")
+	}
+	return clientUsageFromList(c.RootWithoutClientComponent, clientUsageMappingTargets(c.Mappings), rows)
+}
+
+// clientUsageMappingTargets returns the children of the root that mappings receive to,
+// unless they are expanded per filesystem or client.
+func clientUsageMappingTargets(mappings []FilesystemMapping) map[string]bool {
+	targets := make(map[string]bool)
+	for _, m := range mappings {
+		child := strings.SplitN(m.Replace, "/", 2)[0]
+		if !strings.Contains(child, "$") {
+			targets[child] = true
+		}
+	}
+	return targets
+}
+
+// rows are the output of `zfs list -rHp -o clientUsageProperties root`.
+func clientUsageFromList(root *zfs.DatasetPath, excluded map[string]bool, rows [][]string) ([]ClientUsage, error) {
+	byClient := make(map[string]*ClientUsage)
+	for _, row := range rows {
+		if len(row) != len(clientUsageProperties) {
+			return nil, errors.Errorf("unexpected zfs list output: %q", row)
+		}
+		p, err := zfs.NewDatasetPath(row[0])
+		if err != nil {
+			return nil, err
+		}
+		if p.Length() <= root.Length() || !p.HasPrefix(root) {
+			continue
+		}
+		var values [3]uint64
+		for i, v := range row[1:] {
+			if v == "-" {
+				continue // logicalreferenced is not supported by all ZFS versions
+			}
+			if values[i], err = strconv.ParseUint(v, 10, 64); err != nil {
+				return nil, errors.Wrapf(err, "cannot parse %s of %s", clientUsageProperties[i+1], row[0])
+			}
+		}
+
+		rel := p.Copy()
+		rel.TrimPrefix(root)
+		client := strings.SplitN(rel.ToString(), "/", 2)[0]
+		if excluded[client] {
+			continue
+		}
+		u, ok := byClient[client]
+		if !ok {
+			u = &ClientUsage{ClientIdentity: client}
+			byClient[client] = u
+		}
+		if rel.Length() == 1 {
+			u.Used = values[0]
+		}
+		u.UsedBySnapshots += values[1]
+		u.LogicalReferenced += values[2]
+	}
+
+	usage := make([]ClientUsage, 0, len(byClient))
+	for _, u := range byClient {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].ClientIdentity < usage[j].ClientIdentity })
+	return usage, nil
+}
@@ -0,0 +1,36 @@
+package endpoint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestClientUsageFromList(t *testing.T) {
+	root, err := zfs.NewDatasetPath("pool/sink")
+	require.NoError(t, err)
+	rows := [][]string{
+		{"pool/sink", "1000", "0", "0"},
+		{"pool/sink/prod", "600", "50", "100"},
+		{"pool/sink/prod/pool", "550", "0", "-"},
+		{"pool/sink/prod/pool/data", "500", "200", "800"},
+		{"pool/sink/laptop", "300", "10", "400"},
+		{"pool/sink/shared", "100", "0", "100"},
+		{"pool/sink/shared/prod", "90", "0", "90"},
+	}
+	usage, err := clientUsageFromList(root, clientUsageMappingTargets([]FilesystemMapping{
+		{Replace: "shared/${client}"},
+		{Replace: "${client}/other"},
+	}), rows)
+	require.NoError(t, err)
+	assert.Equal(t, []ClientUsage{
+		{ClientIdentity: "laptop", Used: 300, UsedBySnapshots: 10, LogicalReferenced: 400},
+		{ClientIdentity: "prod", Used: 600, UsedBySnapshots: 250, LogicalReferenced: 900},
+	}, usage, "used is that of the client root, the others are sums over the subtree, mapping targets are excluded")
+
+	_, err = clientUsageFromList(root, nil, [][]string{{"pool/sink/prod", "x", "0", "0"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,68 @@
+package endpoint
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/zrepl/zrepl/util/semaphore"
+)
+
+// Daemon-wide limits for the number of concurrently running `zfs send` and `zfs recv` processes.
+// Waiters are served round-robin by job (see semaphore.Fair).
+// A nil semaphore means no limit.
+var sendRecvConcurrency struct {
+	mtx        sync.Mutex
+	send, recv *semaphore.Fair
+}
+
+// SetSendRecvConcurrencyLimits must be called before any Sender or Receiver is used.
+// A limit of 0 means no limit.
+func SetSendRecvConcurrencyLimits(send, recv int) {
+	sendRecvConcurrency.mtx.Lock()
+	defer sendRecvConcurrency.mtx.Unlock()
+	sendRecvConcurrency.send, sendRecvConcurrency.recv = nil, nil
+	if send > 0 {
+		sendRecvConcurrency.send = semaphore.NewFair(int64(send))
+	}
+	if recv > 0 {
+		sendRecvConcurrency.recv = semaphore.NewFair(int64(recv))
+	}
+}
+
+type sendRecvConcurrencyGuard interface {
+	Release()
+}
+
+type noSendRecvConcurrencyLimit struct{}
+
+func (noSendRecvConcurrencyLimit) Release() {}
+
+func acquireSendRecvConcurrency(ctx context.Context, recv bool, jobID JobID) (sendRecvConcurrencyGuard, error) {
+	sendRecvConcurrency.mtx.Lock()
+	sem := sendRecvConcurrency.send
+	if recv {
+		sem = sendRecvConcurrency.recv
+	}
+	sendRecvConcurrency.mtx.Unlock()
+	if sem == nil {
+		return noSendRecvConcurrencyLimit{}, nil
+	}
+	guard, err := sem.Acquire(ctx, jobID.String())
+	if err != nil {
+		return nil, err
+	}
+	return guard, nil
+}
+
+// releases guard when the send stream is closed, i.e., after `zfs send` exited
+type sendRecvConcurrencyReleasingReadCloser struct {
+	io.ReadCloser
+	guard sendRecvConcurrencyGuard
+}
+
+func (r *sendRecvConcurrencyReleasingReadCloser) Close() error {
+	err := r.ReadCloser.Close()
+	r.guard.Release()
+	return err
+}
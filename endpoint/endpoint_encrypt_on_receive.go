@@ -0,0 +1,107 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs"
+	zfsprop "github.com/zrepl/zrepl/zfs/property"
+)
+
+const encryptOnReceiveProperty zfsprop.Property = "encryption"
+
+func (c *ReceiverConfig) validateEncryptOnReceive() error {
+	if !c.EncryptOnReceive {
+		return nil
+	}
+	if c.PlaceholderEncryption == PlaceholderCreationEncryptionPropertyOff {
+		return errors.New("`PlaceholderEncryption` must not be off with `EncryptOnReceive`, the received filesystems inherit encryption from their parents")
+	}
+	if _, ok := c.OverrideProperties[encryptOnReceiveProperty]; ok {
+		return errors.Errorf("property %q must not be overridden, it is controlled by `EncryptOnReceive`", encryptOnReceiveProperty)
+	}
+	for _, p := range c.InheritProperties {
+		if p == encryptOnReceiveProperty {
+			return errors.Errorf("property %q must not be inherited, it is controlled by `EncryptOnReceive`", encryptOnReceiveProperty)
+		}
+	}
+	return nil
+}
+
+// EncryptOnReceiveError is returned by Receive if ReceiverConfig.EncryptOnReceive is set
+// but the received filesystem cannot inherit encryption from its parent.
+type EncryptOnReceiveError struct {
+	Filesystem, Parent string
+	Reason             string
+}
+
+func (e *EncryptOnReceiveError) Error() string {
+	return fmt.Sprintf("cannot encrypt %s on receive: parent filesystem %s %s", e.Filesystem, e.Parent, e.Reason)
+}
+
+// Sets the recv flags that make the plaintext send stream that starts with header inherit encryption from lp's parent:
+// initial receives get `-o encryption=on`, which zfs refuses for incremental receives,
+// and incremental receives get `-x encryption` to ignore the encryption property of streams sent with properties.
+// Raw send streams are received as is, they remain encrypted with the sender's key.
+//
+// Refuses initial receives whose parent is not encrypted or whose key is not loaded,
+// zfs would otherwise fail with an error that does not name the parent.
+func (s *Receiver) receive_EncryptOnReceive(ctx context.Context, lp *zfs.DatasetPath, ph *zfs.FilesystemPlaceholderState, header []byte, opts *zfs.RecvOptions) error {
+	if !s.conf.EncryptOnReceive {
+		return nil
+	}
+	log := getLogger(ctx).WithField("local_fs", lp.ToString())
+
+	begin, err := zfs.ParseSendStreamBegin(header)
+	if err != nil {
+		return errors.Wrap(err, "cannot determine whether send stream is raw for encryption on receive")
+	}
+	if begin.Raw {
+		log.Debug("receiving raw send stream, encrypted with the sender's key")
+		return nil
+	}
+
+	if ph.FSExists && !ph.IsPlaceholder {
+		props, err := zfs.ZFSGet(ctx, lp, []string{"encryption"})
+		if err != nil {
+			return errors.Wrap(err, "cannot get encryption property of receiving filesystem")
+		}
+		if props.Get("encryption") == "off" {
+			log.Warn("receiving filesystem is not encrypted, encryption on receive only applies to the initial replication of a filesystem")
+		}
+		inherit := make([]zfsprop.Property, 0, len(opts.InheritProperties)+1)
+		inherit = append(inherit, opts.InheritProperties...)
+		opts.InheritProperties = append(inherit, encryptOnReceiveProperty)
+		return nil
+	}
+
+	parent, err := zfs.NewDatasetPath(lp.ToString()[:strings.LastIndex(lp.ToString(), "/")])
+	if err != nil {
+		return err
+	}
+	props, err := zfs.ZFSGet(ctx, parent, []string{"encryption", "keystatus"})
+	if err != nil {
+		return errors.Wrap(err, "cannot get encryption properties of parent filesystem")
+	}
+	refuse := func(reason string) error {
+		err := &EncryptOnReceiveError{Filesystem: lp.ToString(), Parent: parent.ToString(), Reason: reason}
+		log.WithError(err).Error("refusing receive")
+		return err
+	}
+	if props.Get("encryption") == "off" {
+		return refuse("is not encrypted")
+	}
+	if keystatus := props.Get("keystatus"); keystatus != "available" {
+		return refuse(fmt.Sprintf("has keystatus %s, load its key with zfs load-key", keystatus))
+	}
+	override := make(map[zfsprop.Property]string, len(opts.OverrideProperties)+1)
+	for p, v := range opts.OverrideProperties {
+		override[p] = v
+	}
+	override[encryptOnReceiveProperty] = "on"
+	opts.OverrideProperties = override
+	return nil
+}
@@ -0,0 +1,59 @@
+package endpoint
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/util/bandwidthlimit"
+	"github.com/zrepl/zrepl/zfs"
+	zfsprop "github.com/zrepl/zrepl/zfs/property"
+)
+
+func TestReceiverEncryptOnReceive(t *testing.T) {
+	root, err := zfs.NewDatasetPath("backup")
+	require.NoError(t, err)
+	c := ReceiverConfig{
+		JobID:                      MustMakeJobID("sink"),
+		RootWithoutClientComponent: root,
+		PlaceholderEncryption:      PlaceholderCreationEncryptionPropertyUnspecified,
+		BandwidthLimit:             bandwidthlimit.NoLimitConfig(),
+		EncryptOnReceive:           true,
+	}
+	require.NoError(t, c.Validate())
+
+	lp, err := zfs.NewDatasetPath("backup/pool/data")
+	require.NoError(t, err)
+	ph, err := NewReceiver(c).receive_GetPlaceholderCreationEncryptionValue(root, lp)
+	require.NoError(t, err)
+	assert.Equal(t, zfs.FilesystemPlaceholderCreateEncryptionInherit, ph, "unspecified placeholders inherit encryption")
+
+	invalid := c
+	invalid.PlaceholderEncryption = PlaceholderCreationEncryptionPropertyOff
+	assert.Error(t, invalid.Validate())
+	invalid = c
+	invalid.OverrideProperties = map[zfsprop.Property]string{"encryption": "on"}
+	assert.Error(t, invalid.Validate())
+	invalid = c
+	invalid.InheritProperties = []zfsprop.Property{"encryption"}
+	assert.Error(t, invalid.Validate())
+
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+	r := NewReceiver(c)
+	var opts zfs.RecvOptions
+
+	err = r.receive_EncryptOnReceive(ctx, lp, &zfs.FilesystemPlaceholderState{}, []byte("not a send stream"), &opts)
+	assert.Error(t, err)
+
+	raw := make([]byte, 312)
+	binary.LittleEndian.PutUint64(raw[8:], 0x2F5bacbac)
+	binary.LittleEndian.PutUint64(raw[16:], (1<<24)<<2)
+	err = r.receive_EncryptOnReceive(ctx, lp, &zfs.FilesystemPlaceholderState{}, raw, &opts)
+	require.NoError(t, err)
+	assert.Equal(t, zfs.RecvOptions{}, opts, "raw streams keep the sender's encryption")
+}
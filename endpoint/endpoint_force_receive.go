@@ -0,0 +1,187 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// ForceReceivePolicy controls when the Receiver uses `zfs recv -F`, which rolls back the receiving filesystem.
+type ForceReceivePolicy int
+
+const (
+	// Only to replace placeholders, destroying their snapshots.
+	ForceReceivePlaceholders ForceReceivePolicy = iota
+	// Never, i.e., placeholders cannot be replaced by received filesystems.
+	ForceReceiveNever
+	// Only if the receiving filesystem has no snapshots newer than the incremental source,
+	// i.e., placeholders without snapshots are replaced and incremental receives discard
+	// the changes made since the receiving filesystem's most recent snapshot.
+	ForceReceiveNoNewerSnapshots
+	// Like ForceReceiveNoNewerSnapshots, but placeholders are replaced unconditionally and
+	// incremental receives also destroy the receiving filesystem's snapshots newer than the incremental source.
+	ForceReceiveAlways
+)
+
+// Note: the values are part of the config format!
+var forceReceivePolicyConfigMap = map[ForceReceivePolicy]string{
+	ForceReceivePlaceholders:     "placeholders",
+	ForceReceiveNever:            "never",
+	ForceReceiveNoNewerSnapshots: "no_newer_snapshots",
+	ForceReceiveAlways:           "always",
+}
+
+func ForceReceivePolicyFromConfig(in string) (ForceReceivePolicy, error) {
+	l := make([]string, 0, len(forceReceivePolicyConfigMap))
+	for p := ForceReceivePlaceholders; p <= ForceReceiveAlways; p++ {
+		if forceReceivePolicyConfigMap[p] == in {
+			return p, nil
+		}
+		l = append(l, forceReceivePolicyConfigMap[p])
+	}
+	return 0, fmt.Errorf("invalid value %q, must be one of %s", in, strings.Join(l, ", "))
+}
+
+func (p ForceReceivePolicy) String() string {
+	if s, ok := forceReceivePolicyConfigMap[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("ForceReceivePolicy(%d)", int(p))
+}
+
+func (c *ReceiverConfig) validateForceReceive() error {
+	if _, ok := forceReceivePolicyConfigMap[c.ForceReceive]; !ok {
+		return errors.Errorf("`ForceReceive` field is invalid")
+	}
+	if c.AppendOnly && c.ForceReceive == ForceReceiveAlways {
+		return errors.Errorf("`ForceReceive` must not be %q if `AppendOnly` is set", c.ForceReceive)
+	}
+	return nil
+}
+
+// ForceReceiveRefusedError is returned by Receive if the receive requires `zfs recv -F`
+// but ReceiverConfig.ForceReceive does not permit it.
+type ForceReceiveRefusedError struct {
+	Filesystem string
+	Policy     ForceReceivePolicy
+	Reason     string
+}
+
+func (e *ForceReceiveRefusedError) Error() string {
+	return fmt.Sprintf("receiving side's force_receive policy %q refuses forced receive into %s: %s", e.Policy, e.Filesystem, e.Reason)
+}
+
+func (s *Receiver) refuseForceReceive(ctx context.Context, lp *zfs.DatasetPath, reason string) error {
+	err := &ForceReceiveRefusedError{Filesystem: lp.ToString(), Policy: s.conf.ForceReceive, Reason: reason}
+	getLogger(ctx).WithError(err).Error("refusing receive")
+	return err
+}
+
+// forceReceive describes the rollback of a forced receive, for the audit record.
+type forceReceive struct {
+	// the snapshot that the receive rolls back to, empty if it destroys all snapshots
+	rollbackTo string
+	// the snapshots that the receive destroys
+	destroyed []string
+}
+
+func forceReceiveSortedSnapshots(ctx context.Context, lp *zfs.DatasetPath) ([]zfs.FilesystemVersion, error) {
+	snaps, err := zfs.ZFSListFilesystemVersions(ctx, lp, zfs.ListFilesystemVersionsOptions{Types: zfs.Snapshots})
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot list snapshots of %s for forced receive", lp.ToString())
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].CreateTXG < snaps[j].CreateTXG })
+	return snaps, nil
+}
+
+func forceReceiveNames(snaps []zfs.FilesystemVersion) []string {
+	names := make([]string, len(snaps))
+	for i, s := range snaps {
+		names[i] = s.Name
+	}
+	return names
+}
+
+// Decides whether the placeholder lp may be replaced by a forced receive, which destroys all of its snapshots.
+func (s *Receiver) receive_ForcePlaceholder(ctx context.Context, lp *zfs.DatasetPath) (*forceReceive, error) {
+	if s.conf.ForceReceive == ForceReceiveNever {
+		return nil, s.refuseForceReceive(ctx, lp, "cannot replace placeholder filesystem")
+	}
+	snaps, err := forceReceiveSortedSnapshots(ctx, lp)
+	if err != nil {
+		return nil, err
+	}
+	if len(snaps) > 0 && s.conf.ForceReceive == ForceReceiveNoNewerSnapshots {
+		return nil, s.refuseForceReceive(ctx, lp, fmt.Sprintf("placeholder filesystem has %d snapshot(s)", len(snaps)))
+	}
+	return &forceReceive{destroyed: forceReceiveNames(snaps)}, nil
+}
+
+// Decides whether the receive of the send stream that starts with header into the existing filesystem lp uses `zfs recv -F`.
+// Returns nil if it does not.
+//
+// Without -F, the receive fails if lp has been modified since its most recent snapshot,
+// e.g., because it is mounted read-write, or if lp has snapshots newer than the incremental source.
+func (s *Receiver) receive_ForceIncremental(ctx context.Context, lp *zfs.DatasetPath, header []byte) (*forceReceive, error) {
+	if s.conf.ForceReceive != ForceReceiveNoNewerSnapshots && s.conf.ForceReceive != ForceReceiveAlways {
+		return nil, nil
+	}
+	log := getLogger(ctx).WithField("local_fs", lp.ToString())
+
+	begin, err := zfs.ParseSendStreamBegin(header)
+	if err != nil {
+		log.WithError(err).Warn("cannot determine incremental source of send stream, not using forced receive")
+		return nil, nil
+	}
+	if begin.FromGUID == 0 {
+		return nil, nil // full receive into an existing filesystem, fails with or without -F
+	}
+	snaps, err := forceReceiveSortedSnapshots(ctx, lp)
+	if err != nil {
+		return nil, err
+	}
+	from := -1
+	for i, snap := range snaps {
+		if snap.Guid == begin.FromGUID {
+			from = i
+		}
+	}
+	if from == -1 {
+		return nil, nil // the receive fails with or without -F
+	}
+
+	newer := snaps[from+1:]
+	if len(newer) > 0 && s.conf.ForceReceive == ForceReceiveNoNewerSnapshots {
+		return nil, s.refuseForceReceive(ctx, lp, fmt.Sprintf("%d snapshot(s) are newer than incremental source %s: %s",
+			len(newer), snaps[from].Name, strings.Join(forceReceiveNames(newer), ", ")))
+	}
+	return &forceReceive{rollbackTo: snaps[from].Name, destroyed: forceReceiveNames(newer)}, nil
+}
+
+// Logs the audit record of the rollback that the forced receive into lp is about to perform.
+func (s *Receiver) receive_AuditForceReceive(ctx context.Context, lp *zfs.DatasetPath, f *forceReceive) {
+	log := getLogger(ctx).
+		WithField("local_fs", lp.ToString()).
+		WithField("force_receive", s.conf.ForceReceive.String()).
+		WithField("destroyed_snapshots", f.destroyed)
+	if s.conf.AppendClientIdentity {
+		log = log.WithField("client_identity", s.clientIdentityFromCtx(ctx))
+	}
+	if f.rollbackTo != "" {
+		log = log.WithField("rollback_to", f.rollbackTo)
+	} else {
+		log = log.WithField("rollback_to", "(destroy all snapshots)")
+	}
+	// the changes since the most recent snapshot, which the rollback discards in any case
+	if props, err := zfs.ZFSGet(ctx, lp, []string{"written"}); err != nil {
+		log.WithError(err).Debug("cannot get written property for audit record")
+	} else {
+		log = log.WithField("discarded_written_bytes", props.Get("written"))
+	}
+	log.Warn("audit: forced receive rolls back receiving filesystem")
+}
@@ -0,0 +1,68 @@
+package endpoint
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/util/bandwidthlimit"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestForceReceivePolicyFromConfig(t *testing.T) {
+	for p, s := range forceReceivePolicyConfigMap {
+		parsed, err := ForceReceivePolicyFromConfig(s)
+		require.NoError(t, err)
+		assert.Equal(t, p, parsed)
+		assert.Equal(t, s, p.String())
+	}
+	_, err := ForceReceivePolicyFromConfig("sometimes")
+	assert.Error(t, err)
+}
+
+func TestReceiverForceReceive(t *testing.T) {
+	root, err := zfs.NewDatasetPath("backup")
+	require.NoError(t, err)
+	c := ReceiverConfig{
+		JobID:                      MustMakeJobID("sink"),
+		RootWithoutClientComponent: root,
+		PlaceholderEncryption:      PlaceholderCreationEncryptionPropertyInherit,
+		BandwidthLimit:             bandwidthlimit.NoLimitConfig(),
+	}
+	require.NoError(t, c.Validate())
+	assert.Equal(t, ForceReceivePlaceholders, c.ForceReceive, "the zero value is the default")
+
+	c.ForceReceive = ForceReceivePolicy(23)
+	assert.Error(t, c.Validate())
+	c.ForceReceive = ForceReceiveAlways
+	c.AppendOnly = true
+	assert.Error(t, c.Validate(), "always destroys snapshots")
+	c.ForceReceive = ForceReceiveNoNewerSnapshots
+	assert.NoError(t, c.Validate())
+	c.AppendOnly = false
+
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+	lp, err := zfs.NewDatasetPath("backup/pool/data")
+	require.NoError(t, err)
+
+	c.ForceReceive = ForceReceiveNever
+	_, err = NewReceiver(c).receive_ForcePlaceholder(ctx, lp)
+	require.IsType(t, &ForceReceiveRefusedError{}, err)
+	assert.Equal(t, "backup/pool/data", err.(*ForceReceiveRefusedError).Filesystem)
+
+	for _, p := range []ForceReceivePolicy{ForceReceivePlaceholders, ForceReceiveNever} {
+		c.ForceReceive = p
+		f, err := NewReceiver(c).receive_ForceIncremental(ctx, lp, nil)
+		assert.NoError(t, err)
+		assert.Nil(t, f, "%s does not force incremental receives", p)
+	}
+
+	c.ForceReceive = ForceReceiveAlways
+	f, err := NewReceiver(c).receive_ForceIncremental(ctx, lp, []byte("not a send stream"))
+	assert.NoError(t, err)
+	assert.Nil(t, f, "no forced receive if the incremental source is unknown")
+}
@@ -0,0 +1,54 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// FreeSpaceThresholdError is returned by Receiver.Receive if the space available
+// to the receiving side's root filesystem, less the expected size of the send stream,
+// is below ReceiverConfig.MinFreeSpace.
+type FreeSpaceThresholdError struct {
+	Dataset                            string
+	Available, ExpectedSize, Threshold uint64
+}
+
+func (e *FreeSpaceThresholdError) Error() string {
+	return fmt.Sprintf("%s, refusing to receive until space is freed up (%s has %d bytes available, the send stream is expected to be %d bytes, threshold is %d bytes)",
+		pdu.ReceiverLowOnSpaceMessage, e.Dataset, e.Available, e.ExpectedSize, e.Threshold)
+}
+
+// Refuses the receive if the space available to root would fall below the configured threshold
+// after receiving a send stream of expectedSize bytes (0 if unknown).
+//
+// Must be called after root has been created.
+func (s *Receiver) receive_EnforceMinFreeSpace(ctx context.Context, root *zfs.DatasetPath, expectedSize uint64) error {
+	if s.conf.MinFreeSpace == 0 {
+		return nil
+	}
+	props, err := zfs.ZFSGet(ctx, root, []string{"available"})
+	if err != nil {
+		return errors.Wrap(err, "cannot get available space of root filesystem")
+	}
+	available, err := strconv.ParseUint(props.Get("available"), 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "cannot parse available space of root filesystem")
+	}
+	if available < expectedSize || available-expectedSize < s.conf.MinFreeSpace {
+		err := &FreeSpaceThresholdError{
+			Dataset:      root.ToString(),
+			Available:    available,
+			ExpectedSize: expectedSize,
+			Threshold:    s.conf.MinFreeSpace,
+		}
+		getLogger(ctx).WithError(err).Error("refusing receive")
+		return err
+	}
+	return nil
+}
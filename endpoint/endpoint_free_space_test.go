@@ -0,0 +1,40 @@
+package endpoint
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+	"github.com/zrepl/zrepl/util/bandwidthlimit"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestFreeSpaceThresholdError(t *testing.T) {
+	err := &FreeSpaceThresholdError{Dataset: "backup", Available: 10 << 30, ExpectedSize: 3 << 30, Threshold: 8 << 30}
+	assert.Contains(t, err.Error(), "10737418240 bytes available")
+	assert.Contains(t, err.Error(), "3221225472 bytes")
+	assert.True(t, pdu.IsReceiverLowOnSpaceError(err))
+	// the active side only gets the message of a remote receiver's error
+	assert.True(t, pdu.IsReceiverLowOnSpaceError(errors.Wrap(errors.New("server error: "+err.Error()), "receive")))
+	assert.False(t, pdu.IsReceiverLowOnSpaceError(errors.New("cannot receive")))
+	assert.False(t, pdu.IsReceiverLowOnSpaceError(nil))
+}
+
+func TestReceiverEnforceMinFreeSpaceDisabled(t *testing.T) {
+	root, err := zfs.NewDatasetPath("backup")
+	require.NoError(t, err)
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+	r := NewReceiver(ReceiverConfig{
+		JobID:                      MustMakeJobID("sink"),
+		RootWithoutClientComponent: root,
+		PlaceholderEncryption:      PlaceholderCreationEncryptionPropertyInherit,
+		BandwidthLimit:             bandwidthlimit.NoLimitConfig(),
+	})
+	assert.NoError(t, r.receive_EnforceMinFreeSpace(ctx, root, 1<<40), "does not query zfs if disabled")
+}
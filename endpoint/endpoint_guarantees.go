@@ -146,7 +146,11 @@ func (g ReplicationGuaranteeIncremental) SenderPostRecvConfirmed(ctx context.Con
 	return senderPostRecvConfirmedCommon(ctx, jid, fs, to)
 }
 
-type ReplicationGuaranteeResumability struct{}
+type ReplicationGuaranteeResumability struct {
+	// Protect the `from` version with a tentative replication cursor bookmark
+	// instead of a step hold. Resumability only requires that `to` is held.
+	FromBookmark bool
+}
 
 func (g ReplicationGuaranteeResumability) String() string { return "resumability" }
 
@@ -156,7 +160,19 @@ func (g ReplicationGuaranteeResumability) Kind() ReplicationGuaranteeKind {
 
 func (g ReplicationGuaranteeResumability) SenderPreSend(ctx context.Context, jid JobID, sendArgs *zfs.ZFSSendArgsValidated) (keep []Abstraction, err error) {
 	// try to hold the FromVersion
-	if sendArgs.FromVersion != nil {
+	if sendArgs.FromVersion != nil && g.FromBookmark {
+		from, err := CreateTentativeReplicationCursor(ctx, sendArgs.FS, *sendArgs.FromVersion, jid)
+		if err != nil {
+			if err == zfs.ErrBookmarkCloningNotSupported {
+				getLogger(ctx).WithField("replication_guarantee", g).
+					WithField("bookmark", sendArgs.From.FullPath(sendArgs.FS)).
+					Info("bookmark cloning is not supported, speculating that `from` will not be destroyed until step is done")
+			} else {
+				return nil, err
+			}
+		}
+		keep = append(keep, from)
+	} else if sendArgs.FromVersion != nil {
 		if sendArgs.FromVersion.Type == zfs.Bookmark {
 			getLogger(ctx).WithField("replication_guarantee", g).WithField("fromVersion", sendArgs.FromVersion.FullPath(sendArgs.FS)).
 				Debug("cannot hold a bookmark, speculating that `from` will not be destroyed until step is done")
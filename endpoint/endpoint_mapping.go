@@ -0,0 +1,206 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs"
+	zfsprop "github.com/zrepl/zrepl/zfs/property"
+)
+
+// FilesystemMapping is a rule of ReceiverConfig.Mappings.
+type FilesystemMapping struct {
+	// client identities the rule applies to, all clients if empty
+	Clients []string
+	// must match the whole filesystem path on the sender
+	Match *regexp.Regexp
+	// path relative to ReceiverConfig.RootWithoutClientComponent,
+	// expanded with regexp.Regexp.Expand and ${client} for the client identity
+	Replace string
+}
+
+// The user properties that record the sender's filesystem and the client identity on the filesystems
+// received by a Receiver with Mappings, so that ListFilesystems can present them under that name
+// and the other clients cannot access them.
+const (
+	MappedFromPropertyName       = "zrepl:mapped_from"
+	MappedFromClientPropertyName = "zrepl:mapped_from_client"
+)
+
+func (m FilesystemMapping) appliesTo(clientIdentity string) bool {
+	if len(m.Clients) == 0 {
+		return true
+	}
+	for _, c := range m.Clients {
+		if c == clientIdentity {
+			return true
+		}
+	}
+	return false
+}
+
+func (m FilesystemMapping) validate() error {
+	if m.Match == nil {
+		return errors.New("`Match` must not be nil")
+	}
+	if m.Replace == "" {
+		return errors.New("`Replace` must not be empty")
+	}
+	if len(m.Clients) != 1 && !strings.Contains(m.Replace, "${client}") {
+		return errors.New("`Replace` must contain ${client} unless the rule applies to exactly one client, " +
+			"otherwise the same filesystem of different clients is mapped to the same local filesystem")
+	}
+	return nil
+}
+
+// mapFilesystem returns the local filesystem that the first rule that applies to clientIdentity maps fs to.
+// If no rule matches, ok is false.
+func mapFilesystem(root *zfs.DatasetPath, rules []FilesystemMapping, clientIdentity, fs string) (lp *zfs.DatasetPath, ok bool, err error) {
+	for i, r := range rules {
+		if !r.appliesTo(clientIdentity) {
+			continue
+		}
+		m := r.Match.FindStringSubmatchIndex(fs)
+		if m == nil || m[0] != 0 || m[1] != len(fs) {
+			continue
+		}
+		template := r.Replace
+		if !hasSubexp(r.Match, "client") {
+			template = strings.Replace(template, "${client}", strings.Replace(clientIdentity, "$", "$$", -1), -1)
+		}
+		rel := string(r.Match.ExpandString(nil, template, fs, m))
+		relPath, err := zfs.NewDatasetPath(rel)
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "mapping #%d maps %q to invalid path %q", i, fs, rel)
+		}
+		if relPath.Length() == 0 {
+			return nil, false, errors.Errorf("mapping #%d maps %q to an empty path", i, fs)
+		}
+		for _, c := range strings.Split(rel, "/") {
+			if c == "" || c == "." || c == ".." {
+				return nil, false, errors.Errorf("mapping #%d maps %q to invalid path %q", i, fs, rel)
+			}
+		}
+		lp = root.Copy()
+		lp.Extend(relPath)
+		return lp, true, nil
+	}
+	return nil, false, nil
+}
+
+func hasSubexp(re *regexp.Regexp, name string) bool {
+	for _, n := range re.SubexpNames() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// mapToLocal maps the sender's filesystem fs to the local filesystem, using s.conf.Mappings
+// and falling back to fs below the client root.
+func (s *Receiver) mapToLocal(ctx context.Context, fs string) (*zfs.DatasetPath, error) {
+	root := s.clientRootFromCtx(ctx)
+	if len(s.conf.Mappings) > 0 {
+		lp, ok, err := mapFilesystem(s.conf.RootWithoutClientComponent, s.conf.Mappings, s.clientIdentityFromCtx(ctx), fs)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			if err := checkMappedFilesystemOwner(ctx, lp, s.clientIdentityFromCtx(ctx), fs); err != nil {
+				return nil, err
+			}
+			return lp, nil
+		}
+	}
+	return subroot{root}.MapToLocal(fs)
+}
+
+// checkMappedFilesystemOwner returns an error if the local filesystem lp, which a mapping maps
+// the filesystem fs of clientIdentity to, has been received from another client or sender filesystem.
+// Filesystems without the properties, e.g. placeholders or filesystems that were received
+// before the mappings were configured, and nonexistent filesystems pass the check.
+func checkMappedFilesystemOwner(ctx context.Context, lp *zfs.DatasetPath, clientIdentity, fs string) error {
+	props, err := zfs.ZFSGetLocal(ctx, lp, []string{MappedFromPropertyName, MappedFromClientPropertyName})
+	if _, ok := err.(*zfs.DatasetDoesNotExist); ok {
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "cannot get %s property of %q", MappedFromPropertyName, lp.ToString())
+	}
+	mappedFrom, mappedFromClient := props.Get(MappedFromPropertyName), props.Get(MappedFromClientPropertyName)
+	if mappedFrom == "" && mappedFromClient == "" {
+		return nil
+	}
+	if mappedFrom != fs || mappedFromClient != clientIdentity {
+		return errors.Errorf("mapped filesystem %q has been received from filesystem %q of client %q, refusing access for filesystem %q of client %q",
+			lp.ToString(), mappedFrom, mappedFromClient, fs, clientIdentity)
+	}
+	return nil
+}
+
+// listFilesystems_SenderPath returns the name under which ListFilesystems presents the local filesystem p,
+// ok is false if p does not belong to the client.
+//
+// With Mappings, the filesystems received since the mappings were configured carry the sender's name
+// in MappedFromPropertyName and the client identity in MappedFromClientPropertyName.
+// They belong to the client if it is that client and the mappings still map that name to p.
+// Filesystems without the property belong to the client if they are below the client root
+// and are not mapped elsewhere, e.g. received before the mappings were configured.
+func (s *Receiver) listFilesystems_SenderPath(ctx context.Context, clientRoot, p *zfs.DatasetPath) (senderPath string, ok bool, err error) {
+	if len(s.conf.Mappings) > 0 {
+		props, err := zfs.ZFSGetLocal(ctx, p, []string{MappedFromPropertyName, MappedFromClientPropertyName})
+		if err != nil {
+			return "", false, errors.Wrapf(err, "cannot get %s property of %q", MappedFromPropertyName, p.ToString())
+		}
+		if mappedFrom := props.Get(MappedFromPropertyName); mappedFrom != "" {
+			if props.Get(MappedFromClientPropertyName) != s.clientIdentityFromCtx(ctx) {
+				return "", false, nil
+			}
+			lp, err := s.mapToLocal(ctx, mappedFrom)
+			if err != nil || !lp.Equal(p) {
+				return "", false, nil
+			}
+			return mappedFrom, true, nil
+		}
+	}
+	if !p.HasPrefix(clientRoot) || p.Equal(clientRoot) {
+		return "", false, nil
+	}
+	rel := p.Copy()
+	rel.TrimPrefix(clientRoot)
+	if len(s.conf.Mappings) > 0 {
+		if _, mapped, err := mapFilesystem(s.conf.RootWithoutClientComponent, s.conf.Mappings, s.clientIdentityFromCtx(ctx), rel.ToString()); err != nil || mapped {
+			return "", false, nil
+		}
+	}
+	return rel.ToString(), true, nil
+}
+
+func (c *ReceiverConfig) validateMappings() error {
+	if len(c.Mappings) == 0 {
+		return nil
+	}
+	if !c.AppendClientIdentity {
+		return errors.New("`Mappings` require `AppendClientIdentity`")
+	}
+	for i, m := range c.Mappings {
+		if err := m.validate(); err != nil {
+			return errors.Wrapf(err, "`Mappings` #%d", i)
+		}
+	}
+	for _, prop := range []zfsprop.Property{MappedFromPropertyName, MappedFromClientPropertyName} {
+		if _, ok := c.OverrideProperties[prop]; ok {
+			return fmt.Errorf("property %q must not be overridden if `Mappings` are configured", prop)
+		}
+		for _, p := range c.InheritProperties {
+			if p == prop {
+				return fmt.Errorf("property %q must not be inherited if `Mappings` are configured", prop)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package endpoint
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/util/bandwidthlimit"
+	"github.com/zrepl/zrepl/zfs"
+	zfsprop "github.com/zrepl/zrepl/zfs/property"
+)
+
+func TestMapFilesystem(t *testing.T) {
+	root, err := zfs.NewDatasetPath("backup")
+	require.NoError(t, err)
+	rules := []FilesystemMapping{
+		{Clients: []string{"db1", "db2"}, Match: regexp.MustCompile(`^(?:zroot/var/db(/.*)?)$`), Replace: "db/${client}$1"},
+		{Match: regexp.MustCompile(`^(?:tank/(.+))$`), Replace: "${client}/$1"},
+		{Match: regexp.MustCompile(`^(?:evil/(.*))$`), Replace: "x/$1"},
+		{Match: regexp.MustCompile(`^(?:(?P<client>[^/]+)/.*)$`), Replace: "pools/${client}"},
+	}
+
+	type testCase struct {
+		client, fs string
+		expect     string // empty if no rule matches
+		expectErr  bool
+	}
+	tcs := []testCase{
+		{client: "db1", fs: "zroot/var/db", expect: "backup/db/db1"},
+		{client: "db2", fs: "zroot/var/db/mysql", expect: "backup/db/db2/mysql"},
+		{client: "web", fs: "zroot/var/db", expect: "backup/pools/zroot"}, // first rule does not apply to client web
+		{client: "web", fs: "tank/home/alice", expect: "backup/web/home/alice"},
+		{client: "web", fs: "tank", expect: ""},                    // tank matches neither tank/(.+) nor [^/]+/.*
+		{client: "web", fs: "pool/a", expect: "backup/pools/pool"}, // named submatch client takes precedence
+		{client: "web", fs: "evil/a/../../b", expectErr: true},
+		{client: "web", fs: "evil/", expectErr: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.client+":"+tc.fs, func(t *testing.T) {
+			lp, ok, err := mapFilesystem(root, rules, tc.client, tc.fs)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tc.expect == "" {
+				assert.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			assert.Equal(t, tc.expect, lp.ToString())
+		})
+	}
+}
+
+func TestReceiverConfigValidateMappings(t *testing.T) {
+	root, err := zfs.NewDatasetPath("backup")
+	require.NoError(t, err)
+	c := ReceiverConfig{
+		JobID:                      MustMakeJobID("sink"),
+		RootWithoutClientComponent: root,
+		AppendClientIdentity:       true,
+		PlaceholderEncryption:      PlaceholderCreationEncryptionPropertyInherit,
+		BandwidthLimit:             bandwidthlimit.NoLimitConfig(),
+		Mappings:                   []FilesystemMapping{{Match: regexp.MustCompile(`^(?:tank/(.*))$`), Replace: "${client}/$1"}},
+	}
+	assert.NoError(t, c.Validate())
+
+	// rules that apply to more than one client must map their filesystems to different paths
+	oneClient := c
+	oneClient.Mappings = []FilesystemMapping{{Clients: []string{"db1"}, Match: regexp.MustCompile(`^(?:tank/(.*))$`), Replace: "db1/$1"}}
+	assert.NoError(t, oneClient.Validate())
+	for _, clients := range [][]string{nil, {"db1", "db2"}} {
+		shared := c
+		shared.Mappings = []FilesystemMapping{{Clients: clients, Match: regexp.MustCompile(`^(?:tank/(.*))$`), Replace: "$1"}}
+		assert.Error(t, shared.Validate(), "%v", clients)
+	}
+
+	noAppend := c
+	noAppend.AppendClientIdentity = false
+	assert.Error(t, noAppend.Validate())
+
+	override := c
+	override.OverrideProperties = map[zfsprop.Property]string{MappedFromPropertyName: "x"}
+	assert.Error(t, override.Validate())
+	override.OverrideProperties = map[zfsprop.Property]string{MappedFromClientPropertyName: "x"}
+	assert.Error(t, override.Validate())
+	inherit := c
+	inherit.InheritProperties = []zfsprop.Property{MappedFromClientPropertyName}
+	assert.Error(t, inherit.Validate())
+
+	emptyReplace := c
+	emptyReplace.Mappings = []FilesystemMapping{{Match: regexp.MustCompile(`.*`)}}
+	assert.Error(t, emptyReplace.Validate())
+}
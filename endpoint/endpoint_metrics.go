@@ -1,7 +1,56 @@
 package endpoint
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"io"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var replicationMetrics struct {
+	bytesSent     *prometheus.CounterVec // labels: zrepl_job, filesystem
+	bytesReceived *prometheus.CounterVec // labels: zrepl_job, filesystem
+}
+
+func init() {
+	replicationMetrics.bytesSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "zrepl",
+		Subsystem: "endpoint",
+		Name:      "bytes_sent",
+		Help:      "number of bytes of send streams produced by the sending side, per job and filesystem",
+	}, []string{"zrepl_job", "filesystem"})
+	replicationMetrics.bytesReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "zrepl",
+		Subsystem: "endpoint",
+		Name:      "bytes_received",
+		Help:      "number of bytes of send streams consumed by the receiving side, per job and filesystem",
+	}, []string{"zrepl_job", "filesystem"})
+}
 
 func RegisterMetrics(r prometheus.Registerer) {
 	r.MustRegister(abstractionsCacheMetrics.count)
+	r.MustRegister(replicationMetrics.bytesSent)
+	r.MustRegister(replicationMetrics.bytesReceived)
+}
+
+// The value of the `filesystem` label of per-filesystem metrics.
+// If perFilesystem is false, all filesystems of a job share the empty label value.
+func metricsFilesystemLabel(perFilesystem bool, fs string) string {
+	if !perFilesystem {
+		return ""
+	}
+	return fs
+}
+
+// counts the bytes read from rc while they are read, not only when the stream is done
+type metricsCountingReadCloser struct {
+	io.ReadCloser
+	counter prometheus.Counter
+}
+
+func (r *metricsCountingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.counter.Add(float64(n))
+	}
+	return n, err
 }
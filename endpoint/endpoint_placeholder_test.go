@@ -0,0 +1,35 @@
+package endpoint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/util/bandwidthlimit"
+	"github.com/zrepl/zrepl/zfs"
+	zfsprop "github.com/zrepl/zrepl/zfs/property"
+)
+
+func TestReceiverPlaceholderProperties(t *testing.T) {
+	root, err := zfs.NewDatasetPath("backup")
+	require.NoError(t, err)
+	c := ReceiverConfig{
+		JobID:                      MustMakeJobID("sink"),
+		RootWithoutClientComponent: root,
+		PlaceholderEncryption:      PlaceholderCreationEncryptionPropertyInherit,
+		BandwidthLimit:             bandwidthlimit.NoLimitConfig(),
+	}
+	assert.Equal(t, zfs.PlaceholderDefaultProperties, NewReceiver(c).placeholderProperties())
+
+	c.PlaceholderProperties = map[zfsprop.Property]string{}
+	assert.Empty(t, NewReceiver(c).placeholderProperties(), "an empty map does not fall back to the default")
+
+	c.PlaceholderProperties = map[zfsprop.Property]string{"canmount": "off", "readonly": "on"}
+	assert.Equal(t, map[string]string{"canmount": "off", "readonly": "on"}, NewReceiver(c).placeholderProperties())
+
+	for _, prop := range []zfsprop.Property{"encryption", zfsprop.Property(zfs.PlaceholderPropertyName), "-o"} {
+		c.PlaceholderProperties = map[zfsprop.Property]string{prop: "on"}
+		assert.Error(t, c.Validate(), "%s", prop)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/pkg/errors"
 
@@ -23,6 +24,15 @@ func replicationCursorBookmarkNameImpl(fs string, guid uint64, jobid string) (st
 
 var ErrV1ReplicationCursor = fmt.Errorf("bookmark name is a v1-replication cursor")
 
+const v1ReplicationCursorBookmarkName = "zrepl_replication_cursor"
+
+// IsReplicationCursorBookmarkName returns true if the bookmark name (without dataset path)
+// is reserved for (tentative) replication cursors, even if it is not a valid cursor name.
+func IsReplicationCursorBookmarkName(name string) bool {
+	// also covers tentativeReplicationCursorBookmarkNamePrefix
+	return strings.HasPrefix(name, replicationCursorBookmarkNamePrefix) || name == v1ReplicationCursorBookmarkName
+}
+
 // err != nil always means that the bookmark is not a valid replication bookmark
 //
 // Returns ErrV1ReplicationCursor as error if the bookmark is a v1 replication cursor
@@ -37,8 +47,7 @@ func ParseReplicationCursorBookmarkName(fullname string) (uint64, JobID, error)
 		if err != nil {
 			return 0, JobID{}, errors.Wrap(err, "parse replication cursor bookmark name: decompose version string")
 		}
-		const V1ReplicationCursorBookmarkName = "zrepl_replication_cursor"
-		if name == V1ReplicationCursorBookmarkName {
+		if name == v1ReplicationCursorBookmarkName {
 			return 0, JobID{}, ErrV1ReplicationCursor
 		}
 		// fallthrough to main parser
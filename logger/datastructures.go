@@ -169,6 +169,24 @@ func (os *Outlets) Get(level Level) []Outlet {
 	return os.outs[level]
 }
 
+// Replace atomically replaces the outlets of os with those of other
+// and returns the outlets that os contained before.
+func (os *Outlets) Replace(other *Outlets) (replaced []Outlet) {
+	other.mtx.RLock()
+	outs := make(map[Level][]Outlet, len(other.outs))
+	for level := range other.outs {
+		outs[level] = append([]Outlet(nil), other.outs[level]...)
+	}
+	other.mtx.RUnlock()
+
+	os.mtx.Lock()
+	defer os.mtx.Unlock()
+	// every outlet receives at least the highest level
+	replaced = os.outs[AllLevels[len(AllLevels)-1]]
+	os.outs = outs
+	return replaced
+}
+
 // Return the first outlet added to this Outlets list using Add()
 // with minLevel <= Error.
 // If no such outlet is in this Outlets list, a discarding outlet is returned.
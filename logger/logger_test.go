@@ -46,3 +46,23 @@ func TestLogger_Basic(t *testing.T) {
 	t.Log(pretty.Sprint(outlet_arr))
 
 }
+
+func TestOutlets_Replace(t *testing.T) {
+	old, new := NewTestOutlet(), NewTestOutlet()
+	outlets := logger.NewOutlets()
+	outlets.Add(old, logger.Debug)
+	l := logger.NewLogger(outlets, 1*time.Second)
+
+	replacement := logger.NewOutlets()
+	replacement.Add(new, logger.Warn)
+	replaced := outlets.Replace(replacement)
+	if len(replaced) != 1 || replaced[0] != old {
+		t.Fatalf("unexpected replaced outlets: %v", replaced)
+	}
+
+	l.Info("dropped")
+	l.Warn("kept")
+	if len(old.Record) != 0 || len(new.Record) != 1 || new.Record[0].Message != "kept" {
+		t.Fatalf("unexpected records: old=%v new=%v", old.Record, new.Record)
+	}
+}
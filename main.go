@@ -10,15 +10,27 @@ import (
 
 func init() {
 	cli.AddSubcommand(daemon.DaemonCmd)
+	cli.AddSubcommand(daemon.RunJobCmd)
 	cli.AddSubcommand(status.Subcommand)
+	cli.AddSubcommand(status.MonitorCmd)
 	cli.AddSubcommand(client.SignalCmd)
+	cli.AddSubcommand(client.SnapCmd)
+	cli.AddSubcommand(client.JobCmd)
 	cli.AddSubcommand(client.StdinserverCmd)
+	cli.AddSubcommand(client.IdentitiesCmd)
 	cli.AddSubcommand(client.ConfigcheckCmd)
+	cli.AddSubcommand(client.BootstrapCmd)
 	cli.AddSubcommand(client.VersionCmd)
 	cli.AddSubcommand(client.PprofCmd)
+	cli.AddSubcommand(client.MetricsCmd)
+	cli.AddSubcommand(client.LogsCmd)
 	cli.AddSubcommand(client.TestCmd)
+	cli.AddSubcommand(client.VerifyCmd)
+	cli.AddSubcommand(client.DoctorCmd)
+	cli.AddSubcommand(client.AllowCmd)
 	cli.AddSubcommand(client.MigrateCmd)
 	cli.AddSubcommand(client.ZFSAbstractionsCmd)
+	cli.AddSubcommand(client.ZFSHelperCmd)
 }
 
 func main() {
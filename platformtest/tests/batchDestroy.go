@@ -52,3 +52,43 @@ func BatchDestroy(ctx *platformtest.Context) {
 	`)
 
 }
+
+func BatchDestroyRanged(ctx *platformtest.Context) {
+
+	platformtest.Run(ctx, platformtest.PanicErr, ctx.RootDataset, `
+		DESTROYROOT
+		CREATEROOT
+		+  "foo bar"
+		+  "foo bar@1"
+		+  "foo bar@2"
+		+  "foo bar@3"
+		+  "foo bar@4"
+		+  "foo bar@5"
+	`)
+
+	var reqs []*zfs.DestroySnapOp
+	for _, name := range []string{"1", "2", "3", "5"} {
+		reqs = append(reqs, &zfs.DestroySnapOp{
+			ErrOut:     new(error),
+			Filesystem: fmt.Sprintf("%s/foo bar", ctx.RootDataset),
+			Name:       name,
+		})
+	}
+	zfs.ZFSDestroyFilesystemVersionsRanged(ctx, reqs)
+	for _, r := range reqs {
+		if *r.ErrOut != nil {
+			panic(fmt.Sprintf("expecting no error: %s", *r.ErrOut))
+		}
+	}
+
+	platformtest.Run(ctx, platformtest.PanicErr, ctx.RootDataset, `
+	!N "foo bar@1"
+	!N "foo bar@2"
+	!N "foo bar@3"
+	!E "foo bar@4"
+	!N "foo bar@5"
+	-  "foo bar@4"
+	-  "foo bar"
+	`)
+
+}
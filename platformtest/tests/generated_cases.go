@@ -3,6 +3,7 @@
 package tests
 
 var Cases = []Case{BatchDestroy,
+	BatchDestroyRanged,
 	CreateReplicationCursor,
 	GetNonexistent,
 	HoldsWork,
@@ -16,6 +17,8 @@ var Cases = []Case{BatchDestroy,
 	ListFilesystemsNoFilter,
 	ReceiveForceIntoEncryptedErr,
 	ReceiveForceRollbackWorksUnencrypted,
+	ReplicationEncryptOnReceive__PlaintextStreamsInheritEncryption,
+	ReplicationEncryptOnReceive__UnencryptedParentIsRefused,
 	ReplicationFailingInitialParentProhibitsChildReplication,
 	ReplicationIncrementalCleansUpStaleAbstractionsWithCacheOnSecondReplication,
 	ReplicationIncrementalCleansUpStaleAbstractionsWithoutCacheOnSecondReplication,
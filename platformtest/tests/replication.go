@@ -117,7 +117,7 @@ func (i replicationInvocation) Do(ctx *platformtest.Context) *report.Report {
 			StepQueueConcurrency:     1,
 			ReconnectHardFailTimeout: 1 * time.Second,
 		},
-		logic.NewPlanner(nil, nil, sender, receiver, plannerPolicy),
+		logic.NewPlanner(logic.PlannerMetrics{}, sender, receiver, plannerPolicy),
 	)
 	wait(true)
 	return report()
@@ -1408,6 +1408,81 @@ func ReplicationPlaceholderEncryption__EncryptOnReceiverUseCase__WorksIfConfigur
 	require.Equal(ctx, rfsRoot, props.Get("encryptionroot"))
 }
 
+func replicationEncryptOnReceiveInvocation(ctx *platformtest.Context) replicationInvocation {
+	sfs := ctx.RootDataset + "/sender"
+	return replicationInvocation{
+		sjid:      endpoint.MustMakeJobID("sender-job"),
+		rjid:      endpoint.MustMakeJobID("receiver-job"),
+		sfs:       sfs,
+		rfsRoot:   ctx.RootDataset + "/receiver",
+		guarantee: pdu.ReplicationConfigProtectionWithKind(pdu.ReplicationGuaranteeKind_GuaranteeResumability),
+		senderConfigHook: func(c *endpoint.SenderConfig) {
+			c.SendProperties = true
+		},
+		receiverConfigHook: func(rc *endpoint.ReceiverConfig) {
+			rc.EncryptOnReceive = true
+		},
+	}
+}
+
+func ReplicationEncryptOnReceive__PlaintextStreamsInheritEncryption(ctx *platformtest.Context) {
+
+	platformtest.Run(ctx, platformtest.PanicErr, ctx.RootDataset, `
+		CREATEROOT
+		+  "sender"
+		+  "sender@1"
+		+  "receiver" encrypted
+	`)
+
+	rep := replicationEncryptOnReceiveInvocation(ctx)
+	rfs := rep.ReceiveSideFilesystem()
+
+	// initial replication with -o encryption=on, creates the placeholders below receiver
+	r := rep.Do(ctx)
+	ctx.Logf("\n%s", pretty.Sprint(r))
+	require.Len(ctx, r.Attempts, 1)
+	require.Equal(ctx, report.AttemptDone, r.Attempts[0].State)
+	mustGetFilesystemVersion(ctx, rfs+"@1")
+
+	// incremental replication with -x encryption
+	mustSnapshot(ctx, rep.sfs+"@2")
+	r = rep.Do(ctx)
+	ctx.Logf("\n%s", pretty.Sprint(r))
+	require.Len(ctx, r.Attempts, 1)
+	require.Equal(ctx, report.AttemptDone, r.Attempts[0].State)
+	mustGetFilesystemVersion(ctx, rfs+"@2")
+
+	props, err := zfs.ZFSGet(ctx, mustDatasetPath(rfs), []string{"encryptionroot"})
+	require.NoError(ctx, err)
+	require.Equal(ctx, rep.rfsRoot, props.Get("encryptionroot"))
+}
+
+func ReplicationEncryptOnReceive__UnencryptedParentIsRefused(ctx *platformtest.Context) {
+
+	platformtest.Run(ctx, platformtest.PanicErr, ctx.RootDataset, `
+		CREATEROOT
+		+  "sender"
+		+  "sender@1"
+		+  "receiver"
+		R  zfs create -p "${ROOTDS}/receiver/${ROOTDS}"
+	`)
+
+	rep := replicationEncryptOnReceiveInvocation(ctx)
+
+	r := rep.Do(ctx)
+	ctx.Logf("\n%s", pretty.Sprint(r))
+	require.Len(ctx, r.Attempts, 1)
+	attempt := r.Attempts[0]
+	require.Nil(ctx, attempt.PlanError)
+	require.Len(ctx, attempt.Filesystems, 1)
+	afs := attempt.Filesystems[0]
+	require.Equal(ctx, report.FilesystemSteppingErrored, afs.State)
+	require.Contains(ctx, afs.StepError.Err, "cannot encrypt "+rep.ReceiveSideFilesystem()+" on receive: parent filesystem "+rep.rfsRoot+"/"+ctx.RootDataset+" is not encrypted")
+
+	_, err := zfs.ZFSGet(ctx, mustDatasetPath(rep.ReceiveSideFilesystem()), []string{"name"})
+	require.IsType(ctx, &zfs.DatasetDoesNotExist{}, err, "nothing was received unencrypted")
+}
+
 func replicationInitialImpl(ctx *platformtest.Context, iras logic.InitialReplicationAutoResolution, expectExactRfsSnaps []string) *report.Report {
 	// reverse order for snap names to expose sorting assumptions
 	platformtest.Run(ctx, platformtest.PanicErr, ctx.RootDataset, `
@@ -0,0 +1,45 @@
+package pruning
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// KeepHold keeps all snapshots that have a hold whose tag matches re.
+type KeepHold struct {
+	re *regexp.Regexp
+}
+
+var _ KeepRule = &KeepHold{}
+
+func NewKeepHold(tags string) (*KeepHold, error) {
+	if tags == "" {
+		return nil, fmt.Errorf("tags regex must not be empty")
+	}
+	re, err := regexp.Compile(tags)
+	if err != nil {
+		return nil, errors.Errorf("invalid regex %q: %s", tags, err)
+	}
+	return &KeepHold{re}, nil
+}
+
+func MustKeepHold(tags string) *KeepHold {
+	k, err := NewKeepHold(tags)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+func (k *KeepHold) KeepRule(snaps []Snapshot) []Snapshot {
+	return filterSnapList(snaps, func(s Snapshot) bool {
+		for _, tag := range s.Holds() {
+			if k.re.MatchString(tag) {
+				return false
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,57 @@
+package pruning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type heldSnap struct {
+	stubSnap
+	holds []string
+}
+
+func (s heldSnap) Holds() []string { return s.holds }
+
+func TestKeepHold(t *testing.T) {
+
+	s := func(name string, holds ...string) Snapshot {
+		return &heldSnap{stubSnap{name: name}, holds}
+	}
+
+	inputs := map[string][]Snapshot{
+		"s1": []Snapshot{
+			s("a"),
+			s("b", "admin_keep"),
+			s("c", "zrepl_STEP_J_foo", "backup"),
+			s("d", "zrepl_last_received_J_foo"),
+		},
+	}
+
+	tcs := map[string]testCase{
+		"matchingTag": {
+			inputs: inputs["s1"],
+			rules:  []KeepRule{MustKeepHold("^admin_")},
+			expDestroy: map[string]bool{
+				"a": true, "c": true, "d": true,
+			},
+		},
+		"anyMatchingTag": {
+			inputs: inputs["s1"],
+			rules:  []KeepRule{MustKeepHold("^(admin_.*|backup)$")},
+			expDestroy: map[string]bool{
+				"a": true, "d": true,
+			},
+		},
+		"noHolds": {
+			inputs:     []Snapshot{s("a"), s("b")},
+			rules:      []KeepRule{MustKeepHold(".*")},
+			expDestroy: map[string]bool{"a": true, "b": true},
+		},
+	}
+
+	testTable(tcs, t)
+
+	_, err := NewKeepHold("")
+	assert.Error(t, err)
+}
@@ -0,0 +1,74 @@
+package pruning
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// KeepSpaceBudget destroys the oldest snapshots matching re until the
+// space used by the snapshots of the filesystem is within budget.
+// The most recent matching snapshot is always kept.
+type KeepSpaceBudget struct {
+	budget uint64
+	re     *regexp.Regexp
+	fs     Filesystem
+}
+
+var _ FilesystemKeepRule = &KeepSpaceBudget{}
+
+func NewKeepSpaceBudget(budget uint64, regex string) (*KeepSpaceBudget, error) {
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, errors.Errorf("invalid regex %q: %s", regex, err)
+	}
+	return &KeepSpaceBudget{budget: budget, re: re}, nil
+}
+
+func MustKeepSpaceBudget(budget uint64, regex string) *KeepSpaceBudget {
+	k, err := NewKeepSpaceBudget(budget, regex)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+func (k *KeepSpaceBudget) ForFilesystem(fs Filesystem) KeepRule {
+	c := *k
+	c.fs = fs
+	return &c
+}
+
+func (k *KeepSpaceBudget) KeepRule(snaps []Snapshot) (destroyList []Snapshot) {
+	matching, notMatching := partitionSnapList(snaps, func(snapshot Snapshot) bool {
+		return k.re.MatchString(snapshot.Name())
+	})
+	// snaps that don't match the regex are not kept by this rule
+	destroyList = append(destroyList, notMatching...)
+
+	sort.Slice(matching, func(i, j int) bool {
+		// by date (oldest first)
+		id, jd := matching[i].Date(), matching[j].Date()
+		if !id.Equal(jd) {
+			return id.Before(jd)
+		}
+		// then lexicographically ascending (e.g. a, b)
+		return strings.Compare(matching[i].Name(), matching[j].Name()) == -1
+	})
+
+	// Destroying a snapshot frees at least its used space.
+	// Space shared by multiple snapshots is only freed once all of them are destroyed,
+	// hence remaining is an upper bound for the space used after destroying the snapshots in destroyList.
+	remaining := k.fs.UsedBySnapshots
+	for i := 0; i < len(matching)-1 && remaining > k.budget; i++ {
+		destroyList = append(destroyList, matching[i])
+		if used := matching[i].Used(); used < remaining {
+			remaining -= used
+		} else {
+			remaining = 0
+		}
+	}
+	return destroyList
+}
@@ -0,0 +1,94 @@
+package pruning
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type usedSnap struct {
+	stubSnap
+	used uint64
+}
+
+func (s usedSnap) Used() uint64 { return s.used }
+
+func TestKeepSpaceBudget(t *testing.T) {
+
+	o := func(minutes int) time.Time {
+		return time.Unix(123, 0).Add(time.Duration(minutes) * time.Minute)
+	}
+	s := func(name string, minutes int, used uint64) Snapshot {
+		return usedSnap{stubSnap{name: name, date: o(minutes)}, used}
+	}
+
+	inputs := map[string][]Snapshot{
+		"s1": []Snapshot{
+			s("zrepl_4", 40, 10),
+			s("zrepl_1", 10, 30),
+			s("zrepl_3", 30, 20),
+			s("manual_1", 15, 100),
+			s("zrepl_2", 20, 10),
+		},
+		"shared": []Snapshot{
+			s("zrepl_1", 10, 0),
+			s("zrepl_2", 20, 0),
+			s("zrepl_3", 30, 0),
+		},
+	}
+
+	fs := func(usedBySnapshots uint64) Filesystem { return Filesystem{UsedBySnapshots: usedBySnapshots} }
+
+	tcs := map[string]testCase{
+		"withinBudget": {
+			inputs:     inputs["s1"],
+			rules:      RulesForFilesystem([]KeepRule{MustKeepSpaceBudget(200, "")}, fs(170)),
+			expDestroy: map[string]bool{},
+		},
+		"oldestFirst": {
+			inputs: inputs["s1"],
+			rules:  RulesForFilesystem([]KeepRule{MustKeepSpaceBudget(50, "")}, fs(170)),
+			expDestroy: map[string]bool{
+				"zrepl_1": true, "manual_1": true,
+			},
+		},
+		"regexNotMatchingIsDestroyed": {
+			inputs: inputs["s1"],
+			rules:  RulesForFilesystem([]KeepRule{MustKeepSpaceBudget(50, "^zrepl_")}, fs(170)),
+			expDestroy: map[string]bool{
+				"manual_1": true, "zrepl_1": true, "zrepl_2": true, "zrepl_3": true,
+			},
+		},
+		"regexWithOtherRule": {
+			inputs: inputs["s1"],
+			rules: RulesForFilesystem([]KeepRule{
+				MustKeepSpaceBudget(50, "^zrepl_"),
+				MustKeepRegex("^manual_", false),
+			}, fs(170)),
+			expDestroy: map[string]bool{
+				"zrepl_1": true, "zrepl_2": true, "zrepl_3": true,
+			},
+		},
+		"sharedSpaceKeepsMostRecent": {
+			inputs: inputs["shared"],
+			rules:  RulesForFilesystem([]KeepRule{MustKeepSpaceBudget(10, "")}, fs(100)),
+			expDestroy: map[string]bool{
+				"zrepl_1": true, "zrepl_2": true,
+			},
+		},
+		"unknownUsageKeepsAll": {
+			inputs:     inputs["s1"],
+			rules:      []KeepRule{MustKeepSpaceBudget(0, "")},
+			expDestroy: map[string]bool{},
+		},
+	}
+
+	testTable(tcs, t)
+
+	t.Run("ForFilesystemDoesNotModifyRule", func(t *testing.T) {
+		k := MustKeepSpaceBudget(50, "")
+		k.ForFilesystem(fs(170))
+		assert.Empty(t, k.KeepRule(inputs["s1"]))
+	})
+}
@@ -0,0 +1,120 @@
+package pruning
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TierCounts specifies how many calendar periods of each tier are retained.
+// A count of 0 disables the tier.
+type TierCounts struct {
+	Hourly, Daily, Weekly, Monthly, Yearly int
+}
+
+type tier struct {
+	name  string
+	count int
+	// period returns a key that is equal for all times in the same calendar period
+	period func(t time.Time) [3]int
+}
+
+func hourPeriod(t time.Time) [3]int  { return [3]int{t.Year(), t.YearDay(), t.Hour()} }
+func dayPeriod(t time.Time) [3]int   { return [3]int{t.Year(), t.YearDay(), 0} }
+func weekPeriod(t time.Time) [3]int  { y, w := t.ISOWeek(); return [3]int{y, w, 0} }
+func monthPeriod(t time.Time) [3]int { return [3]int{t.Year(), int(t.Month()), 0} }
+func yearPeriod(t time.Time) [3]int  { return [3]int{t.Year(), 0, 0} }
+
+// KeepTiered keeps the most recent snapshot matching re of each of the
+// most recent calendar periods (hours, days, ISO weeks, months, years)
+// that contain such a snapshot, up to the tier's count.
+// Periods are determined from the snapshot creation time in local time.
+// A snapshot is kept if any tier keeps it.
+type KeepTiered struct {
+	tiers []tier
+	re    *regexp.Regexp
+	loc   *time.Location
+}
+
+func NewKeepTiered(counts TierCounts, regex string) (*KeepTiered, error) {
+	k := &KeepTiered{loc: time.Local}
+	for _, t := range []tier{
+		{"hourly", counts.Hourly, hourPeriod},
+		{"daily", counts.Daily, dayPeriod},
+		{"weekly", counts.Weekly, weekPeriod},
+		{"monthly", counts.Monthly, monthPeriod},
+		{"yearly", counts.Yearly, yearPeriod},
+	} {
+		if t.count < 0 {
+			return nil, errors.Errorf("tier %q must not be negative, got %d", t.name, t.count)
+		}
+		if t.count > 0 {
+			k.tiers = append(k.tiers, t)
+		}
+	}
+	if len(k.tiers) == 0 {
+		return nil, errors.New("must specify a positive count for at least one tier")
+	}
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, errors.Errorf("invalid regex %q: %s", regex, err)
+	}
+	k.re = re
+	return k, nil
+}
+
+func MustKeepTiered(counts TierCounts, regex string) *KeepTiered {
+	k, err := NewKeepTiered(counts, regex)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+func (k *KeepTiered) KeepRule(snaps []Snapshot) (destroyList []Snapshot) {
+	matching, notMatching := partitionSnapList(snaps, func(snapshot Snapshot) bool {
+		return k.re.MatchString(snapshot.Name())
+	})
+	// snaps that don't match the regex are not kept by this rule
+	destroyList = append(destroyList, notMatching...)
+
+	if len(matching) == 0 {
+		return destroyList
+	}
+
+	sort.Slice(matching, func(i, j int) bool {
+		// by date (youngest first)
+		id, jd := matching[i].Date(), matching[j].Date()
+		if !id.Equal(jd) {
+			return id.After(jd)
+		}
+		// then lexicographically descending (e.g. b, a)
+		return strings.Compare(matching[i].Name(), matching[j].Name()) == 1
+	})
+
+	keep := make([]bool, len(matching))
+	for _, t := range k.tiers {
+		seen := make(map[[3]int]bool, t.count)
+		for i, s := range matching {
+			p := t.period(s.Date().In(k.loc))
+			if seen[p] {
+				continue
+			}
+			if len(seen) == t.count {
+				break
+			}
+			seen[p] = true
+			keep[i] = true
+		}
+	}
+
+	for i, s := range matching {
+		if !keep[i] {
+			destroyList = append(destroyList, s)
+		}
+	}
+	return destroyList
+}
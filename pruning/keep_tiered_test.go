@@ -0,0 +1,85 @@
+package pruning
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeepTiered(t *testing.T) {
+
+	d := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2020, month, day, hour, min, 0, 0, time.UTC)
+	}
+	s := func(name string, date time.Time) Snapshot {
+		return stubSnap{name: name, date: date}
+	}
+	tiered := func(c TierCounts, regex string) *KeepTiered {
+		k := MustKeepTiered(c, regex)
+		k.loc = time.UTC
+		return k
+	}
+
+	inputs := map[string][]Snapshot{
+		"s1": []Snapshot{
+			s("a_0100", d(3, 2, 1, 0)),
+			s("a_0130", d(3, 2, 1, 30)),
+			s("a_0200", d(3, 2, 2, 0)),
+			s("a_0300", d(3, 2, 3, 0)),
+			s("b_0315", d(3, 2, 3, 15)),
+			s("a_yday", d(3, 1, 12, 0)), // sunday of the previous ISO week
+			s("a_feb", d(2, 15, 12, 0)),
+		},
+	}
+
+	tcs := map[string]testCase{
+		"hourly": {
+			inputs: inputs["s1"],
+			rules:  []KeepRule{tiered(TierCounts{Hourly: 2}, "")},
+			expDestroy: map[string]bool{
+				"a_0100": true, "a_0130": true, "a_0300": true, "a_yday": true, "a_feb": true,
+			},
+		},
+		"hourlyKeepsMostRecentInPeriod": {
+			inputs: inputs["s1"],
+			rules:  []KeepRule{tiered(TierCounts{Hourly: 4}, "^a_")},
+			expDestroy: map[string]bool{
+				"a_0100": true, "b_0315": true, "a_feb": true,
+			},
+		},
+		"daily": {
+			inputs: inputs["s1"],
+			rules:  []KeepRule{tiered(TierCounts{Daily: 2}, "^a_")},
+			expDestroy: map[string]bool{
+				"a_0100": true, "a_0130": true, "a_0200": true, "b_0315": true, "a_feb": true,
+			},
+		},
+		"weeklyAndMonthly": {
+			inputs: inputs["s1"],
+			rules:  []KeepRule{tiered(TierCounts{Weekly: 1, Monthly: 2}, "^a_")},
+			expDestroy: map[string]bool{
+				"a_0100": true, "a_0130": true, "a_0200": true, "b_0315": true, "a_yday": true,
+			},
+		},
+		"tiersAreCombined": {
+			inputs: inputs["s1"],
+			rules:  []KeepRule{tiered(TierCounts{Hourly: 1, Daily: 2, Yearly: 1}, "")},
+			expDestroy: map[string]bool{
+				"a_0100": true, "a_0130": true, "a_0200": true, "a_0300": true, "a_feb": true,
+			},
+		},
+		"countLargerThanPeriods": {
+			inputs:     inputs["s1"],
+			rules:      []KeepRule{tiered(TierCounts{Monthly: 10, Yearly: 10}, "")},
+			expDestroy: map[string]bool{"a_0100": true, "a_0130": true, "a_0200": true, "a_0300": true, "a_yday": true},
+		},
+	}
+
+	testTable(tcs, t)
+
+	_, err := NewKeepTiered(TierCounts{}, "")
+	assert.Error(t, err)
+	_, err = NewKeepTiered(TierCounts{Daily: 7, Hourly: -1}, "")
+	assert.Error(t, err)
+}
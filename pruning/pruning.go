@@ -17,31 +17,80 @@ type Snapshot interface {
 	Name() string
 	Replicated() bool
 	Date() time.Time
+	Used() uint64    // bytes, 0 if unknown
+	Holds() []string // hold tags, nil if unknown
+}
+
+// Properties of the filesystem whose snapshots are pruned.
+type Filesystem struct {
+	UsedBySnapshots uint64 // bytes, 0 if unknown
+}
+
+// A FilesystemKeepRule depends on the properties of the filesystem.
+// Its KeepRule method behaves as if all properties were unknown.
+type FilesystemKeepRule interface {
+	KeepRule
+	ForFilesystem(fs Filesystem) KeepRule
+}
+
+// RulesForFilesystem binds the FilesystemKeepRules in rules to fs.
+func RulesForFilesystem(rules []KeepRule, fs Filesystem) []KeepRule {
+	bound := make([]KeepRule, len(rules))
+	for i, r := range rules {
+		if fr, ok := r.(FilesystemKeepRule); ok {
+			bound[i] = fr.ForFilesystem(fs)
+		} else {
+			bound[i] = r
+		}
+	}
+	return bound
 }
 
 // The returned snapshot list is guaranteed to only contains elements of input parameter snaps
 func PruneSnapshots(snaps []Snapshot, keepRules []KeepRule) []Snapshot {
+	remove, _ := PruneSnapshotsExplain(snaps, keepRules)
+	return remove
+}
+
+// PruneSnapshotsExplain is like PruneSnapshots, but also returns, for each snapshot
+// that is not destroyed, the indices of the keepRules that keep the snapshot.
+func PruneSnapshotsExplain(snaps []Snapshot, keepRules []KeepRule) (remove []Snapshot, keptBy map[Snapshot][]int) {
 
 	if len(keepRules) == 0 {
-		return []Snapshot{}
+		return []Snapshot{}, map[Snapshot][]int{}
 	}
 
+	remByRule := make([]map[Snapshot]bool, len(keepRules))
 	remCount := make(map[Snapshot]int, len(snaps))
-	for _, r := range keepRules {
+	for i, r := range keepRules {
 		ruleRems := r.KeepRule(snaps)
+		remByRule[i] = make(map[Snapshot]bool, len(ruleRems))
 		for _, ruleRem := range ruleRems {
+			remByRule[i][ruleRem] = true
 			remCount[ruleRem]++
 		}
 	}
 
-	remove := make([]Snapshot, 0, len(snaps))
+	remove = make([]Snapshot, 0, len(snaps))
 	for snap, rc := range remCount {
 		if rc == len(keepRules) {
 			remove = append(remove, snap)
 		}
 	}
 
-	return remove
+	keptBy = make(map[Snapshot][]int, len(snaps)-len(remove))
+	for _, snap := range snaps {
+		if remCount[snap] == len(keepRules) {
+			continue
+		}
+		for i := range keepRules {
+			if !remByRule[i][snap] {
+				keptBy[snap] = append(keptBy[snap], i)
+			}
+		}
+	}
+
+	return remove, keptBy
 }
 
 func RulesFromConfig(in []config.PruningEnum) (rules []KeepRule, err error) {
@@ -65,6 +114,21 @@ func RuleFromConfig(in config.PruningEnum) (KeepRule, error) {
 		return NewKeepRegex(v.Regex, v.Negate)
 	case *config.PruneGrid:
 		return NewKeepGrid(v)
+	case *config.PruneKeepSpaceBudget:
+		if v.Budget.ToBytes() < 0 {
+			return nil, fmt.Errorf("space budget must not be negative")
+		}
+		return NewKeepSpaceBudget(uint64(v.Budget.ToBytes()), v.Regex)
+	case *config.PruneKeepHold:
+		return NewKeepHold(v.Tags)
+	case *config.PruneKeepTiered:
+		return NewKeepTiered(TierCounts{
+			Hourly:  v.Hourly,
+			Daily:   v.Daily,
+			Weekly:  v.Weekly,
+			Monthly: v.Monthly,
+			Yearly:  v.Yearly,
+		}, v.Regex)
 	default:
 		return nil, fmt.Errorf("unknown keep rule type %T", v)
 	}
@@ -20,6 +20,10 @@ func (s stubSnap) Replicated() bool { return s.replicated }
 
 func (s stubSnap) Date() time.Time { return s.date }
 
+func (s stubSnap) Used() uint64 { return 0 }
+
+func (s stubSnap) Holds() []string { return nil }
+
 type testCase struct {
 	inputs     []Snapshot
 	rules      []KeepRule
@@ -147,3 +151,21 @@ func TestPruneSnapshots(t *testing.T) {
 
 	testTable(tcs, t)
 }
+
+func TestPruneSnapshotsExplain(t *testing.T) {
+	foo1, foo2, bar, baz := stubSnap{name: "foo_1"}, stubSnap{name: "foo_2"}, stubSnap{name: "bar_1"}, stubSnap{name: "baz_2"}
+	remove, keptBy := PruneSnapshotsExplain([]Snapshot{foo1, foo2, bar, baz}, []KeepRule{
+		MustKeepRegex("^foo_", false),
+		MustKeepRegex("_1$", false),
+	})
+	assert.Equal(t, []Snapshot{baz}, remove)
+	assert.Equal(t, map[Snapshot][]int{
+		foo1: {0, 1},
+		foo2: {0},
+		bar:  {1},
+	}, keptBy)
+
+	remove, keptBy = PruneSnapshotsExplain([]Snapshot{foo1}, nil)
+	assert.Empty(t, remove)
+	assert.Empty(t, keptBy)
+}
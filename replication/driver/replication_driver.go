@@ -3,6 +3,8 @@ package driver
 import (
 	"context"
 	"fmt"
+	"math"
+	"math/rand"
 	"net"
 	"sort"
 	"strings"
@@ -18,6 +20,7 @@ import (
 	"github.com/zrepl/zrepl/daemon/logging/trace"
 	"github.com/zrepl/zrepl/zfs"
 
+	"github.com/zrepl/zrepl/replication/logic/pdu"
 	"github.com/zrepl/zrepl/replication/report"
 	"github.com/zrepl/zrepl/util/chainlock"
 )
@@ -70,6 +73,11 @@ type run struct {
 	waitReconnect      interval
 	waitReconnectError *timedError
 
+	// backoff delay before the next attempt
+	waitRetry interval
+
+	maxAttempts int
+
 	// the attempts attempted so far:
 	// All but the last in this slice must have finished with some errors.
 	// The last attempt may not be finished and may not have errors.
@@ -94,6 +102,10 @@ type attempt struct {
 	// if both are nil, it must be assumed that Planner.Plan is active
 	planErr *timedError
 	fss     []*fs
+
+	// classification of the most recent error, valid once the attempt has finished with errors
+	errClassValid bool
+	errClass      errorClass
 }
 
 type timedError struct {
@@ -175,6 +187,13 @@ type fs struct {
 		// if step >= len(steps), no more work needs to be done
 		step int
 	}
+
+	// retries after retryable step errors within this attempt
+	retry struct {
+		count   int
+		lastErr *timedError
+		errs    []*report.FilesystemError
+	}
 }
 
 type step struct {
@@ -189,6 +208,22 @@ type Config struct {
 	StepQueueConcurrency     int           `validate:"gte=1"`
 	MaxAttempts              int           `validate:"eq=-1|gt=0"`
 	ReconnectHardFailTimeout time.Duration `validate:"gt=0"`
+
+	// Delay between an attempt that failed with a retryable error and the next attempt.
+	// The delay is multiplied by RetryBackoffMultiplier after each failed attempt,
+	// but never exceeds RetryMaxDelay (if non-zero).
+	// A zero RetryInitialDelay retries immediately.
+	RetryInitialDelay time.Duration `validate:"gte=0"`
+	RetryMaxDelay     time.Duration `validate:"gte=0"`
+	// Zero is treated like 1, i.e., constant delay.
+	RetryBackoffMultiplier float64 `validate:"eq=0|gte=1"`
+	// Randomizes each delay by +/- RetryJitter * delay.
+	RetryJitter float64 `validate:"gte=0,lte=1"`
+
+	// Number of times a filesystem whose step failed with a retryable error
+	// is re-planned and retried within the same attempt, before the error fails the attempt.
+	// The delay before each retry is computed like the delay between attempts.
+	MaxFilesystemRetries int `validate:"gte=0"`
 }
 
 var validate = validator.New()
@@ -197,6 +232,26 @@ func (c Config) Validate() error {
 	return validate.Struct(c)
 }
 
+// The backoff delay before attempt number `failedAttempts` (counting from 0).
+// Caller must ensure failedAttempts >= 1.
+func (c Config) retryDelay(failedAttempts int) time.Duration {
+	if c.RetryInitialDelay == 0 {
+		return 0
+	}
+	multiplier := c.RetryBackoffMultiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	delay := float64(c.RetryInitialDelay) * math.Pow(multiplier, float64(failedAttempts-1))
+	if c.RetryMaxDelay > 0 && delay > float64(c.RetryMaxDelay) {
+		delay = float64(c.RetryMaxDelay)
+	}
+	if c.RetryJitter > 0 {
+		delay += delay * c.RetryJitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
 // caller must ensure config.Validate() == nil
 func Do(ctx context.Context, config Config, planner Planner) (ReportFunc, WaitFunc) {
 
@@ -207,8 +262,9 @@ func Do(ctx context.Context, config Config, planner Planner) (ReportFunc, WaitFu
 	log := getLog(ctx)
 	l := chainlock.New()
 	run := &run{
-		l:         l,
-		startedAt: time.Now(),
+		l:           l,
+		startedAt:   time.Now(),
+		maxAttempts: config.MaxAttempts,
 	}
 
 	done := make(chan struct{})
@@ -220,8 +276,32 @@ func Do(ctx context.Context, config Config, planner Planner) (ReportFunc, WaitFu
 		defer log.Debug("run ended")
 		var prev *attempt
 		mainLog := log
-		for ano := 0; ano < int(config.MaxAttempts); ano++ {
+		for ano := 0; config.MaxAttempts == -1 || ano < config.MaxAttempts; ano++ {
 			log := mainLog.WithField("attempt_number", ano)
+
+			if ano > 0 {
+				if delay := config.retryDelay(ano); delay > 0 {
+					run.waitRetry.Set(time.Now(), delay)
+					log.WithField("delay", delay).WithField("until", run.waitRetry.End()).
+						Info("wait before retrying")
+					var waitErr error
+					run.l.DropWhile(func() {
+						t := time.NewTimer(delay)
+						defer t.Stop()
+						select {
+						case <-t.C:
+						case <-ctx.Done():
+							waitErr = ctx.Err()
+						}
+					})
+					run.waitRetry.SetZero()
+					if waitErr != nil {
+						log.WithError(waitErr).Info("context error while waiting to retry")
+						return
+					}
+				}
+			}
+
 			log.Debug("start attempt")
 
 			run.waitReconnect.SetZero()
@@ -265,6 +345,11 @@ func Do(ctx context.Context, config Config, planner Planner) (ReportFunc, WaitFu
 				break
 			}
 			log.WithError(mostRecentErr.Err).Error("most recent error in this attempt")
+			cur.errClassValid, cur.errClass = true, mostRecentErrClass
+			if config.MaxAttempts != -1 && ano+1 >= config.MaxAttempts {
+				log.WithField("max_attempts", config.MaxAttempts).Error("maximum number of attempts reached, aborting run")
+				break
+			}
 			shouldReconnect := mostRecentErrClass == errorClassTemporaryConnectivityRelated
 			log.WithField("reconnect_decision", shouldReconnect).Debug("reconnect decision made")
 			if shouldReconnect {
@@ -451,7 +536,7 @@ func (a *attempt) doFilesystems(ctx context.Context, prevs map[*fs]*fs) {
 			// avoid explosion of tasks with name f.report().Info.Name
 			ctx, endTask := trace.WithTaskAndSpan(ctx, "repl-fs", f.report().Info.Name)
 			defer endTask()
-			f.do(ctx, stepQueue, prevs[f])
+			f.do(ctx, a.config, a.planner, stepQueue, prevs[f])
 			f.l.HoldWhile(func() {
 				// every return from f means it's unblocked...
 				f.blockedOn = report.FsBlockedOnNothing
@@ -485,12 +570,8 @@ func (f *fs) initialRepOrdWakeupChildren() {
 	}
 }
 
-func (f *fs) do(ctx context.Context, pq *stepQueue, prev *fs) {
-
-	defer f.l.Lock().Unlock()
-	defer f.initialRepOrdWakeupChildren()
-
-	// get planned steps from replication logic
+// caller must hold f.l
+func (f *fs) planSteps(ctx context.Context, pq *stepQueue) ([]*step, *timedError) {
 	var psteps []Step
 	var errTime time.Time
 	var err error
@@ -508,16 +589,43 @@ func (f *fs) do(ctx context.Context, pq *stepQueue, prev *fs) {
 		errTime = time.Now()           // no shadow
 	})
 	if err != nil {
-		f.planning.err = newTimedError(err, errTime)
-		return
+		return nil, newTimedError(err, errTime)
 	}
+	steps := make([]*step, 0, len(psteps))
 	for _, pstep := range psteps {
-		step := &step{
+		steps = append(steps, &step{
 			l:    f.l,
 			step: pstep,
+		})
+	}
+	return steps, nil
+}
+
+// Returns the index of the step in steps whose target equals the highest possible target of uncompleted,
+// or ok=false if there is no such step.
+func correlateUncompletedSteps(uncompleted, steps []*step) (_ int, ok bool) {
+	for p := len(uncompleted) - 1; p >= 0; p-- {
+		for q := len(steps) - 1; q >= 0; q-- {
+			if uncompleted[p].step.TargetEquals(steps[q].step) {
+				return q, true
+			}
 		}
-		f.planned.steps = append(f.planned.steps, step)
 	}
+	return -1, false
+}
+
+func (f *fs) do(ctx context.Context, config Config, planner Planner, pq *stepQueue, prev *fs) {
+
+	defer f.l.Lock().Unlock()
+	defer f.initialRepOrdWakeupChildren()
+
+	// get planned steps from replication logic
+	steps, planErr := f.planSteps(ctx, pq)
+	if planErr != nil {
+		f.planning.err = planErr
+		return
+	}
+	f.planned.steps = steps
 	// we're not done planning yet, f.planned.steps might still be changed by next block
 	// => don't set f.planning.done just yet
 	f.debug("initial len(fs.planned.steps) = %d", len(f.planned.steps))
@@ -528,33 +636,20 @@ func (f *fs) do(ctx context.Context, pq *stepQueue, prev *fs) {
 		f.debug("attempting to correlate plan with previous attempt to find out what is left to do")
 		// find the highest of the previously uncompleted steps for which we can also find a step
 		// in our current plan
-		prevUncompleted := prev.planned.steps[prev.planned.step:]
-		var target struct{ prev, cur int }
-		target.prev = -1
-		target.cur = -1
-	out:
-		for p := len(prevUncompleted) - 1; p >= 0; p-- {
-			for q := len(f.planned.steps) - 1; q >= 0; q-- {
-				if prevUncompleted[p].step.TargetEquals(f.planned.steps[q].step) {
-					target.prev = p
-					target.cur = q
-					break out
-				}
-			}
-		}
-		if target.prev == -1 || target.cur == -1 {
+		target, ok := correlateUncompletedSteps(prev.planned.steps[prev.planned.step:], f.planned.steps)
+		if !ok {
 			f.debug("no correlation possible between previous attempt and this attempt's plan")
 			f.planning.err = newTimedError(fmt.Errorf("cannot correlate previously failed attempt to current plan"), time.Now())
 			return
 		}
 
-		f.planned.steps = f.planned.steps[0:target.cur]
+		f.planned.steps = f.planned.steps[0:target]
 		f.debug("found correlation, new steps are len(fs.planned.steps) = %d", len(f.planned.steps))
 	} else {
 		f.debug("previous attempt does not exist or did not finish planning, no correlation possible, taking this attempt's plan as is")
 	}
 
-	// now we are done planning (f.planned.steps won't change from now on)
+	// now we are done planning (f.planned.steps only changes if a step is retried, see retryStep)
 	f.planning.done = true
 
 	// wait for parents' initial replication
@@ -639,7 +734,10 @@ func (f *fs) do(ctx context.Context, pq *stepQueue, prev *fs) {
 	f.debug("all parents ready, start replication %s", parents)
 
 	// do our steps
-	for i, s := range f.planned.steps {
+	for f.planned.step < len(f.planned.steps) {
+		s := f.planned.steps[f.planned.step]
+		var err error
+		var errTime time.Time
 		// lock must not be held while executing step in order for reporting to work
 		f.l.DropWhile(func() {
 			// wait for parallel replication
@@ -654,16 +752,104 @@ func (f *fs) do(ctx context.Context, pq *stepQueue, prev *fs) {
 		})
 
 		if err != nil {
-			f.planned.stepErr = newTimedError(err, errTime)
-			break
+			if !f.retryStep(ctx, config, planner, pq, newTimedError(err, errTime)) {
+				break
+			}
+			continue
 		}
-		f.planned.step = i + 1 // fs.planned.step must be == len(fs.planned.steps) if all went OK
+		f.planned.step++ // fs.planned.step must be == len(fs.planned.steps) if all went OK
 
 		f.initialRepOrdWakeupChildren()
 	}
 
 }
 
+// retryStep re-plans f after the current step failed with stepErr and replaces
+// the uncompleted steps with the corresponding part of the new plan, so that
+// only f is retried instead of the entire attempt.
+// Before re-planning, it waits for the retry delay and for the planner's connectivity,
+// for at most config.ReconnectHardFailTimeout.
+//
+// Returns false if f must not be retried, in which case f.planned.stepErr is set.
+//
+// caller must hold f.l
+func (f *fs) retryStep(ctx context.Context, config Config, planner Planner, pq *stepQueue, stepErr *timedError) bool {
+	err := stepErr
+	for {
+		if classifyError(err.Err) != errorClassTemporaryConnectivityRelated {
+			f.planned.stepErr = err
+			return false
+		}
+		if f.retry.count >= config.MaxFilesystemRetries {
+			f.debug("retryable error, but maximum number of filesystem retries (%d) reached", config.MaxFilesystemRetries)
+			f.planned.stepErr = err
+			return false
+		}
+		f.retry.count++
+		f.retry.lastErr = err
+		retryErr := &report.FilesystemError{Err: err.Err.Error(), Time: err.Time}
+		if f.planned.step < len(f.planned.steps) {
+			retryErr.Step = f.planned.steps[f.planned.step].step.ReportInfo()
+		}
+		f.retry.errs = append(f.retry.errs, retryErr)
+		f.debug("retryable error, retry #%d: %s", f.retry.count, err.Err)
+
+		if delay := config.retryDelay(f.retry.count); delay > 0 {
+			f.blockedOn = report.FsBlockedOnRetryWait
+			var waitErr error
+			f.l.DropWhile(func() {
+				t := time.NewTimer(delay)
+				defer t.Stop()
+				select {
+				case <-t.C:
+				case <-ctx.Done():
+					waitErr = ctx.Err()
+				}
+			})
+			f.blockedOn = report.FsBlockedOnNothing
+			if waitErr != nil {
+				f.planned.stepErr = newTimedError(waitErr, time.Now())
+				return false
+			}
+		}
+
+		// the error is connectivity-related, re-planning fails likewise until the peer is reachable again
+		f.blockedOn = report.FsBlockedOnReconnect
+		var connectErr error
+		f.l.DropWhile(func() {
+			ctx, cancel := context.WithTimeout(ctx, config.ReconnectHardFailTimeout)
+			defer cancel()
+			connectErr = planner.WaitForConnectivity(ctx)
+		})
+		f.blockedOn = report.FsBlockedOnNothing
+		if connectErr != nil {
+			f.debug("reconnecting failed, not retrying: %s", connectErr)
+			f.planned.stepErr = newTimedError(connectErr, time.Now())
+			return false
+		}
+
+		steps, planErr := f.planSteps(ctx, pq)
+		if planErr != nil {
+			err = planErr
+			continue
+		}
+		// unlike between attempts, the new plan starts at the receiver's current state
+		// and must include the step for the failed step's target
+		target, ok := correlateUncompletedSteps(f.planned.steps[f.planned.step:], steps)
+		if !ok {
+			f.planned.stepErr = newTimedError(fmt.Errorf("cannot correlate failed step to new plan"), time.Now())
+			return false
+		}
+		steps = steps[0 : target+1]
+		done := f.planned.steps[:f.planned.step]
+		f.planned.steps = make([]*step, 0, len(done)+len(steps))
+		f.planned.steps = append(f.planned.steps, done...)
+		f.planned.steps = append(f.planned.steps, steps...)
+		f.debug("re-planned, new steps are len(fs.planned.steps) = %d", len(f.planned.steps))
+		return true
+	}
+}
+
 // caller must hold lock l
 func (r *run) report() *report.Report {
 	report := &report.Report{
@@ -673,6 +859,9 @@ func (r *run) report() *report.Report {
 		WaitReconnectSince: r.waitReconnect.begin,
 		WaitReconnectUntil: r.waitReconnect.end,
 		WaitReconnectError: r.waitReconnectError.IntoReportError(),
+		WaitRetrySince:     r.waitRetry.begin,
+		WaitRetryUntil:     r.waitRetry.end,
+		MaxAttempts:        r.maxAttempts,
 	}
 	for i := range report.Attempts {
 		report.Attempts[i] = r.attempts[i].report()
@@ -718,6 +907,10 @@ func (a *attempt) report() *report.AttemptReport {
 	}
 	r.State = state
 
+	if a.errClassValid {
+		r.ErrorClass = a.errClass.reportErrorClass()
+	}
+
 	return r
 }
 
@@ -745,6 +938,9 @@ func (f *fs) report() *report.FilesystemReport {
 		StepError:   f.planned.stepErr.IntoReportError(),
 		Steps:       make([]*report.StepReport, len(f.planned.steps)),
 		CurrentStep: f.planned.step,
+		Retries:     f.retry.count,
+		RetryError:  f.retry.lastErr.IntoReportError(),
+		RetryErrors: append([]*report.FilesystemError(nil), f.retry.errs...),
 	}
 	for i := range r.Steps {
 		r.Steps[i] = f.planned.steps[i].report()
@@ -766,8 +962,39 @@ type errorClass int
 const (
 	errorClassPermanent errorClass = iota
 	errorClassTemporaryConnectivityRelated
+	// the receiving side refuses to receive until space is freed up, retrying is futile
+	errorClassReceiverLowOnSpace
 )
 
+func (c errorClass) reportErrorClass() report.AttemptErrorClass {
+	switch c {
+	case errorClassPermanent:
+		return report.AttemptErrorPermanent
+	case errorClassTemporaryConnectivityRelated:
+		return report.AttemptErrorRetryable
+	case errorClassReceiverLowOnSpace:
+		return report.AttemptErrorReceiverLowOnSpace
+	default:
+		panic(fmt.Sprintf("unknown error class %s", c))
+	}
+}
+
+func classifyError(err error) errorClass {
+	if neterr, ok := err.(net.Error); ok && neterr.Timeout() {
+		return errorClassTemporaryConnectivityRelated
+	}
+	if st, ok := status.FromError(err); ok && st.Code() == codes.Unavailable {
+		// technically, codes.Unavailable could be returned by the gRPC endpoint, indicating overload, etc.
+		// for now, let's assume it only happens for connectivity issues, as specified in
+		// https://grpc.io/grpc/core/md_doc_statuscodes.html
+		return errorClassTemporaryConnectivityRelated
+	}
+	if pdu.IsReceiverLowOnSpaceError(err) {
+		return errorClassReceiverLowOnSpace
+	}
+	return errorClassPermanent
+}
+
 type errorReport struct {
 	flattened []*timedError
 	// sorted DESCending by err time
@@ -797,18 +1024,7 @@ func (a *attempt) errorReport() *errorReport {
 			r.byClass[class] = errs
 		}
 		for _, err := range r.flattened {
-			if neterr, ok := err.Err.(net.Error); ok && neterr.Timeout() {
-				putClass(err, errorClassTemporaryConnectivityRelated)
-				continue
-			}
-			if st, ok := status.FromError(err.Err); ok && st.Code() == codes.Unavailable {
-				// technically, codes.Unavailable could be returned by the gRPC endpoint, indicating overload, etc.
-				// for now, let's assume it only happens for connectivity issues, as specified in
-				// https://grpc.io/grpc/core/md_doc_statuscodes.html
-				putClass(err, errorClassTemporaryConnectivityRelated)
-				continue
-			}
-			putClass(err, errorClassPermanent)
+			putClass(err, classifyError(err.Err))
 		}
 		for _, errs := range r.byClass {
 			sort.Slice(errs, func(i, j int) bool {
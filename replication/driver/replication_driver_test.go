@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/zrepl/zrepl/daemon/logging/trace"
 
+	"github.com/zrepl/zrepl/replication/logic/pdu"
 	"github.com/zrepl/zrepl/replication/report"
 
 	"github.com/stretchr/testify/assert"
@@ -222,3 +224,245 @@ func TestReplication(t *testing.T) {
 	}
 
 }
+
+func TestConfigRetryDelay(t *testing.T) {
+	c := Config{
+		RetryInitialDelay:      10 * time.Second,
+		RetryMaxDelay:          1 * time.Minute,
+		RetryBackoffMultiplier: 2,
+	}
+	assert.Equal(t, 10*time.Second, c.retryDelay(1))
+	assert.Equal(t, 20*time.Second, c.retryDelay(2))
+	assert.Equal(t, 40*time.Second, c.retryDelay(3))
+	assert.Equal(t, 1*time.Minute, c.retryDelay(4), "must be capped by RetryMaxDelay")
+
+	c.RetryBackoffMultiplier = 0
+	assert.Equal(t, 10*time.Second, c.retryDelay(3), "zero multiplier means constant delay")
+
+	c.RetryInitialDelay = 0
+	assert.Equal(t, time.Duration(0), c.retryDelay(3))
+
+	c = Config{
+		RetryInitialDelay:      10 * time.Second,
+		RetryBackoffMultiplier: 1,
+		RetryJitter:            0.5,
+	}
+	for i := 0; i < 100; i++ {
+		d := c.retryDelay(1)
+		assert.True(t, d >= 5*time.Second && d <= 15*time.Second, "%s", d)
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "mock timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// flakyPlanner's filesystems replicate snapshots 1..numSnaps, one step per snapshot.
+type flakyPlanner struct {
+	fss []*flakyFS
+	// returned by WaitForConnectivity, which counts its calls in waits
+	connectErr error
+	waits      int32
+}
+
+func (p *flakyPlanner) Plan(ctx context.Context) ([]FS, error) {
+	fss := make([]FS, len(p.fss))
+	for i := range p.fss {
+		fss[i] = p.fss[i]
+	}
+	return fss, nil
+}
+
+func (p *flakyPlanner) WaitForConnectivity(context.Context) error {
+	atomic.AddInt32(&p.waits, 1)
+	return p.connectErr
+}
+
+type flakyFS struct {
+	name     string
+	numSnaps int
+
+	mtx sync.Mutex
+	// the most recent snapshot on the receiver
+	received int
+	// fail the steps to these snapshots with the given error, once
+	failures map[int]error
+	plans    int
+}
+
+func (f *flakyFS) EqualToPreviousAttempt(other FS) bool {
+	return f.name == other.(*flakyFS).name
+}
+
+func (f *flakyFS) PlanFS(ctx context.Context) ([]Step, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.plans++
+	var steps []Step
+	for i := f.received + 1; i <= f.numSnaps; i++ {
+		steps = append(steps, &flakyStep{f, i})
+	}
+	return steps, nil
+}
+
+func (f *flakyFS) ReportInfo() *report.FilesystemInfo {
+	return &report.FilesystemInfo{Name: f.name}
+}
+
+type flakyStep struct {
+	fs *flakyFS
+	to int
+}
+
+func (s *flakyStep) Step(ctx context.Context) error {
+	s.fs.mtx.Lock()
+	defer s.fs.mtx.Unlock()
+	if err, ok := s.fs.failures[s.to]; ok {
+		delete(s.fs.failures, s.to)
+		return err
+	}
+	s.fs.received = s.to
+	return nil
+}
+
+func (s *flakyStep) TargetEquals(other Step) bool {
+	return s.to == other.(*flakyStep).to
+}
+
+func (s *flakyStep) TargetDate() time.Time {
+	return time.Unix(int64(s.to), 0)
+}
+
+func (s *flakyStep) ReportInfo() *report.StepInfo {
+	return &report.StepInfo{To: fmt.Sprintf("@%d", s.to)}
+}
+
+func TestReplicationFilesystemRetry(t *testing.T) {
+
+	ctx := context.Background()
+	defer trace.WithTaskFromStackUpdateCtx(&ctx)()
+
+	flaky := &flakyFS{name: "zroot/flaky", numSnaps: 3, failures: map[int]error{2: timeoutError{}}}
+	permanent := &flakyFS{name: "zroot/permanent", numSnaps: 2, failures: map[int]error{1: fmt.Errorf("mock permanent error")}}
+	ok := &flakyFS{name: "zroot/ok", numSnaps: 2}
+	p := &flakyPlanner{fss: []*flakyFS{flaky, permanent, ok}}
+
+	getReport, wait := Do(ctx, Config{
+		StepQueueConcurrency:     1,
+		MaxAttempts:              1,
+		ReconnectHardFailTimeout: 1 * time.Second,
+		MaxFilesystemRetries:     1,
+	}, p)
+	wait(true)
+
+	rep := getReport()
+	require.Len(t, rep.Attempts, 1)
+	fsReps := make(map[string]*report.FilesystemReport)
+	for _, fs := range rep.Attempts[0].Filesystems {
+		fsReps[fs.Info.Name] = fs
+	}
+
+	// only the flaky filesystem is re-planned, and it continues where it left off
+	r := fsReps["zroot/flaky"]
+	assert.Equal(t, report.FilesystemDone, r.State)
+	assert.Equal(t, 1, r.Retries)
+	require.NotNil(t, r.RetryError)
+	assert.Equal(t, "mock timeout", r.RetryError.Err)
+	require.Len(t, r.RetryErrors, 1)
+	assert.Equal(t, "mock timeout", r.RetryErrors[0].Err)
+	assert.Equal(t, "@2", r.RetryErrors[0].Step.To)
+	assert.Equal(t, r.RetryErrors, r.Errors(), "the retry succeeded")
+	assert.Equal(t, 3, flaky.received)
+	assert.Equal(t, 2, flaky.plans)
+	assert.Len(t, r.Steps, 3)
+	assert.Equal(t, 3, r.CurrentStep)
+
+	// permanent errors are not retried
+	r = fsReps["zroot/permanent"]
+	assert.Equal(t, report.FilesystemSteppingErrored, r.State)
+	assert.Equal(t, 0, r.Retries)
+	assert.Equal(t, 1, permanent.plans)
+	errs := r.Errors()
+	require.Len(t, errs, 1)
+	assert.Equal(t, "mock permanent error", errs[0].Err)
+	assert.Equal(t, "@1", errs[0].Step.To)
+
+	assert.Equal(t, report.FilesystemDone, fsReps["zroot/ok"].State)
+	assert.Equal(t, 1, ok.plans)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&p.waits), "the retry waits for connectivity")
+}
+
+func TestReplicationFilesystemRetryReconnectFails(t *testing.T) {
+
+	ctx := context.Background()
+	defer trace.WithTaskFromStackUpdateCtx(&ctx)()
+
+	flaky := &flakyFS{name: "zroot/flaky", numSnaps: 2, failures: map[int]error{1: timeoutError{}}}
+	p := &flakyPlanner{fss: []*flakyFS{flaky}, connectErr: fmt.Errorf("mock peer unreachable")}
+	getReport, wait := Do(ctx, Config{
+		StepQueueConcurrency:     1,
+		MaxAttempts:              1,
+		ReconnectHardFailTimeout: 1 * time.Second,
+		MaxFilesystemRetries:     3,
+	}, p)
+	wait(true)
+
+	rep := getReport()
+	r := rep.Attempts[0].Filesystems[0]
+	assert.Equal(t, report.FilesystemSteppingErrored, r.State)
+	assert.Equal(t, 1, r.Retries, "retries are not used up while the peer is unreachable")
+	assert.Equal(t, 1, flaky.plans, "not re-planned without connectivity")
+	errs := r.Errors()
+	require.NotEmpty(t, errs)
+	assert.Equal(t, "mock peer unreachable", errs[len(errs)-1].Err)
+}
+
+func TestReplicationFilesystemRetryExhausted(t *testing.T) {
+
+	ctx := context.Background()
+	defer trace.WithTaskFromStackUpdateCtx(&ctx)()
+
+	flaky := &flakyFS{name: "zroot/flaky", numSnaps: 2, failures: map[int]error{1: timeoutError{}}}
+	getReport, wait := Do(ctx, Config{
+		StepQueueConcurrency:     1,
+		MaxAttempts:              1,
+		ReconnectHardFailTimeout: 1 * time.Second,
+		MaxFilesystemRetries:     0,
+	}, &flakyPlanner{fss: []*flakyFS{flaky}})
+	wait(true)
+
+	rep := getReport()
+	require.Len(t, rep.Attempts, 1)
+	r := rep.Attempts[0].Filesystems[0]
+	assert.Equal(t, report.FilesystemSteppingErrored, r.State)
+	assert.Equal(t, 0, r.Retries)
+	assert.Equal(t, 1, flaky.plans)
+	assert.Equal(t, report.AttemptErrorRetryable, rep.Attempts[0].ErrorClass)
+}
+
+func TestReplicationReceiverLowOnSpace(t *testing.T) {
+
+	ctx := context.Background()
+	defer trace.WithTaskFromStackUpdateCtx(&ctx)()
+
+	// the error of a remote receiver, which reaches the driver as a string
+	lowOnSpace := fmt.Errorf("server error: %s, refusing to receive until space is freed up", pdu.ReceiverLowOnSpaceMessage)
+	full := &flakyFS{name: "zroot/full", numSnaps: 2, failures: map[int]error{1: lowOnSpace}}
+	getReport, wait := Do(ctx, Config{
+		StepQueueConcurrency:     1,
+		MaxAttempts:              3,
+		ReconnectHardFailTimeout: 1 * time.Second,
+		MaxFilesystemRetries:     1,
+	}, &flakyPlanner{fss: []*flakyFS{full}})
+	wait(true)
+
+	rep := getReport()
+	require.Len(t, rep.Attempts, 1, "the run is not retried")
+	assert.Equal(t, report.AttemptErrorReceiverLowOnSpace, rep.Attempts[0].ErrorClass)
+	r := rep.Attempts[0].Filesystems[0]
+	assert.Equal(t, report.FilesystemSteppingErrored, r.State)
+	assert.Equal(t, 0, r.Retries, "the filesystem is not retried")
+	assert.Equal(t, 1, full.plans)
+}
@@ -167,3 +167,59 @@ func TestIncrementalPath_BookmarkSupport(t *testing.T) {
 	})
 
 }
+
+func TestVerify(t *testing.T) {
+
+	l := fsvlist
+
+	// in sync, including bookmarks, older sender snapshots and newer not-yet-replicated sender snapshots
+	r := Verify(l("@b,2", "@c,3"), l("@a,1", "@b,2", "#c,3", "@c,3", "@d,4"), 3, true)
+	assert.True(t, r.OK())
+	assert.Empty(t, r.Extra)
+
+	// intermediate snapshot not replicated (skip_intermediates) or pruned on the receiver, extra snapshot destroyed on sender
+	r = Verify(l("@x,0", "@b,2", "@d,4"), l("@b,2", "@c,3", "@d,4", "@e,5"), 4, true)
+	assert.True(t, r.OK())
+	assert.Empty(t, r.Missing)
+	assert.Equal(t, l("@c,3"), r.NotPresent)
+	assert.Equal(t, l("@x,0"), r.Extra)
+
+	// the receiver lacks the replicated snapshot
+	r = Verify(l("@a,1", "@b,2"), l("@a,1", "@b,2", "@c,3", "@d,4"), 3, true)
+	assert.False(t, r.OK())
+	assert.Equal(t, l("@c,3"), r.Missing)
+	assert.Empty(t, r.NotPresent)
+
+	// replication cursor only exists as a bookmark on the sender
+	r = Verify(l("@a,1"), l("@a,1", "@b,2", "#c,3", "@d,4"), 3, true)
+	assert.Equal(t, l("#c,3"), r.Missing)
+	assert.Equal(t, l("@b,2"), r.NotPresent)
+	r = Verify(l("@a,1", "@c,3"), l("@a,1", "@b,2", "#c,3", "@d,4"), 3, true)
+	assert.True(t, r.OK())
+
+	// no replication cursor: use most recent common snapshot
+	r = Verify(l("@a,1", "@c,3"), l("@a,1", "@b,2", "@c,3", "@d,4"), 0, false)
+	assert.True(t, r.OK())
+	assert.Equal(t, l("@b,2"), r.NotPresent)
+
+	// diverged: same name, different guid
+	diverged := l("@b,2")[0]
+	diverged.Guid = 20
+	r = Verify([]*FilesystemVersion{l("@a,1")[0], diverged}, l("@a,1", "@b,2"), 0, false)
+	require.Len(t, r.Diverged, 1)
+	assert.Equal(t, uint64(2), r.Diverged[0].Sender.Guid)
+	assert.Equal(t, uint64(20), r.Diverged[0].Receiver.Guid)
+	assert.Empty(t, r.Missing)
+	assert.False(t, r.OK())
+
+	// out of order: receiver has b (sender txg 2) before a (sender txg 1)
+	ra, rb := l("@a,1")[0], l("@b,2")[0]
+	ra.CreateTXG, rb.CreateTXG = 20, 10
+	r = Verify([]*FilesystemVersion{ra, rb}, l("@a,1", "@b,2"), 2, true)
+	assert.Equal(t, []*FilesystemVersion{ra}, r.OutOfOrder)
+	assert.False(t, r.OK())
+
+	// nothing in common
+	r = Verify(l(), l("@a,1"), 0, false)
+	assert.True(t, r.OK())
+}
@@ -0,0 +1,119 @@
+package diff
+
+import (
+	. "github.com/zrepl/zrepl/replication/logic/pdu"
+)
+
+// VerifyResult describes how the snapshots of a filesystem on the receiving side
+// deviate from those on the sending side.
+type VerifyResult struct {
+	// The most recent replicated version on the sender (a snapshot, or a bookmark if the sender
+	// has destroyed the snapshot) if the receiver does not have its snapshot.
+	Missing []*FilesystemVersion
+	// Sender snapshots that are not present on the receiver, but neither older than the oldest snapshot
+	// present on both sides nor newer than the most recent replicated snapshot.
+	// This is not an error: the replication does not send intermediate snapshots with
+	// skip_intermediates, and the receiving side's pruning destroys snapshots.
+	NotPresent []*FilesystemVersion
+	// Receiver snapshots whose GUID does not exist on the sender,
+	// e.g., because the sender has already destroyed them.
+	Extra []*FilesystemVersion
+	// Receiver snapshots that have the same name as a sender snapshot but a different GUID.
+	Diverged []VerifyDiverged
+	// Receiver snapshots whose order by createtxg is inconsistent with
+	// the order of their sender counterparts.
+	OutOfOrder []*FilesystemVersion
+}
+
+type VerifyDiverged struct {
+	Sender, Receiver *FilesystemVersion
+}
+
+// OK returns true if there are no missing, diverged or out-of-order snapshots.
+// Extra and not present snapshots are not considered an error.
+func (r *VerifyResult) OK() bool {
+	return len(r.Missing) == 0 && len(r.Diverged) == 0 && len(r.OutOfOrder) == 0
+}
+
+// Verify compares the snapshots of the receiver and sender version lists.
+// Bookmarks in the lists are only used to look up replicatedGUID.
+//
+// replicatedGUID identifies the most recent version that is known to be replicated,
+// usually the replication cursor. If hasReplicatedGUID is false, the most recent
+// sender snapshot that is present on the receiver is used instead.
+func Verify(receiver, sender []*FilesystemVersion, replicatedGUID uint64, hasReplicatedGUID bool) *VerifyResult {
+	var res VerifyResult
+
+	// the sender's version of replicatedGUID, if it still exists as a snapshot or bookmark
+	var replicated *FilesystemVersion
+	if hasReplicatedGUID {
+		for _, v := range sender {
+			if v.Guid == replicatedGUID {
+				replicated = v
+				break
+			}
+		}
+	}
+
+	sender = SortVersionListByCreateTXGThenBookmarkLTSnapshot(StripBookmarksFromVersionList(sender))
+	receiver = SortVersionListByCreateTXGThenBookmarkLTSnapshot(StripBookmarksFromVersionList(receiver))
+
+	senderByGUID := make(map[uint64]*FilesystemVersion, len(sender))
+	senderByName := make(map[string]*FilesystemVersion, len(sender))
+	for _, v := range sender {
+		senderByGUID[v.Guid] = v
+		senderByName[v.Name] = v
+	}
+	receiverByGUID := make(map[uint64]*FilesystemVersion, len(receiver))
+	divergedSenderGUIDs := make(map[uint64]bool)
+
+	// the range of sender createtxgs that must be present on the receiver
+	var oldestCommonTXG, newestCommonTXG uint64
+	hasCommon := false
+	var lastSenderTXG uint64
+	for _, r := range receiver {
+		receiverByGUID[r.Guid] = r
+		s, ok := senderByGUID[r.Guid]
+		if !ok {
+			if sn, ok := senderByName[r.Name]; ok {
+				res.Diverged = append(res.Diverged, VerifyDiverged{Sender: sn, Receiver: r})
+				divergedSenderGUIDs[sn.Guid] = true
+			} else {
+				res.Extra = append(res.Extra, r)
+			}
+			continue
+		}
+		if !hasCommon {
+			oldestCommonTXG, hasCommon = s.CreateTXG, true
+		} else if s.CreateTXG <= lastSenderTXG {
+			res.OutOfOrder = append(res.OutOfOrder, r)
+		}
+		if s.CreateTXG > lastSenderTXG {
+			lastSenderTXG = s.CreateTXG
+		}
+		if s.CreateTXG > newestCommonTXG {
+			newestCommonTXG = s.CreateTXG
+		}
+	}
+	if !hasCommon {
+		return &res
+	}
+	replicatedTXG := newestCommonTXG
+	if replicated != nil {
+		replicatedTXG = replicated.CreateTXG
+		if _, ok := receiverByGUID[replicated.Guid]; !ok && !divergedSenderGUIDs[replicated.Guid] {
+			res.Missing = append(res.Missing, replicated)
+		}
+	}
+
+	for _, s := range sender {
+		if s.CreateTXG < oldestCommonTXG || s.CreateTXG > replicatedTXG || s.Guid == replicatedGUID {
+			continue
+		}
+		if _, ok := receiverByGUID[s.Guid]; !ok && !divergedSenderGUIDs[s.Guid] {
+			res.NotPresent = append(res.NotPresent, s)
+		}
+	}
+
+	return &res
+}
@@ -2,6 +2,7 @@ package pdu
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/zrepl/zrepl/zfs"
@@ -44,13 +45,19 @@ func FilesystemVersionFromZFS(fsv *zfs.FilesystemVersion) *FilesystemVersion {
 	default:
 		panic("unknown fsv.Type: " + fsv.Type)
 	}
-	return &FilesystemVersion{
-		Type:      t,
-		Name:      fsv.Name,
-		Guid:      fsv.Guid,
-		CreateTXG: fsv.CreateTXG,
-		Creation:  fsv.Creation.Format(time.RFC3339),
+	v := &FilesystemVersion{
+		Type:          t,
+		Name:          fsv.Name,
+		Guid:          fsv.Guid,
+		CreateTXG:     fsv.CreateTXG,
+		Creation:      fsv.Creation.Format(time.RFC3339),
+		Used:          fsv.Used,
+		IsCloneOrigin: fsv.IsCloneOrigin,
 	}
+	if !fsv.PruneMarkedAt.IsZero() {
+		v.PruneMarkedAt = fsv.PruneMarkedAt.Format(time.RFC3339)
+	}
+	return v
 }
 
 func FilesystemVersionCreation(t time.Time) string {
@@ -61,6 +68,14 @@ func (v *FilesystemVersion) CreationAsTime() (time.Time, error) {
 	return time.Parse(time.RFC3339, v.Creation)
 }
 
+// Returns the zero value if v is not marked for destruction.
+func (v *FilesystemVersion) PruneMarkedAtAsTime() (time.Time, error) {
+	if v.GetPruneMarkedAt() == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, v.PruneMarkedAt)
+}
+
 // implement fsfsm.FilesystemVersion
 func (v *FilesystemVersion) SnapshotTime() time.Time {
 	t, err := v.CreationAsTime()
@@ -75,12 +90,19 @@ func (v *FilesystemVersion) ZFSFilesystemVersion() (*zfs.FilesystemVersion, erro
 	if err != nil {
 		return nil, err
 	}
+	mt, err := v.PruneMarkedAtAsTime()
+	if err != nil {
+		return nil, err
+	}
 	return &zfs.FilesystemVersion{
-		Type:      v.Type.ZFSVersionType(),
-		Name:      v.Name,
-		Guid:      v.Guid,
-		CreateTXG: v.CreateTXG,
-		Creation:  ct,
+		Type:          v.Type.ZFSVersionType(),
+		Name:          v.Name,
+		Guid:          v.Guid,
+		CreateTXG:     v.CreateTXG,
+		Creation:      ct,
+		Used:          v.Used,
+		IsCloneOrigin: v.IsCloneOrigin,
+		PruneMarkedAt: mt,
 	}, nil
 }
 
@@ -90,3 +112,13 @@ func ReplicationConfigProtectionWithKind(both ReplicationGuaranteeKind) *Replica
 		Incremental: both,
 	}
 }
+
+// The message of the error that the receiving side returns from Receive if it is low on free space.
+// Errors of remote endpoints reach the active side as strings, hence the error is identified by its message.
+const ReceiverLowOnSpaceMessage = "receiving side is low on free space"
+
+// IsReceiverLowOnSpaceError returns true if err is (or wraps) the error that the receiving side
+// returns from Receive if it is low on free space.
+func IsReceiverLowOnSpaceError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), ReceiverLowOnSpaceMessage)
+}
@@ -25,6 +25,7 @@ type ReplicationClient interface {
 	ReplicationCursor(ctx context.Context, in *ReplicationCursorReq, opts ...grpc.CallOption) (*ReplicationCursorRes, error)
 	SendDry(ctx context.Context, in *SendReq, opts ...grpc.CallOption) (*SendRes, error)
 	SendCompleted(ctx context.Context, in *SendCompletedReq, opts ...grpc.CallOption) (*SendCompletedRes, error)
+	SetPruneMarks(ctx context.Context, in *SetPruneMarksReq, opts ...grpc.CallOption) (*SetPruneMarksRes, error)
 }
 
 type replicationClient struct {
@@ -98,6 +99,15 @@ func (c *replicationClient) SendCompleted(ctx context.Context, in *SendCompleted
 	return out, nil
 }
 
+func (c *replicationClient) SetPruneMarks(ctx context.Context, in *SetPruneMarksReq, opts ...grpc.CallOption) (*SetPruneMarksRes, error) {
+	out := new(SetPruneMarksRes)
+	err := c.cc.Invoke(ctx, "/Replication/SetPruneMarks", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ReplicationServer is the server API for Replication service.
 // All implementations must embed UnimplementedReplicationServer
 // for forward compatibility
@@ -109,6 +119,7 @@ type ReplicationServer interface {
 	ReplicationCursor(context.Context, *ReplicationCursorReq) (*ReplicationCursorRes, error)
 	SendDry(context.Context, *SendReq) (*SendRes, error)
 	SendCompleted(context.Context, *SendCompletedReq) (*SendCompletedRes, error)
+	SetPruneMarks(context.Context, *SetPruneMarksReq) (*SetPruneMarksRes, error)
 	mustEmbedUnimplementedReplicationServer()
 }
 
@@ -137,6 +148,9 @@ func (UnimplementedReplicationServer) SendDry(context.Context, *SendReq) (*SendR
 func (UnimplementedReplicationServer) SendCompleted(context.Context, *SendCompletedReq) (*SendCompletedRes, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SendCompleted not implemented")
 }
+func (UnimplementedReplicationServer) SetPruneMarks(context.Context, *SetPruneMarksReq) (*SetPruneMarksRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetPruneMarks not implemented")
+}
 func (UnimplementedReplicationServer) mustEmbedUnimplementedReplicationServer() {}
 
 // UnsafeReplicationServer may be embedded to opt out of forward compatibility for this service.
@@ -276,6 +290,24 @@ func _Replication_SendCompleted_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Replication_SetPruneMarks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetPruneMarksReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReplicationServer).SetPruneMarks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Replication/SetPruneMarks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReplicationServer).SetPruneMarks(ctx, req.(*SetPruneMarksReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Replication_ServiceDesc is the grpc.ServiceDesc for Replication service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -311,6 +343,10 @@ var Replication_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "SendCompleted",
 			Handler:    _Replication_SendCompleted_Handler,
 		},
+		{
+			MethodName: "SetPruneMarks",
+			Handler:    _Replication_SetPruneMarks_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "pdu.proto",
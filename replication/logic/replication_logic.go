@@ -32,6 +32,7 @@ type Endpoint interface {
 	ListFilesystems(ctx context.Context, req *pdu.ListFilesystemReq) (*pdu.ListFilesystemRes, error)
 	ListFilesystemVersions(ctx context.Context, req *pdu.ListFilesystemVersionsReq) (*pdu.ListFilesystemVersionsRes, error)
 	DestroySnapshots(ctx context.Context, req *pdu.DestroySnapshotsReq) (*pdu.DestroySnapshotsRes, error)
+	SetPruneMarks(ctx context.Context, req *pdu.SetPruneMarksReq) (*pdu.SetPruneMarksRes, error)
 	WaitForConnectivity(ctx context.Context) error
 }
 
@@ -57,9 +58,49 @@ type Planner struct {
 	sender   Sender
 	receiver Receiver
 	policy   PlannerPolicy
+	metrics  PlannerMetrics
 
-	promSecsPerState    *prometheus.HistogramVec // labels: state
-	promBytesReplicated *prometheus.CounterVec   // labels: filesystem
+	// shared by the Filesystems of all attempts
+	resumedBytesCounted *resumedBytesCounted
+}
+
+// Records the steps whose resumed bytes PlannerMetrics.BytesResumed has counted.
+//
+// The driver re-plans a filesystem after a failed step, and each attempt plans
+// new Filesystems. Both yield a new resume token for the same target,
+// whose bytes include those that have already been counted.
+type resumedBytesCounted struct {
+	mtx     sync.Mutex
+	counted map[resumedBytesCountedKey]bool
+}
+
+type resumedBytesCountedKey struct {
+	fs     string
+	toGUID uint64
+}
+
+func newResumedBytesCounted() *resumedBytesCounted {
+	return &resumedBytesCounted{counted: make(map[resumedBytesCountedKey]bool)}
+}
+
+// Returns true if the resumed bytes of the step from fs to toGUID have not been counted before.
+func (c *resumedBytesCounted) markCounted(fs string, toGUID uint64) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	k := resumedBytesCountedKey{fs, toGUID}
+	if c.counted[k] {
+		return false
+	}
+	c.counted[k] = true
+	return true
+}
+
+// PlannerMetrics are the metrics that a Planner updates, nil metrics are not updated.
+type PlannerMetrics struct {
+	SecsPerState    *prometheus.HistogramVec // labels: state
+	StepSecs        *prometheus.HistogramVec // labels: step_type
+	BytesReplicated *prometheus.CounterVec   // labels: filesystem
+	BytesResumed    *prometheus.CounterVec   // labels: filesystem
 }
 
 func (p *Planner) Plan(ctx context.Context) ([]driver.FS, error) {
@@ -122,8 +163,14 @@ type Filesystem struct {
 	Path                 string             // compat
 	receiverFS, senderFS *pdu.Filesystem    // receiverFS may be nil, senderFS never nil
 	promBytesReplicated  prometheus.Counter // compat
+	promBytesResumed     prometheus.Counter
+	promStepSecs         *prometheus.HistogramVec // labels: step_type
+	resumedBytesCounted  *resumedBytesCounted
 
 	sizeEstimateRequestSem *semaphore.S
+
+	// true after the first call to PlanFS
+	planned bool
 }
 
 func (f *Filesystem) EqualToPreviousAttempt(other driver.FS) bool {
@@ -136,6 +183,14 @@ func (f *Filesystem) EqualToPreviousAttempt(other driver.FS) bool {
 }
 
 func (f *Filesystem) PlanFS(ctx context.Context) ([]driver.Step, error) {
+	if f.planned {
+		// the driver re-plans a filesystem after a failed step,
+		// and the receiver state (placeholder, resume token) has likely changed since Planner.Plan
+		if err := f.refreshReceiverFS(ctx); err != nil {
+			return nil, err
+		}
+	}
+	f.planned = true
 	steps, err := f.doPlanning(ctx)
 	if err != nil {
 		return nil, err
@@ -146,6 +201,22 @@ func (f *Filesystem) PlanFS(ctx context.Context) ([]driver.Step, error) {
 	}
 	return dsteps, nil
 }
+
+func (f *Filesystem) refreshReceiverFS(ctx context.Context) error {
+	rlfssres, err := f.receiver.ListFilesystems(ctx, &pdu.ListFilesystemReq{})
+	if err != nil {
+		getLogger(ctx).WithField("filesystem", f.Path).WithError(err).Error("error listing receiver filesystems")
+		return err
+	}
+	f.receiverFS = nil
+	for _, rfs := range rlfssres.GetFilesystems() {
+		if rfs.Path == f.Path {
+			f.receiverFS = rfs
+		}
+	}
+	return nil
+}
+
 func (f *Filesystem) ReportInfo() *report.FilesystemInfo {
 	return &report.FilesystemInfo{Name: f.Path} // FIXME compat name
 }
@@ -157,6 +228,9 @@ type Step struct {
 	parent      *Filesystem
 	from, to    *pdu.FilesystemVersion // from may be nil, indicating full send
 	resumeToken string                 // empty means no resume token shall be used
+	// bytes of the stream that were received before the step was interrupted,
+	// i.e. before resumeToken was created (0 if unknown or no resume token)
+	resumedBytes uint64
 
 	expectedSize uint64 // 0 means no size estimate present / possible
 
@@ -183,7 +257,16 @@ func (s *Step) TargetDate() time.Time {
 }
 
 func (s *Step) Step(ctx context.Context) error {
-	return s.doReplication(ctx)
+	start := time.Now()
+	err := s.doReplication(ctx)
+	if err == nil && s.parent.promStepSecs != nil {
+		stepType := "incremental"
+		if s.from == nil {
+			stepType = "full"
+		}
+		trace.ObserveWithExemplar(ctx, s.parent.promStepSecs.WithLabelValues(stepType), time.Since(start).Seconds())
+	}
+	return err
 }
 
 func (s *Step) ReportInfo() *report.StepInfo {
@@ -204,13 +287,14 @@ func (s *Step) ReportInfo() *report.StepInfo {
 		From:            from,
 		To:              s.to.RelName(),
 		Resumed:         s.resumeToken != "",
+		BytesResumed:    s.resumedBytes,
 		BytesExpected:   s.expectedSize,
 		BytesReplicated: byteCounter,
 	}
 }
 
 // caller must ensure policy.Validate() == nil
-func NewPlanner(secsPerState *prometheus.HistogramVec, bytesReplicated *prometheus.CounterVec, sender Sender, receiver Receiver, policy PlannerPolicy) *Planner {
+func NewPlanner(metrics PlannerMetrics, sender Sender, receiver Receiver, policy PlannerPolicy) *Planner {
 	if err := policy.Validate(); err != nil {
 		panic(err)
 	}
@@ -218,8 +302,8 @@ func NewPlanner(secsPerState *prometheus.HistogramVec, bytesReplicated *promethe
 		sender:              sender,
 		receiver:            receiver,
 		policy:              policy,
-		promSecsPerState:    secsPerState,
-		promBytesReplicated: bytesReplicated,
+		metrics:             metrics,
+		resumedBytesCounted: newResumedBytesCounted(),
 	}
 }
 
@@ -273,6 +357,24 @@ func tryAutoresolveConflict(conflict error, policy ConflictResolution) (path []*
 	return nil, conflict
 }
 
+func filterFilesystems(fss []*pdu.Filesystem, filter zfs.DatasetFilter) ([]*pdu.Filesystem, error) {
+	filtered := make([]*pdu.Filesystem, 0, len(fss))
+	for _, fs := range fss {
+		path, err := zfs.NewDatasetPath(fs.Path)
+		if err != nil {
+			return nil, err
+		}
+		pass, err := filter.Filter(path)
+		if err != nil {
+			return nil, err
+		}
+		if pass {
+			filtered = append(filtered, fs)
+		}
+	}
+	return filtered, nil
+}
+
 func (p *Planner) doPlanning(ctx context.Context) ([]*Filesystem, error) {
 
 	log := getLogger(ctx)
@@ -285,6 +387,16 @@ func (p *Planner) doPlanning(ctx context.Context) ([]*Filesystem, error) {
 		return nil, err
 	}
 	sfss := slfssres.GetFilesystems()
+	if p.policy.Filesystems != nil {
+		sfss, err = filterFilesystems(sfss, p.policy.Filesystems)
+		if err != nil {
+			log.WithError(err).Error("error filtering sender filesystems")
+			return nil, err
+		}
+		if len(sfss) == 0 {
+			log.Warn("no sender filesystem matches the filesystems of this invocation")
+		}
+	}
 
 	rlfssres, err := p.receiver.ListFilesystems(ctx, &pdu.ListFilesystemReq{})
 	if err != nil {
@@ -305,9 +417,16 @@ func (p *Planner) doPlanning(ctx context.Context) ([]*Filesystem, error) {
 			}
 		}
 
-		var ctr prometheus.Counter
-		if p.promBytesReplicated != nil {
-			ctr = p.promBytesReplicated.WithLabelValues(fs.Path)
+		fsLabel := fs.Path
+		if !p.policy.PerFilesystemMetrics {
+			fsLabel = ""
+		}
+		var ctr, resumedCtr prometheus.Counter
+		if p.metrics.BytesReplicated != nil {
+			ctr = p.metrics.BytesReplicated.WithLabelValues(fsLabel)
+		}
+		if p.metrics.BytesResumed != nil {
+			resumedCtr = p.metrics.BytesResumed.WithLabelValues(fsLabel)
 		}
 
 		q = append(q, &Filesystem{
@@ -318,6 +437,9 @@ func (p *Planner) doPlanning(ctx context.Context) ([]*Filesystem, error) {
 			senderFS:               fs,
 			receiverFS:             receiverFS,
 			promBytesReplicated:    ctr,
+			promBytesResumed:       resumedCtr,
+			promStepSecs:           p.metrics.StepSecs,
+			resumedBytesCounted:    p.resumedBytesCounted,
 			sizeEstimateRequestSem: sizeEstimateRequestSem,
 		})
 	}
@@ -325,6 +447,14 @@ func (p *Planner) doPlanning(ctx context.Context) ([]*Filesystem, error) {
 	return q, nil
 }
 
+// Returns the first and last element of path, or path itself if it has less than three elements.
+func skipIntermediates(path []*pdu.FilesystemVersion) []*pdu.FilesystemVersion {
+	if len(path) < 3 {
+		return path
+	}
+	return []*pdu.FilesystemVersion{path[0], path[len(path)-1]}
+}
+
 func (fs *Filesystem) doPlanning(ctx context.Context) ([]*Step, error) {
 
 	log := func(ctx context.Context) logger.Logger {
@@ -421,7 +551,8 @@ func (fs *Filesystem) doPlanning(ctx context.Context) ([]*Step, error) {
 			from: fromVersion,
 			to:   toVersion,
 
-			resumeToken: resumeTokenRaw,
+			resumeToken:  resumeTokenRaw,
+			resumedBytes: resumeToken.Bytes, // 0 if !resumeToken.HasBytes
 		}
 
 		// by definition, the resume token _must_ be the receiver's most recent version, if they have any
@@ -435,6 +566,9 @@ func (fs *Filesystem) doPlanning(ctx context.Context) ([]*Step, error) {
 				remainingSFSVs = append(remainingSFSVs, sfsv)
 			}
 		}
+		if fs.policy.SkipIntermediateSnapshots {
+			remainingSFSVs = skipIntermediates(remainingSFSVs)
+		}
 
 		steps = make([]*Step, 0, len(remainingSFSVs)) // shadow
 		steps = append(steps, resumeStep)
@@ -462,6 +596,10 @@ func (fs *Filesystem) doPlanning(ctx context.Context) ([]*Step, error) {
 		if conflict != nil {
 			return nil, conflict
 		}
+		// initial replication (path[0] == nil) is governed by the conflict resolution policy
+		if fs.policy.SkipIntermediateSnapshots && len(path) > 0 && path[0] != nil {
+			path = skipIntermediates(path)
+		}
 		if len(path) == 0 {
 			steps = nil
 		} else if len(path) == 1 {
@@ -596,12 +734,20 @@ func (s *Step) doReplication(ctx context.Context) error {
 			s.parent.promBytesReplicated.Add(float64(s.byteCounter.Count()))
 		}
 	}()
+	if s.parent.promBytesResumed != nil && s.resumeToken != "" && s.parent.resumedBytesCounted.markCounted(s.parent.Path, s.to.GetGuid()) {
+		s.parent.promBytesResumed.Add(float64(s.resumedBytes))
+	}
 
+	expectedSize := sres.GetExpectedSize()
+	if expectedSize == 0 {
+		expectedSize = s.expectedSize // from the dry run
+	}
 	rr := &pdu.ReceiveReq{
 		Filesystem:        fs,
 		To:                sr.GetTo(),
 		ClearResumeToken:  !sres.UsedResumeToken,
 		ReplicationConfig: s.parent.policy.ReplicationConfig,
+		ExpectedSize:      expectedSize,
 	}
 	log.Debug("initiate receive request")
 	_, err = s.receiver.Receive(ctx, rr, byteCountingStream)
@@ -9,6 +9,7 @@ import (
 
 	"github.com/zrepl/zrepl/config"
 	"github.com/zrepl/zrepl/replication/logic/pdu"
+	"github.com/zrepl/zrepl/zfs"
 )
 
 //go:generate enumer -type=InitialReplicationAutoResolution -trimprefix=InitialReplicationAutoResolution
@@ -66,6 +67,16 @@ type PlannerPolicy struct {
 	ConflictResolution        *ConflictResolution    `validate:"ne=nil"`
 	ReplicationConfig         *pdu.ReplicationConfig `validate:"ne=nil"`
 	SizeEstimationConcurrency int                    `validate:"gte=1"`
+	// If true, incremental replication sends the delta between the most recent
+	// common version and the sender's most recent snapshot in a single step,
+	// skipping the intermediate snapshots.
+	SkipIntermediateSnapshots bool
+	// If false, the per-filesystem metrics passed to NewPlanner are not labelled
+	// with the filesystem, i.e., all filesystems share the empty label value.
+	PerFilesystemMetrics bool
+	// If not nil, only the sender's filesystems that pass the filter are replicated,
+	// e.g., for wakeups that target a subset of the job's filesystems.
+	Filesystems zfs.DatasetFilter
 }
 
 var validate = validator.New()
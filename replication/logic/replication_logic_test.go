@@ -0,0 +1,96 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+)
+
+type resumeSender struct {
+	Sender // not implemented
+}
+
+func (s resumeSender) Send(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, io.ReadCloser, error) {
+	return &pdu.SendRes{UsedResumeToken: r.GetResumeToken() != ""}, ioutil.NopCloser(strings.NewReader("stream")), nil
+}
+
+func (s resumeSender) SendCompleted(ctx context.Context, r *pdu.SendCompletedReq) (*pdu.SendCompletedRes, error) {
+	return &pdu.SendCompletedRes{}, nil
+}
+
+type failingReceiver struct {
+	Receiver // not implemented
+	failures int
+}
+
+func (r *failingReceiver) Receive(ctx context.Context, req *pdu.ReceiveReq, stream io.ReadCloser) (*pdu.ReceiveRes, error) {
+	if _, err := ioutil.ReadAll(stream); err != nil {
+		return nil, err
+	}
+	if r.failures > 0 {
+		r.failures--
+		return nil, fmt.Errorf("mock receive error")
+	}
+	return &pdu.ReceiveRes{}, nil
+}
+
+func TestStepCountsResumedBytesOnce(t *testing.T) {
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+
+	resumed := prometheus.NewCounter(prometheus.CounterOpts{Name: "bytes_resumed"})
+	receiver := &failingReceiver{failures: 2}
+	counted := newResumedBytesCounted()
+	// each attempt of the driver plans new Filesystems that share the Planner's resumedBytesCounted
+	newFS := func() *Filesystem {
+		return &Filesystem{
+			sender:              resumeSender{},
+			receiver:            receiver,
+			Path:                "pool/data",
+			promBytesResumed:    resumed,
+			resumedBytesCounted: counted,
+		}
+	}
+	fs := newFS()
+	to := &pdu.FilesystemVersion{Type: pdu.FilesystemVersion_Snapshot, Name: "b", Guid: 2}
+	step := func(token string, resumedBytes uint64) *Step {
+		return &Step{
+			parent:       fs,
+			sender:       fs.sender,
+			receiver:     fs.receiver,
+			to:           to,
+			resumeToken:  token,
+			resumedBytes: resumedBytes,
+		}
+	}
+
+	assert.Error(t, step("token1", 100).Step(ctx))
+	assert.Equal(t, float64(100), testutil.ToFloat64(resumed))
+
+	// retrying the step within the same attempt re-plans it with a new resume token
+	// that includes the bytes resumed by the first token
+	assert.Error(t, step("token2", 150).Step(ctx))
+	assert.Equal(t, float64(100), testutil.ToFloat64(resumed), "the resumed bytes of a step are counted once")
+
+	// so does the next attempt
+	fs = newFS()
+	require.NoError(t, step("token3", 180).Step(ctx))
+	assert.Equal(t, float64(100), testutil.ToFloat64(resumed), "the resumed bytes of a step are counted once across attempts")
+
+	to = &pdu.FilesystemVersion{Type: pdu.FilesystemVersion_Snapshot, Name: "c", Guid: 3}
+	require.NoError(t, step("", 0).Step(ctx))
+	assert.Equal(t, float64(100), testutil.ToFloat64(resumed), "steps without resume token do not count")
+	require.NoError(t, step("token4", 20).Step(ctx))
+	assert.Equal(t, float64(120), testutil.ToFloat64(resumed))
+}
@@ -9,7 +9,11 @@ type Report struct {
 	StartAt, FinishAt                      time.Time
 	WaitReconnectSince, WaitReconnectUntil time.Time
 	WaitReconnectError                     *TimedError
-	Attempts                               []*AttemptReport
+	// non-zero while the backoff delay before the next attempt is in progress
+	WaitRetrySince, WaitRetryUntil time.Time
+	// -1 means unlimited
+	MaxAttempts int
+	Attempts    []*AttemptReport
 }
 
 var _, _ = json.Marshal(&Report{})
@@ -40,8 +44,24 @@ type AttemptReport struct {
 	StartAt, FinishAt time.Time
 	PlanError         *TimedError
 	Filesystems       []*FilesystemReport
+	// set once the attempt has finished with errors, empty otherwise
+	ErrorClass AttemptErrorClass `json:",omitempty"`
 }
 
+// Classification of the most recent error of an attempt.
+// Determines whether the replication driver makes another attempt.
+type AttemptErrorClass string
+
+const (
+	// e.g. network errors, the driver reconnects and retries
+	AttemptErrorRetryable AttemptErrorClass = "retryable"
+	// e.g. zfs errors, the driver aborts the run
+	AttemptErrorPermanent AttemptErrorClass = "permanent"
+	// the receiving side is low on free space, the driver aborts the run
+	// and the job is paused until the next invocation finds enough free space
+	AttemptErrorReceiverLowOnSpace AttemptErrorClass = "receiver-low-on-space"
+)
+
 type AttemptState string
 
 const (
@@ -69,6 +89,8 @@ const (
 	FsBlockedOnPlanningStepQueue FsBlockedOn = "plan-queue"
 	FsBlockedOnParentInitialRepl FsBlockedOn = "parent-initial-repl"
 	FsBlockedOnReplStepQueue     FsBlockedOn = "repl-queue"
+	FsBlockedOnRetryWait         FsBlockedOn = "retry-wait"
+	FsBlockedOnReconnect         FsBlockedOn = "reconnect"
 )
 
 type FilesystemReport struct {
@@ -87,6 +109,21 @@ type FilesystemReport struct {
 	// Valid in State = FilesystemStepping
 	CurrentStep int
 	Steps       []*StepReport
+
+	// Number of times the filesystem was re-planned after a retryable step error within this attempt.
+	Retries int `json:",omitempty"`
+	// The error that caused the most recent retry, nil if Retries == 0.
+	RetryError *TimedError `json:",omitempty"`
+	// The errors that caused the retries, oldest first.
+	RetryErrors []*FilesystemError `json:",omitempty"`
+}
+
+// FilesystemError is an error of a filesystem with the step in which it occurred.
+type FilesystemError struct {
+	Err  string
+	Time time.Time
+	// nil if the error did not occur in a step, e.g., during planning
+	Step *StepInfo `json:",omitempty"`
 }
 
 type FilesystemInfo struct {
@@ -98,8 +135,12 @@ type StepReport struct {
 }
 
 type StepInfo struct {
-	From, To        string
-	Resumed         bool
+	From, To string
+	Resumed  bool
+	// Bytes that were transferred before the step was interrupted and resumed.
+	// Not included in BytesExpected and BytesReplicated, which only refer to
+	// the remainder of the stream.
+	BytesResumed    uint64 `json:",omitempty"`
 	BytesExpected   uint64
 	BytesReplicated uint64
 }
@@ -114,10 +155,11 @@ func (a *AttemptReport) BytesSum() (expected, replicated uint64, containsInvalid
 	return expected, replicated, containsInvalidSizeEstimates
 }
 
+// The returned sums include the bytes that resumed steps had transferred before their interruption.
 func (f *FilesystemReport) BytesSum() (expected, replicated uint64, containsInvalidSizeEstimates bool) {
 	for _, step := range f.Steps {
-		expected += step.Info.BytesExpected
-		replicated += step.Info.BytesReplicated
+		expected += step.Info.BytesResumed + step.Info.BytesExpected
+		replicated += step.Info.BytesResumed + step.Info.BytesReplicated
 		containsInvalidSizeEstimates = containsInvalidSizeEstimates || step.Info.BytesExpected == 0
 	}
 	return
@@ -143,6 +185,21 @@ func (f *FilesystemReport) Error() *TimedError {
 	return nil
 }
 
+// Errors returns the errors of f within its attempt, oldest first:
+// the errors that caused retries, followed by the error of the filesystem, if any.
+func (f *FilesystemReport) Errors() []*FilesystemError {
+	errs := make([]*FilesystemError, 0, len(f.RetryErrors)+1)
+	errs = append(errs, f.RetryErrors...)
+	if err := f.Error(); err != nil {
+		e := &FilesystemError{Err: err.Err, Time: err.Time}
+		if f.State == FilesystemSteppingErrored && f.CurrentStep < len(f.Steps) {
+			e.Step = f.Steps[f.CurrentStep].Info
+		}
+		errs = append(errs, e)
+	}
+	return errs
+}
+
 // may return nil
 func (f *FilesystemReport) NextStep() *StepReport {
 	switch f.State {
@@ -42,9 +42,11 @@ var _ logic.Receiver = &Client{}
 type DialContextFunc = func(ctx context.Context, network string, addr string) (net.Conn, error)
 
 // config must be validated, NewClient will panic if it is not valid
-func NewClient(cn transport.Connecter, loggers Loggers) *Client {
+//
+// The versions of the peer are reported by versionhandshake.Peers under peer.
+func NewClient(cn transport.Connecter, loggers Loggers, peer versionhandshake.PeerKey) *Client {
 
-	cn = versionhandshake.Connecter(cn, envconst.Duration("ZREPL_RPC_CLIENT_VERSIONHANDSHAKE_TIMEOUT", 10*time.Second))
+	cn = versionhandshake.Connecter(cn, envconst.Duration("ZREPL_RPC_CLIENT_VERSIONHANDSHAKE_TIMEOUT", 10*time.Second), peer, loggers.General)
 
 	muxedConnecter := mux(cn)
 
@@ -115,6 +117,13 @@ func (c *Client) SendDry(ctx context.Context, in *pdu.SendReq) (*pdu.SendRes, er
 	return c.controlClient.SendDry(ctx, in)
 }
 
+func (c *Client) Ping(ctx context.Context, in *pdu.PingReq) (*pdu.PingRes, error) {
+	ctx, endSpan := trace.WithSpan(ctx, "rpc.client.Ping")
+	defer endSpan()
+
+	return c.controlClient.Ping(ctx, in)
+}
+
 func (c *Client) ListFilesystems(ctx context.Context, in *pdu.ListFilesystemReq) (*pdu.ListFilesystemRes, error) {
 	ctx, endSpan := trace.WithSpan(ctx, "rpc.client.ListFilesystems")
 	defer endSpan()
@@ -136,6 +145,13 @@ func (c *Client) DestroySnapshots(ctx context.Context, in *pdu.DestroySnapshotsR
 	return c.controlClient.DestroySnapshots(ctx, in)
 }
 
+func (c *Client) SetPruneMarks(ctx context.Context, in *pdu.SetPruneMarksReq) (*pdu.SetPruneMarksRes, error) {
+	ctx, endSpan := trace.WithSpan(ctx, "rpc.client.SetPruneMarks")
+	defer endSpan()
+
+	return c.controlClient.SetPruneMarks(ctx, in)
+}
+
 func (c *Client) ReplicationCursor(ctx context.Context, in *pdu.ReplicationCursorReq) (*pdu.ReplicationCursorRes, error) {
 	ctx, endSpan := trace.WithSpan(ctx, "rpc.client.ReplicationCursor")
 	defer endSpan()
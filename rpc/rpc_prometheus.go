@@ -0,0 +1,78 @@
+package rpc
+
+import (
+	"context"
+	"sync"
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zrepl/zrepl/rpc/dataconn/timeoutconn"
+	"github.com/zrepl/zrepl/transport"
+)
+
+var prom struct {
+	connections    *prometheus.GaugeVec   // labels: zrepl_job
+	authRejections *prometheus.CounterVec // labels: zrepl_job
+}
+
+func init() {
+	prom.connections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "zrepl",
+		Subsystem: "rpc",
+		Name:      "server_connections",
+		Help:      "number of open connections that the job's listener accepted, after the protocol version handshake",
+	}, []string{"zrepl_job"})
+	prom.authRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "zrepl",
+		Subsystem: "rpc",
+		Name:      "server_authentication_rejections",
+		Help:      "number of connections that the job's listener rejected because the client is not authorized",
+	}, []string{"zrepl_job"})
+}
+
+func RegisterMetrics(r prometheus.Registerer) {
+	r.MustRegister(prom.connections)
+	r.MustRegister(prom.authRejections)
+}
+
+// instrumentedListener accounts the connections of the listener of job in prom.
+type instrumentedListener struct {
+	transport.AuthenticatedListener
+	job string
+}
+
+func (l instrumentedListener) Accept(ctx context.Context) (*transport.AuthConn, error) {
+	conn, err := l.AuthenticatedListener.Accept(ctx)
+	if err != nil {
+		if _, ok := err.(*transport.AuthenticationError); ok {
+			prom.authRejections.WithLabelValues(l.job).Inc()
+		}
+		return nil, err
+	}
+	open := prom.connections.WithLabelValues(l.job)
+	open.Inc()
+	return transport.NewAuthConn(&countedWire{Wire: conn.Wire, open: open}, conn.ClientIdentity()), nil
+}
+
+// countedWire decrements open when it is closed for the first time.
+type countedWire struct {
+	transport.Wire
+	open   prometheus.Gauge
+	closed sync.Once
+}
+
+var _ timeoutconn.SyscallConner = (*countedWire)(nil)
+
+func (w *countedWire) Close() error {
+	w.closed.Do(w.open.Dec)
+	return w.Wire.Close()
+}
+
+func (w *countedWire) SyscallConn() (rawConn syscall.RawConn, err error) {
+	scc, ok := w.Wire.(timeoutconn.SyscallConner)
+	if !ok {
+		return nil, timeoutconn.SyscallConnNotSupported
+	}
+	return scc.SyscallConn()
+}
@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/transport"
+	"github.com/zrepl/zrepl/util/socketpair"
+)
+
+type acceptResult struct {
+	conn *transport.AuthConn
+	err  error
+}
+
+type fakeListener struct {
+	transport.AuthenticatedListener
+	results []acceptResult
+}
+
+func (l *fakeListener) Accept(ctx context.Context) (*transport.AuthConn, error) {
+	r := l.results[0]
+	l.results = l.results[1:]
+	return r.conn, r.err
+}
+
+func TestInstrumentedListener(t *testing.T) {
+	a, b, err := socketpair.SocketPair()
+	require.NoError(t, err)
+	defer b.Close()
+	const job = "test-instrumented-listener"
+	l := instrumentedListener{&fakeListener{results: []acceptResult{
+		{nil, &transport.AuthenticationError{Err: errors.New("client IP not in client map")}},
+		{nil, net.UnknownNetworkError("not an authentication error")},
+		{transport.NewAuthConn(a, "client"), nil},
+	}}, job}
+
+	_, err = l.Accept(context.Background())
+	assert.Error(t, err)
+	_, err = l.Accept(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(prom.authRejections.WithLabelValues(job)))
+
+	conn, err := l.Accept(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "client", conn.ClientIdentity())
+	assert.Equal(t, 1.0, testutil.ToFloat64(prom.connections.WithLabelValues(job)))
+	_, err = conn.SyscallConn()
+	assert.NoError(t, err, "the wrapped connection supports SyscallConn")
+	assert.NoError(t, conn.Close())
+	conn.Close()
+	assert.Equal(t, 0.0, testutil.ToFloat64(prom.connections.WithLabelValues(job)), "a connection is closed once")
+}
@@ -24,6 +24,7 @@ type serveFunc func(ctx context.Context, demuxedListener transport.Authenticated
 // Server abstracts the accept and request routing infrastructure for the
 // passive side of a replication setup.
 type Server struct {
+	job                string
 	logger             Logger
 	handler            Handler
 	controlServerServe serveFunc
@@ -47,7 +48,8 @@ func (d interceptorData) FullMethod() string     { return d.prefixMethod + d.wra
 type HandlerContextInterceptor func(ctx context.Context, data HandlerContextInterceptorData, handler func(ctx context.Context))
 
 // config must be valid (use its Validate function).
-func NewServer(handler Handler, loggers Loggers, ctxInterceptor HandlerContextInterceptor) *Server {
+// The versions of connecting peers are reported by versionhandshake.Peers under job.
+func NewServer(job string, handler Handler, loggers Loggers, ctxInterceptor HandlerContextInterceptor) *Server {
 
 	// setup control server
 	controlServerServe := func(ctx context.Context, controlListener transport.AuthenticatedListener, errOut chan<- error) {
@@ -88,6 +90,7 @@ func NewServer(handler Handler, loggers Loggers, ctxInterceptor HandlerContextIn
 	}
 
 	server := &Server{
+		job:                job,
 		logger:             loggers.General,
 		handler:            handler,
 		controlServerServe: controlServerServe,
@@ -105,7 +108,8 @@ func (s *Server) Serve(ctx context.Context, l transport.AuthenticatedListener) {
 	defer cancel()
 	defer s.logger.Debug("rpc.(*Server).Serve done")
 
-	l = versionhandshake.Listener(l, envconst.Duration("ZREPL_RPC_SERVER_VERSIONHANDSHAKE_TIMEOUT", 10*time.Second))
+	l = versionhandshake.Listener(l, envconst.Duration("ZREPL_RPC_SERVER_VERSIONHANDSHAKE_TIMEOUT", 10*time.Second), s.job, s.logger)
+	l = instrumentedListener{l, s.job}
 
 	// it is important that demux's context is cancelled,
 	// it has background goroutines attached
@@ -189,26 +189,37 @@ func (m *HandshakeMessage) DecodeReader(r io.Reader, maxLen int) error {
 	return nil
 }
 
-func DoHandshakeCurrentVersion(conn net.Conn, deadline time.Time) *HandshakeError {
+// DoHandshakeCurrentVersion returns the version information announced by the peer.
+// The error is a *HandshakeError if non-nil.
+func DoHandshakeCurrentVersion(conn net.Conn, deadline time.Time) (*PeerVersion, error) {
 	// current protocol version is hardcoded here
-	return DoHandshakeVersion(conn, deadline, 7)
+	peer, err := doHandshake(conn, deadline, 7)
+	if err != nil {
+		return nil, err
+	}
+	return peer, nil
 }
 
 const HandshakeMessageMaxLen = 16 * 4096
 
-func DoHandshakeVersion(conn net.Conn, deadline time.Time, version int) (rErr *HandshakeError) {
+func DoHandshakeVersion(conn net.Conn, deadline time.Time, version int) *HandshakeError {
+	_, err := doHandshake(conn, deadline, version)
+	return err
+}
+
+func doHandshake(conn net.Conn, deadline time.Time, version int) (peer *PeerVersion, rErr *HandshakeError) {
 	ours := HandshakeMessage{
 		ProtocolVersion: version,
-		Extensions:      nil,
+		Extensions:      ourExtensions(),
 	}
 	hsb, err := ours.Encode()
 	if err != nil {
-		return hsErr("could not encode protocol banner: %s", err)
+		return nil, hsErr("could not encode protocol banner: %s", err)
 	}
 
 	err = conn.SetDeadline(deadline)
 	if err != nil {
-		return hsErr("could not set deadline for protocol banner handshake: %s", err)
+		return nil, hsErr("could not set deadline for protocol banner handshake: %s", err)
 	}
 	defer func() {
 		if rErr != nil {
@@ -216,24 +227,28 @@ func DoHandshakeVersion(conn net.Conn, deadline time.Time, version int) (rErr *H
 		}
 		err := conn.SetDeadline(time.Time{})
 		if err != nil {
-			rErr = hsErr("could not reset deadline after protocol banner handshake: %s", err)
+			peer, rErr = nil, hsErr("could not reset deadline after protocol banner handshake: %s", err)
 		}
 	}()
 	_, err = io.Copy(conn, bytes.NewBuffer(hsb))
 	if err != nil {
-		return hsErr("could not send protocol banner: %s", err)
+		return nil, hsErr("could not send protocol banner: %s", err)
 	}
 
 	theirs := HandshakeMessage{}
 	if err := theirs.DecodeReader(conn, HandshakeMessageMaxLen); err != nil {
-		return hsErr("could not decode protocol banner: %s", err)
+		return nil, hsErr("could not decode protocol banner: %s", err)
 	}
 
+	peer = peerVersionFromMessage(&theirs)
 	if theirs.ProtocolVersion != ours.ProtocolVersion {
-		return hsErr("protocol versions do not match: ours is %d, theirs is %d",
+		if peer.Version != "" {
+			return nil, hsErr("protocol versions do not match: ours is %d, theirs is %d (zrepl %s)",
+				ours.ProtocolVersion, theirs.ProtocolVersion, peer.Version)
+		}
+		return nil, hsErr("protocol versions do not match: ours is %d, theirs is %d",
 			ours.ProtocolVersion, theirs.ProtocolVersion)
 	}
-	// ignore extensions, we don't use them
 
-	return nil
+	return peer, nil
 }
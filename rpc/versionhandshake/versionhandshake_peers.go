@@ -0,0 +1,248 @@
+package versionhandshake
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/version"
+)
+
+type Logger = logger.Logger
+
+// Features are the protocol features that this zrepl announces in the handshake
+// in addition to the protocol version.
+var Features = []string{
+	featurePeerVersion,
+}
+
+const featurePeerVersion = "peer-version"
+
+// The zrepl version and features are sent as handshake extensions,
+// which zrepl versions that predate them ignore.
+const (
+	extensionVersionPrefix = "zrepl-version="
+	extensionFeaturePrefix = "feature="
+	// the wall clock of the sender of the handshake message, to detect clock skew
+	extensionTimePrefix = "time="
+	// the ZFS userland version of the sender, only sent by daemons, see SetZFSVersion
+	extensionZFSVersionPrefix = "zfs-version="
+)
+
+var ourZFSVersion struct {
+	mtx sync.Mutex
+	v   string
+}
+
+// SetZFSVersion sets the ZFS version that this zrepl announces in the handshake, e.g. zfs-2.1.5-1.
+func SetZFSVersion(v string) {
+	ourZFSVersion.mtx.Lock()
+	defer ourZFSVersion.mtx.Unlock()
+	ourZFSVersion.v = strings.Replace(v, "\n", " ", -1)
+}
+
+func ourExtensions() []string {
+	exts := []string{
+		extensionVersionPrefix + version.NewZreplVersionInformation().Version,
+		extensionTimePrefix + time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	ourZFSVersion.mtx.Lock()
+	if ourZFSVersion.v != "" {
+		exts = append(exts, extensionZFSVersionPrefix+ourZFSVersion.v)
+	}
+	ourZFSVersion.mtx.Unlock()
+	for _, f := range Features {
+		exts = append(exts, extensionFeaturePrefix+f)
+	}
+	return exts
+}
+
+// PeerVersion is what the other side of a connection announced in the handshake.
+type PeerVersion struct {
+	ProtocolVersion int
+	// empty if the peer does not announce it, i.e., it predates the exchange of zrepl versions,
+	// or is a development build
+	Version  string   `json:",omitempty"`
+	Features []string `json:",omitempty"`
+	// empty if the peer does not announce it, e.g. because it is not a daemon
+	ZFSVersion string `json:",omitempty"`
+	// zero if the peer does not announce it
+	Time time.Time `json:"-"`
+}
+
+func peerVersionFromMessage(m *HandshakeMessage) *PeerVersion {
+	v := &PeerVersion{ProtocolVersion: m.ProtocolVersion}
+	for _, ext := range m.Extensions {
+		switch {
+		case strings.HasPrefix(ext, extensionVersionPrefix):
+			v.Version = strings.TrimPrefix(ext, extensionVersionPrefix)
+		case strings.HasPrefix(ext, extensionFeaturePrefix):
+			v.Features = append(v.Features, strings.TrimPrefix(ext, extensionFeaturePrefix))
+		case strings.HasPrefix(ext, extensionZFSVersionPrefix):
+			v.ZFSVersion = strings.TrimPrefix(ext, extensionZFSVersionPrefix)
+		case strings.HasPrefix(ext, extensionTimePrefix):
+			// ignore invalid values, the clock offset is informational
+			v.Time, _ = time.Parse(time.RFC3339Nano, strings.TrimPrefix(ext, extensionTimePrefix))
+		}
+	}
+	return v
+}
+
+// PeerKey identifies a peer in the report of Peers.
+type PeerKey struct {
+	Job string
+	// the client identity of a connecting peer, the name of a push destination,
+	// or empty for the peer that an active job connects to
+	Peer string `json:",omitempty"`
+}
+
+type PeerReport struct {
+	PeerKey
+	PeerVersion
+	// the peer connected to this daemon
+	Incoming bool
+	LastSeen time.Time
+	// the features of this zrepl that the peer does not support
+	MissingFeatures []string `json:",omitempty"`
+	// describes the version difference if it exceeds `global.version_skew`, empty otherwise
+	Skew string `json:",omitempty"`
+	// the peer's clock minus this daemon's clock at LastSeen, including the latency of the handshake,
+	// nil if the peer does not announce its time
+	ClockOffset *time.Duration `json:",omitempty"`
+}
+
+var peers = struct {
+	mtx                sync.Mutex
+	reports            map[PeerKey]*PeerReport
+	maxMinorDifference int
+}{
+	reports: make(map[PeerKey]*PeerReport),
+}
+
+// SetMaxMinorDifference sets the tolerance for version skew warnings, see VersionSkew.
+func SetMaxMinorDifference(n int) {
+	peers.mtx.Lock()
+	defer peers.mtx.Unlock()
+	peers.maxMinorDifference = n
+}
+
+// observePeer records v and logs a warning if the version skew exceeds the tolerance.
+// To avoid repeating the warning for every connection, it is only logged when the peer's version changes.
+func observePeer(log Logger, key PeerKey, incoming bool, v *PeerVersion) {
+	peers.mtx.Lock()
+	defer peers.mtx.Unlock()
+	prev, seen := peers.reports[key]
+	r := &PeerReport{
+		PeerKey:         key,
+		PeerVersion:     *v,
+		Incoming:        incoming,
+		LastSeen:        time.Now(),
+		MissingFeatures: missingFeatures(v),
+	}
+	if !v.Time.IsZero() {
+		offset := v.Time.Sub(r.LastSeen)
+		r.ClockOffset = &offset
+	}
+	ours := version.NewZreplVersionInformation().Version
+	if v.announces(featurePeerVersion) {
+		r.Skew = VersionSkew(ours, v.Version, peers.maxMinorDifference)
+	} else {
+		r.Skew = fmt.Sprintf("zrepl version skew: the peer does not announce its version, it is older than this daemon's %s", ours)
+	}
+	peers.reports[key] = r
+	if seen && prev.Version == r.Version && prev.Skew == r.Skew {
+		return
+	}
+	log = log.WithField("peer_version", r.Version).WithField("peer_features", r.Features)
+	if r.Skew != "" {
+		log.WithField("missing_features", r.MissingFeatures).Warn(r.Skew)
+	} else {
+		log.Info("peer zrepl version")
+	}
+}
+
+// Peers returns the peers seen since the daemon started, sorted by job and peer.
+func Peers() []PeerReport {
+	peers.mtx.Lock()
+	defer peers.mtx.Unlock()
+	ret := make([]PeerReport, 0, len(peers.reports))
+	for _, r := range peers.reports {
+		ret = append(ret, *r)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Job != ret[j].Job {
+			return ret[i].Job < ret[j].Job
+		}
+		return ret[i].Peer < ret[j].Peer
+	})
+	return ret
+}
+
+// JobPeers returns the Peers of job.
+func JobPeers(job string) []PeerReport {
+	var ret []PeerReport
+	for _, r := range Peers() {
+		if r.Job == job {
+			ret = append(ret, r)
+		}
+	}
+	return ret
+}
+
+func (v *PeerVersion) announces(feature string) bool {
+	for _, f := range v.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+func missingFeatures(theirs *PeerVersion) []string {
+	var missing []string
+	for _, f := range Features {
+		if !theirs.announces(f) {
+			missing = append(missing, f)
+		}
+	}
+	return missing
+}
+
+var versionRE = regexp.MustCompile(`^v?(\d+)\.(\d+)`)
+
+func parseMajorMinor(v string) (major, minor int, ok bool) {
+	m := versionRE.FindStringSubmatch(v)
+	if m == nil {
+		return 0, 0, false
+	}
+	major, _ = strconv.Atoi(m[1])
+	minor, _ = strconv.Atoi(m[2])
+	return major, minor, true
+}
+
+// VersionSkew describes the difference between the zrepl versions ours and theirs
+// if their major versions differ or their minor versions differ by more than maxMinorDifference.
+// It returns an empty string otherwise, and if either version is not a release version, e.g., for development builds.
+func VersionSkew(ours, theirs string, maxMinorDifference int) string {
+	ourMajor, ourMinor, ok := parseMajorMinor(ours)
+	if !ok {
+		return ""
+	}
+	theirMajor, theirMinor, ok := parseMajorMinor(theirs)
+	if !ok {
+		return ""
+	}
+	diff := ourMinor - theirMinor
+	if diff < 0 {
+		diff = -diff
+	}
+	if ourMajor == theirMajor && diff <= maxMinorDifference {
+		return ""
+	}
+	return fmt.Sprintf("zrepl version skew: this daemon runs %s, the peer runs %s", ours, theirs)
+}
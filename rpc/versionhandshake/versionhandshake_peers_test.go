@@ -0,0 +1,91 @@
+package versionhandshake
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/util/socketpair"
+)
+
+func TestVersionSkew(t *testing.T) {
+	tcs := []struct {
+		ours, theirs string
+		max          int
+		skew         bool
+	}{
+		{"v0.6.1", "v0.6.0", 0, false},
+		{"v0.6.1", "v0.6.1-12-gdeadbeef", 0, false},
+		{"v0.6.1", "v0.5.0", 0, true},
+		{"v0.6.1", "v0.5.0", 1, false},
+		{"v0.6.1", "v0.8.0", 1, true},
+		{"v1.0.0", "v0.9.0", 10, true},
+		{"", "v0.5.0", 0, false},
+		{"v0.6.1", "", 0, false},
+		{"v0.6.1", "devel", 0, false},
+	}
+	for _, tc := range tcs {
+		skew := VersionSkew(tc.ours, tc.theirs, tc.max)
+		assert.Equal(t, tc.skew, skew != "", "%#v: %q", tc, skew)
+	}
+}
+
+func TestDoHandshakeCurrentVersion_PeerVersion(t *testing.T) {
+	srv, client, err := socketpair.SocketPair()
+	require.NoError(t, err)
+	defer srv.Close()
+	defer client.Close()
+
+	srvPeerCh := make(chan *PeerVersion)
+	go func() {
+		peer, err := DoHandshakeCurrentVersion(srv, time.Now().Add(2*time.Second))
+		assert.Nil(t, err)
+		srvPeerCh <- peer
+	}()
+	peer, err := DoHandshakeCurrentVersion(client, time.Now().Add(2*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, Features, peer.Features)
+	srvPeer := <-srvPeerCh
+	// the announced times differ
+	assert.WithinDuration(t, time.Now(), peer.Time, time.Second)
+	assert.WithinDuration(t, time.Now(), srvPeer.Time, time.Second)
+	peer.Time, srvPeer.Time = time.Time{}, time.Time{}
+	assert.Equal(t, peer, srvPeer)
+}
+
+func TestPeerVersionZFSVersion(t *testing.T) {
+	m := HandshakeMessage{ProtocolVersion: 7, Extensions: ourExtensions()}
+	assert.Empty(t, peerVersionFromMessage(&m).ZFSVersion, "not set")
+
+	SetZFSVersion("zfs-2.1.5-1")
+	defer SetZFSVersion("")
+	m = HandshakeMessage{ProtocolVersion: 7, Extensions: ourExtensions()}
+	assert.Equal(t, "zfs-2.1.5-1", peerVersionFromMessage(&m).ZFSVersion)
+	_, err := m.Encode()
+	assert.NoError(t, err)
+}
+
+func TestObservePeer(t *testing.T) {
+	log := logger.NewTestLogger(t)
+	key := PeerKey{Job: "test-observe-peer", Peer: "client1"}
+
+	observePeer(log, key, true, &PeerVersion{ProtocolVersion: 7})
+	peers := JobPeers(key.Job)
+	require.Len(t, peers, 1)
+	assert.Equal(t, key, peers[0].PeerKey)
+	assert.True(t, peers[0].Incoming)
+	assert.Equal(t, Features, peers[0].MissingFeatures)
+	assert.Contains(t, peers[0].Skew, "does not announce its version")
+	assert.Nil(t, peers[0].ClockOffset)
+
+	observePeer(log, key, true, &PeerVersion{ProtocolVersion: 7, Features: Features, Time: time.Now().Add(time.Hour)})
+	peers = JobPeers(key.Job)
+	require.Len(t, peers, 1)
+	assert.Empty(t, peers[0].MissingFeatures)
+	require.NotNil(t, peers[0].ClockOffset)
+	assert.InDelta(t, time.Hour, *peers[0].ClockOffset, float64(time.Second))
+	assert.Empty(t, peers[0].Skew) // the test binary has no release version
+}
@@ -0,0 +1,26 @@
+package versionhandshake
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// labels: zrepl_job, peer (see PeerKey), direction (incoming or outgoing), outcome (success or failure)
+var promHandshakes = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "zrepl",
+	Subsystem: "versionhandshake",
+	Name:      "handshakes",
+	Help:      "number of protocol version handshakes with the peer",
+}, []string{"zrepl_job", "peer", "direction", "outcome"})
+
+func RegisterMetrics(r prometheus.Registerer) {
+	r.MustRegister(promHandshakes)
+}
+
+func observeHandshake(peer PeerKey, incoming bool, err error) {
+	direction, outcome := "outgoing", "success"
+	if incoming {
+		direction = "incoming"
+	}
+	if err != nil {
+		outcome = "failure"
+	}
+	promHandshakes.WithLabelValues(peer.Job, peer.Peer, direction, outcome).Inc()
+}
@@ -2,15 +2,20 @@ package versionhandshake
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"net"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/transport"
 	"github.com/zrepl/zrepl/util/socketpair"
 )
 
@@ -117,3 +122,37 @@ func TestDoHandshakeCurrentVersion(t *testing.T) {
 	assert.Nil(t, <-srvErrCh)
 
 }
+
+type socketpairConnecter struct{ conn *net.UnixConn }
+
+func (c socketpairConnecter) Connect(ctx context.Context) (transport.Wire, error) {
+	return c.conn, nil
+}
+
+func TestHandshakeConnecter(t *testing.T) {
+	srv, client, err := socketpair.SocketPair()
+	require.NoError(t, err)
+	defer srv.Close()
+	defer client.Close()
+
+	go func() {
+		_, err := DoHandshakeCurrentVersion(srv, time.Now().Add(2*time.Second))
+		assert.Nil(t, err)
+	}()
+	key := PeerKey{Job: "test-handshake-connecter"}
+	conn, err := Connecter(socketpairConnecter{client}, 2*time.Second, key, logger.NewTestLogger(t)).Connect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, client, conn)
+	assert.Len(t, JobPeers(key.Job), 1)
+	assert.Equal(t, 1.0, testutil.ToFloat64(promHandshakes.WithLabelValues(key.Job, "", "outgoing", "success")))
+
+	// the peer closes the connection instead of sending its handshake
+	srv2, client2, err := socketpair.SocketPair()
+	require.NoError(t, err)
+	defer client2.Close()
+	srv2.Close()
+	_, err = Connecter(socketpairConnecter{client2}, 2*time.Second, key, logger.NewTestLogger(t)).Connect(context.Background())
+	assert.Error(t, err)
+	assert.IsType(t, &HandshakeError{}, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(promHandshakes.WithLabelValues(key.Job, "", "outgoing", "failure")))
+}
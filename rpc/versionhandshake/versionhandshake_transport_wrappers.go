@@ -11,6 +11,8 @@ import (
 type HandshakeConnecter struct {
 	connecter transport.Connecter
 	timeout   time.Duration
+	peer      PeerKey
+	log       Logger
 }
 
 func (c HandshakeConnecter) Connect(ctx context.Context) (transport.Wire, error) {
@@ -22,17 +24,23 @@ func (c HandshakeConnecter) Connect(ctx context.Context) (transport.Wire, error)
 	if !ok {
 		dl = time.Now().Add(c.timeout)
 	}
-	if err := DoHandshakeCurrentVersion(conn, dl); err != nil {
+	peerVersion, err := DoHandshakeCurrentVersion(conn, dl)
+	observeHandshake(c.peer, false, err)
+	if err != nil {
 		conn.Close()
 		return nil, err
 	}
+	observePeer(c.log, c.peer, false, peerVersion)
 	return conn, nil
 }
 
-func Connecter(connecter transport.Connecter, timeout time.Duration) HandshakeConnecter {
+// Connecter records the versions of the peer in Peers under the given key.
+func Connecter(connecter transport.Connecter, timeout time.Duration, peer PeerKey, log Logger) HandshakeConnecter {
 	return HandshakeConnecter{
 		connecter: connecter,
 		timeout:   timeout,
+		peer:      peer,
+		log:       log,
 	}
 }
 
@@ -40,6 +48,8 @@ func Connecter(connecter transport.Connecter, timeout time.Duration) HandshakeCo
 type HandshakeListener struct {
 	l       transport.AuthenticatedListener
 	timeout time.Duration
+	job     string
+	log     Logger
 }
 
 func (l HandshakeListener) Addr() net.Addr { return l.l.Addr() }
@@ -55,14 +65,21 @@ func (l HandshakeListener) Accept(ctx context.Context) (*transport.AuthConn, err
 	if !ok {
 		dl = time.Now().Add(l.timeout) // shadowing
 	}
-	if err := DoHandshakeCurrentVersion(conn, dl); err != nil {
-		err.isAcceptError = true
+	peer := PeerKey{Job: l.job, Peer: conn.ClientIdentity()}
+	peerVersion, err := DoHandshakeCurrentVersion(conn, dl)
+	observeHandshake(peer, true, err)
+	if err != nil {
+		if hsErr, ok := err.(*HandshakeError); ok {
+			hsErr.isAcceptError = true
+		}
 		conn.Close()
 		return nil, err
 	}
+	observePeer(l.log, peer, true, peerVersion)
 	return conn, nil
 }
 
-func Listener(l transport.AuthenticatedListener, timeout time.Duration) transport.AuthenticatedListener {
-	return HandshakeListener{l, timeout}
+// Listener records the versions of the connecting peers in Peers under job and their client identity.
+func Listener(l transport.AuthenticatedListener, timeout time.Duration, job string, log Logger) transport.AuthenticatedListener {
+	return HandshakeListener{l, timeout, job, log}
 }
@@ -0,0 +1,103 @@
+// Package secret loads the secrets referenced by config.Secret.
+package secret
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/util/envconst"
+)
+
+// Load returns the secret referenced by s without trailing newlines.
+// Secret files must not be accessible by others or writable by the group.
+func Load(s config.Secret) ([]byte, error) {
+	var (
+		secret []byte
+		err    error
+	)
+	switch {
+	case s.File != "":
+		secret, err = readFile(s.File)
+	case s.Env != "":
+		secret = []byte(os.Getenv(s.Env))
+	case len(s.Command) > 0:
+		secret, err = runCommand(s.Command)
+	default:
+		return nil, errors.New("secret is not configured")
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot load secret from %s", s)
+	}
+	secret = bytes.TrimRight(secret, "\r\n")
+	if len(secret) == 0 {
+		return nil, errors.Errorf("secret from %s is empty", s)
+	}
+	return secret, nil
+}
+
+// LoadX509KeyPair is tls.LoadX509KeyPair with the private key loaded from key.
+func LoadX509KeyPair(certFile string, key config.Secret) (tls.Certificate, error) {
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyPEM, err := Load(key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+func readFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, errors.New("not a regular file")
+	}
+	if err := checkPermissions(fi.Mode()); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(f)
+}
+
+func checkPermissions(mode os.FileMode) error {
+	if envconst.Bool("ZREPL_SECRET_FILE_SKIP_PERMISSION_CHECK", false) {
+		return nil
+	}
+	if mode.Perm()&0027 != 0 {
+		return errors.Errorf("insecure permissions %v: must not be accessible by others or writable by the group (chmod o-rwx,g-w)", mode.Perm())
+	}
+	return nil
+}
+
+func runCommand(argv []string) ([]byte, error) {
+	timeout := envconst.Duration("ZREPL_SECRET_COMMAND_TIMEOUT", 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, errors.Errorf("command timed out after %s", timeout)
+		}
+		return nil, errors.Wrapf(err, "command failed: %s", bytes.TrimSpace(stderr.Bytes()))
+	}
+	return stdout.Bytes(), nil
+}
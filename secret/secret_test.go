@@ -0,0 +1,54 @@
+package secret
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-secret-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	write := func(name string, perm os.FileMode, content string) string {
+		p := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(p, []byte(content), perm))
+		require.NoError(t, os.Chmod(p, perm)) // umask
+		return p
+	}
+
+	s, err := Load(config.Secret{File: write("ok", 0640, "s3cret\n")})
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", string(s))
+
+	_, err = Load(config.Secret{File: write("world", 0644, "s3cret")})
+	assert.Error(t, err)
+	_, err = Load(config.Secret{File: write("groupwrite", 0660, "s3cret")})
+	assert.Error(t, err)
+	_, err = Load(config.Secret{File: write("empty", 0600, "\n")})
+	assert.Error(t, err)
+	_, err = Load(config.Secret{File: dir})
+	assert.Error(t, err)
+
+	require.NoError(t, os.Setenv("ZREPL_SECRET_TEST", "fromenv"))
+	defer os.Unsetenv("ZREPL_SECRET_TEST")
+	s, err = Load(config.Secret{Env: "ZREPL_SECRET_TEST"})
+	require.NoError(t, err)
+	assert.Equal(t, "fromenv", string(s))
+	_, err = Load(config.Secret{Env: "ZREPL_SECRET_TEST_UNSET"})
+	assert.Error(t, err)
+
+	s, err = Load(config.Secret{Command: []string{"echo", "fromcommand"}})
+	require.NoError(t, err)
+	assert.Equal(t, "fromcommand", string(s))
+	_, err = Load(config.Secret{Command: []string{"sh", "-c", "echo oops >&2; exit 1"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "oops")
+	assert.NotContains(t, err.Error(), "fromcommand")
+}
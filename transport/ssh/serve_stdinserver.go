@@ -6,6 +6,7 @@ import (
 	"net"
 	"path"
 	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/problame/go-netssh"
@@ -50,13 +51,14 @@ func multiStdinserverListenerFromClientIdentities(sockdir string, cis []string)
 	var err error
 	for _, ci := range cis {
 		sockpath := path.Join(sockdir, ci)
-		l := &stdinserverListener{clientIdentity: ci}
+		l := &stdinserverListener{clientIdentity: ci, sockpath: sockpath}
 		if err = nethelpers.PreparePrivateSockpath(sockpath); err != nil {
 			break
 		}
 		if l.l, err = netssh.Listen(sockpath); err != nil {
 			break
 		}
+		stdinserverSocketUpdate(sockpath, ci, func(r *StdinserverSocketReport) { r.Listening = true })
 		listeners = append(listeners, l)
 	}
 	if err != nil {
@@ -120,6 +122,7 @@ func (m *MultiStdinserverListener) Close() error {
 type stdinserverListener struct {
 	l              *netssh.Listener
 	clientIdentity string
+	sockpath       string
 }
 
 type listenerAddr struct {
@@ -141,9 +144,17 @@ func (l stdinserverListener) Accept(ctx context.Context) (*transport.AuthConn, e
 	if err != nil {
 		return nil, err
 	}
-	return transport.NewAuthConn(c, l.clientIdentity), nil
+	stdinserverSocketUpdate(l.sockpath, l.clientIdentity, func(r *StdinserverSocketReport) {
+		r.Open++
+		r.LastAccepted = time.Now()
+	})
+	conn := &stdinserverConn{ServeConn: c, closed: func() {
+		stdinserverSocketUpdate(l.sockpath, l.clientIdentity, func(r *StdinserverSocketReport) { r.Open-- })
+	}}
+	return transport.NewAuthConn(conn, l.clientIdentity), nil
 }
 
 func (l stdinserverListener) Close() (err error) {
+	stdinserverSocketUpdate(l.sockpath, l.clientIdentity, func(r *StdinserverSocketReport) { r.Listening = false })
 	return l.l.Close()
 }
@@ -0,0 +1,65 @@
+package ssh
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/problame/go-netssh"
+)
+
+// StdinserverSocketReport describes a stdinserver socket of the daemon and the connections accepted on it,
+// see StdinserverSockets.
+type StdinserverSocketReport struct {
+	ClientIdentity string
+	SockPath       string
+	// false after the listener was closed, e.g. by a config reload that removed the client identity
+	Listening bool
+	// the connections that are currently open
+	Open int
+	// zero if no connection was accepted since the daemon started
+	LastAccepted time.Time `json:",omitempty"`
+}
+
+var stdinserverSockets = struct {
+	mtx    sync.Mutex
+	bySock map[string]*StdinserverSocketReport
+}{
+	bySock: make(map[string]*StdinserverSocketReport),
+}
+
+// StdinserverSockets returns the stdinserver sockets that the daemon listened on since it started, sorted by path.
+func StdinserverSockets() []StdinserverSocketReport {
+	stdinserverSockets.mtx.Lock()
+	defer stdinserverSockets.mtx.Unlock()
+	ret := make([]StdinserverSocketReport, 0, len(stdinserverSockets.bySock))
+	for _, r := range stdinserverSockets.bySock {
+		ret = append(ret, *r)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].SockPath < ret[j].SockPath })
+	return ret
+}
+
+func stdinserverSocketUpdate(sockpath, clientIdentity string, f func(r *StdinserverSocketReport)) {
+	stdinserverSockets.mtx.Lock()
+	defer stdinserverSockets.mtx.Unlock()
+	r, ok := stdinserverSockets.bySock[sockpath]
+	if !ok {
+		r = &StdinserverSocketReport{SockPath: sockpath}
+		stdinserverSockets.bySock[sockpath] = r
+	}
+	r.ClientIdentity = clientIdentity
+	f(r)
+}
+
+// stdinserverConn counts itself as open in the report of its socket until it is closed.
+type stdinserverConn struct {
+	*netssh.ServeConn
+	closeOnce sync.Once
+	closed    func()
+}
+
+func (c *stdinserverConn) Close() error {
+	c.closeOnce.Do(c.closed)
+	return c.ServeConn.Close()
+}
@@ -50,7 +50,7 @@ func (f *TCPAuthListener) Accept(ctx context.Context) (*transport.AuthConn, erro
 	if err != nil {
 		transport.GetLogger(ctx).WithField("ipaddr", clientAddr).Error("client IP not in client map")
 		nc.Close()
-		return nil, err
+		return nil, &transport.AuthenticationError{Err: err}
 	}
 	return transport.NewAuthConn(nc, clientIdent), nil
 }
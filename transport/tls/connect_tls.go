@@ -8,6 +8,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/secret"
 	"github.com/zrepl/zrepl/tlsconf"
 	"github.com/zrepl/zrepl/transport"
 )
@@ -32,7 +33,7 @@ func TLSConnecterFromConfig(in *config.TLSConnect, parseFlags config.ParseFlags)
 		return nil, errors.Wrap(err, "cannot parse ca file")
 	}
 
-	cert, err := tls.LoadX509KeyPair(in.Cert, in.Key)
+	cert, err := secret.LoadX509KeyPair(in.Cert, in.Key)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot parse cert/key pair")
 	}
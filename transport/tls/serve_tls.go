@@ -2,13 +2,13 @@ package tls
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
 	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/secret"
 	"github.com/zrepl/zrepl/tlsconf"
 	"github.com/zrepl/zrepl/transport"
 	"github.com/zrepl/zrepl/util/tcpsock"
@@ -21,7 +21,7 @@ func TLSListenerFactoryFromConfig(c *config.Global, in *config.TLSServe, parseFl
 	address := in.Listen
 	handshakeTimeout := in.HandshakeTimeout
 
-	if in.Ca == "" || in.Cert == "" || in.Key == "" {
+	if in.Ca == "" || in.Cert == "" || in.Key.IsZero() {
 		return nil, errors.New("fields 'ca', 'cert' and 'key'must be specified")
 	}
 
@@ -34,7 +34,7 @@ func TLSListenerFactoryFromConfig(c *config.Global, in *config.TLSServe, parseFl
 		return nil, errors.Wrap(err, "cannot parse ca file")
 	}
 
-	serverCert, err := tls.LoadX509KeyPair(in.Cert, in.Key)
+	serverCert, err := secret.LoadX509KeyPair(in.Cert, in.Key)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot parse cert/key pair")
 	}
@@ -87,7 +87,7 @@ func (l tlsAuthListener) Accept(ctx context.Context) (*transport.AuthConn, error
 		if err := tlsConn.Close(); err != nil {
 			log.WithError(err).Error("error closing connection with unauthorized common name")
 		}
-		return nil, fmt.Errorf("unauthorized client common name %q from %s", cn, tlsConn.RemoteAddr())
+		return nil, &transport.AuthenticationError{Err: fmt.Errorf("unauthorized client common name %q from %s", cn, tlsConn.RemoteAddr())}
 	}
 	adaptor := newWireAdaptor(tlsConn, tcpConn)
 	return transport.NewAuthConn(adaptor, cn), nil
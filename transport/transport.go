@@ -50,6 +50,14 @@ type AuthenticatedListener interface {
 
 type AuthenticatedListenerFactory func() (AuthenticatedListener, error)
 
+// AuthenticationError is returned by AuthenticatedListener.Accept if the listener rejected a connection
+// because the client is not authorized, e.g. its IP address or the common name of its TLS client certificate.
+type AuthenticationError struct {
+	Err error
+}
+
+func (e *AuthenticationError) Error() string { return e.Err.Error() }
+
 type Wire = timeoutconn.Wire
 
 type Connecter interface {
@@ -0,0 +1,71 @@
+// Package sdnotify implements the client side of systemd's service notification protocol,
+// see sd_notify(3).
+package sdnotify
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	Ready     = "READY=1"
+	Reloading = "RELOADING=1"
+	Stopping  = "STOPPING=1"
+	Watchdog  = "WATCHDOG=1"
+)
+
+func Status(text string) string { return "STATUS=" + text }
+
+// Enabled returns true if the service manager expects notifications.
+func Enabled() bool { return os.Getenv("NOTIFY_SOCKET") != "" }
+
+// Notify sends state, i.e., newline-separated assignments like Ready, to the service manager.
+// If the service manager does not expect notifications ($NOTIFY_SOCKET is unset), Notify returns false and no error.
+func Notify(state string) (sent bool, err error) {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return false, nil
+	}
+	if addr[0] == '@' {
+		addr = "\x00" + addr[1:] // abstract namespace
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return false, errors.Wrap(err, "cannot connect to $NOTIFY_SOCKET")
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, errors.Wrap(err, "cannot write to $NOTIFY_SOCKET")
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns the interval at which the service manager expects Watchdog keepalives
+// ($WATCHDOG_USEC), or ok = false if the watchdog is disabled for this process.
+func WatchdogInterval() (interval time.Duration, ok bool, err error) {
+	usecStr := os.Getenv("WATCHDOG_USEC")
+	if usecStr == "" {
+		return 0, false, nil
+	}
+	if pidStr := os.Getenv("WATCHDOG_PID"); pidStr != "" {
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil {
+			return 0, false, errors.Wrap(err, "invalid $WATCHDOG_PID")
+		}
+		if pid != os.Getpid() {
+			return 0, false, nil
+		}
+	}
+	usec, err := strconv.ParseUint(usecStr, 10, 64)
+	if err != nil {
+		return 0, false, errors.Wrap(err, "invalid $WATCHDOG_USEC")
+	}
+	if usec == 0 {
+		return 0, false, errors.New("$WATCHDOG_USEC must be positive")
+	}
+	return time.Duration(usec) * time.Microsecond, true, nil
+}
@@ -0,0 +1,81 @@
+package sdnotify_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/util/sdnotify"
+)
+
+// setenv sets key to value and returns a function that restores the previous value
+func setenv(t *testing.T, key, value string) (restore func()) {
+	prev, ok := os.LookupEnv(key)
+	require.NoError(t, os.Setenv(key, value))
+	return func() {
+		if ok {
+			os.Setenv(key, prev)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestNotify(t *testing.T) {
+	defer setenv(t, "NOTIFY_SOCKET", "")()
+	sent, err := sdnotify.Notify(sdnotify.Ready)
+	assert.NoError(t, err)
+	assert.False(t, sent)
+
+	dir, err := ioutil.TempDir("", "zrepl-sdnotify")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	defer setenv(t, "NOTIFY_SOCKET", path)()
+	assert.True(t, sdnotify.Enabled())
+	sent, err = sdnotify.Notify(sdnotify.Ready + "\n" + sdnotify.Status("1 of 1 jobs running"))
+	require.NoError(t, err)
+	assert.True(t, sent)
+
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(10*time.Second)))
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "READY=1\nSTATUS=1 of 1 jobs running", string(buf[:n]))
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	defer setenv(t, "WATCHDOG_USEC", "")()
+	_, ok, err := sdnotify.WatchdogInterval()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	defer setenv(t, "WATCHDOG_USEC", "30000000")()
+	defer setenv(t, "WATCHDOG_PID", strconv.Itoa(os.Getpid()))()
+	interval, ok, err := sdnotify.WatchdogInterval()
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 30*time.Second, interval)
+
+	// the watchdog is meant for another process
+	defer setenv(t, "WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))()
+	_, ok, err = sdnotify.WatchdogInterval()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	defer setenv(t, "WATCHDOG_PID", "")()
+	defer setenv(t, "WATCHDOG_USEC", "foo")()
+	_, _, err = sdnotify.WatchdogInterval()
+	assert.Error(t, err)
+}
@@ -0,0 +1,127 @@
+package semaphore
+
+import (
+	"context"
+	"sync"
+
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+)
+
+// Fair is a semaphore whose waiters are grouped into named queues.
+// Free slots are handed out round-robin between the queues that have waiters,
+// FIFO within each queue. Thus, a queue with many waiters cannot starve others.
+type Fair struct {
+	mtx    sync.Mutex
+	max    int64
+	active int64
+	queues map[string][]*fairWaiter
+	// names of the queues in queues, in the order in which they are served next
+	order []string
+}
+
+type fairWaiter struct {
+	ready   chan struct{}
+	granted bool // protected by Fair.mtx
+}
+
+func NewFair(max int64) *Fair {
+	if max < 1 {
+		panic("max must be at least 1")
+	}
+	return &Fair{
+		max:    max,
+		queues: make(map[string][]*fairWaiter),
+	}
+}
+
+type FairAcquireGuard struct {
+	s        *Fair
+	released bool
+}
+
+// The returned FairAcquireGuard is not goroutine-safe.
+func (s *Fair) Acquire(ctx context.Context, queue string) (*FairAcquireGuard, error) {
+	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
+
+	s.mtx.Lock()
+	if s.active < s.max && len(s.order) == 0 {
+		s.active++
+		s.mtx.Unlock()
+		return &FairAcquireGuard{s, false}, nil
+	}
+	w := &fairWaiter{ready: make(chan struct{})}
+	if _, ok := s.queues[queue]; !ok {
+		s.order = append(s.order, queue)
+	}
+	s.queues[queue] = append(s.queues[queue], w)
+	s.mtx.Unlock()
+
+	select {
+	case <-w.ready:
+		return &FairAcquireGuard{s, false}, nil
+	case <-ctx.Done():
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if w.granted {
+		// lost the race against grantWaiters, give the slot to the next waiter
+		s.active--
+		s.grantWaiters()
+		return nil, ctx.Err()
+	}
+	q := s.queues[queue]
+	for i := range q {
+		if q[i] == w {
+			q = append(q[:i], q[i+1:]...)
+			break
+		}
+	}
+	if len(q) > 0 {
+		s.queues[queue] = q
+	} else {
+		s.removeQueue(queue)
+	}
+	return nil, ctx.Err()
+}
+
+// caller must hold s.mtx
+func (s *Fair) grantWaiters() {
+	for s.active < s.max && len(s.order) > 0 {
+		queue := s.order[0]
+		s.order = s.order[1:]
+		q := s.queues[queue]
+		w := q[0]
+		if len(q) > 1 {
+			s.queues[queue] = q[1:]
+			s.order = append(s.order, queue)
+		} else {
+			delete(s.queues, queue)
+		}
+		w.granted = true
+		close(w.ready)
+		s.active++
+	}
+}
+
+// caller must hold s.mtx
+func (s *Fair) removeQueue(queue string) {
+	delete(s.queues, queue)
+	for i := range s.order {
+		if s.order[i] == queue {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			return
+		}
+	}
+}
+
+func (g *FairAcquireGuard) Release() {
+	if g == nil || g.released {
+		return
+	}
+	g.released = true
+	g.s.mtx.Lock()
+	defer g.s.mtx.Unlock()
+	g.s.active--
+	g.s.grantWaiters()
+}
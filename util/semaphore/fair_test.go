@@ -0,0 +1,84 @@
+package semaphore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+)
+
+func fairNumWaiters(s *Fair) (n int) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, q := range s.queues {
+		n += len(q)
+	}
+	return n
+}
+
+func TestFairRoundRobin(t *testing.T) {
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+
+	s := NewFair(1)
+	first, err := s.Acquire(ctx, "a")
+	require.NoError(t, err)
+
+	type acquisition struct {
+		name  string
+		guard *FairAcquireGuard
+	}
+	acquired := make(chan acquisition)
+	enqueue := func(queue, name string) {
+		n := fairNumWaiters(s)
+		go func() {
+			ctx, end := trace.WithTaskFromStack(ctx)
+			defer end()
+			g, err := s.Acquire(ctx, queue)
+			assert.NoError(t, err)
+			acquired <- acquisition{name, g}
+		}()
+		for fairNumWaiters(s) == n {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	enqueue("a", "a1")
+	enqueue("a", "a2")
+	enqueue("a", "a3")
+	enqueue("b", "b1")
+	enqueue("c", "c1")
+
+	var order []string
+	first.Release()
+	for i := 0; i < 5; i++ {
+		a := <-acquired
+		order = append(order, a.name)
+		a.guard.Release()
+	}
+	assert.Equal(t, []string{"a1", "b1", "c1", "a2", "a3"}, order)
+}
+
+func TestFairAcquireCancel(t *testing.T) {
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+
+	s := NewFair(1)
+	g, err := s.Acquire(ctx, "a")
+	require.NoError(t, err)
+
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = s.Acquire(cctx, "b")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 0, fairNumWaiters(s))
+
+	g.Release()
+	g.Release() // idempotent
+	g, err = s.Acquire(ctx, "b")
+	require.NoError(t, err)
+	g.Release()
+}
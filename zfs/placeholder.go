@@ -5,6 +5,7 @@ import (
 	"crypto/sha512"
 	"encoding/hex"
 	"fmt"
+	"sort"
 
 	"github.com/pkg/errors"
 
@@ -88,7 +89,12 @@ const (
 	FilesystemPlaceholderCreateEncryptionOff
 )
 
-func ZFSCreatePlaceholderFilesystem(ctx context.Context, fs *DatasetPath, parent *DatasetPath, encryption FilesystemPlaceholderCreateEncryptionValue) (err error) {
+// PlaceholderDefaultProperties are the properties of placeholders if the receiver does not configure them.
+var PlaceholderDefaultProperties = map[string]string{"mountpoint": "none"}
+
+// ZFSCreatePlaceholderFilesystem creates the placeholder fs with the given properties (see PlaceholderDefaultProperties),
+// which must not include the encryption property or PlaceholderPropertyName.
+func ZFSCreatePlaceholderFilesystem(ctx context.Context, fs *DatasetPath, parent *DatasetPath, encryption FilesystemPlaceholderCreateEncryptionValue, properties map[string]string) (err error) {
 	if fs.Length() == 1 {
 		return fmt.Errorf("cannot create %q: pools cannot be created with zfs create", fs.ToString())
 	}
@@ -96,7 +102,14 @@ func ZFSCreatePlaceholderFilesystem(ctx context.Context, fs *DatasetPath, parent
 	cmdline := []string{
 		"create",
 		"-o", fmt.Sprintf("%s=%s", PlaceholderPropertyName, placeholderPropertyOn),
-		"-o", "mountpoint=none",
+	}
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmdline = append(cmdline, "-o", fmt.Sprintf("%s=%s", name, properties[name]))
 	}
 
 	if !encryption.IsAFilesystemPlaceholderCreateEncryptionValue() {
@@ -26,6 +26,10 @@ type ResumeToken struct {
 	HasLargeBlockOK, LargeBlockOK bool
 	HasEmbedOk, EmbedOK           bool
 	HasSavedOk, SavedOk           bool
+	// number of bytes of the stream that were already received before the
+	// interruption, informational only
+	HasBytes bool
+	Bytes    uint64
 }
 
 var resumeTokenNVListRE = regexp.MustCompile(`\t(\S+) = (.*)`)
@@ -231,6 +235,12 @@ func ParseResumeToken(ctx context.Context, token string) (*ResumeToken, error) {
 			rt.HasToGUID = true
 		case "toname":
 			rt.ToName = val
+		case "bytes":
+			rt.Bytes, err = strconv.ParseUint(val, 0, 64)
+			if err != nil {
+				return nil, ResumeTokenParsingError
+			}
+			rt.HasBytes = true
 		case "rawok":
 			rt.HasRawOk = true
 			rt.RawOK, err = strconv.ParseBool(val)
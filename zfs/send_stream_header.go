@@ -0,0 +1,62 @@
+package zfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// SendStreamBegin holds the fields of the DRR_BEGIN record that starts a send stream.
+type SendStreamBegin struct {
+	// 0 for full streams
+	FromGUID, ToGUID uint64
+	ToName           string
+	// the stream was sent with zfs send --raw, i.e., it contains the sender's encrypted blocks
+	Raw bool
+}
+
+// Layout of struct dmu_replay_record with the drr_begin member of its union,
+// see include/sys/zfs_ioctl.h in the ZFS source tree.
+const (
+	sendStreamDRRBegin                 = 0
+	sendStreamBackupMagic       uint64 = 0x2F5bacbac
+	sendStreamOffsetMagic              = 8
+	sendStreamOffsetVersionInfo        = 16
+	sendStreamOffsetToGUID             = 40
+	sendStreamOffsetFromGUID           = 48
+	sendStreamOffsetToName             = 56
+	sendStreamBeginLen                 = sendStreamOffsetToName + 256 // MAXNAMELEN
+
+	// DMU_BACKUP_FEATURE_RAW in the feature flags, bits 2 to 31 of drr_versioninfo
+	sendStreamFeatureRaw uint64 = 1 << 24
+)
+
+// ParseSendStreamBegin parses the DRR_BEGIN record at the start of the send stream prefix b.
+// The record is in the byte order of the sending host, detected through its magic number.
+func ParseSendStreamBegin(b []byte) (*SendStreamBegin, error) {
+	if len(b) < sendStreamBeginLen {
+		return nil, fmt.Errorf("send stream too short for begin record: %d bytes", len(b))
+	}
+	var order binary.ByteOrder
+	switch sendStreamBackupMagic {
+	case binary.LittleEndian.Uint64(b[sendStreamOffsetMagic:]):
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint64(b[sendStreamOffsetMagic:]):
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("send stream does not start with a begin record: invalid magic number")
+	}
+	if typ := order.Uint32(b); typ != sendStreamDRRBegin {
+		return nil, fmt.Errorf("send stream does not start with a begin record: record type %d", typ)
+	}
+	name := b[sendStreamOffsetToName:sendStreamBeginLen]
+	if i := bytes.IndexByte(name, 0); i >= 0 {
+		name = name[:i]
+	}
+	return &SendStreamBegin{
+		FromGUID: order.Uint64(b[sendStreamOffsetFromGUID:]),
+		ToGUID:   order.Uint64(b[sendStreamOffsetToGUID:]),
+		ToName:   string(name),
+		Raw:      (order.Uint64(b[sendStreamOffsetVersionInfo:])>>2)&sendStreamFeatureRaw != 0,
+	}, nil
+}
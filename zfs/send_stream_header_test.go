@@ -0,0 +1,51 @@
+package zfs
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSendStreamBegin(t *testing.T) {
+	record := func(order binary.ByteOrder, from, to uint64, name string) []byte {
+		b := make([]byte, 312+100) // followed by more records
+		order.PutUint32(b, sendStreamDRRBegin)
+		order.PutUint64(b[8:], sendStreamBackupMagic)
+		order.PutUint64(b[40:], to)
+		order.PutUint64(b[48:], from)
+		copy(b[56:], name)
+		return b
+	}
+
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		begin, err := ParseSendStreamBegin(record(order, 0x1234, 0x5678, "pool/data@b"))
+		require.NoError(t, err, "%s", order)
+		assert.Equal(t, &SendStreamBegin{FromGUID: 0x1234, ToGUID: 0x5678, ToName: "pool/data@b"}, begin)
+	}
+
+	begin, err := ParseSendStreamBegin(record(binary.LittleEndian, 0, 0x5678, "pool/data@a"))
+	require.NoError(t, err)
+	assert.Zero(t, begin.FromGUID, "full stream")
+	assert.False(t, begin.Raw)
+
+	raw := record(binary.BigEndian, 0, 0x5678, "pool/data@a")
+	binary.BigEndian.PutUint64(raw[16:], (sendStreamFeatureRaw<<2)|1) // DMU_SUBSTREAM
+	begin, err = ParseSendStreamBegin(raw)
+	require.NoError(t, err)
+	assert.True(t, begin.Raw)
+
+	_, err = ParseSendStreamBegin(record(binary.LittleEndian, 1, 2, "x")[:100])
+	assert.Error(t, err, "too short")
+
+	b := record(binary.LittleEndian, 1, 2, "x")
+	b[8] = 0
+	_, err = ParseSendStreamBegin(b)
+	assert.Error(t, err, "invalid magic")
+
+	b = record(binary.LittleEndian, 1, 2, "x")
+	b[0] = 1
+	_, err = ParseSendStreamBegin(b)
+	assert.Error(t, err, "not a begin record")
+}
@@ -100,6 +100,15 @@ type FilesystemVersion struct {
 
 	// userrefs field (snapshots only)
 	UserRefs OptionUint64
+
+	// used property in bytes (snapshots only), 0 if unknown
+	Used uint64
+
+	// the snapshot has clones (snapshots only), false if unknown
+	IsCloneOrigin bool
+
+	// value of PruneMarkProperty (snapshots only), zero if not marked
+	PruneMarkedAt time.Time
 }
 
 type OptionUint64 struct {
@@ -147,6 +156,9 @@ func (v FilesystemVersion) ToSendArgVersion() ZFSSendArgVersion {
 type ParseFilesystemVersionArgs struct {
 	fullname                            string
 	guid, createtxg, creation, userrefs string
+	used                                string // optional
+	clones                              string // optional
+	pruneMark                           string // optional
 }
 
 func ParseFilesystemVersion(args ParseFilesystemVersionArgs) (v FilesystemVersion, err error) {
@@ -185,6 +197,20 @@ func ParseFilesystemVersion(args ParseFilesystemVersionArgs) (v FilesystemVersio
 			return v, err
 		}
 		v.UserRefs.Valid = true
+		if args.used != "" {
+			if v.Used, err = strconv.ParseUint(args.used, 10, 64); err != nil {
+				err = errors.Wrapf(err, "cannot parse used %q", args.used)
+				return v, err
+			}
+		}
+		// comma-separated list of clones, "-" on some platforms if there are none
+		v.IsCloneOrigin = args.clones != "" && args.clones != "-"
+		// a mark that does not parse is treated as no mark, i.e., it delays the destroy
+		if args.pruneMark != "" && args.pruneMark != "-" {
+			if t, err := time.Parse(time.RFC3339, args.pruneMark); err == nil {
+				v.PruneMarkedAt = t
+			}
+		}
 	default:
 		panic(v.Type)
 	}
@@ -232,7 +258,7 @@ func ZFSListFilesystemVersions(ctx context.Context, fs *DatasetPath, options Lis
 	go func() {
 		defer wg.Done()
 		ZFSListChan(ctx, listResults,
-			[]string{"name", "guid", "createtxg", "creation", "userrefs"},
+			[]string{"name", "guid", "createtxg", "creation", "userrefs", "used", "clones", PruneMarkProperty},
 			fs,
 			"-r", "-d", "1",
 			"-t", options.typesFlagArgs(),
@@ -252,6 +278,9 @@ func ZFSListFilesystemVersions(ctx context.Context, fs *DatasetPath, options Lis
 			createtxg: line[2],
 			creation:  line[3],
 			userrefs:  line[4],
+			used:      line[5],
+			clones:    line[6],
+			pruneMark: line[7],
 		}
 		v, err := ParseFilesystemVersion(args)
 		if err != nil {
@@ -279,3 +308,22 @@ func ZFSGetFilesystemVersion(ctx context.Context, ds string) (v FilesystemVersio
 		userrefs:  props.Get("userrefs"),
 	})
 }
+
+// PruneMarkProperty is the user property with which a pruner with a grace period
+// marks snapshots for destruction by a later run.
+// Its value is the time of marking in RFC 3339 format.
+const PruneMarkProperty = "zrepl:prune_marked_at"
+
+func ZFSSetPruneMark(ctx context.Context, fs *DatasetPath, v *FilesystemVersion, at time.Time) error {
+	if v.Type != Snapshot {
+		return errors.Errorf("only snapshots can be marked for destruction, got %s", v.ToAbsPath(fs))
+	}
+	return zfsSet(ctx, v.ToAbsPath(fs), map[string]string{PruneMarkProperty: at.Format(time.RFC3339)})
+}
+
+func ZFSClearPruneMark(ctx context.Context, fs *DatasetPath, v *FilesystemVersion) error {
+	if v.Type != Snapshot {
+		return errors.Errorf("only snapshots can be marked for destruction, got %s", v.ToAbsPath(fs))
+	}
+	return zfsInherit(ctx, v.ToAbsPath(fs), PruneMarkProperty)
+}
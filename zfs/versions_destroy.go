@@ -39,7 +39,13 @@ func (o *DestroySnapOp) String() string {
 }
 
 func ZFSDestroyFilesystemVersions(ctx context.Context, reqs []*DestroySnapOp) {
-	doDestroy(ctx, reqs, destroyerSingleton)
+	doDestroy(ctx, reqs, destroyerSingleton, false)
+}
+
+// Like ZFSDestroyFilesystemVersions, but snapshots of a filesystem that directly follow
+// each other in creation order are destroyed using the range syntax fs@first%last.
+func ZFSDestroyFilesystemVersionsRanged(ctx context.Context, reqs []*DestroySnapOp) {
+	doDestroy(ctx, reqs, destroyerSingleton, true)
 }
 
 func setDestroySnapOpErr(b []*DestroySnapOp, err error) {
@@ -51,9 +57,11 @@ func setDestroySnapOpErr(b []*DestroySnapOp, err error) {
 type destroyer interface {
 	Destroy(ctx context.Context, args []string) error
 	DestroySnapshotsCommaSyntaxSupported(context.Context) (bool, error)
+	// names of all snapshots of fs, oldest first
+	ListSnapshots(ctx context.Context, fs string) ([]string, error)
 }
 
-func doDestroy(ctx context.Context, reqs []*DestroySnapOp, e destroyer) {
+func doDestroy(ctx context.Context, reqs []*DestroySnapOp, e destroyer, ranged bool) {
 
 	var validated []*DestroySnapOp
 	for _, req := range reqs {
@@ -80,7 +88,7 @@ func doDestroy(ctx context.Context, reqs []*DestroySnapOp, e destroyer) {
 	if !commaSupported {
 		doDestroySeq(ctx, reqs, e)
 	} else {
-		doDestroyBatched(ctx, reqs, e)
+		doDestroyBatched(ctx, reqs, e, ranged)
 	}
 }
 
@@ -90,13 +98,64 @@ func doDestroySeq(ctx context.Context, reqs []*DestroySnapOp, e destroyer) {
 	}
 }
 
-func doDestroyBatched(ctx context.Context, reqs []*DestroySnapOp, d destroyer) {
+func doDestroyBatched(ctx context.Context, reqs []*DestroySnapOp, d destroyer, ranged bool) {
 	perFS := buildBatches(reqs)
 	for _, fsbatch := range perFS {
-		doDestroyBatchedRec(ctx, fsbatch, d)
+		var order snapshotOrder
+		if ranged && len(fsbatch) >= 3 { // see batchArgNames
+			snaps, err := d.ListSnapshots(ctx, fsbatch[0].Filesystem)
+			if err != nil {
+				// ranges are an optimization, comma syntax works regardless
+				debug("batch destroy: cannot list snapshots, not using ranges: %s", err)
+			} else {
+				order = make(snapshotOrder, len(snaps))
+				for i, s := range snaps {
+					order[s] = i
+				}
+			}
+		}
+		doDestroyBatchedRec(ctx, fsbatch, d, order)
 	}
 }
 
+// index of each snapshot of a filesystem in creation order, nil if ranges are not used
+type snapshotOrder map[string]int
+
+// batchArgNames returns the snapshot part of the destroy argument for names.
+// Runs of at least three names that are consecutive in order are joined to a range.
+func batchArgNames(names []string, order snapshotOrder) string {
+	if order == nil {
+		return strings.Join(names, ",")
+	}
+	var unordered []string
+	ordered := make([]string, 0, len(names))
+	for _, n := range names {
+		if _, ok := order[n]; ok {
+			ordered = append(ordered, n)
+		} else {
+			unordered = append(unordered, n)
+		}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return order[ordered[i]] < order[ordered[j]]
+	})
+
+	var comps []string
+	for i := 0; i < len(ordered); {
+		j := i + 1
+		for ; j < len(ordered) && order[ordered[j]] == order[ordered[j-1]]+1; j++ {
+		}
+		if j-i >= 3 {
+			comps = append(comps, fmt.Sprintf("%s%%%s", ordered[i], ordered[j-1]))
+		} else {
+			comps = append(comps, ordered[i:j]...)
+		}
+		i = j
+	}
+	comps = append(comps, unordered...)
+	return strings.Join(comps, ",")
+}
+
 func buildBatches(reqs []*DestroySnapOp) [][]*DestroySnapOp {
 	if len(reqs) == 0 {
 		return nil
@@ -127,7 +186,7 @@ func buildBatches(reqs []*DestroySnapOp) [][]*DestroySnapOp {
 }
 
 // batch must be on same Filesystem, panics otherwise
-func tryBatch(ctx context.Context, batch []*DestroySnapOp, d destroyer) error {
+func tryBatch(ctx context.Context, batch []*DestroySnapOp, d destroyer, order snapshotOrder) error {
 	if len(batch) == 0 {
 		return nil
 	}
@@ -140,18 +199,18 @@ func tryBatch(ctx context.Context, batch []*DestroySnapOp, d destroyer) error {
 			panic("inconsistent batch")
 		}
 	}
-	batchArg := fmt.Sprintf("%s@%s", batchFS, strings.Join(batchNames, ","))
+	batchArg := fmt.Sprintf("%s@%s", batchFS, batchArgNames(batchNames, order))
 	return d.Destroy(ctx, []string{batchArg})
 }
 
 // fsbatch must be on same filesystem
-func doDestroyBatchedRec(ctx context.Context, fsbatch []*DestroySnapOp, d destroyer) {
+func doDestroyBatchedRec(ctx context.Context, fsbatch []*DestroySnapOp, d destroyer, order snapshotOrder) {
 	if len(fsbatch) <= 1 {
 		doDestroySeq(ctx, fsbatch, d)
 		return
 	}
 
-	err := tryBatch(ctx, fsbatch, d)
+	err := tryBatch(ctx, fsbatch, d, order)
 	if err == nil {
 		setDestroySnapOpErr(fsbatch, nil)
 		return
@@ -161,8 +220,8 @@ func doDestroyBatchedRec(ctx context.Context, fsbatch []*DestroySnapOp, d destro
 		// see TestExcessiveArgumentsResultInE2BIG
 		// try halving batch size, assuming snapshots names are roughly the same length
 		debug("batch destroy: E2BIG encountered: %s", err)
-		doDestroyBatchedRec(ctx, fsbatch[0:len(fsbatch)/2], d)
-		doDestroyBatchedRec(ctx, fsbatch[len(fsbatch)/2:], d)
+		doDestroyBatchedRec(ctx, fsbatch[0:len(fsbatch)/2], d, order)
+		doDestroyBatchedRec(ctx, fsbatch[len(fsbatch)/2:], d, order)
 		return
 	}
 
@@ -187,7 +246,7 @@ func doDestroyBatchedRec(ctx context.Context, fsbatch []*DestroySnapOp, d destro
 			}
 		}
 
-		err := tryBatch(ctx, strippedBatch, d)
+		err := tryBatch(ctx, strippedBatch, d, order)
 		if err != nil {
 			// run entire batch sequentially if the stripped one fails
 			// (it shouldn't because we stripped erroneous datasets)
@@ -217,6 +276,25 @@ func (d destroyerImpl) Destroy(ctx context.Context, args []string) error {
 	return ZFSDestroy(ctx, args[0])
 }
 
+func (d destroyerImpl) ListSnapshots(ctx context.Context, fs string) ([]string, error) {
+	p, err := NewDatasetPath(fs)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := ZFSListFilesystemVersions(ctx, p, ListFilesystemVersionsOptions{Types: Snapshots})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].CreateTXG < versions[j].CreateTXG
+	})
+	names := make([]string, len(versions))
+	for i := range versions {
+		names[i] = versions[i].Name
+	}
+	return names, nil
+}
+
 var batchDestroyFeatureCheck struct {
 	once   sync.Once
 	enable bool
@@ -23,6 +23,35 @@ type mockBatchDestroy struct {
 	undestroyable    *regexp.Regexp
 	randomerror      string
 	e2biglen         int
+	snapshots        map[string][]string // per filesystem, oldest first
+}
+
+func (m *mockBatchDestroy) ListSnapshots(_ context.Context, fs string) ([]string, error) {
+	snaps, ok := m.snapshots[fs]
+	if !ok {
+		return nil, fmt.Errorf("mock: unknown filesystem %q", fs)
+	}
+	return snaps, nil
+}
+
+// expands the range syntax first%last of name using m.snapshots
+func (m *mockBatchDestroy) expandRange(fs, name string) []string {
+	comps := strings.SplitN(name, "%", 2)
+	if len(comps) != 2 {
+		return []string{name}
+	}
+	var expanded []string
+	in := false
+	for _, s := range m.snapshots[fs] {
+		in = in || s == comps[0]
+		if in {
+			expanded = append(expanded, s)
+		}
+		if s == comps[1] {
+			return expanded
+		}
+	}
+	panic("mock: invalid range " + name)
 }
 
 func (m *mockBatchDestroy) DestroySnapshotsCommaSyntaxSupported(_ context.Context) (bool, error) {
@@ -57,6 +86,11 @@ func (m *mockBatchDestroy) Destroy(ctx context.Context, args []string) error {
 		panic(vt)
 	}
 	snapnames[0] = firstsnapname
+	var expanded []string
+	for _, snap := range snapnames {
+		expanded = append(expanded, m.expandRange(fs, snap)...)
+	}
+	snapnames = expanded
 
 	var undestroyable []string
 	if m.undestroyable != nil {
@@ -108,7 +142,7 @@ func TestBatchDestroySnaps(t *testing.T) {
 			randomerror:      "randomerror",
 		}
 
-		doDestroy(context.TODO(), opsTemplate, mock)
+		doDestroy(context.TODO(), opsTemplate, mock, false)
 
 		assert.NoError(t, errs[0])
 		assert.NoError(t, errs[1])
@@ -152,7 +186,7 @@ func TestBatchDestroySnaps(t *testing.T) {
 			undestroyable:    regexp.MustCompile(`.*`),
 		}
 
-		doDestroy(context.TODO(), opsTemplate, mock)
+		doDestroy(context.TODO(), opsTemplate, mock, false)
 
 		defer mock.mtx.Lock().Unlock()
 		assert.Equal(
@@ -174,7 +208,7 @@ func TestBatchDestroySnaps(t *testing.T) {
 			randomerror:      "randomerror",
 		}
 
-		doDestroy(context.TODO(), opsTemplate, mock)
+		doDestroy(context.TODO(), opsTemplate, mock, false)
 
 		assert.NoError(t, errs[0])
 		assert.NoError(t, errs[1])
@@ -209,7 +243,7 @@ func TestBatchDestroySnaps(t *testing.T) {
 
 	t.Run("empty_ops", func(t *testing.T) {
 		mock := &mockBatchDestroy{}
-		doDestroy(context.TODO(), nil, mock)
+		doDestroy(context.TODO(), nil, mock, false)
 		defer mock.mtx.Lock().Unlock()
 		assert.Empty(t, mock.calls)
 	})
@@ -218,7 +252,7 @@ func TestBatchDestroySnaps(t *testing.T) {
 		mock := &mockBatchDestroy{}
 		var err error
 		ops := []*DestroySnapOp{&DestroySnapOp{"somefs", "", &err}}
-		doDestroy(context.TODO(), ops, mock)
+		doDestroy(context.TODO(), ops, mock, false)
 		assert.Error(t, err)
 		defer mock.mtx.Lock().Unlock()
 		assert.Empty(t, mock.calls)
@@ -228,7 +262,7 @@ func TestBatchDestroySnaps(t *testing.T) {
 		mock := &mockBatchDestroy{}
 		var err error
 		ops := []*DestroySnapOp{&DestroySnapOp{"", "fsname", &err}}
-		doDestroy(context.TODO(), ops, mock)
+		doDestroy(context.TODO(), ops, mock, false)
 		assert.Error(t, err)
 		defer mock.mtx.Lock().Unlock()
 		assert.Empty(t, mock.calls)
@@ -259,7 +293,7 @@ func TestBatchDestroySnaps(t *testing.T) {
 			&DestroySnapOp{"2222", "10", &dummy},
 		}
 
-		doDestroy(context.TODO(), reqs, mock)
+		doDestroy(context.TODO(), reqs, mock, false)
 
 		defer mock.mtx.Lock().Unlock()
 		assert.Equal(
@@ -280,6 +314,51 @@ func TestBatchDestroySnaps(t *testing.T) {
 
 }
 
+func TestBatchDestroyRanged(t *testing.T) {
+	errs := make([]error, 8)
+	op := func(fs, name string, i int) *DestroySnapOp {
+		return &DestroySnapOp{fs, name, &errs[i]}
+	}
+	ops := []*DestroySnapOp{
+		op("zroot/a", "1", 0),
+		op("zroot/a", "2", 1),
+		op("zroot/a", "3", 2),
+		op("zroot/a", "5", 3), // 4 is kept
+		op("zroot/a", "6", 4),
+		op("zroot/a", "7", 5),
+		op("zroot/a", "8", 6),
+		op("zroot/b", "x", 7), // listing fails, no ranges
+	}
+	mock := &mockBatchDestroy{
+		undestroyable: regexp.MustCompile(`^7$`),
+		snapshots: map[string][]string{
+			"zroot/a": {"1", "2", "3", "4", "5", "6", "7", "8", "9"},
+		},
+	}
+
+	doDestroy(context.TODO(), ops, mock, true)
+
+	for i, err := range errs {
+		if i == 5 {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+	defer mock.mtx.Lock().Unlock()
+	assert.Equal(
+		t,
+		[]string{
+			"zroot/a@1%3,5%8",
+			"zroot/a@1%3,5,6,8", // eliminate undestroyables, ranges must not include them
+			"zroot/a@7",
+			"zroot/b@x",
+		},
+		mock.calls,
+	)
+	assert.Equal(t, "1,3,a,b", batchArgNames([]string{"1", "3", "a", "b"}, snapshotOrder{"1": 0, "3": 2}))
+}
+
 func TestExcessiveArgumentsResultInE2BIG(t *testing.T) {
 	// FIXME dynamic value
 	const maxArgumentLength = 1 << 20 // higher than any OS we know, should always fail
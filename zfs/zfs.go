@@ -1176,8 +1176,13 @@ type RecvOptions struct {
 	// Rollback to the oldest snapshot, destroy it, then perform `recv -F`.
 	// Note that this doesn't change property values, i.e. an existing local property value will be kept.
 	RollbackAndForceRecv bool
+	// Set -F flag without prior rollback, i.e., an incremental receive discards the changes since
+	// the incremental source and destroys the snapshots newer than it.
+	Force bool
 	// Set -s flag used for resumable send & recv
 	SavePartialRecvState bool
+	// Set -u flag, i.e., do not mount the received filesystem
+	NoMount bool
 
 	InheritProperties  []zfsprop.Property
 	OverrideProperties map[zfsprop.Property]string
@@ -1185,12 +1190,15 @@ type RecvOptions struct {
 
 func (opts RecvOptions) buildRecvFlags() []string {
 	args := make([]string, 0)
-	if opts.RollbackAndForceRecv {
+	if opts.RollbackAndForceRecv || opts.Force {
 		args = append(args, "-F")
 	}
 	if opts.SavePartialRecvState {
 		args = append(args, "-s")
 	}
+	if opts.NoMount {
+		args = append(args, "-u")
+	}
 	if opts.InheritProperties != nil {
 		for _, prop := range opts.InheritProperties {
 			args = append(args, "-x", string(prop))
@@ -1482,6 +1490,18 @@ func zfsSet(ctx context.Context, path string, props map[string]string) error {
 	return err
 }
 
+func zfsInherit(ctx context.Context, path string, prop string) error {
+	cmd := zfscmd.CommandContext(ctx, ZFS_BINARY, "inherit", prop, path)
+	stdio, err := cmd.CombinedOutput()
+	if err != nil {
+		err = &ZFSError{
+			Stderr:  stdio,
+			WaitErr: err,
+		}
+	}
+	return err
+}
+
 func ZFSSet(ctx context.Context, fs *DatasetPath, props map[string]string) error {
 	return zfsSet(ctx, fs.ToString(), props)
 }
@@ -1490,6 +1510,11 @@ func ZFSGet(ctx context.Context, fs *DatasetPath, props []string) (*ZFSPropertie
 	return zfsGet(ctx, fs.ToString(), props, SourceAny)
 }
 
+// ZFSGetLocal is like ZFSGet, but the values of properties that are not set locally on fs are empty.
+func ZFSGetLocal(ctx context.Context, fs *DatasetPath, props []string) (*ZFSProperties, error) {
+	return zfsGet(ctx, fs.ToString(), props, SourceLocal)
+}
+
 // The returned error includes requested filesystem and version as quoted strings in its error message
 func ZFSGetGUID(ctx context.Context, fs string, version string) (g uint64, err error) {
 	defer func(e *error) {
@@ -1539,6 +1564,22 @@ func ZFSGetMountpoint(ctx context.Context, fs string) (*GetMountpointOutput, err
 	return o, nil
 }
 
+// ZFSMount mounts the filesystem fs at its mountpoint.
+func ZFSMount(ctx context.Context, fs string) error {
+	if err := EntityNamecheck(fs, EntityTypeFilesystem); err != nil {
+		return err
+	}
+	cmd := zfscmd.CommandContext(ctx, ZFS_BINARY, "mount", fs)
+	stdio, err := cmd.CombinedOutput()
+	if err != nil {
+		err = &ZFSError{
+			Stderr:  stdio,
+			WaitErr: err,
+		}
+	}
+	return err
+}
+
 func ZFSGetRawAnySource(ctx context.Context, path string, props []string) (*ZFSProperties, error) {
 	return zfsGet(ctx, path, props, SourceAny)
 }
@@ -1832,6 +1873,11 @@ func ZFSDestroyIdempotent(ctx context.Context, path string) error {
 }
 
 func ZFSSnapshot(ctx context.Context, fs *DatasetPath, name string, recursive bool) (err error) {
+	return ZFSSnapshotWithProperties(ctx, fs, name, recursive, nil)
+}
+
+// props are set atomically with the creation of the snapshot (`zfs snapshot -o`)
+func ZFSSnapshotWithProperties(ctx context.Context, fs *DatasetPath, name string, recursive bool, props map[string]string) (err error) {
 
 	promTimer := prometheus.NewTimer(prom.ZFSSnapshotDuration.WithLabelValues(fs.ToString()))
 	defer promTimer.ObserveDuration()
@@ -1841,7 +1887,17 @@ func ZFSSnapshot(ctx context.Context, fs *DatasetPath, name string, recursive bo
 		return errors.Wrap(err, "zfs snapshot")
 	}
 
-	cmd := zfscmd.CommandContext(ctx, ZFS_BINARY, "snapshot", snapname)
+	args := []string{"snapshot"}
+	propNames := make([]string, 0, len(props))
+	for k := range props {
+		propNames = append(propNames, k)
+	}
+	sort.Strings(propNames)
+	for _, k := range propNames {
+		args = append(args, "-o", fmt.Sprintf("%s=%s", k, props[k]))
+	}
+	args = append(args, snapname)
+	cmd := zfscmd.CommandContext(ctx, ZFS_BINARY, args...)
 	stdio, err := cmd.CombinedOutput()
 	if err != nil {
 		err = &ZFSError{
@@ -1950,6 +2006,19 @@ func ZFSBookmark(ctx context.Context, fs string, v FilesystemVersion, bookmark s
 	return bm, nil
 }
 
+// ZFSRename renames fs and its children to newName in the same pool, creating missing parents of newName.
+func ZFSRename(ctx context.Context, fs, newName *DatasetPath) error {
+	cmd := zfscmd.CommandContext(ctx, ZFS_BINARY, "rename", "-p", fs.ToString(), newName.ToString())
+	stdio, err := cmd.CombinedOutput()
+	if err != nil {
+		return &ZFSError{
+			Stderr:  stdio,
+			WaitErr: err,
+		}
+	}
+	return nil
+}
+
 func ZFSRollback(ctx context.Context, fs *DatasetPath, snapshot FilesystemVersion, rollbackArgs ...string) (err error) {
 
 	snapabs := snapshot.ToAbsPath(fs)
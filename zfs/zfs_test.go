@@ -424,11 +424,21 @@ func TestZFSCommonRecvArgsBuild(t *testing.T) {
 			flagsInclude: []string{"-F"},
 			flagsExclude: []string{"-x", "-o", "-s"},
 		},
+		"Force": {
+			conf:         RecvOptions{Force: true},
+			flagsInclude: []string{"-F"},
+			flagsExclude: []string{"-x", "-o", "-s"},
+		},
 		"PartialSend": {
 			conf:         RecvOptions{SavePartialRecvState: true},
 			flagsInclude: []string{"-s"},
 			flagsExclude: []string{"-x", "-o", "-F"},
 		},
+		"NoMount": {
+			conf:         RecvOptions{NoMount: true},
+			flagsInclude: []string{"-u"},
+			flagsExclude: []string{"-x", "-o", "-F", "-s"},
+		},
 		"Override properties": {
 			conf:         RecvOptions{OverrideProperties: map[zfsprop.Property]string{zfsprop.Property("abc"): "123"}},
 			flagsInclude: []string{"-o", "abc=123"},
@@ -0,0 +1,24 @@
+package zfs
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+// ZFSVersion returns the version of the ZFS userland tools, i.e., the first line of `zfs version`, e.g. zfs-2.1.5-1.
+// ZFS releases before 0.8 do not have the subcommand.
+func ZFSVersion(ctx context.Context) (string, error) {
+	output, err := zfscmd.CommandContext(ctx, ZFS_BINARY, "version").CombinedOutput()
+	if err != nil {
+		return "", errors.Wrapf(err, "zfs version: %s", strings.TrimSpace(string(output)))
+	}
+	lines := strings.SplitN(strings.TrimSpace(string(output)), "\n", 2)
+	if lines[0] == "" {
+		return "", errors.New("zfs version: empty output")
+	}
+	return strings.TrimSpace(lines[0]), nil
+}
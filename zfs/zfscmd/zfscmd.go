@@ -20,15 +20,23 @@ import (
 
 type Cmd struct {
 	cmd                                      *exec.Cmd
+	args                                     []string // as passed to CommandContext, cmd runs the helper if viaHelper
+	viaHelper                                bool
 	ctx                                      context.Context
 	mtx                                      sync.RWMutex
 	startedAt, waitStartedAt, waitReturnedAt time.Time
 	waitReturnEndSpanCb                      trace.DoneFunc
 }
 
+// CommandContext executes zfs and zpool through the helper if one is set, see SetHelper.
 func CommandContext(ctx context.Context, name string, arg ...string) *Cmd {
+	args := append([]string{name}, arg...)
+	if helperArgv := helperCommand(ctx, name, arg); helperArgv != nil {
+		cmd := exec.CommandContext(ctx, helperArgv[0], helperArgv[1:]...)
+		return &Cmd{cmd: cmd, args: args, viaHelper: true, ctx: ctx}
+	}
 	cmd := exec.CommandContext(ctx, name, arg...)
-	return &Cmd{cmd: cmd, ctx: ctx}
+	return &Cmd{cmd: cmd, args: args, ctx: ctx}
 }
 
 // err.(*exec.ExitError).Stderr will NOT be set
@@ -72,7 +80,7 @@ func (c *Cmd) SetStdio(stdio Stdio) {
 }
 
 func (c *Cmd) String() string {
-	return strings.Join(c.cmd.Args, " ") // includes argv[0] if initialized with CommandContext, that's the only way we o it
+	return strings.Join(c.args, " ")
 }
 
 func (c *Cmd) log() Logger {
@@ -85,11 +93,19 @@ func (c *Cmd) log() Logger {
 // If the process is successfully started (err == nil), it is the CALLER'S RESPONSIBILITY to ensure that
 // the spawned process does not outlive the ctx's trace.Task.
 //
+// The ProcessLimits of the ctx passed to CommandContext are applied to the started process,
+// by the helper if the command is executed through it.
+//
 // If this method returns an error, the Cmd instance is invalid. Start must not be called repeatedly.
 func (c *Cmd) Start() (err error) {
 	c.startPre(true)
 	err = c.cmd.Start()
 	c.startPost(err)
+	if err == nil && !c.viaHelper {
+		if limitErr := ApplyProcessLimits(c.ctx, c.cmd.Process); limitErr != nil {
+			c.log().WithError(limitErr).Warn("cannot apply process limits")
+		}
+	}
 	return err
 }
 
@@ -0,0 +1,50 @@
+package zfscmd
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sync"
+)
+
+// HelperCommands are the commands that CommandContext executes through the helper, if one is set.
+var HelperCommands = map[string]bool{
+	"zfs":   true,
+	"zpool": true,
+}
+
+var helper struct {
+	mtx  sync.RWMutex
+	argv []string
+}
+
+// SetHelper makes CommandContext execute HelperCommands through the command argv,
+// which is invoked as
+//
+//	argv... [--limits JSON] -- zfs ARGS...
+//
+// with the JSON encoding of the context's ProcessLimits, see package zfshelper.
+// A nil argv executes all commands directly.
+func SetHelper(argv []string) {
+	helper.mtx.Lock()
+	defer helper.mtx.Unlock()
+	helper.argv = argv
+}
+
+func helperCommand(ctx context.Context, name string, arg []string) []string {
+	helper.mtx.RLock()
+	defer helper.mtx.RUnlock()
+	if helper.argv == nil || !HelperCommands[filepath.Base(name)] {
+		return nil
+	}
+	argv := append([]string{}, helper.argv...)
+	if l := getProcessLimits(ctx); l != nil {
+		limits, err := json.Marshal(l)
+		if err != nil {
+			panic(err) // ProcessLimits only contains basic types
+		}
+		argv = append(argv, "--limits", string(limits))
+	}
+	argv = append(argv, "--", filepath.Base(name))
+	return append(argv, arg...)
+}
@@ -0,0 +1,28 @@
+package zfscmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHelperCommand(t *testing.T) {
+	SetHelper([]string{"/usr/bin/zrepl", "zfs-helper", "exec"})
+	defer SetHelper(nil)
+
+	ctx := context.Background()
+	cmd := CommandContext(ctx, "zfs", "list", "-H")
+	assert.Equal(t, []string{"/usr/bin/zrepl", "zfs-helper", "exec", "--", "zfs", "list", "-H"}, cmd.cmd.Args)
+	assert.Equal(t, "zfs list -H", cmd.String())
+
+	nice := 10
+	ctx = WithProcessLimits(ctx, &ProcessLimits{Nice: &nice})
+	cmd = CommandContext(ctx, "/sbin/zpool", "get")
+	assert.Equal(t, []string{"/usr/bin/zrepl", "zfs-helper", "exec",
+		"--limits", `{"Nice":10,"IOClass":0,"IOPriority":0,"Cgroup":"","CPUWeight":0,"IOWeight":0}`,
+		"--", "zpool", "get"}, cmd.cmd.Args)
+
+	cmd = CommandContext(ctx, "sh", "-c", "true")
+	assert.Equal(t, []string{"sh", "-c", "true"}, cmd.cmd.Args)
+}
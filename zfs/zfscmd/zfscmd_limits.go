@@ -0,0 +1,106 @@
+package zfscmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+type IOClass int
+
+// The values are those of the Linux ioprio_set(2) system call.
+const (
+	IOClassUnchanged  IOClass = 0
+	IOClassRealtime   IOClass = 1
+	IOClassBestEffort IOClass = 2
+	IOClassIdle       IOClass = 3
+)
+
+// ProcessLimits are applied to the processes started with Cmd.Start or passed to ApplyProcessLimits,
+// e.g. zfs send, zfs recv and hooks, right after the process is started.
+type ProcessLimits struct {
+	Nice       *int
+	IOClass    IOClass
+	IOPriority int
+	// absolute path of a cgroup v2 directory, empty to leave the cgroup unchanged
+	Cgroup              string
+	CPUWeight, IOWeight int // of Cgroup, 0 to leave unchanged
+
+	setupCgroup sync.Once
+	cgroupErr   error
+}
+
+// WithProcessLimits applies l to the processes started with ctx, l may be nil.
+func WithProcessLimits(ctx context.Context, l *ProcessLimits) context.Context {
+	if l == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKeyProcessLimits, l)
+}
+
+func getProcessLimits(ctx context.Context) *ProcessLimits {
+	l, _ := ctx.Value(contextKeyProcessLimits).(*ProcessLimits)
+	return l
+}
+
+// ApplyProcessLimits applies the ProcessLimits of ctx, if any, to p.
+func ApplyProcessLimits(ctx context.Context, p *os.Process) error {
+	l := getProcessLimits(ctx)
+	if l == nil {
+		return nil
+	}
+	return l.Apply(p.Pid)
+}
+
+// Apply applies l to the process with the given pid.
+func (l *ProcessLimits) Apply(pid int) error {
+	if l.Cgroup != "" {
+		l.setupCgroup.Do(func() { l.cgroupErr = l.createCgroup() })
+		if l.cgroupErr != nil {
+			return l.cgroupErr
+		}
+		if err := writeCgroupFile(l.Cgroup, "cgroup.procs", strconv.Itoa(pid)); err != nil {
+			return err
+		}
+	}
+	if l.Nice != nil {
+		if err := setNice(pid, *l.Nice); err != nil {
+			return errors.Wrap(err, "cannot set nice value")
+		}
+	}
+	if l.IOClass != IOClassUnchanged {
+		if err := setIOPriority(pid, l.IOClass, l.IOPriority); err != nil {
+			return errors.Wrap(err, "cannot set I/O priority")
+		}
+	}
+	return nil
+}
+
+func (l *ProcessLimits) createCgroup() error {
+	if err := os.MkdirAll(l.Cgroup, 0755); err != nil {
+		return errors.Wrap(err, "cannot create cgroup")
+	}
+	for file, weight := range map[string]int{"cpu.weight": l.CPUWeight, "io.weight": l.IOWeight} {
+		if weight == 0 {
+			continue
+		}
+		if err := writeCgroupFile(l.Cgroup, file, fmt.Sprintf("%d", weight)); err != nil {
+			return errors.Wrapf(err, "the controller may need to be enabled in the parent's cgroup.subtree_control")
+		}
+	}
+	return nil
+}
+
+func writeCgroupFile(cgroup, file, content string) error {
+	p := filepath.Join(cgroup, file)
+	if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+		return errors.Wrapf(err, "cannot write %s", p)
+	}
+	return nil
+}
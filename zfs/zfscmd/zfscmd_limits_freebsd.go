@@ -0,0 +1,17 @@
+//go:build freebsd
+// +build freebsd
+
+package zfscmd
+
+import (
+	"fmt"
+	"syscall"
+)
+
+func setNice(pid, nice int) error {
+	return syscall.Setpriority(syscall.PRIO_PROCESS, pid, nice)
+}
+
+func setIOPriority(pid int, class IOClass, priority int) error {
+	return fmt.Errorf("I/O scheduling classes are not supported on this platform")
+}
@@ -0,0 +1,23 @@
+//go:build linux
+// +build linux
+
+package zfscmd
+
+import "syscall"
+
+func setNice(pid, nice int) error {
+	return syscall.Setpriority(syscall.PRIO_PROCESS, pid, nice)
+}
+
+func setIOPriority(pid int, class IOClass, priority int) error {
+	const (
+		ioprioWhoProcess = 1
+		ioprioClassShift = 13
+	)
+	prio := uintptr(class)<<ioprioClassShift | uintptr(priority)
+	_, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), prio)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package zfscmd
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessLimitsNice(t *testing.T) {
+	nice := 15 // raising the nice value does not require privileges
+	ctx := WithProcessLimits(context.Background(), &ProcessLimits{Nice: &nice, IOClass: IOClassIdle})
+	cmd := CommandContext(ctx, "sleep", "10")
+	require.NoError(t, cmd.Start())
+	defer func() {
+		_ = cmd.Process().Kill()
+		_ = cmd.Wait()
+	}()
+
+	// getpriority(2) returns 20 - nice on Linux
+	prio, err := syscall.Getpriority(syscall.PRIO_PROCESS, cmd.Process().Pid)
+	require.NoError(t, err)
+	assert.Equal(t, 20-nice, prio)
+}
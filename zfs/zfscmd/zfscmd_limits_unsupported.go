@@ -0,0 +1,14 @@
+//go:build !linux && !freebsd
+// +build !linux,!freebsd
+
+package zfscmd
+
+import "fmt"
+
+func setNice(pid, nice int) error {
+	return fmt.Errorf("setting the nice value of a process is not supported on this platform")
+}
+
+func setIOPriority(pid int, class IOClass, priority int) error {
+	return fmt.Errorf("I/O scheduling classes are not supported on this platform")
+}
@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zrepl/zrepl/daemon/logging/trace"
 )
 
 var metrics struct {
@@ -48,8 +50,8 @@ func RegisterMetrics(r prometheus.Registerer) {
 
 func waitPostPrometheus(c *Cmd, u usage, err error, now time.Time) {
 
-	if len(c.cmd.Args) < 2 {
-		getLogger(c.ctx).WithField("args", c.cmd.Args).
+	if len(c.args) < 2 {
+		getLogger(c.ctx).WithField("args", c.args).
 			Warn("prometheus: cannot turn zfs command into metric")
 		return
 	}
@@ -60,14 +62,11 @@ func waitPostPrometheus(c *Cmd, u usage, err error, now time.Time) {
 
 	jobid := getJobIDOrDefault(c.ctx, "_nojobid")
 
-	labelValues := []string{jobid, c.cmd.Args[0], c.cmd.Args[1]}
+	labelValues := []string{jobid, c.args[0], c.args[1]}
 
-	metrics.totaltime.
-		WithLabelValues(labelValues...).
-		Observe(u.total_secs)
-	metrics.systemtime.WithLabelValues(labelValues...).
-		Observe(u.system_secs)
-	metrics.usertime.WithLabelValues(labelValues...).
-		Observe(u.user_secs)
+	// the exemplars link to the span of the command, see trace.ExemplarLabels
+	trace.ObserveWithExemplar(c.ctx, metrics.totaltime.WithLabelValues(labelValues...), u.total_secs)
+	trace.ObserveWithExemplar(c.ctx, metrics.systemtime.WithLabelValues(labelValues...), u.system_secs)
+	trace.ObserveWithExemplar(c.ctx, metrics.usertime.WithLabelValues(labelValues...), u.user_secs)
 
 }
@@ -24,7 +24,7 @@ func GetReport() *Report {
 		c.mtx.RLock()
 		activeCommands = append(activeCommands, ActiveCommand{
 			Path:      c.cmd.Path,
-			Args:      c.cmd.Args,
+			Args:      c.args,
 			StartedAt: c.startedAt,
 		})
 		c.mtx.RUnlock()
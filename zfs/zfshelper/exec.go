@@ -0,0 +1,55 @@
+package zfshelper
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+// Exec executes argv through the helper listening on sockpath, with stdin, stdout
+// and stderr of this process, and returns the command's exit code.
+func Exec(sockpath string, limits *zfscmd.ProcessLimits, argv []string) (exitCode int, err error) {
+	return execWithStdio(sockpath, limits, argv, [3]*os.File{os.Stdin, os.Stdout, os.Stderr})
+}
+
+func execWithStdio(sockpath string, limits *zfscmd.ProcessLimits, argv []string, stdio [3]*os.File) (exitCode int, err error) {
+	if err := CheckCommand(argv); err != nil {
+		return 0, err
+	}
+	conn, err := net.DialUnix(network, nil, &net.UnixAddr{Name: sockpath, Net: network})
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot connect to zfs helper")
+	}
+	defer conn.Close()
+
+	req, err := json.Marshal(Request{Argv: argv, Limits: limits})
+	if err != nil {
+		return 0, err
+	}
+	if len(req) > maxMessageSize {
+		return 0, errors.Errorf("command line exceeds %d bytes", maxMessageSize)
+	}
+	rights := syscall.UnixRights(int(stdio[0].Fd()), int(stdio[1].Fd()), int(stdio[2].Fd()))
+	if _, _, err := conn.WriteMsgUnix(req, rights, nil); err != nil {
+		return 0, errors.Wrap(err, "cannot send request to zfs helper")
+	}
+
+	buf := make([]byte, maxMessageSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot read response from zfs helper")
+	}
+	var res Response
+	if err := json.Unmarshal(buf[:n], &res); err != nil {
+		return 0, errors.Wrap(err, "cannot decode response from zfs helper")
+	}
+	if res.Error != "" {
+		return 0, errors.Errorf("zfs helper: %s", res.Error)
+	}
+	return res.ExitCode, nil
+}
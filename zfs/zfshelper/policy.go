@@ -0,0 +1,181 @@
+package zfshelper
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+// Policy restricts the arguments of the whitelisted commands to the datasets,
+// properties and cgroups of the jobs in the helper's config.
+type Policy struct {
+	// the filesystems filters of the sending and snapshotting jobs
+	Filters []zfs.DatasetFilter
+	// the root_fs of the receiving jobs, the commands may operate on them and their descendants
+	Roots []*zfs.DatasetPath
+	// the subset of Roots whose jobs roll back filesystems before a forced receive,
+	// zfs rollback may only operate on their descendants
+	RollbackRoots []*zfs.DatasetPath
+	// the native properties that the jobs set, mapped to the values they may be set to.
+	// A nil map permits any value, an empty map permits only inheriting the property.
+	// Properties in the zrepl: namespace are always permitted.
+	Properties map[string]map[string]bool
+	// mountpoint may be set below these paths (browse mounts)
+	MountpointPrefixes []string
+	// the cgroups of the jobs' process limits
+	Cgroups map[string]CgroupWeights
+
+	// returns the snapshot that a resume token sends, nil for zfs.ParseResumeToken
+	resumeTokenToName func(ctx context.Context, token string) (string, error)
+}
+
+type CgroupWeights struct {
+	CPUWeight, IOWeight int
+}
+
+// CheckCommand returns an error unless argv is permitted by the package-level CheckCommand
+// and its datasets and properties are those of the configured jobs.
+func (p *Policy) CheckCommand(ctx context.Context, argv []string) error {
+	spec, args, err := parseCommand(argv)
+	if err != nil {
+		return err
+	}
+	for _, a := range args {
+		switch a.kind {
+		case argDataset:
+			err = p.checkDataset(a.value, spec.readOnly)
+		case argVersion:
+			if !strings.ContainsAny(a.value, "@#") {
+				return errors.Errorf("%q is neither a snapshot nor a bookmark", a.value)
+			}
+			err = p.checkDataset(a.value, false)
+		case argRollbackSnapshot:
+			err = p.checkRollbackSnapshot(a.value)
+		case argProperty:
+			i := strings.IndexByte(a.value, '=')
+			if i == -1 {
+				return errors.Errorf("invalid property assignment %q", a.value)
+			}
+			err = p.checkProperty(a.value[:i], a.value[i+1:], true)
+		case argPropertyName:
+			err = p.checkProperty(a.value, "", false)
+		case argUserProperty:
+			if !strings.Contains(strings.SplitN(a.value, "=", 2)[0], ":") {
+				err = errors.Errorf("%q is not a user property", a.value)
+			}
+		case argResumeToken:
+			err = p.checkResumeToken(ctx, a.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Policy) checkDataset(name string, readOnly bool) error {
+	if strings.HasPrefix(name, "@") || strings.HasPrefix(name, "#") {
+		return nil // relative to the dataset of another argument, e.g., zfs send -i @a pool/fs@b
+	}
+	fs := name
+	if i := strings.IndexAny(fs, "@#"); i != -1 {
+		fs = fs[:i]
+	}
+	dp, err := zfs.NewDatasetPath(fs)
+	if err != nil || dp.Empty() {
+		return errors.Errorf("invalid dataset %q", name)
+	}
+	for _, root := range p.Roots {
+		if dp.HasPrefix(root) || readOnly && root.HasPrefix(dp) {
+			return nil
+		}
+	}
+	for _, f := range p.Filters {
+		pass, err := f.Filter(dp)
+		if err != nil {
+			return errors.Wrapf(err, "cannot filter %q", fs)
+		}
+		if pass {
+			return nil
+		}
+	}
+	return errors.Errorf("dataset %q is neither below the root_fs of a job nor matched by the filesystems filter of a job", fs)
+}
+
+func (p *Policy) checkRollbackSnapshot(name string) error {
+	i := strings.IndexByte(name, '@')
+	if i == -1 {
+		return errors.Errorf("%q is not a snapshot", name)
+	}
+	dp, err := zfs.NewDatasetPath(name[:i])
+	if err != nil || dp.Empty() {
+		return errors.Errorf("invalid dataset %q", name)
+	}
+	for _, root := range p.RollbackRoots {
+		if dp.HasPrefix(root) {
+			return nil
+		}
+	}
+	return errors.Errorf("dataset %q is not below the root_fs of a job that permits forced receives", name[:i])
+}
+
+func (p *Policy) checkProperty(prop, value string, set bool) error {
+	if strings.HasPrefix(prop, "zrepl:") {
+		return nil
+	}
+	if prop == "mountpoint" && set {
+		mountpoint := path.Clean(value)
+		for _, prefix := range p.MountpointPrefixes {
+			prefix = path.Clean(prefix)
+			if mountpoint == prefix || strings.HasPrefix(mountpoint, prefix+"/") {
+				return nil
+			}
+		}
+	}
+	values, ok := p.Properties[prop]
+	if !ok {
+		return errors.Errorf("property %q is not set by zrepl", prop)
+	}
+	if set && values != nil && !values[value] {
+		return errors.Errorf("value %q of property %q is not set by zrepl", value, prop)
+	}
+	return nil
+}
+
+func (p *Policy) checkResumeToken(ctx context.Context, token string) error {
+	toName := p.resumeTokenToName
+	if toName == nil {
+		toName = func(ctx context.Context, token string) (string, error) {
+			t, err := zfs.ParseResumeToken(ctx, token)
+			if err != nil {
+				return "", err
+			}
+			return t.ToName, nil
+		}
+	}
+	name, err := toName(ctx, token)
+	if err != nil {
+		return errors.Wrap(err, "cannot decode resume token")
+	}
+	return p.checkDataset(name, false)
+}
+
+// checkLimits returns an error unless the cgroup of l, if any, is one of Cgroups with the same weights.
+func (p *Policy) checkLimits(l *zfscmd.ProcessLimits) error {
+	if l == nil || l.Cgroup == "" {
+		return nil
+	}
+	w, ok := p.Cgroups[l.Cgroup]
+	if !ok {
+		return errors.Errorf("cgroup %q is not configured in the process limits of a job", l.Cgroup)
+	}
+	if w != (CgroupWeights{l.CPUWeight, l.IOWeight}) {
+		return errors.Errorf("weights of cgroup %q differ from the process limits of the jobs", l.Cgroup)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package zfshelper
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/logger"
+)
+
+type Logger = logger.Logger
+
+// Listen creates the helper's socket at sockpath, replacing a stale socket.
+// Only root and, if not empty, the members of group may connect.
+func Listen(sockpath, group string) (*net.UnixListener, error) {
+	gid := -1
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return nil, err
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return nil, errors.Wrapf(err, "invalid gid of group %q", group)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(sockpath), 0755); err != nil {
+		return nil, err
+	}
+	if fi, err := os.Lstat(sockpath); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, errors.Errorf("%s exists and is not a socket", sockpath)
+		}
+		if err := os.Remove(sockpath); err != nil {
+			return nil, errors.Wrap(err, "cannot remove stale socket")
+		}
+	}
+
+	// connecting requires write permission, which the default umask does not grant
+	// to the group or others until the chmod below
+	l, err := net.ListenUnix(network, &net.UnixAddr{Name: sockpath, Net: network})
+	if err != nil {
+		return nil, err
+	}
+	mode := os.FileMode(0600)
+	if gid != -1 {
+		mode = 0660
+		if err := os.Chown(sockpath, -1, gid); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	if err := os.Chmod(sockpath, mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+type Server struct {
+	log    Logger
+	policy *Policy
+	// absolute paths of the whitelisted commands
+	binaries map[string]string
+}
+
+// NewServer looks up the whitelisted commands in $PATH,
+// the helper never executes a binary chosen by the client.
+func NewServer(log Logger, policy *Policy) (*Server, error) {
+	s := &Server{log: log, policy: policy, binaries: make(map[string]string, len(Whitelist))}
+	for name := range Whitelist {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			return nil, err
+		}
+		s.binaries[name] = path
+	}
+	return s, nil
+}
+
+// Serve handles the connections on l until ctx is done.
+func (s *Server) Serve(ctx context.Context, l *net.UnixListener) error {
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	for {
+		conn, err := l.AcceptUnix()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go s.handle(ctx, conn)
+	}
+}
+
+func (s *Server) handle(ctx context.Context, conn *net.UnixConn) {
+	defer conn.Close()
+	res, err := s.serveRequest(ctx, conn)
+	if err != nil {
+		res = Response{Error: err.Error()}
+	}
+	b, err := json.Marshal(res)
+	if err != nil {
+		panic(err)
+	}
+	if _, err := conn.Write(b); err != nil {
+		s.log.WithError(err).Warn("cannot write response")
+	}
+}
+
+func (s *Server) serveRequest(ctx context.Context, conn *net.UnixConn) (Response, error) {
+	buf := make([]byte, maxMessageSize)
+	oob := make([]byte, syscall.CmsgSpace(3*4))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		s.log.WithError(err).Warn("cannot read request")
+		return Response{}, err
+	}
+	stdio, err := receiveFiles(oob[:oobn])
+	if err != nil {
+		s.log.WithError(err).Warn("invalid request")
+		return Response{}, err
+	}
+	defer func() {
+		for _, f := range stdio {
+			f.Close()
+		}
+	}()
+	var req Request
+	if err := json.Unmarshal(buf[:n], &req); err != nil {
+		s.log.WithError(err).Warn("cannot decode request")
+		return Response{}, err
+	}
+
+	log := s.log.WithField("cmd", strings.Join(req.Argv, " "))
+	if err := s.policy.CheckCommand(ctx, req.Argv); err != nil {
+		log.WithError(err).Warn("rejecting command")
+		return Response{}, err
+	}
+	// the helper creates the cgroup as root, it must not be chosen by the client
+	if err := s.policy.checkLimits(req.Limits); err != nil {
+		log.WithError(err).Warn("rejecting command")
+		return Response{}, err
+	}
+
+	cmd := exec.Command(s.binaries[req.Argv[0]], req.Argv[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdio[0], stdio[1], stdio[2]
+	log.Debug("start command")
+	if err := cmd.Start(); err != nil {
+		log.WithError(err).Error("cannot start command")
+		return Response{}, err
+	}
+	// the client holds the other ends of the file descriptors until it receives the response
+	for _, f := range stdio {
+		f.Close()
+	}
+	stdio = nil
+	if req.Limits != nil {
+		if err := req.Limits.Apply(cmd.Process.Pid); err != nil {
+			log.WithError(err).Warn("cannot apply process limits")
+		}
+	}
+
+	// the client sends nothing but the request, so Read returns when it closes the connection
+	exited := make(chan struct{})
+	go func() {
+		_, _ = conn.Read(make([]byte, 1))
+		select {
+		case <-exited:
+		default:
+			log.Warn("client disconnected, killing command")
+			_ = cmd.Process.Kill()
+		}
+	}()
+	err = cmd.Wait()
+	close(exited)
+
+	status := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	res := Response{ExitCode: status.ExitStatus()}
+	if status.Signaled() {
+		res.ExitCode = 128 + int(status.Signal())
+	}
+	log.WithField("exit_code", res.ExitCode).Debug("command exited")
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		return Response{}, err
+	}
+	return res, nil
+}
+
+// receiveFiles returns stdin, stdout and stderr passed with SCM_RIGHTS.
+func receiveFiles(oob []byte) ([]*os.File, error) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot parse control message")
+	}
+	var fds []int
+	for i := range msgs {
+		rights, err := syscall.ParseUnixRights(&msgs[i])
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot parse control message")
+		}
+		fds = append(fds, rights...)
+	}
+	files := make([]*os.File, 0, len(fds))
+	for i, fd := range fds {
+		syscall.CloseOnExec(fd)
+		files = append(files, os.NewFile(uintptr(fd), []string{"stdin", "stdout", "stderr"}[i%3]))
+	}
+	if len(files) != 3 {
+		for _, f := range files {
+			f.Close()
+		}
+		return nil, errors.Errorf("expected stdin, stdout and stderr, got %d file descriptors", len(files))
+	}
+	return files, nil
+}
@@ -0,0 +1,190 @@
+// Package zfshelper implements the privileged helper that executes zfs and zpool
+// on behalf of an unprivileged zrepl daemon.
+//
+// The daemon executes `zrepl zfs-helper exec` (Exec) instead of zfs and zpool, see zfscmd.SetHelper.
+// Exec connects to the helper's unix socket and sends a single Request together with its
+// stdin, stdout and stderr file descriptors (SCM_RIGHTS).
+// The helper (Server) checks the command against a whitelist of the operations zrepl uses
+// and the datasets, properties and cgroups of the jobs in its config (Policy),
+// runs its own zfs or zpool binary with the passed file descriptors, applies the ProcessLimits,
+// and responds with the exit status once the command has exited.
+// If the connection is closed before, e.g., because the daemon killed Exec, the helper kills the command.
+package zfshelper
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+// SEQPACKET preserves message boundaries, so a Request and its file descriptors
+// are always received with a single read.
+const network = "unixpacket"
+
+const maxMessageSize = 1 << 16
+
+type Request struct {
+	Argv   []string
+	Limits *zfscmd.ProcessLimits `json:",omitempty"`
+}
+
+type Response struct {
+	// exit code of the command, 128+N if it was killed by signal N
+	ExitCode int
+	// set if the command could not be executed
+	Error string `json:",omitempty"`
+}
+
+// Kinds of the arguments of a whitelisted subcommand, which determine how Policy checks them.
+type argKind int
+
+const (
+	argAny argKind = iota
+	argDataset
+	// a property assignment prop=value
+	argProperty
+	// the name of a property that is inherited
+	argPropertyName
+	// a user property assignment, e.g., the hook annotations of zfs snapshot -o
+	argUserProperty
+	// the resume token of zfs send -t
+	argResumeToken
+	argPool
+	// a snapshot or bookmark, e.g., the operands of zfs destroy, which must not destroy filesystems
+	argVersion
+	// the snapshot of zfs rollback, which destroys all later snapshots and bookmarks
+	argRollbackSnapshot
+)
+
+// argSpec describes the command line of a whitelisted subcommand in getopt syntax.
+type argSpec struct {
+	// flags without value, e.g., "Hp" for -H and -p
+	flags string
+	// flags that take a value, and the kind of the value
+	valueFlags map[byte]argKind
+	// the kinds of the positional arguments before the datasets, e.g., the property list of zfs get
+	leading []argKind
+	// the positional arguments are property assignments until the first one without '=' (zfs set)
+	assignments bool
+	// the positional arguments are pools instead of datasets
+	pools bool
+	// the positional arguments are snapshots or bookmarks instead of datasets
+	versions bool
+	// the positional argument is the snapshot to roll back to instead of a dataset
+	rollback bool
+	// the subcommand does not modify datasets and may be used on the ancestors of a job's root_fs
+	readOnly bool
+}
+
+// Whitelist are the subcommands of zfs and zpool that zrepl uses and that the helper executes,
+// with the flags that zrepl passes to them. Neither -r nor -R is permitted unless listed.
+var Whitelist = map[string]map[string]argSpec{
+	"zfs": {
+		"list":     {flags: "Hpr", valueFlags: map[byte]argKind{'o': argAny, 't': argAny, 's': argAny, 'd': argAny}, readOnly: true},
+		"get":      {flags: "Hpr", valueFlags: map[byte]argKind{'o': argAny, 't': argAny, 's': argAny, 'd': argAny}, leading: []argKind{argAny}, readOnly: true},
+		"set":      {assignments: true},
+		"inherit":  {leading: []argKind{argPropertyName}},
+		"snapshot": {valueFlags: map[byte]argKind{'o': argUserProperty}},
+		"bookmark": {},
+		"send":     {flags: "wpbLceSnvP", valueFlags: map[byte]argKind{'t': argResumeToken, 'i': argDataset}},
+		"recv":     {flags: "FsuA", valueFlags: map[byte]argKind{'o': argProperty, 'x': argPropertyName}},
+		"receive":  {flags: "FsuA", valueFlags: map[byte]argKind{'o': argProperty, 'x': argPropertyName}},
+		"destroy":  {versions: true},
+		"hold":     {leading: []argKind{argAny}},
+		"release":  {leading: []argKind{argAny}},
+		"holds":    {flags: "H", readOnly: true},
+		"create":   {valueFlags: map[byte]argKind{'o': argProperty}},
+		"rollback": {flags: "r", rollback: true},
+		"load-key": {},
+		"mount":    {},
+		"version":  {},
+	},
+	"zpool": {
+		"get":    {flags: "Hp", valueFlags: map[byte]argKind{'o': argAny}, leading: []argKind{argAny}, pools: true, readOnly: true},
+		"list":   {flags: "H", valueFlags: map[byte]argKind{'o': argAny}, pools: true, readOnly: true},
+		"status": {flags: "p", pools: true, readOnly: true},
+	},
+}
+
+type arg struct {
+	kind  argKind
+	value string
+}
+
+// parseCommand checks argv against Whitelist and returns the arguments that Policy checks.
+func parseCommand(argv []string) (spec argSpec, args []arg, err error) {
+	if len(argv) == 0 {
+		return spec, nil, errors.New("empty command")
+	}
+	subcommands, ok := Whitelist[argv[0]]
+	if !ok {
+		return spec, nil, fmt.Errorf("command %q is not allowed", argv[0])
+	}
+	if len(argv) < 2 {
+		return spec, nil, fmt.Errorf("%s without subcommand is not allowed", argv[0])
+	}
+	spec, ok = subcommands[argv[1]]
+	if !ok {
+		return spec, nil, fmt.Errorf("%s %q is not allowed", argv[0], argv[1])
+	}
+
+	// getopt permutes the arguments, i.e., flags may follow positional arguments
+	var positional []string
+	for i := 2; i < len(argv); i++ {
+		a := argv[i]
+		if !strings.HasPrefix(a, "-") || a == "-" {
+			positional = append(positional, a)
+			continue
+		}
+		if strings.HasPrefix(a, "--") {
+			return spec, nil, fmt.Errorf("%s %s: argument %q is not allowed", argv[0], argv[1], a)
+		}
+		for j := 1; j < len(a); j++ {
+			if strings.IndexByte(spec.flags, a[j]) >= 0 {
+				continue
+			}
+			kind, ok := spec.valueFlags[a[j]]
+			if !ok {
+				return spec, nil, fmt.Errorf("%s %s: flag -%c is not allowed", argv[0], argv[1], a[j])
+			}
+			value := a[j+1:]
+			if value == "" {
+				if i+1 == len(argv) {
+					return spec, nil, fmt.Errorf("%s %s: flag -%c requires a value", argv[0], argv[1], a[j])
+				}
+				i++
+				value = argv[i]
+			}
+			args = append(args, arg{kind, value})
+			break
+		}
+	}
+
+	for i, a := range positional {
+		kind := argDataset
+		switch {
+		case i < len(spec.leading):
+			kind = spec.leading[i]
+		case spec.assignments && strings.Contains(a, "="):
+			kind = argProperty
+		case spec.pools:
+			kind = argPool
+		case spec.versions:
+			kind = argVersion
+		case spec.rollback:
+			kind = argRollbackSnapshot
+		}
+		args = append(args, arg{kind, a})
+	}
+	return spec, args, nil
+}
+
+// CheckCommand returns an error unless argv is a whitelisted zfs or zpool command
+// with the flags that zrepl uses, see Policy.CheckCommand for its arguments.
+func CheckCommand(argv []string) error {
+	_, _, err := parseCommand(argv)
+	return err
+}
@@ -0,0 +1,229 @@
+package zfshelper
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/zfs"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+func TestCheckCommand(t *testing.T) {
+	assert.NoError(t, CheckCommand([]string{"zfs", "send", "-w", "pool/fs@a"}))
+	assert.NoError(t, CheckCommand([]string{"zfs", "send", "-nvt", "1-abc"}))
+	assert.NoError(t, CheckCommand([]string{"zfs", "get", "-Hp", "-o", "name,value", "-r", "-d1", "name", "pool"}))
+	assert.NoError(t, CheckCommand([]string{"zfs", "send"}), "feature discovery")
+	assert.NoError(t, CheckCommand([]string{"zpool", "get", "-H", "feature@bookmarks", "pool"}))
+	assert.Error(t, CheckCommand(nil))
+	assert.Error(t, CheckCommand([]string{"zfs"}))
+	assert.Error(t, CheckCommand([]string{"zfs", "allow", "user", "send", "pool"}))
+	assert.Error(t, CheckCommand([]string{"zpool", "destroy", "pool"}))
+	assert.Error(t, CheckCommand([]string{"/sbin/zfs", "list"}))
+	assert.Error(t, CheckCommand([]string{"sh", "-c", "true"}))
+
+	assert.Error(t, CheckCommand([]string{"zfs", "destroy", "-r", "pool/fs"}))
+	assert.Error(t, CheckCommand([]string{"zfs", "destroy", "pool/fs", "-R"}), "getopt permutes arguments")
+	assert.Error(t, CheckCommand([]string{"zfs", "send", "-R", "pool/fs@a"}))
+	assert.Error(t, CheckCommand([]string{"zfs", "snapshot", "-r", "pool@a"}))
+	assert.NoError(t, CheckCommand([]string{"zfs", "rollback", "-r", "pool/fs@a"}))
+	assert.Error(t, CheckCommand([]string{"zfs", "list", "-o"}), "missing value")
+	assert.Error(t, CheckCommand([]string{"zfs", "list", "--json"}))
+}
+
+func TestPolicy(t *testing.T) {
+	path := func(p string) *zfs.DatasetPath {
+		dp, err := zfs.NewDatasetPath(p)
+		require.NoError(t, err)
+		return dp
+	}
+	filter := filters.NewDatasetMapFilter(1, true)
+	require.NoError(t, filter.Add("pool/data<", "ok"))
+	p := &Policy{
+		Filters:            []zfs.DatasetFilter{filter},
+		Roots:              []*zfs.DatasetPath{path("pool/backups/sink"), path("pool/backups/noforce")},
+		RollbackRoots:      []*zfs.DatasetPath{path("pool/backups/sink")},
+		Properties:         map[string]map[string]bool{"mountpoint": {"none": true}, "encryption": {"off": true}, "readonly": {"on": true}, "quota": nil, "canmount": {}},
+		MountpointPrefixes: []string{"/zrepl/browse"},
+		Cgroups:            map[string]CgroupWeights{"/sys/fs/cgroup/zrepl": {CPUWeight: 50}},
+		resumeTokenToName: func(ctx context.Context, token string) (string, error) {
+			return map[string]string{"1-data": "pool/data/a@b", "1-other": "pool/other@b"}[token], nil
+		},
+	}
+	check := func(argv ...string) error {
+		return p.CheckCommand(context.Background(), argv)
+	}
+
+	// datasets
+	assert.NoError(t, check("zfs", "list", "-H", "-p", "-o", "name", "-r", "-t", "filesystem,volume"))
+	assert.NoError(t, check("zfs", "send", "-w", "-i", "pool/data/a#zrepl_CURSOR", "pool/data/a@b"))
+	assert.NoError(t, check("zfs", "send", "-i", "@a", "pool/data@b"))
+	assert.Error(t, check("zfs", "send", "-i", "pool/other@a", "pool/data@b"))
+	assert.NoError(t, check("zfs", "destroy", "pool/data/a@a,b%c"))
+	assert.Error(t, check("zfs", "destroy", "pool/other@a"))
+	assert.Error(t, check("zfs", "destroy", "pool/dataset@a"))
+	assert.NoError(t, check("zfs", "recv", "-s", "-u", "pool/backups/sink/client/fs@a"))
+	assert.NoError(t, check("zfs", "destroy", "pool/backups/sink/client/fs#zrepl_CURSOR"))
+	assert.Error(t, check("zfs", "destroy", "pool/backups/sink"), "filesystems must not be destroyed")
+	assert.Error(t, check("zfs", "destroy", "pool/data/a"), "filesystems must not be destroyed")
+	assert.Error(t, check("zfs", "destroy", "pool/data/a@a", "pool/data/b"), "filesystems must not be destroyed")
+	assert.Error(t, check("zfs", "recv", "pool/backups/sinkhole@a"))
+	assert.Error(t, check("zfs", "destroy", "pool/backups@a"))
+	assert.NoError(t, check("zfs", "rollback", "-r", "pool/backups/sink/client/fs@a"))
+	assert.Error(t, check("zfs", "rollback", "-r", "pool/backups/sink/client/fs"), "not a snapshot")
+	assert.Error(t, check("zfs", "rollback", "-r", "pool/backups/noforce/fs@a"), "root does not permit forced receives")
+	assert.Error(t, check("zfs", "rollback", "-r", "pool/data/a@a"), "only receiving jobs roll back")
+	assert.NoError(t, check("zfs", "get", "-Hp", "-o", "name,property,value,source", "zrepl:placeholder", "pool/backups"), "ancestors of root_fs may be read")
+	assert.NoError(t, check("zfs", "hold", "zrepl_STEP", "pool/data/a@a", "pool/backups/sink/x@a"))
+	assert.Error(t, check("zfs", "hold", "zrepl_STEP", "pool/data/a@a", "pool/other@a"))
+	assert.Error(t, check("zfs", "mount", ""))
+	assert.NoError(t, check("zfs", "send", "-nvt", "1-data"))
+	assert.Error(t, check("zfs", "send", "-t", "1-other"))
+	assert.NoError(t, check("zpool", "status", "-p", "otherpool"), "pools are not restricted")
+
+	// properties
+	assert.NoError(t, check("zfs", "set", "zrepl:placeholder=off", "pool/backups/sink/x"))
+	assert.NoError(t, check("zfs", "set", "quota=1024", "pool/backups/sink/x"))
+	assert.NoError(t, check("zfs", "set", "mountpoint=/zrepl/browse/x/y", "readonly=on", "pool/backups/sink/x"))
+	assert.Error(t, check("zfs", "set", "mountpoint=/zrepl/browse/x/y", "readonly=off", "pool/backups/sink/x"), "readonly is not permitted")
+	assert.Error(t, check("zfs", "set", "mountpoint=/etc", "pool/backups/sink/x"))
+	assert.Error(t, check("zfs", "set", "mountpoint=/zrepl/browse/../../etc", "pool/backups/sink/x"))
+	assert.Error(t, check("zfs", "set", "canmount=on", "pool/backups/sink/x"), "only inherited")
+	assert.NoError(t, check("zfs", "inherit", "canmount", "pool/backups/sink/x"))
+	assert.NoError(t, check("zfs", "create", "-o", "zrepl:placeholder=on", "-o", "mountpoint=none", "-o", "encryption=off", "pool/backups/sink/x"))
+	assert.Error(t, check("zfs", "create", "-o", "mountpoint=/", "pool/backups/sink/x"))
+	assert.Error(t, check("zfs", "create", "-omountpoint=/", "pool/backups/sink/x"))
+	assert.NoError(t, check("zfs", "recv", "-x", "canmount", "-o", "encryption=off", "pool/backups/sink/x"))
+	assert.Error(t, check("zfs", "recv", "-x", "sharenfs", "pool/backups/sink/x"))
+	assert.NoError(t, check("zfs", "snapshot", "-o", "com.example:hook=1", "pool/data/a@b"))
+	assert.Error(t, check("zfs", "snapshot", "-o", "mountpoint=/", "pool/data/a@b"))
+
+	// cgroups
+	assert.NoError(t, p.checkLimits(nil))
+	assert.NoError(t, p.checkLimits(&zfscmd.ProcessLimits{Nice: new(int)}))
+	assert.NoError(t, p.checkLimits(&zfscmd.ProcessLimits{Cgroup: "/sys/fs/cgroup/zrepl", CPUWeight: 50}))
+	assert.Error(t, p.checkLimits(&zfscmd.ProcessLimits{Cgroup: "/sys/fs/cgroup/zrepl", CPUWeight: 10000}))
+	assert.Error(t, p.checkLimits(&zfscmd.ProcessLimits{Cgroup: "/sys/fs/cgroup/other"}))
+}
+
+func TestExec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-zfshelper")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the fake zfs echoes its arguments and stdin and exits with the status in $ZFSHELPER_TEST_EXIT
+	fakeZFS := filepath.Join(dir, "zfs")
+	script := "#!/bin/sh\necho \"$@\"\ncat\necho stderr >&2\nexit ${ZFSHELPER_TEST_EXIT:-0}\n"
+	require.NoError(t, ioutil.WriteFile(fakeZFS, []byte(script), 0755))
+
+	sockpath := filepath.Join(dir, "sub", "helper.sock")
+	l, err := Listen(sockpath, "")
+	require.NoError(t, err)
+	fi, err := os.Stat(sockpath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	pool, err := zfs.NewDatasetPath("pool")
+	require.NoError(t, err)
+	p := &Policy{Roots: []*zfs.DatasetPath{pool}}
+	s := &Server{log: logger.NewTestLogger(t), policy: p, binaries: map[string]string{"zfs": fakeZFS}}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() { served <- s.Serve(ctx, l) }()
+	defer func() {
+		cancel()
+		assert.NoError(t, <-served)
+	}()
+
+	run := func(argv []string, stdin string) (code int, stdout, stderr string, err error) {
+		files := [3]*os.File{}
+		for i := range files {
+			f, err := ioutil.TempFile(dir, "stdio")
+			require.NoError(t, err)
+			defer f.Close()
+			files[i] = f
+		}
+		_, err = files[0].WriteString(stdin)
+		require.NoError(t, err)
+		_, err = files[0].Seek(0, 0)
+		require.NoError(t, err)
+		code, err = execWithStdio(sockpath, nil, argv, files)
+		out, rerr := ioutil.ReadFile(files[1].Name())
+		require.NoError(t, rerr)
+		errOut, rerr := ioutil.ReadFile(files[2].Name())
+		require.NoError(t, rerr)
+		return code, string(out), string(errOut), err
+	}
+
+	code, stdout, stderr, err := run([]string{"zfs", "list", "-H", "pool"}, "input\n")
+	require.NoError(t, err)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "list -H pool\ninput\n", stdout)
+	assert.Equal(t, "stderr\n", stderr)
+
+	os.Setenv("ZFSHELPER_TEST_EXIT", "3")
+	defer os.Unsetenv("ZFSHELPER_TEST_EXIT")
+	code, _, _, err = run([]string{"zfs", "destroy", "pool/fs@a"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, 3, code)
+
+	_, _, _, err = run([]string{"zfs", "allow", "pool"}, "")
+	assert.Error(t, err)
+	_, _, _, err = run([]string{"zfs", "destroy", "otherpool/fs@a"}, "")
+	assert.Error(t, err, "rejected by the policy")
+}
+
+// Executed by TestZFSVersion through the helper instead of zfs, like `zrepl zfs-helper exec`.
+func TestZFSHelperProcess(t *testing.T) {
+	sockpath := os.Getenv("ZFSHELPER_TEST_SOCKPATH")
+	if sockpath == "" {
+		return
+	}
+	args := os.Args
+	for len(args) > 0 && args[0] != "--" {
+		args = args[1:]
+	}
+	code, err := Exec(sockpath, nil, args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(code)
+}
+
+func TestZFSVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-zfshelper")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fakeZFS := filepath.Join(dir, "zfs")
+	script := "#!/bin/sh\n[ \"$*\" = version ] || exit 2\necho zfs-2.1.5-1\necho zfs-kmod-2.1.5-1\n"
+	require.NoError(t, ioutil.WriteFile(fakeZFS, []byte(script), 0755))
+
+	sockpath := filepath.Join(dir, "helper.sock")
+	l, err := Listen(sockpath, "")
+	require.NoError(t, err)
+	s := &Server{log: logger.NewTestLogger(t), policy: &Policy{}, binaries: map[string]string{"zfs": fakeZFS}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = s.Serve(ctx, l) }()
+
+	// the daemon queries the version after it has switched to the helper
+	os.Setenv("ZFSHELPER_TEST_SOCKPATH", sockpath)
+	defer os.Unsetenv("ZFSHELPER_TEST_SOCKPATH")
+	zfscmd.SetHelper([]string{os.Args[0], "-test.run=^TestZFSHelperProcess$"})
+	defer zfscmd.SetHelper(nil)
+	ctx, end := trace.WithTaskFromStack(ctx)
+	defer end()
+	v, err := zfs.ZFSVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "zfs-2.1.5-1", v)
+}
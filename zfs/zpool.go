@@ -0,0 +1,197 @@
+package zfs
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+// ZPoolStatus is the state of a pool as reported by `zpool get` and `zpool status`.
+type ZPoolStatus struct {
+	Name string
+	// e.g. ONLINE, DEGRADED, FAULTED, see ZPoolHealthStates
+	Health                string
+	Size, Allocated, Free uint64
+	// percentage of the allocated space
+	Capacity int
+	// percentage of fragmentation of the free space, -1 if the pool does not report it
+	Fragmentation int
+	// the latest scrub or resilver, nil if there was none
+	Scan *ZPoolScan `json:",omitempty"`
+}
+
+// ZPoolHealthStates are the values of the health property of a pool.
+var ZPoolHealthStates = []string{"ONLINE", "DEGRADED", "FAULTED", "OFFLINE", "UNAVAIL", "REMOVED", "SUSPENDED"}
+
+type ZPoolScan struct {
+	Function string // scrub or resilver
+	State    string // in_progress, paused, finished or canceled
+	// the fraction of the scan that is done, between 0 and 1, only set if the scan is in progress or paused
+	Progress float64
+	// the errors of a finished scan
+	Errors uint64
+	// the start of a scan in progress or paused, the end of a finished or canceled scan
+	// zero if it could not be parsed
+	Time time.Time
+}
+
+// InProgress returns true if the scan is in progress or paused, and false for a nil scan.
+func (s *ZPoolScan) InProgress() bool {
+	return s != nil && (s.State == "in_progress" || s.State == "paused")
+}
+
+// ZPoolList returns the names of the imported pools.
+func ZPoolList(ctx context.Context) ([]string, error) {
+	output, err := zfscmd.CommandContext(ctx, "zpool", "list", "-H", "-o", "name").CombinedOutput()
+	if err != nil {
+		return nil, errors.Wrapf(err, "zpool list: %s", strings.TrimSpace(string(output)))
+	}
+	var pools []string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if line != "" {
+			pools = append(pools, line)
+		}
+	}
+	return pools, nil
+}
+
+var zpoolStatusProperties = []string{"health", "size", "allocated", "free", "capacity", "fragmentation"}
+
+// ZPoolGetStatus returns the status of pool.
+func ZPoolGetStatus(ctx context.Context, pool string) (*ZPoolStatus, error) {
+	output, err := zfscmd.CommandContext(ctx, "zpool", "get", "-H", "-p", "-o", "property,value",
+		strings.Join(zpoolStatusProperties, ","), pool).CombinedOutput()
+	if err != nil {
+		return nil, errors.Wrapf(err, "zpool get: %s", strings.TrimSpace(string(output)))
+	}
+	s, err := parseZPoolGet(pool, string(output))
+	if err != nil {
+		return nil, err
+	}
+	output, err = zfscmd.CommandContext(ctx, "zpool", "status", "-p", pool).CombinedOutput()
+	if err != nil {
+		return nil, errors.Wrapf(err, "zpool status: %s", strings.TrimSpace(string(output)))
+	}
+	s.Scan = parseZPoolStatusScan(string(output), time.Local)
+	return s, nil
+}
+
+func parseZPoolGet(pool, output string) (*ZPoolStatus, error) {
+	s := &ZPoolStatus{Name: pool, Fragmentation: -1}
+	seen := make(map[string]bool, len(zpoolStatusProperties))
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.SplitN(line, "\t", 2)
+		if len(fields) != 2 {
+			return nil, errors.Errorf("zpool get: unexpected output line %q", line)
+		}
+		prop, value := fields[0], fields[1]
+		seen[prop] = true
+		if prop == "health" {
+			s.Health = value
+			continue
+		}
+		if value == "-" {
+			// e.g. fragmentation without feature@spacemap_histogram
+			continue
+		}
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "zpool get: invalid value of %s", prop)
+		}
+		switch prop {
+		case "size":
+			s.Size = n
+		case "allocated":
+			s.Allocated = n
+		case "free":
+			s.Free = n
+		case "capacity":
+			s.Capacity = int(n)
+		case "fragmentation":
+			s.Fragmentation = int(n)
+		}
+	}
+	for _, prop := range zpoolStatusProperties {
+		if !seen[prop] {
+			return nil, errors.Errorf("zpool get: missing property %s", prop)
+		}
+	}
+	return s, nil
+}
+
+var (
+	zpoolStatusSectionRE = regexp.MustCompile(`^\s*([a-z]+):(\s|$)`)
+	zpoolScanFinishedRE  = regexp.MustCompile(`^(scrub repaired|resilvered) .* with (\d+) errors on (.*)$`)
+	zpoolScanOtherRE     = regexp.MustCompile(`^(scrub|resilver) (in progress since|paused since|canceled on) (.*)$`)
+	zpoolScanProgressRE  = regexp.MustCompile(`([0-9.]+)% done`)
+)
+
+// zpoolStatusTimeLayout is the format of the times in the scan section of `zpool status`, i.e., ctime(3)
+const zpoolStatusTimeLayout = "Mon Jan _2 15:04:05 2006"
+
+// parseZPoolStatusScan parses the scan section of the output of `zpool status` of a single pool.
+// It returns nil if there was no scan or if the section has an unknown format.
+func parseZPoolStatusScan(output string, loc *time.Location) *ZPoolScan {
+	var section []string
+	for _, line := range strings.Split(output, "\n") {
+		m := zpoolStatusSectionRE.FindStringSubmatch(line)
+		if len(section) > 0 && m != nil {
+			break
+		}
+		if m != nil && m[1] == "scan" {
+			section = append(section, strings.TrimSpace(strings.SplitN(line, ":", 2)[1]))
+		} else if len(section) > 0 {
+			section = append(section, strings.TrimSpace(line))
+		}
+	}
+	if len(section) == 0 {
+		return nil
+	}
+
+	s := &ZPoolScan{}
+	parseTime := func(v string) {
+		if t, err := time.ParseInLocation(zpoolStatusTimeLayout, strings.TrimSpace(v), loc); err == nil {
+			s.Time = t
+		}
+	}
+	if m := zpoolScanFinishedRE.FindStringSubmatch(section[0]); m != nil {
+		s.Function = "resilver"
+		if m[1] == "scrub repaired" {
+			s.Function = "scrub"
+		}
+		s.State = "finished"
+		s.Errors, _ = strconv.ParseUint(m[2], 10, 64)
+		parseTime(m[3])
+		return s
+	}
+	m := zpoolScanOtherRE.FindStringSubmatch(section[0])
+	if m == nil {
+		// none requested
+		return nil
+	}
+	s.Function = m[1]
+	parseTime(m[3])
+	switch m[2] {
+	case "canceled on":
+		s.State = "canceled"
+		return s
+	case "paused since":
+		s.State = "paused"
+	default:
+		s.State = "in_progress"
+	}
+	for _, line := range section[1:] {
+		if m := zpoolScanProgressRE.FindStringSubmatch(line); m != nil {
+			if p, err := strconv.ParseFloat(m[1], 64); err == nil {
+				s.Progress = p / 100
+			}
+		}
+	}
+	return s
+}
@@ -0,0 +1,91 @@
+package zfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseZPoolGet(t *testing.T) {
+	s, err := parseZPoolGet("tank", "health\tDEGRADED\nsize\t1000\nallocated\t600\nfree\t400\ncapacity\t60\nfragmentation\t-\n")
+	require.NoError(t, err)
+	assert.Equal(t, &ZPoolStatus{
+		Name:          "tank",
+		Health:        "DEGRADED",
+		Size:          1000,
+		Allocated:     600,
+		Free:          400,
+		Capacity:      60,
+		Fragmentation: -1,
+	}, s)
+
+	_, err = parseZPoolGet("tank", "health\tONLINE\nsize\t1000\n")
+	assert.Error(t, err, "missing properties")
+	_, err = parseZPoolGet("tank", "health\tONLINE\nsize\t1T\nallocated\t600\nfree\t400\ncapacity\t60\nfragmentation\t3\n")
+	assert.Error(t, err, "not parsable")
+}
+
+func TestParseZPoolStatusScan(t *testing.T) {
+	status := func(scan string) string {
+		return "  pool: tank\n state: ONLINE\nstatus: Some supported features are not enabled on the pool.\n\tThe pool can still be used.\naction: Enable all features using 'zpool upgrade'.\n" +
+			"  scan: " + scan + "\n" +
+			"config:\n\n\tNAME        STATE     READ WRITE CKSUM\n\ttank        ONLINE       0     0     0\n\nerrors: No known data errors\n"
+	}
+	at := func(s string) time.Time {
+		t, err := time.ParseInLocation(zpoolStatusTimeLayout, s, time.UTC)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+
+	tcs := []struct {
+		name string
+		scan string
+		exp  *ZPoolScan
+	}{
+		{"none", "none requested", nil},
+		{
+			"scrub finished",
+			"scrub repaired 0B in 01:02:03 with 2 errors on Sun Oct 11 01:26:04 2026",
+			&ZPoolScan{Function: "scrub", State: "finished", Errors: 2, Time: at("Sun Oct 11 01:26:04 2026")},
+		},
+		{
+			"resilver finished, days",
+			"resilvered 1.21G in 0 days 00:01:02 with 0 errors on Thu Oct  1 09:00:00 2026",
+			&ZPoolScan{Function: "resilver", State: "finished", Time: at("Thu Oct  1 09:00:00 2026")},
+		},
+		{
+			"scrub in progress",
+			"scrub in progress since Sun Oct 11 00:24:01 2026\n\t1352347345 scanned at 1.50G/s, 536870912 issued at 600M/s, 2199023255552 total\n\t0B repaired, 24.41% done, 00:43:21 to go",
+			&ZPoolScan{Function: "scrub", State: "in_progress", Progress: 0.2441, Time: at("Sun Oct 11 00:24:01 2026")},
+		},
+		{
+			"resilver without estimate",
+			"resilver in progress since Sun Oct 11 00:24:01 2026\n\t1352347345 scanned at 1.50G/s, 0 issued at 0B/s, 2199023255552 total\n\t0B resilvered, 0.00% done, no estimated completion time",
+			&ZPoolScan{Function: "resilver", State: "in_progress", Time: at("Sun Oct 11 00:24:01 2026")},
+		},
+		{
+			"scrub paused",
+			"scrub paused since Mon Oct 12 10:00:00 2026\n\tscrub started on Sun Oct 11 00:24:01 2026\n\t1352347345 scanned, 536870912 issued, 2199023255552 total\n\t0B repaired, 24.41% done",
+			&ZPoolScan{Function: "scrub", State: "paused", Progress: 0.2441, Time: at("Mon Oct 12 10:00:00 2026")},
+		},
+		{
+			"scrub canceled",
+			"scrub canceled on Mon Oct 12 10:00:00 2026",
+			&ZPoolScan{Function: "scrub", State: "canceled", Time: at("Mon Oct 12 10:00:00 2026")},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			s := parseZPoolStatusScan(status(tc.scan), time.UTC)
+			if tc.exp != nil && s != nil {
+				assert.InDelta(t, tc.exp.Progress, s.Progress, 1e-9)
+				s.Progress = tc.exp.Progress
+			}
+			assert.Equal(t, tc.exp, s)
+		})
+	}
+}